@@ -331,10 +331,18 @@ func mustBuildCored() []byte {
 		"CGO_ENABLED=0",
 	}
 
-	date := time.Now().UTC().Format(time.RFC3339)
-	cmd := exec.Command("go", "build",
+	// Stamp the commit and its time, not the current time,
+	// so rebuilding the same commit gives the same metadata.
+	cmd := exec.Command("git", "log", "-1", "--format=%H %ct")
+	cmd.Stderr = os.Stderr
+	head, err := cmd.Output()
+	must(err)
+	var commit, date string
+	_, err = fmt.Sscan(string(head), &commit, &date)
+	must(err)
+	cmd = exec.Command("go", "build",
 		"-tags", "insecure_disable_https_redirect",
-		"-ldflags", "-X main.buildDate="+date,
+		"-ldflags", "-X chain/core/build.Tag=bench -X chain/core/build.Commit="+commit+" -X chain/core/build.Date="+date,
 		"-o", "/dev/stdout",
 		"chain/cmd/cored",
 	)
@@ -34,7 +34,7 @@ var (
 	dbURL      = env.String("DATABASE_URL", "postgres:///blockcache?sslmode=disable")
 	listen     = env.String("LISTEN", ":2000")
 	target     = env.String("TARGET", "http://localhost:1999")
-	targetAuth = env.String("TARGET_AUTH", "")
+	targetAuth = env.Secret("TARGET_AUTH", "")
 	tlsCrt     = env.String("TLSCRT", "")
 	tlsKey     = env.String("TLSKEY", "")
 
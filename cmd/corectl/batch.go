@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"chain/database/pg"
+	"chain/database/sql"
+)
+
+// batchStep is a single command in a batch script.
+type batchStep struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+}
+
+// notBatchable lists commands that cannot run
+// inside a database transaction.
+var notBatchable = map[string]bool{
+	"batch":   true, // no nesting
+	"migrate": true, // some migrations manage their own transactions
+	"reset":   true,
+	"shell":   true, // reads commands from stdin
+}
+
+func init() {
+	// Registered here rather than in the commands literal,
+	// since runBatch itself refers to commands.
+	commands["batch"] = &command{runBatch}
+}
+
+func runBatch(db pg.DB, args []string) {
+	const usage = "usage: corectl batch -f script.json"
+	var flags flag.FlagSet
+	flagF := flags.String("f", "", "`file` containing the batch script (- for stdin)")
+	flags.Usage = func() {
+		fmt.Println(usage)
+		flags.PrintDefaults()
+		exit(1)
+	}
+	flags.Parse(args)
+	if *flagF == "" || len(flags.Args()) != 0 {
+		fatalln(usage)
+	}
+
+	sqlDB, ok := db.(*sql.DB)
+	if !ok {
+		fatalln("error: batch cannot be nested")
+	}
+
+	var (
+		b   []byte
+		err error
+	)
+	if *flagF == "-" {
+		b, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		b, err = ioutil.ReadFile(*flagF)
+	}
+	if err != nil {
+		fatalln("error:", err)
+	}
+
+	var steps []batchStep
+	err = json.Unmarshal(b, &steps)
+	if err != nil {
+		fatalln("error: parsing batch script:", err)
+	}
+	for i, step := range steps {
+		if commands[step.Command] == nil {
+			fatalln(fmt.Sprintf("error: step %d: unknown command %q", i, step.Command))
+		}
+		if notBatchable[step.Command] {
+			fatalln(fmt.Sprintf("error: step %d: %s cannot be used in a batch", i, step.Command))
+		}
+	}
+
+	ctx := context.Background()
+
+	// Schema migrations can't run inside the batch transaction,
+	// so bring the schema up to date before starting it.
+	migrateIfMissingSchema(ctx, sqlDB)
+
+	err = execBatch(ctx, sqlDB, steps)
+	if err != nil {
+		fatalln("error:", err)
+	}
+}
+
+// execBatch runs steps in a single transaction.
+// If any step fails, it rolls back the transaction,
+// so that none of the steps' changes are applied,
+// and returns the step's error.
+func execBatch(ctx context.Context, db *sql.DB, steps []batchStep) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	for i, step := range steps {
+		fmt.Printf("step %d: %s\n", i, step.Command)
+		err = runBatchStep(tx, commands[step.Command], step.Args)
+		if err != nil {
+			tx.Rollback(ctx)
+			return fmt.Errorf("step %d: %s: %v; batch rolled back, no changes were applied", i, step.Command, err)
+		}
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("committing batch: %v", err)
+	}
+	return nil
+}
+
+// batchExit is the panic value exit
+// uses while a batch step runs.
+type batchExit int
+
+// runBatchStep runs cmd, returning an error instead of
+// exiting if cmd fails, whether by fatalln or by a
+// usage error.
+func runBatchStep(db pg.DB, cmd *command, args []string) (err error) {
+	defer func(old func(int)) {
+		exit = old
+		if v := recover(); v != nil {
+			code, ok := v.(batchExit)
+			if !ok {
+				panic(v)
+			}
+			err = fmt.Errorf("exit status %d", code)
+		}
+	}(exit)
+	exit = func(code int) { panic(batchExit(code)) }
+	cmd.f(db, args)
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"strconv"
+	"testing"
+
+	"chain/database/pg"
+	"chain/database/pg/pgtest"
+)
+
+func TestRunBatchStep(t *testing.T) {
+	usage := func(db pg.DB, args []string) {
+		var flags flag.FlagSet
+		flags.Usage = func() { exit(1) }
+		flags.Parse(args)
+	}
+	cases := []struct {
+		f    func(pg.DB, []string)
+		args []string
+		want string
+	}{
+		{func(pg.DB, []string) {}, nil, ""},
+		{func(pg.DB, []string) { fatalln("error: boom") }, nil, "exit status 2"},
+		{usage, []string{"-bogus"}, "exit status 1"},
+	}
+	for i, c := range cases {
+		err := runBatchStep(nil, &command{c.f}, c.args)
+		var got string
+		if err != nil {
+			got = err.Error()
+		}
+		if got != c.want {
+			t.Errorf("case %d: runBatchStep() error = %q want %q", i, got, c.want)
+		}
+	}
+
+	// exit must be restored after a failing step,
+	// or a later failure would panic instead of exiting.
+	defer func(old func(int)) { exit = old }(exit)
+	var code int
+	exit = func(c int) { code = c }
+	runBatchStep(nil, &command{func(pg.DB, []string) { fatalln("error: boom") }}, nil)
+	exit(3)
+	if code != 3 {
+		t.Errorf("exit after failing step: got code %d want 3", code)
+	}
+}
+
+func TestBatchRollback(t *testing.T) {
+	ctx := context.Background()
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	pgtest.Exec(ctx, db, t, `CREATE TABLE batch_test (n integer)`)
+
+	var ran []string
+	commands["test-insert"] = &command{func(db pg.DB, args []string) {
+		ran = append(ran, "insert "+args[0])
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			fatalln("error:", err)
+		}
+		_, err = db.Exec(ctx, `INSERT INTO batch_test (n) VALUES ($1)`, n)
+		if err != nil {
+			fatalln("error:", err)
+		}
+	}}
+	commands["test-fail"] = &command{func(db pg.DB, args []string) {
+		ran = append(ran, "fail")
+		fatalln("error: step failed")
+	}}
+	defer delete(commands, "test-insert")
+	defer delete(commands, "test-fail")
+
+	steps := []batchStep{
+		{Command: "test-insert", Args: []string{"1"}},
+		{Command: "test-insert", Args: []string{"2"}},
+		{Command: "test-fail"},
+		{Command: "test-insert", Args: []string{"3"}},
+	}
+	err := execBatch(ctx, db, steps)
+	if err == nil {
+		t.Fatal("execBatch() succeeded, want error from step 2")
+	}
+	if len(ran) != 3 {
+		t.Errorf("ran %v, want to stop after the failing step", ran)
+	}
+
+	var n int
+	err = db.QueryRow(ctx, `SELECT count(*) FROM batch_test`).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("batch_test has %d rows after rollback, want 0", n)
+	}
+}
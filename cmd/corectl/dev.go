@@ -8,10 +8,10 @@ import (
 
 	"chain/core/coreunsafe"
 	"chain/core/mockhsm"
-	"chain/database/sql"
+	"chain/database/pg"
 )
 
-func reset(db *sql.DB, args []string) {
+func reset(db pg.DB, args []string) {
 	if len(args) != 0 {
 		fatalln("error: reset takes no args")
 	}
@@ -21,9 +21,10 @@ func reset(db *sql.DB, args []string) {
 	if err != nil {
 		fatalln("error:", err)
 	}
+	recordAudit(ctx, db, "reset", map[string]interface{}{"everything": true})
 }
 
-func createBlockKeyPair(db *sql.DB, args []string) {
+func createBlockKeyPair(db pg.DB, args []string) {
 	if len(args) != 0 {
 		fatalln("error: create-block-keypair takes no args")
 	}
@@ -37,3 +38,22 @@ func createBlockKeyPair(db *sql.DB, args []string) {
 
 	fmt.Printf("%x\n", pub.Pub)
 }
+
+func rotateBlockKey(db pg.DB, args []string) {
+	const usage = "usage: corectl rotate-block-key [alias]"
+	alias := "block_key"
+	if len(args) == 1 {
+		alias = args[0]
+	} else if len(args) > 1 {
+		fatalln(usage)
+	}
+	ctx := context.Background()
+	migrateIfMissingSchema(ctx, db)
+	hsm := mockhsm.New(db)
+	v, err := hsm.RotateKey(ctx, alias)
+	if err != nil {
+		fatalln("error:", err)
+	}
+
+	fmt.Printf("version %d %x\n", v.Version, []byte(v.Pub))
+}
@@ -7,12 +7,33 @@ variable; the default is to connect to the "core" database on localhost.
 
 The config commands initialize the schema if necessary.
 
+Log entries are written to stderr as they happen. Environment
+variable LOG_LEVEL sets which are written; the default, error,
+writes only errors. See chain/log.SetLevels for its format.
+
 Migrate
 
 Subcommand 'migrate' applies any pending database migrations, ensuring
 the database has an up-to-date schema.
 
-	corectl migrate
+	corectl migrate [-status] [-down n] [-plan file]
+
+Flag -status prints each migration and when it was applied,
+without applying any.
+
+Flag -down reverts the n most recently applied migrations,
+for example after a failed upgrade, before reinstalling the
+previous version of Chain Core. Only recent migrations can
+be reverted; if any of the n cannot, none are.
+
+Flag -plan writes the SQL of the pending migrations to file,
+without applying any, for review and for applying with psql.
+The script also records each migration as applied.
+
+If environment variable MIGRATIONS_DIR is set, the .sql files
+in that directory are migrations too, run in order of their
+names along with the built-in ones. Name them like the built-in
+migrations, YYYY-MM-DD.N.component.description.sql.
 
 Config Generator
 
@@ -20,9 +41,11 @@ Subcommand 'config-generator' configures a new core as a generator.
 It matches the dashboard's behavior when writing the config,
 but with additional functionality.
 
-	corectl config-generator [-s] [-w duration] [quorum] [pubkey url]...
+	corectl config-generator [-k pubkey] [-hsm-url url -hsm-token token] [-w duration] [quorum] [pubkey url]...
 
-Flag -s sets this core as a signer.
+Flag -k sets this core as a signer, with the given public key
+for signing blocks. Flags -hsm-url and -hsm-token are as for
+subcommand 'config', below.
 
 Flag -w, followed by a duration string (e.g. "24h"), sets the maximum issuance window.
 The default is 24 hours.
@@ -34,13 +57,85 @@ blockchain ID and the corresponding generator URL. Optionally, it takes
 the public key of a block signing key if the Core is to be configured
 as a signer.
 
-	corectl config [-t token] [-k pubkey] [blockchain-id] [url]
+	corectl config [-t token] [-k pubkey] [-hsm-url url -hsm-token token] [blockchain-id] [url]
+	corectl config [-t token] [-k pubkey] [-hsm-url url -hsm-token token] -m file -p pubkey
 
 Flag -t provides an access token to authenticate with the generator.
 
+Flag -m reads the blockchain ID and generator URL from a network
+membership document, as returned by the generator's
+/get-network-membership endpoint. Flag -p, required with -m, is the
+generator's block-signing pubkey, as the generator's operator
+reports it. The document must be signed with that key, and the key
+must be one of the block signers the document names. The generator
+URL in the document is the one its cored was given in PUBLIC_URL.
+
 Flag -k causes the core to be a block signer.
 Its argument is the local public key for signing blocks.
-If -k is not given, the core will be a participant (not a generator or a signer).
+If neither -k nor -hsm-url is given, the core will be a participant
+(not a generator or a signer).
+
+Flags -hsm-url and -hsm-token name the HSM that holds the block
+signing key, and the access token for it. Either subcommand given
+-hsm-url without -k makes the core a block signer, with a key the
+HSM creates, or already has, for the purpose. It prints the key's
+public key.
+
+An -hsm-url that is a PKCS#11 URI, such as
+
+	pkcs11:slot-id=0?module-path=/usr/lib/softhsm/libsofthsm2.so
+
+names a token that cored signs with through that PKCS#11 module.
+Its -hsm-token is the token's PIN. Such a token needs -k, since
+corectl cannot create keys in it, and cored must be built with
+the build tag pkcs11.
+
+Both subcommands validate the configuration before writing anything.
+They check key formats and the quorum, and that the generator, the
+block signers, and the block HSM can be reached. If there are problems,
+they print each of them and exit.
+
+Config History and Rollback
+
+Subcommand 'config-history' lists each saved version of the
+configuration, oldest first: its number, when it was saved,
+the core's role, its generator URL, and its final block height.
+A version is saved each time the core is configured or its
+final height is set.
+
+    corectl config-history
+
+Subcommand 'config-rollback' restores the configuration saved
+as the given version, for example to undo a wrong generator URL,
+and saves the result as a new version. It cannot restore a
+version saved for a different blockchain. Restart cored for the
+restored configuration to take effect.
+
+    corectl config-rollback [version]
+
+Rotate Signers
+
+Subcommand 'rotate-signers' schedules a change of the block signers
+and quorum at a future block height. The block at that height is
+signed by the current signers and carries a new consensus program,
+which requires quorum signatures from the new signers for every
+later block.
+
+    corectl rotate-signers [-cancel] [height] [quorum] [pubkey url]...
+
+Run it with the same height, quorum, and pubkeys on the generator
+and on each current signer: a signer refuses to sign a block that
+changes the consensus program unless the change is scheduled. Only
+the generator uses the URLs. Restart cored on the generator after
+scheduling a rotation, so that it connects to the new signers.
+
+Flag -cancel removes the rotation scheduled at height,
+if its block has not been made yet.
+
+Subcommand 'list-rotations' lists the scheduled rotations.
+On the generator, it also shows the block that applied each one.
+
+    corectl list-rotations
 
 Create Block Keypair
 
@@ -49,13 +144,134 @@ with the alias "block_key".
 
     corectl create-block-keypair
 
+Subcommand 'rotate-block-key' makes a new version of the MockHSM
+key with the given alias, by default "block_key", and prints its
+version and public key. Earlier versions are kept.
+
+    corectl rotate-block-key [alias]
+
+A block signer whose key is in the MockHSM signs with whichever
+version of it the consensus program requires. To move the network
+to the new version, schedule it with rotate-signers; the signer
+needs no other change.
+
+Threshold Block Key
+
+Subcommand 'threshold-keygen' makes a new block signing key held
+in shares, any threshold of which can sign a block together. It
+writes one file per share, share-1.json through share-N.json,
+and prints the block pub. No file holds the whole key.
+
+    corectl threshold-keygen [-dir dir] [threshold] [shares]
+
+Run it on a trusted, offline machine, give each share file to a
+different signer, and delete the files. Each signer sets
+BLOCK_SIGNING_SHARE to the path of its share file and is
+configured with the printed block pub as its own (-k). The
+generator must hold a share too; list the other share holders
+as signers, each with the block pub and its own URL, and a
+quorum of 1. The generator then collects the shares of the
+threshold signers for every block and combines them into one
+signature.
+
 Create Access Token
 
 Subcommand 'create-token' generates a new access token with the given name.
 Flag -net means to create a network token,
 otherwise it will create a client token.
+Flag -ttl sets how long the token is valid,
+for example 720h; by default it never expires.
+Expired tokens are deleted by cored.
+Flag -scopes limits the token to a comma-separated
+list of scopes: submit-tx, read-accounts, sign-block,
+and admin. By default the token is not limited.
+
+    corectl create-token [-net] [-ttl duration] [-scopes list] [name]
+
+Dump State and Load State
+
+Subcommand 'dump-state' writes the core's access tokens to file,
+for restoring onto a rebuilt core with 'load-state'. The file holds
+hashes of the token secrets, not the secrets themselves, and is
+encrypted with the key in environment variable STATE_KEY:
+32 random bytes, hex-encoded. Keep the key apart from the file.
+
+    corectl dump-state [file]
+    corectl load-state [file]
+
+Subcommand 'load-state' skips tokens whose IDs are already in use,
+so it can be run again if it is interrupted.
+
+Set Final Height
+
+Subcommand 'set-final-height' sets the last block height the blockchain
+will produce or accept, so that a network can shut down in an orderly way
+before migrating to a successor blockchain. Height 0 removes the limit.
+Every core in the network must be given the same final height,
+and must be restarted for it to take effect.
+
+    corectl set-final-height [height]
+
+Unlock Signed Blocks
+
+A block signer refuses to sign a second block at any height,
+or a block that does not extend the block it signed at the height
+before. Subcommand 'unlock-signed-blocks' makes it forget the blocks
+it signed at height and above, so that it can sign different ones.
+Use it only when those blocks are lost and will never be published,
+such as after the generator fails before committing them.
+
+    corectl unlock-signed-blocks [height]
+
+Reindex
+
+Subcommand 'reindex' rebuilds the annotated transactions, inputs,
+and outputs, and the account balances, from the raw blocks, as
+needed after a change to how they are annotated. The core's leader
+process rebuilds them in the background, while queries go on using
+the old index, and swaps in the new index when it has caught up.
+
+    corectl reindex [-status]
+
+Flag -status prints the progress of the most recent rebuild,
+or the error that stopped it, instead of starting one.
+A rebuild cannot start while another is in progress.
+The API's /reindex and /get-reindex-status do the same.
+
+Batch
+
+Subcommand 'batch' runs a sequence of commands from a JSON script
+in a single database transaction. If any command fails,
+the transaction is rolled back and none of the changes are applied.
+
+    corectl batch -f script.json
+
+The script is a list of steps, each naming a command and its arguments:
+
+    [
+        {"command": "config-generator", "args": ["-w", "1h"]},
+        {"command": "create-token", "args": ["-net", "peer"]}
+    ]
+
+Flag -f names the script file; "-" reads it from standard input.
+Commands migrate, reset, and batch cannot be used in a batch.
+
+Shell
+
+Subcommand 'shell' reads commands interactively, one per line,
+and runs them over a single database connection. At a terminal,
+it supports line editing, command history (the arrow keys, or
+the history command), and tab completion of command names and flags.
+
+    corectl shell
+
+Ending a command with "> file" writes its output to file,
+and ">> file" appends its output to file:
+
+    corectl> create-token -net peer > peer-token.txt
 
-    corectl create-token [-net] [name]
+A failing command prints an error but does not end the shell.
+Use exit or quit, or end the input, to leave it.
 
 Reset
 
@@ -1,56 +1,109 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"chain/core/accesstoken"
+	"chain/core/audit"
+	"chain/core/blocksigner"
+	"chain/core/build"
 	"chain/core/config"
 	"chain/core/migrate"
+	"chain/core/query"
 	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/threshold"
+	"chain/database/pg"
 	"chain/database/sql"
 	chainjson "chain/encoding/json"
 	"chain/env"
+	"chain/errors"
 	"chain/log"
+	"chain/net/http/authz"
 )
 
 const version = "1.1.3"
 
 // config vars
 var (
-	dbURL = env.String("DATABASE_URL", "postgres:///core?sslmode=disable")
+	dbURL         = env.String("DATABASE_URL", "postgres:///core?sslmode=disable")
+	migrationsDir = env.String("MIGRATIONS_DIR", "")
+	stateKey      = env.Secret("STATE_KEY", "")
+	logLevel      = env.String("LOG_LEVEL", "error")
 )
 
-// We collect log output in this buffer,
-// and display it only when there's an error.
-var logbuf bytes.Buffer
+// exit ends a command that cannot continue.
+// The shell replaces it so that a failing
+// command doesn't end the whole session.
+var exit = os.Exit
 
 type command struct {
-	f func(*sql.DB, []string)
+	f func(pg.DB, []string)
 }
 
 var commands = map[string]*command{
 	"config-generator":     {configGenerator},
 	"create-block-keypair": {createBlockKeyPair},
 	"create-token":         {createToken},
+	"create-grant":         {createGrant},
+	"delete-grant":         {deleteGrant},
+	"list-grants":          {listGrants},
+	"list-audit-log":       {listAuditLog},
+	"verify-audit-log":     {verifyAuditLog},
+	"dump-state":           {dumpState},
+	"load-state":           {loadState},
 	"config":               {configNongenerator},
+	"config-history":       {configHistory},
+	"config-rollback":      {configRollback},
 	"migrate":              {runMigrations},
+	"reindex":              {reindex},
 	"reset":                {reset},
+	"rotate-signers":       {rotateSigners},
+	"rotate-block-key":     {rotateBlockKey},
+	"list-rotations":       {listRotations},
+	"set-final-height":     {setFinalHeight},
+	"threshold-keygen":     {thresholdKeygen},
+	"unlock-signed-blocks": {unlockSignedBlocks},
 }
 
 func main() {
-	log.SetOutput(&logbuf)
+	// Log entries go to stderr as they happen, so
+	// they don't mix with the output of commands.
+	log.SetOutput(os.Stderr)
+	if len(os.Args) >= 2 && os.Args[1] == "-print-env" {
+		// Print reports invalid vars rather than exiting.
+		env.Print(os.Stdout)
+		return
+	}
+
 	env.Parse()
+	err := log.SetLevels(*logLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error: LOG_LEVEL:", errors.Detail(err))
+		os.Exit(2)
+	}
 
 	if len(os.Args) >= 2 && os.Args[1] == "-version" {
 		fmt.Printf("corectl (Chain Core) %s\n", version)
+		if len(os.Args) >= 3 && os.Args[2] == "-verbose" {
+			info := build.Current()
+			fmt.Printf("build-tag: %s\n", info.Tag)
+			fmt.Printf("build-commit: %s\n", info.Commit)
+			fmt.Printf("build-date: %s\n", info.Date)
+			fmt.Printf("build-deps: %s\n", info.Deps)
+			fmt.Printf("go-version: %s\n", info.GoVersion)
+			fmt.Printf("consensus-digest: %s\n", info.ConsensusDigest)
+		}
 		return
 	}
 
@@ -59,6 +112,13 @@ func main() {
 		fmt.Fprintln(os.Stderr, "error:", err)
 		os.Exit(2)
 	}
+	if *migrationsDir != "" {
+		err = migrate.LoadDir(*migrationsDir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(2)
+		}
+	}
 
 	if len(os.Args) < 2 {
 		help(os.Stdout)
@@ -73,24 +133,34 @@ func main() {
 	cmd.f(db, os.Args[2:])
 }
 
-func runMigrations(db *sql.DB, args []string) {
-	const usage = "usage: corectl migrate [-status]"
+func runMigrations(db pg.DB, args []string) {
+	const usage = "usage: corectl migrate [-status] [-down n] [-plan file]"
 
 	var flags flag.FlagSet
 	flagStatus := flags.Bool("status", false, "print all migrations and their status")
+	flagDown := flags.Int("down", 0, "revert the last `n` applied migrations")
+	flagPlan := flags.String("plan", "", "write the SQL of pending migrations to `file` instead of applying them")
 	flags.Usage = func() {
 		fmt.Println(usage)
 		flags.PrintDefaults()
-		os.Exit(1)
+		exit(1)
 	}
 	flags.Parse(args)
 	if len(flags.Args()) != 0 {
 		fatalln("error: migrate takes no args")
 	}
 
+	if *flagDown < 0 {
+		fatalln("error: -down must not be negative")
+	}
+
 	var err error
 	if *flagStatus {
 		err = migrate.PrintStatus(db)
+	} else if *flagDown > 0 {
+		err = migrate.Rollback(db, *flagDown)
+	} else if *flagPlan != "" {
+		err = writePlan(db, *flagPlan)
 	} else {
 		err = migrate.Run(db)
 	}
@@ -99,7 +169,29 @@ func runMigrations(db *sql.DB, args []string) {
 	}
 }
 
-func configGenerator(db *sql.DB, args []string) {
+func writePlan(db pg.DB, filename string) error {
+	plan, err := migrate.Plan(db)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	err = migrate.WritePlan(f, plan)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("wrote %d pending migrations to %s\n", len(plan), filename)
+	return nil
+}
+
+func configGenerator(db pg.DB, args []string) {
 	const usage = "usage: corectl config-generator [flags] [quorum] [pubkey url]..."
 	var (
 		quorum  int
@@ -116,23 +208,18 @@ func configGenerator(db *sql.DB, args []string) {
 	flags.Usage = func() {
 		fmt.Println(usage)
 		flags.PrintDefaults()
-		os.Exit(1)
+		exit(1)
 	}
 	flags.Parse(args)
 	args = flags.Args()
 
-	// not a blocksigner
-	if *flagK == "" && *flagHSMURL != "" {
-		fatalln("error: flag -hsm-url has no effect without -k")
-	}
-
 	// TODO(ameets): update when switching to x.509 authorization
 	if (*flagHSMURL == "") != (*flagHSMToken == "") {
 		fatalln("error: flags -hsm-url and -hsm-token must be given together")
 	}
 
 	if len(args) == 0 {
-		if *flagK != "" {
+		if *flagK != "" || *flagHSMURL != "" {
 			quorum = 1
 		}
 	} else if len(args)%2 != 1 {
@@ -163,33 +250,84 @@ func configGenerator(db *sql.DB, args []string) {
 		IsGenerator: true,
 		Quorum:      quorum,
 		Signers:     signers,
-		MaxIssuanceWindow: chainjson.Duration{
-			Duration: *maxIssuanceWindow,
+		Operational: config.Operational{
+			MaxIssuanceWindow: chainjson.Duration{
+				Duration: *maxIssuanceWindow,
+			},
 		},
-		IsSigner:            *flagK != "",
+		IsSigner:            *flagK != "" || *flagHSMURL != "",
 		BlockPub:            *flagK,
 		BlockHSMURL:         *flagHSMURL,
 		BlockHSMAccessToken: *flagHSMToken,
 	}
 
 	ctx := context.Background()
+	validate(ctx, conf)
 	migrateIfMissingSchema(ctx, db)
 	err = config.Configure(ctx, db, conf)
 	if err != nil {
 		fatalln("error:", err)
 	}
+	recordConfigure(ctx, db, conf)
 
 	fmt.Println("blockchain id", conf.BlockchainID)
+	if *flagK == "" && *flagHSMURL != "" {
+		fmt.Println("block pub", conf.BlockPub)
+	}
 }
 
-func createToken(db *sql.DB, args []string) {
-	const usage = "usage: corectl create-token [-net] [name]"
+func thresholdKeygen(db pg.DB, args []string) {
+	const usage = "usage: corectl threshold-keygen [-dir dir] [threshold] [shares]"
+	var flags flag.FlagSet
+	flagDir := flags.String("dir", ".", "write the share files to `dir`")
+	flags.Usage = func() {
+		fmt.Println(usage)
+		flags.PrintDefaults()
+		exit(1)
+	}
+	flags.Parse(args)
+	args = flags.Args()
+	if len(args) != 2 {
+		fatalln(usage)
+	}
+	t, err := strconv.Atoi(args[0])
+	if err != nil {
+		fatalln(usage)
+	}
+	n, err := strconv.Atoi(args[1])
+	if err != nil {
+		fatalln(usage)
+	}
+
+	pub, shares, err := threshold.Deal(t, n, nil)
+	if err != nil {
+		fatalln("error:", err)
+	}
+	for _, share := range shares {
+		b, err := json.MarshalIndent(share, "", "  ")
+		if err != nil {
+			fatalln("error:", err)
+		}
+		name := filepath.Join(*flagDir, fmt.Sprintf("share-%d.json", share.Index))
+		err = ioutil.WriteFile(name, append(b, '\n'), 0600)
+		if err != nil {
+			fatalln("error:", err)
+		}
+		fmt.Println("wrote", name)
+	}
+	fmt.Printf("block pub %x\n", []byte(pub))
+}
+
+func createToken(db pg.DB, args []string) {
+	const usage = "usage: corectl create-token [-net] [-ttl duration] [-scopes list] [name]"
 	var flags flag.FlagSet
 	flagNet := flags.Bool("net", false, "create a network token instead of client")
+	flagTTL := flags.Duration("ttl", 0, "expire the token after `duration` (0 means never)")
+	flagScopes := flags.String("scopes", "", "comma-separated `list` of scopes to limit the token to")
 	flags.Usage = func() {
 		fmt.Println(usage)
 		flags.PrintDefaults()
-		os.Exit(1)
+		exit(1)
 	}
 	flags.Parse(args)
 	args = flags.Args()
@@ -201,17 +339,212 @@ func createToken(db *sql.DB, args []string) {
 	migrateIfMissingSchema(ctx, db)
 	accessTokens := &accesstoken.CredentialStore{DB: db}
 	typ := map[bool]string{true: "network", false: "client"}[*flagNet]
-	tok, err := accessTokens.Create(ctx, args[0], typ)
+	var scopes []string
+	if *flagScopes != "" {
+		scopes = strings.Split(*flagScopes, ",")
+	}
+	tok, err := accessTokens.Create(ctx, args[0], typ, *flagTTL, scopes)
 	if err != nil {
 		fatalln("error:", err)
 	}
+	recordAudit(ctx, db, "create-access-token", map[string]interface{}{
+		"id":     args[0],
+		"type":   typ,
+		"ttl":    flagTTL.String(),
+		"scopes": scopes,
+	})
 	fmt.Println(tok.Token)
 }
 
-func configNongenerator(db *sql.DB, args []string) {
-	const usage = "usage: corectl config [flags] [blockchain-id] [generator-url]"
+func createGrant(db pg.DB, args []string) {
+	const usage = "usage: corectl create-grant [policy] [guard-type] [guard-data]"
+	if len(args) < 2 || len(args) > 3 {
+		fatalln(usage)
+	}
+	var guardData []byte
+	if len(args) == 3 {
+		guardData = []byte(args[2])
+	}
+
+	ctx := context.Background()
+	migrateIfMissingSchema(ctx, db)
+	grants := &authz.Store{DB: db}
+	g, err := grants.Create(ctx, args[1], guardData, args[0])
+	if err != nil {
+		fatalln("error:", err)
+	}
+	recordAudit(ctx, db, "create-authorization-grant", g)
+	fmt.Printf("%s\t%s\t%s\n", g.Policy, g.GuardType, g.GuardData)
+}
+
+func deleteGrant(db pg.DB, args []string) {
+	const usage = "usage: corectl delete-grant [policy] [guard-type] [guard-data]"
+	if len(args) < 2 || len(args) > 3 {
+		fatalln(usage)
+	}
+	var guardData []byte
+	if len(args) == 3 {
+		guardData = []byte(args[2])
+	}
+
+	ctx := context.Background()
+	grants := &authz.Store{DB: db}
+	err := grants.Delete(ctx, args[1], guardData, args[0])
+	if err != nil {
+		fatalln("error:", err)
+	}
+	recordAudit(ctx, db, "delete-authorization-grant", map[string]interface{}{
+		"guard_type": args[1],
+		"guard_data": string(guardData),
+		"policy":     args[0],
+	})
+}
+
+func listGrants(db pg.DB, args []string) {
+	const usage = "usage: corectl list-grants"
+	if len(args) != 0 {
+		fatalln(usage)
+	}
+
+	grants, err := (&authz.Store{DB: db}).List(context.Background())
+	if err != nil {
+		fatalln("error:", err)
+	}
+	for _, g := range grants {
+		fmt.Printf("%s\t%s\t%s\n", g.Policy, g.GuardType, g.GuardData)
+	}
+}
+
+func listAuditLog(db pg.DB, args []string) {
+	const usage = "usage: corectl list-audit-log [-after seq]"
+	var flags flag.FlagSet
+	flagAfter := flags.String("after", "", "list entries after sequence number `seq`")
+	flags.Usage = func() {
+		fmt.Println(usage)
+		flags.PrintDefaults()
+		exit(1)
+	}
+	flags.Parse(args)
+	if len(flags.Args()) != 0 {
+		fatalln(usage)
+	}
+
+	ctx := context.Background()
+	after := *flagAfter
+	for {
+		entries, next, err := audit.List(ctx, db, after, 100)
+		if err != nil {
+			fatalln("error:", err)
+		}
+		for _, e := range entries {
+			fmt.Printf("%d\t%s\t%s\t%s\t%s\n", e.Seq, e.Timestamp.Format(time.RFC3339), e.Actor, e.Action, *e.Detail)
+		}
+		if len(entries) < 100 {
+			return
+		}
+		after = next
+	}
+}
+
+func verifyAuditLog(db pg.DB, args []string) {
+	const usage = "usage: corectl verify-audit-log"
+	if len(args) != 0 {
+		fatalln(usage)
+	}
+
+	err := audit.Verify(context.Background(), db)
+	if err != nil {
+		fatalln("error:", errors.Detail(err))
+	}
+	fmt.Println("ok")
+}
+
+// recordAudit records action in the audit
+// log as taken by the user running corectl.
+func recordAudit(ctx context.Context, db pg.DB, action string, detail interface{}) {
+	_, err := audit.Record(ctx, db, audit.LocalActor(), action, detail)
+	if err != nil {
+		fatalln("error:", err)
+	}
+}
+
+// recordConfigure records the configuration of the
+// core, leaving out its secrets, in the audit log.
+func recordConfigure(ctx context.Context, db pg.DB, conf *config.Config) {
+	recordAudit(ctx, db, "configure", map[string]interface{}{
+		"is_generator":  conf.IsGenerator,
+		"is_signer":     conf.IsSigner,
+		"blockchain_id": conf.BlockchainID,
+		"generator_url": conf.GeneratorURL,
+	})
+}
+
+// readStateKey decodes the hex-encoded key
+// that encrypts state dumps from STATE_KEY.
+func readStateKey() []byte {
+	if *stateKey == "" {
+		fatalln("error: STATE_KEY must be set")
+	}
+	key, err := hex.DecodeString(*stateKey)
+	if err != nil || len(key) != accesstoken.ArchiveKeySize {
+		fatalln("error: STATE_KEY must be", accesstoken.ArchiveKeySize, "hex-encoded bytes")
+	}
+	return key
+}
+
+func dumpState(db pg.DB, args []string) {
+	const usage = "usage: corectl dump-state [file]"
+	if len(args) != 1 {
+		fatalln(usage)
+	}
+	key := readStateKey()
+
+	f, err := os.Create(args[0])
+	if err != nil {
+		fatalln("error:", err)
+	}
+	ctx := context.Background()
+	accessTokens := &accesstoken.CredentialStore{DB: db}
+	err = accessTokens.ExportAll(ctx, f, key)
+	if err != nil {
+		f.Close()
+		fatalln("error:", err)
+	}
+	err = f.Close()
+	if err != nil {
+		fatalln("error:", err)
+	}
+	fmt.Println("wrote access tokens to", args[0])
+}
+
+func loadState(db pg.DB, args []string) {
+	const usage = "usage: corectl load-state [file]"
+	if len(args) != 1 {
+		fatalln(usage)
+	}
+	key := readStateKey()
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		fatalln("error:", err)
+	}
+	defer f.Close()
+	ctx := context.Background()
+	migrateIfMissingSchema(ctx, db)
+	accessTokens := &accesstoken.CredentialStore{DB: db}
+	n, err := accessTokens.ImportAll(ctx, f, key)
+	if err != nil {
+		fatalln("error:", err)
+	}
+	fmt.Println("loaded", n, "access tokens")
+}
+
+func configNongenerator(db pg.DB, args []string) {
+	const usage = "usage: corectl config [flags] [blockchain-id] [generator-url]\n       corectl config [flags] -m membership-file -p pubkey"
 	var flags flag.FlagSet
 	flagT := flags.String("t", "", "generator access `token`")
+	flagM := flags.String("m", "", "take the blockchain ID and generator URL from the membership document in `file`")
+	flagP := flags.String("p", "", "`pubkey` that must have signed the membership document")
 	flagK := flags.String("k", "", "local `pubkey` for signing blocks")
 	flagHSMURL := flags.String("hsm-url", "", "hsm `url` for signing blocks (mockhsm if empty)")
 	flagHSMToken := flags.String("hsm-token", "", "hsm `access-token` for connecting to hsm")
@@ -219,47 +552,285 @@ func configNongenerator(db *sql.DB, args []string) {
 	flags.Usage = func() {
 		fmt.Println(usage)
 		flags.PrintDefaults()
-		os.Exit(1)
+		exit(1)
 	}
 	flags.Parse(args)
 	args = flags.Args()
-	if len(args) < 2 {
+	if (*flagM == "" && len(args) < 2) || (*flagM != "" && len(args) != 0) || (*flagM == "") != (*flagP == "") {
 		fatalln(usage)
 	}
 
-	// not a blocksigner
-	if *flagK == "" && *flagHSMURL != "" {
-		fatalln("error: flag -hsm-url has no effect without -k")
-	}
-
 	// TODO(ameets): update when switching to x.509 authorization
 	if (*flagHSMURL == "") != (*flagHSMToken == "") {
 		fatalln("error: flags -hsm-url and -hsm-token must be given together")
 	}
 
 	var conf config.Config
-	err := conf.BlockchainID.UnmarshalText([]byte(args[0]))
-	if err != nil {
-		fatalln("error: invalid blockchain ID:", err)
+	if *flagM != "" {
+		m := readMembership(*flagM, *flagP)
+		conf.BlockchainID = m.BlockchainID
+		conf.GeneratorURL = m.GeneratorURL
+	} else {
+		err := conf.BlockchainID.UnmarshalText([]byte(args[0]))
+		if err != nil {
+			fatalln("error: invalid blockchain ID:", err)
+		}
+		conf.GeneratorURL = args[1]
 	}
-	conf.GeneratorURL = args[1]
 	conf.GeneratorAccessToken = *flagT
-	conf.IsSigner = *flagK != ""
+	conf.IsSigner = *flagK != "" || *flagHSMURL != ""
 	conf.BlockPub = *flagK
 	conf.BlockHSMURL = *flagHSMURL
 	conf.BlockHSMAccessToken = *flagHSMToken
 
 	ctx := context.Background()
+	validate(ctx, &conf)
 	migrateIfMissingSchema(ctx, db)
-	err = config.Configure(ctx, db, &conf)
+	err := config.Configure(ctx, db, &conf)
+	if err != nil {
+		fatalln("error:", err)
+	}
+	recordConfigure(ctx, db, &conf)
+	if *flagK == "" && *flagHSMURL != "" {
+		fmt.Println("block pub", conf.BlockPub)
+	}
+}
+
+// readMembership reads the signed membership document in the
+// named file, and verifies that it was signed with pubkey, in
+// hex, which the operator got from the generator's operator.
+// It prints the network the document describes.
+func readMembership(filename, pubkey string) *config.Membership {
+	pub, err := hex.DecodeString(pubkey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		fatalln("error: invalid pubkey:", pubkey)
+	}
+	b, err := ioutil.ReadFile(filename)
 	if err != nil {
 		fatalln("error:", err)
 	}
+	var doc config.SignedMembership
+	err = json.Unmarshal(b, &doc)
+	if err != nil {
+		fatalln("error: invalid membership document:", err)
+	}
+	m, err := config.VerifyMembership(&doc, ed25519.PublicKey(pub))
+	if err != nil {
+		fatalln("error:", err)
+	}
+	fmt.Printf("membership document signed by %x\n", []byte(doc.Pubkey))
+	fmt.Println("blockchain id", m.BlockchainID)
+	fmt.Println("generator url", m.GeneratorURL)
+	fmt.Printf("quorum %d of %d block signers at height %d\n", m.Quorum, len(m.Signers), m.Height)
+	return m
+}
+
+func setFinalHeight(db pg.DB, args []string) {
+	const usage = "usage: corectl set-final-height [height]"
+	if len(args) != 1 {
+		fatalln(usage)
+	}
+	height, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		fatalln(usage)
+	}
+
+	ctx := context.Background()
+	err = config.SetFinalBlockHeight(ctx, db, height)
+	if err != nil {
+		fatalln("error:", err)
+	}
+	fmt.Println("restart cored for the new final height to take effect")
+}
+
+func unlockSignedBlocks(db pg.DB, args []string) {
+	const usage = "usage: corectl unlock-signed-blocks [height]"
+	if len(args) != 1 {
+		fatalln(usage)
+	}
+	height, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		fatalln(usage)
+	}
+
+	ctx := context.Background()
+	n, err := blocksigner.UnlockHeights(ctx, db, height)
+	if err != nil {
+		fatalln("error:", err)
+	}
+	fmt.Printf("forgot %d signed blocks at height %d and above\n", n, height)
+}
+
+func rotateSigners(db pg.DB, args []string) {
+	const usage = "usage: corectl rotate-signers [-cancel] [height] [quorum] [pubkey url]..."
+	var flags flag.FlagSet
+	flagCancel := flags.Bool("cancel", false, "cancel the rotation scheduled at height")
+	flags.Usage = func() {
+		fmt.Println(usage)
+		flags.PrintDefaults()
+		exit(1)
+	}
+	flags.Parse(args)
+	args = flags.Args()
+	if len(args) < 1 {
+		fatalln(usage)
+	}
+	height, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		fatalln(usage)
+	}
+
+	ctx := context.Background()
+	if *flagCancel {
+		if len(args) != 1 {
+			fatalln(usage)
+		}
+		err = config.CancelRotation(ctx, db, height)
+		if err != nil {
+			fatalln("error:", err)
+		}
+		fmt.Println("restart cored on the generator for the cancellation to take effect")
+		return
+	}
+
+	if len(args) < 4 || len(args)%2 != 0 {
+		fatalln(usage)
+	}
+	quorum, err := strconv.Atoi(args[1])
+	if err != nil {
+		fatalln(usage)
+	}
+	var signers []config.BlockSigner
+	for i := 2; i < len(args); i += 2 {
+		pubkey, err := hex.DecodeString(args[i])
+		if err != nil {
+			fatalln(usage)
+		}
+		signers = append(signers, config.BlockSigner{
+			Pubkey: pubkey,
+			URL:    args[i+1],
+		})
+	}
+
+	r, err := config.ProposeRotation(ctx, db, height, signers, quorum)
+	if err != nil {
+		fatalln("error:", err)
+	}
+	fmt.Printf("consensus program %x scheduled at height %d\n", []byte(r.Program), r.Height)
+	fmt.Println("restart cored on the generator for the rotation to take effect")
+}
+
+func listRotations(db pg.DB, args []string) {
+	const usage = "usage: corectl list-rotations"
+	if len(args) != 0 {
+		fatalln(usage)
+	}
+
+	ctx := context.Background()
+	rotations, err := config.Rotations(ctx, db)
+	if err != nil {
+		fatalln("error:", err)
+	}
+	for _, r := range rotations {
+		status := "pending"
+		if r.AppliedBlock != nil {
+			status = "applied in block " + r.AppliedBlock.String()
+		}
+		fmt.Printf("%d\t%d of %d signers\t%s\n", r.Height, r.Quorum, len(r.Signers), status)
+		for _, s := range r.Signers {
+			fmt.Printf("\t%x %s\n", []byte(s.Pubkey), s.URL)
+		}
+	}
+}
+
+func reindex(db pg.DB, args []string) {
+	const usage = "usage: corectl reindex [-status]"
+	var flags flag.FlagSet
+	flagStatus := flags.Bool("status", false, "print the status of the last rebuild instead of starting one")
+	flags.Usage = func() {
+		fmt.Println(usage)
+		flags.PrintDefaults()
+		exit(1)
+	}
+	flags.Parse(args)
+	if len(flags.Args()) != 0 {
+		fatalln(usage)
+	}
+
+	ctx := context.Background()
+	if !*flagStatus {
+		_, err := query.RequestReindex(ctx, db)
+		if err != nil {
+			fatalln("error:", err)
+		}
+		fmt.Println("the leader will rebuild the index in the background")
+		fmt.Println("run corectl reindex -status to follow its progress")
+		return
+	}
+
+	st, err := query.GetReindexStatus(ctx, db)
+	if err != nil {
+		fatalln("error:", err)
+	}
+	switch {
+	case st == nil:
+		fmt.Println("no rebuild has been requested")
+	case st.FinishedAt != nil && st.Error != "":
+		fmt.Printf("failed at %s after block %d: %s\n", st.FinishedAt.Format(time.RFC3339), st.Height, st.Error)
+	case st.FinishedAt != nil:
+		fmt.Printf("finished at %s through block %d\n", st.FinishedAt.Format(time.RFC3339), st.Height)
+	case st.StartedAt != nil:
+		fmt.Printf("rebuilding since %s, through block %d so far\n", st.StartedAt.Format(time.RFC3339), st.Height)
+	default:
+		fmt.Printf("requested at %s, not yet started\n", st.RequestedAt.Format(time.RFC3339))
+	}
+}
+
+func configHistory(db pg.DB, args []string) {
+	const usage = "usage: corectl config-history"
+	if len(args) != 0 {
+		fatalln(usage)
+	}
+
+	ctx := context.Background()
+	versions, err := config.History(ctx, db)
+	if err != nil {
+		fatalln("error:", err)
+	}
+	for _, v := range versions {
+		c := v.Config
+		role := "participant"
+		if c.IsGenerator {
+			role = "generator"
+		} else if c.IsSigner {
+			role = "signer"
+		}
+		fmt.Printf("%d\t%s\t%s\tgenerator-url=%q final-height=%d\n",
+			v.Version, v.SavedAt.Format(time.RFC3339), role, c.GeneratorURL, c.FinalBlockHeight)
+	}
+}
+
+func configRollback(db pg.DB, args []string) {
+	const usage = "usage: corectl config-rollback [version]"
+	if len(args) != 1 {
+		fatalln(usage)
+	}
+	version, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		fatalln(usage)
+	}
+
+	ctx := context.Background()
+	err = config.Rollback(ctx, db, version)
+	if err != nil {
+		fatalln("error:", err)
+	}
+	fmt.Println("restart cored for the restored config to take effect")
 }
 
 // migrateIfMissingSchema will migrate the provided database only
 // if the database is blank without any migrations.
-func migrateIfMissingSchema(ctx context.Context, db *sql.DB) {
+func migrateIfMissingSchema(ctx context.Context, db pg.DB) {
 	const q = `SELECT to_regclass('migrations') IS NOT NULL`
 	var initialized bool
 	err := db.QueryRow(ctx, q).Scan(&initialized)
@@ -276,19 +847,34 @@ func migrateIfMissingSchema(ctx context.Context, db *sql.DB) {
 	}
 }
 
+// validate checks conf with config.Validate,
+// and exits listing every problem if it is invalid.
+func validate(ctx context.Context, conf *config.Config) {
+	err := config.Validate(ctx, conf)
+	if errors.Root(err) == config.ErrInvalidConfig {
+		for _, p := range errors.Data(err)["problems"].([]error) {
+			fmt.Fprintln(os.Stderr, "problem:", p)
+		}
+	}
+	if err != nil {
+		fatalln("error:", err)
+	}
+}
+
 func fatalln(v ...interface{}) {
-	io.Copy(os.Stderr, &logbuf)
 	fmt.Fprintln(os.Stderr, v...)
-	os.Exit(2)
+	exit(2)
 }
 
 func help(w io.Writer) {
-	fmt.Fprintln(w, "usage: corectl [-version] [command] [arguments]")
+	fmt.Fprintln(w, "usage: corectl [-version [-verbose] | -print-env] [command] [arguments]")
 	fmt.Fprint(w, "\nThe commands are:\n\n")
 	for name := range commands {
 		fmt.Fprintln(w, "\t", name)
 	}
 	fmt.Fprint(w, "\nFlags:\n")
 	fmt.Fprintln(w, "\t-version   print version information")
+	fmt.Fprintln(w, "\t-verbose   with -version, also print build information")
+	fmt.Fprintln(w, "\t-print-env print the environment variables corectl reads")
 	fmt.Fprintln(w)
 }
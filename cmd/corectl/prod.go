@@ -2,12 +2,16 @@
 
 package main
 
-import "chain/database/sql"
+import "chain/database/pg"
 
-func reset(db *sql.DB, args []string) {
+func reset(db pg.DB, args []string) {
 	fatalln("error: reset disabled in prod build")
 }
 
-func createBlockKeyPair(db *sql.DB, args []string) {
+func createBlockKeyPair(db pg.DB, args []string) {
 	fatalln("error: create-block-keypair disabled in prod build")
 }
+
+func rotateBlockKey(db pg.DB, args []string) {
+	fatalln("error: rotate-block-key disabled in prod build")
+}
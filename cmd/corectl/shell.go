@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/ssh/terminal"
+
+	"chain/database/pg"
+	"chain/errors"
+)
+
+const shellPrompt = "corectl> "
+
+// commandFlags lists the flags of each command,
+// for tab completion in the shell.
+var commandFlags = map[string][]string{
+	"batch":            {"-f"},
+	"config":           {"-t", "-k", "-hsm-url", "-hsm-token"},
+	"config-generator": {"-w", "-k", "-hsm-url", "-hsm-token"},
+	"create-token":     {"-net"},
+	"migrate":          {"-status"},
+}
+
+// shellBuiltins are commands handled by the shell itself.
+var shellBuiltins = []string{"exit", "help", "history", "quit"}
+
+// shellExit is the panic value used to unwind
+// a command that calls exit inside the shell.
+type shellExit int
+
+func init() {
+	commands["shell"] = &command{runShell}
+}
+
+// runShell reads and runs commands interactively. All commands
+// share the database connection opened by main, so each one
+// avoids the cost of connecting anew. A command's output can
+// be written to a file by ending it with "> file" or ">> file".
+func runShell(db pg.DB, args []string) {
+	if len(args) != 0 {
+		fatalln("error: shell takes no args")
+	}
+
+	var (
+		history []string
+		read    func() (string, error)
+	)
+	if fd := int(os.Stdin.Fd()); terminal.IsTerminal(fd) {
+		term := terminal.NewTerminal(struct {
+			io.Reader
+			io.Writer
+		}{os.Stdin, os.Stdout}, shellPrompt)
+		term.AutoCompleteCallback = complete
+		read = func() (string, error) {
+			// Leave raw mode while commands run,
+			// so their output is written as usual.
+			st, err := terminal.MakeRaw(fd)
+			if err != nil {
+				return "", err
+			}
+			defer terminal.Restore(fd, st)
+			return term.ReadLine()
+		}
+	} else {
+		scanner := bufio.NewScanner(os.Stdin)
+		read = func() (string, error) {
+			if !scanner.Scan() {
+				if scanner.Err() != nil {
+					return "", scanner.Err()
+				}
+				return "", io.EOF
+			}
+			return scanner.Text(), nil
+		}
+	}
+
+	for {
+		line, err := read()
+		if err == io.EOF {
+			fmt.Println()
+			return
+		} else if err != nil {
+			fatalln("error:", err)
+		}
+		words, err := splitWords(line)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			continue
+		}
+		if len(words) == 0 {
+			continue
+		}
+		history = append(history, line)
+
+		switch words[0] {
+		case "exit", "quit":
+			return
+		case "help":
+			help(os.Stdout)
+			fmt.Println("The shell also accepts exit, history, and quit.")
+			fmt.Println("End a command with \"> file\" or \">> file\" to write its output to file.")
+			continue
+		case "history":
+			for i, h := range history {
+				fmt.Printf("%5d  %s\n", i+1, h)
+			}
+			continue
+		case "shell":
+			fmt.Fprintln(os.Stderr, "error: already in a shell")
+			continue
+		}
+		cmd := commands[words[0]]
+		if cmd == nil {
+			fmt.Fprintln(os.Stderr, "unknown command:", words[0])
+			continue
+		}
+		args, out, err := redirect(words[1:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			continue
+		}
+		runShellCommand(db, cmd, args, out)
+	}
+}
+
+// runShellCommand runs cmd, writing its output to the
+// file described by out (if any) instead of stdout.
+// If cmd fails, the shell reports the exit status
+// and carries on.
+func runShellCommand(db pg.DB, cmd *command, args []string, out *shellOutput) {
+	stdout := os.Stdout
+	if out != nil {
+		flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if out.append {
+			flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+		f, err := os.OpenFile(out.path, flag, 0644)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return
+		}
+		defer f.Close()
+		os.Stdout = f
+	}
+
+	defer func(old func(int)) {
+		exit = old
+		os.Stdout = stdout
+		if v := recover(); v != nil {
+			code, ok := v.(shellExit)
+			if !ok {
+				panic(v)
+			}
+			fmt.Fprintf(os.Stderr, "exit status %d\n", code)
+		}
+	}(exit)
+	exit = func(code int) { panic(shellExit(code)) }
+	cmd.f(db, args)
+}
+
+type shellOutput struct {
+	path   string
+	append bool
+}
+
+// redirect removes a trailing output redirection
+// ("> file" or ">> file") from words.
+func redirect(words []string) ([]string, *shellOutput, error) {
+	for i, w := range words {
+		var out shellOutput
+		switch {
+		case strings.HasPrefix(w, ">>"):
+			out.append = true
+			out.path = w[2:]
+		case strings.HasPrefix(w, ">"):
+			out.path = w[1:]
+		default:
+			continue
+		}
+		rest := words[i+1:]
+		if out.path == "" && len(rest) > 0 {
+			out.path, rest = rest[0], rest[1:]
+		}
+		if out.path == "" || len(rest) > 0 {
+			return nil, nil, errors.New("redirection must be \"> file\" or \">> file\" at the end of the command")
+		}
+		return words[:i], &out, nil
+	}
+	return words, nil, nil
+}
+
+// splitWords splits line into words separated by spaces.
+// Single or double quotes group characters, including
+// spaces, into one word.
+func splitWords(line string) ([]string, error) {
+	var (
+		words []string
+		word  []rune
+		quote rune
+		in    bool // whether a word is in progress
+	)
+	for _, c := range line {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			word = append(word, c)
+		case c == '\'' || c == '"':
+			quote = c
+			in = true
+		case c == ' ' || c == '\t':
+			if in {
+				words = append(words, string(word))
+				word, in = nil, false
+			}
+		default:
+			word = append(word, c)
+			in = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if in {
+		words = append(words, string(word))
+	}
+	return words, nil
+}
+
+// complete implements tab completion of command names
+// and flags. It is a terminal.AutoCompleteCallback.
+func complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+	start := strings.LastIndexAny(line[:pos], " \t") + 1
+	prefix := line[start:pos]
+
+	var candidates []string
+	if strings.TrimSpace(line[:start]) == "" {
+		for name := range commands {
+			candidates = append(candidates, name)
+		}
+		candidates = append(candidates, shellBuiltins...)
+	} else if strings.HasPrefix(prefix, "-") {
+		candidates = commandFlags[strings.Fields(line)[0]]
+	}
+
+	var matches []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) {
+			matches = append(matches, c)
+		}
+	}
+	if len(matches) == 0 {
+		return "", 0, false
+	}
+	sort.Strings(matches)
+	completion := commonPrefix(matches)
+	if len(matches) == 1 {
+		completion += " "
+	}
+	if completion == prefix {
+		return "", 0, false
+	}
+	return line[:start] + completion + line[pos:], start + len(completion), true
+}
+
+func commonPrefix(a []string) string {
+	p := a[0]
+	for _, s := range a[1:] {
+		for !strings.HasPrefix(s, p) {
+			p = p[:len(p)-1]
+		}
+	}
+	return p
+}
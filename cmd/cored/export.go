@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// s3Store is a core.ExportStore in an S3-compatible bucket.
+// Credentials come from the environment, as for any
+// aws-sdk-go client.
+type s3Store struct {
+	client *s3.S3
+	bucket string
+}
+
+func newS3Store(bucket, region, endpoint string) *s3Store {
+	conf := aws.DefaultConfig.Copy()
+	if region != "" {
+		conf = conf.WithRegion(region)
+	}
+	if endpoint != "" {
+		// Most S3-compatible services don't
+		// support virtual-hosted buckets.
+		conf = conf.WithEndpoint(endpoint).WithS3ForcePathStyle(true)
+	}
+	return &s3Store{client: s3.New(conf), bucket: bucket}
+}
+
+func (s *s3Store) Put(ctx context.Context, key, contentType string, data []byte) error {
+	_, err := s.client.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType),
+	})
+	return err
+}
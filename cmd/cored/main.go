@@ -4,16 +4,20 @@ package main
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/hex"
+	stdjson "encoding/json"
 	"expvar"
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
@@ -24,18 +28,23 @@ import (
 	"chain/core/account"
 	"chain/core/asset"
 	"chain/core/blocksigner"
+	"chain/core/build"
 	"chain/core/config"
 	"chain/core/fetch"
 	"chain/core/generator"
 	"chain/core/leader"
 	"chain/core/migrate"
 	"chain/core/pin"
+	"chain/core/pkcs11hsm"
 	"chain/core/query"
+	"chain/core/query/graphql"
 	"chain/core/rpc"
 	"chain/core/txbuilder"
 	"chain/core/txdb"
 	"chain/core/txfeed"
+	"chain/core/webhook"
 	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/threshold"
 	"chain/database/pg"
 	"chain/database/sql"
 	"chain/encoding/json"
@@ -45,9 +54,12 @@ import (
 	chainlog "chain/log"
 	"chain/log/rotation"
 	"chain/log/splunk"
+	"chain/net/http/authz"
 	"chain/net/http/limit"
 	"chain/protocol"
 	"chain/protocol/bc"
+	"chain/protocol/mempool"
+	"chain/protocol/vm"
 )
 
 const (
@@ -58,36 +70,70 @@ const (
 var (
 	// config vars
 	tlsCrt        = env.String("TLSCRT", "")
-	tlsKey        = env.String("TLSKEY", "")
+	tlsKey        = env.Secret("TLSKEY", "")
+	tlsClientCA   = env.String("TLSCLIENTCA", "") // PEM CA certs for client certificates
 	listenAddr    = env.String("LISTEN", ":1999")
+	publicURL     = env.String("PUBLIC_URL", "") // generator URL in membership documents
 	dbURL         = env.String("DATABASE_URL", "postgres:///core?sslmode=disable")
-	splunkAddr    = os.Getenv("SPLUNKADDR")
-	logFile       = os.Getenv("LOGFILE")
+	dbReadURL     = env.String("DATABASE_READ_URL", "") // replica for queries
+	dbReadMaxLag  = env.Duration("DATABASE_READ_MAX_LAG", 5*time.Second)
+	splunkAddr    = env.String("SPLUNKADDR", "")
+	logFile       = env.String("LOGFILE", "")
 	logSize       = env.Int("LOGSIZE", 5e6) // 5MB
 	logCount      = env.Int("LOGCOUNT", 9)
 	logQueries    = env.Bool("LOG_QUERIES", false)
-	maxDBConns    = env.Int("MAXDBCONNS", 10)           // set to 100 in prod
+	logStmts      = env.Bool("LOG_STATEMENTS", false)
+	logStmtsMin   = env.Duration("LOG_STATEMENTS_MIN_DURATION", 0)
+	logStmtsArgs  = env.String("LOG_STATEMENTS_ARGS", "redact")
+	logLevel      = env.String("LOG_LEVEL", "info")
+	logFormat     = env.String("LOG_FORMAT", "text")
+	logErrWindow  = env.Duration("LOG_ERROR_WINDOW", time.Minute)
+	crashDumpDir  = env.String("CRASH_DUMP_DIR", os.TempDir())
+	crashDumpLen  = env.Int("CRASH_DUMP_ENTRIES", 1000)
+	maxDBConns    = env.Int("MAXDBCONNS", 10)        // set to 100 in prod
+	maxDBIdle     = env.Int("DB_MAX_IDLE_CONNS", -1) // -1 means MAXDBCONNS
+	dbConnMaxLife = env.Duration("DB_CONN_MAX_LIFETIME", 0)
+	dbStmtTimeout = env.Duration("DB_STATEMENT_TIMEOUT", 0)
+	dbStmtCache   = env.Int("DB_STATEMENT_CACHE_SIZE", 0)
+	migrationsDir = env.String("MIGRATIONS_DIR", "")
+	authURL       = env.String("AUTH_INTROSPECTION_URL", "")
+	authToken     = env.Secret("AUTH_INTROSPECTION_TOKEN", "")
+	autoMigrate   = env.Bool("AUTO_MIGRATE", true)      // if false, only check the schema
 	rpsToken      = env.Int("RATELIMIT_TOKEN", 0)       // reqs/sec
 	rpsRemoteAddr = env.Int("RATELIMIT_REMOTE_ADDR", 0) // reqs/sec
 	indexTxs      = env.Bool("INDEX_TRANSACTIONS", true)
-
-	// build vars; initialized by the linker
-	buildTag    = "?"
-	buildCommit = "?"
-	buildDate   = "?"
+	enableGraphQL = env.Bool("GRAPHQL", false)
+	enableGRPC    = env.Bool("GRPC", false) // needs TLS, for HTTP/2
+	queryFuncs    = env.Bool("QUERY_FUNCTIONS", false)
+	maxPageSize   = env.Int("MAX_PAGE_SIZE", 0)        // 0 means the core's default
+	exportBucket  = env.String("EXPORT_S3_BUCKET", "") // empty means no export store
+	exportRegion  = env.String("EXPORT_S3_REGION", "") // empty means AWS_REGION
+	exportURL     = env.String("EXPORT_S3_ENDPOINT", "")
+	maxConcurrent = env.Int("MAX_CONCURRENT_REQUESTS", 0)
+	vmSuperinsts  = env.Bool("VM_SUPERINSTRUCTIONS", false)
+	hashFunc      = env.Int("HASH_FUNC", 0) // entry hash function ID for a new blockchain; ignored once there is an initial block
+	mempoolMaxTxs = env.Int("MEMPOOL_MAX_TXS", mempool.DefaultLimits.MaxTxs)
+	mempoolMaxAge = env.Duration("MEMPOOL_MAX_AGE", mempool.DefaultLimits.MaxAge)
+	blockShare    = env.String("BLOCK_SIGNING_SHARE", "")    // file from corectl threshold-keygen
+	blockMaxTxs   = env.Int("BLOCK_SIGNING_MAX_TXS", 0)      // 0 means no limit
+	blockIssuers  = env.StringSlice("BLOCK_SIGNING_ISSUERS") // hex issuance programs; empty means any
 
 	race          []interface{} // initialized in race.go
 	httpsRedirect = true        // initialized in insecure.go
 
 	blockPeriod              = time.Second
 	expireReservationsPeriod = time.Second
+	expireTokensPeriod       = time.Minute
+	tokenUsagePeriod         = 10 * time.Second
+	reindexPollPeriod        = 10 * time.Second
+	webhookPeriod            = time.Second
 )
 
 func init() {
 	var version string
-	if buildTag != "?" {
+	if build.Tag != "?" {
 		// build tag with chain-core-server- prefix indicates official release
-		version = strings.TrimPrefix(buildTag, "chain-core-server-")
+		version = strings.TrimPrefix(build.Tag, "chain-core-server-")
 	} else {
 		// version of the form rev123 indicates non-release build
 		version = rev.ID
@@ -100,23 +146,54 @@ func init() {
 
 	expvar.NewString("prod").Set(prodStr)
 	expvar.NewString("version").Set(version)
-	expvar.NewString("build_tag").Set(buildTag)
-	expvar.NewString("build_date").Set(buildDate)
-	expvar.NewString("build_commit").Set(buildCommit)
+	expvar.NewString("build_tag").Set(build.Tag)
+	expvar.NewString("build_date").Set(build.Date)
+	expvar.NewString("build_commit").Set(build.Commit)
+	expvar.NewString("build_deps").Set(build.Deps)
 	expvar.NewString("runtime.GOOS").Set(runtime.GOOS)
 	expvar.NewString("runtime.GOARCH").Set(runtime.GOARCH)
 	expvar.NewString("runtime.Version").Set(runtime.Version())
 
 	config.Version = version
-	config.BuildCommit = buildCommit
-	config.BuildDate = buildDate
+	config.BuildCommit = build.Commit
+	config.BuildDate = build.Date
 	config.Production = prod
 }
 
+func init() {
+	env.Validate(checkEnv)
+}
+
+// checkEnv checks the constraints between config vars.
+func checkEnv() error {
+	switch {
+	case (*tlsCrt == "") != (*tlsKey == ""):
+		return errors.New("TLSCRT and TLSKEY must be set together")
+	case *tlsClientCA != "" && *tlsCrt == "":
+		return errors.New("TLSCLIENTCA requires TLSCRT and TLSKEY")
+	case *enableGRPC && *tlsCrt == "":
+		// gRPC needs HTTP/2, which net/http
+		// serves only over TLS.
+		return errors.New("GRPC requires TLSCRT and TLSKEY")
+	case *logFormat != "text" && *logFormat != "json":
+		return fmt.Errorf("LOG_FORMAT must be text or json, not %q", *logFormat)
+	case *exportBucket == "" && (*exportRegion != "" || *exportURL != ""):
+		return errors.New("EXPORT_S3_REGION and EXPORT_S3_ENDPOINT require EXPORT_S3_BUCKET")
+	}
+	return nil
+}
+
 func main() {
 	v := flag.Bool("version", false, "print version information")
+	printEnv := flag.Bool("print-env", false, "print the environment variables cored reads, and exit")
+	config.DefineFlags(flag.CommandLine)
 	flag.Parse()
 
+	if *printEnv {
+		env.Print(os.Stdout)
+		return
+	}
+
 	if !*v {
 		fmt.Printf("Chain Core starting...\n\n")
 	}
@@ -125,6 +202,8 @@ func main() {
 	fmt.Printf("production: %t\n", config.Production)
 	fmt.Printf("build-commit: %v\n", config.BuildCommit)
 	fmt.Printf("build-date: %v\n", config.BuildDate)
+	fmt.Printf("build-deps: %v\n", build.Deps)
+	fmt.Printf("consensus-digest: %v\n", build.Current().ConsensusDigest)
 
 	if *v {
 		return
@@ -140,24 +219,99 @@ func runServer() {
 	ctx := context.Background()
 	env.Parse()
 
+	// Keep recent log entries to write out if cored
+	// panics or exits with a fatal error.
+	chainlog.SetCrashDump(*crashDumpDir, *crashDumpLen)
+	chainlog.SetErrorWindow(*logErrWindow)
+	defer chainlog.DumpOnPanic(ctx)
+
 	sql.EnableQueryLogging(*logQueries)
+	if *logStmts {
+		sql.EnableStatementLogging(*logStmtsMin, stmtArgPolicy(ctx, *logStmtsArgs))
+	}
+	vm.Superinstructions = *vmSuperinsts
+	pg.StatementCacheSize = *dbStmtCache
+	if *dbStmtTimeout > 0 {
+		u, err := withStatementTimeout(*dbURL, *dbStmtTimeout)
+		if err != nil {
+			chainlog.Fatalkv(ctx, chainlog.KeyError, err)
+		}
+		*dbURL = u
+		if *dbReadURL != "" {
+			u, err = withStatementTimeout(*dbReadURL, *dbStmtTimeout)
+			if err != nil {
+				chainlog.Fatalkv(ctx, chainlog.KeyError, err)
+			}
+			*dbReadURL = u
+		}
+	}
 	db, err := sql.Open("hapg", *dbURL)
 	if err != nil {
 		chainlog.Fatalkv(ctx, chainlog.KeyError, err)
 	}
 	db.SetMaxOpenConns(*maxDBConns)
-	db.SetMaxIdleConns(*maxDBConns)
+	if *maxDBIdle < 0 {
+		*maxDBIdle = *maxDBConns
+	}
+	db.SetMaxIdleConns(*maxDBIdle)
+	db.SetConnMaxLifetime(*dbConnMaxLife)
+	expvar.Publish("db_pool", expvar.Func(func() interface{} { return db.Stats() }))
+
+	var readDB pg.DB = db
+	if *dbReadURL != "" {
+		replica, err := sql.Open("hapg", *dbReadURL)
+		if err != nil {
+			chainlog.Fatalkv(ctx, chainlog.KeyError, err)
+		}
+		replica.SetMaxOpenConns(*maxDBConns)
+		replica.SetMaxIdleConns(*maxDBIdle)
+		replica.SetConnMaxLifetime(*dbConnMaxLife)
+		expvar.Publish("db_read_pool", expvar.Func(func() interface{} { return replica.Stats() }))
+		r := sql.NewReadDB(db, replica, *dbReadMaxLag, pg.ReplicaLag)
+		go r.Monitor(ctx, time.Second)
+		readDB = r
+	}
 
-	err = migrate.Run(db)
+	if *migrationsDir != "" {
+		err = migrate.LoadDir(*migrationsDir)
+		if err != nil {
+			chainlog.Fatalkv(ctx, chainlog.KeyError, err)
+		}
+	}
+	if *autoMigrate {
+		_, err = migrate.RunExclusive(db)
+	} else {
+		err = migrate.Check(db)
+	}
 	if err != nil {
 		chainlog.Fatalkv(ctx, chainlog.KeyError, err)
 	}
+	if *autoMigrate {
+		go func() {
+			err := migrate.RunBackfills(ctx, db)
+			if err != nil {
+				chainlog.Error(ctx, err)
+			}
+		}()
+	}
 	resetInDevIfRequested(db)
 
+	err = protocol.InitHashFunc(ctx, txdb.NewStore(db), uint64(*hashFunc))
+	if err != nil {
+		chainlog.Fatalkv(ctx, chainlog.KeyError, err, "HASH_FUNC", *hashFunc)
+	}
+
 	conf, err := config.Load(ctx, db)
 	if err != nil {
 		chainlog.Fatalkv(ctx, chainlog.KeyError, err)
 	}
+	var settings []config.Setting
+	if conf != nil {
+		settings, err = config.LookupOverrides(flag.CommandLine).Resolve(conf)
+		if err != nil {
+			chainlog.Fatalkv(ctx, chainlog.KeyError, err)
+		}
+	}
 
 	// Initialize internode rpc clients.
 	hostname, err := os.Hostname()
@@ -170,14 +324,21 @@ func runServer() {
 	}
 	expvar.NewString("processID").Set(processID)
 
-	log.SetPrefix("cored-" + buildTag + ": ")
+	log.SetPrefix("cored-" + build.Tag + ": ")
 	log.SetFlags(log.Lshortfile)
-	chainlog.SetPrefix(append([]interface{}{"app", "cored", "buildtag", buildTag, "processID", processID}, race...)...)
+	chainlog.SetPrefix(append([]interface{}{"app", "cored", "buildtag", build.Tag, "processID", processID}, race...)...)
 	chainlog.SetOutput(logWriter())
+	if *logFormat == "json" {
+		chainlog.SetFormat(chainlog.FormatJSON)
+	}
+	err = chainlog.SetLevels(*logLevel)
+	if err != nil {
+		chainlog.Fatalkv(ctx, chainlog.KeyError, err, "LOG_LEVEL", *logLevel)
+	}
 
 	var h http.Handler
 	if conf != nil {
-		h = launchConfiguredCore(ctx, db, conf, processID)
+		h = launchConfiguredCore(ctx, db, readDB, conf, settings, processID)
 	} else {
 		h = launchUnconfiguredCore(ctx, db)
 	}
@@ -203,6 +364,11 @@ func runServer() {
 		// https://github.com/golang/go/issues/17071
 		TLSNextProto: map[string]func(*http.Server, *tls.Conn, http.Handler){},
 	}
+	if *enableGRPC {
+		// gRPC needs HTTP/2, which net/http
+		// serves only over TLS; see checkEnv.
+		server.TLSNextProto = nil
+	}
 	if *tlsCrt != "" {
 		cert, err := tls.X509KeyPair([]byte(*tlsCrt), []byte(*tlsKey))
 		if err != nil {
@@ -212,6 +378,16 @@ func runServer() {
 		server.TLSConfig = &tls.Config{
 			Certificates: []tls.Certificate{cert},
 		}
+		if *tlsClientCA != "" {
+			// Verify the client certificates that requests
+			// may present to satisfy x509 grant guards.
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM([]byte(*tlsClientCA)) {
+				chainlog.Fatalkv(ctx, chainlog.KeyError, errors.New("parsing TLSCLIENTCA: no certificates"))
+			}
+			server.TLSConfig.ClientCAs = pool
+			server.TLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
 		err = server.ListenAndServeTLS("", "") // uses TLS certs from above
 		if err != nil {
 			chainlog.Fatalkv(ctx, chainlog.KeyError, errors.Wrap(err, "ListenAndServeTLS"))
@@ -224,20 +400,26 @@ func runServer() {
 	}
 }
 
-func launchConfiguredCore(ctx context.Context, db pg.DB, conf *config.Config, processID string) http.Handler {
+func launchConfiguredCore(ctx context.Context, db, readDB pg.DB, conf *config.Config, settings []config.Setting, processID string) http.Handler {
 	// Initialize the protocol.Chain.
-	heights, err := txdb.ListenBlocks(ctx, *dbURL)
+	store := txdb.NewStore(db)
+	heights, err := store.ListenBlocks(ctx, *dbURL)
 	if err != nil {
 		chainlog.Fatalkv(ctx, chainlog.KeyError, err)
 	}
-	store := txdb.NewStore(db)
 	c, err := protocol.NewChain(ctx, conf.BlockchainID, store, heights)
 	if err != nil {
 		chainlog.Fatalkv(ctx, chainlog.KeyError, err)
 	}
 
+	// Scheduled changes of the block signers.
+	schedule := &config.RotationSchedule{DB: db}
+
 	var generatorSigners []generator.BlockSigner
+	var membershipSigner config.MessageSigner
 	var signBlockHandler func(context.Context, *bc.Block) ([]byte, error)
+	var share *threshold.Share
+	var localSigner *blocksigner.BlockSigner
 	if conf.IsSigner {
 		blockPub, err := hex.DecodeString(conf.BlockPub)
 		if err != nil {
@@ -245,7 +427,20 @@ func launchConfiguredCore(ctx context.Context, db pg.DB, conf *config.Config, pr
 		}
 
 		var hsm blocksigner.Signer
-		if conf.BlockHSMURL != "" {
+		if pkcs11hsm.IsURI(conf.BlockHSMURL) {
+			p11conf, err := pkcs11hsm.ParseURI(conf.BlockHSMURL)
+			if err != nil {
+				chainlog.Fatalkv(ctx, chainlog.KeyError, err)
+			}
+			if p11conf.PIN == "" {
+				p11conf.PIN = conf.BlockHSMAccessToken
+			}
+			p11, err := pkcs11hsm.Open(p11conf)
+			if err != nil {
+				chainlog.Fatalkv(ctx, chainlog.KeyError, err)
+			}
+			hsm = p11
+		} else if conf.BlockHSMURL != "" {
 			// TODO(ameets): potential option to take only a password when configuring
 			//  and convert to an access token string here for BlockHSMAccessToken
 			hsm = &remoteHSM{Client: &rpc.Client{
@@ -253,7 +448,7 @@ func launchConfiguredCore(ctx context.Context, db pg.DB, conf *config.Config, pr
 				AccessToken:  conf.BlockHSMAccessToken,
 				Username:     processID,
 				CoreID:       conf.ID,
-				BuildTag:     buildTag,
+				BuildTag:     build.Tag,
 				BlockchainID: conf.BlockchainID.String(),
 			}}
 		} else {
@@ -262,7 +457,28 @@ func launchConfiguredCore(ctx context.Context, db pg.DB, conf *config.Config, pr
 				chainlog.Fatalkv(ctx, chainlog.KeyError, err)
 			}
 		}
+		membershipSigner, _ = hsm.(config.MessageSigner)
 		s := blocksigner.New(blockPub, hsm, db, c)
+		s.SetSchedule(schedule)
+		policy, err := signingPolicy(*blockMaxTxs, *blockIssuers)
+		if err != nil {
+			chainlog.Fatalkv(ctx, chainlog.KeyError, err)
+		}
+		s.SetPolicy(policy)
+		if keys, ok := hsm.(blocksigner.KeyRing); ok {
+			s.SetKeyRing(keys)
+		}
+		if *blockShare != "" {
+			share, err = readShare(*blockShare)
+			if err != nil {
+				chainlog.Fatalkv(ctx, chainlog.KeyError, err)
+			}
+			err = s.SetShare(share)
+			if err != nil {
+				chainlog.Fatalkv(ctx, chainlog.KeyError, err)
+			}
+			localSigner = s
+		}
 
 		generatorSigners = append(generatorSigners, s) // "local" signer
 		signBlockHandler = func(ctx context.Context, b *bc.Block) ([]byte, error) {
@@ -273,9 +489,26 @@ func launchConfiguredCore(ctx context.Context, db pg.DB, conf *config.Config, pr
 			return sig, err
 		}
 	}
+	c.FinalHeight = conf.FinalBlockHeight
+	var peers []*rpc.Client
 	if conf.IsGenerator {
-		for _, signer := range remoteSignerInfo(ctx, processID, buildTag, conf.BlockchainID.String(), conf) {
+		signers, err := config.ActiveSigners(ctx, db, conf)
+		if err != nil {
+			chainlog.Fatalkv(ctx, chainlog.KeyError, err)
+		}
+		for _, signer := range remoteSignerInfo(ctx, processID, build.Tag, conf.BlockchainID.String(), conf, signers) {
 			generatorSigners = append(generatorSigners, signer)
+			peers = append(peers, signer.Client)
+		}
+		if share != nil {
+			signers, err := config.ThresholdSigners(ctx, db, conf)
+			if err != nil {
+				chainlog.Fatalkv(ctx, chainlog.KeyError, err)
+			}
+			for _, signer := range remoteSignerInfo(ctx, processID, build.Tag, conf.BlockchainID.String(), conf, signers) {
+				generatorSigners = append(generatorSigners, signer)
+				peers = append(peers, signer.Client)
+			}
 		}
 		c.MaxIssuanceWindow = conf.MaxIssuanceWindow.Duration
 	}
@@ -289,12 +522,18 @@ func launchConfiguredCore(ctx context.Context, db pg.DB, conf *config.Config, pr
 			AccessToken:  conf.GeneratorAccessToken,
 			Username:     processID,
 			CoreID:       conf.ID,
-			BuildTag:     buildTag,
+			BuildTag:     build.Tag,
 			BlockchainID: conf.BlockchainID.String(),
 		}
 		submitter = &txbuilder.RemoteGenerator{Peer: remoteGenerator}
+		peers = append(peers, remoteGenerator)
 	} else {
 		gen = generator.New(c, generatorSigners, db)
+		gen.SetSchedule(schedule)
+		if share != nil {
+			gen.SetThreshold(ed25519.PublicKey(share.GroupKey[:]), share.Threshold)
+		}
+		gen.SetPoolLimits(mempool.Limits{MaxTxs: *mempoolMaxTxs, MaxAge: *mempoolMaxAge})
 		submitter = gen
 	}
 
@@ -308,10 +547,18 @@ func launchConfiguredCore(ctx context.Context, db pg.DB, conf *config.Config, pr
 	go pinStore.Listen(ctx, account.PinName, *dbURL)
 	go pinStore.Listen(ctx, account.ExpirePinName, *dbURL)
 	go pinStore.Listen(ctx, account.DeleteSpentsPinName, *dbURL)
+	go pinStore.Listen(ctx, account.EventsPinName, *dbURL)
 	go pinStore.Listen(ctx, asset.PinName, *dbURL)
 
 	// Setup the transaction query indexer to index every transaction.
 	indexer := query.NewIndexer(db, c, pinStore)
+	indexer.UseReadDB(readDB)
+	if *queryFuncs {
+		err = indexer.UseQueryFunctions(ctx)
+		if err != nil {
+			chainlog.Fatalkv(ctx, chainlog.KeyError, err)
+		}
+	}
 
 	assets := asset.NewRegistry(db, c, pinStore)
 	accounts := account.NewManager(db, c, pinStore)
@@ -329,6 +576,25 @@ func launchConfiguredCore(ctx context.Context, db pg.DB, conf *config.Config, pr
 	// Clean up expired UTXO reservations periodically.
 	go accounts.ExpireReservations(ctx, expireReservationsPeriod)
 
+	// Delete expired access tokens periodically.
+	accessTokens := &accesstoken.CredentialStore{DB: db}
+	go accessTokens.ExpireTokens(ctx, expireTokensPeriod)
+
+	// Write access token usage periodically.
+	go accessTokens.WriteUsage(ctx, tokenUsagePeriod)
+
+	// Tell accounts about pending txs that expire unconfirmed.
+	if gen != nil {
+		gen.OnExpiredTxs(func(ctx context.Context, txs []*bc.Tx) {
+			for _, tx := range txs {
+				err := accounts.RecordTxEvent(ctx, account.EventExpired, tx)
+				if err != nil {
+					chainlog.Error(ctx, err)
+				}
+			}
+		})
+	}
+
 	h := &core.API{
 		Chain:        c,
 		Store:        store,
@@ -336,14 +602,40 @@ func launchConfiguredCore(ctx context.Context, db pg.DB, conf *config.Config, pr
 		Assets:       assets,
 		Accounts:     accounts,
 		Submitter:    submitter,
+		Peers:        peers,
 		TxFeeds:      &txfeed.Tracker{DB: db},
+		Webhooks:     &webhook.Manager{DB: db, Indexer: indexer},
 		Indexer:      indexer,
-		AccessTokens: &accesstoken.CredentialStore{DB: db},
+		AccessTokens: accessTokens,
+		Grants:       &authz.Store{DB: db},
 		Config:       conf,
+		Settings:     settings,
 		DB:           db,
 		Addr:         *listenAddr,
 		Signer:       signBlockHandler,
 		AltAuth:      authLoopbackInDev,
+		MaxPageSize:  *maxPageSize,
+	}
+	if localSigner != nil {
+		h.CommitBlock = localSigner.CommitBlock
+		h.SignBlockShare = localSigner.SignBlockShare
+	}
+	if gen != nil {
+		h.SignatureStatus = gen.SignatureStatus
+	}
+	if conf.IsGenerator && membershipSigner != nil {
+		h.MembershipSigner = membershipSigner
+		h.PublicURL = *publicURL
+	}
+	if *enableGraphQL {
+		h.GraphQL = graphql.NewSchema(indexer, *maxPageSize)
+	}
+	h.GRPC = *enableGRPC
+	if *exportBucket != "" {
+		h.ExportStore = newS3Store(*exportBucket, *exportRegion, *exportURL)
+	}
+	if *authURL != "" {
+		h.Authenticator = &accesstoken.Introspector{URL: *authURL, Token: *authToken}
 	}
 	if *rpsToken > 0 {
 		h.RequestLimits = append(h.RequestLimits, core.RequestLimit{
@@ -359,6 +651,7 @@ func launchConfiguredCore(ctx context.Context, db pg.DB, conf *config.Config, pr
 			PerSecond: *rpsRemoteAddr,
 		})
 	}
+	h.MaxConcurrentRequests = *maxConcurrent
 
 	var (
 		genhealth   = h.HealthSetter("generator")
@@ -399,6 +692,10 @@ func launchConfiguredCore(ctx context.Context, db pg.DB, conf *config.Config, pr
 		if err != nil {
 			chainlog.Fatalkv(ctx, chainlog.KeyError, err)
 		}
+		err = pinStore.CreatePin(ctx, account.EventsPinName, pinHeight)
+		if err != nil {
+			chainlog.Fatalkv(ctx, chainlog.KeyError, err)
+		}
 		err = pinStore.CreatePin(ctx, asset.PinName, pinHeight)
 		if err != nil {
 			chainlog.Fatalkv(ctx, chainlog.KeyError, err)
@@ -409,7 +706,11 @@ func launchConfiguredCore(ctx context.Context, db pg.DB, conf *config.Config, pr
 		}
 
 		if conf.IsGenerator {
-			go gen.Generate(ctx, blockPeriod, genhealth, recoveredBlock, recoveredSnapshot)
+			period := blockPeriod
+			if conf.BlockPeriod.Duration > 0 {
+				period = conf.BlockPeriod.Duration
+			}
+			go gen.Generate(ctx, period, genhealth, recoveredBlock, recoveredSnapshot)
 		} else {
 			go fetch.Fetch(ctx, c, remoteGenerator, fetchhealth, recoveredBlock, recoveredSnapshot)
 		}
@@ -417,6 +718,8 @@ func launchConfiguredCore(ctx context.Context, db pg.DB, conf *config.Config, pr
 		go h.Assets.ProcessBlocks(ctx)
 		if *indexTxs {
 			go h.Indexer.ProcessBlocks(ctx)
+			go h.Indexer.ProcessReindex(ctx, reindexPollPeriod)
+			go h.Webhooks.Deliver(ctx, webhookPeriod)
 		}
 	})
 
@@ -434,6 +737,8 @@ func launchUnconfiguredCore(ctx context.Context, db pg.DB) http.Handler {
 		DB:           db,
 		AltAuth:      authLoopbackInDev,
 		AccessTokens: &accesstoken.CredentialStore{DB: db},
+		Grants:       &authz.Store{DB: db},
+		GRPC:         *enableGRPC,
 	}, nil)
 }
 
@@ -458,8 +763,17 @@ func (h *remoteHSM) Sign(ctx context.Context, pk ed25519.PublicKey, bh *bc.Block
 	return
 }
 
-func remoteSignerInfo(ctx context.Context, processID, buildTag, blockchainID string, conf *config.Config) (a []*remoteSigner) {
-	for _, signer := range conf.Signers {
+func (h *remoteHSM) SignMessage(ctx context.Context, pk ed25519.PublicKey, msg []byte) (signature []byte, err error) {
+	body := struct {
+		Message json.HexBytes `json:"message"`
+		Pub     json.HexBytes `json:"pubkey"`
+	}{msg, json.HexBytes(pk[:])}
+	err = h.Client.Call(ctx, "/sign-message", body, &signature)
+	return
+}
+
+func remoteSignerInfo(ctx context.Context, processID, buildTag, blockchainID string, conf *config.Config, signers []config.BlockSigner) (a []*remoteSigner) {
+	for _, signer := range signers {
 		u, err := url.Parse(signer.URL)
 		if err != nil {
 			chainlog.Fatalkv(ctx, chainlog.KeyError, err)
@@ -488,21 +802,84 @@ func (s *remoteSigner) SignBlock(ctx context.Context, b *bc.Block) (signature []
 	return
 }
 
+func (s *remoteSigner) CommitBlock(ctx context.Context, b *bc.Block) (c threshold.Commitment, err error) {
+	err = s.Client.Call(ctx, "/rpc/signer/threshold-commit", b, &c)
+	return
+}
+
+func (s *remoteSigner) SignBlockShare(ctx context.Context, req *blocksigner.ShareRequest) (z threshold.Scalar, err error) {
+	err = s.Client.Call(ctx, "/rpc/signer/threshold-sign", req, &z)
+	return
+}
+
+// readShare reads a threshold key share
+// written by corectl threshold-keygen.
+func readShare(filename string) (*threshold.Share, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading BLOCK_SIGNING_SHARE")
+	}
+	share := new(threshold.Share)
+	err = stdjson.Unmarshal(b, share)
+	return share, errors.Wrap(err, "parsing BLOCK_SIGNING_SHARE")
+}
+
+// signingPolicy returns the block signing policy
+// set by BLOCK_SIGNING_MAX_TXS and BLOCK_SIGNING_ISSUERS.
+func signingPolicy(maxTxs int, issuers []string) (*blocksigner.Policy, error) {
+	p := &blocksigner.Policy{MaxTxs: maxTxs}
+	for _, issuer := range issuers {
+		prog, err := hex.DecodeString(issuer)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing BLOCK_SIGNING_ISSUERS")
+		}
+		p.Issuers = append(p.Issuers, prog)
+	}
+	return p, nil
+}
+
 func (s *remoteSigner) String() string {
 	return s.Client.BaseURL
 }
 
+// withStatementTimeout sets the statement_timeout run-time
+// parameter, in milliseconds, in the database URL.
+func withStatementTimeout(dbURL string, d time.Duration) (string, error) {
+	u, err := url.Parse(dbURL)
+	if err != nil {
+		return "", errors.Wrap(err, "parsing DATABASE_URL")
+	}
+	q := u.Query()
+	q.Set("statement_timeout", strconv.FormatInt(int64(d/time.Millisecond), 10))
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// stmtArgPolicy parses the LOG_STATEMENTS_ARGS setting.
+func stmtArgPolicy(ctx context.Context, s string) sql.ArgPolicy {
+	switch s {
+	case "redact":
+		return sql.ArgsRedact
+	case "truncate":
+		return sql.ArgsTruncate
+	case "full":
+		return sql.ArgsFull
+	}
+	chainlog.Fatalkv(ctx, chainlog.KeyError, "LOG_STATEMENTS_ARGS must be redact, truncate, or full", "value", s)
+	return 0
+}
+
 func logWriter() io.Writer {
 	dropmsg := []byte("\nlog data dropped\n")
-	rotation := &errlog{w: rotation.Create(logFile, *logSize, *logCount)}
-	splunk := &errlog{w: splunk.New(splunkAddr, dropmsg)}
+	rotation := &errlog{w: rotation.Create(*logFile, *logSize, *logCount)}
+	splunk := &errlog{w: splunk.New(*splunkAddr, dropmsg)}
 
 	switch {
-	case logFile != "" && splunkAddr != "":
+	case *logFile != "" && *splunkAddr != "":
 		return io.MultiWriter(rotation, splunk)
-	case logFile != "" && splunkAddr == "":
+	case *logFile != "" && *splunkAddr == "":
 		return rotation
-	case logFile == "" && splunkAddr != "":
+	case *logFile == "" && *splunkAddr != "":
 		return splunk
 	}
 	return os.Stdout
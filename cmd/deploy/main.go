@@ -1,7 +1,6 @@
 package main
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
 	"log"
@@ -80,15 +79,18 @@ func mustBuild(filename string) []byte {
 		"CGO_ENABLED=0",
 	}
 
-	cmd := exec.Command("git", "rev-parse", "HEAD")
+	// Stamp the commit time, not the current time,
+	// so rebuilding the same commit gives the same metadata.
+	cmd := exec.Command("git", "log", "-1", "--format=%H %ct")
 	cmd.Stderr = os.Stderr
-	commit, err := cmd.Output()
+	head, err := cmd.Output()
+	must(err)
+	var commit, date string
+	_, err = fmt.Sscan(string(head), &commit, &date)
 	must(err)
-	commit = bytes.TrimSpace(commit)
-	date := time.Now().UTC().Format(time.RFC3339)
 	cmd = exec.Command("go", "build",
 		"-tags", "insecure_disable_https_redirect",
-		"-ldflags", "-X main.buildTag=dev -X main.buildDate="+date+" -X main.buildCommit="+string(commit),
+		"-ldflags", "-X chain/core/build.Tag=dev -X chain/core/build.Date="+date+" -X chain/core/build.Commit="+commit,
 		"-o", "/dev/stdout",
 		"chain/cmd/"+filename,
 	)
@@ -38,9 +38,9 @@ var (
 
 var (
 	coredAddr        = env.String("CORED_ADDR", "http://:1999")
-	coredAccessToken = env.String("CORED_ACCESS_TOKEN", "")
+	coredAccessToken = env.Secret("CORED_ACCESS_TOKEN", "")
 	libratoUser      = env.String("LIBRATO_USER", "")
-	libratoToken     = env.String("LIBRATO_TOKEN", "")
+	libratoToken     = env.Secret("LIBRATO_TOKEN", "")
 	metricPrefix     = env.String("METRIC_PREFIX", "cored")
 )
 
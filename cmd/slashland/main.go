@@ -26,12 +26,12 @@ Here is an example using the /land command:
 
 var (
 	port          = env.String("PORT", "8080")
-	githubToken   = env.String("GITHUB_TOKEN", "")
+	githubToken   = env.Secret("GITHUB_TOKEN", "")
 	org           = env.String("GITHUB_ORG", "chain")
 	repo          = env.String("GITHUB_REPO", "chain")
 	privRepo      = env.String("GITHUB_REPO_PRIVATE", "chainprv")
 	slackChannels = env.StringSlice("SLACK_CHANNEL")
-	slackToken    = env.String("SLACK_LAND_TOKEN", "")
+	slackToken    = env.Secret("SLACK_LAND_TOKEN", "")
 	postURL       = env.String("SLACK_POST_URL", "")
 )
 
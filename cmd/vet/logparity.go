@@ -30,7 +30,9 @@ func checkLogCall(f *File, node ast.Node) {
 		return
 	}
 
-	if fun.FullName() != "chain/log.Printkv" && fun.FullName() != "chain/log.Fatalkv" {
+	switch fun.FullName() {
+	case "chain/log.Printkv", "chain/log.Debugkv", "chain/log.Fatalkv":
+	default:
 		return
 	}
 
@@ -12,6 +12,7 @@ func PrintkvTests() {
 	log.Printkv(nil, []interface{}{0}...) // any 'arg...' is ok too
 	log.Printkv(nil, "k")                 // ERROR "odd number of arguments in call to log.Printkv"
 	log.Printkv(nil, "k", "v", "k2")      // ERROR "odd number of arguments in call to log.Printkv"
+	log.Debugkv(nil, "k")                 // ERROR "odd number of arguments in call to log.Debugkv"
 
 	var log writer
 	log.Printkv(nil, "k", "v")       // ok
@@ -5,22 +5,40 @@ import (
 	"errors"
 
 	"chain/core/accesstoken"
+	chainjson "chain/encoding/json"
 	"chain/net/http/httpjson"
 )
 
 var errCurrentToken = errors.New("token cannot delete itself")
 
-func (a *API) createAccessToken(ctx context.Context, x struct{ ID, Type string }) (*accesstoken.Token, error) {
-	return a.AccessTokens.Create(ctx, x.ID, x.Type)
+func (a *API) createAccessToken(ctx context.Context, x struct {
+	ID, Type string
+	TTL      chainjson.Duration
+	Scopes   []string
+}) (*accesstoken.Token, error) {
+	tok, err := a.AccessTokens.Create(ctx, x.ID, x.Type, x.TTL.Duration, x.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	err = a.recordAudit(ctx, "create-access-token", map[string]interface{}{
+		"id":     x.ID,
+		"type":   x.Type,
+		"ttl":    x.TTL,
+		"scopes": x.Scopes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tok, nil
 }
 
 func (a *API) listAccessTokens(ctx context.Context, x requestQuery) (*page, error) {
-	limit := x.PageSize
-	if limit == 0 {
-		limit = defGenericPageSize
+	limit, err := a.pageSize(x.PageSize)
+	if err != nil {
+		return nil, err
 	}
 
-	tokens, next, err := a.AccessTokens.List(ctx, x.Type, x.After, limit)
+	tokens, next, err := a.AccessTokens.List(ctx, x.After, limit, x.Type, x.Prefix)
 	if err != nil {
 		return nil, err
 	}
@@ -40,5 +58,15 @@ func (a *API) deleteAccessToken(ctx context.Context, x struct{ ID string }) erro
 	if currentID == x.ID {
 		return errCurrentToken
 	}
-	return a.AccessTokens.Delete(ctx, x.ID)
+	err := a.AccessTokens.Delete(ctx, x.ID)
+	if err != nil {
+		return err
+	}
+	if a.Grants != nil {
+		err = a.Grants.DeleteAccessToken(ctx, x.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return a.recordAudit(ctx, "delete-access-token", map[string]interface{}{"id": x.ID})
 }
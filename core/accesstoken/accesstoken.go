@@ -4,17 +4,45 @@ package accesstoken
 
 import (
 	"context"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
 	"fmt"
 	"regexp"
 	"time"
 
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+
 	"chain/crypto/sha3pool"
 	"chain/database/pg"
 	"chain/errors"
+	"chain/log"
+)
+
+const (
+	tokenSize = 32
+	saltSize  = 16
 )
 
-const tokenSize = 32
+// Scopes limit the API endpoints a token may access.
+// A token created without scopes may access every
+// endpoint for its type.
+const (
+	ScopeSubmitTx     = "submit-tx"
+	ScopeReadAccounts = "read-accounts"
+	ScopeSignBlock    = "sign-block"
+	ScopeAdmin        = "admin"
+)
+
+var validScopes = map[string]bool{
+	ScopeSubmitTx:     true,
+	ScopeReadAccounts: true,
+	ScopeSignBlock:    true,
+	ScopeAdmin:        true,
+}
 
 var (
 	// ErrBadID is returned when Create is called on an invalid id string.
@@ -23,6 +51,10 @@ var (
 	ErrDuplicateID = errors.New("duplicate access token ID")
 	// ErrBadType is returned when Create is called with a bad type.
 	ErrBadType = errors.New("type must be client or network")
+	// ErrBadTTL is returned when Create is called with a negative TTL.
+	ErrBadTTL = errors.New("ttl must not be negative")
+	// ErrBadScope is returned when Create is called with an unknown scope.
+	ErrBadScope = errors.New("unknown scope")
 
 	defaultLimit = 100
 
@@ -32,19 +64,32 @@ var (
 )
 
 type Token struct {
-	ID      string    `json:"id"`
-	Token   string    `json:"token,omitempty"`
-	Type    string    `json:"type"`
-	Created time.Time `json:"created_at"`
-	sortID  string
+	ID      string     `json:"id"`
+	Token   string     `json:"token,omitempty"`
+	Type    string     `json:"type"`
+	Created time.Time  `json:"created_at"`
+	Expires *time.Time `json:"expires_at,omitempty"`
+	Scopes  []string   `json:"scopes,omitempty"`
+
+	// Usage, as of the last write of recorded uses.
+	LastUsed     *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP   string     `json:"last_used_ip,omitempty"`
+	RequestCount int64      `json:"request_count"`
+
+	sortID string
 }
 
 type CredentialStore struct {
 	DB pg.DB
+
+	usage usageBuf
 }
 
 // Create generates a new access token with the given ID.
-func (cs *CredentialStore) Create(ctx context.Context, id, typ string) (*Token, error) {
+// If ttl is positive, the token expires after ttl;
+// otherwise it never expires. If scopes is not empty,
+// the token is limited to them.
+func (cs *CredentialStore) Create(ctx context.Context, id, typ string, ttl time.Duration, scopes []string) (*Token, error) {
 	if !validIDRegexp.MatchString(id) {
 		return nil, errors.WithDetailf(ErrBadID, "invalid id %q", id)
 	}
@@ -53,24 +98,44 @@ func (cs *CredentialStore) Create(ctx context.Context, id, typ string) (*Token,
 		return nil, errors.WithDetailf(ErrBadType, "unknown type %q", typ)
 	}
 
+	if ttl < 0 {
+		return nil, errors.WithDetailf(ErrBadTTL, "ttl %s", ttl)
+	}
+	for _, s := range scopes {
+		if !validScopes[s] {
+			return nil, errors.WithDetailf(ErrBadScope, "unknown scope %q", s)
+		}
+	}
+	if len(scopes) == 0 {
+		scopes = nil
+	}
+
+	var expires *time.Time
+	if ttl > 0 {
+		t := time.Now().Add(ttl)
+		expires = &t
+	}
+
 	var secret [tokenSize]byte
 	_, err := rand.Read(secret[:])
 	if err != nil {
 		return nil, err
 	}
-	var hashedSecret [32]byte
-	sha3pool.Sum256(hashedSecret[:], secret[:])
+	salt, secretHash, err := hashSecret(secret[:])
+	if err != nil {
+		return nil, err
+	}
 
 	const q = `
-		INSERT INTO access_tokens (id, type, hashed_secret)
-		VALUES($1, $2, $3)
+		INSERT INTO access_tokens (id, type, secret_salt, secret_hash, expires_at, scopes)
+		VALUES($1, $2, $3, $4, $5, $6)
 		RETURNING created, sort_id
 	`
 	var (
 		created time.Time
 		sortID  string
 	)
-	err = cs.DB.QueryRow(ctx, q, id, typ, hashedSecret[:]).Scan(&created, &sortID)
+	err = cs.DB.QueryRow(ctx, q, id, typ, salt, secretHash, expires, pq.StringArray(scopes)).Scan(&created, &sortID)
 	if pg.IsUniqueViolation(err) {
 		return nil, errors.WithDetailf(ErrDuplicateID, "id %q already in use", id)
 	}
@@ -83,47 +148,159 @@ func (cs *CredentialStore) Create(ctx context.Context, id, typ string) (*Token,
 		Token:   fmt.Sprintf("%s:%x", id, secret),
 		Type:    typ,
 		Created: created,
+		Expires: expires,
+		Scopes:  scopes,
 		sortID:  sortID,
 	}, nil
 }
 
 // Check returns whether or not an id-secret pair is a valid access token.
+// Expired tokens are not valid.
 func (cs *CredentialStore) Check(ctx context.Context, id, typ string, secret []byte) (bool, error) {
+	valid, _, err := cs.CheckScopes(ctx, id, typ, secret)
+	return valid, err
+}
+
+// CheckScopes is like Check, but also returns the scopes
+// a valid token is limited to. They are empty if the token
+// is not limited.
+//
+// Secrets are random, so a salted HMAC-SHA256 hash is as
+// hard to reverse as a slow password hash, and much cheaper
+// to check. Tokens created before that have an unsalted
+// SHA3 hash of their secret, or a bcrypt hash of it.
+// CheckScopes replaces either with a salted HMAC the first
+// time such a token is used successfully.
+func (cs *CredentialStore) CheckScopes(ctx context.Context, id, typ string, secret []byte) (valid bool, scopes []string, err error) {
+	var toHash [tokenSize]byte
+	copy(toHash[:], secret)
+
+	const q = `
+		SELECT hashed_secret, secret_salt, secret_hash, scopes FROM access_tokens
+		WHERE id=$1 AND type=$2
+		AND (expires_at IS NULL OR expires_at > now())
+	`
 	var (
-		toHash [tokenSize]byte
-		hashed [32]byte
+		legacyHash, salt, secretHash []byte
+		s                            pq.StringArray
 	)
-	copy(toHash[:], secret)
-	sha3pool.Sum256(hashed[:], toHash[:])
+	err = cs.DB.QueryRow(ctx, q, id, typ).Scan(&legacyHash, &salt, &secretHash, &s)
+	if err == sql.ErrNoRows {
+		return false, nil, nil
+	}
+	if err != nil {
+		return false, nil, err
+	}
 
-	const q = `SELECT EXISTS(SELECT 1 FROM access_tokens WHERE id=$1 AND type=$2 AND hashed_secret=$3)`
-	var valid bool
-	err := cs.DB.QueryRow(ctx, q, id, typ, hashed[:]).Scan(&valid)
+	switch {
+	case salt != nil:
+		if !hmac.Equal(secretMAC(salt, toHash[:]), secretHash) {
+			return false, nil, nil
+		}
+		return true, s, nil
+	case secretHash != nil:
+		err = bcrypt.CompareHashAndPassword(secretHash, toHash[:])
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, nil, nil
+		}
+		if err != nil {
+			return false, nil, errors.Wrap(err)
+		}
+	default:
+		var hashed [32]byte
+		sha3pool.Sum256(hashed[:], toHash[:])
+		if subtle.ConstantTimeCompare(hashed[:], legacyHash) != 1 {
+			return false, nil, nil
+		}
+	}
+	err = cs.rehash(ctx, id, toHash[:])
 	if err != nil {
-		return false, err
+		// The secret is still valid; try again next time.
+		log.Error(ctx, err)
 	}
+	return true, s, nil
+}
 
-	return valid, nil
+// rehash replaces the older hash of a token's secret
+// with a salted HMAC.
+func (cs *CredentialStore) rehash(ctx context.Context, id string, secret []byte) error {
+	salt, secretHash, err := hashSecret(secret)
+	if err != nil {
+		return err
+	}
+	const q = `
+		UPDATE access_tokens SET secret_salt=$2, secret_hash=$3, hashed_secret=NULL
+		WHERE id=$1 AND secret_salt IS NULL
+	`
+	_, err = cs.DB.Exec(ctx, q, id, salt, secretHash)
+	return errors.Wrapf(err, "rehashing secret of access token %s", id)
+}
+
+// hashSecret returns a new random salt
+// and the HMAC of secret keyed with it.
+func hashSecret(secret []byte) (salt, mac []byte, err error) {
+	salt = make([]byte, saltSize)
+	_, err = rand.Read(salt)
+	if err != nil {
+		return nil, nil, errors.Wrap(err)
+	}
+	return salt, secretMAC(salt, secret), nil
 }
 
-// List lists all access tokens.
-func (cs *CredentialStore) List(ctx context.Context, typ, after string, limit int) ([]*Token, string, error) {
+func secretMAC(salt, secret []byte) []byte {
+	h := hmac.New(sha256.New, salt)
+	h.Write(secret)
+	return h.Sum(nil)
+}
+
+// Allows reports whether a token limited to scopes
+// (or not limited, if scopes is empty) has scope.
+// The admin scope grants every other scope.
+func Allows(scopes []string, scope string) bool {
+	if len(scopes) == 0 {
+		return true
+	}
+	for _, s := range scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// List lists access tokens, newest first, a page at a time.
+// It returns up to limit tokens after the cursor after,
+// and the cursor of the next page.
+// If typ is not empty, it lists only tokens of that type.
+// If prefix is not empty, it lists only tokens whose
+// IDs begin with prefix.
+func (cs *CredentialStore) List(ctx context.Context, after string, limit int, typ, prefix string) ([]*Token, string, error) {
 	if limit == 0 {
 		limit = defaultLimit
 	}
 	const q = `
-		SELECT id, type, sort_id, created FROM access_tokens
+		SELECT id, type, sort_id, created, expires_at, scopes,
+			last_used_at, COALESCE(last_used_ip, ''), request_count
+		FROM access_tokens
 		WHERE ($1='' OR type=$1::access_token_type) AND ($2='' OR sort_id<$2)
+		AND ($4='' OR left(id, length($4))=$4)
 		ORDER BY sort_id DESC
 		LIMIT $3
 	`
 	var tokens []*Token
-	err := pg.ForQueryRows(ctx, cs.DB, q, typ, after, limit, func(id, typ, sortID string, created time.Time) {
+	err := pg.ForQueryRows(ctx, cs.DB, q, typ, after, limit, prefix, func(id, typ, sortID string, created time.Time, expires *time.Time, scopes pq.StringArray, lastUsed *time.Time, lastIP string, count int64) {
 		tokens = append(tokens, &Token{
 			ID:      id,
 			Type:    typ,
 			Created: created,
-			sortID:  sortID,
+			Expires: expires,
+			Scopes:  scopes,
+
+			LastUsed:     lastUsed,
+			LastUsedIP:   lastIP,
+			RequestCount: count,
+
+			sortID: sortID,
 		})
 	})
 	if err != nil {
@@ -156,3 +333,28 @@ func (cs *CredentialStore) Delete(ctx context.Context, id string) error {
 	}
 	return nil
 }
+
+// DeleteExpired deletes the tokens that have expired.
+func (cs *CredentialStore) DeleteExpired(ctx context.Context) error {
+	const q = `DELETE FROM access_tokens WHERE expires_at <= now()`
+	_, err := cs.DB.Exec(ctx, q)
+	return errors.Wrap(err, "deleting expired access tokens")
+}
+
+// ExpireTokens deletes expired tokens every period,
+// until ctx is done.
+func (cs *CredentialStore) ExpireTokens(ctx context.Context, period time.Duration) {
+	ticks := time.NewTicker(period)
+	defer ticks.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticks.C:
+			err := cs.DeleteExpired(ctx)
+			if err != nil {
+				log.Error(ctx, err)
+			}
+		}
+	}
+}
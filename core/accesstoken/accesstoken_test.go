@@ -1,13 +1,18 @@
 package accesstoken
 
 import (
+	"bytes"
 	"context"
 	"encoding/hex"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
+	"golang.org/x/crypto/bcrypt"
 
+	"chain/crypto/sha3pool"
+	"chain/database/pg"
 	"chain/database/pg/pgtest"
 	"chain/errors"
 	"chain/testutil"
@@ -30,7 +35,7 @@ func TestCreate(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		_, err := cs.Create(ctx, c.id, c.net)
+		_, err := cs.Create(ctx, c.id, c.net, 0, nil)
 		if errors.Root(err) != c.want {
 			t.Errorf("Create(%s, %s) error = %s want %s", c.id, c.net, err, c.want)
 		}
@@ -43,24 +48,26 @@ func TestList(t *testing.T) {
 	a := mustCreateToken(t, ctx, cs, "a", "client")
 	b := mustCreateToken(t, ctx, cs, "b", "network")
 	c := mustCreateToken(t, ctx, cs, "c", "client")
-	for _, token := range []*Token{a, b, c} {
+	ab := mustCreateToken(t, ctx, cs, "a_b", "client")
+	for _, token := range []*Token{a, b, c, ab} {
 		token.Token = ""
 	}
 
 	cases := []struct {
 		typ      string
+		prefix   string
 		after    string
 		limit    int
 		want     []*Token
 		wantNext string
 	}{{
 		limit:    100,
-		want:     []*Token{c, b, a},
+		want:     []*Token{ab, c, b, a},
 		wantNext: a.sortID,
 	}, {
 		limit:    1,
-		want:     []*Token{c},
-		wantNext: c.sortID,
+		want:     []*Token{ab},
+		wantNext: ab.sortID,
 	}, {
 		after:    c.sortID,
 		limit:    1,
@@ -69,7 +76,7 @@ func TestList(t *testing.T) {
 	}, {
 		typ:      "client",
 		limit:    100,
-		want:     []*Token{c, a},
+		want:     []*Token{ab, c, a},
 		wantNext: a.sortID,
 	}, {
 		typ:      "client",
@@ -77,6 +84,23 @@ func TestList(t *testing.T) {
 		limit:    1,
 		want:     []*Token{a},
 		wantNext: a.sortID,
+	}, {
+		prefix:   "a",
+		limit:    100,
+		want:     []*Token{ab, a},
+		wantNext: a.sortID,
+	}, {
+		// _ matches only itself, not any character.
+		prefix:   "a_",
+		limit:    100,
+		want:     []*Token{ab},
+		wantNext: ab.sortID,
+	}, {
+		typ:      "network",
+		prefix:   "a",
+		limit:    100,
+		want:     nil,
+		wantNext: "",
 	}, {
 		typ:      "network",
 		limit:    100,
@@ -85,7 +109,7 @@ func TestList(t *testing.T) {
 	}}
 
 	for _, c := range cases {
-		got, gotNext, err := cs.List(ctx, c.typ, c.after, c.limit)
+		got, gotNext, err := cs.List(ctx, c.after, c.limit, c.typ, c.prefix)
 
 		if err != nil {
 			t.Errorf("List(%s, %d) errored: %s", c.after, c.limit, err)
@@ -132,6 +156,210 @@ func TestCheck(t *testing.T) {
 	}
 }
 
+func TestLegacySecret(t *testing.T) {
+	ctx := context.Background()
+	dbtx := pgtest.NewTx(t)
+	cs := &CredentialStore{DB: dbtx}
+
+	// Store the secret the way tokens were stored
+	// before secrets were salted.
+	secret := make([]byte, tokenSize)
+	secret[0] = 1
+	var hashed [32]byte
+	sha3pool.Sum256(hashed[:], secret)
+	_, err := dbtx.Exec(ctx, `INSERT INTO access_tokens (id, type, hashed_secret) VALUES ('x', 'client', $1)`, hashed[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid, err := cs.Check(ctx, "x", "client", []byte("badsecret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if valid {
+		t.Fatal("expected bad secret to not be valid")
+	}
+
+	for i := 0; i < 2; i++ {
+		valid, err = cs.Check(ctx, "x", "client", secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !valid {
+			t.Fatalf("check %d: expected legacy secret to be valid", i)
+		}
+
+		checkRehashed(ctx, t, dbtx, "x")
+	}
+}
+
+func TestBcryptSecret(t *testing.T) {
+	ctx := context.Background()
+	dbtx := pgtest.NewTx(t)
+	cs := &CredentialStore{DB: dbtx}
+
+	// Store the secret the way tokens were stored
+	// before secrets were hashed with HMAC.
+	secret := make([]byte, tokenSize)
+	secret[0] = 1
+	hashed, err := bcrypt.GenerateFromPassword(secret, bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = dbtx.Exec(ctx, `INSERT INTO access_tokens (id, type, secret_hash) VALUES ('x', 'client', $1)`, hashed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid, err := cs.Check(ctx, "x", "client", []byte("badsecret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if valid {
+		t.Fatal("expected bad secret to not be valid")
+	}
+
+	for i := 0; i < 2; i++ {
+		valid, err = cs.Check(ctx, "x", "client", secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !valid {
+			t.Fatalf("check %d: expected bcrypt-hashed secret to be valid", i)
+		}
+		checkRehashed(ctx, t, dbtx, "x")
+	}
+}
+
+// checkRehashed checks that the token id has a
+// salted HMAC of its secret, and no other hash.
+func checkRehashed(ctx context.Context, t *testing.T, db pg.DB, id string) {
+	var legacy, salt, mac []byte
+	const q = `SELECT hashed_secret, secret_salt, secret_hash FROM access_tokens WHERE id=$1`
+	err := db.QueryRow(ctx, q, id).Scan(&legacy, &salt, &mac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if legacy != nil || len(salt) != saltSize || len(mac) != 32 {
+		t.Errorf("hashed_secret = %x, secret_salt = %x, secret_hash = %x, want only a salted HMAC", legacy, salt, mac)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	ctx := context.Background()
+	dbtx := pgtest.NewTx(t)
+	cs := &CredentialStore{DB: dbtx}
+
+	_, err := cs.Create(ctx, "x", "client", -time.Second, nil)
+	if errors.Root(err) != ErrBadTTL {
+		t.Errorf("Create with negative ttl: err = %v, want %v", err, ErrBadTTL)
+	}
+
+	token, err := cs.Create(ctx, "x", "client", time.Hour, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Expires == nil {
+		t.Fatal("token with ttl has no expiry")
+	}
+	secret, err := hex.DecodeString(strings.Split(token.Token, ":")[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid, err := cs.Check(ctx, "x", "client", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !valid {
+		t.Fatal("expected unexpired token to be valid")
+	}
+
+	// Expire the token. The transaction's now() is
+	// fixed, so move the expiry, not the clock.
+	_, err = dbtx.Exec(ctx, `UPDATE access_tokens SET expires_at = now() - interval '1 second' WHERE id='x'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid, err = cs.Check(ctx, "x", "client", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if valid {
+		t.Fatal("expected expired token to not be valid")
+	}
+
+	err = cs.DeleteExpired(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cs.Delete(ctx, "x")
+	if errors.Root(err) != pg.ErrUserInputNotFound {
+		t.Errorf("deleting expired token after DeleteExpired: err = %v, want %v", err, pg.ErrUserInputNotFound)
+	}
+}
+
+func TestScopes(t *testing.T) {
+	ctx := context.Background()
+	cs := &CredentialStore{DB: pgtest.NewTx(t)}
+
+	_, err := cs.Create(ctx, "x", "client", 0, []string{"bogus"})
+	if errors.Root(err) != ErrBadScope {
+		t.Errorf("Create with unknown scope: err = %v, want %v", err, ErrBadScope)
+	}
+
+	scopes := []string{ScopeSubmitTx, ScopeReadAccounts}
+	token, err := cs.Create(ctx, "x", "client", 0, scopes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := hex.DecodeString(strings.Split(token.Token, ":")[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid, got, err := cs.CheckScopes(ctx, "x", "client", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !valid || !testutil.DeepEqual(got, scopes) {
+		t.Errorf("CheckScopes = %v, %v, want true, %v", valid, got, scopes)
+	}
+
+	unscoped := mustCreateToken(t, ctx, cs, "y", "client")
+	secret, err = hex.DecodeString(strings.Split(unscoped.Token, ":")[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid, got, err = cs.CheckScopes(ctx, "y", "client", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !valid || len(got) != 0 {
+		t.Errorf("CheckScopes = %v, %v, want true, []", valid, got)
+	}
+}
+
+func TestAllows(t *testing.T) {
+	cases := []struct {
+		scopes []string
+		scope  string
+		want   bool
+	}{
+		{nil, ScopeSignBlock, true},
+		{[]string{ScopeSubmitTx}, ScopeSubmitTx, true},
+		{[]string{ScopeSubmitTx}, ScopeReadAccounts, false},
+		{[]string{ScopeAdmin}, ScopeSignBlock, true},
+		{[]string{ScopeReadAccounts}, ScopeAdmin, false},
+	}
+	for _, c := range cases {
+		got := Allows(c.scopes, c.scope)
+		if got != c.want {
+			t.Errorf("Allows(%v, %q) = %v, want %v", c.scopes, c.scope, got, c.want)
+		}
+	}
+}
+
 func TestDelete(t *testing.T) {
 	ctx := context.Background()
 	cs := &CredentialStore{DB: pgtest.NewTx(t)}
@@ -144,9 +372,30 @@ func TestDelete(t *testing.T) {
 }
 
 func mustCreateToken(t *testing.T, ctx context.Context, cs *CredentialStore, id, typ string) *Token {
-	token, err := cs.Create(ctx, id, typ)
+	token, err := cs.Create(ctx, id, typ, 0, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
 	return token
 }
+
+func TestHashSecret(t *testing.T) {
+	secret := []byte("secret")
+	salt1, mac1, err := hashSecret(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	salt2, mac2, err := hashSecret(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(salt1) != saltSize || bytes.Equal(salt1, salt2) || bytes.Equal(mac1, mac2) {
+		t.Errorf("hashSecret twice: salts %x, %x, hashes %x, %x; want distinct salts and hashes", salt1, salt2, mac1, mac2)
+	}
+	if !bytes.Equal(secretMAC(salt1, secret), mac1) {
+		t.Error("secretMAC does not reproduce the hash from hashSecret")
+	}
+	if bytes.Equal(secretMAC(salt1, []byte("other")), mac1) {
+		t.Error("secretMAC of another secret matches")
+	}
+}
@@ -0,0 +1,176 @@
+package accesstoken
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/database/pg"
+	"chain/errors"
+)
+
+// ArchiveKeySize is the size of the key that
+// encrypts archives of access tokens.
+const ArchiveKeySize = 32
+
+// archiveVersion is the version of archives written by
+// ExportAll. Version 1 archives, from before secrets had
+// salts of their own, can still be imported.
+const archiveVersion = 2
+
+var (
+	// ErrBadArchiveKey is returned by ExportAll and ImportAll
+	// when the key is not ArchiveKeySize bytes long.
+	ErrBadArchiveKey = errors.New("archive key must be 32 bytes")
+	// ErrBadArchive is returned by ImportAll when the archive
+	// cannot be decrypted with the key, or is malformed.
+	ErrBadArchive = errors.New("invalid access token archive")
+)
+
+// archive is the plaintext of an exported archive.
+type archive struct {
+	Version int             `json:"version"`
+	Tokens  []archivedToken `json:"tokens"`
+}
+
+// archivedToken is a token as stored in the database.
+// It holds the hash of the token's secret, never
+// the secret itself.
+type archivedToken struct {
+	ID           string     `json:"id"`
+	Type         string     `json:"type"`
+	SortID       string     `json:"sort_id"`
+	Created      time.Time  `json:"created_at"`
+	Expires      *time.Time `json:"expires_at,omitempty"`
+	Scopes       []string   `json:"scopes,omitempty"`
+	HashedSecret []byte     `json:"hashed_secret,omitempty"` // unsalted; see CheckScopes
+	SecretSalt   []byte     `json:"secret_salt,omitempty"`
+	SecretHash   []byte     `json:"secret_hash,omitempty"`
+}
+
+// ExportAll writes every access token to w, as an archive
+// encrypted and authenticated with key using AES-GCM.
+// The archive holds the hashes of token secrets, so tokens
+// imported from it accept the same secrets as before.
+func (cs *CredentialStore) ExportAll(ctx context.Context, w io.Writer, key []byte) error {
+	aead, err := archiveCipher(key)
+	if err != nil {
+		return err
+	}
+
+	const q = `
+		SELECT id, type, sort_id, created, expires_at, scopes, hashed_secret, secret_salt, secret_hash
+		FROM access_tokens ORDER BY sort_id
+	`
+	a := archive{Version: archiveVersion}
+	err = pg.ForQueryRows(ctx, cs.DB, q, func(id, typ, sortID string, created time.Time, expires *time.Time, scopes pq.StringArray, hashedSecret, secretSalt, secretHash []byte) {
+		a.Tokens = append(a.Tokens, archivedToken{
+			ID:           id,
+			Type:         typ,
+			SortID:       sortID,
+			Created:      created,
+			Expires:      expires,
+			Scopes:       scopes,
+			HashedSecret: hashedSecret,
+			SecretSalt:   secretSalt,
+			SecretHash:   secretHash,
+		})
+	})
+	if err != nil {
+		return errors.Wrap(err, "reading access tokens")
+	}
+
+	plaintext, err := json.Marshal(a)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	_, err = w.Write(aead.Seal(nonce, nonce, plaintext, nil))
+	return errors.Wrap(err, "writing access token archive")
+}
+
+// ImportAll reads an archive written by ExportAll
+// and stores its tokens. Tokens whose IDs are already
+// in use are skipped, so an interrupted import can be
+// run again. It returns the number of tokens stored.
+func (cs *CredentialStore) ImportAll(ctx context.Context, r io.Reader, key []byte) (int, error) {
+	aead, err := archiveCipher(key)
+	if err != nil {
+		return 0, err
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return 0, errors.Wrap(err, "reading access token archive")
+	}
+	if len(data) < aead.NonceSize() {
+		return 0, errors.WithDetail(ErrBadArchive, "archive is truncated")
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return 0, errors.WithDetail(ErrBadArchive, "wrong key or corrupt archive")
+	}
+
+	var a archive
+	err = json.Unmarshal(plaintext, &a)
+	if err != nil {
+		return 0, errors.WithDetail(ErrBadArchive, err.Error())
+	}
+	if a.Version < 1 || a.Version > archiveVersion {
+		return 0, errors.WithDetailf(ErrBadArchive, "unknown archive version %d", a.Version)
+	}
+
+	const q = `
+		INSERT INTO access_tokens (id, type, sort_id, created, expires_at, scopes, hashed_secret, secret_salt, secret_hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (id) DO NOTHING
+	`
+	var n int
+	for _, t := range a.Tokens {
+		var scopes interface{}
+		if len(t.Scopes) > 0 {
+			scopes = pq.StringArray(t.Scopes)
+		}
+		res, err := cs.DB.Exec(ctx, q, t.ID, t.Type, t.SortID, t.Created, t.Expires, scopes, nullBytes(t.HashedSecret), nullBytes(t.SecretSalt), nullBytes(t.SecretHash))
+		if err != nil {
+			return n, errors.Wrapf(err, "importing access token %s", t.ID)
+		}
+		k, err := res.RowsAffected()
+		if err != nil {
+			return n, errors.Wrap(err)
+		}
+		n += int(k)
+	}
+	return n, nil
+}
+
+// nullBytes returns b, or nil if b is empty,
+// so that empty hashes are stored as NULL.
+func nullBytes(b []byte) interface{} {
+	if len(b) == 0 {
+		return nil
+	}
+	return b
+}
+
+func archiveCipher(key []byte) (cipher.AEAD, error) {
+	if len(key) != ArchiveKeySize {
+		return nil, errors.WithDetailf(ErrBadArchiveKey, "key is %d bytes", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,75 @@
+package accesstoken
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"chain/database/pg/pgtest"
+	"chain/errors"
+)
+
+func TestArchive(t *testing.T) {
+	ctx := context.Background()
+	key := bytes.Repeat([]byte{1}, ArchiveKeySize)
+
+	cs := &CredentialStore{DB: pgtest.NewTx(t)}
+	token, err := cs.Create(ctx, "x", "client", 0, []string{ScopeSubmitTx})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustCreateToken(t, ctx, cs, "y", "network")
+
+	var buf bytes.Buffer
+	err = cs.ExportAll(ctx, &buf, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := buf.Bytes()
+
+	// Lose x, but keep y.
+	err = cs.Delete(ctx, "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = cs.ImportAll(ctx, bytes.NewReader(archive), bytes.Repeat([]byte{2}, ArchiveKeySize))
+	if errors.Root(err) != ErrBadArchive {
+		t.Errorf("ImportAll with wrong key: err = %v, want %v", err, ErrBadArchive)
+	}
+
+	n, err := cs.ImportAll(ctx, bytes.NewReader(archive), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("imported %d tokens, want 1 (y is already in use)", n)
+	}
+
+	secret, err := hex.DecodeString(strings.Split(token.Token, ":")[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid, scopes, err := cs.CheckScopes(ctx, "x", "client", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !valid || len(scopes) != 1 || scopes[0] != ScopeSubmitTx {
+		t.Errorf("imported token: valid = %v, scopes = %v, want true, [%s]", valid, scopes, ScopeSubmitTx)
+	}
+}
+
+func TestArchiveBadKey(t *testing.T) {
+	ctx := context.Background()
+	cs := new(CredentialStore)
+	err := cs.ExportAll(ctx, new(bytes.Buffer), []byte("short"))
+	if errors.Root(err) != ErrBadArchiveKey {
+		t.Errorf("ExportAll with short key: err = %v, want %v", err, ErrBadArchiveKey)
+	}
+	_, err = cs.ImportAll(ctx, new(bytes.Buffer), []byte("short"))
+	if errors.Root(err) != ErrBadArchiveKey {
+		t.Errorf("ImportAll with short key: err = %v, want %v", err, ErrBadArchiveKey)
+	}
+}
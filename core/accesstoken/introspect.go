@@ -0,0 +1,106 @@
+package accesstoken
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"chain/errors"
+)
+
+// An Authenticator checks the credentials of API requests.
+// CredentialStore is an Authenticator for the tokens
+// in the core's database.
+type Authenticator interface {
+	// CheckScopes reports whether secret is valid for
+	// the token id of type typ, and if so, the scopes
+	// the token is limited to. They are empty if the
+	// token is not limited.
+	CheckScopes(ctx context.Context, id, typ string, secret []byte) (valid bool, scopes []string, err error)
+}
+
+// ErrIntrospection is returned by Introspector when
+// the introspection endpoint fails.
+var ErrIntrospection = errors.New("token introspection failed")
+
+// Introspector is an Authenticator that delegates to an
+// OAuth 2.0 token introspection endpoint (RFC 7662),
+// so that credentials can be managed outside the core.
+//
+// It sends the token as "id:secret", with the secret
+// hex-encoded, the same form clients send, and the token
+// type, client or network, as token_type_hint. The token
+// is valid if the response is active and its token_type
+// is the type asked for. The space-separated scope of
+// the response limits the token, as the scopes of a
+// CredentialStore token do. A response without a scope
+// grants nothing, so the token is not valid; to allow
+// everything, the endpoint must return the admin scope.
+type Introspector struct {
+	URL string
+
+	// Token, if set, is sent as a bearer token
+	// to authenticate the core to the endpoint.
+	Token string
+
+	// Client sends requests to the endpoint.
+	// If nil, a client with a 10-second timeout is used.
+	Client *http.Client
+}
+
+var defaultIntrospectClient = &http.Client{Timeout: 10 * time.Second}
+
+// CheckScopes implements Authenticator.
+func (in *Introspector) CheckScopes(ctx context.Context, id, typ string, secret []byte) (bool, []string, error) {
+	form := url.Values{
+		"token":           {id + ":" + hex.EncodeToString(secret)},
+		"token_type_hint": {typ},
+	}
+	req, err := http.NewRequest("POST", in.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, nil, errors.Wrap(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if in.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+in.Token)
+	}
+
+	client := in.Client
+	if client == nil {
+		client = defaultIntrospectClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, nil, errors.Wrap(err, "introspecting token")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, nil, errors.WithDetailf(ErrIntrospection, "status %s from %s", resp.Status, in.URL)
+	}
+
+	var result struct {
+		Active    bool   `json:"active"`
+		TokenType string `json:"token_type"`
+		Scope     string `json:"scope"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return false, nil, errors.WithDetailf(ErrIntrospection, "decoding response: %s", err)
+	}
+	if !result.Active || result.TokenType != typ {
+		return false, nil, nil
+	}
+	scopes := strings.Fields(result.Scope)
+	if len(scopes) == 0 {
+		// No scopes would mean no limits to
+		// authorized, not no access.
+		return false, nil, nil
+	}
+	return true, scopes, nil
+}
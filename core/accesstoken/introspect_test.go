@@ -0,0 +1,63 @@
+package accesstoken
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"chain/errors"
+	"chain/testutil"
+)
+
+func TestIntrospector(t *testing.T) {
+	ctx := context.Background()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer core-secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch req.PostFormValue("token") {
+		case "alice:0102":
+			w.Write([]byte(`{"active": true, "token_type": "client", "scope": "submit-tx read-accounts"}`))
+		case "bob:0102":
+			w.Write([]byte(`{"active": true, "token_type": "client"}`))
+		case "dave:0102":
+			w.Write([]byte(`{"active": true, "token_type": "network", "scope": "sign-block"}`))
+		case "erin:0102":
+			w.Write([]byte(`{"active": true, "scope": "admin"}`))
+		default:
+			w.Write([]byte(`{"active": false}`))
+		}
+	}))
+	defer srv.Close()
+
+	in := &Introspector{URL: srv.URL, Token: "core-secret"}
+	cases := []struct {
+		id         string
+		wantValid  bool
+		wantScopes []string
+	}{
+		{"alice", true, []string{ScopeSubmitTx, ScopeReadAccounts}},
+		{"bob", false, nil},   // no scope grants nothing
+		{"carol", false, nil}, // inactive
+		{"dave", false, nil},  // network token, client asked for
+		{"erin", false, nil},  // no token type
+	}
+	for _, c := range cases {
+		valid, scopes, err := in.CheckScopes(ctx, c.id, "client", []byte{1, 2})
+		if err != nil {
+			t.Errorf("CheckScopes(%s) error = %v", c.id, err)
+			continue
+		}
+		if valid != c.wantValid || !testutil.DeepEqual(scopes, c.wantScopes) {
+			t.Errorf("CheckScopes(%s) = %v, %v, want %v, %v", c.id, valid, scopes, c.wantValid, c.wantScopes)
+		}
+	}
+
+	in.Token = "wrong"
+	_, _, err := in.CheckScopes(ctx, "alice", "client", []byte{1, 2})
+	if errors.Root(err) != ErrIntrospection {
+		t.Errorf("CheckScopes with bad endpoint credentials: err = %v, want %v", err, ErrIntrospection)
+	}
+}
@@ -0,0 +1,99 @@
+package accesstoken
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/errors"
+	"chain/log"
+)
+
+// usage is the use of one token since usage was last
+// written to the database.
+type usage struct {
+	last  time.Time
+	ip    string
+	count int64
+}
+
+// usageBuf holds token usage in memory, so that busy
+// tokens cost one write per period instead of one per
+// request.
+type usageBuf struct {
+	mu sync.Mutex // protects m
+	m  map[string]*usage
+}
+
+// RecordUse records that the token with the given id
+// was used for a request from ip. The use is written
+// to the database by the next call to FlushUsage.
+func (cs *CredentialStore) RecordUse(id, ip string) {
+	cs.usage.mu.Lock()
+	defer cs.usage.mu.Unlock()
+	if cs.usage.m == nil {
+		cs.usage.m = make(map[string]*usage)
+	}
+	u := cs.usage.m[id]
+	if u == nil {
+		u = new(usage)
+		cs.usage.m[id] = u
+	}
+	u.last = time.Now()
+	u.ip = ip
+	u.count++
+}
+
+// FlushUsage writes the token usage recorded since the
+// last call, for all tokens at once.
+func (cs *CredentialStore) FlushUsage(ctx context.Context) error {
+	cs.usage.mu.Lock()
+	m := cs.usage.m
+	cs.usage.m = nil
+	cs.usage.mu.Unlock()
+	if len(m) == 0 {
+		return nil
+	}
+
+	var (
+		ids, ips     pq.StringArray
+		lasts, count pq.Int64Array
+	)
+	for id, u := range m {
+		ids = append(ids, id)
+		ips = append(ips, u.ip)
+		lasts = append(lasts, u.last.UnixNano()/int64(time.Millisecond))
+		count = append(count, u.count)
+	}
+
+	const q = `
+		UPDATE access_tokens SET
+			last_used_at = GREATEST(last_used_at, to_timestamp(u.last_ms / 1000.0)),
+			last_used_ip = u.ip,
+			request_count = request_count + u.count
+		FROM unnest($1::text[], $2::text[], $3::bigint[], $4::bigint[]) AS u(id, ip, last_ms, count)
+		WHERE access_tokens.id = u.id
+	`
+	_, err := cs.DB.Exec(ctx, q, ids, ips, lasts, count)
+	return errors.Wrap(err, "writing access token usage")
+}
+
+// WriteUsage calls FlushUsage every period, until ctx
+// is done.
+func (cs *CredentialStore) WriteUsage(ctx context.Context, period time.Duration) {
+	ticks := time.NewTicker(period)
+	defer ticks.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticks.C:
+			err := cs.FlushUsage(ctx)
+			if err != nil {
+				log.Error(ctx, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package accesstoken
+
+import (
+	"context"
+	"testing"
+
+	"chain/database/pg/pgtest"
+)
+
+func TestRecordUse(t *testing.T) {
+	cs := new(CredentialStore)
+	cs.RecordUse("x", "10.0.0.1")
+	cs.RecordUse("x", "10.0.0.2")
+	cs.RecordUse("y", "10.0.0.3")
+
+	if len(cs.usage.m) != 2 {
+		t.Fatalf("recorded usage of %d tokens, want 2", len(cs.usage.m))
+	}
+	u := cs.usage.m["x"]
+	if u.count != 2 || u.ip != "10.0.0.2" {
+		t.Errorf("usage of x = %d requests from %s, want 2 from 10.0.0.2", u.count, u.ip)
+	}
+}
+
+func TestFlushUsage(t *testing.T) {
+	ctx := context.Background()
+	cs := &CredentialStore{DB: pgtest.NewTx(t)}
+	mustCreateToken(t, ctx, cs, "x", "client")
+	mustCreateToken(t, ctx, cs, "y", "client")
+
+	for i := 0; i < 3; i++ {
+		cs.RecordUse("x", "10.0.0.1")
+		err := cs.FlushUsage(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Usage of deleted tokens is dropped.
+	cs.RecordUse("deleted", "10.0.0.2")
+	err := cs.FlushUsage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens, _, err := cs.List(ctx, "", 10, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tok := range tokens {
+		switch tok.ID {
+		case "x":
+			if tok.RequestCount != 3 || tok.LastUsedIP != "10.0.0.1" || tok.LastUsed == nil {
+				t.Errorf("x: got %d requests from %q at %v, want 3 from 10.0.0.1", tok.RequestCount, tok.LastUsedIP, tok.LastUsed)
+			}
+		case "y":
+			if tok.RequestCount != 0 || tok.LastUsed != nil {
+				t.Errorf("y: got %d requests at %v, want none", tok.RequestCount, tok.LastUsed)
+			}
+		}
+	}
+}
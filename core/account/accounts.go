@@ -82,8 +82,9 @@ func (m *Manager) ExpireReservations(ctx context.Context, period time.Duration)
 
 type Account struct {
 	*signers.Signer
-	Alias string
-	Tags  map[string]interface{}
+	Alias   string
+	Tags    map[string]interface{}
+	Deleted bool
 }
 
 // Create creates a new Account.
@@ -184,6 +185,10 @@ func (m *Manager) createControlProgram(ctx context.Context, accountID string, ch
 	if err != nil {
 		return nil, err
 	}
+	err = m.checkActive(ctx, account.ID)
+	if err != nil {
+		return nil, err
+	}
 
 	idx, err := m.nextIndex(ctx)
 	if err != nil {
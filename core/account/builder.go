@@ -52,6 +52,10 @@ func (a *spendAction) Build(ctx context.Context, b *txbuilder.TemplateBuilder) e
 	if err != nil {
 		return errors.Wrap(err, "get account info")
 	}
+	err = a.accounts.checkActive(ctx, acct.ID)
+	if err != nil {
+		return err
+	}
 
 	src := source{
 		AssetID:   a.AssetID,
@@ -85,14 +89,46 @@ func (a *spendAction) Build(ctx context.Context, b *txbuilder.TemplateBuilder) e
 		// Don't insert the control program until callbacks are executed.
 		a.accounts.insertControlProgramDelayed(ctx, b, acp)
 
-		err = b.AddOutput(bc.NewTxOutput(a.AssetID, res.Change, acp.controlProgram, nil))
-		if err != nil {
-			return errors.Wrap(err, "adding change output")
+		for _, amount := range splitChange(res.Change, a.Amount) {
+			err = b.AddOutput(bc.NewTxOutput(a.AssetID, amount, acp.controlProgram, nil))
+			if err != nil {
+				return errors.Wrap(err, "adding change output")
+			}
 		}
 	}
 	return nil
 }
 
+// maxChangeOutputs is the most outputs
+// the change of a spend is split into.
+const maxChangeOutputs = 4
+
+// splitChange divides the change of a spend of amount into
+// as many as maxChangeOutputs amounts, none smaller than
+// amount. When a spend uses only a small part of a large
+// output, this leaves the account several outputs instead
+// of one, so that once the transaction is confirmed,
+// concurrent spends of similar amounts can each reserve
+// one of them rather than contending for the same output.
+func splitChange(change, amount uint64) []uint64 {
+	n := uint64(1)
+	if amount > 0 {
+		n = change / amount
+	}
+	if n > maxChangeOutputs {
+		n = maxChangeOutputs
+	}
+	if n <= 1 {
+		return []uint64{change}
+	}
+	parts := make([]uint64, n)
+	for i := range parts {
+		parts[i] = change / n
+	}
+	parts[n-1] += change % n
+	return parts
+}
+
 func (m *Manager) NewSpendUTXOAction(outputID bc.Hash) txbuilder.Action {
 	return &spendUTXOAction{
 		accounts: m,
@@ -129,6 +165,10 @@ func (a *spendUTXOAction) Build(ctx context.Context, b *txbuilder.TemplateBuilde
 	if err != nil {
 		return err
 	}
+	err = a.accounts.checkActive(ctx, acct.ID)
+	if err != nil {
+		return err
+	}
 	txInput, sigInst, err := utxoToInputs(ctx, acct, res.UTXOs[0], a.ReferenceData)
 	if err != nil {
 		return err
@@ -157,7 +197,7 @@ func utxoToInputs(ctx context.Context, account *signers.Signer, u *utxo, refData
 		AssetAmount: u.AssetAmount,
 	}
 
-	path := signers.Path(account, signers.AccountKeySpace, u.ControlProgramIndex)
+	path := programPath(account, u.ControlProgramIndex)
 	sigInst.AddWitnessKeys(account.XPubs, path, account.Quorum)
 
 	return txInput, sigInst, nil
@@ -0,0 +1,146 @@
+package account
+
+import (
+	"context"
+	stdsql "database/sql"
+	"encoding/json"
+
+	"chain/database/pg"
+	"chain/errors"
+)
+
+// ErrDeleted is returned when spending from, or making
+// control programs for, an account that has been deleted.
+var ErrDeleted = errors.New("account deleted")
+
+// checkActive returns ErrDeleted if the account
+// with the given ID has been deleted.
+func (m *Manager) checkActive(ctx context.Context, accountID string) error {
+	const q = `SELECT deleted_at IS NOT NULL FROM accounts WHERE account_id=$1`
+	var deleted bool
+	err := m.db.QueryRow(ctx, q, accountID).Scan(&deleted)
+	if err == stdsql.ErrNoRows {
+		return errors.WithDetailf(pg.ErrUserInputNotFound, "account id: %s", accountID)
+	}
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	if deleted {
+		return errors.WithDetailf(ErrDeleted, "account id: %s", accountID)
+	}
+	return nil
+}
+
+// UpdateAlias changes the alias of an account, identified by
+// its ID or current alias. An empty alias removes the account's
+// alias. Other Core processes may still resolve the old alias
+// to the account until it leaves their caches.
+func (m *Manager) UpdateAlias(ctx context.Context, accID, accAlias, alias string) (*Account, error) {
+	signer, err := m.findAccount(ctx, accID, accAlias)
+	if err != nil {
+		return nil, err
+	}
+
+	aliasSQL := stdsql.NullString{
+		String: alias,
+		Valid:  alias != "",
+	}
+
+	// The old row in the FROM clause holds the values
+	// from before the update.
+	const q = `
+		UPDATE accounts a SET alias = $2
+		FROM accounts old
+		WHERE a.account_id = $1 AND old.account_id = $1 AND a.deleted_at IS NULL
+		RETURNING old.alias, a.tags
+	`
+	var (
+		oldAlias stdsql.NullString
+		tags     []byte
+	)
+	err = m.db.QueryRow(ctx, q, signer.ID, aliasSQL).Scan(&oldAlias, &tags)
+	if pg.IsUniqueViolation(err) {
+		return nil, errors.WithDetail(ErrDuplicateAlias, "an account with the provided alias already exists")
+	} else if err == stdsql.ErrNoRows {
+		return nil, errors.WithDetailf(ErrDeleted, "account id: %s", signer.ID)
+	} else if err != nil {
+		return nil, errors.Wrap(err)
+	}
+
+	m.cacheMu.Lock()
+	m.aliasCache.Remove(oldAlias.String)
+	m.cacheMu.Unlock()
+
+	// Outputs the account holds are listed under the new
+	// alias. Those it spent keep the alias they had.
+	const outputsQ = `
+		UPDATE annotated_outputs SET account_alias = $2
+		WHERE account_id = $1 AND upper_inf(timespan)
+	`
+	_, err = m.db.Exec(ctx, outputsQ, signer.ID, aliasSQL)
+	if err != nil {
+		return nil, errors.Wrap(err, "updating output aliases")
+	}
+
+	account := &Account{Signer: signer, Alias: alias}
+	if len(tags) > 0 {
+		err = json.Unmarshal(tags, &account.Tags)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding tags")
+		}
+	}
+	err = m.indexAnnotatedAccount(ctx, account)
+	if err != nil {
+		return nil, errors.Wrap(err, "indexing annotated account")
+	}
+	return account, nil
+}
+
+// Delete deletes an account, identified by its ID or alias. A
+// deleted account can no longer spend or make control programs,
+// and its alias is free to be used by another account. The query
+// indexes keep it, marked as deleted, and its transactions and
+// outputs, which continue to be indexed.
+//
+// An account with reservations cannot be deleted, so Delete must
+// be called in the leader process, where reservations are held.
+func (m *Manager) Delete(ctx context.Context, accID, accAlias string) error {
+	signer, err := m.findAccount(ctx, accID, accAlias)
+	if err != nil {
+		return err
+	}
+	if res := m.Reservations(signer.ID); len(res) > 0 {
+		return errors.WithDetailf(ErrReserved, "account has %d reservations for transactions being built", len(res))
+	}
+
+	const q = `
+		UPDATE accounts a SET alias = NULL, deleted_at = now()
+		FROM accounts old
+		WHERE a.account_id = $1 AND old.account_id = $1 AND a.deleted_at IS NULL
+		RETURNING old.alias, a.tags
+	`
+	var (
+		alias stdsql.NullString
+		tags  []byte
+	)
+	err = m.db.QueryRow(ctx, q, signer.ID).Scan(&alias, &tags)
+	if err == stdsql.ErrNoRows {
+		return errors.WithDetailf(ErrDeleted, "account id: %s", signer.ID)
+	} else if err != nil {
+		return errors.Wrap(err)
+	}
+
+	m.cacheMu.Lock()
+	m.aliasCache.Remove(alias.String)
+	m.cacheMu.Unlock()
+
+	account := &Account{Signer: signer, Alias: alias.String, Deleted: true}
+	if len(tags) > 0 {
+		err = json.Unmarshal(tags, &account.Tags)
+		if err != nil {
+			return errors.Wrap(err, "decoding tags")
+		}
+	}
+	err = m.indexAnnotatedAccount(ctx, account)
+	return errors.Wrap(err, "indexing annotated account")
+}
@@ -0,0 +1,87 @@
+package account
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"chain/core/txbuilder"
+	"chain/database/pg"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestUpdateAlias(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	m := NewManager(db, prottest.NewChain(t), nil)
+	ctx := context.Background()
+	account := m.createTestAccount(ctx, t, "typo", map[string]interface{}{"a": "b"})
+	m.createTestAccount(ctx, t, "taken", nil)
+
+	// Fill the alias cache.
+	_, err := m.FindByAlias(ctx, "typo")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	updated, err := m.UpdateAlias(ctx, "", "typo", "fixed")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if updated.Alias != "fixed" || updated.Tags["a"] != "b" {
+		t.Errorf("updated account = %+v, want alias fixed and the same tags", updated)
+	}
+
+	found, err := m.FindByAlias(ctx, "fixed")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if found.ID != account.ID {
+		t.Errorf("found account %s by new alias, want %s", found.ID, account.ID)
+	}
+	_, err = m.FindByAlias(ctx, "typo")
+	if errors.Root(err) != pg.ErrUserInputNotFound {
+		t.Errorf("finding by old alias err = %v want %v", err, pg.ErrUserInputNotFound)
+	}
+
+	_, err = m.UpdateAlias(ctx, account.ID, "", "taken")
+	if errors.Root(err) != ErrDuplicateAlias {
+		t.Errorf("renaming to a taken alias err = %v want %v", err, ErrDuplicateAlias)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	m := NewManager(db, prottest.NewChain(t), nil)
+	ctx := context.Background()
+	account := m.createTestAccount(ctx, t, "old", nil)
+
+	err := m.Delete(ctx, "", "old")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	_, err = m.CreateControlProgram(ctx, account.ID, false, time.Time{})
+	if errors.Root(err) != ErrDeleted {
+		t.Errorf("creating control program err = %v want %v", err, ErrDeleted)
+	}
+	spend := m.NewSpendAction(bc.AssetAmount{AssetID: bc.AssetID{1}, Amount: 1}, account.ID, nil, nil)
+	err = spend.Build(ctx, txbuilder.NewBuilder(time.Now().Add(time.Minute)))
+	if errors.Root(err) != ErrDeleted {
+		t.Errorf("spending err = %v want %v", err, ErrDeleted)
+	}
+	err = m.Delete(ctx, account.ID, "")
+	if errors.Root(err) != ErrDeleted {
+		t.Errorf("deleting again err = %v want %v", err, ErrDeleted)
+	}
+	_, err = m.UpdateAlias(ctx, account.ID, "", "new")
+	if errors.Root(err) != ErrDeleted {
+		t.Errorf("renaming err = %v want %v", err, ErrDeleted)
+	}
+
+	// The alias is free again.
+	m.createTestAccount(ctx, t, "old", nil)
+}
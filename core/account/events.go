@@ -0,0 +1,180 @@
+package account
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
+)
+
+// EventsPinName is used to identify the pin associated
+// with the processor that records account events
+// for confirmed transactions.
+const EventsPinName = "account-events"
+
+// Event types, in the order they occur
+// in the lifecycle of a transaction.
+const (
+	EventBuilt     = "built"
+	EventSubmitted = "submitted"
+	EventConfirmed = "confirmed"
+	EventSpent     = "spent"
+
+	// EventExpired is recorded in place of EventConfirmed
+	// when the generator drops a submitted tx whose
+	// max time passed before it was included in a block.
+	EventExpired = "expired"
+)
+
+// Event is an entry in an account's event stream.
+type Event struct {
+	ID            string    `json:"id"`
+	Type          string    `json:"type"`
+	AccountID     string    `json:"account_id"`
+	TransactionID bc.Hash   `json:"transaction_id"`
+	OutputID      *bc.Hash  `json:"output_id,omitempty"`
+	BlockHeight   uint64    `json:"block_height,omitempty"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// RecordTxEvent records an event of type typ
+// (EventBuilt, EventSubmitted, or EventExpired) for each account
+// whose outputs tx spends or creates.
+func (m *Manager) RecordTxEvent(ctx context.Context, typ string, tx *bc.Tx) error {
+	accountIDs, err := m.txAccounts(ctx, tx)
+	if err != nil {
+		return errors.Wrap(err, "looking up tx accounts")
+	}
+	if len(accountIDs) == 0 {
+		return nil
+	}
+	const q = `
+		INSERT INTO account_events (type, account_id, tx_hash)
+		SELECT $1, unnest($2::text[]), $3
+		ON CONFLICT (account_id, type, tx_hash, output_id) DO NOTHING
+	`
+	_, err = m.db.Exec(ctx, q, typ, pq.StringArray(accountIDs), tx.ID)
+	return errors.Wrap(err, "recording tx events")
+}
+
+// indexAccountEvents records a confirmation event for each
+// account involved in each of b's transactions, and a spend
+// event for each account output spent in b.
+// It runs after the account indexer, so that outputs created
+// in b are already known, and before spent outputs are deleted.
+func (m *Manager) indexAccountEvents(ctx context.Context, b *bc.Block) error {
+	var (
+		types      pq.StringArray
+		accountIDs pq.StringArray
+		txHashes   pq.ByteaArray
+		outputIDs  pq.ByteaArray
+		heights    pq.Int64Array
+	)
+	for _, tx := range b.Transactions {
+		ids, err := m.txAccounts(ctx, tx)
+		if err != nil {
+			return errors.Wrap(err, "looking up tx accounts")
+		}
+		for _, id := range ids {
+			types = append(types, EventConfirmed)
+			accountIDs = append(accountIDs, id)
+			txHashes = append(txHashes, tx.ID.Bytes())
+			outputIDs = append(outputIDs, []byte{})
+			heights = append(heights, int64(b.Height))
+		}
+
+		const q = `
+			SELECT output_id, account_id FROM account_utxos
+			WHERE output_id = ANY($1::bytea[])
+		`
+		err = pg.ForQueryRows(ctx, m.db, q, prevoutDBKeys(tx), func(outputID bc.Hash, accountID string) {
+			types = append(types, EventSpent)
+			accountIDs = append(accountIDs, accountID)
+			txHashes = append(txHashes, tx.ID.Bytes())
+			outputIDs = append(outputIDs, outputID.Bytes())
+			heights = append(heights, int64(b.Height))
+		})
+		if err != nil {
+			return errors.Wrap(err, "looking up spent account outputs")
+		}
+	}
+
+	const q = `
+		INSERT INTO account_events (type, account_id, tx_hash, output_id, block_height)
+		SELECT unnest($1::text[]), unnest($2::text[]), unnest($3::bytea[]),
+			unnest($4::bytea[]), unnest($5::bigint[])
+		ON CONFLICT (account_id, type, tx_hash, output_id) DO NOTHING
+	`
+	_, err := m.db.Exec(ctx, q, types, accountIDs, txHashes, outputIDs, heights)
+	return errors.Wrap(err, "inserting account events")
+}
+
+// Events returns up to limit events for the given account,
+// oldest first, beginning after the cursor after.
+// It also returns a cursor for the next page.
+func (m *Manager) Events(ctx context.Context, accountID, after string, limit int) ([]*Event, string, error) {
+	var afterSeq int64
+	if after != "" {
+		var err error
+		afterSeq, err = strconv.ParseInt(after, 10, 64)
+		if err != nil {
+			return nil, "", errors.WithDetailf(httpjson.ErrBadRequest, "invalid after: %q", after)
+		}
+	}
+
+	const q = `
+		SELECT seq, type, account_id, tx_hash, output_id, block_height, created_at
+		FROM account_events
+		WHERE account_id = $1 AND seq > $2
+		ORDER BY seq
+		LIMIT $3
+	`
+	var events []*Event
+	err := pg.ForQueryRows(ctx, m.db, q, accountID, afterSeq, limit,
+		func(seq int64, typ, accountID string, txHash bc.Hash, outputID []byte, height int64, created time.Time) {
+			ev := &Event{
+				ID:            strconv.FormatInt(seq, 10),
+				Type:          typ,
+				AccountID:     accountID,
+				TransactionID: txHash,
+				BlockHeight:   uint64(height),
+				Timestamp:     created,
+			}
+			if len(outputID) > 0 {
+				var h bc.Hash
+				copy(h[:], outputID)
+				ev.OutputID = &h
+			}
+			events = append(events, ev)
+			afterSeq = seq
+		})
+	if err != nil {
+		return nil, "", errors.Wrap(err, "listing account events")
+	}
+	return events, strconv.FormatInt(afterSeq, 10), nil
+}
+
+// txAccounts returns the IDs of the accounts whose outputs
+// tx spends or creates.
+func (m *Manager) txAccounts(ctx context.Context, tx *bc.Tx) ([]string, error) {
+	var programs pq.ByteaArray
+	for _, out := range tx.Outputs {
+		programs = append(programs, out.ControlProgram)
+	}
+	const q = `
+		SELECT account_id FROM account_utxos WHERE output_id = ANY($1::bytea[])
+		UNION
+		SELECT signer_id FROM account_control_programs WHERE control_program = ANY($2::bytea[])
+	`
+	var accountIDs []string
+	err := pg.ForQueryRows(ctx, m.db, q, prevoutDBKeys(tx), programs, func(accountID string) {
+		accountIDs = append(accountIDs, accountID)
+	})
+	return accountIDs, err
+}
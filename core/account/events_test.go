@@ -0,0 +1,92 @@
+package account
+
+import (
+	"context"
+	"testing"
+
+	"chain/database/pg/pgtest"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestAccountEvents(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	m := NewManager(db, prottest.NewChain(t), nil)
+	ctx := context.Background()
+
+	acc := m.createTestAccount(ctx, t, "", nil)
+	acp := m.createTestControlProgram(ctx, t, acc.ID).controlProgram
+
+	assetID := bc.AssetID{}
+	tx1 := bc.NewTx(bc.TxData{
+		Outputs: []*bc.TxOutput{
+			bc.NewTxOutput(assetID, 1, acp, nil),
+		},
+	})
+	err := m.RecordTxEvent(ctx, EventBuilt, tx1)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	block1 := &bc.Block{BlockHeader: bc.BlockHeader{Height: 1}, Transactions: []*bc.Tx{tx1}}
+	err = m.indexAccountUTXOs(ctx, block1)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = m.indexAccountEvents(ctx, block1)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	tx2 := bc.NewTx(bc.TxData{
+		Inputs: []*bc.TxInput{
+			bc.NewSpendInput(nil, tx1.Results[0].SourceID, assetID, 1, tx1.Results[0].SourcePos, acp, tx1.Results[0].RefDataHash, nil),
+		},
+	})
+	block2 := &bc.Block{BlockHeader: bc.BlockHeader{Height: 2}, Transactions: []*bc.Tx{tx2}}
+	err = m.indexAccountUTXOs(ctx, block2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = m.indexAccountEvents(ctx, block2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	// Reprocessing a block must not duplicate its events.
+	err = m.indexAccountEvents(ctx, block2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	type summary struct {
+		typ    string
+		txID   bc.Hash
+		height uint64
+	}
+	want := []summary{
+		{EventBuilt, tx1.ID, 0},
+		{EventConfirmed, tx1.ID, 1},
+		{EventConfirmed, tx2.ID, 2},
+		{EventSpent, tx2.ID, 2},
+	}
+
+	// Page through the events two at a time.
+	var (
+		got   []summary
+		after string
+	)
+	for i := 0; i < 3; i++ {
+		var events []*Event
+		events, after, err = m.Events(ctx, acc.ID, after, 2)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		for _, ev := range events {
+			got = append(got, summary{ev.Type, ev.TransactionID, ev.BlockHeight})
+		}
+	}
+	if !testutil.DeepEqual(got, want) {
+		t.Errorf("events = %+v want %+v", got, want)
+	}
+}
@@ -38,10 +38,11 @@ type Saver interface {
 
 func Annotated(a *Account) (*query.AnnotatedAccount, error) {
 	aa := &query.AnnotatedAccount{
-		ID:     a.ID,
-		Alias:  a.Alias,
-		Quorum: a.Quorum,
-		Tags:   &emptyJSONObject,
+		ID:        a.ID,
+		Alias:     a.Alias,
+		Quorum:    a.Quorum,
+		Tags:      &emptyJSONObject,
+		IsDeleted: query.Bool(a.Deleted),
 	}
 
 	tags, err := json.Marshal(a.Tags)
@@ -109,8 +110,13 @@ func (m *Manager) ProcessBlocks(ctx context.Context) {
 	go m.pinStore.ProcessBlocks(ctx, m.chain, DeleteSpentsPinName, func(ctx context.Context, b *bc.Block) error {
 		<-m.pinStore.PinWaiter(PinName, b.Height)
 		<-m.pinStore.PinWaiter(query.TxPinName, b.Height)
+		<-m.pinStore.PinWaiter(EventsPinName, b.Height)
 		return m.deleteSpentOutputs(ctx, b)
 	})
+	go m.pinStore.ProcessBlocks(ctx, m.chain, EventsPinName, func(ctx context.Context, b *bc.Block) error {
+		<-m.pinStore.PinWaiter(PinName, b.Height)
+		return m.indexAccountEvents(ctx, b)
+	})
 	m.pinStore.ProcessBlocks(ctx, m.chain, PinName, m.indexAccountUTXOs)
 }
 
@@ -138,18 +144,8 @@ func (m *Manager) indexAccountUTXOs(ctx context.Context, b *bc.Block) error {
 	blockPositions := make(map[bc.Hash]uint32, len(b.Transactions))
 	for i, tx := range b.Transactions {
 		blockPositions[tx.ID] = uint32(i)
-		for j, out := range tx.Outputs {
-			out := &rawOutput{
-				OutputID:       tx.OutputID(uint32(j)),
-				AssetAmount:    out.AssetAmount,
-				ControlProgram: out.ControlProgram,
-				txHash:         tx.ID,
-				outputIndex:    uint32(j),
-				sourceID:       tx.Results[j].SourceID,
-				sourcePos:      tx.Results[j].SourcePos,
-				refData:        tx.Results[j].RefDataHash,
-			}
-			outs = append(outs, out)
+		for j := range tx.Outputs {
+			outs = append(outs, blockOutput(tx, uint32(j)))
 		}
 	}
 	accOuts, err := m.loadAccountInfo(ctx, outs)
@@ -173,6 +169,21 @@ func prevoutDBKeys(txs ...*bc.Tx) (outputIDs pq.ByteaArray) {
 	return
 }
 
+// blockOutput returns the output of tx with the given index.
+func blockOutput(tx *bc.Tx, index uint32) *rawOutput {
+	out := tx.Outputs[index]
+	return &rawOutput{
+		OutputID:       tx.OutputID(index),
+		AssetAmount:    out.AssetAmount,
+		ControlProgram: out.ControlProgram,
+		txHash:         tx.ID,
+		outputIndex:    index,
+		sourceID:       tx.Results[index].SourceID,
+		sourcePos:      tx.Results[index].SourcePos,
+		refData:        tx.Results[index].RefDataHash,
+	}
+}
+
 // loadAccountInfo turns a set of output IDs into a set of
 // outputs by adding account annotations.  Outputs that can't be
 // annotated are excluded from the result.
@@ -4,35 +4,318 @@ import (
 	"context"
 	"time"
 
+	"github.com/lib/pq"
+
+	"chain/core/signers"
 	"chain/core/txbuilder"
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg"
 	"chain/errors"
+	"chain/protocol/vmutil"
 )
 
 const defaultReceiverExpiry = 30 * 24 * time.Hour // 30 days
 
+// DefaultGapLimit is the number of consecutive unused receivers
+// after which RecoverReceivers stops looking for more.
+const DefaultGapLimit = 20
+
+// receiverIndexBit is set in the key index of a control program
+// derived for a receiver from its index in the account, rather
+// than from the Core's control program sequence. The sequence
+// never gets near it.
+const receiverIndexBit = 1 << 62
+
+// programPath returns the derivation path of the
+// control program with the given key index in account.
+func programPath(account *signers.Signer, keyIndex uint64) [][]byte {
+	if keyIndex&receiverIndexBit != 0 {
+		// A receiver path has no hardened steps, so Selectors can't fail.
+		path, _ := chainkd.ReceiverPath(keyIndex &^ receiverIndexBit).Selectors()
+		return path
+	}
+	return signers.Path(account, signers.AccountKeySpace, keyIndex)
+}
+
+// receiverProgram returns the control program of
+// the receiver with the given index in account.
+func receiverProgram(account *signers.Signer, idx uint64, expiresAt time.Time) (*controlProgram, error) {
+	keyIndex := idx | receiverIndexBit
+	derivedXPubs := chainkd.DeriveXPubs(account.XPubs, programPath(account, keyIndex))
+	control, err := vmutil.P2SPMultiSigProgram(chainkd.XPubKeys(derivedXPubs), account.Quorum)
+	if err != nil {
+		return nil, err
+	}
+	return &controlProgram{
+		accountID:      account.ID,
+		keyIndex:       keyIndex,
+		controlProgram: control,
+		expiresAt:      expiresAt,
+	}, nil
+}
+
 // CreateReceiver creates a new account receiver for an account
 // with the provided expiry. If a zero time is provided for the
 // expiry, a default expiry of 30 days from the current time is
 // used.
+//
+// Receivers are numbered within their account, and each one's
+// control program is derived from the account's xpubs and its
+// number alone, so they can be found again with RecoverReceivers.
 func (m *Manager) CreateReceiver(ctx context.Context, accID, accAlias string, expiresAt time.Time) (*txbuilder.Receiver, error) {
 	if expiresAt.IsZero() {
 		expiresAt = time.Now().Add(defaultReceiverExpiry)
 	}
 
-	if accAlias != "" {
-		s, err := m.FindByAlias(ctx, accAlias)
-		if err != nil {
-			return nil, err
-		}
-		accID = s.ID
+	account, err := m.findAccount(ctx, accID, accAlias)
+	if err != nil {
+		return nil, err
+	}
+	err = m.checkActive(ctx, account.ID)
+	if err != nil {
+		return nil, err
 	}
 
-	cp, err := m.CreateControlProgram(ctx, accID, false, expiresAt)
+	const q = `
+		UPDATE accounts SET next_receiver_index = next_receiver_index + 1
+		WHERE account_id = $1
+		RETURNING next_receiver_index - 1
+	`
+	var idx uint64
+	err = m.db.QueryRow(ctx, q, account.ID).Scan(&idx)
+	if err != nil {
+		return nil, errors.Wrap(err, "allocating receiver index")
+	}
+
+	cp, err := receiverProgram(account, idx, expiresAt)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	err = m.insertAccountControlProgram(ctx, cp)
 	if err != nil {
 		return nil, errors.Wrap(err)
 	}
 	return &txbuilder.Receiver{
-		ControlProgram: cp,
+		ControlProgram: cp.controlProgram,
 		ExpiresAt:      expiresAt,
 	}, nil
 }
+
+func (m *Manager) findAccount(ctx context.Context, accID, accAlias string) (*signers.Signer, error) {
+	if accAlias != "" {
+		return m.FindByAlias(ctx, accAlias)
+	}
+	return m.findByID(ctx, accID)
+}
+
+// ReceiverRecovery reports the result of RecoverReceivers.
+type ReceiverRecovery struct {
+	// Receivers is the number of receivers recovered: one more
+	// than the index of the last one used on the blockchain.
+	Receivers uint64 `json:"receivers"`
+
+	// UnspentOutputs is the number of unspent outputs
+	// controlled by the recovered receivers.
+	UnspentOutputs int `json:"unspent_outputs"`
+}
+
+// RecoverReceivers finds the receivers of an account that have been
+// used on the blockchain, for an account restored on a new Core from
+// the xpubs of an account on another one. It derives the receivers'
+// control programs in order until gapLimit consecutive ones have not
+// been used, and watches those before the last used one, as if they
+// had been made by CreateReceiver. Unspent outputs they control
+// become spendable by the account.
+//
+// It looks for the programs in the outputs indexed for queries, so it
+// should be run once the Core has caught up with the blockchain. If
+// gapLimit is zero, DefaultGapLimit is used. If expiresAt is zero,
+// the recovered receivers do not expire.
+func (m *Manager) RecoverReceivers(ctx context.Context, accID, accAlias string, gapLimit int, expiresAt time.Time) (*ReceiverRecovery, error) {
+	if gapLimit <= 0 {
+		gapLimit = DefaultGapLimit
+	}
+
+	account, err := m.findAccount(ctx, accID, accAlias)
+	if err != nil {
+		return nil, err
+	}
+	err = m.checkActive(ctx, account.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		next  uint64 // one past the last used index
+		progs []*controlProgram
+	)
+	for start := uint64(0); start < next+uint64(gapLimit); {
+		end := next + uint64(gapLimit)
+		batch := make([]*controlProgram, 0, end-start)
+		for idx := start; idx < end; idx++ {
+			cp, err := receiverProgram(account, idx, expiresAt)
+			if err != nil {
+				return nil, errors.Wrap(err)
+			}
+			batch = append(batch, cp)
+		}
+		used, err := m.usedPrograms(ctx, batch)
+		if err != nil {
+			return nil, errors.Wrap(err, "finding used receivers")
+		}
+		for i, cp := range batch {
+			if used[string(cp.controlProgram)] {
+				next = start + uint64(i) + 1
+			}
+		}
+		progs = append(progs, batch...)
+		start = end
+	}
+	progs = progs[:next]
+
+	res := &ReceiverRecovery{Receivers: next}
+	if len(progs) == 0 {
+		return res, nil
+	}
+
+	// Receivers found by an earlier recovery, or made by
+	// CreateReceiver since, are already watched.
+	watched, err := m.watchedPrograms(ctx, progs)
+	if err != nil {
+		return nil, errors.Wrap(err, "finding watched receivers")
+	}
+	var unwatched []*controlProgram
+	for _, cp := range progs {
+		if !watched[string(cp.controlProgram)] {
+			unwatched = append(unwatched, cp)
+		}
+	}
+	if len(unwatched) > 0 {
+		err = m.insertAccountControlProgram(ctx, unwatched...)
+		if err != nil {
+			return nil, errors.Wrap(err, "inserting recovered receivers")
+		}
+	}
+
+	const q = `
+		UPDATE accounts SET next_receiver_index = GREATEST(next_receiver_index, $2)
+		WHERE account_id = $1
+	`
+	_, err = m.db.Exec(ctx, q, account.ID, next)
+	if err != nil {
+		return nil, errors.Wrap(err, "updating receiver index")
+	}
+
+	res.UnspentOutputs, err = m.restoreUnspentOutputs(ctx, account.ID, progs)
+	if err != nil {
+		return nil, errors.Wrap(err, "restoring unspent outputs")
+	}
+	return res, nil
+}
+
+// usedPrograms returns the set of the given control
+// programs that control any output on the blockchain.
+func (m *Manager) usedPrograms(ctx context.Context, progs []*controlProgram) (map[string]bool, error) {
+	var programs pq.ByteaArray
+	for _, cp := range progs {
+		programs = append(programs, cp.controlProgram)
+	}
+
+	const q = `
+		SELECT DISTINCT control_program FROM annotated_outputs
+		WHERE control_program IN (SELECT unnest($1::bytea[]))
+	`
+	used := make(map[string]bool)
+	err := pg.ForQueryRows(ctx, m.db, q, programs, func(program []byte) {
+		used[string(program)] = true
+	})
+	return used, err
+}
+
+// watchedPrograms returns the set of the given control
+// programs that are already in account_control_programs.
+func (m *Manager) watchedPrograms(ctx context.Context, progs []*controlProgram) (map[string]bool, error) {
+	var programs pq.ByteaArray
+	for _, cp := range progs {
+		programs = append(programs, cp.controlProgram)
+	}
+
+	const q = `
+		SELECT control_program FROM account_control_programs
+		WHERE control_program IN (SELECT unnest($1::bytea[]))
+	`
+	watched := make(map[string]bool)
+	err := pg.ForQueryRows(ctx, m.db, q, programs, func(program []byte) {
+		watched[string(program)] = true
+	})
+	return watched, err
+}
+
+// restoreUnspentOutputs indexes the unspent outputs controlled by
+// progs, which were in blocks the account indexer processed before
+// progs were watched, and annotates them with the account for
+// queries. It returns the number of outputs.
+func (m *Manager) restoreUnspentOutputs(ctx context.Context, accountID string, progs []*controlProgram) (int, error) {
+	var programs pq.ByteaArray
+	for _, cp := range progs {
+		programs = append(programs, cp.controlProgram)
+	}
+
+	const q = `
+		SELECT block_height, tx_pos, output_index FROM annotated_outputs
+		WHERE control_program IN (SELECT unnest($1::bytea[])) AND upper_inf(timespan)
+		ORDER BY block_height
+	`
+	type outputPos struct {
+		height      uint64
+		txPos       uint32
+		outputIndex uint32
+	}
+	var positions []outputPos
+	err := pg.ForQueryRows(ctx, m.db, q, programs, func(height uint64, txPos, outputIndex uint32) {
+		positions = append(positions, outputPos{height, txPos, outputIndex})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for len(positions) > 0 {
+		height := positions[0].height
+		b, err := m.chain.GetBlock(ctx, height)
+		if err != nil {
+			return n, errors.Wrapf(err, "getting block %d", height)
+		}
+		var outs []*rawOutput
+		for len(positions) > 0 && positions[0].height == height {
+			p := positions[0]
+			positions = positions[1:]
+			if int(p.txPos) >= len(b.Transactions) || int(p.outputIndex) >= len(b.Transactions[p.txPos].Outputs) {
+				return n, errors.Wrapf(pg.ErrUserInputNotFound, "output %d/%d/%d", height, p.txPos, p.outputIndex)
+			}
+			outs = append(outs, blockOutput(b.Transactions[p.txPos], p.outputIndex))
+		}
+		accOuts, err := m.loadAccountInfo(ctx, outs)
+		if err != nil {
+			return n, errors.Wrap(err, "loading account info from control programs")
+		}
+		err = m.upsertConfirmedAccountOutputs(ctx, accOuts, nil, b)
+		if err != nil {
+			return n, errors.Wrap(err, "upserting confirmed account utxos")
+		}
+		n += len(accOuts)
+	}
+
+	const annotateQ = `
+		UPDATE annotated_outputs o
+		SET account_id = a.account_id, account_alias = a.alias, account_tags = COALESCE(a.tags, '{}'::jsonb)
+		FROM accounts a
+		WHERE a.account_id = $1 AND o.account_id IS NULL
+			AND o.control_program IN (SELECT unnest($2::bytea[]))
+	`
+	_, err = m.db.Exec(ctx, annotateQ, accountID, programs)
+	if err != nil {
+		return n, errors.Wrap(err, "annotating outputs")
+	}
+	return n, nil
+}
@@ -1,10 +1,13 @@
 package account
 
 import (
+	"bytes"
 	"context"
+	"reflect"
 	"testing"
 	"time"
 
+	"chain/core/signers"
 	"chain/crypto/ed25519/chainkd"
 	"chain/database/pg/pgtest"
 	"chain/protocol/prottest"
@@ -33,3 +36,118 @@ func TestCreateReceiver(t *testing.T) {
 		testutil.FatalErr(t, err)
 	}
 }
+
+func TestReceiverProgram(t *testing.T) {
+	// Receivers of accounts with the same keys are the
+	// same, whatever the accounts' key indexes.
+	a := &signers.Signer{XPubs: []chainkd.XPub{testutil.TestXPub}, Quorum: 1, KeyIndex: 1}
+	b := &signers.Signer{XPubs: []chainkd.XPub{testutil.TestXPub}, Quorum: 1, KeyIndex: 2}
+	cpA, err := receiverProgram(a, 5, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cpB, err := receiverProgram(b, 5, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(cpA.controlProgram, cpB.controlProgram) {
+		t.Errorf("receiver 5 programs differ: %x and %x", cpA.controlProgram, cpB.controlProgram)
+	}
+	cp6, err := receiverProgram(a, 6, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(cpA.controlProgram, cp6.controlProgram) {
+		t.Error("receivers 5 and 6 have the same program")
+	}
+
+	// Spending a receiver's output derives its keys by
+	// receiver path, and other programs' by account path.
+	if cpA.keyIndex != 5|receiverIndexBit {
+		t.Errorf("key index = %x want %x", cpA.keyIndex, 5|receiverIndexBit)
+	}
+	want, _ := chainkd.ReceiverPath(5).Selectors()
+	if got := programPath(a, cpA.keyIndex); !reflect.DeepEqual(got, want) {
+		t.Errorf("programPath(receiver 5) = %x want %x", got, want)
+	}
+	want = signers.Path(a, signers.AccountKeySpace, 5)
+	if got := programPath(a, 5); !reflect.DeepEqual(got, want) {
+		t.Errorf("programPath(5) = %x want %x", got, want)
+	}
+}
+
+func TestRecoverReceivers(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	m := NewManager(db, prottest.NewChain(t), nil)
+	ctx := context.Background()
+
+	account, err := m.Create(ctx, []chainkd.XPub{testutil.TestXPub}, 1, "alias", nil, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	var progs [][]byte
+	for i := 0; i < 3; i++ {
+		r, err := m.CreateReceiver(ctx, account.ID, "", time.Time{})
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		progs = append(progs, r.ControlProgram)
+	}
+
+	// Only the last receiver has been paid, and the
+	// output spent. Then the Core loses its receivers.
+	const insertQ = `
+		INSERT INTO annotated_outputs (block_height, tx_pos, output_index, tx_hash,
+			timespan, output_id, type, purpose, asset_id, asset_alias, asset_definition,
+			asset_tags, asset_local, amount, control_program, reference_data, local)
+		VALUES (1, 0, 0, '', int8range(1, 2), '', 'control', 'receive', '', '', '{}',
+			'{}', true, 1, $1, '{}', true)
+	`
+	_, err = db.Exec(ctx, insertQ, progs[2])
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	_, err = db.Exec(ctx, `DELETE FROM account_control_programs`)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	_, err = db.Exec(ctx, `UPDATE accounts SET next_receiver_index = 0`)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	res, err := m.RecoverReceivers(ctx, account.ID, "", 2, time.Time{})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if res.Receivers != 0 {
+		t.Errorf("with gap limit 2, recovered %d receivers want 0", res.Receivers)
+	}
+
+	res, err = m.RecoverReceivers(ctx, "", "alias", 3, time.Time{})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if res.Receivers != 3 || res.UnspentOutputs != 0 {
+		t.Errorf("with gap limit 3, recovered %+v want 3 receivers, 0 outputs", res)
+	}
+	var n int
+	err = db.QueryRow(ctx, `SELECT count(*) FROM account_control_programs WHERE control_program IN ($1, $2, $3)`, progs[0], progs[1], progs[2]).Scan(&n)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if n != 3 {
+		t.Errorf("watching %d recovered programs want 3", n)
+	}
+
+	// The next receiver is a new one.
+	r, err := m.CreateReceiver(ctx, account.ID, "", time.Time{})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	for i, p := range progs {
+		if bytes.Equal(r.ControlProgram, p) {
+			t.Errorf("new receiver reuses receiver %d", i)
+		}
+	}
+}
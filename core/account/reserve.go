@@ -3,7 +3,7 @@ package account
 import (
 	"context"
 	"database/sql"
-	"fmt"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -102,6 +102,9 @@ type reserver struct {
 
 // Reserve selects and reserves UTXOs according to the criteria provided
 // in source. The resulting reservation expires at exp.
+//
+// A retry with the same client token returns the earlier
+// reservation, its expiry extended to exp if that is later.
 func (re *reserver) Reserve(ctx context.Context, src source, amount uint64, clientToken *string, exp time.Time) (*reservation, error) {
 	if clientToken == nil {
 		return re.reserve(ctx, src, amount, clientToken, exp)
@@ -110,7 +113,10 @@ func (re *reserver) Reserve(ctx context.Context, src source, amount uint64, clie
 	untypedRes, err := re.idempotency.Once(*clientToken, func() (interface{}, error) {
 		return re.reserve(ctx, src, amount, clientToken, exp)
 	})
-	return untypedRes.(*reservation), err
+	if err != nil {
+		return nil, err
+	}
+	return re.extend(untypedRes.(*reservation), exp), nil
 }
 
 func (re *reserver) reserve(ctx context.Context, src source, amount uint64, clientToken *string, exp time.Time) (res *reservation, err error) {
@@ -153,7 +159,27 @@ func (re *reserver) ReserveUTXO(ctx context.Context, out bc.Hash, clientToken *s
 	untypedRes, err := re.idempotency.Once(*clientToken, func() (interface{}, error) {
 		return re.reserveUTXO(ctx, out, exp, clientToken)
 	})
-	return untypedRes.(*reservation), err
+	if err != nil {
+		return nil, err
+	}
+	return re.extend(untypedRes.(*reservation), exp), nil
+}
+
+// extend returns res with its expiry extended to exp, if that
+// is later. Since reservations are immutable, it replaces the
+// stored reservation with an extended copy. If res has already
+// expired or been canceled, extend returns it unchanged.
+func (re *reserver) extend(res *reservation, exp time.Time) *reservation {
+	re.reservationsMu.Lock()
+	defer re.reservationsMu.Unlock()
+	cur, ok := re.reservations[res.ID]
+	if !ok || !exp.After(cur.Expiry) {
+		return res
+	}
+	ext := *cur
+	ext.Expiry = exp
+	re.reservations[res.ID] = &ext
+	return &ext
 }
 
 func (re *reserver) reserveUTXO(ctx context.Context, out bc.Hash, exp time.Time, clientToken *string) (*reservation, error) {
@@ -192,7 +218,7 @@ func (re *reserver) Cancel(ctx context.Context, rid uint64) error {
 	delete(re.reservations, rid)
 	re.reservationsMu.Unlock()
 	if !ok {
-		return fmt.Errorf("couldn't find reservation %d", rid)
+		return errors.WithDetailf(pg.ErrUserInputNotFound, "reservation %d", rid)
 	}
 	re.source(res.Source).cancel(res)
 	if res.ClientToken != nil {
@@ -231,6 +257,25 @@ func (re *reserver) ExpireReservations(ctx context.Context) error {
 	return nil
 }
 
+// List returns the current reservations, in
+// the order they were made.
+func (re *reserver) List() []*reservation {
+	re.reservationsMu.Lock()
+	list := make([]*reservation, 0, len(re.reservations))
+	for _, res := range re.reservations {
+		list = append(list, res)
+	}
+	re.reservationsMu.Unlock()
+	sort.Sort(byID(list))
+	return list
+}
+
+type byID []*reservation
+
+func (a byID) Len() int           { return len(a) }
+func (a byID) Less(i, j int) bool { return a[i].ID < a[j].ID }
+func (a byID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+
 func (re *reserver) checkUTXO(u *utxo) bool {
 	_, s := re.c.State()
 	return s.Tree.Contains(u.OutputID.Bytes())
@@ -438,3 +483,56 @@ func findSpecificUTXO(ctx context.Context, db pg.DB, out bc.Hash) (*utxo, error)
 	u.OutputID = out
 	return u, nil
 }
+
+// Reservation describes a reservation of an account's unspent
+// outputs for a transaction being built.
+type Reservation struct {
+	ID        uint64     `json:"id"`
+	AccountID string     `json:"account_id"`
+	AssetID   bc.AssetID `json:"asset_id"`
+
+	// Amount is the total amount of the outputs
+	// reserved, including the change.
+	Amount    uint64    `json:"amount"`
+	Change    uint64    `json:"change"`
+	OutputIDs []bc.Hash `json:"output_ids"`
+	ExpiresAt time.Time `json:"expires_at"`
+
+	ClientToken *string `json:"client_token,omitempty"`
+}
+
+// Reservations returns the reservations of the given account's
+// outputs, or of all accounts' outputs if accountID is empty.
+// Reservations are held in memory by the leader process, so
+// only the leader can list them.
+func (m *Manager) Reservations(accountID string) []*Reservation {
+	var list []*Reservation
+	for _, res := range m.utxoDB.List() {
+		if accountID != "" && res.Source.AccountID != accountID {
+			continue
+		}
+		r := &Reservation{
+			ID:          res.ID,
+			AccountID:   res.Source.AccountID,
+			AssetID:     res.Source.AssetID,
+			Change:      res.Change,
+			OutputIDs:   make([]bc.Hash, 0, len(res.UTXOs)),
+			ExpiresAt:   res.Expiry,
+			ClientToken: res.ClientToken,
+		}
+		for _, u := range res.UTXOs {
+			r.Amount += u.Amount
+			r.OutputIDs = append(r.OutputIDs, u.OutputID)
+		}
+		list = append(list, r)
+	}
+	return list
+}
+
+// CancelReservation cancels the reservation with the given ID,
+// making its outputs available for reservation again. A build
+// that used the reservation can still be signed and submitted,
+// but may then conflict with another using the same outputs.
+func (m *Manager) CancelReservation(ctx context.Context, id uint64) error {
+	return m.utxoDB.Cancel(ctx, id)
+}
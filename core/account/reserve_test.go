@@ -2,6 +2,7 @@ package account
 
 import (
 	"context"
+	"reflect"
 	"testing"
 	"time"
 
@@ -63,3 +64,70 @@ func TestCancelReservation(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestExtendReservation(t *testing.T) {
+	ctx := context.Background()
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	_, err := db.Exec(ctx, sampleAccountUTXOs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var outid bc.Hash
+	err = outid.UnmarshalText([]byte("9886ae2dc24b6d868c68768038c43801e905a62f1a9b826ca0dc357f00c30117"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := prottest.NewChainWithStorage(t, memstore.New(), outid)
+
+	utxoDB := newReserver(db, c, nil)
+	token := "build-1"
+	exp := time.Now().Add(time.Minute)
+	res, err := utxoDB.ReserveUTXO(ctx, outid, &token, exp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A retry with a later expiry extends the reservation.
+	later := exp.Add(time.Minute)
+	res2, err := utxoDB.ReserveUTXO(ctx, outid, &token, later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res2.ID != res.ID || !res2.Expiry.Equal(later) {
+		t.Errorf("retry got reservation %d expiring %s, want %d expiring %s", res2.ID, res2.Expiry, res.ID, later)
+	}
+
+	// A retry with an earlier one leaves it.
+	res3, err := utxoDB.ReserveUTXO(ctx, outid, &token, exp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res3.Expiry.Equal(later) {
+		t.Errorf("retry got expiry %s, want %s", res3.Expiry, later)
+	}
+
+	list := utxoDB.List()
+	if len(list) != 1 || list[0].ID != res.ID || !list[0].Expiry.Equal(later) {
+		t.Errorf("List() = %+v, want reservation %d expiring %s", list, res.ID, later)
+	}
+}
+
+func TestSplitChange(t *testing.T) {
+	cases := []struct {
+		change, amount uint64
+		want           []uint64
+	}{
+		{1, 1, []uint64{1}},
+		{5, 3, []uint64{5}},
+		{90, 10, []uint64{22, 22, 22, 24}},
+		{30, 10, []uint64{10, 10, 10}},
+		{7, 0, []uint64{7}},
+	}
+	for _, c := range cases {
+		got := splitChange(c.change, c.amount)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("splitChange(%d, %d) = %v want %v", c.change, c.amount, got, c.want)
+		}
+	}
+}
@@ -5,7 +5,11 @@ import (
 	"sync"
 
 	"chain/core/account"
+	"chain/core/leader"
+	"chain/core/query"
 	"chain/crypto/ed25519/chainkd"
+	"chain/errors"
+	"chain/net/http/httpjson"
 	"chain/net/http/reqid"
 )
 
@@ -49,3 +53,84 @@ func (a *API) createAccount(ctx context.Context, ins []struct {
 	wg.Wait()
 	return responses
 }
+
+// updateAccountAlias changes the alias of an account,
+// identified by its ID or current alias.
+//
+// POST /update-account-alias
+func (a *API) updateAccountAlias(ctx context.Context, in struct {
+	ID       string `json:"id"`
+	Alias    string `json:"alias"`
+	NewAlias string `json:"new_alias"`
+}) (*query.AnnotatedAccount, error) {
+	if in.ID == "" && in.Alias == "" {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "id or alias is required")
+	}
+	acc, err := a.Accounts.UpdateAlias(ctx, in.ID, in.Alias, in.NewAlias)
+	if err != nil {
+		return nil, err
+	}
+	return account.Annotated(acc)
+}
+
+// deleteAccount deletes an account, identified by its ID or
+// alias. The account can no longer spend, but it and its
+// history remain in the query indexes. Reservations live in
+// the leader process, so other processes forward the request
+// to it.
+//
+// POST /delete-account
+func (a *API) deleteAccount(ctx context.Context, in struct {
+	ID    string `json:"id"`
+	Alias string `json:"alias"`
+}) error {
+	if !leader.IsLeading() {
+		return a.forwardToLeader(ctx, "/delete-account", in, nil)
+	}
+	if in.ID == "" && in.Alias == "" {
+		return errors.WithDetail(httpjson.ErrBadRequest, "id or alias is required")
+	}
+	return a.Accounts.Delete(ctx, in.ID, in.Alias)
+}
+
+// listAccountEvents is an http handler for listing the lifecycle
+// events (transactions built, submitted and confirmed, and outputs
+// spent) of a single account, oldest first.
+//
+// POST /list-account-events
+func (a *API) listAccountEvents(ctx context.Context, in requestQuery) (page, error) {
+	limit, err := a.pageSize(in.PageSize)
+	if err != nil {
+		return page{}, err
+	}
+
+	_, _, err = a.pinnedHeight(ctx)
+	if err != nil {
+		return page{}, err
+	}
+
+	accountID := in.AccountID
+	if accountID == "" {
+		if in.AccountAlias == "" {
+			return page{}, errors.WithDetail(httpjson.ErrBadRequest, "account_id or account_alias is required")
+		}
+		acc, err := a.Accounts.FindByAlias(ctx, in.AccountAlias)
+		if err != nil {
+			return page{}, err
+		}
+		accountID = acc.ID
+	}
+
+	events, after, err := a.Accounts.Events(ctx, accountID, in.After, limit)
+	if err != nil {
+		return page{}, err
+	}
+
+	out := in
+	out.After = after
+	return page{
+		Items:    httpjson.Array(events),
+		LastPage: len(events) < limit,
+		Next:     out,
+	}, nil
+}
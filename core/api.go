@@ -13,19 +13,25 @@ import (
 	"chain/core/accesstoken"
 	"chain/core/account"
 	"chain/core/asset"
+	"chain/core/blocksigner"
 	"chain/core/config"
+	"chain/core/generator"
 	"chain/core/leader"
 	"chain/core/pin"
 	"chain/core/query"
+	"chain/core/query/graphql"
 	"chain/core/rpc"
 	"chain/core/txbuilder"
 	"chain/core/txdb"
 	"chain/core/txfeed"
+	"chain/core/webhook"
+	"chain/crypto/ed25519/threshold"
 	"chain/database/pg"
 	"chain/encoding/json"
 	"chain/errors"
 	"chain/generated/dashboard"
 	"chain/generated/docs"
+	"chain/net/http/authz"
 	"chain/net/http/gzip"
 	"chain/net/http/httpjson"
 	"chain/net/http/limit"
@@ -37,6 +43,7 @@ import (
 
 const (
 	defGenericPageSize = 100
+	defMaxPageSize     = 1000
 )
 
 // TODO(kr): change this to "network" or something.
@@ -45,46 +52,174 @@ const networkRPCPrefix = "/rpc/"
 var (
 	errNotFound       = errors.New("not found")
 	errRateLimited    = errors.New("request limit exceeded")
+	errServerBusy     = errors.New("too many concurrent requests")
+	errBodyTooLarge   = errors.New("request body too large")
 	errLeaderElection = errors.New("no leader; pending election")
 )
 
 // Handler serves the Chain HTTP API
 type API struct {
-	Chain         *protocol.Chain
-	Store         *txdb.Store
-	PinStore      *pin.Store
-	Assets        *asset.Registry
-	Accounts      *account.Manager
-	Indexer       *query.Indexer
-	TxFeeds       *txfeed.Tracker
-	AccessTokens  *accesstoken.CredentialStore
-	Config        *config.Config
-	Submitter     txbuilder.Submitter
-	DB            pg.DB
-	Addr          string
-	AltAuth       func(*http.Request) bool
-	Signer        func(context.Context, *bc.Block) ([]byte, error)
+	Chain        *protocol.Chain
+	Store        *txdb.Store
+	PinStore     *pin.Store
+	Assets       *asset.Registry
+	Accounts     *account.Manager
+	Indexer      *query.Indexer
+	GraphQL      *graphql.Schema // optional
+	TxFeeds      *txfeed.Tracker
+	Webhooks     *webhook.Manager
+	AccessTokens *accesstoken.CredentialStore
+	Config       *config.Config
+	Settings     []config.Setting // effective values of overridable settings
+	Submitter    txbuilder.Submitter
+	Peers        []*rpc.Client // checked by /check-network-build
+	DB           pg.DB
+	Addr         string
+	AltAuth      func(*http.Request) bool
+	Signer       func(context.Context, *bc.Block) ([]byte, error)
+
+	// CommitBlock and SignBlockShare are the two rounds of
+	// threshold block signing. They are set only on a block
+	// signer that holds a share of a threshold key.
+	CommitBlock    func(context.Context, *bc.Block) (threshold.Commitment, error)
+	SignBlockShare func(context.Context, *blocksigner.ShareRequest) (threshold.Scalar, error)
+
 	RequestLimits []RequestLimit
 
+	// MaxConcurrentRequests limits the requests served at
+	// once, other than those that wait for new blocks.
+	// Requests beyond it get a 503 response. If 0, there
+	// is no limit.
+	MaxConcurrentRequests int
+
+	// Authenticator checks access tokens.
+	// If nil, AccessTokens checks them.
+	Authenticator accesstoken.Authenticator
+
+	// MembershipSigner signs network membership documents.
+	// It is set only on a generator that is a block signer.
+	MembershipSigner config.MessageSigner
+
+	// PublicURL is the URL at which other cores reach this
+	// generator. Membership documents name it as the
+	// generator URL; if it is empty, none are issued.
+	PublicURL string
+
+	// SignatureStatus reports the progress of collecting
+	// block signatures. It is set only on a generator.
+	SignatureStatus func() *generator.SignatureStatus
+
+	// MaxPageSize is the largest page_size a list request
+	// may ask for. If 0, defMaxPageSize is used.
+	MaxPageSize int
+
+	// ExportStore holds transaction exports written to
+	// a store rather than the response. It is optional.
+	ExportStore ExportStore
+
+	// GRPC enables the gRPC API, served on
+	// the same listener as the JSON API.
+	GRPC bool
+
+	// Grants authorize requests by policy, on top of the
+	// scopes of access tokens. If nil, any valid token
+	// may use the routes its scopes allow.
+	Grants *authz.Store
+
 	healthMu     sync.Mutex
 	healthErrors map[string]interface{}
 }
 
+// pageSize returns the number of items to return in a page
+// of a list request that asked for n items, and an error
+// if n is negative or greater than max.
+// If n is 0, it returns defGenericPageSize.
+func pageSize(n, max int) (int, error) {
+	if n < 0 {
+		return 0, errors.WithDetail(httpjson.ErrBadRequest, "page_size must not be negative")
+	}
+	if n > max {
+		return 0, errors.WithDetailf(httpjson.ErrBadRequest, "page_size must not be greater than %d", max)
+	}
+	if n == 0 {
+		n = defGenericPageSize
+	}
+	return n, nil
+}
+
+// pageSize is like the pageSize function,
+// with a.MaxPageSize as the maximum.
+func (a *API) pageSize(n int) (int, error) {
+	max := a.MaxPageSize
+	if max == 0 {
+		max = defMaxPageSize
+	}
+	return pageSize(n, max)
+}
+
 type RequestLimit struct {
 	Key       func(*http.Request) string
 	Burst     int
 	PerSecond int
 }
 
-func maxBytes(h http.Handler) http.Handler {
-	const maxReqSize = 1e7 // 10MB
+// maxReqSize limits the size of request bodies,
+// except on the routes in routeReqSizes.
+const maxReqSize = 1e7 // 10MB
+
+// maxTxReqSize limits the size of transactions
+// submitted to the generator by other cores.
+const maxTxReqSize = 1e6 // 1MB
+
+// routeReqSizes limits the request bodies of some routes
+// differently, where 0 means no limit. A block can easily
+// be bigger than maxReqSize, but everything else should be
+// pretty small.
+var routeReqSizes = map[string]int64{
+	networkRPCPrefix + "signer/sign-block":       0,
+	networkRPCPrefix + "signer/threshold-commit": 0,
+	networkRPCPrefix + "signer/threshold-sign":   0,
+	networkRPCPrefix + "submit":                  maxTxReqSize,
+	networkRPCPrefix + "submit-idempotent":       maxTxReqSize,
+}
+
+// routeTimeouts bounds the time spent on routes that
+// should be quick, so that stuck requests can't hold
+// on to the core's resources. A client's shorter
+// timeout header still applies.
+var routeTimeouts = map[string]time.Duration{
+	networkRPCPrefix + "submit":            30 * time.Second,
+	networkRPCPrefix + "submit-idempotent": 30 * time.Second,
+	networkRPCPrefix + "block-height":      10 * time.Second,
+	networkRPCPrefix + "build-info":        10 * time.Second,
+	networkRPCPrefix + "get-snapshot-info": 10 * time.Second,
+	"/info":                                30 * time.Second,
+	"/build-transaction":                   time.Minute,
+	"/merge-transactions":                  time.Minute,
+	"/create-account-receiver":             30 * time.Second,
+}
+
+// longRunningRoutes may wait a long time for new blocks,
+// so they don't count toward MaxConcurrentRequests.
+var longRunningRoutes = map[string]bool{
+	networkRPCPrefix + "get-blocks":   true,
+	networkRPCPrefix + "get-block":    true,
+	networkRPCPrefix + "get-snapshot": true,
+	"/list-transactions":              true,
+	"/export-transactions":            true,
+	grpcPrefix + "StreamBlocks":       true,
+}
+
+// concurrencyLimit serves requests with next, at most max
+// at a time, other than those to longRunningRoutes.
+func concurrencyLimit(next http.Handler, max int) http.Handler {
+	limited := limit.Concurrency(next, alwaysError(errServerBusy), max, time.Second)
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		// A block can easily be bigger than maxReqSize, but everything
-		// else should be pretty small.
-		if req.URL.Path != networkRPCPrefix+"signer/sign-block" {
-			req.Body = http.MaxBytesReader(w, req.Body, maxReqSize)
+		if longRunningRoutes[req.URL.Path] {
+			next.ServeHTTP(w, req)
+			return
 		}
-		h.ServeHTTP(w, req)
+		limited.ServeHTTP(w, req)
 	})
 }
 
@@ -106,15 +241,30 @@ func Handler(a *API, register func(*http.ServeMux, *API)) http.Handler {
 		devOnly = func(h http.Handler) http.Handler { return alwaysError(errProduction) }
 	}
 
+	needGrants := jsonHandler
+	if a.Grants == nil {
+		needGrants = func(f interface{}) http.Handler { return alwaysError(errNoGrants) }
+	}
+
 	m := http.NewServeMux()
 	m.Handle("/", alwaysError(errNotFound))
 
 	m.Handle("/create-account", needConfig(a.createAccount))
+	m.Handle("/update-account-alias", needConfig(a.updateAccountAlias))
+	m.Handle("/delete-account", needConfig(a.deleteAccount))
 	m.Handle("/create-asset", needConfig(a.createAsset))
+	m.Handle("/create-asset-successor", needConfig(a.createAssetSuccessor))
+	m.Handle("/get-asset-lineage", needConfig(a.getAssetLineage))
+	m.Handle("/get-asset-definition-history", needConfig(a.getAssetDefinitionHistory))
+	m.Handle("/set-asset-issuance-policy", needConfig(a.setAssetIssuancePolicy))
+	m.Handle("/get-asset-issuance-policy", needConfig(a.getAssetIssuancePolicy))
 	m.Handle("/build-transaction", needConfig(a.build))
 	m.Handle("/submit-transaction", needConfig(a.submit))
+	m.Handle("/merge-transactions", needConfig(a.mergeTransactions))
+	m.Handle("/add-transaction-signatures", needConfig(a.addTransactionSignatures))
 	m.Handle("/create-control-program", needConfig(a.createControlProgram)) // DEPRECATED
 	m.Handle("/create-account-receiver", needConfig(a.createAccountReceiver))
+	m.Handle("/recover-account-receivers", needConfig(a.recoverAccountReceivers))
 	m.Handle("/create-transaction-feed", needConfig(a.createTxFeed))
 	m.Handle("/get-transaction-feed", needConfig(a.getTxFeed))
 	m.Handle("/update-transaction-feed", needConfig(a.updateTxFeed))
@@ -123,19 +273,36 @@ func Handler(a *API, register func(*http.ServeMux, *API)) http.Handler {
 	m.Handle("/list-accounts", needConfig(a.listAccounts))
 	m.Handle("/list-assets", needConfig(a.listAssets))
 	m.Handle("/list-transaction-feeds", needConfig(a.listTxFeeds))
+	m.Handle("/create-webhook", needConfig(a.createWebhook))
+	m.Handle("/get-webhook", needConfig(a.getWebhook))
+	m.Handle("/delete-webhook", needConfig(a.deleteWebhook))
+	m.Handle("/rotate-webhook-secret", needConfig(a.rotateWebhookSecret))
+	m.Handle("/list-webhooks", needConfig(a.listWebhooks))
+	m.Handle("/list-webhook-dead-letters", needConfig(a.listWebhookDeadLetters))
 	m.Handle("/list-transactions", needConfig(a.listTransactions))
 	m.Handle("/list-balances", needConfig(a.listBalances))
+	m.Handle("/aggregate-outputs", needConfig(a.aggregateOutputs))
 	m.Handle("/list-unspent-outputs", needConfig(a.listUnspentOutputs))
+	m.Handle("/list-account-events", needConfig(a.listAccountEvents))
+	m.Handle("/list-reservations", needConfig(a.listReservations))
+	m.Handle("/cancel-reservation", needConfig(a.cancelReservation))
 	m.Handle("/reset", devOnly(needConfig(a.reset)))
+	if a.GraphQL != nil {
+		m.Handle("/graphql", needConfig(a.graphQL))
+	}
 
 	m.Handle(networkRPCPrefix+"submit", needConfig(func(ctx context.Context, tx *bc.Tx) error {
-		return a.Submitter.Submit(ctx, tx)
+		return a.Submitter.Submit(txbuilder.WithSubmitter(ctx, accessTokenID(ctx)), tx)
 	}))
+	m.Handle(networkRPCPrefix+"submit-idempotent", needConfig(a.submitIdempotentRPC))
 	m.Handle(networkRPCPrefix+"get-blocks", needConfig(a.getBlocksRPC)) // DEPRECATED: use get-block instead
 	m.Handle(networkRPCPrefix+"get-block", needConfig(a.getBlockRPC))
 	m.Handle(networkRPCPrefix+"get-snapshot-info", needConfig(a.getSnapshotInfoRPC))
 	m.Handle(networkRPCPrefix+"get-snapshot", http.HandlerFunc(a.getSnapshotRPC))
 	m.Handle(networkRPCPrefix+"signer/sign-block", needConfig(a.leaderSignHandler(a.Signer)))
+	m.Handle(networkRPCPrefix+"signer/threshold-commit", needConfig(a.leaderCommitHandler(a.CommitBlock)))
+	m.Handle(networkRPCPrefix+"signer/threshold-sign", needConfig(a.leaderSignShareHandler(a.SignBlockShare)))
+	m.Handle(networkRPCPrefix+"build-info", jsonHandler(a.getBuildInfoRPC))
 	m.Handle(networkRPCPrefix+"block-height", needConfig(func(ctx context.Context) map[string]uint64 {
 		h := a.Chain.Height()
 		return map[string]uint64{
@@ -146,8 +313,27 @@ func Handler(a *API, register func(*http.ServeMux, *API)) http.Handler {
 	m.Handle("/create-access-token", jsonHandler(a.createAccessToken))
 	m.Handle("/list-access-tokens", jsonHandler(a.listAccessTokens))
 	m.Handle("/delete-access-token", jsonHandler(a.deleteAccessToken))
+	m.Handle("/create-authorization-grant", needGrants(a.createGrant))
+	m.Handle("/list-authorization-grants", needGrants(a.listGrants))
+	m.Handle("/delete-authorization-grant", needGrants(a.deleteGrant))
+	m.Handle("/list-audit-log", jsonHandler(a.listAuditLog))
 	m.Handle("/configure", jsonHandler(a.configure))
+	m.Handle("/update-configuration", needConfig(a.updateConfiguration))
+	m.Handle("/propose-signer-rotation", needConfig(a.proposeSignerRotation))
+	m.Handle("/cancel-signer-rotation", needConfig(a.cancelSignerRotation))
+	m.Handle("/list-signer-rotations", needConfig(a.listSignerRotations))
+	m.Handle("/get-network-membership", needConfig(a.getNetworkMembership))
+	m.Handle("/get-block-signature-status", needConfig(a.getBlockSignatureStatus))
+	m.Handle("/list-settings", needConfig(a.listSettings))
 	m.Handle("/info", jsonHandler(a.info))
+	m.Handle("/check-network-build", needConfig(a.checkNetworkBuild))
+	m.Handle("/storage-usage", needConfig(a.storageUsage))
+	m.Handle("/reindex", needConfig(a.reindex))
+	m.Handle("/get-reindex-status", needConfig(a.getReindexStatus))
+	m.Handle("/export-transactions", http.HandlerFunc(a.exportTransactions))
+	m.Handle("/list-slow-transactions", needConfig(a.listSlowTransactions))
+	m.Handle("/get-log-levels", jsonHandler(a.getLogLevels))
+	m.Handle("/set-log-level", jsonHandler(a.setLogLevel))
 
 	m.Handle("/debug/vars", expvar.Handler())
 	m.Handle("/debug/pprof/", http.HandlerFunc(pprof.Index))
@@ -166,20 +352,38 @@ func Handler(a *API, register func(*http.ServeMux, *API)) http.Handler {
 		m.ServeHTTP(w, req)
 	})
 
-	var handler = (&apiAuthn{
-		tokens:   a.AccessTokens,
-		tokenMap: make(map[string]tokenResult),
+	authn := &apiAuthn{
+		tokens:   a.Authenticator,
+		tokenMap: make(map[tokenKey]tokenResult),
+		failures: make(map[tokenKey]time.Time),
 		alt:      a.AltAuth,
-	}).handler(latencyHandler)
-	handler = maxBytes(handler)
+	}
+	if authn.tokens == nil {
+		authn.tokens = a.AccessTokens
+		authn.usage = a.AccessTokens
+	}
+	if a.Grants != nil {
+		authn.authorizer = authz.NewAuthorizer(a.Grants, routePolicies)
+	}
+	var apiHandler http.Handler = latencyHandler
+	if a.GRPC {
+		apiHandler = grpcHandler(a, apiHandler)
+	}
+	var handler = authn.handler(apiHandler)
+	handler = limit.Timeout(handler, routeTimeouts)
+	handler = limit.BodySize(handler, alwaysError(errBodyTooLarge), maxReqSize, routeReqSizes)
 	handler = webAssetsHandler(handler)
 	handler = healthHandler(handler)
 	for _, l := range a.RequestLimits {
 		handler = limit.Handler(handler, alwaysError(errRateLimited), l.PerSecond, l.Burst, l.Key)
 	}
+	if a.MaxConcurrentRequests > 0 {
+		handler = concurrencyLimit(handler, a.MaxConcurrentRequests)
+	}
 	handler = gzip.Handler{Handler: handler}
 	handler = coreCounter(handler)
 	handler = reqid.Handler(handler)
+	handler = blockHeightContextHandler(handler)
 	handler = timeoutContextHandler(handler)
 
 	return handler
@@ -192,6 +396,10 @@ type requestQuery struct {
 	SumBy        []string      `json:"sum_by,omitempty"`
 	PageSize     int           `json:"page_size"`
 
+	// GroupBy and Aggregates are used by /aggregate-outputs.
+	GroupBy    []string `json:"group_by,omitempty"`
+	Aggregates []string `json:"aggregates,omitempty"`
+
 	// AscLongPoll and Timeout are used by /list-transactions
 	// to facilitate notifications.
 	AscLongPoll bool          `json:"ascending_with_long_poll,omitempty"`
@@ -210,10 +418,22 @@ type requestQuery struct {
 	// TODO(bobg): Different request structs for endpoints with different needs
 	TimestampMS uint64 `json:"timestamp,omitempty"`
 
+	// BlockHeight is used, instead of TimestampMS, for
+	// point-in-time queries as of a given block.
+	BlockHeight uint64 `json:"block_height,omitempty"`
+
 	// This is used for filtering results from /list-access-tokens
 	// Value must be "client" or "network"
 	Type string `json:"type"`
 
+	// Prefix limits /list-access-tokens to tokens
+	// whose IDs begin with it.
+	Prefix string `json:"prefix,omitempty"`
+
+	// These are used to select the account for /list-account-events
+	AccountID    string `json:"account_id,omitempty"`
+	AccountAlias string `json:"account_alias,omitempty"`
+
 	// Aliases is used to filter results from /mockshm/list-keys
 	Aliases []string `json:"aliases,omitempty"`
 }
@@ -278,6 +498,34 @@ func (a *API) leaderSignHandler(f func(context.Context, *bc.Block) ([]byte, erro
 	}
 }
 
+func (a *API) leaderCommitHandler(f func(context.Context, *bc.Block) (threshold.Commitment, error)) func(context.Context, *bc.Block) (threshold.Commitment, error) {
+	return func(ctx context.Context, b *bc.Block) (threshold.Commitment, error) {
+		if f == nil {
+			return threshold.Commitment{}, errNotFound
+		}
+		if leader.IsLeading() {
+			return f(ctx, b)
+		}
+		var resp threshold.Commitment
+		err := a.forwardToLeader(ctx, "/rpc/signer/threshold-commit", b, &resp)
+		return resp, err
+	}
+}
+
+func (a *API) leaderSignShareHandler(f func(context.Context, *blocksigner.ShareRequest) (threshold.Scalar, error)) func(context.Context, *blocksigner.ShareRequest) (threshold.Scalar, error) {
+	return func(ctx context.Context, req *blocksigner.ShareRequest) (threshold.Scalar, error) {
+		if f == nil {
+			return threshold.Scalar{}, errNotFound
+		}
+		if leader.IsLeading() {
+			return f(ctx, req)
+		}
+		var resp threshold.Scalar
+		err := a.forwardToLeader(ctx, "/rpc/signer/threshold-sign", req, &resp)
+		return resp, err
+	}
+}
+
 // forwardToLeader forwards the current request to the core's leader
 // process. It propagates the same credentials used in the current
 // request. For that reason, it cannot be used outside of a request-
@@ -370,3 +370,46 @@ func toTxTemplate(ctx context.Context, inp map[string]interface{}) (*txbuilder.T
 	err = json.Unmarshal(jsonInp, tpl)
 	return tpl, err
 }
+
+func TestPageSize(t *testing.T) {
+	cases := []struct {
+		n, max  int
+		want    int
+		wantErr bool
+	}{
+		{n: 0, max: 10, want: defGenericPageSize},
+		{n: 5, max: 10, want: 5},
+		{n: 10, max: 10, want: 10},
+		{n: 11, max: 10, wantErr: true},
+		{n: -1, max: 10, wantErr: true},
+	}
+	for _, c := range cases {
+		got, err := pageSize(c.n, c.max)
+		if (err != nil) != c.wantErr {
+			t.Errorf("pageSize(%d, %d) err = %v, want error %v", c.n, c.max, err, c.wantErr)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("pageSize(%d, %d) = %d want %d", c.n, c.max, got, c.want)
+		}
+	}
+}
+
+func TestListSettings(t *testing.T) {
+	a := &API{Settings: []config.Setting{
+		{Name: "generator_url", Value: "https://gen.example", Source: config.SourceFlag},
+		{Name: "generator_access_token", Value: "gen:secret", Source: config.SourceEnv, Secret: true},
+		{Name: "block_hsm_access_token", Value: "secret", Source: config.SourceStored, Secret: true},
+		{Name: "unset_token", Value: "", Source: config.SourceStored, Secret: true},
+	}}
+	got := a.listSettings(context.Background())
+	want := []interface{}{"https://gen.example", "gen:********", "********", ""}
+	for i, s := range got {
+		if s.Value != want[i] {
+			t.Errorf("setting %s = %v want %v", s.Name, s.Value, want[i])
+		}
+	}
+	if a.Settings[1].Value != "gen:secret" {
+		t.Errorf("listSettings changed the stored setting to %v", a.Settings[1].Value)
+	}
+}
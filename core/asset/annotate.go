@@ -74,6 +74,16 @@ func (reg *Registry) AnnotateTxs(ctx context.Context, txs []*query.AnnotatedTx)
 		return errors.Wrap(err, "querying assets")
 	}
 
+	// Annotate with the most recent version of each definition.
+	updatedDefs, err := latestDefinitions(ctx, reg.db, pq.ByteaArray(assetIDs))
+	if err != nil {
+		return err
+	}
+	for assetID, defBlob := range updatedDefs {
+		jsonDef := json.RawMessage(defBlob)
+		defsByAssetID[assetID] = &jsonDef
+	}
+
 	empty := json.RawMessage(`{}`)
 	for _, tx := range txs {
 		for _, in := range tx.Inputs {
@@ -38,6 +38,7 @@ func NewRegistry(db pg.DB, chain *protocol.Chain, pinStore *pin.Store) *Registry
 		pinStore:         pinStore,
 		cache:            lru.New(maxAssetCache),
 		aliasCache:       lru.New(maxAssetCache),
+		rates:            make(map[bc.AssetID]*issueWindow),
 	}
 }
 
@@ -55,6 +56,9 @@ type Registry struct {
 	cacheMu    sync.Mutex
 	cache      *lru.Cache
 	aliasCache *lru.Cache
+
+	rateMu sync.Mutex
+	rates  map[bc.AssetID]*issueWindow // issuance rate limit windows
 }
 
 func (reg *Registry) IndexAssets(indexer Saver) {
@@ -72,6 +76,13 @@ type Asset struct {
 	rawDefinition    []byte
 	definition       map[string]interface{}
 	sortID           string
+
+	// DefinitionVersion is the number of confirmed updates to the
+	// asset's definition, and currentDefinition the most recent
+	// one. The asset's ID and issuances always use its original
+	// definition.
+	DefinitionVersion uint64
+	currentDefinition []byte
 }
 
 func (asset *Asset) Definition() (map[string]interface{}, error) {
@@ -275,10 +286,14 @@ func assetQuery(ctx context.Context, db pg.DB, pred string, args ...interface{})
 			assets.initial_block_hash, assets.sort_id,
 			signers.id, COALESCE(signers.type, ''), COALESCE(signers.xpubs, '{}'),
 			COALESCE(signers.quorum, 0), COALESCE(signers.key_index, 0),
-			asset_tags.tags
+			asset_tags.tags, COALESCE(v.version, 0), v.definition
 		FROM assets
 		LEFT JOIN signers ON signers.id=assets.signer_id
 		LEFT JOIN asset_tags ON asset_tags.asset_id=assets.id
+		LEFT JOIN LATERAL (
+			SELECT version, definition FROM asset_definition_versions
+			WHERE asset_id=assets.id ORDER BY version DESC LIMIT 1
+		) v ON true
 		WHERE %s
 		LIMIT 1
 	`
@@ -306,6 +321,8 @@ func assetQuery(ctx context.Context, db pg.DB, pred string, args ...interface{})
 		&quorum,
 		&keyIndex,
 		&tags,
+		&a.DefinitionVersion,
+		&a.currentDefinition,
 	)
 	if err == sql.ErrNoRows {
 		return nil, pg.ErrUserInputNotFound
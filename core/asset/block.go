@@ -30,7 +30,9 @@ type Saver interface {
 func Annotated(a *Asset) (*query.AnnotatedAsset, error) {
 	jsonTags := json.RawMessage(`{}`)
 	jsonDefinition := json.RawMessage(`{}`)
-	if len(a.RawDefinition()) > 0 {
+	if len(a.currentDefinition) > 0 {
+		jsonDefinition = json.RawMessage(a.currentDefinition)
+	} else if len(a.RawDefinition()) > 0 {
 		jsonDefinition = json.RawMessage(a.RawDefinition())
 	}
 	if a.Tags != nil {
@@ -42,10 +44,11 @@ func Annotated(a *Asset) (*query.AnnotatedAsset, error) {
 	}
 
 	aa := &query.AnnotatedAsset{
-		ID:              a.AssetID,
-		Definition:      &jsonDefinition,
-		Tags:            &jsonTags,
-		IssuanceProgram: chainjson.HexBytes(a.IssuanceProgram),
+		ID:                a.AssetID,
+		Definition:        &jsonDefinition,
+		DefinitionVersion: a.DefinitionVersion,
+		Tags:              &jsonTags,
+		IssuanceProgram:   chainjson.HexBytes(a.IssuanceProgram),
 	}
 	if a.Alias != nil {
 		aa.Alias = *a.Alias
@@ -99,8 +102,15 @@ func (reg *Registry) ProcessBlocks(ctx context.Context) {
 	reg.pinStore.ProcessBlocks(ctx, reg.chain, PinName, reg.indexAssets)
 }
 
-// indexAssets is run on every block and indexes all non-local assets.
+// indexAssets is run on every block and indexes all non-local assets,
+// along with any asset successions and definition updates attested
+// in the block.
 func (reg *Registry) indexAssets(ctx context.Context, b *bc.Block) error {
+	err := reg.indexSuccessions(ctx, b)
+	if err != nil {
+		return errors.Wrap(err, "indexing asset successions")
+	}
+
 	var (
 		assetIDs         pq.ByteaArray
 		definitions      pq.StringArray
@@ -151,19 +161,27 @@ func (reg *Registry) indexAssets(ctx context.Context, b *bc.Block) error {
 		SELECT id FROM assets WHERE first_block_height = $7
 	`
 	var newAssetIDs []bc.AssetID
-	err := pg.ForQueryRows(ctx, reg.db, q, assetIDs, vmVersions, issuancePrograms, definitions, b.Time(), reg.initialBlockHash, b.Height,
+	err = pg.ForQueryRows(ctx, reg.db, q, assetIDs, vmVersions, issuancePrograms, definitions, b.Time(), reg.initialBlockHash, b.Height,
 		func(assetID bc.AssetID) { newAssetIDs = append(newAssetIDs, assetID) })
 	if err != nil {
 		return errors.Wrap(err, "error indexing non-local assets")
 	}
 
+	// Definition updates are issuances, so the
+	// assets they update are in the table by now.
+	updatedAssetIDs, err := reg.indexDefinitionUpdates(ctx, b)
+	if err != nil {
+		return errors.Wrap(err, "indexing asset definition updates")
+	}
+
 	if reg.indexer == nil {
 		return nil
 	}
 
 	// newAssetIDs now contains only the asset IDs of new, non-local
-	// assets. We need to index them as annotated assets too.
-	for _, assetID := range newAssetIDs {
+	// assets. We need to index them as annotated assets too, along
+	// with the assets whose definitions were updated.
+	for _, assetID := range append(newAssetIDs, updatedAssetIDs...) {
 		// TODO(jackson): Batch the asset lookups.
 		a, err := reg.findByID(ctx, assetID)
 		if err != nil {
@@ -47,6 +47,13 @@ func (a *issueAction) Build(ctx context.Context, builder *txbuilder.TemplateBuil
 		return err
 	}
 
+	// Fail early if the issuance's policy forbids it. The
+	// issuance counts toward its rate limit when submitted.
+	err = a.assets.checkIssuance(ctx, a.AssetID, a.Amount, issuer(ctx), false)
+	if err != nil {
+		return err
+	}
+
 	var nonce [8]byte
 	_, err = rand.Read(nonce[:])
 	if err != nil {
@@ -0,0 +1,165 @@
+package asset
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/lib/pq"
+
+	"chain/core/txbuilder"
+	"chain/database/pg"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// ErrBadDefinitionUpdate is returned when an asset
+// definition update cannot be built.
+var ErrBadDefinitionUpdate = errors.New("invalid asset definition update")
+
+// definitionUpdate is the reference data of an issuance input
+// publishing a new version of the issued asset's definition.
+// As with succession links, only holders of the issuance keys
+// can issue, so the update is attested by the asset's keys.
+type definitionUpdate struct {
+	Definition *json.RawMessage `json:"updated_asset_definition"`
+}
+
+// parseDefinitionUpdate parses the updated asset definition, if
+// any, from the reference data of an issuance input, and returns
+// it in the canonical form produced by serializeAssetDef.
+func parseDefinitionUpdate(refdata []byte) ([]byte, bool) {
+	var u definitionUpdate
+	if len(refdata) == 0 || json.Unmarshal(refdata, &u) != nil || u.Definition == nil {
+		return nil, false
+	}
+	var def map[string]interface{}
+	if json.Unmarshal(*u.Definition, &def) != nil || def == nil {
+		return nil, false
+	}
+	raw, err := serializeAssetDef(def)
+	if err != nil {
+		return nil, false
+	}
+	return raw, true
+}
+
+// indexDefinitionUpdates records the asset definition updates
+// attested by issuances in b, numbering each asset's versions
+// in the order they were confirmed. The asset's original
+// definition is version 0. It returns the IDs of the assets
+// updated in b.
+func (reg *Registry) indexDefinitionUpdates(ctx context.Context, b *bc.Block) ([]bc.AssetID, error) {
+	// An update is identified by its asset and transaction,
+	// so reprocessing the block records it only once.
+	const insertQ = `
+		INSERT INTO asset_definition_versions (asset_id, version, definition, tx_hash, block_height)
+		SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3, $4
+		FROM asset_definition_versions WHERE asset_id = $1
+		HAVING NOT EXISTS (
+			SELECT 1 FROM asset_definition_versions WHERE asset_id = $1 AND tx_hash = $3
+		)
+	`
+	for _, tx := range b.Transactions {
+		for _, in := range tx.Inputs {
+			if !in.IsIssuance() {
+				continue
+			}
+			def, ok := parseDefinitionUpdate(in.ReferenceData)
+			if !ok {
+				continue
+			}
+			_, err := reg.db.Exec(ctx, insertQ, in.AssetID(), def, tx.ID, b.Height)
+			if err != nil {
+				return nil, errors.Wrap(err, "inserting asset definition version")
+			}
+		}
+	}
+
+	// For idempotency, as with new assets in indexAssets,
+	// return every asset updated at this height.
+	const q = `
+		SELECT DISTINCT asset_id FROM asset_definition_versions WHERE block_height = $1
+	`
+	var updated []bc.AssetID
+	err := pg.ForQueryRows(ctx, reg.db, q, b.Height, func(id bc.AssetID) {
+		updated = append(updated, id)
+	})
+	return updated, errors.Wrap(err, "querying updated assets")
+}
+
+// latestDefinitions returns the most recent updated
+// definitions of the given assets. Assets whose
+// definitions have not been updated are omitted.
+func latestDefinitions(ctx context.Context, db pg.DB, assetIDs pq.ByteaArray) (map[bc.AssetID][]byte, error) {
+	const q = `
+		SELECT DISTINCT ON (asset_id) asset_id, definition FROM asset_definition_versions
+		WHERE asset_id IN (SELECT unnest($1::bytea[]))
+		ORDER BY asset_id, version DESC
+	`
+	defs := make(map[bc.AssetID][]byte)
+	err := pg.ForQueryRows(ctx, db, q, assetIDs, func(id bc.AssetID, def []byte) {
+		defs[id] = def
+	})
+	return defs, errors.Wrap(err, "querying latest asset definitions")
+}
+
+func (reg *Registry) NewUpdateDefinitionAction(assetID bc.AssetID, definition map[string]interface{}) txbuilder.Action {
+	return &updateDefinitionAction{
+		assets:     reg,
+		AssetID:    assetID,
+		Definition: definition,
+	}
+}
+
+func (reg *Registry) DecodeUpdateDefinitionAction(data []byte) (txbuilder.Action, error) {
+	a := &updateDefinitionAction{assets: reg}
+	err := json.Unmarshal(data, a)
+	return a, err
+}
+
+// updateDefinitionAction issues one unit of an asset, with
+// reference data holding a new version of its definition. The
+// asset keeps its ID, which is derived from its original
+// definition. The unit must be retired or otherwise spent by
+// another action in the same transaction.
+type updateDefinitionAction struct {
+	assets     *Registry
+	AssetID    bc.AssetID             `json:"asset_id"`
+	Definition map[string]interface{} `json:"definition"`
+}
+
+func (a *updateDefinitionAction) Build(ctx context.Context, builder *txbuilder.TemplateBuilder) error {
+	var missing []string
+	if a.AssetID == (bc.AssetID{}) {
+		missing = append(missing, "asset_id")
+	}
+	if a.Definition == nil {
+		missing = append(missing, "definition")
+	}
+	if len(missing) > 0 {
+		return txbuilder.MissingFieldsError(missing...)
+	}
+
+	asset, err := a.assets.findByID(ctx, a.AssetID)
+	if errors.Root(err) == pg.ErrUserInputNotFound {
+		err = errors.WithDetailf(err, "missing asset with ID %q", a.AssetID)
+	}
+	if err != nil {
+		return err
+	}
+	if asset.Signer == nil {
+		return errors.WithDetail(ErrBadDefinitionUpdate, "asset keys are not held by this core")
+	}
+
+	refdata, err := json.Marshal(map[string]interface{}{"updated_asset_definition": a.Definition})
+	if err != nil {
+		return errors.Wrap(err, "marshaling asset definition update")
+	}
+	issue := &issueAction{
+		assets:        a.assets,
+		AssetAmount:   bc.AssetAmount{AssetID: a.AssetID, Amount: 1},
+		ReferenceData: chainjson.Map(refdata),
+	}
+	return issue.Build(ctx, builder)
+}
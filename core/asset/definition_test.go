@@ -0,0 +1,92 @@
+package asset
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg/pgtest"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestParseDefinitionUpdate(t *testing.T) {
+	cases := []struct {
+		refdata string
+		want    string
+		wantOK  bool
+	}{
+		{``, ``, false},
+		{`{}`, ``, false},
+		{`not json`, ``, false},
+		{`{"updated_asset_definition": "USD"}`, ``, false},
+		{`{"updated_asset_definition": null}`, ``, false},
+		{`{"updated_asset_definition": {"b": 1, "a": 2}}`, "{\n  \"a\": 2,\n  \"b\": 1\n}", true},
+	}
+	for _, c := range cases {
+		got, ok := parseDefinitionUpdate([]byte(c.refdata))
+		if string(got) != c.want || ok != c.wantOK {
+			t.Errorf("parseDefinitionUpdate(%q) = %q, %t want %q, %t", c.refdata, got, ok, c.want, c.wantOK)
+		}
+	}
+}
+
+func TestDefinitionUpdates(t *testing.T) {
+	r := NewRegistry(pgtest.NewTx(t), prottest.NewChain(t), nil)
+	ctx := context.Background()
+
+	def := map[string]interface{}{"name": "Dolar"}
+	asset, err := r.Define(ctx, []chainkd.XPub{testutil.TestXPub}, 1, def, "", nil, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	updates := []map[string]interface{}{
+		{"name": "Dollar"},
+		{"name": "US Dollar"},
+	}
+	for i, u := range updates {
+		b := &bc.Block{
+			BlockHeader:  bc.BlockHeader{Height: uint64(i + 1)},
+			Transactions: []*bc.Tx{definitionUpdateTx(t, asset, u)},
+		}
+		// Processing a block twice records its updates once.
+		for j := 0; j < 2; j++ {
+			updated, err := r.indexDefinitionUpdates(ctx, b)
+			if err != nil {
+				testutil.FatalErr(t, err)
+			}
+			if want := []bc.AssetID{asset.AssetID}; !testutil.DeepEqual(updated, want) {
+				t.Errorf("block %d: updated assets = %v want %v", b.Height, updated, want)
+			}
+		}
+	}
+
+	got, err := r.findByID(ctx, asset.AssetID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if got.DefinitionVersion != 2 {
+		t.Errorf("definition version = %d want 2", got.DefinitionVersion)
+	}
+	if string(got.RawDefinition()) != string(asset.RawDefinition()) {
+		t.Errorf("raw definition = %s want original %s", got.RawDefinition(), asset.RawDefinition())
+	}
+	want, _ := serializeAssetDef(updates[1])
+	if string(got.currentDefinition) != string(want) {
+		t.Errorf("current definition = %s want %s", got.currentDefinition, want)
+	}
+}
+
+// definitionUpdateTx returns a tx issuing one unit of
+// asset with reference data holding def as its new definition.
+func definitionUpdateTx(t *testing.T, asset *Asset, def map[string]interface{}) *bc.Tx {
+	refdata, err := json.Marshal(map[string]interface{}{"updated_asset_definition": def})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	in := bc.NewIssuanceInput(nil, 1, refdata, asset.InitialBlockHash, asset.IssuanceProgram, nil, asset.RawDefinition())
+	return bc.NewTx(bc.TxData{Inputs: []*bc.TxInput{in}})
+}
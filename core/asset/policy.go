@@ -0,0 +1,225 @@
+package asset
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+var (
+	ErrBadIssuancePolicy    = errors.New("invalid issuance policy")
+	ErrIssuanceNotPermitted = errors.New("issuance not permitted by policy")
+	ErrIssuanceLimit        = errors.New("issuance exceeds the asset's outstanding limit")
+	ErrIssuanceRateLimited  = errors.New("issuance rate limit exceeded")
+	errUnknownPolicy        = errors.New("unknown policy")
+)
+
+// IssuancePolicy restricts the issuance of an asset through this
+// Core, on top of its issuance program: it is checked when issue
+// actions are built and when transactions with issuances are
+// submitted. Empty fields leave issuance unrestricted in that
+// respect.
+type IssuancePolicy struct {
+	// MaxOutstanding limits the amount of the asset in unspent
+	// outputs on the blockchain, as indexed by this Core, after
+	// an issuance. 0 means no limit.
+	MaxOutstanding uint64 `json:"max_outstanding"`
+
+	// Tokens lists the IDs of the access
+	// tokens that may issue the asset.
+	Tokens []string `json:"tokens"`
+
+	// MaxPerHour limits the amount of the asset issued in
+	// transactions submitted each hour. 0 means no limit.
+	MaxPerHour uint64 `json:"max_per_hour"`
+}
+
+// issueWindow counts the amount of an asset
+// issued in the hour starting at start.
+type issueWindow struct {
+	start  time.Time
+	amount uint64
+}
+
+type issuerKey struct{}
+
+// WithIssuer returns a context carrying the ID of the access
+// token of whoever builds issue actions with it, to check
+// against issuance policies.
+func WithIssuer(ctx context.Context, issuer string) context.Context {
+	return context.WithValue(ctx, issuerKey{}, issuer)
+}
+
+// issuer returns the identity carried by ctx, or "".
+func issuer(ctx context.Context) string {
+	s, _ := ctx.Value(issuerKey{}).(string)
+	return s
+}
+
+// SetIssuancePolicy sets the issuance policy for the
+// asset with the given ID, replacing any earlier policy.
+func (reg *Registry) SetIssuancePolicy(ctx context.Context, id bc.AssetID, p *IssuancePolicy) error {
+	if p.MaxOutstanding > math.MaxInt64 || p.MaxPerHour > math.MaxInt64 {
+		return errors.WithDetail(ErrBadIssuancePolicy, "limit too big")
+	}
+
+	const q = `
+		INSERT INTO asset_issuance_policies (asset_id, max_outstanding, tokens, max_per_hour)
+		SELECT id, $2, COALESCE($3::text[], '{}'), $4 FROM assets WHERE id = $1
+		ON CONFLICT (asset_id) DO UPDATE
+		SET max_outstanding = excluded.max_outstanding, tokens = excluded.tokens, max_per_hour = excluded.max_per_hour
+	`
+	res, err := reg.db.Exec(ctx, q, id, int64(p.MaxOutstanding), pq.StringArray(p.Tokens), int64(p.MaxPerHour))
+	if err != nil {
+		return errors.Wrap(err, "storing issuance policy")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "storing issuance policy")
+	}
+	if n == 0 {
+		return errors.WithDetailf(pg.ErrUserInputNotFound, "asset id: %s", id)
+	}
+	return nil
+}
+
+// IssuancePolicy returns the issuance policy for the asset
+// with the given ID. An asset with no policy has an empty one.
+func (reg *Registry) IssuancePolicy(ctx context.Context, id bc.AssetID) (*IssuancePolicy, error) {
+	p, err := reg.issuancePolicy(ctx, id)
+	if err == errUnknownPolicy {
+		_, err = reg.findByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return &IssuancePolicy{Tokens: []string{}}, nil
+	}
+	return p, err
+}
+
+// checkIssuance returns an error if the policy of the asset
+// forbids the holder of token issuing amount of it now. If count is
+// true, it also counts the amount toward the asset's rate
+// limit.
+func (reg *Registry) checkIssuance(ctx context.Context, id bc.AssetID, amount uint64, token string, count bool) error {
+	p, err := reg.issuancePolicy(ctx, id)
+	if err == errUnknownPolicy {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if token != "" && len(p.Tokens) > 0 && !contains(p.Tokens, token) {
+		return errors.WithDetailf(ErrIssuanceNotPermitted, "access token %q may not issue asset %s", token, id)
+	}
+
+	if p.MaxOutstanding > 0 {
+		const q = `
+			SELECT COALESCE(SUM(amount), 0) FROM annotated_outputs
+			WHERE asset_id = $1 AND upper_inf(timespan)
+		`
+		var outstanding uint64
+		err = reg.db.QueryRow(ctx, q, id).Scan(&outstanding)
+		if err != nil {
+			return errors.Wrap(err, "summing outstanding amount")
+		}
+		if amount > p.MaxOutstanding || outstanding > p.MaxOutstanding-amount {
+			return errors.WithDetailf(ErrIssuanceLimit, "%d outstanding, at most %d allowed", outstanding, p.MaxOutstanding)
+		}
+	}
+
+	if p.MaxPerHour == 0 {
+		return nil
+	}
+	reg.rateMu.Lock()
+	defer reg.rateMu.Unlock()
+	now := time.Now()
+	w := reg.rates[id]
+	if w == nil || now.Sub(w.start) >= time.Hour {
+		w = &issueWindow{start: now}
+		if count {
+			reg.rates[id] = w
+		}
+	}
+	if amount > p.MaxPerHour || w.amount > p.MaxPerHour-amount {
+		return errors.WithDetailf(ErrIssuanceRateLimited, "%d issued this hour, at most %d allowed", w.amount, p.MaxPerHour)
+	}
+	if count {
+		w.amount += amount
+	}
+	return nil
+}
+
+// AuthorizeIssuances checks the issuances in tx against the
+// policies of their assets before tx is submitted with the
+// access token with the given ID, and counts them toward the
+// assets' rate limits. An empty token, from a request
+// authenticated otherwise than by access token, is not checked
+// against the policies' tokens.
+func (reg *Registry) AuthorizeIssuances(ctx context.Context, tx *bc.Tx, token string) error {
+	// Issuances of an asset are checked together.
+	var ids []bc.AssetID
+	amounts := make(map[bc.AssetID]uint64)
+	for _, in := range tx.Inputs {
+		if !in.IsIssuance() {
+			continue
+		}
+		id := in.AssetID()
+		if _, ok := amounts[id]; !ok {
+			ids = append(ids, id)
+		}
+		amounts[id] += in.Amount()
+	}
+	// Check them all before counting any, so a tx
+	// rejected for one asset doesn't count toward
+	// another's rate limit.
+	for _, count := range []bool{false, true} {
+		for _, id := range ids {
+			err := reg.checkIssuance(ctx, id, amounts[id], token, count)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (reg *Registry) issuancePolicy(ctx context.Context, id bc.AssetID) (*IssuancePolicy, error) {
+	const q = `
+		SELECT max_outstanding, tokens, max_per_hour
+		FROM asset_issuance_policies WHERE asset_id = $1
+	`
+	var (
+		tokens         pq.StringArray
+		maxOutstanding int64
+		maxPerHour     int64
+	)
+	err := reg.db.QueryRow(ctx, q, id).Scan(&maxOutstanding, &tokens, &maxPerHour)
+	if err == sql.ErrNoRows {
+		return nil, errUnknownPolicy
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "reading issuance policy")
+	}
+	return &IssuancePolicy{
+		MaxOutstanding: uint64(maxOutstanding),
+		Tokens:         tokens,
+		MaxPerHour:     uint64(maxPerHour),
+	}, nil
+}
+
+func contains(a []string, s string) bool {
+	for _, x := range a {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
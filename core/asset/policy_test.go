@@ -0,0 +1,81 @@
+package asset
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"chain/core/txbuilder"
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestIssuancePolicy(t *testing.T) {
+	r := NewRegistry(pgtest.NewTx(t), prottest.NewChain(t), nil)
+	ctx := context.Background()
+
+	asset, err := r.Define(ctx, []chainkd.XPub{testutil.TestXPub}, 1, nil, "", nil, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	got, err := r.IssuancePolicy(ctx, asset.AssetID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	want := &IssuancePolicy{Tokens: []string{}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("default policy = %+v want %+v", got, want)
+	}
+
+	want = &IssuancePolicy{Tokens: []string{"issuer"}, MaxPerHour: 10}
+	err = r.SetIssuancePolicy(ctx, asset.AssetID, want)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	got, err = r.IssuancePolicy(ctx, asset.AssetID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("policy = %+v want %+v", got, want)
+	}
+
+	issue := func(token string, amount uint64) error {
+		ctx := WithIssuer(ctx, token)
+		a := r.NewIssueAction(bc.AssetAmount{AssetID: asset.AssetID, Amount: amount}, nil)
+		return a.Build(ctx, txbuilder.NewBuilder(time.Now().Add(time.Minute)))
+	}
+	err = issue("other", 1)
+	if errors.Root(err) != ErrIssuanceNotPermitted {
+		t.Errorf("issuing with other token err = %v want %v", err, ErrIssuanceNotPermitted)
+	}
+	err = issue("issuer", 11)
+	if errors.Root(err) != ErrIssuanceRateLimited {
+		t.Errorf("issuing over the rate limit err = %v want %v", err, ErrIssuanceRateLimited)
+	}
+
+	// Building doesn't count toward the rate limit; submitting does.
+	for i := 0; i < 2; i++ {
+		err = issue("issuer", 6)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+	}
+	tx := bc.NewTx(bc.TxData{Inputs: []*bc.TxInput{
+		bc.NewIssuanceInput(nil, 6, nil, asset.InitialBlockHash, asset.IssuanceProgram, nil, asset.RawDefinition()),
+	}})
+	err = r.AuthorizeIssuances(ctx, tx, "issuer")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = r.AuthorizeIssuances(ctx, tx, "issuer")
+	if errors.Root(err) != ErrIssuanceRateLimited {
+		t.Errorf("submitting over the rate limit err = %v want %v", err, ErrIssuanceRateLimited)
+	}
+}
@@ -0,0 +1,245 @@
+package asset
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/lib/pq"
+
+	"chain/core/txbuilder"
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// Fields of an issuance input's reference data that link
+// assets in a succession. A succession takes effect only if
+// one transaction issues the predecessor, naming its successor,
+// and issues the successor, naming its predecessor. Only
+// holders of an asset's issuance keys can issue it, so the
+// link is attested by the keys of both assets.
+const (
+	successorField   = "successor_asset_id"
+	predecessorField = "predecessor_asset_id"
+)
+
+// ErrBadSuccessor is returned when an asset cannot
+// succeed the requested predecessor.
+var ErrBadSuccessor = errors.New("invalid asset successor")
+
+// DefineSuccessor defines a new asset, controlled by a new set of
+// keys, to succeed the asset predecessorID. The new asset has the
+// same definition as its predecessor but a different asset ID.
+// The succession takes effect once a link transaction, built with
+// the link_asset_successor action and signed with the keys of both
+// assets, is confirmed.
+func (reg *Registry) DefineSuccessor(ctx context.Context, predecessorID bc.AssetID, xpubs []chainkd.XPub, quorum int, alias string, tags map[string]interface{}, clientToken string) (*Asset, error) {
+	pred, err := reg.findByID(ctx, predecessorID)
+	if errors.Root(err) == pg.ErrUserInputNotFound {
+		err = errors.WithDetailf(err, "missing asset with ID %q", predecessorID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	def, err := pred.Definition()
+	if err != nil {
+		return nil, errors.WithDetail(ErrBadSuccessor, "predecessor asset definition is not a JSON object")
+	}
+	return reg.Define(ctx, xpubs, quorum, def, alias, tags, clientToken)
+}
+
+// Lineage returns the IDs of the assets in the lineage of id,
+// from the original asset to its most recent successor.
+// An asset with no predecessor or successor is its own lineage.
+func (reg *Registry) Lineage(ctx context.Context, id bc.AssetID) ([]bc.AssetID, error) {
+	const q = `
+		WITH RECURSIVE
+		up (id, depth) AS (
+			SELECT $1::bytea, 0
+			UNION ALL
+			SELECT s.predecessor_id, up.depth-1 FROM up
+			JOIN asset_successions s ON s.successor_id = up.id
+		),
+		down (id, depth) AS (
+			SELECT $1::bytea, 0
+			UNION ALL
+			SELECT s.successor_id, down.depth+1 FROM down
+			JOIN asset_successions s ON s.predecessor_id = down.id
+		)
+		SELECT id FROM (SELECT * FROM up UNION SELECT * FROM down) l ORDER BY depth
+	`
+	var ids []bc.AssetID
+	err := pg.ForQueryRows(ctx, reg.db, q, id, func(id bc.AssetID) {
+		ids = append(ids, id)
+	})
+	return ids, errors.Wrap(err, "querying asset lineage")
+}
+
+// indexSuccessions records the asset successions
+// attested by pairs of issuances in b.
+func (reg *Registry) indexSuccessions(ctx context.Context, b *bc.Block) error {
+	var (
+		predecessors pq.ByteaArray
+		successors   pq.ByteaArray
+		txHashes     pq.ByteaArray
+
+		// To keep lineages acyclic, each asset may take part
+		// in at most one new succession per block.
+		involved = make(map[bc.AssetID]bool)
+	)
+	for _, tx := range b.Transactions {
+		// The successions the successors' issuances accept.
+		accepted := make(map[[2]bc.AssetID]bool)
+		for _, in := range tx.Inputs {
+			if !in.IsIssuance() {
+				continue
+			}
+			if pred, ok := assetLink(in.ReferenceData, predecessorField); ok {
+				accepted[[2]bc.AssetID{pred, in.AssetID()}] = true
+			}
+		}
+
+		for _, in := range tx.Inputs {
+			if !in.IsIssuance() {
+				continue
+			}
+			succ, ok := assetLink(in.ReferenceData, successorField)
+			if !ok {
+				continue
+			}
+			pred := in.AssetID()
+			if !accepted[[2]bc.AssetID{pred, succ}] {
+				continue
+			}
+			if pred == succ || involved[pred] || involved[succ] {
+				continue
+			}
+			involved[pred] = true
+			involved[succ] = true
+			predecessors = append(predecessors, pred[:])
+			successors = append(successors, succ[:])
+			txHashes = append(txHashes, tx.ID.Bytes())
+		}
+	}
+	if len(predecessors) == 0 {
+		return nil
+	}
+
+	// A successor must be new to the succession graph, and a
+	// predecessor may be succeeded only once. Together these
+	// rule out cycles across blocks.
+	const q = `
+		INSERT INTO asset_successions (predecessor_id, successor_id, tx_hash, block_height)
+		SELECT l.pred, l.succ, l.tx_hash, $4 FROM (
+			SELECT unnest($1::bytea[]) AS pred, unnest($2::bytea[]) AS succ, unnest($3::bytea[]) AS tx_hash
+		) l
+		WHERE NOT EXISTS (
+			SELECT 1 FROM asset_successions
+			WHERE (successor_id = l.succ OR predecessor_id = l.succ) AND block_height <> $4
+		)
+		ON CONFLICT DO NOTHING
+	`
+	_, err := reg.db.Exec(ctx, q, predecessors, successors, txHashes, b.Height)
+	return errors.Wrap(err, "inserting asset successions")
+}
+
+// assetLink parses the asset ID in field, if any,
+// from the reference data of an issuance input.
+func assetLink(refdata []byte, field string) (bc.AssetID, bool) {
+	var (
+		fields map[string]json.RawMessage
+		id     bc.AssetID
+	)
+	if len(refdata) == 0 || json.Unmarshal(refdata, &fields) != nil || fields[field] == nil {
+		return bc.AssetID{}, false
+	}
+	if json.Unmarshal(fields[field], &id) != nil || id == (bc.AssetID{}) {
+		return bc.AssetID{}, false
+	}
+	return id, true
+}
+
+func (reg *Registry) NewLinkSuccessorAction(assetID, successorID bc.AssetID) txbuilder.Action {
+	return &linkSuccessorAction{
+		assets:      reg,
+		AssetID:     assetID,
+		SuccessorID: successorID,
+	}
+}
+
+func (reg *Registry) DecodeLinkSuccessorAction(data []byte) (txbuilder.Action, error) {
+	a := &linkSuccessorAction{assets: reg}
+	err := json.Unmarshal(data, a)
+	return a, err
+}
+
+// linkSuccessorAction issues one unit of the predecessor asset,
+// with reference data naming its successor, and one unit of the
+// successor, with reference data naming its predecessor. The units
+// must be retired or otherwise spent by other actions in the same
+// transaction.
+type linkSuccessorAction struct {
+	assets      *Registry
+	AssetID     bc.AssetID `json:"asset_id"`
+	SuccessorID bc.AssetID `json:"successor_asset_id"`
+}
+
+func (a *linkSuccessorAction) Build(ctx context.Context, builder *txbuilder.TemplateBuilder) error {
+	var missing []string
+	if a.AssetID == (bc.AssetID{}) {
+		missing = append(missing, "asset_id")
+	}
+	if a.SuccessorID == (bc.AssetID{}) {
+		missing = append(missing, "successor_asset_id")
+	}
+	if len(missing) > 0 {
+		return txbuilder.MissingFieldsError(missing...)
+	}
+	if a.AssetID == a.SuccessorID {
+		return errors.WithDetail(ErrBadSuccessor, "an asset cannot succeed itself")
+	}
+
+	pred, err := a.assets.findByID(ctx, a.AssetID)
+	if errors.Root(err) == pg.ErrUserInputNotFound {
+		err = errors.WithDetailf(err, "missing asset with ID %q", a.AssetID)
+	}
+	if err != nil {
+		return err
+	}
+	if pred.Signer == nil {
+		return errors.WithDetail(ErrBadSuccessor, "predecessor asset keys are not held by this core")
+	}
+	succ, err := a.assets.findByID(ctx, a.SuccessorID)
+	if errors.Root(err) == pg.ErrUserInputNotFound {
+		err = errors.WithDetailf(err, "missing asset with ID %q", a.SuccessorID)
+	}
+	if err != nil {
+		return err
+	}
+	if succ.Signer == nil {
+		return errors.WithDetail(ErrBadSuccessor, "successor asset keys are not held by this core")
+	}
+
+	err = a.issueLink(ctx, builder, a.AssetID, successorField, a.SuccessorID)
+	if err != nil {
+		return err
+	}
+	return a.issueLink(ctx, builder, a.SuccessorID, predecessorField, a.AssetID)
+}
+
+// issueLink issues one unit of assetID with reference
+// data naming other in field.
+func (a *linkSuccessorAction) issueLink(ctx context.Context, builder *txbuilder.TemplateBuilder, assetID bc.AssetID, field string, other bc.AssetID) error {
+	refdata, err := json.Marshal(map[string]interface{}{field: other})
+	if err != nil {
+		return errors.Wrap(err, "marshaling succession link")
+	}
+	issue := &issueAction{
+		assets:        a.assets,
+		AssetAmount:   bc.AssetAmount{AssetID: assetID, Amount: 1},
+		ReferenceData: chainjson.Map(refdata),
+	}
+	return issue.Build(ctx, builder)
+}
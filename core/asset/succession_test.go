@@ -0,0 +1,117 @@
+package asset
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg/pgtest"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestAssetLink(t *testing.T) {
+	id := bc.AssetID{1}
+	cases := []struct {
+		refdata string
+		want    bc.AssetID
+		wantOK  bool
+	}{
+		{``, bc.AssetID{}, false},
+		{`{}`, bc.AssetID{}, false},
+		{`not json`, bc.AssetID{}, false},
+		{`{"successor_asset_id": "zz"}`, bc.AssetID{}, false},
+		{`{"successor_asset_id": null}`, bc.AssetID{}, false},
+		{fmt.Sprintf(`{"successor_asset_id": "%s"}`, id), id, true},
+		{fmt.Sprintf(`{"predecessor_asset_id": "%s"}`, id), bc.AssetID{}, false},
+		{fmt.Sprintf(`{"successor_asset_id": "%s", "note": 5}`, id), id, true},
+	}
+	for _, c := range cases {
+		got, ok := assetLink([]byte(c.refdata), successorField)
+		if got != c.want || ok != c.wantOK {
+			t.Errorf("assetLink(%q) = %v, %t want %v, %t", c.refdata, got, ok, c.want, c.wantOK)
+		}
+	}
+}
+
+func TestAssetSuccession(t *testing.T) {
+	r := NewRegistry(pgtest.NewTx(t), prottest.NewChain(t), nil)
+	ctx := context.Background()
+
+	def := map[string]interface{}{"currency": "USD"}
+	a1, err := r.Define(ctx, []chainkd.XPub{testutil.TestXPub}, 1, def, "", nil, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	a2, err := r.DefineSuccessor(ctx, a1.AssetID, []chainkd.XPub{testutil.TestXPub}, 1, "", nil, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	a3, err := r.DefineSuccessor(ctx, a2.AssetID, []chainkd.XPub{testutil.TestXPub}, 1, "", nil, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if a2.AssetID == a1.AssetID || a3.AssetID == a2.AssetID {
+		t.Fatal("successor has the same asset ID as its predecessor")
+	}
+	if got, _ := a3.Definition(); !testutil.DeepEqual(got, def) {
+		t.Errorf("successor definition = %v want %v", got, def)
+	}
+
+	a4, err := r.Define(ctx, []chainkd.XPub{testutil.TestXPub}, 1, def, "", nil, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// A link the successor doesn't accept is ignored,
+	// and doesn't keep a2 from succeeding a1.
+	b := &bc.Block{
+		BlockHeader:  bc.BlockHeader{Height: 1},
+		Transactions: []*bc.Tx{bc.NewTx(bc.TxData{Inputs: []*bc.TxInput{linkInput(a4, successorField, a2.AssetID)}})},
+	}
+	err = r.indexSuccessions(ctx, b)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	for i, pair := range [][2]*Asset{{a1, a2}, {a2, a3}, {a3, a1}} {
+		b := &bc.Block{
+			BlockHeader:  bc.BlockHeader{Height: uint64(i + 2)},
+			Transactions: []*bc.Tx{issuanceLink(pair[0], pair[1])},
+		}
+		err = r.indexSuccessions(ctx, b)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+	}
+
+	want := []bc.AssetID{a1.AssetID, a2.AssetID, a3.AssetID}
+	for _, a := range []*Asset{a1, a2, a3} {
+		got, err := r.Lineage(ctx, a.AssetID)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		// The a3 -> a1 link would close a cycle and must be ignored.
+		if !testutil.DeepEqual(got, want) {
+			t.Errorf("Lineage(%v) = %v want %v", a.AssetID, got, want)
+		}
+	}
+}
+
+// issuanceLink returns a tx issuing one unit each of pred and
+// succ, with reference data naming each other in succession.
+func issuanceLink(pred, succ *Asset) *bc.Tx {
+	return bc.NewTx(bc.TxData{Inputs: []*bc.TxInput{
+		linkInput(pred, successorField, succ.AssetID),
+		linkInput(succ, predecessorField, pred.AssetID),
+	}})
+}
+
+// linkInput returns an input issuing one unit of a
+// with reference data naming other in field.
+func linkInput(a *Asset, field string, other bc.AssetID) *bc.TxInput {
+	refdata := fmt.Sprintf(`{%q: "%s"}`, field, other)
+	return bc.NewIssuanceInput(nil, 1, []byte(refdata), a.InitialBlockHash, a.IssuanceProgram, nil, a.RawDefinition())
+}
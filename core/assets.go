@@ -7,6 +7,7 @@ import (
 	"chain/core/asset"
 	"chain/crypto/ed25519/chainkd"
 	"chain/net/http/reqid"
+	"chain/protocol/bc"
 )
 
 // POST /create-asset
@@ -58,3 +59,141 @@ func (a *API) createAsset(ctx context.Context, ins []struct {
 	wg.Wait()
 	return responses, nil
 }
+
+// POST /create-asset-successor
+func (a *API) createAssetSuccessor(ctx context.Context, ins []struct {
+	AssetID    bc.AssetID     `json:"asset_id"`
+	AssetAlias string         `json:"asset_alias"`
+	Alias      string         `json:"alias"`
+	RootXPubs  []chainkd.XPub `json:"root_xpubs"`
+	Quorum     int
+	Tags       map[string]interface{}
+
+	// ClientToken serves the same purpose as in /create-asset.
+	ClientToken string `json:"client_token"`
+}) ([]interface{}, error) {
+	responses := make([]interface{}, len(ins))
+	var wg sync.WaitGroup
+	wg.Add(len(responses))
+
+	for i := range responses {
+		go func(i int) {
+			subctx := reqid.NewSubContext(ctx, reqid.New())
+			defer wg.Done()
+			defer batchRecover(subctx, &responses[i])
+
+			predID := ins[i].AssetID
+			if ins[i].AssetAlias != "" {
+				pred, err := a.Assets.FindByAlias(subctx, ins[i].AssetAlias)
+				if err != nil {
+					responses[i] = err
+					return
+				}
+				predID = pred.AssetID
+			}
+			a, err := a.Assets.DefineSuccessor(
+				subctx,
+				predID,
+				ins[i].RootXPubs,
+				ins[i].Quorum,
+				ins[i].Alias,
+				ins[i].Tags,
+				ins[i].ClientToken,
+			)
+			if err != nil {
+				responses[i] = err
+				return
+			}
+			aa, err := asset.Annotated(a)
+			if err != nil {
+				responses[i] = err
+				return
+			}
+			responses[i] = aa
+		}(i)
+	}
+
+	wg.Wait()
+	return responses, nil
+}
+
+// POST /get-asset-lineage
+func (a *API) getAssetLineage(ctx context.Context, in struct {
+	AssetID    bc.AssetID `json:"asset_id"`
+	AssetAlias string     `json:"asset_alias"`
+}) (map[string]interface{}, error) {
+	id := in.AssetID
+	if in.AssetAlias != "" {
+		found, err := a.Assets.FindByAlias(ctx, in.AssetAlias)
+		if err != nil {
+			return nil, err
+		}
+		id = found.AssetID
+	}
+	ids, err := a.Assets.Lineage(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"asset_ids": ids}, nil
+}
+
+// getAssetDefinitionHistory returns the versions of the definition
+// of an asset, given by its ID or alias, published by
+// update_asset_definition actions.
+//
+// POST /get-asset-definition-history
+func (a *API) getAssetDefinitionHistory(ctx context.Context, in struct {
+	AssetID    bc.AssetID `json:"asset_id"`
+	AssetAlias string     `json:"asset_alias"`
+}) (map[string]interface{}, error) {
+	id, err := a.assetID(ctx, in.AssetID, in.AssetAlias)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := a.Indexer.AssetDefinitionHistory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"versions": versions}, nil
+}
+
+// setAssetIssuancePolicy restricts the issuance of an asset
+// through this Core, given by its ID or alias.
+//
+// POST /set-asset-issuance-policy
+func (a *API) setAssetIssuancePolicy(ctx context.Context, in struct {
+	AssetID    bc.AssetID           `json:"asset_id"`
+	AssetAlias string               `json:"asset_alias"`
+	Policy     asset.IssuancePolicy `json:"policy"`
+}) error {
+	id, err := a.assetID(ctx, in.AssetID, in.AssetAlias)
+	if err != nil {
+		return err
+	}
+	return a.Assets.SetIssuancePolicy(ctx, id, &in.Policy)
+}
+
+// POST /get-asset-issuance-policy
+func (a *API) getAssetIssuancePolicy(ctx context.Context, in struct {
+	AssetID    bc.AssetID `json:"asset_id"`
+	AssetAlias string     `json:"asset_alias"`
+}) (*asset.IssuancePolicy, error) {
+	id, err := a.assetID(ctx, in.AssetID, in.AssetAlias)
+	if err != nil {
+		return nil, err
+	}
+	return a.Assets.IssuancePolicy(ctx, id)
+}
+
+// assetID returns id, or if alias is
+// given, the ID of the asset it names.
+func (a *API) assetID(ctx context.Context, id bc.AssetID, alias string) (bc.AssetID, error) {
+	if alias == "" {
+		return id, nil
+	}
+	found, err := a.Assets.FindByAlias(ctx, alias)
+	if err != nil {
+		return bc.AssetID{}, err
+	}
+	return found.AssetID, nil
+}
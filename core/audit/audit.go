@@ -0,0 +1,219 @@
+// Package audit keeps a log of the administrative actions
+// taken on a core, such as configuring it, managing access
+// tokens and grants, resetting it, and migrating its schema.
+//
+// The log is append-only and hash-chained: the hash of each
+// entry commits to its contents and to the hash of the entry
+// before it, so Verify detects entries that were altered,
+// removed, or inserted after the fact.
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"chain/crypto/sha3pool"
+	"chain/database/pg"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+)
+
+var (
+	// ErrBadAfter is returned by List when
+	// its pagination cursor is malformed.
+	ErrBadAfter = errors.New("malformed pagination parameter after")
+
+	// ErrBroken is returned by Verify when the
+	// log's hash chain doesn't hold.
+	ErrBroken = errors.New("audit log hash chain is broken")
+)
+
+// maxAttempts is how many times Record tries to append
+// an entry when other processes append at the same time.
+const maxAttempts = 10
+
+// An Entry records an action taken by an actor.
+// Detail is a JSON object describing the action.
+type Entry struct {
+	Seq       uint64             `json:"seq"`
+	Timestamp time.Time          `json:"timestamp"`
+	Actor     string             `json:"actor"`
+	Action    string             `json:"action"`
+	Detail    *json.RawMessage   `json:"detail"`
+	PrevHash  chainjson.HexBytes `json:"prev_hash"`
+	Hash      chainjson.HexBytes `json:"hash"`
+}
+
+// hash computes the hash of e, which
+// commits to its fields and its PrevHash.
+func (e *Entry) hash() []byte {
+	var buf []byte
+	buf = append(buf, e.PrevHash...)
+	buf = appendUint64(buf, e.Seq)
+	buf = appendUint64(buf, uint64(e.Timestamp.UnixNano()))
+	buf = appendBytes(buf, []byte(e.Actor))
+	buf = appendBytes(buf, []byte(e.Action))
+	buf = appendBytes(buf, *e.Detail)
+	h := make([]byte, 32)
+	sha3pool.Sum256(h, buf)
+	return h
+}
+
+func appendUint64(buf []byte, n uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], n)
+	return append(buf, b[:]...)
+}
+
+func appendBytes(buf, b []byte) []byte {
+	return append(appendUint64(buf, uint64(len(b))), b...)
+}
+
+// Record appends an entry to the log for action by actor,
+// with detail, which must marshal to a JSON object.
+func Record(ctx context.Context, db pg.DB, actor, action string, detail interface{}) (*Entry, error) {
+	b, err := json.Marshal(detail)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshaling audit detail")
+	}
+	raw := json.RawMessage(b)
+	for attempt := 1; ; attempt++ {
+		e := &Entry{
+			// Postgres stores microseconds; hash what it stores.
+			Timestamp: time.Now().UTC().Truncate(time.Microsecond),
+			Actor:     actor,
+			Action:    action,
+			Detail:    &raw,
+		}
+		err = appendEntry(ctx, db, e)
+		if pg.IsUniqueViolation(err) && attempt < maxAttempts {
+			// Another entry took this sequence number.
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "recording audit entry for %s", action)
+		}
+		return e, nil
+	}
+}
+
+// appendEntry chains e to the last entry in the log,
+// and inserts it. It fails with a unique violation if
+// another entry was appended in the meantime.
+func appendEntry(ctx context.Context, db pg.DB, e *Entry) error {
+	const lastQ = `SELECT seq, hash FROM audit_log ORDER BY seq DESC LIMIT 1`
+	var (
+		prevSeq  uint64
+		prevHash = make([]byte, 32)
+	)
+	err := db.QueryRow(ctx, lastQ).Scan(&prevSeq, &prevHash)
+	if err != nil && err != sql.ErrNoRows {
+		return errors.Wrap(err, "reading last audit entry")
+	}
+	e.Seq = prevSeq + 1
+	e.PrevHash = prevHash
+	e.Hash = e.hash()
+
+	const q = `
+		INSERT INTO audit_log (seq, timestamp, actor, action, detail, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err = db.Exec(ctx, q, e.Seq, e.Timestamp, e.Actor, e.Action, []byte(*e.Detail), []byte(e.PrevHash), []byte(e.Hash))
+	return err
+}
+
+// List returns up to limit entries after the cursor after,
+// oldest first, and the cursor of the next page.
+// If after is empty, List starts at the first entry.
+func List(ctx context.Context, db pg.DB, after string, limit int) ([]*Entry, string, error) {
+	var afterSeq uint64
+	if after != "" {
+		var err error
+		afterSeq, err = strconv.ParseUint(after, 10, 64)
+		if err != nil {
+			return nil, "", errors.WithDetailf(ErrBadAfter, "value: %q", after)
+		}
+	}
+
+	const q = `
+		SELECT seq, timestamp, actor, action, detail, prev_hash, hash
+		FROM audit_log WHERE seq > $1 ORDER BY seq LIMIT $2
+	`
+	var entries []*Entry
+	err := forEntries(ctx, db, q, func(e *Entry) error {
+		entries = append(entries, e)
+		return nil
+	}, afterSeq, limit)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "listing audit entries")
+	}
+
+	next := after
+	if len(entries) > 0 {
+		next = strconv.FormatUint(entries[len(entries)-1].Seq, 10)
+	}
+	return entries, next, nil
+}
+
+// Verify checks the hash chain of the whole log. It returns
+// an error wrapping ErrBroken if any entry has a gap before
+// it, doesn't chain to the one before it, or doesn't match
+// its hash.
+func Verify(ctx context.Context, db pg.DB) error {
+	const q = `
+		SELECT seq, timestamp, actor, action, detail, prev_hash, hash
+		FROM audit_log ORDER BY seq
+	`
+	var (
+		prevSeq  uint64
+		prevHash = make([]byte, 32)
+	)
+	err := forEntries(ctx, db, q, func(e *Entry) error {
+		switch {
+		case e.Seq != prevSeq+1:
+			return errors.WithDetailf(ErrBroken, "entry %d follows entry %d", e.Seq, prevSeq)
+		case string(e.PrevHash) != string(prevHash):
+			return errors.WithDetailf(ErrBroken, "entry %d does not chain to entry %d", e.Seq, prevSeq)
+		case string(e.Hash) != string(e.hash()):
+			return errors.WithDetailf(ErrBroken, "entry %d does not match its hash", e.Seq)
+		}
+		prevSeq, prevHash = e.Seq, e.Hash
+		return nil
+	})
+	if errors.Root(err) == ErrBroken {
+		return err
+	}
+	return errors.Wrap(err, "verifying audit log")
+}
+
+func forEntries(ctx context.Context, db pg.DB, q string, f func(*Entry) error, args ...interface{}) error {
+	args = append(args, func(seq uint64, ts time.Time, actor, action string, detail, prevHash, hash []byte) error {
+		raw := json.RawMessage(detail)
+		return f(&Entry{
+			Seq:       seq,
+			Timestamp: ts.UTC(),
+			Actor:     actor,
+			Action:    action,
+			Detail:    &raw,
+			PrevHash:  prevHash,
+			Hash:      hash,
+		})
+	})
+	return pg.ForQueryRows(ctx, db, q, args...)
+}
+
+// LocalActor names the user running this process, for
+// actions taken outside the API, such as with corectl.
+func LocalActor() string {
+	actor := filepath.Base(os.Args[0])
+	if user := os.Getenv("USER"); user != "" {
+		actor += ":" + user
+	}
+	return actor
+}
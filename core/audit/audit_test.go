@@ -0,0 +1,70 @@
+package audit
+
+import (
+	"context"
+	"testing"
+
+	"chain/database/pg/pgtest"
+	"chain/errors"
+)
+
+func TestRecordList(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.NewTx(t)
+
+	for _, action := range []string{"configure", "create-access-token", "reset"} {
+		_, err := Record(ctx, db, "corectl:alice", action, map[string]string{"id": action})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, next, err := List(ctx, db, "", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Action != "configure" || entries[1].Action != "create-access-token" {
+		t.Fatalf("first page = %+v", entries)
+	}
+	if string(entries[1].PrevHash) != string(entries[0].Hash) {
+		t.Errorf("entry 2 prev hash = %x want %x", entries[1].PrevHash, entries[0].Hash)
+	}
+
+	entries, _, err = List(ctx, db, next, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Action != "reset" || entries[0].Seq != 3 {
+		t.Fatalf("second page = %+v", entries)
+	}
+
+	_, _, err = List(ctx, db, "x", 2)
+	if errors.Root(err) != ErrBadAfter {
+		t.Errorf("List(after x) error = %v want %v", err, ErrBadAfter)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.NewTx(t)
+
+	for _, actor := range []string{"token:alice", "token:bob"} {
+		_, err := Record(ctx, db, actor, "create-access-token", map[string]string{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := Verify(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tamper with the log as someone with
+	// direct access to the database could.
+	pgtest.Exec(ctx, db, t, `ALTER TABLE audit_log DISABLE TRIGGER audit_log_append_only`)
+	pgtest.Exec(ctx, db, t, `UPDATE audit_log SET actor='token:mallory' WHERE seq=1`)
+	err = Verify(ctx, db)
+	if errors.Root(err) != ErrBroken {
+		t.Errorf("Verify after tampering error = %v want %v", err, ErrBroken)
+	}
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"context"
+
+	"chain/core/audit"
+	"chain/net/http/httpjson"
+)
+
+// auditActor names the sender of the
+// request in ctx for the audit log.
+func auditActor(ctx context.Context) string {
+	if id := accessTokenID(ctx); id != "" {
+		return "token:" + id
+	}
+	req := httpjson.Request(ctx)
+	if req.TLS != nil && len(req.TLS.VerifiedChains) > 0 {
+		return "x509:" + req.TLS.VerifiedChains[0][0].Subject.CommonName
+	}
+	return "ip:" + remoteIP(req)
+}
+
+// recordAudit records action, described by detail, in
+// the audit log as taken by the sender of the request in
+// ctx. Callers record an action once it has succeeded,
+// and fail the request if it can't be recorded.
+func (a *API) recordAudit(ctx context.Context, action string, detail interface{}) error {
+	_, err := audit.Record(ctx, a.DB, auditActor(ctx), action, detail)
+	return err
+}
+
+func (a *API) listAuditLog(ctx context.Context, x requestQuery) (*page, error) {
+	limit, err := a.pageSize(x.PageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, next, err := audit.List(ctx, a.DB, x.After, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	outQuery := x
+	outQuery.After = next
+
+	return &page{
+		Items:    httpjson.Array(entries),
+		LastPage: len(entries) < limit,
+		Next:     outQuery,
+	}, nil
+}
@@ -3,6 +3,7 @@ package core
 import (
 	"context"
 	"encoding/hex"
+	"net"
 	"net/http"
 	"strings"
 	"sync"
@@ -10,27 +11,122 @@ import (
 
 	"chain/core/accesstoken"
 	"chain/errors"
+	"chain/net/http/authz"
+	"chain/net/http/httpjson"
 )
 
-var errNotAuthenticated = errors.New("not authenticated")
+var (
+	errNotAuthenticated = errors.New("not authenticated")
+	errNotAuthorized    = errors.New("not authorized")
+)
+
+const (
+	tokenExpiry = time.Minute * 5
 
-const tokenExpiry = time.Minute * 5
+	// maxCachedTokens bounds the number of valid
+	// credentials, and of token IDs that failed
+	// to authenticate, that apiAuthn remembers.
+	maxCachedTokens = 10000
+
+	// failedLookupInterval is how long after a failed
+	// lookup of a token ID it may be looked up again.
+	// Requests for it in the meantime are rejected.
+	failedLookupInterval = time.Second
+)
 
 type apiAuthn struct {
-	tokens *accesstoken.CredentialStore
+	tokens accesstoken.Authenticator
+	// usage records the use of tokens;
+	// nil if tokens are checked elsewhere.
+	usage *accesstoken.CredentialStore
 	// alternative authentication mechanism,
 	// used when no basic auth creds are provided.
 	alt func(*http.Request) bool
+	// authorizer checks requests against the grants;
+	// nil if only token scopes limit access.
+	authorizer *authz.Authorizer
 
 	tokenMu  sync.Mutex // protects the following
-	tokenMap map[string]tokenResult
+	tokenMap map[tokenKey]tokenResult
+	failures map[tokenKey]time.Time // by type and ID; pw is empty
+}
+
+type tokenKey struct {
+	typ, user, pw string
 }
 
 type tokenResult struct {
-	valid      bool
+	scopes     []string
 	lastLookup time.Time
 }
 
+// pathScopes maps API paths to the scope a limited
+// access token needs to use them. Paths not listed
+// here, other than those in openPaths, need the
+// admin scope.
+var pathScopes = map[string]string{
+	"/build-transaction":                         accesstoken.ScopeSubmitTx,
+	"/submit-transaction":                        accesstoken.ScopeSubmitTx,
+	"/merge-transactions":                        accesstoken.ScopeSubmitTx,
+	"/add-transaction-signatures":                accesstoken.ScopeSubmitTx,
+	networkRPCPrefix + "submit":                  accesstoken.ScopeSubmitTx,
+	networkRPCPrefix + "submit-idempotent":       accesstoken.ScopeSubmitTx,
+	"/list-accounts":                             accesstoken.ScopeReadAccounts,
+	"/list-balances":                             accesstoken.ScopeReadAccounts,
+	"/aggregate-outputs":                         accesstoken.ScopeReadAccounts,
+	"/list-unspent-outputs":                      accesstoken.ScopeReadAccounts,
+	"/list-account-events":                       accesstoken.ScopeReadAccounts,
+	"/list-reservations":                         accesstoken.ScopeReadAccounts,
+	"/cancel-reservation":                        accesstoken.ScopeSubmitTx,
+	networkRPCPrefix + "signer/sign-block":       accesstoken.ScopeSignBlock,
+	networkRPCPrefix + "signer/threshold-commit": accesstoken.ScopeSignBlock,
+	networkRPCPrefix + "signer/threshold-sign":   accesstoken.ScopeSignBlock,
+	grpcPrefix + "BuildTxs":                      accesstoken.ScopeSubmitTx,
+	grpcPrefix + "SubmitTxs":                     accesstoken.ScopeSubmitTx,
+	grpcPrefix + "ListAccounts":                  accesstoken.ScopeReadAccounts,
+	grpcPrefix + "ListBalances":                  accesstoken.ScopeReadAccounts,
+	grpcPrefix + "ListUnspentOutputs":            accesstoken.ScopeReadAccounts,
+}
+
+// openPaths may be used by any valid access token,
+// whatever its scopes.
+var openPaths = map[string]bool{
+	"/info":                           true,
+	"/get-network-membership":         true,
+	networkRPCPrefix + "build-info":   true,
+	networkRPCPrefix + "block-height": true,
+	grpcPrefix + "Info":               true,
+}
+
+// adminPaths may be used only by tokens with the admin
+// scope. Unlike the other paths that need it, they are
+// not open to tokens created without scopes.
+var adminPaths = map[string]bool{
+	"/mockhsm/set-key-policy": true,
+	"/mockhsm/export-key":     true,
+}
+
+// authorized reports whether a token limited to scopes
+// may access path.
+func authorized(scopes []string, path string) bool {
+	if adminPaths[path] {
+		for _, s := range scopes {
+			if s == accesstoken.ScopeAdmin {
+				return true
+			}
+		}
+		return false
+	}
+	if len(scopes) == 0 || openPaths[path] {
+		return true
+	}
+	scope, ok := pathScopes[path]
+	if !ok {
+		scope = accesstoken.ScopeAdmin
+	}
+	return accesstoken.Allows(scopes, scope)
+}
+
 func (a *apiAuthn) handler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		err := a.auth(req)
@@ -48,37 +144,151 @@ func (a *apiAuthn) auth(req *http.Request) error {
 		return nil
 	}
 
-	typ := "client"
-	if strings.HasPrefix(req.URL.Path, networkRPCPrefix) {
-		typ = "network"
+	creds := &authz.Credentials{Localhost: isLoopback(req)}
+	if req.TLS != nil && len(req.TLS.VerifiedChains) > 0 {
+		creds.X509Subject = &req.TLS.VerifiedChains[0][0].Subject
+	}
+
+	// Without grants, every request needs a token.
+	if ok || a.authorizer == nil {
+		typ := "client"
+		if strings.HasPrefix(req.URL.Path, networkRPCPrefix) {
+			typ = "network"
+		}
+		res, err := a.cachedAuthCheck(req.Context(), typ, user, pw)
+		if err != nil {
+			return err
+		}
+		if !authorized(res.scopes, req.URL.Path) {
+			return errNotAuthorized
+		}
+		creds.AccessTokenID = user
+		creds.AccessTokenType = typ
+		creds.AccessTokenScopes = res.scopes
+	}
+
+	if a.authorizer != nil {
+		err := a.authorizer.Authorize(req.Context(), creds, authzRoute(req.URL.Path))
+		if errors.Root(err) == authz.ErrNotAuthorized {
+			if creds.AccessTokenID == "" && creds.X509Subject == nil {
+				return errNotAuthenticated
+			}
+			return errNotAuthorized
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if ok && a.usage != nil {
+		a.usage.RecordUse(user, remoteIP(req))
+	}
+	return nil
+}
+
+// remoteIP returns the IP address of the client
+// that sent req, without its port.
+func remoteIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
 	}
-	return a.cachedAuthCheck(req.Context(), typ, user, pw)
+	return host
 }
 
-func (a *apiAuthn) authCheck(ctx context.Context, typ, user, pw string) (bool, error) {
+// isLoopback reports whether req came
+// from the loopback interface.
+func isLoopback(req *http.Request) bool {
+	ip := net.ParseIP(remoteIP(req))
+	return ip != nil && ip.IsLoopback()
+}
+
+func (a *apiAuthn) authCheck(ctx context.Context, typ, user, pw string) (bool, []string, error) {
 	pwBytes, err := hex.DecodeString(pw)
 	if err != nil {
-		return false, nil
+		return false, nil, nil
 	}
-	return a.tokens.Check(ctx, user, typ, pwBytes)
+	return a.tokens.CheckScopes(ctx, user, typ, pwBytes)
 }
 
-func (a *apiAuthn) cachedAuthCheck(ctx context.Context, typ, user, pw string) error {
+// cachedAuthCheck checks the credentials typ, user, and pw,
+// remembering valid ones for tokenExpiry. After a failed
+// check, it rejects credentials with the same ID without
+// checking them until failedLookupInterval has passed, so
+// that guessing secrets can't load the token store.
+func (a *apiAuthn) cachedAuthCheck(ctx context.Context, typ, user, pw string) (tokenResult, error) {
+	key := tokenKey{typ, user, pw}
+	idKey := tokenKey{typ: typ, user: user}
+	now := time.Now()
+
 	a.tokenMu.Lock()
-	res, ok := a.tokenMap[typ+user+pw]
+	res, ok := a.tokenMap[key]
+	failedAt, failed := a.failures[idKey]
 	a.tokenMu.Unlock()
-	if !ok || time.Now().After(res.lastLookup.Add(tokenExpiry)) {
-		valid, err := a.authCheck(ctx, typ, user, pw)
-		if err != nil {
-			return errors.Wrap(err)
+	if ok && now.Before(res.lastLookup.Add(tokenExpiry)) {
+		return res, nil
+	}
+	if failed && now.Before(failedAt.Add(failedLookupInterval)) {
+		return tokenResult{}, errNotAuthenticated
+	}
+
+	valid, scopes, err := a.authCheck(ctx, typ, user, pw)
+	if err != nil {
+		return tokenResult{}, errors.Wrap(err)
+	}
+
+	a.tokenMu.Lock()
+	defer a.tokenMu.Unlock()
+	a.makeRoom(now)
+	if !valid {
+		delete(a.tokenMap, key)
+		a.failures[idKey] = now
+		return tokenResult{}, errNotAuthenticated
+	}
+	delete(a.failures, idKey)
+	res = tokenResult{scopes: scopes, lastLookup: now}
+	a.tokenMap[key] = res
+	return res, nil
+}
+
+// makeRoom removes expired entries from a full cache. If
+// that isn't enough, it removes arbitrary entries until the
+// cache is a tenth below its limit, so that a flood of new
+// credentials doesn't make every check sweep the cache.
+// The caller must hold a.tokenMu.
+func (a *apiAuthn) makeRoom(now time.Time) {
+	const target = maxCachedTokens * 9 / 10
+	if len(a.tokenMap) >= maxCachedTokens {
+		for k, r := range a.tokenMap {
+			if !now.Before(r.lastLookup.Add(tokenExpiry)) {
+				delete(a.tokenMap, k)
+			}
+		}
+		for k := range a.tokenMap {
+			if len(a.tokenMap) <= target {
+				break
+			}
+			delete(a.tokenMap, k)
 		}
-		res = tokenResult{valid: valid, lastLookup: time.Now()}
-		a.tokenMu.Lock()
-		a.tokenMap[typ+user+pw] = res
-		a.tokenMu.Unlock()
 	}
-	if !res.valid {
-		return errNotAuthenticated
+	if len(a.failures) >= maxCachedTokens {
+		for k, t := range a.failures {
+			if !now.Before(t.Add(failedLookupInterval)) {
+				delete(a.failures, k)
+			}
+		}
+		for k := range a.failures {
+			if len(a.failures) <= target {
+				break
+			}
+			delete(a.failures, k)
+		}
 	}
-	return nil
+}
+
+// accessTokenID returns the ID of the access token that
+// authenticated the request in ctx, or "" if the request
+// was authenticated some other way.
+func accessTokenID(ctx context.Context) string {
+	user, _, _ := httpjson.Request(ctx).BasicAuth()
+	return user
 }
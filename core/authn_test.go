@@ -0,0 +1,116 @@
+package core
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"chain/core/accesstoken"
+)
+
+func TestAuthorized(t *testing.T) {
+	cases := []struct {
+		scopes []string
+		path   string
+		want   bool
+	}{
+		{nil, "/create-account", true},
+		{[]string{accesstoken.ScopeSubmitTx}, "/submit-transaction", true},
+		{[]string{accesstoken.ScopeSubmitTx}, "/list-balances", false},
+		{[]string{accesstoken.ScopeSubmitTx}, "/create-account", false},
+		{[]string{accesstoken.ScopeReadAccounts}, "/info", true},
+		{[]string{accesstoken.ScopeSignBlock}, networkRPCPrefix + "signer/sign-block", true},
+		{[]string{accesstoken.ScopeAdmin}, "/create-account", true},
+		{nil, "/mockhsm/set-key-policy", false},
+		{[]string{accesstoken.ScopeSubmitTx}, "/mockhsm/set-key-policy", false},
+		{[]string{accesstoken.ScopeAdmin}, "/mockhsm/set-key-policy", true},
+		{nil, "/mockhsm/export-key", false},
+		{[]string{accesstoken.ScopeSubmitTx}, "/mockhsm/export-key", false},
+		{[]string{accesstoken.ScopeAdmin}, "/mockhsm/export-key", true},
+	}
+	for _, c := range cases {
+		got := authorized(c.scopes, c.path)
+		if got != c.want {
+			t.Errorf("authorized(%v, %q) = %v, want %v", c.scopes, c.path, got, c.want)
+		}
+	}
+}
+
+type fakeTokens struct {
+	secrets map[string]string // by id, hex-encoded
+	lookups int
+}
+
+func (f *fakeTokens) CheckScopes(ctx context.Context, id, typ string, secret []byte) (bool, []string, error) {
+	f.lookups++
+	return f.secrets[id] == hex.EncodeToString(secret), nil, nil
+}
+
+func TestCachedAuthCheck(t *testing.T) {
+	ctx := context.Background()
+	tokens := &fakeTokens{secrets: map[string]string{"abcd": "ee11", "other": "ee"}}
+	a := &apiAuthn{
+		tokens:   tokens,
+		tokenMap: make(map[tokenKey]tokenResult),
+		failures: make(map[tokenKey]time.Time),
+	}
+
+	check := func(user, pw string, wantOK bool, wantLookups int) {
+		_, err := a.cachedAuthCheck(ctx, "client", user, pw)
+		if (err == nil) != wantOK {
+			t.Errorf("cachedAuthCheck(%s, %s) = %v, want ok %t", user, pw, err, wantOK)
+		}
+		if tokens.lookups != wantLookups {
+			t.Errorf("after cachedAuthCheck(%s, %s): %d lookups want %d", user, pw, tokens.lookups, wantLookups)
+		}
+	}
+
+	check("abcd", "ee11", true, 1)
+	check("abcd", "ee11", true, 1) // cached
+
+	// The same characters split differently
+	// are different credentials.
+	check("abcdee", "11", false, 2)
+
+	// After a failure, the ID isn't looked up again
+	// for a while, whatever the password.
+	check("abcdee", "22", false, 2)
+	check("other", "ee", true, 3)
+
+	a.failures[tokenKey{typ: "client", user: "abcdee"}] = time.Now().Add(-failedLookupInterval)
+	check("abcdee", "22", false, 4)
+
+	// A failed guess doesn't evict the valid credential.
+	check("abcd", "00", false, 5)
+	check("abcd", "ee11", true, 5)
+}
+
+func TestCachedAuthCheckBounded(t *testing.T) {
+	ctx := context.Background()
+	tokens := &fakeTokens{secrets: map[string]string{"abc": "dd"}}
+	a := &apiAuthn{
+		tokens:   tokens,
+		tokenMap: make(map[tokenKey]tokenResult),
+		failures: make(map[tokenKey]time.Time),
+	}
+	now := time.Now()
+	for i := 0; i < maxCachedTokens; i++ {
+		k := tokenKey{typ: "client", user: hex.EncodeToString([]byte{byte(i), byte(i >> 8)})}
+		a.failures[k] = now
+		k.pw = "ff"
+		a.tokenMap[k] = tokenResult{lastLookup: now.Add(-tokenExpiry)}
+	}
+
+	a.cachedAuthCheck(ctx, "client", "abc", "dd")
+	a.cachedAuthCheck(ctx, "client", "xyz", "dd")
+	if n := len(a.tokenMap); n != 1 {
+		t.Errorf("token cache has %d entries, want only the valid one", n)
+	}
+	if n := len(a.failures); n > maxCachedTokens {
+		t.Errorf("failure cache has %d entries, want at most %d", n, maxCachedTokens)
+	}
+	if _, ok := a.failures[tokenKey{typ: "client", user: "xyz"}]; !ok {
+		t.Error("failure cache is missing the latest failure")
+	}
+}
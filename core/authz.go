@@ -0,0 +1,161 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"chain/errors"
+	"chain/net/http/authz"
+	"chain/net/http/httpjson"
+)
+
+var errNoGrants = errors.New("no grant store")
+
+// Routes by the policies, other than admin, that allow them.
+// Routes not listed here allow only admin.
+var (
+	// infoRoutes are allowed by every policy.
+	infoRoutes = []string{
+		"/info",
+		"/get-network-membership",
+		networkRPCPrefix + "build-info",
+		networkRPCPrefix + "block-height",
+		grpcPrefix + "Info",
+	}
+
+	clientReadRoutes = []string{
+		"/list-accounts",
+		"/list-assets",
+		"/get-asset-lineage",
+		"/get-asset-definition-history",
+		"/get-asset-issuance-policy",
+		"/get-transaction-feed",
+		"/list-transaction-feeds",
+		"/get-webhook",
+		"/list-webhooks",
+		"/list-webhook-dead-letters",
+		"/list-transactions",
+		"/list-balances",
+		"/aggregate-outputs",
+		"/list-unspent-outputs",
+		"/list-account-events",
+		"/list-reservations",
+		"/export-transactions",
+		"/graphql",
+		grpcPrefix + "ListTxs",
+		grpcPrefix + "ListAccounts",
+		grpcPrefix + "ListAssets",
+		grpcPrefix + "ListBalances",
+		grpcPrefix + "ListUnspentOutputs",
+		grpcPrefix + "StreamBlocks",
+	}
+
+	clientWriteRoutes = []string{
+		"/create-account",
+		"/update-account-alias",
+		"/delete-account",
+		"/create-asset",
+		"/create-asset-successor",
+		"/set-asset-issuance-policy",
+		"/build-transaction",
+		"/submit-transaction",
+		"/merge-transactions",
+		"/add-transaction-signatures",
+		"/create-control-program",
+		"/create-account-receiver",
+		"/recover-account-receivers",
+		"/create-transaction-feed",
+		"/update-transaction-feed",
+		"/delete-transaction-feed",
+		"/create-webhook",
+		"/delete-webhook",
+		"/rotate-webhook-secret",
+		"/cancel-reservation",
+		grpcPrefix + "BuildTxs",
+		grpcPrefix + "SubmitTxs",
+	}
+
+	networkRoutes = []string{
+		networkRPCPrefix + "submit",
+		networkRPCPrefix + "submit-idempotent",
+		networkRPCPrefix + "get-blocks",
+		networkRPCPrefix + "get-block",
+		networkRPCPrefix + "get-snapshot-info",
+		networkRPCPrefix + "get-snapshot",
+		networkRPCPrefix + "signer/sign-block",
+		networkRPCPrefix + "signer/threshold-commit",
+		networkRPCPrefix + "signer/threshold-sign",
+	}
+
+	monitoringRoutes = []string{
+		"/get-block-signature-status",
+		"/check-network-build",
+		"/storage-usage",
+		"/get-reindex-status",
+		"/list-slow-transactions",
+		"/get-log-levels",
+		"/debug/vars",
+		"/debug/pprof/",
+	}
+)
+
+// routePolicies maps each route to the policies,
+// other than admin, that allow it.
+var routePolicies = func() map[string][]string {
+	m := make(map[string][]string)
+	add := func(routes []string, policies ...string) {
+		for _, r := range routes {
+			m[r] = append(m[r], policies...)
+		}
+	}
+	add(infoRoutes, authz.PolicyClientReadWrite, authz.PolicyClientReadOnly, authz.PolicyNetwork, authz.PolicyMonitoring)
+	add(clientReadRoutes, authz.PolicyClientReadWrite, authz.PolicyClientReadOnly)
+	add(clientWriteRoutes, authz.PolicyClientReadWrite)
+	add(networkRoutes, authz.PolicyNetwork)
+	add(monitoringRoutes, authz.PolicyMonitoring)
+	return m
+}()
+
+// authzRoute returns the route of path
+// for looking up its policies.
+func authzRoute(path string) string {
+	if strings.HasPrefix(path, "/debug/pprof/") {
+		return "/debug/pprof/"
+	}
+	return path
+}
+
+type grantRequest struct {
+	GuardType string          `json:"guard_type"`
+	GuardData json.RawMessage `json:"guard_data"`
+	Policy    string          `json:"policy"`
+}
+
+func (a *API) createGrant(ctx context.Context, x grantRequest) (*authz.Grant, error) {
+	g, err := a.Grants.Create(ctx, x.GuardType, x.GuardData, x.Policy)
+	if err != nil {
+		return nil, err
+	}
+	err = a.recordAudit(ctx, "create-authorization-grant", grantRequest{g.GuardType, g.GuardData, g.Policy})
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (a *API) listGrants(ctx context.Context) (map[string]interface{}, error) {
+	grants, err := a.Grants.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"items": httpjson.Array(grants)}, nil
+}
+
+func (a *API) deleteGrant(ctx context.Context, x grantRequest) error {
+	err := a.Grants.Delete(ctx, x.GuardType, x.GuardData, x.Policy)
+	if err != nil {
+		return err
+	}
+	return a.recordAudit(ctx, "delete-authorization-grant", x)
+}
@@ -5,16 +5,20 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"sync"
 
 	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/threshold"
 	"chain/database/pg"
 	"chain/errors"
 	"chain/protocol"
 	"chain/protocol/bc"
+	"chain/protocol/vmutil"
 )
 
 // ErrConsensusChange is returned from ValidateAndSignBlock
-// when a new consensus program is detected.
+// when a new consensus program is detected that is not
+// scheduled.
 var ErrConsensusChange = errors.New("consensus program has changed")
 
 // ErrInvalidKey is returned from SignBlock when the
@@ -29,12 +33,37 @@ type Signer interface {
 	Sign(context.Context, ed25519.PublicKey, *bc.BlockHeader) ([]byte, error)
 }
 
+// A KeyRing knows the successive versions of a block signing key.
+// It is implemented by the MockHSM.
+type KeyRing interface {
+	// KeyVersions returns every version of the key
+	// that includes pub, oldest first.
+	KeyVersions(ctx context.Context, pub ed25519.PublicKey) ([]ed25519.PublicKey, error)
+}
+
+// A Schedule provides scheduled changes of
+// the consensus program.
+type Schedule interface {
+	// ConsensusProgram returns the consensus program
+	// scheduled for the block at height, or nil if
+	// there is none.
+	ConsensusProgram(ctx context.Context, height uint64) ([]byte, error)
+}
+
 // BlockSigner validates and signs blocks.
 type BlockSigner struct {
 	Pub ed25519.PublicKey
 	hsm Signer
 	db  pg.DB
 	c   *protocol.Chain
+
+	schedule Schedule // optional
+	keys     KeyRing  // optional
+	policy   *Policy  // optional
+
+	share   *threshold.Share // optional
+	nonceMu sync.Mutex       // protects nonces
+	nonces  map[bc.Hash]*blockNonce
 }
 
 // New returns a new Signer that validates blocks with c and signs
@@ -48,10 +77,36 @@ func New(pub ed25519.PublicKey, hsm Signer, db pg.DB, c *protocol.Chain) *BlockS
 	}
 }
 
+// SetSchedule sets the source of scheduled changes of the
+// consensus program. ValidateAndSignBlock signs a block that
+// changes the program only if s has the change scheduled.
+func (s *BlockSigner) SetSchedule(sched Schedule) {
+	s.schedule = sched
+}
+
+// SetKeyRing sets the source of the versions of the signer's
+// key. SignBlock then signs each block with the version that
+// the previous block's consensus program requires, so that
+// the key can be rotated without reconfiguring the core.
+func (s *BlockSigner) SetKeyRing(keys KeyRing) {
+	s.keys = keys
+}
+
 // SignBlock computes the signature for the block using
 // the private key in s.  It does not validate the block.
 func (s *BlockSigner) SignBlock(ctx context.Context, b *bc.Block) ([]byte, error) {
-	sig, err := s.hsm.Sign(ctx, s.Pub, &b.BlockHeader)
+	pub := s.Pub
+	if s.keys != nil && b.Height > 1 {
+		prev, err := s.c.GetBlock(ctx, b.Height-1)
+		if err != nil {
+			return nil, errors.Wrapf(err, "getting block at height %d", b.Height-1)
+		}
+		pub, err = s.keyFor(ctx, prev)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sig, err := s.hsm.Sign(ctx, pub, &b.BlockHeader)
 	if err != nil {
 		return nil, errors.Sub(ErrInvalidKey, err)
 	}
@@ -67,46 +122,83 @@ func (s *BlockSigner) String() string {
 // is used as the httpjson handler for /rpc/signer/sign-block.
 //
 // This function fails if this node has ever signed a different block at the
-// same height as b.
+// same height as b, or a block at the height before that b does not extend,
+// or if b breaks the signer's policy.
 func (s *BlockSigner) ValidateAndSignBlock(ctx context.Context, b *bc.Block) ([]byte, error) {
+	err := s.validateAndLock(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+	return s.SignBlock(ctx, b)
+}
+
+// keyFor returns the newest version of the signer's key that
+// the consensus program of prev requires, or s.Pub if none
+// of them is required.
+func (s *BlockSigner) keyFor(ctx context.Context, prev *bc.Block) (ed25519.PublicKey, error) {
+	versions, err := s.keys.KeyVersions(ctx, s.Pub)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading key versions")
+	}
+	pubkeys, _, err := vmutil.ParseBlockMultiSigProgram(prev.ConsensusProgram)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing consensus program")
+	}
+	for i := len(versions) - 1; i >= 0; i-- {
+		for _, pub := range pubkeys {
+			if bytes.Equal(pub, versions[i]) {
+				return versions[i], nil
+			}
+		}
+	}
+	return s.Pub, nil
+}
+
+// validateAndLock validates b against the current blockchain
+// and the signer's policy, and records the intention to sign it.
+// It fails if signing b would conflict with a block this node
+// has already signed.
+func (s *BlockSigner) validateAndLock(ctx context.Context, b *bc.Block) error {
 	err := <-s.c.BlockSoonWaiter(ctx, b.Height-1)
 	if err != nil {
-		return nil, errors.Wrapf(err, "waiting for block at height %d", b.Height-1)
+		return errors.Wrapf(err, "waiting for block at height %d", b.Height-1)
 	}
 	prev, err := s.c.GetBlock(ctx, b.Height-1)
 	if err != nil {
-		return nil, errors.Wrap(err, "getting block at height %d", b.Height-1)
+		return errors.Wrapf(err, "getting block at height %d", b.Height-1)
 	}
-	// TODO: Add the ability to change the consensus program
-	// by having a current consensus program, and a potential
-	// next consensus program. Once the next consensus program
-	// has been used, it will become the current consensus program
-	// and the only signable consensus program until a new
-	// next is set.
 	if !bytes.Equal(b.ConsensusProgram, prev.ConsensusProgram) {
-		return nil, errors.Wrap(ErrConsensusChange)
+		err = s.checkScheduled(ctx, b)
+		if err != nil {
+			return err
+		}
 	}
 	err = s.c.ValidateBlockForSig(ctx, b)
 	if err != nil {
-		return nil, errors.Wrap(err, "validating block for signature")
+		return errors.Wrap(err, "validating block for signature")
 	}
-	err = lockBlockHeight(ctx, s.db, b)
-	if err != nil {
-		return nil, errors.Wrap(err, "lock block height")
+	if s.policy != nil {
+		err = s.policy.check(b)
+		if err != nil {
+			return err
+		}
 	}
-	return s.SignBlock(ctx, b)
+	err = lockBlockHeight(ctx, s.db, b)
+	return errors.Wrap(err, "lock block height")
 }
 
-// lockBlockHeight records a signer's intention to sign a given block
-// at a given height.  It's an error if a different block at the same
-// height has previously been signed.
-func lockBlockHeight(ctx context.Context, db pg.DB, b *bc.Block) error {
-	const q = `
-		INSERT INTO signed_blocks (block_height, block_hash)
-		SELECT $1, $2
-		    WHERE NOT EXISTS (SELECT 1 FROM signed_blocks
-		                      WHERE block_height = $1 AND block_hash = $2)
-	`
-	_, err := db.Exec(ctx, q, b.Height, b.Hash())
-	return err
+// checkScheduled returns ErrConsensusChange unless the
+// consensus program of b is scheduled for its height.
+func (s *BlockSigner) checkScheduled(ctx context.Context, b *bc.Block) error {
+	if s.schedule == nil {
+		return errors.Wrap(ErrConsensusChange)
+	}
+	program, err := s.schedule.ConsensusProgram(ctx, b.Height)
+	if err != nil {
+		return errors.Wrap(err, "loading scheduled consensus program")
+	}
+	if program == nil || !bytes.Equal(program, b.ConsensusProgram) {
+		return errors.WithDetailf(ErrConsensusChange, "no change to the consensus program is scheduled at height %d", b.Height)
+	}
+	return nil
 }
@@ -0,0 +1,117 @@
+package blocksigner
+
+import (
+	"bytes"
+	"context"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+var (
+	// ErrDoubleSign is returned when signing a block would
+	// conflict with a block this signer has already signed:
+	// a different block at the same height, or a block that
+	// does not extend the one it signed at the height before.
+	ErrDoubleSign = errors.New("block conflicts with a signed block")
+
+	// ErrPolicy is returned when a block breaks
+	// the signer's Policy.
+	ErrPolicy = errors.New("block violates signing policy")
+)
+
+// Policy holds rules that a block must follow, beyond
+// validity, for the signer to sign it.
+type Policy struct {
+	// MaxTxs limits the number of transactions
+	// in a block. 0 means no limit.
+	MaxTxs int
+
+	// Issuers lists the issuance programs that may issue
+	// assets in a block. Nil means any may.
+	Issuers [][]byte
+}
+
+// SetPolicy sets the rules that blocks must follow
+// for ValidateAndSignBlock to sign them.
+func (s *BlockSigner) SetPolicy(p *Policy) {
+	s.policy = p
+}
+
+// check returns ErrPolicy if b breaks p.
+func (p *Policy) check(b *bc.Block) error {
+	if p.MaxTxs > 0 && len(b.Transactions) > p.MaxTxs {
+		return errors.WithDetailf(ErrPolicy, "block has %d transactions, more than %d", len(b.Transactions), p.MaxTxs)
+	}
+	if p.Issuers == nil {
+		return nil
+	}
+	for _, tx := range b.Transactions {
+		for _, in := range tx.Inputs {
+			if !in.IsIssuance() || p.allowsIssuer(in.IssuanceProgram()) {
+				continue
+			}
+			return errors.WithDetailf(ErrPolicy, "transaction %s issues asset %s with an issuance program not allowed", tx.ID, in.AssetID())
+		}
+	}
+	return nil
+}
+
+func (p *Policy) allowsIssuer(prog []byte) bool {
+	for _, issuer := range p.Issuers {
+		if bytes.Equal(issuer, prog) {
+			return true
+		}
+	}
+	return false
+}
+
+// lockBlockHeight records a signer's intention to sign a given block
+// at a given height. It returns ErrDoubleSign if a different block at
+// the same height has previously been signed, or if the block does not
+// extend the block previously signed at the height before.
+func lockBlockHeight(ctx context.Context, db pg.DB, b *bc.Block) error {
+	const q = `
+		INSERT INTO signed_blocks (block_height, block_hash)
+		SELECT $1, $2
+		    WHERE NOT EXISTS (SELECT 1 FROM signed_blocks
+		                      WHERE block_height = $1 AND block_hash = $2)
+		    AND NOT EXISTS (SELECT 1 FROM signed_blocks
+		                    WHERE block_height = $1 - 1 AND block_hash <> $3)
+	`
+	_, err := db.Exec(ctx, q, b.Height, b.Hash(), b.PreviousBlockHash)
+	if pg.IsUniqueViolation(err) {
+		return errors.WithDetailf(ErrDoubleSign, "already signed a different block at height %d", b.Height)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Unless the block is now recorded, it either was
+	// already, or it does not extend the chain we signed.
+	var signed bool
+	const checkQ = `SELECT EXISTS (SELECT 1 FROM signed_blocks WHERE block_height = $1 AND block_hash = $2)`
+	err = db.QueryRow(ctx, checkQ, b.Height, b.Hash()).Scan(&signed)
+	if err != nil {
+		return err
+	}
+	if !signed {
+		return errors.WithDetailf(ErrDoubleSign, "block does not extend the block signed at height %d", b.Height-1)
+	}
+	return nil
+}
+
+// UnlockHeights forgets the blocks signed at height and
+// above, so that the signer can sign different blocks at
+// those heights. It is for recovering from a generator
+// that lost blocks this signer signed, and must not be
+// used unless those blocks will never be published. It
+// returns the number of signed blocks forgotten.
+func UnlockHeights(ctx context.Context, db pg.DB, height uint64) (int64, error) {
+	res, err := db.Exec(ctx, `DELETE FROM signed_blocks WHERE block_height >= $1`, height)
+	if err != nil {
+		return 0, errors.Wrap(err, "deleting signed blocks")
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,117 @@
+package blocksigner
+
+import (
+	"context"
+	"testing"
+
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	c := prottest.NewChain(t)
+	tx1 := prottest.NewIssuanceTx(t, c)
+	tx2 := prottest.NewIssuanceTx(t, c)
+	issuer1 := tx1.Inputs[0].IssuanceProgram()
+	issuer2 := tx2.Inputs[0].IssuanceProgram()
+	b := &bc.Block{Transactions: []*bc.Tx{tx1, tx2}}
+
+	cases := []struct {
+		policy Policy
+		want   error
+	}{
+		{Policy{}, nil},
+		{Policy{MaxTxs: 2}, nil},
+		{Policy{MaxTxs: 1}, ErrPolicy},
+		{Policy{Issuers: [][]byte{issuer1, issuer2}}, nil},
+		{Policy{Issuers: [][]byte{issuer1}}, ErrPolicy},
+		{Policy{Issuers: [][]byte{}}, ErrPolicy},
+		{Policy{Issuers: [][]byte{[]byte{0x51}}}, ErrPolicy},
+	}
+	for i, c := range cases {
+		got := c.policy.check(b)
+		if errors.Root(got) != c.want {
+			t.Errorf("case %d: check() = %v want %v", i, got, c.want)
+		}
+	}
+
+	// A block without issuances passes any list of issuers.
+	empty := &bc.Block{}
+	p := &Policy{Issuers: [][]byte{}}
+	if err := p.check(empty); err != nil {
+		t.Errorf("check(empty block) = %v want nil", err)
+	}
+}
+
+func TestLockBlockHeight(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.NewTx(t)
+
+	b1 := &bc.Block{BlockHeader: bc.BlockHeader{Height: 1, TimestampMS: 1}}
+	b2 := &bc.Block{BlockHeader: bc.BlockHeader{Height: 2, TimestampMS: 2, PreviousBlockHash: b1.Hash()}}
+	b2other := &bc.Block{BlockHeader: bc.BlockHeader{Height: 2, TimestampMS: 3, PreviousBlockHash: b1.Hash()}}
+	b3fork := &bc.Block{BlockHeader: bc.BlockHeader{Height: 3, TimestampMS: 4, PreviousBlockHash: b2other.Hash()}}
+	b3 := &bc.Block{BlockHeader: bc.BlockHeader{Height: 3, TimestampMS: 4, PreviousBlockHash: b2.Hash()}}
+
+	steps := []struct {
+		b    *bc.Block
+		want error
+	}{
+		{b1, nil},
+		{b2, nil},
+		{b2, nil},                // signing the same block again is fine
+		{b2other, ErrDoubleSign}, // a different block at the same height
+		{b3fork, ErrDoubleSign},  // a block that does not extend b2
+		{b3, nil},
+	}
+	for i, s := range steps {
+		err := lockBlockHeight(ctx, db, s.b)
+		if errors.Root(err) != s.want {
+			t.Errorf("step %d: lockBlockHeight(height %d) = %v want %v", i, s.b.Height, err, s.want)
+		}
+	}
+}
+
+func TestUnlockHeights(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.NewTx(t)
+
+	b1 := &bc.Block{BlockHeader: bc.BlockHeader{Height: 1, TimestampMS: 1}}
+	b2 := &bc.Block{BlockHeader: bc.BlockHeader{Height: 2, TimestampMS: 2, PreviousBlockHash: b1.Hash()}}
+	b3 := &bc.Block{BlockHeader: bc.BlockHeader{Height: 3, TimestampMS: 3, PreviousBlockHash: b2.Hash()}}
+	for _, b := range []*bc.Block{b1, b2, b3} {
+		err := lockBlockHeight(ctx, db, b)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+	}
+
+	n, err := UnlockHeights(ctx, db, 2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if n != 2 {
+		t.Errorf("UnlockHeights(2) = %d want 2", n)
+	}
+
+	// With heights 2 and up forgotten, the signer can
+	// sign a different block at height 2, but still
+	// only one that extends the block at height 1.
+	b2other := &bc.Block{BlockHeader: bc.BlockHeader{Height: 2, TimestampMS: 4, PreviousBlockHash: b1.Hash()}}
+	err = lockBlockHeight(ctx, db, b2other)
+	if err != nil {
+		t.Errorf("lockBlockHeight(b2other) after unlock = %v want nil", err)
+	}
+	b2fork := &bc.Block{BlockHeader: bc.BlockHeader{Height: 2, TimestampMS: 5}}
+	_, err = UnlockHeights(ctx, db, 2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = lockBlockHeight(ctx, db, b2fork)
+	if errors.Root(err) != ErrDoubleSign {
+		t.Errorf("lockBlockHeight(b2fork) = %v want %v", err, ErrDoubleSign)
+	}
+}
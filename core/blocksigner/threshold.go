@@ -0,0 +1,103 @@
+package blocksigner
+
+import (
+	"bytes"
+	"context"
+
+	"chain/crypto/ed25519/threshold"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// ErrNoShare is returned from CommitBlock and SignBlockShare
+// when the signer holds no share of a threshold key.
+var ErrNoShare = errors.New("signer holds no threshold key share")
+
+// ErrNoCommitment is returned from SignBlockShare when the
+// signer has no outstanding commitment for the block.
+var ErrNoCommitment = errors.New("no commitment for block")
+
+// ShareRequest is the body of a request
+// for a signer's share of a block signature.
+type ShareRequest struct {
+	Block       *bc.Block              `json:"block"`
+	Commitments []threshold.Commitment `json:"commitments"`
+}
+
+type blockNonce struct {
+	height uint64
+	nonce  *threshold.Nonce
+}
+
+// SetShare sets the signer's share of a threshold key, whose
+// group key must be s.Pub. With a share, s signs blocks in two
+// rounds, with CommitBlock and SignBlockShare, instead of with
+// ValidateAndSignBlock.
+func (s *BlockSigner) SetShare(share *threshold.Share) error {
+	if !bytes.Equal(share.GroupKey[:], s.Pub) {
+		return errors.WithDetailf(ErrInvalidKey, "share is for group key %x, not %x", share.GroupKey[:], []byte(s.Pub))
+	}
+	s.share = share
+	s.nonces = make(map[bc.Hash]*blockNonce)
+	return nil
+}
+
+// CommitBlock validates b as ValidateAndSignBlock does, then
+// makes a fresh signing nonce for it and returns the nonce's
+// commitment. It is the first round of threshold signing, and
+// is used as the httpjson handler for
+// /rpc/signer/threshold-commit.
+//
+// The nonce is kept in memory until SignBlockShare uses it or
+// a block at a greater height is committed to.
+func (s *BlockSigner) CommitBlock(ctx context.Context, b *bc.Block) (threshold.Commitment, error) {
+	if s.share == nil {
+		return threshold.Commitment{}, errors.Wrap(ErrNoShare)
+	}
+	err := s.validateAndLock(ctx, b)
+	if err != nil {
+		return threshold.Commitment{}, err
+	}
+	nonce, err := s.share.NewNonce(nil)
+	if err != nil {
+		return threshold.Commitment{}, errors.Wrap(err, "making nonce")
+	}
+
+	s.nonceMu.Lock()
+	defer s.nonceMu.Unlock()
+	for hash, n := range s.nonces {
+		if n.height < b.Height {
+			delete(s.nonces, hash)
+		}
+	}
+	s.nonces[b.Hash()] = &blockNonce{height: b.Height, nonce: nonce}
+	return nonce.Commitment(), nil
+}
+
+// SignBlockShare returns the signer's share of the signature
+// of req.Block, given the commitments of all the signers taking
+// part. It is the second round of threshold signing, and is
+// used as the httpjson handler for /rpc/signer/threshold-sign.
+//
+// The signer must have committed to the block with CommitBlock,
+// and it signs with each commitment's nonce only once.
+func (s *BlockSigner) SignBlockShare(ctx context.Context, req *ShareRequest) (threshold.Scalar, error) {
+	if s.share == nil {
+		return threshold.Scalar{}, errors.Wrap(ErrNoShare)
+	}
+	if req.Block == nil {
+		return threshold.Scalar{}, errors.WithDetail(ErrNoCommitment, "no block given")
+	}
+	hash := req.Block.Hash()
+
+	s.nonceMu.Lock()
+	n := s.nonces[hash]
+	delete(s.nonces, hash)
+	s.nonceMu.Unlock()
+	if n == nil {
+		return threshold.Scalar{}, errors.WithDetailf(ErrNoCommitment, "block %x", hash[:])
+	}
+
+	z, err := s.share.Sign(hash[:], n.nonce, req.Commitments)
+	return z, errors.Wrap(err, "signing share")
+}
@@ -0,0 +1,123 @@
+package blocksigner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/threshold"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestThresholdSign(t *testing.T) {
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	prev, snapshot := c.State()
+	b, _, err := c.GenerateBlock(ctx, prev, snapshot, time.Now(), nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	hash := b.Hash()
+
+	groupKey, shares, err := threshold.Deal(2, 3, nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// Each signer keeps its own record of signed blocks.
+	var signers []*BlockSigner
+	for _, share := range shares[:2] {
+		s := New(groupKey, nil, pgtest.NewTx(t), c)
+		err = s.SetShare(share)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		signers = append(signers, s)
+	}
+
+	var commitments []threshold.Commitment
+	for _, s := range signers {
+		cm, err := s.CommitBlock(ctx, b)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		commitments = append(commitments, cm)
+	}
+	req := &ShareRequest{Block: b, Commitments: commitments}
+	var zs []threshold.Scalar
+	for _, s := range signers {
+		z, err := s.SignBlockShare(ctx, req)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		zs = append(zs, z)
+	}
+
+	sig, err := threshold.Aggregate(groupKey, hash[:], commitments, zs, shares[0].PublicShares)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !ed25519.Verify(groupKey, hash[:], sig) {
+		t.Error("aggregate signature does not verify")
+	}
+
+	// Each commitment's nonce signs only once.
+	_, err = signers[0].SignBlockShare(ctx, req)
+	if errors.Root(err) != ErrNoCommitment {
+		t.Errorf("second SignBlockShare = %v want %v", err, ErrNoCommitment)
+	}
+}
+
+func TestThresholdNoCommitment(t *testing.T) {
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	groupKey, shares, err := threshold.Deal(1, 1, nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	s := New(groupKey, nil, nil, c)
+
+	b := &bc.Block{BlockHeader: bc.BlockHeader{Height: 2}}
+	_, err = s.CommitBlock(ctx, b)
+	if errors.Root(err) != ErrNoShare {
+		t.Errorf("CommitBlock without share = %v want %v", err, ErrNoShare)
+	}
+	_, err = s.SignBlockShare(ctx, &ShareRequest{Block: b})
+	if errors.Root(err) != ErrNoShare {
+		t.Errorf("SignBlockShare without share = %v want %v", err, ErrNoShare)
+	}
+
+	err = s.SetShare(shares[0])
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	_, err = s.SignBlockShare(ctx, &ShareRequest{Block: b})
+	if errors.Root(err) != ErrNoCommitment {
+		t.Errorf("SignBlockShare without commitment = %v want %v", err, ErrNoCommitment)
+	}
+	_, err = s.SignBlockShare(ctx, &ShareRequest{})
+	if errors.Root(err) != ErrNoCommitment {
+		t.Errorf("SignBlockShare without block = %v want %v", err, ErrNoCommitment)
+	}
+}
+
+func TestSetShareWrongKey(t *testing.T) {
+	_, shares, err := threshold.Deal(1, 1, nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	otherKey, _, err := threshold.Deal(1, 1, nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	s := New(otherKey, nil, nil, nil)
+	err = s.SetShare(shares[0])
+	if errors.Root(err) != ErrInvalidKey {
+		t.Errorf("SetShare(share of another key) = %v want %v", err, ErrInvalidKey)
+	}
+}
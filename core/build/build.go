@@ -0,0 +1,82 @@
+// Package build describes how the running binary was built.
+//
+// The variables in this package are set by the linker.
+// See bin/build-cored-release for how release builds
+// compute them reproducibly:
+//
+//	go build -ldflags "-X chain/core/build.Commit=$commit ..."
+//
+// Two builds with the same commit, dependency digest,
+// and Go version have the same consensus digest and so
+// validate blocks identically.
+package build
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"runtime"
+
+	"chain/errors"
+)
+
+// Build variables, initialized by the linker.
+var (
+	Tag    = "?" // release tag, e.g. chain-core-server-1.1.3
+	Commit = "?" // VCS commit hash
+	Date   = "?" // build time
+	Deps   = "?" // digest of the vendored dependency tree
+)
+
+// ErrMismatch is returned when two builds
+// have different consensus digests.
+var ErrMismatch = errors.New("consensus-relevant build mismatch")
+
+// Info describes a build.
+type Info struct {
+	Tag       string `json:"build_tag"`
+	Commit    string `json:"build_commit"`
+	Date      string `json:"build_date"`
+	Deps      string `json:"build_deps"`
+	GoVersion string `json:"go_version"`
+
+	// ConsensusDigest identifies the parts of the build
+	// that can affect consensus. See Info.Digest.
+	ConsensusDigest string `json:"consensus_digest"`
+}
+
+// Current returns information about the running binary.
+func Current() Info {
+	i := Info{
+		Tag:       Tag,
+		Commit:    Commit,
+		Date:      Date,
+		Deps:      Deps,
+		GoVersion: runtime.Version(),
+	}
+	i.ConsensusDigest = i.Digest()
+	return i
+}
+
+// Digest computes a digest of the source commit,
+// the dependency digest, and the Go version.
+// It omits the tag and build date, which
+// don't affect the behavior of the binary.
+func (i Info) Digest() string {
+	h := sha256.New()
+	for _, s := range []string{i.Commit, i.Deps, i.GoVersion} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Compare returns ErrMismatch if other is not
+// consensus-equivalent to i. It recomputes other's
+// digest rather than trusting the reported one.
+func (i Info) Compare(other Info) error {
+	if i.Digest() != other.Digest() {
+		return errors.WithDetailf(ErrMismatch, "commit %s deps %s go %s, want commit %s deps %s go %s",
+			other.Commit, other.Deps, other.GoVersion, i.Commit, i.Deps, i.GoVersion)
+	}
+	return nil
+}
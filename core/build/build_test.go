@@ -0,0 +1,37 @@
+package build
+
+import (
+	"testing"
+
+	"chain/errors"
+)
+
+func TestCompare(t *testing.T) {
+	a := Info{Tag: "v1", Commit: "abc", Date: "1", Deps: "d", GoVersion: "go1.8"}
+	cases := []struct {
+		b    Info
+		want error
+	}{
+		{a, nil},
+		{Info{Tag: "v2", Commit: "abc", Date: "2", Deps: "d", GoVersion: "go1.8"}, nil},
+		{Info{Commit: "abd", Deps: "d", GoVersion: "go1.8"}, ErrMismatch},
+		{Info{Commit: "abc", Deps: "e", GoVersion: "go1.8"}, ErrMismatch},
+		{Info{Commit: "abc", Deps: "d", GoVersion: "go1.8.1"}, ErrMismatch},
+
+		// Field boundaries must be unambiguous.
+		{Info{Commit: "ab", Deps: "cd", GoVersion: "go1.8"}, ErrMismatch},
+	}
+	for i, c := range cases {
+		got := a.Compare(c.b)
+		if errors.Root(got) != c.want {
+			t.Errorf("case %d: Compare = %v want %v", i, got, c.want)
+		}
+	}
+}
+
+func TestCurrentDigest(t *testing.T) {
+	i := Current()
+	if i.ConsensusDigest != i.Digest() {
+		t.Errorf("ConsensusDigest = %s want %s", i.ConsensusDigest, i.Digest())
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"time"
 
+	"chain/core/pkcs11hsm"
 	"chain/core/rpc"
 	"chain/core/txdb"
 	"chain/crypto/ed25519"
@@ -33,26 +34,46 @@ var (
 	ErrBadQuorum         = errors.New("quorum must be greater than 0 if there are signers")
 	ErrNoProdBlockPub    = errors.New("blockpub cannot be empty in production")
 	ErrNoProdBlockHSMURL = errors.New("block hsm URL cannot be empty in production")
+	ErrBadFinalHeight    = errors.New("final block height must not be below the current block height")
+	ErrNotConfigured     = errors.New("core is not configured")
+	ErrBadOperational    = errors.New("invalid operational settings")
 
 	Version, BuildCommit, BuildDate string
 	Production                      bool
 )
 
 // Config encapsulates Core-level, persistent configuration options.
+//
+// The fields of Config, other than those of Operational,
+// identify the blockchain and the core's role on it.
+// They are fixed when the core is configured.
 type Config struct {
-	ID                   string  `json:"id"`
-	IsSigner             bool    `json:"is_signer"`
-	IsGenerator          bool    `json:"is_generator"`
-	BlockchainID         bc.Hash `json:"blockchain_id"`
-	GeneratorURL         string  `json:"generator_url"`
-	GeneratorAccessToken string  `json:"generator_access_token"`
-	BlockHSMURL          string  `json:"block_hsm_url"`
-	BlockHSMAccessToken  string  `json:"block_hsm_access_token"`
-	ConfiguredAt         time.Time
-	BlockPub             string        `json:"block_pub"`
-	Signers              []BlockSigner `json:"block_signer_urls"`
-	Quorum               int
+	ID                  string  `json:"id"`
+	IsSigner            bool    `json:"is_signer"`
+	IsGenerator         bool    `json:"is_generator"`
+	BlockchainID        bc.Hash `json:"blockchain_id"`
+	BlockHSMURL         string  `json:"block_hsm_url"`
+	BlockHSMAccessToken string  `json:"block_hsm_access_token"`
+	ConfiguredAt        time.Time
+	BlockPub            string        `json:"block_pub"`
+	Signers             []BlockSigner `json:"block_signer_urls"`
+	Quorum              int
+	FinalBlockHeight    uint64 `json:"final_block_height"`
+
+	Operational
+}
+
+// Operational holds the settings of a configured core
+// that can change without configuring it again.
+// See UpdateOperational.
+type Operational struct {
+	GeneratorURL         string `json:"generator_url"`
+	GeneratorAccessToken string `json:"generator_access_token"`
 	MaxIssuanceWindow    chainjson.Duration
+
+	// BlockPeriod is how often a generator makes blocks.
+	// If zero, cored's default is used.
+	BlockPeriod chainjson.Duration `json:"block_period"`
 }
 
 type BlockSigner struct {
@@ -67,14 +88,15 @@ func Load(ctx context.Context, db pg.DB) (*Config, error) {
 			SELECT id, is_signer, is_generator,
 			blockchain_id, generator_url, generator_access_token, block_pub,
 			block_hsm_url, block_hsm_access_token,
-			remote_block_signers, max_issuance_window_ms, configured_at
+			remote_block_signers, max_issuance_window_ms, final_block_height,
+			configured_at, block_period_ms
 			FROM config
 		`
 
 	c := new(Config)
 	var (
 		blockSignerData []byte
-		miw             int64
+		miw, period     int64
 	)
 	err := db.QueryRow(ctx, q).Scan(
 		&c.ID,
@@ -88,7 +110,9 @@ func Load(ctx context.Context, db pg.DB) (*Config, error) {
 		&c.BlockHSMAccessToken,
 		&blockSignerData,
 		&miw,
+		&c.FinalBlockHeight,
 		&c.ConfiguredAt,
+		&period,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -104,6 +128,7 @@ func Load(ctx context.Context, db pg.DB) (*Config, error) {
 	}
 
 	c.MaxIssuanceWindow = chainjson.Duration{time.Duration(miw) * time.Millisecond}
+	c.BlockPeriod = chainjson.Duration{Duration: time.Duration(period) * time.Millisecond}
 	return c, nil
 }
 
@@ -112,9 +137,14 @@ func Load(ctx context.Context, db pg.DB) (*Config, error) {
 // the caller must ensure that the new configuration is properly reloaded,
 // for example by restarting the process.
 //
-// When running in non-production mode, if c.IsSigner is true and c.BlockPub is empty,
-// Configure generates a new mockhsm keypair
-// for signing blocks, and assigns it to c.BlockPub.
+// If c.IsSigner is true and c.BlockPub is empty, Configure gets
+// a block-signing keypair from the HSM at c.BlockHSMURL, creating
+// it if need be, and assigns its pubkey to c.BlockPub.
+// When running in non-production mode with no c.BlockHSMURL,
+// Configure instead generates a new mockhsm keypair.
+//
+// Configure saves the new configuration as the first version
+// in the configuration history; see History and Rollback.
 //
 // If c.IsGenerator is true, Configure creates an initial block,
 // saves it, and assigns its hash to c.BlockchainID.
@@ -141,7 +171,14 @@ func Configure(ctx context.Context, db pg.DB, c *Config) error {
 		if err != nil {
 			return err
 		}
-		if c.BlockPub == "" {
+		if c.BlockPub == "" && pkcs11hsm.IsURI(c.BlockHSMURL) {
+			return errors.WithDetail(ErrBadBlockPub, "a PKCS#11 block hsm needs a block pub")
+		} else if c.BlockPub == "" && c.BlockHSMURL != "" {
+			blockPub, err = getOrCreateHSMKey(ctx, c)
+			if err != nil {
+				return err
+			}
+		} else if c.BlockPub == "" {
 			blockPub, err = getOrCreateDevKey(ctx, db, c)
 			if err != nil {
 				return err
@@ -164,6 +201,9 @@ func Configure(ctx context.Context, db pg.DB, c *Config) error {
 			if len(signer.Pubkey) != ed25519.PublicKeySize {
 				return errors.Sub(ErrBadSignerPubkey, err)
 			}
+			if hasKey(signingKeys, signer.Pubkey) {
+				continue // another share of a threshold key
+			}
 			signingKeys = append(signingKeys, ed25519.PublicKey(signer.Pubkey))
 		}
 
@@ -212,8 +252,9 @@ func Configure(ctx context.Context, db pg.DB, c *Config) error {
 		INSERT INTO config (id, is_signer, block_pub, is_generator,
 			blockchain_id, generator_url, generator_access_token,
 			block_hsm_url, block_hsm_access_token,
-			remote_block_signers, max_issuance_window_ms, configured_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW())
+			remote_block_signers, max_issuance_window_ms, final_block_height,
+			block_period_ms, configured_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, NOW())
 	`
 	_, err = db.Exec(
 		ctx,
@@ -229,8 +270,119 @@ func Configure(ctx context.Context, db pg.DB, c *Config) error {
 		c.BlockHSMAccessToken,
 		blockSignerData,
 		bc.DurationMillis(c.MaxIssuanceWindow.Duration),
+		c.FinalBlockHeight,
+		bc.DurationMillis(c.BlockPeriod.Duration),
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	return saveVersion(ctx, db)
+}
+
+// UpdateOperational replaces the operational settings of
+// a configured core, leaving the rest of its configuration,
+// and its data, as they are. It saves the result as a new
+// version in the configuration history. Like Configure,
+// it takes effect when cored restarts.
+//
+// A generator has no generator URL or access token.
+// For other cores, UpdateOperational makes a test request
+// to the new generator URL, as Configure does.
+func UpdateOperational(ctx context.Context, db pg.DB, op Operational) error {
+	c, err := Load(ctx, db)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return ErrNotConfigured
+	}
+	if op.MaxIssuanceWindow.Duration < 0 || op.BlockPeriod.Duration < 0 {
+		return errors.WithDetail(ErrBadOperational, "durations must not be negative")
+	}
+	if c.IsGenerator {
+		if op.GeneratorURL != "" || op.GeneratorAccessToken != "" {
+			return errors.WithDetail(ErrBadOperational, "a generator has no generator url or access token")
+		}
+	} else {
+		err = tryGenerator(ctx, op.GeneratorURL, op.GeneratorAccessToken, c.BlockchainID.String())
+		if err != nil {
+			return err
+		}
+	}
+
+	const q = `
+		UPDATE config SET generator_url=$1, generator_access_token=$2,
+			max_issuance_window_ms=$3, block_period_ms=$4
+	`
+	_, err = db.Exec(
+		ctx,
+		q,
+		op.GeneratorURL,
+		op.GeneratorAccessToken,
+		bc.DurationMillis(op.MaxIssuanceWindow.Duration),
+		bc.DurationMillis(op.BlockPeriod.Duration),
+	)
+	if err != nil {
+		return errors.Wrap(err, "updating operational settings")
+	}
+	return saveVersion(ctx, db)
+}
+
+// SetFinalBlockHeight records height as the last block height
+// the blockchain will produce or accept, or removes the limit
+// if height is zero. Every core on the network must be given
+// the same final height, and each must be restarted for
+// the change to take effect.
+//
+// It is an error to set a final height below the current
+// block height.
+func SetFinalBlockHeight(ctx context.Context, db pg.DB, height uint64) error {
+	cur, err := txdb.NewStore(db).Height(ctx)
+	if err != nil {
+		return err
+	}
+	if height != 0 && height < cur {
+		return errors.WithDetailf(ErrBadFinalHeight, "current height is %d", cur)
+	}
+
+	const q = `UPDATE config SET final_block_height = $1`
+	res, err := db.Exec(ctx, q, height)
+	if err != nil {
+		return errors.Wrap(err, "updating final block height")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	if n == 0 {
+		return ErrNotConfigured
+	}
+	return saveVersion(ctx, db)
+}
+
+// getOrCreateHSMKey asks the block HSM at c.BlockHSMURL
+// for its block-signing key, creating the key if the HSM
+// has none yet, and assigns its pubkey to c.BlockPub.
+func getOrCreateHSMKey(ctx context.Context, c *Config) (ed25519.PublicKey, error) {
+	client := &rpc.Client{
+		BaseURL:     c.BlockHSMURL,
+		AccessToken: c.BlockHSMAccessToken,
+	}
+	req := struct {
+		Alias string `json:"alias"`
+	}{autoBlockKeyAlias}
+	var resp struct {
+		Pub chainjson.HexBytes `json:"pubkey"`
+	}
+	err := client.Call(ctx, "/create-block-key", req, &resp)
+	if err != nil {
+		return nil, errors.Sub(ErrBadBlockHSM, err)
+	}
+	if len(resp.Pub) != ed25519.PublicKeySize {
+		return nil, errors.WithDetailf(ErrBadBlockPub, "block hsm returned pubkey %x", []byte(resp.Pub))
+	}
+	c.BlockPub = hex.EncodeToString(resp.Pub)
+	return ed25519.PublicKey(resp.Pub), nil
 }
 
 func tryGenerator(ctx context.Context, url, accessToken, blockchainID string) error {
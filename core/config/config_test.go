@@ -0,0 +1,114 @@
+package config
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"chain/crypto/ed25519"
+	"chain/database/pg/pgtest"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/testutil"
+)
+
+func TestUpdateOperational(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.NewTx(t)
+
+	err := UpdateOperational(ctx, db, Operational{})
+	if errors.Root(err) != ErrNotConfigured {
+		t.Errorf("UpdateOperational before Configure = %v want %v", err, ErrNotConfigured)
+	}
+
+	pgtest.Exec(ctx, db, t, `
+		INSERT INTO config (id, is_signer, is_generator, blockchain_id, configured_at,
+			block_pub, final_block_height)
+		VALUES ('c1', true, true, $1, NOW(), 'abcd', 50)
+	`, bc.Hash{1})
+
+	bad := []Operational{
+		{MaxIssuanceWindow: chainjson.Duration{Duration: -time.Second}},
+		{BlockPeriod: chainjson.Duration{Duration: -time.Second}},
+		{GeneratorURL: "https://gen.example"}, // a generator has none
+		{GeneratorAccessToken: "gen:secret"},
+	}
+	for i, op := range bad {
+		err := UpdateOperational(ctx, db, op)
+		if errors.Root(err) != ErrBadOperational {
+			t.Errorf("case %d: UpdateOperational() = %v want %v", i, err, ErrBadOperational)
+		}
+	}
+
+	op := Operational{
+		MaxIssuanceWindow: chainjson.Duration{Duration: time.Hour},
+		BlockPeriod:       chainjson.Duration{Duration: 2 * time.Second},
+	}
+	err = UpdateOperational(ctx, db, op)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	c, err := Load(ctx, db)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !testutil.DeepEqual(c.Operational, op) {
+		t.Errorf("operational settings = %+v want %+v", c.Operational, op)
+	}
+
+	// The rest of the configuration is unchanged.
+	if c.ID != "c1" || !c.IsSigner || c.BlockPub != "abcd" || c.FinalBlockHeight != 50 {
+		t.Errorf("config after UpdateOperational = %+v", c)
+	}
+}
+
+func TestGetOrCreateHSMKey(t *testing.T) {
+	ctx := context.Background()
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var respPub chainjson.HexBytes
+	hsm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		user, pw, _ := req.BasicAuth()
+		if req.URL.Path != "/create-block-key" || user+":"+pw != "hsm:secret" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		var body struct{ Alias string }
+		json.NewDecoder(req.Body).Decode(&body)
+		if body.Alias != autoBlockKeyAlias {
+			http.Error(w, "bad alias", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"pubkey": respPub})
+	}))
+	defer hsm.Close()
+
+	respPub = chainjson.HexBytes(pub)
+	c := &Config{BlockHSMURL: hsm.URL, BlockHSMAccessToken: "hsm:secret"}
+	got, err := getOrCreateHSMKey(ctx, c)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !testutil.DeepEqual(got, pub) || c.BlockPub != hex.EncodeToString(pub) {
+		t.Errorf("getOrCreateHSMKey() = %x, block pub %s, want %x", got, c.BlockPub, pub)
+	}
+
+	respPub = chainjson.HexBytes(pub[:16])
+	_, err = getOrCreateHSMKey(ctx, &Config{BlockHSMURL: hsm.URL, BlockHSMAccessToken: "hsm:secret"})
+	if errors.Root(err) != ErrBadBlockPub {
+		t.Errorf("short pubkey: got %v want %v", err, ErrBadBlockPub)
+	}
+
+	_, err = getOrCreateHSMKey(ctx, &Config{BlockHSMURL: hsm.URL, BlockHSMAccessToken: "hsm:wrong"})
+	if errors.Root(err) != ErrBadBlockHSM {
+		t.Errorf("wrong token: got %v want %v", err, ErrBadBlockHSM)
+	}
+}
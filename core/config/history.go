@@ -0,0 +1,181 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"chain/core/txdb"
+	"chain/database/pg"
+	"chain/database/sql"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+var (
+	ErrNoVersion  = errors.New("no such config version")
+	ErrOtherChain = errors.New("config version is for a different blockchain")
+)
+
+// SavedConfig is a version of the configuration, as saved
+// by Configure, UpdateOperational, SetFinalBlockHeight,
+// or Rollback.
+// Versions are numbered in the order they were saved.
+// Access tokens are not saved; see Rollback.
+type SavedConfig struct {
+	Version uint64    `json:"version"`
+	SavedAt time.Time `json:"saved_at"`
+	Config  *Config   `json:"config"`
+}
+
+// History returns every saved version
+// of the configuration, oldest first.
+func History(ctx context.Context, db pg.DB) ([]*SavedConfig, error) {
+	const q = `SELECT version, saved_at, data FROM config_history ORDER BY version`
+	var versions []*SavedConfig
+	err := pg.ForQueryRows(ctx, db, q, func(version uint64, savedAt time.Time, data []byte) error {
+		v := &SavedConfig{Version: version, SavedAt: savedAt, Config: new(Config)}
+		err := json.Unmarshal(data, v.Config)
+		if err != nil {
+			return errors.Wrapf(err, "decoding config version %d", version)
+		}
+		versions = append(versions, v)
+		return nil
+	})
+	return versions, errors.Wrap(err, "loading config history")
+}
+
+// Rollback restores the configuration saved as the given
+// version, and saves the result as a new version, so that
+// a rollback can itself be undone. Like Configure, it takes
+// effect when cored restarts.
+//
+// A version can be restored only onto the blockchain it
+// was saved for, and only if its final block height, if
+// any, has not been passed.
+//
+// Saved versions hold no access tokens. The restored
+// configuration keeps the stored token for each URL,
+// generator, block HSM, or signer, that it shares with
+// the current configuration. A URL new to the current
+// configuration gets no token.
+func Rollback(ctx context.Context, db pg.DB, version uint64) error {
+	const q = `SELECT data FROM config_history WHERE version=$1`
+	var data []byte
+	err := db.QueryRow(ctx, q, version).Scan(&data)
+	if err == sql.ErrNoRows {
+		return errors.WithDetailf(ErrNoVersion, "version %d", version)
+	} else if err != nil {
+		return errors.Wrap(err, "loading config version")
+	}
+	c := new(Config)
+	err = json.Unmarshal(data, c)
+	if err != nil {
+		return errors.Wrapf(err, "decoding config version %d", version)
+	}
+
+	cur, err := Load(ctx, db)
+	if err != nil {
+		return err
+	}
+	if cur == nil {
+		return ErrNotConfigured
+	}
+	if cur.BlockchainID != c.BlockchainID {
+		return errors.WithDetailf(ErrOtherChain, "version %d is for blockchain %s", version, c.BlockchainID)
+	}
+	keepSecrets(c, cur)
+	if c.FinalBlockHeight != 0 {
+		height, err := txdb.NewStore(db).Height(ctx)
+		if err != nil {
+			return err
+		}
+		if c.FinalBlockHeight < height {
+			return errors.WithDetailf(ErrBadFinalHeight, "version %d has final height %d; current height is %d", version, c.FinalBlockHeight, height)
+		}
+	}
+
+	var blockSignerData []byte
+	if len(c.Signers) > 0 {
+		blockSignerData, err = json.Marshal(c.Signers)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+	}
+	const updateQ = `
+		UPDATE config SET id=$1, is_signer=$2, block_pub=$3, is_generator=$4,
+			generator_url=$5, generator_access_token=$6,
+			block_hsm_url=$7, block_hsm_access_token=$8,
+			remote_block_signers=$9, max_issuance_window_ms=$10,
+			final_block_height=$11, configured_at=$12, block_period_ms=$13
+	`
+	_, err = db.Exec(
+		ctx,
+		updateQ,
+		c.ID,
+		c.IsSigner,
+		c.BlockPub,
+		c.IsGenerator,
+		c.GeneratorURL,
+		c.GeneratorAccessToken,
+		c.BlockHSMURL,
+		c.BlockHSMAccessToken,
+		blockSignerData,
+		bc.DurationMillis(c.MaxIssuanceWindow.Duration),
+		c.FinalBlockHeight,
+		c.ConfiguredAt,
+		bc.DurationMillis(c.BlockPeriod.Duration),
+	)
+	if err != nil {
+		return errors.Wrap(err, "restoring config")
+	}
+	return saveVersion(ctx, db)
+}
+
+// saveVersion adds the stored configuration
+// to the history as its newest version.
+func saveVersion(ctx context.Context, db pg.DB) error {
+	c, err := Load(ctx, db)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return ErrNotConfigured
+	}
+	stripSecrets(c)
+	data, err := json.Marshal(c)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	const q = `INSERT INTO config_history (data) VALUES ($1)`
+	_, err = db.Exec(ctx, q, data)
+	return errors.Wrap(err, "saving config version")
+}
+
+// stripSecrets removes the access tokens from c.
+func stripSecrets(c *Config) {
+	c.GeneratorAccessToken = ""
+	c.BlockHSMAccessToken = ""
+	for i := range c.Signers {
+		c.Signers[i].AccessToken = ""
+	}
+}
+
+// keepSecrets copies into c the access tokens
+// of cur for the URLs that c and cur share.
+func keepSecrets(c, cur *Config) {
+	if c.GeneratorURL == cur.GeneratorURL {
+		c.GeneratorAccessToken = cur.GeneratorAccessToken
+	}
+	if c.BlockHSMURL == cur.BlockHSMURL {
+		c.BlockHSMAccessToken = cur.BlockHSMAccessToken
+	}
+	for i := range c.Signers {
+		for _, s := range cur.Signers {
+			if s.URL == c.Signers[i].URL {
+				c.Signers[i].AccessToken = s.AccessToken
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/testutil"
+)
+
+func TestHistoryRollback(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.NewTx(t)
+
+	signers, err := json.Marshal([]BlockSigner{{URL: "https://signer.example", AccessToken: "signer:secret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pgtest.Exec(ctx, db, t, `
+		INSERT INTO config (id, is_signer, is_generator, blockchain_id, configured_at,
+			block_hsm_url, block_hsm_access_token, remote_block_signers)
+		VALUES ('c1', false, true, $1, NOW(), 'https://hsm.example', 'hsm:secret', $2)
+	`, bc.Hash{1}, signers)
+
+	err = saveVersion(ctx, db)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = SetFinalBlockHeight(ctx, db, 100)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	versions, err := History(ctx, db)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("got %d versions, want 2", len(versions))
+	}
+	if versions[0].Version >= versions[1].Version {
+		t.Errorf("versions out of order: %d, %d", versions[0].Version, versions[1].Version)
+	}
+	if h := versions[1].Config.FinalBlockHeight; h != 100 {
+		t.Errorf("version %d final height = %d want 100", versions[1].Version, h)
+	}
+	for _, v := range versions {
+		c := v.Config
+		if c.BlockHSMAccessToken != "" || c.GeneratorAccessToken != "" || c.Signers[0].AccessToken != "" {
+			t.Errorf("version %d holds access tokens: %+v", v.Version, c)
+		}
+	}
+
+	// Rotate a token, then roll back past the final height.
+	// The rotated token must survive the rollback.
+	pgtest.Exec(ctx, db, t, `UPDATE config SET block_hsm_access_token='hsm:rotated'`)
+	err = Rollback(ctx, db, versions[0].Version)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	c, err := Load(ctx, db)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if c.FinalBlockHeight != 0 {
+		t.Errorf("final height after rollback = %d want 0", c.FinalBlockHeight)
+	}
+	if c.BlockHSMAccessToken != "hsm:rotated" {
+		t.Errorf("block HSM token after rollback = %q want %q", c.BlockHSMAccessToken, "hsm:rotated")
+	}
+	if len(c.Signers) != 1 || c.Signers[0].AccessToken != "signer:secret" {
+		t.Errorf("signers after rollback = %+v, want the stored token kept", c.Signers)
+	}
+
+	versions, err = History(ctx, db)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(versions) != 3 {
+		t.Errorf("got %d versions after rollback, want 3", len(versions))
+	}
+
+	err = Rollback(ctx, db, 1000)
+	if errors.Root(err) != ErrNoVersion {
+		t.Errorf("Rollback(1000) = %v want %v", err, ErrNoVersion)
+	}
+
+	pgtest.Exec(ctx, db, t, `UPDATE config SET blockchain_id=$1`, bc.Hash{2})
+	err = Rollback(ctx, db, versions[0].Version)
+	if errors.Root(err) != ErrOtherChain {
+		t.Errorf("Rollback onto another blockchain = %v want %v", err, ErrOtherChain)
+	}
+}
+
+func TestKeepSecrets(t *testing.T) {
+	cur := &Config{
+		BlockHSMURL:         "https://hsm.example",
+		BlockHSMAccessToken: "hsm:secret",
+		Signers: []BlockSigner{
+			{URL: "https://a.example", AccessToken: "a:secret"},
+			{URL: "https://b.example", AccessToken: "b:secret"},
+		},
+		Operational: Operational{
+			GeneratorURL:         "https://gen.example",
+			GeneratorAccessToken: "gen:secret",
+		},
+	}
+	c := &Config{
+		BlockHSMURL: "https://other-hsm.example",
+		Signers: []BlockSigner{
+			{URL: "https://b.example"},
+			{URL: "https://c.example"},
+		},
+		Operational: Operational{GeneratorURL: "https://gen.example"},
+	}
+	keepSecrets(c, cur)
+
+	want := &Config{
+		BlockHSMURL: "https://other-hsm.example",
+		Signers: []BlockSigner{
+			{URL: "https://b.example", AccessToken: "b:secret"},
+			{URL: "https://c.example"},
+		},
+		Operational: Operational{
+			GeneratorURL:         "https://gen.example",
+			GeneratorAccessToken: "gen:secret",
+		},
+	}
+	if !testutil.DeepEqual(c, want) {
+		t.Errorf("keepSecrets:\ngot:  %+v\nwant: %+v", c, want)
+	}
+}
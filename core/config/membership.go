@@ -0,0 +1,177 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"chain/core/txdb"
+	"chain/crypto/ed25519"
+	"chain/crypto/sha3pool"
+	"chain/database/pg"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/vmutil"
+)
+
+var (
+	ErrNoMembershipKey  = errors.New("only a generator that is also a block signer can sign a membership document")
+	ErrNoMembershipURL  = errors.New("generator has no public URL for membership documents")
+	ErrBadMembershipSig = errors.New("membership document signature is invalid")
+)
+
+// membershipPrefix separates signatures on membership
+// documents from those on anything else signed with
+// a block-signing key.
+const membershipPrefix = "chain core membership\x00"
+
+// Membership describes a blockchain network as it
+// stands at Height, for cores that are to join it.
+type Membership struct {
+	BlockchainID     bc.Hash            `json:"blockchain_id"`
+	GeneratorURL     string             `json:"generator_url"`
+	Height           uint64             `json:"block_height"`
+	ConsensusProgram chainjson.HexBytes `json:"consensus_program"`
+	Signers          []MemberSigner     `json:"block_signers"`
+	Quorum           int                `json:"quorum"`
+	IssuedAt         time.Time          `json:"issued_at"`
+}
+
+// MemberSigner is a block signer named in a Membership.
+// URL is empty if the generator does not know it, as for
+// the generator's own signing key.
+type MemberSigner struct {
+	Pubkey chainjson.HexBytes `json:"pubkey"`
+	URL    string             `json:"url,omitempty"`
+}
+
+// SignedMembership is a Membership, as JSON, signed with
+// the block-signing key of the generator that issued it.
+// The signature covers exactly the bytes of Membership.
+type SignedMembership struct {
+	Membership json.RawMessage    `json:"membership"`
+	Pubkey     chainjson.HexBytes `json:"pubkey"`
+	Signature  chainjson.HexBytes `json:"signature"`
+}
+
+// A MessageSigner signs messages with a block-signing key.
+type MessageSigner interface {
+	SignMessage(ctx context.Context, pub ed25519.PublicKey, msg []byte) ([]byte, error)
+}
+
+// ExportMembership describes the network of the generator
+// configured by c as of its latest block, and signs the
+// result with the generator's block-signing key, using hsm.
+// The block signers and the quorum are those of the latest
+// block's consensus program.
+// Access tokens for the signers are not included.
+// GeneratorURL must be configured, not taken from a
+// request, since the generator's key vouches for it.
+func ExportMembership(ctx context.Context, db pg.DB, c *Config, generatorURL string, hsm MessageSigner) (*SignedMembership, error) {
+	if !c.IsGenerator || !c.IsSigner || hsm == nil {
+		return nil, ErrNoMembershipKey
+	}
+	if generatorURL == "" {
+		return nil, ErrNoMembershipURL
+	}
+	pub, err := hex.DecodeString(c.BlockPub)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding block pub")
+	}
+
+	store := txdb.NewStore(db)
+	height, err := store.Height(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b, err := store.GetBlock(ctx, height)
+	if err != nil {
+		return nil, err
+	}
+	pubkeys, quorum, err := vmutil.ParseBlockMultiSigProgram(b.ConsensusProgram)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing consensus program")
+	}
+
+	// Look up signer URLs in the configuration
+	// and in every rotation, newest last.
+	urls := make(map[string]string)
+	known := c.Signers
+	rotations, err := Rotations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rotations {
+		known = append(known, r.Signers...)
+	}
+	for _, s := range known {
+		if s.URL != "" {
+			urls[hex.EncodeToString(s.Pubkey)] = s.URL
+		}
+	}
+
+	m := &Membership{
+		BlockchainID:     c.BlockchainID,
+		GeneratorURL:     generatorURL,
+		Height:           height,
+		ConsensusProgram: b.ConsensusProgram,
+		Quorum:           quorum,
+		IssuedAt:         time.Now().UTC(),
+	}
+	for _, k := range pubkeys {
+		m.Signers = append(m.Signers, MemberSigner{
+			Pubkey: chainjson.HexBytes(k),
+			URL:    urls[hex.EncodeToString(k)],
+		})
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	sig, err := hsm.SignMessage(ctx, pub, membershipMessage(data))
+	if err != nil {
+		return nil, errors.Wrap(err, "signing membership document")
+	}
+	return &SignedMembership{Membership: data, Pubkey: pub, Signature: sig}, nil
+}
+
+// VerifyMembership checks that doc was signed with pub,
+// which the caller obtained by other means, such as from
+// the generator's operator, and that pub is one of the
+// block signers the document names. The document alone
+// proves nothing: anyone can sign one with their own key.
+// It returns the Membership doc contains.
+func VerifyMembership(doc *SignedMembership, pub ed25519.PublicKey) (*Membership, error) {
+	if len(doc.Pubkey) != ed25519.PublicKeySize {
+		return nil, errors.WithDetailf(ErrBadMembershipSig, "pubkey %x", []byte(doc.Pubkey))
+	}
+	if !bytes.Equal(doc.Pubkey, pub) {
+		return nil, errors.WithDetailf(ErrBadMembershipSig, "signed by %x, not the expected key %x", []byte(doc.Pubkey), []byte(pub))
+	}
+	if !ed25519.Verify(ed25519.PublicKey(doc.Pubkey), membershipMessage(doc.Membership), doc.Signature) {
+		return nil, errors.Wrap(ErrBadMembershipSig)
+	}
+	m := new(Membership)
+	err := json.Unmarshal(doc.Membership, m)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding membership document")
+	}
+	for _, s := range m.Signers {
+		if bytes.Equal(s.Pubkey, doc.Pubkey) {
+			return m, nil
+		}
+	}
+	return nil, errors.WithDetail(ErrBadMembershipSig, "signed by a key that is not a block signer")
+}
+
+// membershipMessage returns the message signed
+// for a membership document encoded as data.
+func membershipMessage(data []byte) []byte {
+	var h [32]byte
+	sha3pool.Sum256(h[:], append([]byte(membershipPrefix), data...))
+	return h[:]
+}
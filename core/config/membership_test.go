@@ -0,0 +1,67 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+
+	"chain/crypto/ed25519"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+)
+
+func TestVerifyMembership(t *testing.T) {
+	pub, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, otherPrv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(prv ed25519.PrivateKey, signers ...ed25519.PublicKey) *SignedMembership {
+		m := &Membership{GeneratorURL: "https://generator.example", Quorum: 1}
+		for _, k := range signers {
+			m.Signers = append(m.Signers, MemberSigner{Pubkey: chainjson.HexBytes(k)})
+		}
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &SignedMembership{
+			Membership: data,
+			Pubkey:     chainjson.HexBytes(prv.Public().(ed25519.PublicKey)),
+			Signature:  ed25519.Sign(prv, membershipMessage(data)),
+		}
+	}
+
+	m, err := VerifyMembership(sign(prv, pub), pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.GeneratorURL != "https://generator.example" {
+		t.Errorf("generator URL = %q", m.GeneratorURL)
+	}
+
+	cases := []struct {
+		name string
+		doc  *SignedMembership
+	}{
+		// Valid on its own terms, but not from the expected key.
+		{"other key", sign(otherPrv, otherPub)},
+		{"not a signer", sign(prv, otherPub)},
+	}
+	tampered := sign(prv, pub)
+	tampered.Membership = json.RawMessage(`{"generator_url": "https://attacker.example"}`)
+	cases = append(cases, struct {
+		name string
+		doc  *SignedMembership
+	}{"tampered", tampered})
+
+	for _, c := range cases {
+		_, err := VerifyMembership(c.doc, pub)
+		if errors.Root(err) != ErrBadMembershipSig {
+			t.Errorf("%s: got error %v want %v", c.name, err, ErrBadMembershipSig)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"time"
+
+	chainjson "chain/encoding/json"
+	"chain/errors"
+)
+
+// Sources of the value of a setting, from lowest
+// precedence to highest. See Overrides.
+const (
+	SourceStored = "stored"
+	SourceEnv    = "env"
+	SourceFlag   = "flag"
+)
+
+// Setting is the effective value of one setting
+// of a configured core, and where it came from.
+type Setting struct {
+	Name   string      `json:"name"`
+	Value  interface{} `json:"value"`
+	Source string      `json:"source"`
+	Env    string      `json:"env"`
+	Flag   string      `json:"flag"`
+
+	// Secret is true if Value is a credential,
+	// and so should not be shown in full.
+	Secret bool `json:"-"`
+}
+
+// override is a setting that may be overridden
+// by an environment variable or a flag.
+type override struct {
+	name, env, flag, usage string
+	secret                 bool
+	get                    func(*Config) interface{}
+	set                    func(*Config, string) error
+}
+
+var overrides = []override{
+	{
+		name:  "generator_url",
+		env:   "GENERATOR_URL",
+		flag:  "generator-url",
+		usage: "generator `url`",
+		get:   func(c *Config) interface{} { return c.GeneratorURL },
+		set:   func(c *Config, v string) error { c.GeneratorURL = v; return nil },
+	},
+	{
+		name:   "generator_access_token",
+		env:    "GENERATOR_ACCESS_TOKEN",
+		flag:   "generator-access-token",
+		usage:  "generator access `token`",
+		secret: true,
+		get:    func(c *Config) interface{} { return c.GeneratorAccessToken },
+		set:    func(c *Config, v string) error { c.GeneratorAccessToken = v; return nil },
+	},
+	{
+		name:  "max_issuance_window",
+		env:   "MAX_ISSUANCE_WINDOW",
+		flag:  "max-issuance-window",
+		usage: "maximum issuance window `duration`",
+		get:   func(c *Config) interface{} { return c.MaxIssuanceWindow },
+		set:   func(c *Config, v string) error { return setDuration(&c.MaxIssuanceWindow, v) },
+	},
+	{
+		name:  "block_period",
+		env:   "BLOCK_PERIOD",
+		flag:  "block-period",
+		usage: "block period `duration`",
+		get:   func(c *Config) interface{} { return c.BlockPeriod },
+		set:   func(c *Config, v string) error { return setDuration(&c.BlockPeriod, v) },
+	},
+	{
+		name:  "block_hsm_url",
+		env:   "BLOCK_HSM_URL",
+		flag:  "block-hsm-url",
+		usage: "block hsm `url`",
+		get:   func(c *Config) interface{} { return c.BlockHSMURL },
+		set:   func(c *Config, v string) error { c.BlockHSMURL = v; return nil },
+	},
+	{
+		name:   "block_hsm_access_token",
+		env:    "BLOCK_HSM_ACCESS_TOKEN",
+		flag:   "block-hsm-access-token",
+		usage:  "block hsm access `token`",
+		secret: true,
+		get:    func(c *Config) interface{} { return c.BlockHSMAccessToken },
+		set:    func(c *Config, v string) error { c.BlockHSMAccessToken = v; return nil },
+	},
+}
+
+// Overrides holds values, from the environment and from
+// command-line flags, for settings that are otherwise
+// taken from the stored configuration. A flag takes
+// precedence over an environment variable, which takes
+// precedence over the stored value.
+//
+// Overrides are not saved; they last as long as the process.
+type Overrides struct {
+	Env   map[string]string // by variable name
+	Flags map[string]string // by flag name
+}
+
+// DefineFlags defines a flag in fs for each
+// setting that may be overridden.
+func DefineFlags(fs *flag.FlagSet) {
+	for _, o := range overrides {
+		fs.String(o.flag, "", "override the stored "+o.usage)
+	}
+}
+
+// LookupOverrides returns the overrides set in the
+// environment of the process, and by the flags in fs,
+// which must already be parsed. Flags not set are ignored,
+// as are those not defined by DefineFlags.
+func LookupOverrides(fs *flag.FlagSet) *Overrides {
+	o := &Overrides{
+		Env:   make(map[string]string),
+		Flags: make(map[string]string),
+	}
+	for _, ov := range overrides {
+		if v, ok := os.LookupEnv(ov.env); ok {
+			o.Env[ov.env] = v
+		}
+	}
+	fs.Visit(func(f *flag.Flag) {
+		o.Flags[f.Name] = f.Value.String()
+	})
+	return o
+}
+
+// Resolve applies o to c, and reports the effective
+// value of every setting that may be overridden,
+// and its source.
+func (o *Overrides) Resolve(c *Config) ([]Setting, error) {
+	var settings []Setting
+	for _, ov := range overrides {
+		source := SourceStored
+		if v, ok := o.Env[ov.env]; ok {
+			err := ov.set(c, v)
+			if err != nil {
+				return nil, errors.WithDetailf(ErrBadOperational, "environment variable %s: %s", ov.env, err)
+			}
+			source = SourceEnv
+		}
+		if v, ok := o.Flags[ov.flag]; ok {
+			err := ov.set(c, v)
+			if err != nil {
+				return nil, errors.WithDetailf(ErrBadOperational, "flag -%s: %s", ov.flag, err)
+			}
+			source = SourceFlag
+		}
+		settings = append(settings, Setting{
+			Name:   ov.name,
+			Value:  ov.get(c),
+			Source: source,
+			Env:    ov.env,
+			Flag:   "-" + ov.flag,
+			Secret: ov.secret,
+		})
+	}
+	return settings, nil
+}
+
+func setDuration(d *chainjson.Duration, v string) error {
+	x, err := time.ParseDuration(v)
+	if err != nil {
+		return err
+	}
+	if x < 0 {
+		return errors.New("duration must not be negative")
+	}
+	d.Duration = x
+	return nil
+}
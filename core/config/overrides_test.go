@@ -0,0 +1,96 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"testing"
+	"time"
+
+	chainjson "chain/encoding/json"
+	"chain/errors"
+)
+
+func TestResolve(t *testing.T) {
+	c := &Config{
+		BlockHSMURL:         "https://stored-hsm.example",
+		BlockHSMAccessToken: "hsm:stored",
+		Operational: Operational{
+			GeneratorURL:         "https://stored-gen.example",
+			GeneratorAccessToken: "gen:stored",
+			BlockPeriod:          chainjson.Duration{Duration: time.Second},
+		},
+	}
+	o := &Overrides{
+		Env: map[string]string{
+			"GENERATOR_URL":          "https://env-gen.example",
+			"GENERATOR_ACCESS_TOKEN": "gen:env",
+			"BLOCK_PERIOD":           "2s",
+		},
+		Flags: map[string]string{
+			"generator-url":          "https://flag-gen.example",
+			"block-hsm-access-token": "hsm:flag",
+		},
+	}
+	settings, err := o.Resolve(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]struct {
+		value  interface{}
+		source string
+		secret bool
+	}{
+		"generator_url":          {"https://flag-gen.example", SourceFlag, false},
+		"generator_access_token": {"gen:env", SourceEnv, true},
+		"max_issuance_window":    {chainjson.Duration{}, SourceStored, false},
+		"block_period":           {chainjson.Duration{Duration: 2 * time.Second}, SourceEnv, false},
+		"block_hsm_url":          {"https://stored-hsm.example", SourceStored, false},
+		"block_hsm_access_token": {"hsm:flag", SourceFlag, true},
+	}
+	if len(settings) != len(want) {
+		t.Fatalf("got %d settings, want %d", len(settings), len(want))
+	}
+	for _, s := range settings {
+		w := want[s.Name]
+		if s.Value != w.value || s.Source != w.source || s.Secret != w.secret {
+			t.Errorf("setting %s = %v from %s (secret %t), want %v from %s (secret %t)",
+				s.Name, s.Value, s.Source, s.Secret, w.value, w.source, w.secret)
+		}
+	}
+	if c.GeneratorURL != "https://flag-gen.example" || c.BlockHSMAccessToken != "hsm:flag" {
+		t.Errorf("Resolve did not apply overrides to the config: %+v", c)
+	}
+}
+
+func TestResolveBadValue(t *testing.T) {
+	cases := []*Overrides{
+		{Env: map[string]string{"BLOCK_PERIOD": "soon"}},
+		{Flags: map[string]string{"max-issuance-window": "-1s"}},
+	}
+	for i, o := range cases {
+		_, err := o.Resolve(new(Config))
+		if errors.Root(err) != ErrBadOperational {
+			t.Errorf("case %d: Resolve() = %v want %v", i, err, ErrBadOperational)
+		}
+	}
+}
+
+func TestLookupOverrides(t *testing.T) {
+	defer os.Unsetenv("GENERATOR_URL")
+	os.Setenv("GENERATOR_URL", "https://env-gen.example")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	DefineFlags(fs)
+	err := fs.Parse([]string{"-block-period", "3s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := LookupOverrides(fs)
+	if o.Env["GENERATOR_URL"] != "https://env-gen.example" {
+		t.Errorf("env GENERATOR_URL = %q", o.Env["GENERATOR_URL"])
+	}
+	if len(o.Flags) != 1 || o.Flags["block-period"] != "3s" {
+		t.Errorf("flags = %v want only block-period=3s", o.Flags)
+	}
+}
@@ -0,0 +1,307 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"time"
+
+	"chain/core/txdb"
+	"chain/crypto/ed25519"
+	"chain/database/pg"
+	"chain/database/sql"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/vmutil"
+)
+
+// ErrBadRotation is returned by ProposeRotation
+// and CancelRotation when the request is invalid.
+var ErrBadRotation = errors.New("invalid signer rotation")
+
+// Rotation is a scheduled change of the block signers
+// and quorum. The block at Height carries Program as its
+// consensus program, so the old signers sign that block
+// and the new ones sign every block after it.
+type Rotation struct {
+	Height     uint64             `json:"height"`
+	Signers    []BlockSigner      `json:"block_signers"`
+	Quorum     int                `json:"quorum"`
+	Program    chainjson.HexBytes `json:"consensus_program"`
+	ProposedAt time.Time          `json:"proposed_at"`
+
+	// AppliedBlock is the hash of the block at Height,
+	// once it is committed with Program; otherwise nil.
+	AppliedBlock *bc.Hash `json:"applied_block_hash,omitempty"`
+}
+
+// ProposeRotation schedules a change of the block signers
+// to signers, with the given quorum, at the given future
+// height. The generator and every signer that is to sign
+// the block at that height must be given the same signers
+// and quorum: a signer refuses to sign a block that changes
+// the consensus program unless the change is scheduled.
+// Signers need not have URLs on cores other than the generator.
+//
+// Only one rotation may be pending at a time.
+// The generator must restart for a new rotation to take
+// effect, so it can connect to the new signers.
+func ProposeRotation(ctx context.Context, db pg.DB, height uint64, signers []BlockSigner, quorum int) (*Rotation, error) {
+	c, err := Load(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, ErrNotConfigured
+	}
+	if !c.IsGenerator && !c.IsSigner {
+		return nil, errors.WithDetail(ErrBadRotation, "only a generator or a signer takes part in a rotation")
+	}
+
+	cur, err := txdb.NewStore(db).Height(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if height <= cur {
+		return nil, errors.WithDetailf(ErrBadRotation, "height %d is not after the current height %d", height, cur)
+	}
+	if c.FinalBlockHeight != 0 && height > c.FinalBlockHeight {
+		return nil, errors.WithDetailf(ErrBadRotation, "height %d is after the final height %d", height, c.FinalBlockHeight)
+	}
+
+	if len(signers) == 0 || quorum < 1 || quorum > len(signers) {
+		return nil, errors.WithDetailf(ErrBadQuorum, "quorum %d of %d signers", quorum, len(signers))
+	}
+	pubkeys := make([]ed25519.PublicKey, 0, len(signers))
+	for _, s := range signers {
+		if len(s.Pubkey) != ed25519.PublicKeySize {
+			return nil, errors.WithDetailf(ErrBadSignerPubkey, "pubkey %x", []byte(s.Pubkey))
+		}
+		if c.IsGenerator && !isLocalSigner(c, s) {
+			_, err = url.Parse(s.URL)
+			if err != nil || s.URL == "" {
+				return nil, errors.WithDetailf(ErrBadSignerURL, "signer %x has url %q", []byte(s.Pubkey), s.URL)
+			}
+		}
+		if !hasKey(pubkeys, s.Pubkey) {
+			pubkeys = append(pubkeys, ed25519.PublicKey(s.Pubkey))
+		}
+	}
+	if quorum > len(pubkeys) {
+		return nil, errors.WithDetailf(ErrBadQuorum, "quorum %d of %d signing keys", quorum, len(pubkeys))
+	}
+	program, err := vmutil.BlockMultiSigProgram(pubkeys, quorum)
+	if err != nil {
+		return nil, errors.Sub(ErrBadRotation, err)
+	}
+
+	pending, err := pendingRotation(ctx, db, cur)
+	if err != nil {
+		return nil, err
+	}
+	if pending != nil {
+		return nil, errors.WithDetailf(ErrBadRotation, "a rotation is already scheduled at height %d", pending.Height)
+	}
+
+	signerData, err := json.Marshal(signers)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	const q = `
+		INSERT INTO signer_rotations (height, signers, quorum, program)
+		VALUES ($1, $2, $3, $4)
+		RETURNING proposed_at
+	`
+	r := &Rotation{
+		Height:  height,
+		Signers: signers,
+		Quorum:  quorum,
+		Program: program,
+	}
+	err = db.QueryRow(ctx, q, height, signerData, quorum, program).Scan(&r.ProposedAt)
+	if err != nil {
+		return nil, errors.Wrap(err, "saving signer rotation")
+	}
+	return r, nil
+}
+
+// CancelRotation removes the pending rotation at height.
+// A rotation cannot be canceled once its block exists.
+func CancelRotation(ctx context.Context, db pg.DB, height uint64) error {
+	cur, err := txdb.NewStore(db).Height(ctx)
+	if err != nil {
+		return err
+	}
+	if height <= cur {
+		return errors.WithDetailf(ErrBadRotation, "block %d already exists", height)
+	}
+	const q = `DELETE FROM signer_rotations WHERE height=$1`
+	res, err := db.Exec(ctx, q, height)
+	if err != nil {
+		return errors.Wrap(err, "canceling signer rotation")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	if n == 0 {
+		return errors.WithDetailf(pg.ErrUserInputNotFound, "no rotation at height %d", height)
+	}
+	return nil
+}
+
+// Rotations returns every scheduled rotation, oldest first.
+func Rotations(ctx context.Context, db pg.DB) ([]*Rotation, error) {
+	return queryRotations(ctx, db, `TRUE`)
+}
+
+// ActiveSigners returns the remote signers the generator
+// must ask to sign blocks: those of the latest applied
+// rotation, or c.Signers if there is none, and those of
+// the pending rotation, if any.
+func ActiveSigners(ctx context.Context, db pg.DB, c *Config) ([]BlockSigner, error) {
+	active, err := activeSigners(ctx, db, c)
+	if err != nil {
+		return nil, err
+	}
+
+	var signers []BlockSigner
+	seen := make(map[string]bool)
+	for _, s := range active {
+		k := hex.EncodeToString(s.Pubkey)
+		if seen[k] || isLocalSigner(c, s) {
+			continue
+		}
+		seen[k] = true
+		signers = append(signers, s)
+	}
+	return signers, nil
+}
+
+// ThresholdSigners returns the remote signers holding shares
+// of the local block signing key, when it is a threshold key.
+// They are listed among the current or pending signers with
+// the local signer's pubkey and their own URLs.
+func ThresholdSigners(ctx context.Context, db pg.DB, c *Config) ([]BlockSigner, error) {
+	active, err := activeSigners(ctx, db, c)
+	if err != nil {
+		return nil, err
+	}
+
+	var signers []BlockSigner
+	seen := make(map[string]bool)
+	for _, s := range active {
+		if !isLocalSigner(c, s) || s.URL == "" || seen[s.URL] {
+			continue
+		}
+		seen[s.URL] = true
+		signers = append(signers, s)
+	}
+	return signers, nil
+}
+
+// activeSigners returns the current signers,
+// followed by those of the pending rotation.
+func activeSigners(ctx context.Context, db pg.DB, c *Config) ([]BlockSigner, error) {
+	rotations, err := Rotations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	current := c.Signers
+	var pending []BlockSigner
+	for _, r := range rotations {
+		if r.AppliedBlock != nil {
+			current = r.Signers
+		} else {
+			pending = r.Signers
+		}
+	}
+	return append(current, pending...), nil
+}
+
+// RotationSchedule provides the consensus program changes
+// scheduled in a core's database to its generator and
+// block signer.
+type RotationSchedule struct {
+	DB pg.DB
+}
+
+// ConsensusProgram returns the consensus program scheduled
+// for the block at height, or nil if there is none.
+func (rs *RotationSchedule) ConsensusProgram(ctx context.Context, height uint64) ([]byte, error) {
+	const q = `SELECT program FROM signer_rotations WHERE height=$1`
+	var program []byte
+	err := rs.DB.QueryRow(ctx, q, height).Scan(&program)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return program, errors.Wrap(err, "loading scheduled consensus program")
+}
+
+// Applied records that block b, at the height of
+// a scheduled rotation, carries its program.
+func (rs *RotationSchedule) Applied(ctx context.Context, b *bc.Block) error {
+	const q = `
+		UPDATE signer_rotations SET applied_block_hash=$2
+		WHERE height=$1 AND program=$3
+	`
+	_, err := rs.DB.Exec(ctx, q, b.Height, b.Hash(), b.ConsensusProgram)
+	return errors.Wrap(err, "recording signer rotation")
+}
+
+// pendingRotation returns the rotation scheduled
+// after height cur, if any.
+func pendingRotation(ctx context.Context, db pg.DB, cur uint64) (*Rotation, error) {
+	rotations, err := queryRotations(ctx, db, `height > $1`, cur)
+	if err != nil || len(rotations) == 0 {
+		return nil, err
+	}
+	return rotations[0], nil
+}
+
+func queryRotations(ctx context.Context, db pg.DB, where string, args ...interface{}) ([]*Rotation, error) {
+	q := `
+		SELECT height, signers, quorum, program, proposed_at, applied_block_hash
+		FROM signer_rotations WHERE ` + where + ` ORDER BY height
+	`
+	var rotations []*Rotation
+	err := pg.ForQueryRows(ctx, db, q, append(args, func(height uint64, signerData []byte, quorum int, program []byte, proposedAt time.Time, applied *bc.Hash) error {
+		r := &Rotation{
+			Height:       height,
+			Quorum:       quorum,
+			Program:      program,
+			ProposedAt:   proposedAt,
+			AppliedBlock: applied,
+		}
+		err := json.Unmarshal(signerData, &r.Signers)
+		if err != nil {
+			return errors.Wrapf(err, "decoding signers of rotation at height %d", height)
+		}
+		rotations = append(rotations, r)
+		return nil
+	})...)
+	return rotations, errors.Wrap(err, "loading signer rotations")
+}
+
+// isLocalSigner reports whether s is this core's own
+// block signing key.
+func isLocalSigner(c *Config, s BlockSigner) bool {
+	if !c.IsSigner {
+		return false
+	}
+	pub, err := hex.DecodeString(c.BlockPub)
+	return err == nil && bytes.Equal(pub, s.Pubkey)
+}
+
+// hasKey reports whether keys includes k.
+func hasKey(keys []ed25519.PublicKey, k []byte) bool {
+	for _, key := range keys {
+		if bytes.Equal(key, k) {
+			return true
+		}
+	}
+	return false
+}
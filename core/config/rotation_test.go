@@ -0,0 +1,170 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"chain/crypto/ed25519"
+	"chain/database/pg"
+	"chain/database/pg/pgtest"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/testutil"
+)
+
+func TestRotation(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.NewTx(t)
+
+	var pubs []ed25519.PublicKey
+	for i := 0; i < 4; i++ {
+		pub, _, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubs = append(pubs, pub)
+	}
+	signer := func(pub ed25519.PublicKey, url string) BlockSigner {
+		return BlockSigner{Pubkey: chainjson.HexBytes(pub), URL: url}
+	}
+
+	// The generator signs with pubs[0];
+	// pubs[1] is a remote signer.
+	oldSigners := []BlockSigner{signer(pubs[1], "https://one.example")}
+	signerData, err := json.Marshal(oldSigners)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pgtest.Exec(ctx, db, t, `
+		INSERT INTO config (id, is_signer, is_generator, blockchain_id, configured_at,
+			block_pub, remote_block_signers, final_block_height)
+		VALUES ('c1', true, true, $1, NOW(), $2, $3, 100)
+	`, bc.Hash{1}, hex.EncodeToString(pubs[0]), signerData)
+	c, err := Load(ctx, db)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	newSigners := []BlockSigner{
+		signer(pubs[0], ""),
+		signer(pubs[2], "https://two.example"),
+		signer(pubs[3], "https://three.example"),
+	}
+	bad := []struct {
+		height  uint64
+		signers []BlockSigner
+		quorum  int
+		want    error
+	}{
+		{0, newSigners, 2, ErrBadRotation},   // not after the current height
+		{101, newSigners, 2, ErrBadRotation}, // after the final height
+		{10, newSigners, 0, ErrBadQuorum},
+		{10, newSigners, 4, ErrBadQuorum},
+		{10, nil, 1, ErrBadQuorum},
+		{10, []BlockSigner{signer(pubs[2][:5], "https://two.example")}, 1, ErrBadSignerPubkey},
+		{10, []BlockSigner{signer(pubs[2], "")}, 1, ErrBadSignerURL},
+		// Shares of a threshold key count as one key.
+		{10, []BlockSigner{signer(pubs[2], "https://a.example"), signer(pubs[2], "https://b.example")}, 2, ErrBadQuorum},
+	}
+	for i, b := range bad {
+		_, err := ProposeRotation(ctx, db, b.height, b.signers, b.quorum)
+		if errors.Root(err) != b.want {
+			t.Errorf("case %d: ProposeRotation() = %v want %v", i, err, b.want)
+		}
+	}
+
+	r, err := ProposeRotation(ctx, db, 10, newSigners, 2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	_, err = ProposeRotation(ctx, db, 20, newSigners, 2)
+	if errors.Root(err) != ErrBadRotation {
+		t.Errorf("second pending rotation: got %v want %v", err, ErrBadRotation)
+	}
+
+	rs := &RotationSchedule{DB: db}
+	program, err := rs.ConsensusProgram(ctx, 10)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !bytes.Equal(program, r.Program) {
+		t.Errorf("scheduled program at 10 = %x want %x", program, []byte(r.Program))
+	}
+	program, err = rs.ConsensusProgram(ctx, 11)
+	if err != nil || program != nil {
+		t.Errorf("scheduled program at 11 = %x, %v want nil", program, err)
+	}
+
+	// Until the rotation is applied, the generator
+	// asks both the old and the new remote signers.
+	wantActive := func(want ...BlockSigner) {
+		active, err := ActiveSigners(ctx, db, c)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		if !testutil.DeepEqual(active, want) {
+			t.Errorf("active signers = %+v want %+v", active, want)
+		}
+	}
+	wantActive(oldSigners[0], newSigners[1], newSigners[2])
+
+	// A block with another program does not apply the rotation.
+	err = rs.Applied(ctx, &bc.Block{BlockHeader: bc.BlockHeader{Height: 10}})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	wantActive(oldSigners[0], newSigners[1], newSigners[2])
+
+	b := &bc.Block{BlockHeader: bc.BlockHeader{Height: 10}}
+	b.ConsensusProgram = r.Program
+	err = rs.Applied(ctx, b)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	rotations, err := Rotations(ctx, db)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(rotations) != 1 || rotations[0].AppliedBlock == nil || *rotations[0].AppliedBlock != b.Hash() {
+		t.Errorf("rotations after apply = %+v, want one applied at %x", rotations, b.Hash().Bytes())
+	}
+	wantActive(newSigners[1], newSigners[2])
+}
+
+func TestCancelRotation(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.NewTx(t)
+
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pgtest.Exec(ctx, db, t, `
+		INSERT INTO config (id, is_signer, is_generator, blockchain_id, configured_at)
+		VALUES ('c1', false, true, $1, NOW())
+	`, bc.Hash{1})
+	signers := []BlockSigner{{Pubkey: chainjson.HexBytes(pub), URL: "https://one.example"}}
+
+	_, err = ProposeRotation(ctx, db, 10, signers, 1)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = CancelRotation(ctx, db, 11)
+	if errors.Root(err) != pg.ErrUserInputNotFound {
+		t.Errorf("CancelRotation(11) = %v want %v", err, pg.ErrUserInputNotFound)
+	}
+	err = CancelRotation(ctx, db, 10)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// With the rotation canceled, another may be proposed.
+	_, err = ProposeRotation(ctx, db, 12, signers, 1)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+}
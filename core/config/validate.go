@@ -0,0 +1,138 @@
+package config
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"chain/core/pkcs11hsm"
+	"chain/crypto/ed25519"
+	"chain/errors"
+)
+
+var (
+	// ErrInvalidConfig is returned by Validate. Its data holds
+	// the problems found, under the key "problems", as a []error.
+	ErrInvalidConfig = errors.New("invalid configuration")
+
+	ErrBadBlockPub = errors.New("block pub is invalid")
+	ErrBadBlockHSM = errors.New("block hsm is unreachable")
+)
+
+// probeClient makes the reachability checks of Validate.
+// Its timeout keeps an unresponsive server from stalling
+// the check.
+var probeClient = &http.Client{Timeout: 5 * time.Second}
+
+// Validate checks c for mistakes before it is passed
+// to Configure, which writes to the database. It checks
+// the format of public keys and URLs, that the generator,
+// block signers, and block HSM, where c refers to them,
+// can be reached, and that the quorum and the issuance
+// window make sense.
+//
+// Validate reports every problem it finds, not only the
+// first. If there are any, it returns ErrInvalidConfig with
+// the problems attached; see errors.Data. Each problem is an
+// error whose root is another error of this package, such
+// as ErrBadSignerURL or ErrBadQuorum.
+func Validate(ctx context.Context, c *Config) error {
+	var problems []error
+	add := func(err error) {
+		if err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	if !c.IsGenerator {
+		add(tryGenerator(ctx, c.GeneratorURL, c.GeneratorAccessToken, c.BlockchainID.String()))
+	}
+
+	if c.IsSigner {
+		add(checkProdBlockHSMURL(c.BlockHSMURL))
+		if c.BlockPub != "" {
+			pub, err := hex.DecodeString(c.BlockPub)
+			if err != nil || len(pub) != ed25519.PublicKeySize {
+				add(errors.WithDetailf(ErrBadBlockPub, "block pub %q is not a hex-encoded ed25519 public key", c.BlockPub))
+			}
+		}
+		if pkcs11hsm.IsURI(c.BlockHSMURL) {
+			// A PKCS#11 token is opened only by cored,
+			// and cannot create the block key for us.
+			_, err := pkcs11hsm.ParseURI(c.BlockHSMURL)
+			if err != nil {
+				add(errors.Sub(ErrBadBlockHSM, err))
+			}
+			if c.BlockPub == "" {
+				add(errors.WithDetail(ErrBadBlockPub, "a PKCS#11 block hsm needs a block pub"))
+			}
+		} else if c.BlockHSMURL != "" {
+			err := probe(ctx, c.BlockHSMURL)
+			if err != nil {
+				add(errors.WithDetailf(ErrBadBlockHSM, "%s: %s", c.BlockHSMURL, err))
+			}
+		}
+	}
+
+	if c.IsGenerator {
+		// Shares of a threshold key are listed
+		// as signers with the same pubkey.
+		keys := make(map[string]bool)
+		if c.IsSigner {
+			keys[strings.ToLower(c.BlockPub)] = true
+		}
+		for _, s := range c.Signers {
+			keys[hex.EncodeToString(s.Pubkey)] = true
+			if len(s.Pubkey) != ed25519.PublicKeySize {
+				add(errors.WithDetailf(ErrBadSignerPubkey, "pubkey %x has length %d, want %d", []byte(s.Pubkey), len(s.Pubkey), ed25519.PublicKeySize))
+			}
+			u, err := url.Parse(s.URL)
+			if err != nil || u.Scheme == "" || u.Host == "" {
+				add(errors.WithDetailf(ErrBadSignerURL, "signer %x has url %q", []byte(s.Pubkey), s.URL))
+				continue
+			}
+			err = probe(ctx, s.URL)
+			if err != nil {
+				add(errors.WithDetailf(ErrBadSignerURL, "signer %x at %s is unreachable: %s", []byte(s.Pubkey), s.URL, err))
+			}
+		}
+		if nkeys := len(keys); nkeys > 0 && (c.Quorum < 1 || c.Quorum > nkeys) {
+			add(errors.WithDetailf(ErrBadQuorum, "quorum %d of %d signing keys", c.Quorum, nkeys))
+		}
+
+		window, period := c.MaxIssuanceWindow.Duration, c.BlockPeriod.Duration
+		if window > 0 && period > 0 && window < period {
+			add(errors.WithDetailf(ErrBadOperational, "max issuance window %s is shorter than the block period %s", window, period))
+		}
+	}
+
+	if c.MaxIssuanceWindow.Duration < 0 {
+		add(errors.WithDetailf(ErrBadOperational, "max issuance window %s is negative", c.MaxIssuanceWindow.Duration))
+	}
+	if c.BlockPeriod.Duration < 0 {
+		add(errors.WithDetailf(ErrBadOperational, "block period %s is negative", c.BlockPeriod.Duration))
+	}
+
+	if len(problems) > 0 {
+		return errors.WithData(ErrInvalidConfig, "problems", problems)
+	}
+	return nil
+}
+
+// probe reports whether an HTTP server answers at u.
+// Any response, whatever its status, will do.
+func probe(ctx context.Context, u string) error {
+	req, err := http.NewRequest("HEAD", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := probeClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
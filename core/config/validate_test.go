@@ -0,0 +1,159 @@
+package config
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"chain/crypto/ed25519"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+)
+
+func TestValidate(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+	down := httptest.NewServer(nil)
+	down.Close()
+
+	pub1, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub2, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := func(pub ed25519.PublicKey, url string) BlockSigner {
+		return BlockSigner{Pubkey: chainjson.HexBytes(pub), URL: url}
+	}
+	duration := func(d time.Duration) chainjson.Duration {
+		return chainjson.Duration{Duration: d}
+	}
+
+	cases := []struct {
+		c    *Config
+		want []error
+	}{
+		{
+			c:    &Config{IsGenerator: true},
+			want: nil,
+		},
+		{
+			c: &Config{
+				IsGenerator: true,
+				Signers:     []BlockSigner{signer(pub1, server.URL), signer(pub2, server.URL)},
+				Quorum:      2,
+			},
+			want: nil,
+		},
+		{
+			// Shares of a threshold key count as one key.
+			c: &Config{
+				IsGenerator: true,
+				Signers:     []BlockSigner{signer(pub1, server.URL), signer(pub1, server.URL)},
+				Quorum:      2,
+			},
+			want: []error{ErrBadQuorum},
+		},
+		{
+			c: &Config{
+				IsGenerator: true,
+				Signers:     []BlockSigner{signer(pub1, server.URL)},
+				Quorum:      0,
+			},
+			want: []error{ErrBadQuorum},
+		},
+		{
+			// Every problem is reported, not only the first.
+			c: &Config{
+				IsGenerator: true,
+				Signers: []BlockSigner{
+					signer(pub1[:10], server.URL),
+					signer(pub2, "not a url"),
+					signer(pub1, down.URL),
+				},
+				Quorum: 4,
+			},
+			want: []error{ErrBadSignerPubkey, ErrBadSignerURL, ErrBadSignerURL, ErrBadQuorum},
+		},
+		{
+			c: &Config{
+				IsGenerator: true,
+				Operational: Operational{
+					MaxIssuanceWindow: duration(-time.Second),
+					BlockPeriod:       duration(-time.Second),
+				},
+			},
+			want: []error{ErrBadOperational, ErrBadOperational},
+		},
+		{
+			c: &Config{
+				IsGenerator: true,
+				Operational: Operational{
+					MaxIssuanceWindow: duration(time.Second),
+					BlockPeriod:       duration(time.Minute),
+				},
+			},
+			want: []error{ErrBadOperational},
+		},
+		{
+			c: &Config{
+				IsGenerator: true,
+				IsSigner:    true,
+				Quorum:      1,
+				BlockPub:    hex.EncodeToString(pub1),
+				BlockHSMURL: server.URL,
+			},
+			want: nil,
+		},
+		{
+			c: &Config{
+				IsGenerator: true,
+				IsSigner:    true,
+				Quorum:      1,
+				BlockPub:    "zz",
+				BlockHSMURL: down.URL,
+			},
+			want: []error{ErrBadBlockPub, ErrBadBlockHSM},
+		},
+		{
+			// A PKCS#11 token cannot create the block key.
+			c: &Config{
+				IsGenerator: true,
+				IsSigner:    true,
+				Quorum:      1,
+				BlockHSMURL: "pkcs11:slot-id=0?module-path=/usr/lib/softhsm.so",
+			},
+			want: []error{ErrBadBlockPub},
+		},
+	}
+
+	for i, c := range cases {
+		err := Validate(ctx, c.c)
+		if c.want == nil {
+			if err != nil {
+				t.Errorf("case %d: Validate() = %v want nil", i, err)
+			}
+			continue
+		}
+		if errors.Root(err) != ErrInvalidConfig {
+			t.Errorf("case %d: Validate() = %v want %v", i, err, ErrInvalidConfig)
+			continue
+		}
+		problems, _ := errors.Data(err)["problems"].([]error)
+		if len(problems) != len(c.want) {
+			t.Errorf("case %d: got problems %v, want roots %v", i, problems, c.want)
+			continue
+		}
+		for j, p := range problems {
+			if errors.Root(p) != c.want[j] {
+				t.Errorf("case %d: problem %d = %v want %v", i, j, p, c.want[j])
+			}
+		}
+	}
+}
@@ -6,8 +6,10 @@ import (
 	"strings"
 	"time"
 
+	"chain/core/build"
 	"chain/core/config"
 	"chain/core/fetch"
+	"chain/core/generator"
 	"chain/core/leader"
 	"chain/errors"
 	"chain/log"
@@ -18,7 +20,6 @@ var (
 	errAlreadyConfigured = errors.New("core is already configured; must reset first")
 	errUnconfigured      = errors.New("core is not configured")
 	errProduction        = errors.New("core is configured for production, not development")
-	errBadBlockPub       = errors.New("supplied block pub key is invalid")
 	errNoClientTokens    = errors.New("cannot enable client auth without client access tokens")
 )
 
@@ -33,6 +34,10 @@ func (a *API) reset(ctx context.Context, req struct {
 	if req.Everything {
 		dataToReset = "everything"
 	}
+	err := a.recordAudit(ctx, "reset", map[string]interface{}{"everything": req.Everything})
+	if err != nil {
+		return err
+	}
 
 	closeConnOK(httpjson.ResponseWriter(ctx), httpjson.Request(ctx))
 	execSelf(dataToReset)
@@ -43,11 +48,13 @@ func (a *API) info(ctx context.Context) (map[string]interface{}, error) {
 	if a.Config == nil {
 		// never configured
 		return map[string]interface{}{
-			"is_configured": false,
-			"is_production": config.Production,
-			"version":       config.Version,
-			"build_commit":  config.BuildCommit,
-			"build_date":    config.BuildDate,
+			"is_configured":    false,
+			"is_production":    config.Production,
+			"version":          config.Version,
+			"build_commit":     config.BuildCommit,
+			"build_date":       config.BuildDate,
+			"build_deps":       build.Deps,
+			"consensus_digest": build.Current().ConsensusDigest,
 		}, nil
 	}
 	if leader.IsLeading() {
@@ -97,12 +104,15 @@ func (a *API) leaderInfo(ctx context.Context) (map[string]interface{}, error) {
 		"block_height":                      localHeight,
 		"generator_block_height":            generatorHeight,
 		"generator_block_height_fetched_at": generatorFetched,
+		"final_block_height":                a.Config.FinalBlockHeight,
 		"is_production":                     config.Production,
 		"network_rpc_version":               networkRPCVersion,
 		"core_id":                           a.Config.ID,
 		"version":                           config.Version,
 		"build_commit":                      config.BuildCommit,
 		"build_date":                        config.BuildDate,
+		"build_deps":                        build.Deps,
+		"consensus_digest":                  build.Current().ConsensusDigest,
 		"health":                            a.health(),
 	}
 
@@ -128,16 +138,134 @@ func (a *API) configure(ctx context.Context, x *config.Config) error {
 		x.MaxIssuanceWindow.Duration = 24 * time.Hour
 	}
 
-	err := config.Configure(ctx, a.DB, x)
+	err := config.Validate(ctx, x)
+	if errors.Root(err) == config.ErrInvalidConfig {
+		err = errors.WithData(err, "problems", errInfoBodyList(errors.Data(err)["problems"].([]error)))
+	}
+	if err != nil {
+		return err
+	}
+
+	err = config.Configure(ctx, a.DB, x)
+	if err != nil {
+		return err
+	}
+	err = a.recordAudit(ctx, "configure", map[string]interface{}{
+		"is_generator":  x.IsGenerator,
+		"is_signer":     x.IsSigner,
+		"blockchain_id": x.BlockchainID,
+		"generator_url": x.GeneratorURL,
+	})
+	if err != nil {
+		return err
+	}
+
+	closeConnOK(httpjson.ResponseWriter(ctx), httpjson.Request(ctx))
+	execSelf("")
+	panic("unreached")
+}
+
+// updateConfiguration changes the operational settings
+// of a configured core and restarts it to apply them.
+func (a *API) updateConfiguration(ctx context.Context, x config.Operational) error {
+	if a.Config.IsGenerator && x.MaxIssuanceWindow.Duration == 0 {
+		x.MaxIssuanceWindow.Duration = 24 * time.Hour
+	}
+
+	err := config.UpdateOperational(ctx, a.DB, x)
 	if err != nil {
 		return err
 	}
+	err = a.recordAudit(ctx, "update-configuration", map[string]interface{}{
+		"generator_url":       x.GeneratorURL,
+		"max_issuance_window": x.MaxIssuanceWindow,
+		"block_period":        x.BlockPeriod,
+	})
+	if err != nil {
+		return err
+	}
+
+	closeConnOK(httpjson.ResponseWriter(ctx), httpjson.Request(ctx))
+	execSelf("")
+	panic("unreached")
+}
+
+// proposeSignerRotation schedules a change of the block
+// signers. A generator restarts to connect to the new signers.
+func (a *API) proposeSignerRotation(ctx context.Context, x struct {
+	Height  uint64               `json:"height"`
+	Signers []config.BlockSigner `json:"block_signers"`
+	Quorum  int                  `json:"quorum"`
+}) (*config.Rotation, error) {
+	r, err := config.ProposeRotation(ctx, a.DB, x.Height, x.Signers, x.Quorum)
+	if err != nil || !a.Config.IsGenerator {
+		return r, err
+	}
+
+	closeConnOK(httpjson.ResponseWriter(ctx), httpjson.Request(ctx))
+	execSelf("")
+	panic("unreached")
+}
+
+func (a *API) cancelSignerRotation(ctx context.Context, x struct {
+	Height uint64 `json:"height"`
+}) error {
+	err := config.CancelRotation(ctx, a.DB, x.Height)
+	if err != nil || !a.Config.IsGenerator {
+		return err
+	}
 
 	closeConnOK(httpjson.ResponseWriter(ctx), httpjson.Request(ctx))
 	execSelf("")
 	panic("unreached")
 }
 
+func (a *API) listSignerRotations(ctx context.Context) ([]*config.Rotation, error) {
+	return config.Rotations(ctx, a.DB)
+}
+
+// getNetworkMembership returns a signed description of
+// the network for cores that are to join it. The generator
+// URL in it is the configured a.PublicURL, never one taken
+// from the request.
+func (a *API) getNetworkMembership(ctx context.Context) (*config.SignedMembership, error) {
+	return config.ExportMembership(ctx, a.DB, a.Config, a.PublicURL, a.MembershipSigner)
+}
+
+// getBlockSignatureStatus reports the progress of the
+// generator's current, or last, round of collecting block
+// signatures, so that a stalled round can be diagnosed.
+func (a *API) getBlockSignatureStatus(ctx context.Context) (*generator.SignatureStatus, error) {
+	if a.SignatureStatus == nil {
+		return nil, errors.WithDetail(errNotFound, "core is not a generator")
+	}
+	if leader.IsLeading() {
+		return a.SignatureStatus(), nil
+	}
+	var resp *generator.SignatureStatus
+	err := a.forwardToLeader(ctx, "/get-block-signature-status", nil, &resp)
+	return resp, err
+}
+
+// listSettings reports the effective value of each setting
+// that the environment or a flag may override, and which of
+// them, or the stored configuration, it came from.
+func (a *API) listSettings(ctx context.Context) []config.Setting {
+	settings := make([]config.Setting, 0, len(a.Settings))
+	for _, s := range a.Settings {
+		if v, ok := s.Value.(string); ok && s.Secret && v != "" {
+			if strings.Contains(v, ":") {
+				s.Value = obfuscateTokenSecret(v)
+			} else {
+				// Not an id:secret token; hide all of it.
+				s.Value = "********"
+			}
+		}
+		settings = append(settings, s)
+	}
+	return settings
+}
+
 func closeConnOK(w http.ResponseWriter, req *http.Request) {
 	w.Header().Add("Connection", "close")
 	w.WriteHeader(http.StatusNoContent)
@@ -22,6 +22,7 @@ func CreatePins(ctx context.Context, t testing.TB, s *pin.Store) {
 		account.PinName,
 		account.ExpirePinName,
 		account.DeleteSpentsPinName,
+		account.EventsPinName,
 		asset.PinName,
 		query.TxPinName,
 	}
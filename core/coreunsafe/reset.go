@@ -18,13 +18,13 @@ import (
 )
 
 var (
-	persistBlockchainReset = []string{"mockhsm", "access_tokens"}
-	neverReset             = []string{"migrations"}
+	persistBlockchainReset = []string{"mockhsm", "mockhsm_key_versions", "mockhsm_key_policies", "access_tokens"}
+	neverReset             = []string{"migrations", "audit_log", "authz_grants"}
 )
 
 // ResetBlockchain deletes all blockchain data, resulting in an
-// unconfigured core. It does not delete access tokens or mockhsm
-// keys.
+// unconfigured core. It does not delete access tokens, grants,
+// mockhsm keys, or the audit log.
 func ResetBlockchain(ctx context.Context, db pg.DB) error {
 	if config.Production {
 		// Shouldn't ever happen; This package shouldn't even be
@@ -54,7 +54,9 @@ func ResetBlockchain(ctx context.Context, db pg.DB) error {
 	return errors.Wrap(err)
 }
 
-// ResetEverything deletes all of a Core's data.
+// ResetEverything deletes all of a Core's data,
+// other than its audit log and the grants that
+// don't name an access token.
 func ResetEverything(ctx context.Context, db pg.DB) error {
 	if config.Production {
 		// Shouldn't ever happen; This package shouldn't even be
@@ -69,5 +71,11 @@ func ResetEverything(ctx context.Context, db pg.DB) error {
 
 	const q = `TRUNCATE %s RESTART IDENTITY;`
 	_, err = db.Exec(ctx, fmt.Sprintf(q, strings.Join(persistBlockchainReset, ", ")))
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	const grantsQ = `DELETE FROM authz_grants WHERE guard_type='access_token' AND guard_data->>'id' IS NOT NULL`
+	_, err = db.Exec(ctx, grantsQ)
 	return errors.Wrap(err)
 }
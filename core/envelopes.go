@@ -0,0 +1,41 @@
+package core
+
+import (
+	"context"
+
+	"chain/core/txbuilder"
+	"chain/protocol/bc"
+)
+
+// mergeTransactions combines the parts of a multi-party
+// transaction, each built by one party on the same base
+// transaction and not yet signed, into an envelope for
+// the parties to sign.
+//
+// POST /merge-transactions
+func (a *API) mergeTransactions(ctx context.Context, in struct {
+	Base  *bc.TxData        `json:"base_transaction"`
+	Parts []*txbuilder.Part `json:"parts"`
+}) (*txbuilder.Envelope, error) {
+	return txbuilder.Merge(in.Base, in.Parts)
+}
+
+// addTransactionSignatures adds to an envelope the
+// witnesses of a party's inputs from its signed copy
+// of the envelope's template.
+//
+// POST /add-transaction-signatures
+func (a *API) addTransactionSignatures(ctx context.Context, in struct {
+	Envelope *txbuilder.Envelope `json:"envelope"`
+	Party    string              `json:"party"`
+	Signed   *txbuilder.Template `json:"signed_template"`
+}) (*txbuilder.Envelope, error) {
+	if in.Envelope == nil {
+		return nil, txbuilder.MissingFieldsError("envelope")
+	}
+	err := txbuilder.AddSignatures(in.Envelope, in.Party, in.Signed)
+	if err != nil {
+		return nil, err
+	}
+	return in.Envelope, nil
+}
@@ -6,16 +6,22 @@ import (
 	"chain/core/accesstoken"
 	"chain/core/account"
 	"chain/core/asset"
+	"chain/core/audit"
 	"chain/core/blocksigner"
+	"chain/core/build"
 	"chain/core/config"
 	"chain/core/query"
 	"chain/core/query/filter"
+	"chain/core/query/graphql"
 	"chain/core/rpc"
 	"chain/core/signers"
 	"chain/core/txbuilder"
 	"chain/core/txfeed"
+	"chain/core/webhook"
 	"chain/database/pg"
 	"chain/errors"
+	"chain/log"
+	"chain/net/http/authz"
 	"chain/net/http/httpjson"
 	"chain/protocol"
 )
@@ -40,6 +46,8 @@ func isTemporary(info errorInfo, err error) bool {
 		return true
 	case "CH001": // request timed out
 		return true
+	case "CH604": // block height not yet indexed
+		return true
 	case "CH761": // outputs currently reserved
 		return true
 	case "CH706": // 1 or more action errors
@@ -73,15 +81,27 @@ var (
 		errLeaderElection:          errorInfo{503, "CH008", "Electing a new leader for the core; try again soon"},
 		errNotAuthenticated:        errorInfo{401, "CH009", "Request could not be authenticated"},
 		txbuilder.ErrMissingFields: errorInfo{400, "CH010", "One or more fields are missing"},
+		errNotAuthorized:           errorInfo{403, "CH011", "Access token is not authorized for this request"},
 		asset.ErrDuplicateAlias:    errorInfo{400, "CH050", "Alias already exists"},
 		account.ErrDuplicateAlias:  errorInfo{400, "CH050", "Alias already exists"},
 		txfeed.ErrDuplicateAlias:   errorInfo{400, "CH050", "Alias already exists"},
+		webhook.ErrDuplicateAlias:  errorInfo{400, "CH050", "Alias already exists"},
+		asset.ErrBadSuccessor:      errorInfo{400, "CH051", "Invalid asset successor"},
+
+		asset.ErrBadIssuancePolicy:    errorInfo{400, "CH052", "Invalid issuance policy"},
+		asset.ErrIssuanceNotPermitted: errorInfo{403, "CH053", "Issuance not permitted by policy"},
+		asset.ErrIssuanceLimit:        errorInfo{400, "CH054", "Issuance exceeds the asset's outstanding limit"},
+		asset.ErrIssuanceRateLimited:  errorInfo{429, "CH055", "Issuance rate limit exceeded"},
+		asset.ErrBadDefinitionUpdate:  errorInfo{400, "CH056", "Invalid asset definition update"},
+		errServerBusy:                 errorInfo{503, "CH012", "Core is handling too many requests; try again soon"},
+		errBodyTooLarge:               errorInfo{413, "CH013", "Request body is too large"},
+		log.ErrBadLevel:               errorInfo{400, "CH014", "Invalid log level"},
 
 		// Core error namespace
 		errUnconfigured:                errorInfo{400, "CH100", "This core still needs to be configured"},
 		errAlreadyConfigured:           errorInfo{400, "CH101", "This core has already been configured"},
 		config.ErrBadGenerator:         errorInfo{400, "CH102", "Generator URL returned an invalid response"},
-		errBadBlockPub:                 errorInfo{400, "CH103", "Provided Block XPub is invalid"},
+		config.ErrBadBlockPub:          errorInfo{400, "CH103", "Provided Block XPub is invalid"},
 		rpc.ErrWrongNetwork:            errorInfo{502, "CH104", "A peer core is operating on a different blockchain network"},
 		protocol.ErrTheDistantFuture:   errorInfo{400, "CH105", "Requested height is too far ahead"},
 		config.ErrBadSignerURL:         errorInfo{400, "CH106", "Block signer URL is invalid"},
@@ -90,8 +110,19 @@ var (
 		config.ErrNoProdBlockPub:       errorInfo{400, "CH109", "Block Pub cannot be empty when configuring a production signer"},
 		errProduction:                  errorInfo{400, "CH110", "This endpoint can only be called in a development system"},
 		config.ErrNoProdBlockHSMURL:    errorInfo{400, "CH111", "Block HSM URL cannot be empty when configuring a signer in production"},
+		config.ErrBadOperational:       errorInfo{400, "CH112", "Operational settings are invalid"},
+		config.ErrBadRotation:          errorInfo{400, "CH113", "Signer rotation is invalid"},
+		config.ErrInvalidConfig:        errorInfo{400, "CH114", "Configuration is invalid: see attached data"},
+		config.ErrBadBlockHSM:          errorInfo{400, "CH115", "Block HSM is unreachable"},
+		config.ErrNoMembershipKey:      errorInfo{400, "CH116", "Only a generator that is a block signer can sign a membership document"},
+		config.ErrNoMembershipURL:      errorInfo{400, "CH117", "Generator has no public URL to put in a membership document"},
 		errNoClientTokens:              errorInfo{400, "CH120", "Cannot enable client authentication with no client tokens"},
+		build.ErrMismatch:              errorInfo{502, "CH130", "A peer core is running a different consensus-relevant build"},
 		blocksigner.ErrConsensusChange: errorInfo{400, "CH150", "Refuse to sign block with consensus change"},
+		blocksigner.ErrNoShare:         errorInfo{400, "CH151", "Block signer holds no threshold key share"},
+		blocksigner.ErrNoCommitment:    errorInfo{400, "CH152", "Block signer has no commitment for the block"},
+		blocksigner.ErrDoubleSign:      errorInfo{400, "CH153", "Refuse to sign block that conflicts with a signed block"},
+		blocksigner.ErrPolicy:          errorInfo{400, "CH154", "Refuse to sign block that violates signing policy"},
 
 		// Signers error namespace (2xx)
 		signers.ErrBadQuorum: errorInfo{400, "CH200", "Quorum must be greater than 1 and less than or equal to the length of xpubs"},
@@ -104,12 +135,29 @@ var (
 		accesstoken.ErrBadID:       errorInfo{400, "CH300", "Malformed or empty access token id"},
 		accesstoken.ErrBadType:     errorInfo{400, "CH301", "Access tokens must be type client or network"},
 		accesstoken.ErrDuplicateID: errorInfo{400, "CH302", "Access token id is already in use"},
+		accesstoken.ErrBadTTL:      errorInfo{400, "CH303", "Access token ttl must not be negative"},
+		accesstoken.ErrBadScope:    errorInfo{400, "CH304", "Unknown access token scope"},
 		errCurrentToken:            errorInfo{400, "CH310", "The access token used to authenticate this request cannot be deleted"},
 
+		// Authorization grant error namespace (32x)
+		authz.ErrBadGuardType: errorInfo{400, "CH320", "Unknown authorization guard type"},
+		authz.ErrBadGuardData: errorInfo{400, "CH321", "Invalid authorization guard data"},
+		authz.ErrBadPolicy:    errorInfo{400, "CH322", "Unknown authorization policy"},
+		errNoGrants:           errorInfo{400, "CH323", "This core has no authorization grant store"},
+
 		// Query error namespace (6xx)
 		query.ErrBadAfter:               errorInfo{400, "CH600", "Malformed pagination parameter `after`"},
+		audit.ErrBadAfter:               errorInfo{400, "CH600", "Malformed pagination parameter `after`"},
 		query.ErrParameterCountMismatch: errorInfo{400, "CH601", "Incorrect number of parameters to filter"},
 		filter.ErrBadFilter:             errorInfo{400, "CH602", "Malformed query filter"},
+		graphql.ErrBadQuery:             errorInfo{400, "CH603", "Invalid GraphQL query"},
+		graphql.ErrTooComplex:           errorInfo{400, "CH610", "GraphQL query exceeds the depth or cost limit"},
+		query.ErrHeightNotIndexed:       errorInfo{409, "CH604", "Requested block height is not yet indexed by this core"},
+		query.ErrReindexInProgress:      errorInfo{400, "CH605", "An index rebuild is already in progress"},
+		query.ErrBadAggregate:           errorInfo{400, "CH606", "Invalid aggregate"},
+		errBadExportFormat:              errorInfo{400, "CH607", "Unsupported export format"},
+		errNoExportStore:                errorInfo{400, "CH608", "This core has no export store configured"},
+		webhook.ErrBadURL:               errorInfo{400, "CH609", "Invalid webhook URL"},
 
 		// Transaction error namespace (7xx)
 		// Build error namespace (70x)
@@ -121,6 +169,10 @@ var (
 		txbuilder.ErrBlankCheck: errorInfo{400, "CH705", "Unsafe transaction: leaves assets to be taken without requiring payment"},
 		txbuilder.ErrAction:     errorInfo{400, "CH706", "One or more actions had an error: see attached data"},
 
+		txbuilder.ErrMergeConflict:    errorInfo{400, "CH707", "Transaction parts conflict or alter the base transaction"},
+		txbuilder.ErrEnvelopeMismatch: errorInfo{400, "CH708", "Signed transaction does not match the envelope"},
+		txbuilder.ErrBadLock:          errorInfo{400, "CH709", "Invalid lock, or output not held by the given lock and keys"},
+
 		// Submit error namespace (73x)
 		txbuilder.ErrMissingRawTx:          errorInfo{400, "CH730", "Missing raw transaction"},
 		txbuilder.ErrBadInstructionCount:   errorInfo{400, "CH731", "Too many signing instructions in template for transaction"},
@@ -130,10 +182,12 @@ var (
 		txbuilder.ErrNoTxSighashCommitment: errorInfo{400, "CH736", "Transaction is not final, additional actions still allowed"},
 		txbuilder.ErrTxSignatureFailure:    errorInfo{400, "CH737", "Transaction signature missing, client may be missing signature key"},
 		txbuilder.ErrNoTxSighashAttempt:    errorInfo{400, "CH738", "Transaction signature was not attempted"},
+		protocol.ErrNetworkSunset:          errorInfo{400, "CH739", "Blockchain has reached its final height and accepts no more transactions"},
 
 		// account action error namespace (76x)
 		account.ErrInsufficient: errorInfo{400, "CH760", "Insufficient funds for tx"},
 		account.ErrReserved:     errorInfo{400, "CH761", "Some outputs are reserved; try again"},
+		account.ErrDeleted:      errorInfo{400, "CH762", "Account has been deleted"},
 
 		// Mock HSM error namespace (80x)
 	}
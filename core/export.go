@@ -0,0 +1,180 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+
+	"chain/core/query"
+	"chain/errors"
+	"chain/log"
+	"chain/net/http/httpjson"
+)
+
+var (
+	errBadExportFormat = errors.New("unsupported export format")
+	errNoExportStore   = errors.New("no export store")
+)
+
+// ExportStore holds the objects written by
+// /export-transactions with destination "store".
+type ExportStore interface {
+	// Put stores data under key, replacing
+	// any object already stored there.
+	Put(ctx context.Context, key, contentType string, data []byte) error
+}
+
+// HeaderExportComplete is the trailer set on a streamed
+// transaction export once its last record is written.
+// Without it, the export was cut short.
+const HeaderExportComplete = "Chain-Export-Complete"
+
+type exportQuery struct {
+	requestQuery
+
+	// Format is the format of the export. Only "csv",
+	// the default, is supported.
+	Format string `json:"format"`
+
+	// Destination is "response", the default, to stream the
+	// export in the response, or "store" to write each page
+	// to the core's ExportStore as a separate object.
+	Destination string `json:"destination"`
+
+	// KeyPrefix begins the key of each object
+	// written to the store.
+	KeyPrefix string `json:"key_prefix"`
+}
+
+// exportTransactions is an http handler that exports the
+// transactions matching a filter, like /list-transactions,
+// page_size at a time, for reporting outside the core.
+//
+// Each record begins with a cursor. An export cut short
+// resumes with the cursor of its last record as `after`.
+// A page written to the store is named for the position of
+// its first transaction, so that a resumed export writes
+// the same objects.
+//
+// POST /export-transactions
+func (a *API) exportTransactions(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	if a.Config == nil {
+		alwaysError(errUnconfigured).ServeHTTP(rw, req)
+		return
+	}
+
+	var in exportQuery
+	err := httpjson.Read(ctx, req.Body, &in)
+	if err != nil {
+		WriteHTTPError(ctx, rw, err)
+		return
+	}
+	if in.Format != "" && in.Format != "csv" {
+		err = errors.WithDetailf(errBadExportFormat, "format %q is not supported; use csv", in.Format)
+		WriteHTTPError(ctx, rw, err)
+		return
+	}
+	limit, err := a.pageSize(in.PageSize)
+	if err != nil {
+		WriteHTTPError(ctx, rw, err)
+		return
+	}
+	after, err := a.txAfter(ctx, in.requestQuery)
+	if err != nil {
+		WriteHTTPError(ctx, rw, err)
+		return
+	}
+
+	switch in.Destination {
+	case "", "response":
+		a.streamExport(ctx, rw, in, after, limit)
+	case "store":
+		if a.ExportStore == nil {
+			WriteHTTPError(ctx, rw, errNoExportStore)
+			return
+		}
+		keys, err := a.storeExport(ctx, in, &after, limit)
+		if err != nil {
+			err = errors.WithData(err, "objects", keys, "after", after.String())
+			WriteHTTPError(ctx, rw, err)
+			return
+		}
+		httpjson.Write(ctx, rw, 200, map[string]interface{}{"objects": keys})
+	default:
+		err = errors.WithDetailf(httpjson.ErrBadRequest, "unknown destination %q", in.Destination)
+		WriteHTTPError(ctx, rw, err)
+	}
+}
+
+// streamExport writes the export to rw as CSV, flushing each
+// page as it goes. Once the response has begun, an error can
+// only end it early, without the HeaderExportComplete trailer.
+func (a *API) streamExport(ctx context.Context, rw http.ResponseWriter, in exportQuery, after query.TxAfter, limit int) {
+	w := csv.NewWriter(rw)
+	for started := false; ; started = true {
+		txs, next, err := a.Indexer.Transactions(ctx, in.Filter, in.FilterParams, after, limit, false)
+		if err != nil && !started {
+			WriteHTTPError(ctx, rw, err)
+			return
+		}
+		if err != nil {
+			log.Error(ctx, errors.Wrapf(err, "exporting transactions after %s", after))
+			return
+		}
+		if !started {
+			rw.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			rw.Header().Set("Trailer", HeaderExportComplete)
+			w.Write(query.TxCSVHeader)
+		}
+		err = query.WriteTxsCSV(w, txs, after.StopBlockHeight)
+		if err != nil {
+			log.Error(ctx, err)
+			return
+		}
+		if f, ok := rw.(http.Flusher); ok {
+			f.Flush()
+		}
+		if len(txs) < limit {
+			rw.Header().Set(HeaderExportComplete, "true")
+			return
+		}
+		after = *next
+	}
+}
+
+// storeExport writes each page of the export to a.ExportStore
+// as CSV, and returns the keys written. On return, after is
+// the cursor following the last page written.
+func (a *API) storeExport(ctx context.Context, in exportQuery, after *query.TxAfter, limit int) ([]string, error) {
+	keys := []string{} // non-nil for JSON
+	for {
+		txs, next, err := a.Indexer.Transactions(ctx, in.Filter, in.FilterParams, *after, limit, false)
+		if err != nil {
+			return keys, errors.Wrap(err, "running tx query")
+		}
+		if len(txs) == 0 {
+			return keys, nil
+		}
+
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write(query.TxCSVHeader)
+		err = query.WriteTxsCSV(w, txs, after.StopBlockHeight)
+		if err != nil {
+			return keys, err
+		}
+		key := fmt.Sprintf("%s%d-%d.csv", in.KeyPrefix, txs[0].BlockHeight, txs[0].Position)
+		err = a.ExportStore.Put(ctx, key, "text/csv", buf.Bytes())
+		if err != nil {
+			return keys, errors.Wrapf(err, "storing %s", key)
+		}
+		keys = append(keys, key)
+		*after = *next
+		if len(txs) < limit {
+			return keys, nil
+		}
+	}
+}
@@ -102,10 +102,12 @@ func Fetch(ctx context.Context, c *protocol.Chain, peer *rpc.Client, health func
 		case b := <-blockch:
 			for {
 				prevSnapshot, prevBlock, err = applyBlock(ctx, c, prevSnapshot, prevBlock, b)
-				if err == protocol.ErrBadBlock {
-					log.Fatalkv(ctx, log.KeyError, err)
-				} else if err != nil {
-					// This is a serious I/O error.
+				if err != nil {
+					// This is a serious I/O error, or the peer
+					// sent a block we can't apply (protocol.ErrFork
+					// or protocol.ErrBadBlock). Either way, report
+					// it through health and keep retrying; there is
+					// no rollback, so an operator must resolve a fork.
 					health(err)
 					log.Error(ctx, err)
 					nfailures++
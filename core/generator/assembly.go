@@ -0,0 +1,62 @@
+package generator
+
+import (
+	"context"
+	"time"
+
+	"chain/protocol"
+	"chain/protocol/bc"
+	"chain/protocol/mempool"
+)
+
+// An AssemblyPolicy chooses the pending txs
+// offered for inclusion in the next block.
+type AssemblyPolicy interface {
+	// Select returns the txs to offer, in the order the
+	// block should include them, and the IDs of the txs
+	// to drop from the pool without offering them. Every
+	// tx must come after the pool txs whose outputs it
+	// spends.
+	Select(ctx context.Context, c *protocol.Chain, pool *mempool.Pool, now time.Time) (offer []*bc.Tx, drop []bc.Hash)
+}
+
+// FIFO is the default AssemblyPolicy. It offers pending
+// txs in the order they were submitted, except that a tx
+// comes after the pool txs whose outputs it spends, and
+// a tx with a higher priority comes before one with a
+// lower priority. It drops issuance txs whose time range
+// is wider than the chain's maximum issuance window.
+type FIFO struct {
+	// Priority, if not nil, returns the priority of
+	// an item, such as by the tier of its submitter.
+	// Items of equal priority stay in order of
+	// submission.
+	Priority func(mempool.Item) int
+}
+
+// Select implements AssemblyPolicy.
+func (f FIFO) Select(ctx context.Context, c *protocol.Chain, pool *mempool.Pool, now time.Time) ([]*bc.Tx, []bc.Hash) {
+	var less func(a, b *mempool.Item) bool
+	if f.Priority != nil {
+		less = func(a, b *mempool.Item) bool { return f.Priority(*a) > f.Priority(*b) }
+	}
+
+	var (
+		offer []*bc.Tx
+		drop  []bc.Hash
+	)
+	for _, item := range pool.Ordered(less) {
+		if c.CheckIssuanceWindow(item.Tx) != nil {
+			drop = append(drop, item.Tx.ID)
+			continue
+		}
+		offer = append(offer, item.Tx)
+	}
+	return offer, drop
+}
+
+// SetAssemblyPolicy sets the policy that chooses the
+// pending txs for each block. The default is FIFO{}.
+func (g *Generator) SetAssemblyPolicy(p AssemblyPolicy) {
+	g.policy = p
+}
@@ -1,17 +1,21 @@
 package generator
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"sync"
 	"time"
 
+	"chain/core/blocksigner"
 	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/threshold"
 	"chain/database/pg"
 	"chain/database/sql"
 	"chain/errors"
 	"chain/log"
 	"chain/metrics"
+	"chain/protocol"
 	"chain/protocol/bc"
 	"chain/protocol/state"
 	"chain/protocol/vmutil"
@@ -43,17 +47,43 @@ func (g *Generator) makeBlock(ctx context.Context) error {
 	t0 := time.Now()
 	defer recordSince(t0)
 
-	g.mu.Lock()
-	txs := g.pool
-	g.pool = nil
-	g.poolHashes = make(map[bc.Hash]bool)
-	g.mu.Unlock()
+	// Leave pending transactions in the pool once the
+	// blockchain is sunset; there is nowhere to put them.
+	err := g.chain.CheckFinalHeight(g.latestBlock.Height + 1)
+	if err != nil {
+		return err
+	}
+
+	err = g.pool.Evict(ctx, t0)
+	if err != nil {
+		return err
+	}
+	txs, drop := g.policy.Select(ctx, g.chain, g.pool, t0)
+	if len(drop) > 0 {
+		err = g.pool.Remove(ctx, drop)
+		if err != nil {
+			return err
+		}
+	}
 
 	b, s, err := g.chain.GenerateBlock(ctx, g.latestBlock, g.latestSnapshot, time.Now(), txs)
 	if err != nil {
 		return errors.Wrap(err, "generate")
 	}
-	if len(b.Transactions) == 0 {
+	rotating, err := g.applySchedule(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	// Unless the block is full, every pending tx left out
+	// of it is no longer valid and can be dropped.
+	if len(b.Transactions) < protocol.MaxBlockTxs {
+		err = g.pool.Remove(ctx, rejectedTxs(txs, b.Transactions))
+		if err != nil {
+			return err
+		}
+	}
+	if len(b.Transactions) == 0 && !rotating {
 		return nil // don't bother making an empty block
 	}
 	err = savePendingBlock(ctx, g.db, b)
@@ -74,9 +104,56 @@ func (g *Generator) commitBlock(ctx context.Context, b *bc.Block, s *state.Snaps
 		return errors.Wrap(err, "commit")
 	}
 
+	prev := g.latestBlock
 	g.latestBlock = b
 	g.latestSnapshot = s
-	return nil
+
+	if g.schedule != nil && prev != nil && !bytes.Equal(b.ConsensusProgram, prev.ConsensusProgram) {
+		err = g.schedule.Applied(ctx, b)
+		if err != nil {
+			// The block is committed; only the record is missing.
+			log.Error(ctx, err)
+		}
+	}
+
+	err = g.pool.Confirm(ctx, b.Transactions)
+	return errors.Wrap(err, "confirming pending txs")
+}
+
+// applySchedule sets the consensus program of b to the
+// one scheduled for its height, if any. It reports whether
+// the program changed. A block that changes the program
+// is made even if it has no transactions, so that the
+// change happens at the scheduled height.
+func (g *Generator) applySchedule(ctx context.Context, b *bc.Block) (bool, error) {
+	if g.schedule == nil {
+		return false, nil
+	}
+	program, err := g.schedule.ConsensusProgram(ctx, b.Height)
+	if err != nil {
+		return false, err
+	}
+	if program == nil || bytes.Equal(program, b.ConsensusProgram) {
+		return false, nil
+	}
+	b.ConsensusProgram = program
+	return true, nil
+}
+
+// rejectedTxs returns the IDs of the txs in pending
+// that are not in included.
+func rejectedTxs(pending, included []*bc.Tx) []bc.Hash {
+	in := make(map[bc.Hash]bool, len(included))
+	for _, tx := range included {
+		in[tx.ID] = true
+	}
+	var ids []bc.Hash
+	for _, tx := range pending {
+		if !in[tx.ID] {
+			ids = append(ids, tx.ID)
+		}
+	}
+	return ids
 }
 
 func (g *Generator) getAndAddBlockSignatures(ctx context.Context, b, prevBlock *bc.Block) error {
@@ -88,6 +165,14 @@ func (g *Generator) getAndAddBlockSignatures(ctx context.Context, b, prevBlock *
 	if err != nil {
 		return errors.Wrap(err, "parsing prevblock output script")
 	}
+	if g.threshold > 0 && len(pubkeys) == 1 && bytes.Equal(pubkeys[0], g.groupKey) {
+		sig, err := g.getThresholdSignature(ctx, b)
+		if err != nil {
+			return err
+		}
+		b.Witness = [][]byte{sig}
+		return nil
+	}
 	if len(g.signers) < quorum {
 		return errTooFewSigners
 	}
@@ -97,16 +182,20 @@ func (g *Generator) getAndAddBlockSignatures(ctx context.Context, b, prevBlock *
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	status := g.startStatus(b, quorum)
+	defer g.finishStatus(status)
+
 	goodSigs := make([][]byte, len(pubkeys))
 	replies := make([][]byte, len(g.signers))
 	done := make(chan int, len(g.signers))
 	for i, signer := range g.signers {
-		go getSig(ctx, signer, b, &replies[i], i, done)
+		go g.getSig(ctx, status, signer, b, &replies[i], i, done)
 	}
 
 	nready := 0
 	for i := 0; i < len(g.signers) && nready < quorum; i++ {
-		sig := replies[<-done]
+		j := <-done
+		sig := replies[j]
 		if sig == nil {
 			continue
 		}
@@ -114,8 +203,13 @@ func (g *Generator) getAndAddBlockSignatures(ctx context.Context, b, prevBlock *
 		if k >= 0 && goodSigs[k] == nil {
 			goodSigs[k] = sig
 			nready++
+			g.updateStatus(status, j, func(s *SignerStatus) {
+				s.State = SignerSigned
+				s.Error = ""
+			})
 		} else if k < 0 {
 			log.Printkv(ctx, "error", "invalid signature", "block", b.Hash(), "signature", sig)
+			g.updateStatus(status, j, func(s *SignerStatus) { s.State = SignerInvalid })
 		}
 	}
 
@@ -135,13 +229,77 @@ func indexKey(keys []ed25519.PublicKey, msg, sig []byte) int {
 	return -1
 }
 
-func getSig(ctx context.Context, signer BlockSigner, b *bc.Block, sig *[]byte, i int, done chan int) {
-	var err error
-	*sig, err = signer.SignBlock(ctx, b)
-	if err != nil && ctx.Err() != context.Canceled {
-		log.Printkv(ctx, "error", err, "signer", signer)
+// getThresholdSignature collects commitments from the threshold
+// signers, asks the first g.threshold to answer for their shares
+// of the signature of b, and combines the shares.
+func (g *Generator) getThresholdSignature(ctx context.Context, b *bc.Block) ([]byte, error) {
+	var signers []ThresholdSigner
+	for _, s := range g.signers {
+		if ts, ok := s.(ThresholdSigner); ok {
+			signers = append(signers, ts)
+		}
+	}
+	if len(signers) < g.threshold {
+		return nil, errTooFewSigners
 	}
-	done <- i
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Round one: commitments.
+	type commitReply struct {
+		signer ThresholdSigner
+		c      threshold.Commitment
+		err    error
+	}
+	commits := make(chan commitReply, len(signers))
+	for _, s := range signers {
+		go func(s ThresholdSigner) {
+			c, err := s.CommitBlock(ctx, b)
+			commits <- commitReply{s, c, err}
+		}(s)
+	}
+	var (
+		participants []ThresholdSigner
+		commitments  []threshold.Commitment
+	)
+	for i := 0; i < len(signers) && len(participants) < g.threshold; i++ {
+		r := <-commits
+		if r.err != nil {
+			if ctx.Err() != context.Canceled {
+				log.Printkv(ctx, "error", r.err, "signer", r.signer)
+			}
+			continue
+		}
+		participants = append(participants, r.signer)
+		commitments = append(commitments, r.c)
+	}
+	if len(participants) < g.threshold {
+		return nil, fmt.Errorf("got %d of %d needed commitments", len(participants), g.threshold)
+	}
+
+	// Round two: signature shares, from every participant.
+	req := &blocksigner.ShareRequest{Block: b, Commitments: commitments}
+	shares := make([]threshold.Scalar, len(participants))
+	errs := make([]error, len(participants))
+	var wg sync.WaitGroup
+	for i, s := range participants {
+		wg.Add(1)
+		go func(i int, s ThresholdSigner) {
+			defer wg.Done()
+			shares[i], errs[i] = s.SignBlockShare(ctx, req)
+		}(i, s)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return nil, errors.Wrapf(err, "getting signature share from %v", participants[i])
+		}
+	}
+
+	hash := b.Hash()
+	sig, err := threshold.Aggregate(g.groupKey, hash[:], commitments, shares, nil)
+	return sig, errors.Wrap(err, "combining signature shares")
 }
 
 func nonNilSigs(a [][]byte) (b [][]byte) {
@@ -9,10 +9,16 @@ import (
 	"sync"
 	"time"
 
+	"chain/core/blocksigner"
+	"chain/core/txbuilder"
+	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/threshold"
 	"chain/database/pg"
+	"chain/errors"
 	"chain/log"
 	"chain/protocol"
 	"chain/protocol/bc"
+	"chain/protocol/mempool"
 	"chain/protocol/state"
 	"chain/protocol/validation"
 )
@@ -25,17 +31,51 @@ type BlockSigner interface {
 	SignBlock(context.Context, *bc.Block) (signature []byte, err error)
 }
 
+// A ThresholdSigner holds a share of a threshold block
+// signing key and signs blocks in two rounds.
+type ThresholdSigner interface {
+	// CommitBlock validates the block and returns
+	// the commitment to a fresh signing nonce.
+	CommitBlock(context.Context, *bc.Block) (threshold.Commitment, error)
+
+	// SignBlockShare returns the signer's share of the
+	// block's signature, given the commitments of all
+	// the signers taking part.
+	SignBlockShare(context.Context, *blocksigner.ShareRequest) (threshold.Scalar, error)
+}
+
+// A Schedule provides scheduled changes of
+// the consensus program.
+type Schedule interface {
+	// ConsensusProgram returns the consensus program
+	// for the block at height, or nil to keep that of
+	// the previous block.
+	ConsensusProgram(ctx context.Context, height uint64) ([]byte, error)
+
+	// Applied is called after committing a block
+	// that changes the consensus program.
+	Applied(ctx context.Context, b *bc.Block) error
+}
+
 // Generator collects pending transactions and produces new blocks on
 // an interval.
 type Generator struct {
 	// config
-	db      pg.DB
-	chain   *protocol.Chain
-	signers []BlockSigner
+	db       pg.DB
+	chain    *protocol.Chain
+	signers  []BlockSigner
+	schedule Schedule // optional
+	policy   AssemblyPolicy
 
-	mu         sync.Mutex
-	pool       []*bc.Tx // in topological order
-	poolHashes map[bc.Hash]bool
+	// groupKey and threshold are set when the block
+	// signing key is a threshold key.
+	groupKey  ed25519.PublicKey
+	threshold int
+
+	pool *mempool.Pool
+
+	statusMu sync.Mutex       // protects status
+	status   *SignatureStatus // of the current or last round of signing
 
 	// latestBlock and latestSnapshot are current as long as this
 	// process remains the leader process. If the process is demoted,
@@ -51,42 +91,95 @@ func New(
 	s []BlockSigner,
 	db pg.DB,
 ) *Generator {
+	var store mempool.Store
+	if db != nil {
+		store = poolStore{db}
+	}
 	return &Generator{
-		db:         db,
-		chain:      c,
-		signers:    s,
-		poolHashes: make(map[bc.Hash]bool),
+		db:      db,
+		chain:   c,
+		signers: s,
+		pool:    mempool.New(store, mempool.DefaultLimits),
+		policy:  FIFO{},
 	}
 }
 
+// SetPoolLimits sets the limits on the generator's
+// pending tx pool.
+func (g *Generator) SetPoolLimits(l mempool.Limits) {
+	g.pool.SetLimits(l)
+}
+
+// OnExpiredTxs sets f to be called with the pending txs
+// dropped because their max time passed before they
+// could be included in a block.
+func (g *Generator) OnExpiredTxs(f func(context.Context, []*bc.Tx)) {
+	g.pool.OnExpire(f)
+}
+
+// SetSchedule sets the source of scheduled changes
+// of the consensus program.
+func (g *Generator) SetSchedule(s Schedule) {
+	g.schedule = s
+}
+
+// SetThreshold tells the generator that groupKey is a threshold
+// key, held in shares by those of its signers that implement
+// ThresholdSigner, t of which must sign. When the consensus
+// program requires only a signature by groupKey, the generator
+// collects the shares of t signers and combines them.
+func (g *Generator) SetThreshold(groupKey ed25519.PublicKey, t int) {
+	g.groupKey = groupKey
+	g.threshold = t
+}
+
 // PendingTxs returns all of the pendings txs that will be
 // included in the generator's next block.
 func (g *Generator) PendingTxs() []*bc.Tx {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-
-	txs := make([]*bc.Tx, len(g.pool))
-	copy(txs, g.pool)
-	return txs
+	return g.pool.Snapshot()
 }
 
 // Submit adds a new pending tx to the pending tx pool.
+// The submitter carried by ctx, if any, is recorded with it;
+// see txbuilder.WithSubmitter. If ctx carries an idempotency key that
+// the same submitter used for a different tx within the last
+// day, Submit returns a *txbuilder.DuplicateError instead.
+// Once the blockchain reaches its final height, Submit
+// returns protocol.ErrNetworkSunset.
 func (g *Generator) Submit(ctx context.Context, tx *bc.Tx) error {
-	g.mu.Lock()
-	defer g.mu.Unlock()
+	err := g.chain.CheckFinalHeight(g.chain.Height() + 1)
+	if err != nil {
+		return err
+	}
 
-	if g.poolHashes[tx.ID] {
-		return nil
+	who := txbuilder.SubmitterID(ctx)
+	key := txbuilder.IdempotencyKey(ctx)
+	if key == "" || g.db == nil {
+		return g.pool.AddItem(ctx, mempool.Item{Tx: tx, Added: time.Now(), Submitter: who})
 	}
 
-	g.poolHashes[tx.ID] = true
-	g.pool = append(g.pool, tx)
+	id, err := claimKey(ctx, g.db, who, key, tx.ID)
+	if err != nil {
+		return err
+	}
+	if id != tx.ID {
+		return &txbuilder.DuplicateError{Key: key, TxID: id}
+	}
+	err = g.pool.AddItem(ctx, mempool.Item{Tx: tx, Added: time.Now(), Submitter: who})
+	if err != nil {
+		if rerr := releaseKey(ctx, g.db, who, key, tx.ID); rerr != nil {
+			log.Error(ctx, rerr)
+		}
+		return err
+	}
 	return nil
 }
 
 // Generate runs in a loop, making one new block
 // every block period. It returns when its context
-// is canceled.
+// is canceled. Once the blockchain reaches its final
+// height (see protocol.Chain.FinalHeight), it stops
+// making blocks.
 // After each attempt to make a block, it calls health
 // to report either an error or nil to indicate success.
 func (g *Generator) Generate(
@@ -98,6 +191,12 @@ func (g *Generator) Generate(
 ) {
 	g.latestBlock, g.latestSnapshot = recoveredBlock, recoveredSnapshot
 
+	// Reload any pending txs saved by a previous leader process.
+	err := g.pool.Recover(ctx)
+	if err != nil {
+		log.Fatalkv(ctx, log.KeyError, err)
+	}
+
 	// Check to see if we already have a pending, generated block.
 	// This can happen if the leader process exits between generating
 	// the block and committing the signed block to the blockchain.
@@ -120,13 +219,27 @@ func (g *Generator) Generate(
 	}
 
 	ticks := time.Tick(period)
+	prunes := time.Tick(time.Hour)
 	for {
 		select {
 		case <-ctx.Done():
 			log.Printf(ctx, "Deposed, Generate exiting")
 			return
+		case <-prunes:
+			err := pruneKeys(ctx, g.db)
+			if err != nil {
+				log.Error(ctx, err)
+			}
 		case <-ticks:
 			err := g.makeBlock(ctx)
+			if errors.Root(err) == protocol.ErrNetworkSunset {
+				// There will be no more blocks; stop trying
+				// to make them, but keep running until deposed.
+				log.Printkv(ctx, "at", "final height reached; no longer making blocks", "height", g.latestBlock.Height)
+				ticks = nil
+				health(nil)
+				continue
+			}
 			health(err)
 			if err != nil {
 				log.Error(ctx, err)
@@ -1,17 +1,24 @@
 package generator
 
 import (
+	"bytes"
 	"context"
+	"sync"
 	"testing"
 	"time"
 
+	"chain/core/blocksigner"
 	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/threshold"
 	"chain/database/pg/pgtest"
+	"chain/errors"
 	"chain/protocol"
 	"chain/protocol/bc"
+	"chain/protocol/mempool"
 	"chain/protocol/prottest"
 	"chain/protocol/state"
 	"chain/protocol/vm"
+	"chain/protocol/vmutil"
 	"chain/testutil"
 )
 
@@ -50,6 +57,28 @@ func TestGeneratorRecovery(t *testing.T) {
 	}
 }
 
+func TestFinalHeight(t *testing.T) {
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	b1, s1 := c.State()
+	c.FinalHeight = 1
+
+	g := New(c, nil, nil)
+	g.latestBlock, g.latestSnapshot = b1, s1
+
+	err := g.Submit(ctx, prottest.NewIssuanceTx(t, c))
+	if errors.Root(err) != protocol.ErrNetworkSunset {
+		t.Errorf("Submit() error = %v want %v", err, protocol.ErrNetworkSunset)
+	}
+	err = g.makeBlock(ctx)
+	if errors.Root(err) != protocol.ErrNetworkSunset {
+		t.Errorf("makeBlock() error = %v want %v", err, protocol.ErrNetworkSunset)
+	}
+	if c.Height() != 1 {
+		t.Errorf("height = %d want 1", c.Height())
+	}
+}
+
 func TestGetAndAddBlockSignatures(t *testing.T) {
 	ctx := context.Background()
 
@@ -89,6 +118,86 @@ func TestGetAndAddBlockSignatures(t *testing.T) {
 	}
 }
 
+func TestGetAndAddBlockSignaturesRetry(t *testing.T) {
+	ctx := context.Background()
+	defer func(d time.Duration) { signBackoff = d }(signBackoff)
+	signBackoff = time.Millisecond
+
+	pub1, prv1, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	pub2, prv2, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	program, err := vmutil.BlockMultiSigProgram([]ed25519.PublicKey{pub1, pub2}, 2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	prev := &bc.Block{BlockHeader: bc.BlockHeader{Height: 1}}
+	prev.ConsensusProgram = program
+	block := &bc.Block{BlockHeader: bc.BlockHeader{Height: 2, PreviousBlockHash: prev.Hash()}}
+	block.ConsensusProgram = program
+
+	flaky := &flakySigner{testSigner: testSigner{pub2, prv2}, failures: 1}
+	g := New(nil, []BlockSigner{testSigner{pub1, prv1}, flaky}, nil)
+	if g.SignatureStatus() != nil {
+		t.Fatal("expected no signature status before signing")
+	}
+
+	err = g.getAndAddBlockSignatures(ctx, block, prev)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = vm.VerifyBlockHeader(&prev.BlockHeader, block)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	status := g.SignatureStatus()
+	if !status.Done || status.Height != 2 || status.Quorum != 2 || status.Signatures != 2 {
+		t.Errorf("status = %+v, want done with 2 of 2 signatures at height 2", status)
+	}
+	got := status.Signers[1]
+	if got.State != SignerSigned || got.Attempts != 2 || got.Error != "" {
+		t.Errorf("flaky signer status = %+v, want signed after 2 attempts", got)
+	}
+}
+
+func TestGetAndAddBlockSignaturesThreshold(t *testing.T) {
+	ctx := context.Background()
+
+	groupKey, shares, err := threshold.Deal(2, 3, nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	program, err := vmutil.BlockMultiSigProgram([]ed25519.PublicKey{groupKey}, 1)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	prev := &bc.Block{BlockHeader: bc.BlockHeader{Height: 1}}
+	prev.ConsensusProgram = program
+	block := &bc.Block{BlockHeader: bc.BlockHeader{Height: 2, PreviousBlockHash: prev.Hash()}}
+	block.ConsensusProgram = program
+
+	var signers []BlockSigner
+	for _, share := range shares {
+		signers = append(signers, &testThresholdSigner{share: share})
+	}
+	g := New(nil, signers, nil)
+	g.SetThreshold(groupKey, 2)
+
+	err = g.getAndAddBlockSignatures(ctx, block, prev)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = vm.VerifyBlockHeader(&prev.BlockHeader, block)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+}
+
 func TestGetAndAddBlockSignaturesInitialBlock(t *testing.T) {
 	ctx := context.Background()
 
@@ -107,6 +216,94 @@ func TestGetAndAddBlockSignaturesInitialBlock(t *testing.T) {
 	}
 }
 
+func TestApplySchedule(t *testing.T) {
+	ctx := context.Background()
+	g := New(nil, nil, nil)
+	g.SetSchedule(testSchedule{5: []byte{0x51}})
+
+	cases := []struct {
+		height      uint64
+		program     []byte
+		wantRotate  bool
+		wantProgram []byte
+	}{
+		{4, []byte{0x50}, false, []byte{0x50}},
+		{5, []byte{0x50}, true, []byte{0x51}},
+		{5, []byte{0x51}, false, []byte{0x51}},
+	}
+	for _, c := range cases {
+		b := &bc.Block{BlockHeader: bc.BlockHeader{Height: c.height}}
+		b.ConsensusProgram = c.program
+		rotate, err := g.applySchedule(ctx, b)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		if rotate != c.wantRotate || !bytes.Equal(b.ConsensusProgram, c.wantProgram) {
+			t.Errorf("applySchedule(height %d, program %x) = %v, %x, want %v, %x", c.height, c.program, rotate, b.ConsensusProgram, c.wantRotate, c.wantProgram)
+		}
+	}
+}
+
+func TestFIFOSelect(t *testing.T) {
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	c.MaxIssuanceWindow = time.Hour
+	pool := mempool.New(nil, mempool.Limits{})
+
+	// Each tx needs an output: the tx ID commits to the
+	// issuance, and so to its nonce, only through its results.
+	issue := func(nonce byte, window time.Duration) *bc.Tx {
+		in := bc.NewIssuanceInput([]byte{nonce}, 1, nil, bc.Hash{}, []byte{0x51}, nil, nil)
+		return bc.NewTx(bc.TxData{
+			Version: 1,
+			MinTime: 1,
+			MaxTime: 1 + bc.DurationMillis(window),
+			Inputs:  []*bc.TxInput{in},
+			Outputs: []*bc.TxOutput{bc.NewTxOutput(in.AssetID(), 1, []byte{0x51}, nil)},
+		})
+	}
+	var (
+		tx1 = issue(1, time.Minute)
+		tx2 = issue(2, 2*time.Hour) // too wide
+		tx3 = issue(3, time.Minute)
+	)
+	for i, item := range []mempool.Item{
+		{Tx: tx1, Submitter: "basic"},
+		{Tx: tx2, Submitter: "basic"},
+		{Tx: tx3, Submitter: "premium"},
+	} {
+		item.Added = time.Unix(int64(i), 0)
+		err := pool.AddItem(ctx, item)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+	}
+
+	tier := func(item mempool.Item) int {
+		if item.Submitter == "premium" {
+			return 1
+		}
+		return 0
+	}
+	offer, drop := FIFO{Priority: tier}.Select(ctx, c, pool, time.Now())
+	if len(offer) != 2 || offer[0].ID != tx3.ID || offer[1].ID != tx1.ID {
+		t.Errorf("offered %v, want [%x %x]", offer, tx3.ID, tx1.ID)
+	}
+	if len(drop) != 1 || drop[0] != tx2.ID {
+		t.Errorf("dropped %x, want [%x]", drop, tx2.ID)
+	}
+}
+
+type testSchedule map[uint64][]byte
+
+func (s testSchedule) ConsensusProgram(ctx context.Context, height uint64) ([]byte, error) {
+	return s[height], nil
+}
+
+func (s testSchedule) Applied(ctx context.Context, b *bc.Block) error {
+	return nil
+}
+
 type testSigner struct {
 	pubKey  ed25519.PublicKey
 	privKey ed25519.PrivateKey
@@ -120,3 +317,50 @@ func (s testSigner) SignBlock(ctx context.Context, b *bc.Block) ([]byte, error)
 func (s testSigner) String() string {
 	return "test-signer"
 }
+
+// flakySigner fails its first few requests.
+type flakySigner struct {
+	testSigner
+
+	mu       sync.Mutex
+	failures int
+}
+
+func (s *flakySigner) SignBlock(ctx context.Context, b *bc.Block) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return nil, errors.New("signer unavailable")
+	}
+	return s.testSigner.SignBlock(ctx, b)
+}
+
+type testThresholdSigner struct {
+	share *threshold.Share
+
+	mu    sync.Mutex
+	nonce *threshold.Nonce
+}
+
+func (s *testThresholdSigner) SignBlock(ctx context.Context, b *bc.Block) ([]byte, error) {
+	return nil, errors.New("threshold signer cannot sign alone")
+}
+
+func (s *testThresholdSigner) CommitBlock(ctx context.Context, b *bc.Block) (threshold.Commitment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, err := s.share.NewNonce(nil)
+	if err != nil {
+		return threshold.Commitment{}, err
+	}
+	s.nonce = n
+	return n.Commitment(), nil
+}
+
+func (s *testThresholdSigner) SignBlockShare(ctx context.Context, req *blocksigner.ShareRequest) (threshold.Scalar, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hash := req.Block.Hash()
+	return s.share.Sign(hash[:], s.nonce, req.Commitments)
+}
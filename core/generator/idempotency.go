@@ -0,0 +1,54 @@
+package generator
+
+import (
+	"context"
+	"time"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// idempotencyWindow is how long the generator remembers
+// an idempotency key. A key older than that can be used
+// again, for a new tx.
+var idempotencyWindow = 24 * time.Hour
+
+// claimKey records that key, from submitter, is used to
+// submit the tx with the given ID, unless it is already
+// in use. It returns the ID of the tx the key is used for.
+func claimKey(ctx context.Context, db pg.DB, submitter, key string, txID bc.Hash) (bc.Hash, error) {
+	const insertQ = `
+		INSERT INTO generator_idempotency_keys (submitter, key, tx_hash) VALUES ($1, $2, $3)
+		ON CONFLICT (submitter, key) DO UPDATE
+		SET tx_hash = excluded.tx_hash, created_at = now()
+		WHERE generator_idempotency_keys.created_at < now() - $4 * interval '1 millisecond'
+	`
+	window := int64(idempotencyWindow / time.Millisecond)
+	_, err := db.Exec(ctx, insertQ, submitter, key, txID, window)
+	if err != nil {
+		return bc.Hash{}, errors.Wrap(err, "generator_idempotency_keys insert query")
+	}
+
+	const selectQ = `SELECT tx_hash FROM generator_idempotency_keys WHERE submitter = $1 AND key = $2`
+	var id bc.Hash
+	err = db.QueryRow(ctx, selectQ, submitter, key).Scan(&id)
+	return id, errors.Wrap(err, "generator_idempotency_keys select query")
+}
+
+// releaseKey forgets that key, from submitter, is used to
+// submit the tx with the given ID, so that a retry can
+// use it for another tx.
+func releaseKey(ctx context.Context, db pg.DB, submitter, key string, txID bc.Hash) error {
+	const q = `DELETE FROM generator_idempotency_keys WHERE submitter = $1 AND key = $2 AND tx_hash = $3`
+	_, err := db.Exec(ctx, q, submitter, key, txID)
+	return errors.Wrap(err, "generator_idempotency_keys delete query")
+}
+
+// pruneKeys forgets the idempotency keys
+// older than idempotencyWindow.
+func pruneKeys(ctx context.Context, db pg.DB) error {
+	const q = `DELETE FROM generator_idempotency_keys WHERE created_at < now() - $1 * interval '1 millisecond'`
+	_, err := db.Exec(ctx, q, int64(idempotencyWindow/time.Millisecond))
+	return errors.Wrap(err, "generator_idempotency_keys delete query")
+}
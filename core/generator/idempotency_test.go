@@ -0,0 +1,117 @@
+package generator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"chain/core/txbuilder"
+	"chain/database/pg"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestClaimKey(t *testing.T) {
+	ctx := context.Background()
+	dbtx := pgtest.NewTx(t)
+	tx1, tx2, tx3 := bc.Hash{1}, bc.Hash{2}, bc.Hash{3}
+
+	claim := func(submitter string, txID, want bc.Hash) {
+		got, err := claimKey(ctx, dbtx, submitter, "key", txID)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		if got != want {
+			t.Errorf("claimKey(%s, %x) = %x want %x", submitter, txID.Bytes(), got.Bytes(), want.Bytes())
+		}
+	}
+	release := func(submitter string, txID bc.Hash) {
+		err := releaseKey(ctx, dbtx, submitter, "key", txID)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+	}
+
+	claim("alice", tx1, tx1)
+	claim("alice", tx1, tx1) // a retry of the same tx
+	claim("alice", tx2, tx1) // a retry with a new tx
+	claim("bob", tx2, tx2)   // keys are scoped by submitter
+
+	// Releasing the key for another tx leaves it claimed.
+	release("alice", tx2)
+	claim("alice", tx2, tx1)
+	release("alice", tx1)
+	claim("alice", tx2, tx2)
+
+	// A key older than the window can be claimed again.
+	pgtest.Exec(ctx, dbtx, t, `
+		UPDATE generator_idempotency_keys SET created_at = now() - $1 * interval '1 millisecond'
+		WHERE submitter = 'bob'
+	`, int64(2*idempotencyWindow/time.Millisecond))
+	claim("bob", tx3, tx3)
+}
+
+func TestPruneKeys(t *testing.T) {
+	ctx := context.Background()
+	dbtx := pgtest.NewTx(t)
+
+	for _, key := range []string{"old", "new"} {
+		_, err := claimKey(ctx, dbtx, "alice", key, bc.Hash{1})
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+	}
+	pgtest.Exec(ctx, dbtx, t, `
+		UPDATE generator_idempotency_keys SET created_at = now() - $1 * interval '1 millisecond'
+		WHERE key = 'old'
+	`, int64(2*idempotencyWindow/time.Millisecond))
+
+	err := pruneKeys(ctx, dbtx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	var keys []string
+	err = pg.ForQueryRows(ctx, dbtx, `SELECT key FROM generator_idempotency_keys`, func(key string) {
+		keys = append(keys, key)
+	})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !testutil.DeepEqual(keys, []string{"new"}) {
+		t.Errorf("keys after prune = %v want [new]", keys)
+	}
+}
+
+func TestSubmitIdempotent(t *testing.T) {
+	ctx := context.Background()
+	dbtx := pgtest.NewTx(t)
+	c := prottest.NewChain(t)
+	g := New(c, nil, dbtx)
+	tx1 := prottest.NewIssuanceTx(t, c)
+	tx2 := prottest.NewIssuanceTx(t, c)
+
+	submit := func(submitter string, tx *bc.Tx) error {
+		ctx := txbuilder.WithSubmitter(ctx, submitter)
+		ctx = txbuilder.WithIdempotencyKey(ctx, "key")
+		return g.Submit(ctx, tx)
+	}
+
+	err := submit("core1/alice", tx1)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = submit("core1/alice", tx2)
+	if dup, ok := errors.Root(err).(*txbuilder.DuplicateError); !ok || dup.TxID != tx1.ID {
+		t.Errorf("resubmit with the same key = %v want a duplicate of %x", err, tx1.ID.Bytes())
+	}
+
+	// Another client of the same forwarding core
+	// has keys of its own.
+	err = submit("core1/bob", tx2)
+	if err != nil {
+		t.Errorf("submit by another submitter = %v want nil", err)
+	}
+}
@@ -0,0 +1,53 @@
+package generator
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/mempool"
+)
+
+// poolStore persists the generator's pending tx pool
+// so that it survives a crash of the leader process.
+type poolStore struct {
+	db pg.DB
+}
+
+func (s poolStore) SaveTx(ctx context.Context, item mempool.Item) error {
+	const q = `
+		INSERT INTO mempool_txs (tx_hash, data, added_at, submitter) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (tx_hash) DO NOTHING
+	`
+	_, err := s.db.Exec(ctx, q, item.Tx.ID, &item.Tx.TxData, item.Added, item.Submitter)
+	return errors.Wrap(err, "mempool_txs insert query")
+}
+
+func (s poolStore) DeleteTxs(ctx context.Context, ids []bc.Hash) error {
+	hashes := make([][]byte, 0, len(ids))
+	for _, id := range ids {
+		hashes = append(hashes, id.Bytes())
+	}
+	const q = `DELETE FROM mempool_txs WHERE tx_hash = ANY($1::bytea[])`
+	_, err := s.db.Exec(ctx, q, pq.ByteaArray(hashes))
+	return errors.Wrap(err, "mempool_txs delete query")
+}
+
+func (s poolStore) LoadTxs(ctx context.Context) ([]mempool.Item, error) {
+	const q = `SELECT data, added_at, submitter FROM mempool_txs ORDER BY seq`
+	var items []mempool.Item
+	err := pg.ForQueryRows(ctx, s.db, q, func(data bc.TxData, added time.Time, submitter string) error {
+		hashes, err := bc.ComputeTxHashes(&data)
+		if err != nil {
+			return errors.Wrap(err, "computing tx hashes")
+		}
+		tx := &bc.Tx{TxData: data, TxHashes: *hashes}
+		items = append(items, mempool.Item{Tx: tx, Added: added, Submitter: submitter})
+		return nil
+	})
+	return items, errors.Wrap(err, "mempool_txs select query")
+}
@@ -0,0 +1,148 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"chain/log"
+	"chain/protocol/bc"
+)
+
+// Limits on asking one signer to sign a block. Each attempt
+// has its own deadline. Failed attempts are retried, after a
+// pause that doubles each time, until the signer has been
+// tried signAttempts times or the round of signing is over.
+var (
+	signTimeout  = 5 * time.Second
+	signAttempts = 3
+	signBackoff  = 250 * time.Millisecond
+)
+
+// States of a signer in a round of signing.
+const (
+	SignerPending  = "pending"  // not yet answered
+	SignerRetrying = "retrying" // failed, and will be asked again
+	SignerSigned   = "signed"
+	SignerInvalid  = "invalid" // answered with a bad signature
+	SignerFailed   = "failed"  // failed every attempt
+)
+
+// SignatureStatus reports the progress of collecting
+// signatures for a block.
+type SignatureStatus struct {
+	Height     uint64         `json:"height"`
+	BlockHash  bc.Hash        `json:"block_hash"`
+	Quorum     int            `json:"quorum"`
+	Signatures int            `json:"signatures"`
+	StartedAt  time.Time      `json:"started_at"`
+	Done       bool           `json:"done"`
+	Signers    []SignerStatus `json:"signers"`
+}
+
+// SignerStatus reports the progress of one signer.
+type SignerStatus struct {
+	Signer    string    `json:"signer"`
+	State     string    `json:"state"`
+	Attempts  int       `json:"attempts"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SignatureStatus returns the progress of the current
+// round of signing, or of the last one if none is under
+// way. It returns nil if there has been no round.
+func (g *Generator) SignatureStatus() *SignatureStatus {
+	g.statusMu.Lock()
+	defer g.statusMu.Unlock()
+	if g.status == nil {
+		return nil
+	}
+	s := *g.status
+	s.Signers = append([]SignerStatus(nil), g.status.Signers...)
+	return &s
+}
+
+// startStatus begins reporting a new round of signing b.
+func (g *Generator) startStatus(b *bc.Block, quorum int) *SignatureStatus {
+	now := time.Now()
+	s := &SignatureStatus{
+		Height:    b.Height,
+		BlockHash: b.Hash(),
+		Quorum:    quorum,
+		StartedAt: now,
+	}
+	for _, signer := range g.signers {
+		s.Signers = append(s.Signers, SignerStatus{
+			Signer:    fmt.Sprint(signer),
+			State:     SignerPending,
+			UpdatedAt: now,
+		})
+	}
+	g.statusMu.Lock()
+	g.status = s
+	g.statusMu.Unlock()
+	return s
+}
+
+// updateStatus calls f with the status of signer i in round st.
+func (g *Generator) updateStatus(st *SignatureStatus, i int, f func(*SignerStatus)) {
+	g.statusMu.Lock()
+	defer g.statusMu.Unlock()
+	s := &st.Signers[i]
+	f(s)
+	s.UpdatedAt = time.Now()
+	if s.State == SignerSigned {
+		st.Signatures++
+	}
+}
+
+// finishStatus marks round st of signing over.
+func (g *Generator) finishStatus(st *SignatureStatus) {
+	g.statusMu.Lock()
+	st.Done = true
+	g.statusMu.Unlock()
+}
+
+// getSig asks signer i for its signature of b, retrying as
+// needed and reporting progress in st, and stores it in *sig.
+// It sends i on done when it is finished, whether or not it
+// got a signature.
+func (g *Generator) getSig(ctx context.Context, st *SignatureStatus, signer BlockSigner, b *bc.Block, sig *[]byte, i int, done chan int) {
+	defer func() { done <- i }()
+
+	backoff := signBackoff
+	for attempt := 1; ; attempt++ {
+		g.updateStatus(st, i, func(s *SignerStatus) { s.Attempts = attempt })
+
+		attemptCtx, cancel := context.WithTimeout(ctx, signTimeout)
+		res, err := signer.SignBlock(attemptCtx, b)
+		cancel()
+		if err == nil {
+			*sig = res
+			return
+		}
+		if ctx.Err() != nil {
+			return // the round is over
+		}
+		log.Printkv(ctx, "error", err, "signer", signer, "attempt", attempt)
+
+		if attempt >= signAttempts {
+			g.updateStatus(st, i, func(s *SignerStatus) {
+				s.State = SignerFailed
+				s.Error = err.Error()
+			})
+			return
+		}
+		g.updateStatus(st, i, func(s *SignerStatus) {
+			s.State = SignerRetrying
+			s.Error = err.Error()
+		})
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,334 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	netcontext "golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+
+	"chain/core/rpc/pb"
+	"chain/core/txbuilder"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/net/http/httpjson"
+	"chain/net/http/reqid"
+	"chain/protocol/bc"
+)
+
+// grpcPrefix is the path prefix of
+// the RPCs of the gRPC Node service.
+const grpcPrefix = "/chain.core.rpc.pb.Node/"
+
+// grpcHandler serves gRPC requests with the Node service,
+// and passes other requests to next. gRPC needs HTTP/2,
+// which the Go HTTP server provides only over TLS.
+func grpcHandler(a *API, next http.Handler) http.Handler {
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx netcontext.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			return handler(grpcContext(ctx, info.FullMethod), req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			return handler(srv, &grpcStream{ss, grpcContext(ss.Context(), info.FullMethod)})
+		}),
+	)
+	pb.RegisterNodeServer(s, &nodeServer{a})
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.ProtoMajor == 2 && strings.HasPrefix(req.Header.Get("Content-Type"), "application/grpc") {
+			s.ServeHTTP(w, req)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// grpcContext returns a context for the gRPC call to method
+// with context ctx. The gRPC server doesn't pass on the HTTP
+// request, so it carries a request made from the call's
+// metadata, which holds its headers, for the API functions
+// that read the request's credentials.
+func grpcContext(ctx context.Context, method string) context.Context {
+	req := &http.Request{
+		Method: "POST",
+		URL:    &url.URL{Path: method},
+		Header: make(http.Header),
+	}
+	md, _ := metadata.FromContext(ctx)
+	for k, vs := range md {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	ctx = reqid.NewContext(ctx, reqid.New())
+	return httpjson.WithRequest(ctx, req)
+}
+
+// grpcStream is a server stream with a replaced context.
+type grpcStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *grpcStream) Context() netcontext.Context { return s.ctx }
+
+// grpcError logs err and converts it to a gRPC error, with the
+// code that best matches its HTTP status, and a description
+// that begins with its Chain error code.
+func grpcError(ctx context.Context, err error) error {
+	logHTTPError(ctx, err)
+	body, info := errInfo(err)
+	var code codes.Code
+	switch info.HTTPStatus {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusRequestTimeout:
+		code = codes.DeadlineExceeded
+	case http.StatusConflict:
+		code = codes.AlreadyExists
+	case http.StatusTooManyRequests:
+		code = codes.ResourceExhausted
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		code = codes.Unavailable
+	default:
+		code = codes.Internal
+	}
+	msg := info.ChainCode + ": " + info.Message
+	if body.Detail != "" {
+		msg += ": " + body.Detail
+	}
+	return grpc.Errorf(code, "%s", msg)
+}
+
+// nodeServer implements the Node service
+// with the JSON API's functions.
+type nodeServer struct {
+	a *API
+}
+
+func (s *nodeServer) Info(ctx netcontext.Context, in *pb.InfoRequest) (*pb.InfoResponse, error) {
+	info, err := s.a.info(ctx)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+
+	// The info may come from the leader, decoded from JSON,
+	// so convert it through JSON. InfoResponse's JSON field
+	// names are those of /info.
+	b, err := json.Marshal(info)
+	if err != nil {
+		return nil, grpcError(ctx, errors.Wrap(err))
+	}
+	resp := new(pb.InfoResponse)
+	err = json.Unmarshal(b, resp)
+	if err != nil {
+		return nil, grpcError(ctx, errors.Wrap(err))
+	}
+	return resp, nil
+}
+
+func (s *nodeServer) BuildTxs(ctx netcontext.Context, in *pb.BuildTxsRequest) (*pb.TxsResponse, error) {
+	if s.a.Config == nil {
+		return nil, grpcError(ctx, errUnconfigured)
+	}
+	reqs := make([]*buildRequest, 0, len(in.Requests))
+	for i, r := range in.Requests {
+		req := &buildRequest{
+			TTL: chainjson.Duration{Duration: time.Duration(r.TtlMs) * time.Millisecond},
+		}
+		if len(r.BaseTransaction) > 0 {
+			req.Tx = new(bc.TxData)
+			err := req.Tx.Scan(r.BaseTransaction)
+			if err != nil {
+				return nil, grpcError(ctx, errors.WithDetailf(httpjson.ErrBadRequest, "bad base transaction on request %d: %s", i, err))
+			}
+		}
+		for _, b := range r.Actions {
+			var act map[string]interface{}
+			err := httpjson.Read(ctx, bytes.NewReader(b), &act)
+			if err != nil {
+				return nil, grpcError(ctx, errors.Wrapf(err, "request %d", i))
+			}
+			req.Actions = append(req.Actions, act)
+		}
+		reqs = append(reqs, req)
+	}
+	resp, err := s.a.build(ctx, reqs)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return s.txsResponse(ctx, resp)
+}
+
+func (s *nodeServer) SubmitTxs(ctx netcontext.Context, in *pb.SubmitTxsRequest) (*pb.TxsResponse, error) {
+	if s.a.Config == nil {
+		return nil, grpcError(ctx, errUnconfigured)
+	}
+	x := submitArg{
+		Transactions:    make([]txbuilder.Template, len(in.Transactions)),
+		WaitUntil:       in.WaitUntil,
+		IdempotencyKeys: in.IdempotencyKeys,
+	}
+	for i, b := range in.Transactions {
+		err := httpjson.Read(ctx, bytes.NewReader(b), &x.Transactions[i])
+		if err != nil {
+			return nil, grpcError(ctx, errors.Wrapf(err, "transaction %d", i))
+		}
+	}
+	resp, err := s.a.submit(ctx, x)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return s.txsResponse(ctx, resp)
+}
+
+// txsResponse converts the response of /build-transaction
+// or /submit-transaction, a list of results and errors,
+// to a TxsResponse.
+func (s *nodeServer) txsResponse(ctx context.Context, v interface{}) (*pb.TxsResponse, error) {
+	// The response may come from the leader, decoded from
+	// JSON, so the errors are told apart in their JSON form.
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, grpcError(ctx, errors.Wrap(err))
+	}
+	var items []json.RawMessage
+	err = json.Unmarshal(b, &items)
+	if err != nil {
+		return nil, grpcError(ctx, errors.Wrap(err))
+	}
+	resp := new(pb.TxsResponse)
+	for _, item := range items {
+		var e struct {
+			Code      string          `json:"code"`
+			Message   string          `json:"message"`
+			Detail    string          `json:"detail"`
+			Data      json.RawMessage `json:"data"`
+			Temporary bool            `json:"temporary"`
+		}
+		json.Unmarshal(item, &e) // results are JSON objects too; they have no code
+		r := new(pb.TxsResponse_Response)
+		if e.Code != "" {
+			r.Error = &pb.Error{
+				Code:      e.Code,
+				Message:   e.Message,
+				Detail:    e.Detail,
+				Data:      e.Data,
+				Temporary: e.Temporary,
+			}
+		} else {
+			r.Json = item
+		}
+		resp.Responses = append(resp.Responses, r)
+	}
+	return resp, nil
+}
+
+func (s *nodeServer) ListTxs(ctx netcontext.Context, in *pb.QueryRequest) (*pb.QueryResponse, error) {
+	return s.list(ctx, in, s.a.listTransactions)
+}
+
+func (s *nodeServer) ListAccounts(ctx netcontext.Context, in *pb.QueryRequest) (*pb.QueryResponse, error) {
+	return s.list(ctx, in, s.a.listAccounts)
+}
+
+func (s *nodeServer) ListAssets(ctx netcontext.Context, in *pb.QueryRequest) (*pb.QueryResponse, error) {
+	return s.list(ctx, in, s.a.listAssets)
+}
+
+func (s *nodeServer) ListBalances(ctx netcontext.Context, in *pb.QueryRequest) (*pb.QueryResponse, error) {
+	return s.list(ctx, in, s.a.listBalances)
+}
+
+func (s *nodeServer) ListUnspentOutputs(ctx netcontext.Context, in *pb.QueryRequest) (*pb.QueryResponse, error) {
+	return s.list(ctx, in, s.a.listUnspentOutputs)
+}
+
+// list runs the query in with f, one of the JSON API's
+// list functions, and converts its page of results.
+func (s *nodeServer) list(ctx context.Context, in *pb.QueryRequest, f func(context.Context, requestQuery) (page, error)) (*pb.QueryResponse, error) {
+	if s.a.Config == nil {
+		return nil, grpcError(ctx, errUnconfigured)
+	}
+	q := requestQuery{
+		Filter:      in.Filter,
+		SumBy:       in.SumBy,
+		PageSize:    int(in.PageSize),
+		After:       in.After,
+		StartTimeMS: in.StartTime,
+		EndTimeMS:   in.EndTime,
+		TimestampMS: in.Timestamp,
+		AscLongPoll: in.AscendingWithLongPoll,
+		Timeout:     chainjson.Duration{Duration: time.Duration(in.TimeoutMs) * time.Millisecond},
+	}
+	for i, b := range in.FilterParams {
+		var v interface{}
+		err := httpjson.Read(ctx, bytes.NewReader(b), &v)
+		if err != nil {
+			return nil, grpcError(ctx, errors.Wrapf(err, "filter param %d", i))
+		}
+		q.FilterParams = append(q.FilterParams, v)
+	}
+
+	p, err := f(ctx, q)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+
+	b, err := json.Marshal(p.Items)
+	if err != nil {
+		return nil, grpcError(ctx, errors.Wrap(err))
+	}
+	var items []json.RawMessage
+	err = json.Unmarshal(b, &items)
+	if err != nil {
+		return nil, grpcError(ctx, errors.Wrap(err))
+	}
+	next := *in
+	next.After = p.Next.After
+	next.StartTime = p.Next.StartTimeMS
+	next.EndTime = p.Next.EndTimeMS
+	next.Timestamp = p.Next.TimestampMS
+	resp := &pb.QueryResponse{
+		Next:     &next,
+		LastPage: p.LastPage,
+	}
+	for _, item := range items {
+		resp.Items = append(resp.Items, item)
+	}
+	return resp, nil
+}
+
+func (s *nodeServer) StreamBlocks(in *pb.StreamBlocksRequest, stream pb.Node_StreamBlocksServer) error {
+	ctx := stream.Context()
+	if s.a.Config == nil {
+		return grpcError(ctx, errUnconfigured)
+	}
+	for height := in.AfterHeight + 1; ; height++ {
+		select {
+		case <-s.a.Chain.BlockWaiter(height):
+		case <-ctx.Done():
+			return grpc.Errorf(codes.Canceled, "%s", ctx.Err())
+		}
+		rawBlock, err := s.a.Store.GetRawBlock(ctx, height)
+		if err != nil {
+			return grpcError(ctx, errors.Wrapf(err, "getting block %d", height))
+		}
+		err = stream.Send(&pb.Block{Height: height, Block: rawBlock})
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"chain/core/rpc/pb"
+	"chain/database/pg"
+	"chain/errors"
+	"chain/testutil"
+)
+
+func TestGRPCError(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		err      error
+		wantCode codes.Code
+		wantDesc string
+	}{
+		{errors.WithDetail(pg.ErrUserInputNotFound, "asset id: foo"), codes.InvalidArgument, "CH002: Not found: asset id: foo"},
+		{errRateLimited, codes.ResourceExhausted, "CH007: Request limit exceeded"},
+		{errors.New("boom"), codes.Internal, "CH000: Chain API Error"},
+	}
+	for _, c := range cases {
+		err := grpcError(ctx, c.err)
+		if grpc.Code(err) != c.wantCode || grpc.ErrorDesc(err) != c.wantDesc {
+			t.Errorf("grpcError(%v) = %v, %q want %v, %q", c.err, grpc.Code(err), grpc.ErrorDesc(err), c.wantCode, c.wantDesc)
+		}
+	}
+}
+
+func TestTxsResponse(t *testing.T) {
+	ctx := context.Background()
+	body, _ := errInfo(errors.WithDetail(pg.ErrUserInputNotFound, "asset id: foo"))
+	results := []interface{}{
+		map[string]string{"id": "abc"},
+		body,
+	}
+
+	got, err := (&nodeServer{}).txsResponse(ctx, results)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	want := &pb.TxsResponse{Responses: []*pb.TxsResponse_Response{
+		{Json: []byte(`{"id":"abc"}`)},
+		{Error: &pb.Error{Code: "CH002", Message: "Not found", Detail: "asset id: foo"}},
+	}}
+	if !testutil.DeepEqual(got, want) {
+		t.Errorf("txsResponse = %v want %v", got, want)
+	}
+}
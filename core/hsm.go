@@ -9,6 +9,8 @@ import (
 	"chain/core/mockhsm"
 	"chain/core/txbuilder"
 	"chain/crypto/ed25519/chainkd"
+	"chain/encoding/json"
+	"chain/errors"
 	"chain/net/http/httpjson"
 )
 
@@ -16,19 +18,40 @@ func init() {
 	errorInfoTab[mockhsm.ErrDuplicateKeyAlias] = errorInfo{400, "CH050", "Alias already exists"}
 	errorInfoTab[mockhsm.ErrInvalidAfter] = errorInfo{400, "CH801", "Invalid `after` in query"}
 	errorInfoTab[mockhsm.ErrTooManyAliasesToList] = errorInfo{400, "CH802", "Too many aliases to list"}
+	errorInfoTab[mockhsm.ErrNoKey] = errorInfo{404, "CH803", "Key not found"}
+	errorInfoTab[mockhsm.ErrNoKeyVersion] = errorInfo{404, "CH804", "Key version not found"}
+	errorInfoTab[mockhsm.ErrBadWrappingKey] = errorInfo{400, "CH805", "Invalid wrapping key"}
+	errorInfoTab[mockhsm.ErrBadWrappedKey] = errorInfo{400, "CH806", "Wrapped key cannot be unwrapped"}
+	errorInfoTab[mockhsm.ErrBadKeyType] = errorInfo{400, "CH807", "Invalid key type"}
+	errorInfoTab[mockhsm.ErrInvalidKeySize] = errorInfo{400, "CH808", "Invalid private key"}
+	errorInfoTab[mockhsm.ErrBadPolicy] = errorInfo{400, "CH809", "Invalid key policy"}
+	errorInfoTab[mockhsm.ErrNotPermitted] = errorInfo{403, "CH810", "Key use not permitted by policy"}
+	errorInfoTab[mockhsm.ErrRateLimited] = errorInfo{429, "CH811", "Key signing rate limit exceeded"}
 }
 
 type MockHSMHandler struct {
 	MockHSM *mockhsm.HSM
+
+	// pageSize bounds list requests like
+	// the API's own; it is set in Register.
+	pageSize func(int) (int, error)
 }
 
 func (h *MockHSMHandler) Register(m *http.ServeMux, a *API) {
 	needConfig := a.needConfig()
+	h.pageSize = a.pageSize
 
 	m.Handle("/mockhsm/create-key", needConfig(h.mockhsmCreateKey))
 	m.Handle("/mockhsm/list-keys", needConfig(h.mockhsmListKeys))
 	m.Handle("/mockhsm/delkey", needConfig(h.mockhsmDelKey))
 	m.Handle("/mockhsm/sign-transaction", needConfig(h.mockhsmSignTemplates))
+	m.Handle("/mockhsm/rotate-key", needConfig(h.mockhsmRotateKey))
+	m.Handle("/mockhsm/list-key-versions", needConfig(h.mockhsmListKeyVersions))
+	m.Handle("/mockhsm/sign-message", needConfig(h.mockhsmSignMessage))
+	m.Handle("/mockhsm/export-key", needConfig(h.mockhsmExportKey))
+	m.Handle("/mockhsm/import-key", needConfig(h.mockhsmImportKey))
+	m.Handle("/mockhsm/set-key-policy", needConfig(h.mockhsmSetKeyPolicy))
+	m.Handle("/mockhsm/get-key-policy", needConfig(h.mockhsmGetKeyPolicy))
 }
 
 func (h *MockHSMHandler) mockhsmCreateKey(ctx context.Context, in struct{ Alias string }) (result *mockhsm.XPub, err error) {
@@ -36,9 +59,9 @@ func (h *MockHSMHandler) mockhsmCreateKey(ctx context.Context, in struct{ Alias
 }
 
 func (h *MockHSMHandler) mockhsmListKeys(ctx context.Context, query requestQuery) (page, error) {
-	limit := query.PageSize
-	if limit == 0 {
-		limit = defGenericPageSize
+	limit, err := h.pageSize(query.PageSize)
+	if err != nil {
+		return page{}, err
 	}
 
 	xpubs, after, err := h.MockHSM.ListKeys(ctx, query.Aliases, query.After, limit)
@@ -60,6 +83,80 @@ func (h *MockHSMHandler) mockhsmListKeys(ctx context.Context, query requestQuery
 	}, nil
 }
 
+func (h *MockHSMHandler) mockhsmRotateKey(ctx context.Context, in struct{ Alias string }) (*mockhsm.KeyVersion, error) {
+	return h.MockHSM.RotateKey(ctx, in.Alias)
+}
+
+func (h *MockHSMHandler) mockhsmListKeyVersions(ctx context.Context, in struct{ Alias string }) ([]*mockhsm.KeyVersion, error) {
+	return h.MockHSM.ListKeyVersions(ctx, in.Alias)
+}
+
+// mockhsmSignMessage signs a message, prefixed with
+// mockhsm.MessagePrefix, with a version of an aliased key.
+// Version 0, or none, means the current version.
+func (h *MockHSMHandler) mockhsmSignMessage(ctx context.Context, in struct {
+	Alias   string        `json:"alias"`
+	Version int           `json:"version"`
+	Message json.HexBytes `json:"message"`
+}) (json.HexBytes, error) {
+	v, err := h.MockHSM.KeyVersion(ctx, in.Alias, in.Version)
+	if err != nil {
+		return nil, err
+	}
+	err = h.MockHSM.Authorize(ctx, v.Pub, accessTokenID(ctx), mockhsm.PurposeMessage)
+	if err != nil {
+		return nil, err
+	}
+	msg := append([]byte(mockhsm.MessagePrefix), in.Message...)
+	return h.MockHSM.SignMessage(ctx, v.Pub, msg)
+}
+
+// mockhsmExportKey returns the private key for an Ed25519
+// pubkey or a chainkd xpub, wrapped under the given 32-byte key.
+// Only a token with the admin scope may call it, and the
+// key's policy must allow mockhsm.PurposeExport. The block
+// key can't be exported.
+func (h *MockHSMHandler) mockhsmExportKey(ctx context.Context, in struct {
+	Pub         json.HexBytes `json:"pub"`
+	WrappingKey json.HexBytes `json:"wrapping_key"`
+}) (*mockhsm.WrappedKey, error) {
+	err := h.MockHSM.Authorize(ctx, in.Pub, accessTokenID(ctx), mockhsm.PurposeExport)
+	if err != nil {
+		return nil, err
+	}
+	return h.MockHSM.ExportKey(ctx, in.Pub, in.WrappingKey)
+}
+
+// mockhsmImportKey stores a private key, exported from
+// another mockhsm or wrapped by mockhsm.WrapKey.
+func (h *MockHSMHandler) mockhsmImportKey(ctx context.Context, in struct {
+	Alias       string              `json:"alias"`
+	Key         *mockhsm.WrappedKey `json:"key"`
+	WrappingKey json.HexBytes       `json:"wrapping_key"`
+}) error {
+	if in.Key == nil {
+		return errors.WithDetail(httpjson.ErrBadRequest, "missing key")
+	}
+	return h.MockHSM.ImportWrapped(ctx, in.Alias, in.Key, in.WrappingKey)
+}
+
+// mockhsmSetKeyPolicy restricts the use of a key,
+// given by its Ed25519 pubkey or chainkd xpub.
+// Only a token with the admin scope may call it
+// (see adminPaths).
+func (h *MockHSMHandler) mockhsmSetKeyPolicy(ctx context.Context, in struct {
+	Pub    json.HexBytes  `json:"pub"`
+	Policy mockhsm.Policy `json:"policy"`
+}) error {
+	return h.MockHSM.SetKeyPolicy(ctx, in.Pub, &in.Policy)
+}
+
+func (h *MockHSMHandler) mockhsmGetKeyPolicy(ctx context.Context, in struct {
+	Pub json.HexBytes `json:"pub"`
+}) (*mockhsm.Policy, error) {
+	return h.MockHSM.KeyPolicy(ctx, in.Pub)
+}
+
 func (h *MockHSMHandler) mockhsmDelKey(ctx context.Context, xpub chainkd.XPub) error {
 	return h.MockHSM.DeleteChainKDKey(ctx, xpub)
 }
@@ -82,6 +179,10 @@ func (h *MockHSMHandler) mockhsmSignTemplates(ctx context.Context, x struct {
 }
 
 func (h *MockHSMHandler) mockhsmSignTemplate(ctx context.Context, xpub chainkd.XPub, path [][]byte, data [32]byte) ([]byte, error) {
+	err := h.MockHSM.Authorize(ctx, xpub.Bytes(), accessTokenID(ctx), mockhsm.PurposeTx)
+	if err != nil {
+		return nil, err
+	}
 	sigBytes, err := h.MockHSM.XSign(ctx, xpub, path, data[:])
 	if err == mockhsm.ErrNoKey {
 		return nil, nil
@@ -34,6 +34,13 @@ func jsonHandler(f interface{}) http.Handler {
 func WriteHTTPError(ctx context.Context, w http.ResponseWriter, err error) {
 	logHTTPError(ctx, err)
 	body, info := errInfo(err)
+	if info.HTTPStatus == http.StatusTooManyRequests || info.HTTPStatus == http.StatusServiceUnavailable {
+		// Tell the client when to try again,
+		// unless the handler already did.
+		if w.Header().Get("Retry-After") == "" {
+			w.Header().Set("Retry-After", "1")
+		}
+	}
 	httpjson.Write(ctx, w, info.HTTPStatus, body)
 }
 
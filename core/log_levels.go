@@ -0,0 +1,56 @@
+package core
+
+import (
+	"context"
+
+	"chain/errors"
+	"chain/log"
+)
+
+// logLevels is the response of /get-log-levels and /set-log-level.
+type logLevels struct {
+	Level   log.Level            `json:"level"`
+	Modules map[string]log.Level `json:"modules"`
+}
+
+func currentLogLevels() *logLevels {
+	def, modules := log.Levels()
+	return &logLevels{Level: def, Modules: modules}
+}
+
+func (a *API) getLogLevels(ctx context.Context) (*logLevels, error) {
+	return currentLogLevels(), nil
+}
+
+// setLogLevel sets the level of module, or the default level if
+// module is empty. An empty level clears the level of module.
+// Levels set here apply only to this process, and last until
+// it exits; use LOG_LEVEL to set them when cored starts.
+func (a *API) setLogLevel(ctx context.Context, x struct {
+	Module string `json:"module"`
+	Level  string `json:"level"`
+}) (*logLevels, error) {
+	switch {
+	case x.Level == "" && x.Module == "":
+		return nil, errors.WithDetail(log.ErrBadLevel, "level is required to set the default level")
+	case x.Level == "":
+		log.ClearModuleLevel(x.Module)
+	default:
+		l, err := log.ParseLevel(x.Level)
+		if err != nil {
+			return nil, err
+		}
+		if x.Module == "" {
+			log.SetLevel(l)
+		} else {
+			log.SetModuleLevel(x.Module, l)
+		}
+	}
+
+	err := a.recordAudit(ctx, "set-log-level", x)
+	if err != nil {
+		return nil, err
+	}
+	log.Printkv(ctx, "at", "log levels changed", "levels", log.LevelSpec())
+	return currentLogLevels(), nil
+}
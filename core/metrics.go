@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"expvar"
 	"net/http"
 	"sync"
@@ -8,6 +9,7 @@ import (
 
 	"chain/metrics"
 	"chain/net/http/reqid"
+	"chain/protocol/validation"
 )
 
 var (
@@ -15,10 +17,12 @@ var (
 	latencies = map[string]*metrics.RotatingLatency{}
 
 	latencyRange = map[string]time.Duration{
-		networkRPCPrefix + "get-block":         20 * time.Second,
-		networkRPCPrefix + "get-blocks":        20 * time.Second,
-		networkRPCPrefix + "signer/sign-block": 5 * time.Second,
-		networkRPCPrefix + "get-snapshot":      30 * time.Second,
+		networkRPCPrefix + "get-block":               20 * time.Second,
+		networkRPCPrefix + "get-blocks":              20 * time.Second,
+		networkRPCPrefix + "signer/sign-block":       5 * time.Second,
+		networkRPCPrefix + "signer/threshold-commit": 5 * time.Second,
+		networkRPCPrefix + "signer/threshold-sign":   5 * time.Second,
+		networkRPCPrefix + "get-snapshot":            30 * time.Second,
 		// the rest have a default range
 	}
 )
@@ -69,3 +73,28 @@ func countCore(id string) {
 	}
 	coresSeen[id] = true
 }
+
+// POST /list-slow-transactions
+//
+// listSlowTransactions returns the transactions that took longest
+// to validate and apply among the most recently accepted blocks,
+// along with the per-stage timings of those blocks.
+func (a *API) listSlowTransactions(ctx context.Context, in struct {
+	Blocks int `json:"blocks"`
+	Limit  int `json:"limit"`
+}) (map[string]interface{}, error) {
+	if in.Blocks <= 0 {
+		in.Blocks = 10
+	}
+	if in.Limit <= 0 {
+		in.Limit = 10
+	}
+	blocks := validation.RecentBlockTimings(in.Blocks)
+	for i := range blocks {
+		blocks[i].SlowTxs = nil // reported below
+	}
+	return map[string]interface{}{
+		"blocks":       blocks,
+		"transactions": validation.SlowestTxs(in.Blocks, in.Limit),
+	}, nil
+}
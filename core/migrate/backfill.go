@@ -0,0 +1,215 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"chain/database/pg"
+	"chain/database/sql"
+	"chain/errors"
+	"chain/log"
+)
+
+const defaultBatchSize = 1000
+
+// A backfill is a data migration that updates existing rows in
+// batches, each in its own transaction, so that it never holds
+// locks for long. Backfills run after the schema migrations,
+// in the background, and after a crash they resume from the
+// last batch committed.
+type backfill struct {
+	Name string
+
+	// Step processes a batch of at most $2 rows
+	// following position $1, and returns one row with
+	// the position of the last row it processed and
+	// the number of rows processed. Positions start at 0.
+	// Zero rows processed means the backfill is done.
+	Step string
+
+	// Count, if set, returns the number of rows to
+	// process, for reporting progress.
+	Count string
+
+	BatchSize int // if 0, defaultBatchSize
+}
+
+// backfills lists the built-in backfills, in the order they run.
+var backfills []backfill
+
+const createBackfillTableSQL = `
+	CREATE TABLE IF NOT EXISTS migration_backfills (
+		name text NOT NULL PRIMARY KEY,
+		checkpoint bigint DEFAULT 0 NOT NULL,
+		processed bigint DEFAULT 0 NOT NULL,
+		total bigint,
+		completed_at timestamp with time zone,
+		updated_at timestamp with time zone DEFAULT now() NOT NULL
+	);
+`
+
+// RunBackfills runs each built-in backfill to completion,
+// or until ctx is done. Processes may run it concurrently;
+// they take turns processing batches.
+func RunBackfills(ctx context.Context, db *sql.DB) error {
+	_, err := db.Exec(ctx, createBackfillTableSQL)
+	if err != nil {
+		return errors.Wrap(err, "creating backfill table")
+	}
+	for _, b := range backfills {
+		err = runBackfill(ctx, db, b)
+		if err != nil {
+			return errors.Wrapf(err, "backfill %s", b.Name)
+		}
+	}
+	return nil
+}
+
+func runBackfill(ctx context.Context, db *sql.DB, b backfill) error {
+	const insertQ = `INSERT INTO migration_backfills (name) VALUES ($1) ON CONFLICT DO NOTHING`
+	_, err := db.Exec(ctx, insertQ, b.Name)
+	if err != nil {
+		return errors.Wrap(err, "recording backfill")
+	}
+	if b.Count != "" {
+		err = countBackfill(ctx, db, b)
+		if err != nil {
+			return err
+		}
+	}
+
+	batchSize := b.BatchSize
+	if batchSize == 0 {
+		batchSize = defaultBatchSize
+	}
+	for {
+		var done bool
+		err := sql.RunTx(ctx, db, func(tx *sql.Tx) error {
+			var (
+				pos       int64
+				completed bool
+			)
+			done = false
+
+			// Lock the checkpoint, so that a concurrent
+			// process waits and then sees this batch.
+			const q = `
+				SELECT checkpoint, completed_at IS NOT NULL FROM migration_backfills
+				WHERE name=$1 FOR UPDATE
+			`
+			err := tx.QueryRow(ctx, q, b.Name).Scan(&pos, &completed)
+			if err != nil {
+				return errors.Wrap(err, "loading checkpoint")
+			}
+			if completed {
+				done = true
+				return nil
+			}
+
+			var next, n int64
+			err = tx.QueryRow(ctx, b.Step, pos, batchSize).Scan(&next, &n)
+			if err != nil {
+				return errors.Wrap(err, "processing batch")
+			}
+			if n == 0 {
+				done = true
+				const doneQ = `
+					UPDATE migration_backfills SET completed_at=now(), updated_at=now()
+					WHERE name=$1
+				`
+				_, err = tx.Exec(ctx, doneQ, b.Name)
+				return errors.Wrap(err, "recording completion")
+			}
+
+			const checkpointQ = `
+				UPDATE migration_backfills SET checkpoint=$2, processed=processed+$3, updated_at=now()
+				WHERE name=$1
+			`
+			_, err = tx.Exec(ctx, checkpointQ, b.Name, next, n)
+			if err != nil {
+				return errors.Wrap(err, "recording checkpoint")
+			}
+			log.Debugkv(ctx, "backfill", b.Name, "position", next, "rows", n)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if done {
+			log.Printkv(ctx, "backfill", b.Name, "status", "success")
+			return nil
+		}
+		if ctx.Err() != nil {
+			return errors.Wrap(ctx.Err())
+		}
+	}
+}
+
+// countBackfill records the number of rows b will process,
+// the first time it runs.
+func countBackfill(ctx context.Context, db *sql.DB, b backfill) error {
+	var counted bool
+	const q = `SELECT total IS NOT NULL FROM migration_backfills WHERE name=$1`
+	err := db.QueryRow(ctx, q, b.Name).Scan(&counted)
+	if err != nil {
+		return errors.Wrap(err, "loading backfill total")
+	}
+	if counted {
+		return nil
+	}
+	var total int64
+	err = db.QueryRow(ctx, b.Count).Scan(&total)
+	if err != nil {
+		return errors.Wrap(err, "counting backfill rows")
+	}
+	const updateQ = `UPDATE migration_backfills SET total=$2 WHERE name=$1`
+	_, err = db.Exec(ctx, updateQ, b.Name, total)
+	return errors.Wrap(err, "recording backfill total")
+}
+
+// printBackfillStatus prints the progress of each built-in backfill.
+func printBackfillStatus(db pg.DB) error {
+	if len(backfills) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+
+	type status struct {
+		rows        int64
+		total       *int64
+		completedAt *time.Time
+	}
+	statuses := make(map[string]status)
+	var exists bool
+	err := db.QueryRow(ctx, `SELECT to_regclass('migration_backfills') IS NOT NULL`).Scan(&exists)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	if exists {
+		const q = `SELECT name, processed, total, completed_at FROM migration_backfills`
+		err = pg.ForQueryRows(ctx, db, q, func(name string, rows int64, total *int64, completedAt *time.Time) {
+			statuses[name] = status{rows, total, completedAt}
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("\n%-60s\t%-14s\t%s\n", "backfill", "rows", "completed_at")
+	for _, b := range backfills {
+		s, ok := statuses[b.Name]
+		rows := fmt.Sprint(s.rows)
+		if s.total != nil && *s.total > 0 {
+			rows = fmt.Sprintf("%d (%d%%)", s.rows, s.rows*100 / *s.total)
+		}
+		completedAt := "(in progress)"
+		if !ok {
+			completedAt = "(pending)"
+		} else if s.completedAt != nil {
+			completedAt = s.completedAt.Format(time.RFC3339)
+		}
+		fmt.Printf("%-60s\t%-14s\t%s\n", b.Name, rows, completedAt)
+	}
+	return nil
+}
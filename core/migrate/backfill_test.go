@@ -0,0 +1,78 @@
+package migrate
+
+import (
+	"context"
+	"testing"
+
+	"chain/database/pg/pgtest"
+)
+
+func TestRunBackfills(t *testing.T) {
+	save := backfills
+	defer func() { backfills = save }()
+
+	ctx := context.Background()
+	_, db := pgtest.NewDB(t, "testdata/empty.sql")
+
+	_, err := db.Exec(ctx, `
+		CREATE TABLE items (id bigint PRIMARY KEY, n bigint, doubled bigint);
+		INSERT INTO items (id, n) SELECT i, i FROM generate_series(1, 25) i;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backfills = []backfill{{
+		Name: "double-items",
+		Step: `
+			WITH batch AS (
+				UPDATE items SET doubled = 2*n WHERE id IN (
+					SELECT id FROM items WHERE id > $1 ORDER BY id LIMIT $2
+				) RETURNING id
+			)
+			SELECT COALESCE(MAX(id), $1), COUNT(*) FROM batch
+		`,
+		Count:     `SELECT COUNT(*) FROM items`,
+		BatchSize: 10,
+	}}
+
+	// Start partway through, as though
+	// resuming after a crash.
+	_, err = db.Exec(ctx, createBackfillTableSQL+`
+		INSERT INTO migration_backfills (name, checkpoint, processed) VALUES ('double-items', 5, 5);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = RunBackfills(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var done, skipped int
+	const q = `SELECT COUNT(doubled), COUNT(*) - COUNT(doubled) FROM items`
+	err = db.QueryRow(ctx, q).Scan(&done, &skipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if done != 20 || skipped != 5 {
+		t.Errorf("backfilled %d rows and skipped %d, want 20 and 5", done, skipped)
+	}
+
+	var (
+		rows, total int64
+		completed   bool
+	)
+	const statusQ = `
+		SELECT processed, total, completed_at IS NOT NULL FROM migration_backfills
+		WHERE name='double-items'
+	`
+	err = db.QueryRow(ctx, statusQ).Scan(&rows, &total, &completed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows != 25 || total != 25 || !completed {
+		t.Errorf("status: rows = %d total = %d completed = %v, want 25, 25, true", rows, total, completed)
+	}
+}
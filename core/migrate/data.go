@@ -10,6 +10,7 @@ import (
 type migration struct {
 	Name      string
 	SQL       string
+	Down      string    // reverts SQL; optional
 	Hash      string    // set in init
 	AppliedAt time.Time // set in loadStatus
 }
@@ -57,4 +58,345 @@ var migrations = []migration{
 		ALTER TABLE account_utxos ALTER COLUMN change SET NOT NULL;
 		COMMIT;
 	`},
+	{Name: `2017-03-20.0.core.config-final-block-height.sql`, SQL: `
+		ALTER TABLE config ADD COLUMN final_block_height bigint DEFAULT 0 NOT NULL;
+	`, Down: `
+		ALTER TABLE config DROP COLUMN final_block_height;
+	`},
+	{Name: `2017-03-21.0.core.mempool-txs.sql`, SQL: `
+		CREATE TABLE mempool_txs (
+			tx_hash bytea NOT NULL PRIMARY KEY,
+			data bytea NOT NULL,
+			added_at timestamp with time zone NOT NULL,
+			seq bigserial NOT NULL
+		);
+	`, Down: `
+		DROP TABLE mempool_txs;
+	`},
+	{Name: `2017-03-22.0.core.account-events.sql`, SQL: `
+		CREATE TABLE account_events (
+			seq bigserial NOT NULL PRIMARY KEY,
+			type text NOT NULL,
+			account_id text NOT NULL,
+			tx_hash bytea NOT NULL,
+			output_id bytea DEFAULT ''::bytea NOT NULL,
+			block_height bigint DEFAULT 0 NOT NULL,
+			created_at timestamp with time zone DEFAULT now() NOT NULL,
+			UNIQUE (account_id, type, tx_hash, output_id)
+		);
+		CREATE INDEX account_events_account_id_seq_idx ON account_events (account_id, seq);
+	`, Down: `
+		DROP TABLE account_events;
+	`},
+	{Name: `2017-03-23.0.core.snapshot-diffs.sql`, SQL: `
+		CREATE TABLE snapshot_diffs (
+			height bigint NOT NULL PRIMARY KEY,
+			base_height bigint NOT NULL,
+			data bytea NOT NULL,
+			created_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+	`, Down: `
+		DROP TABLE snapshot_diffs;
+	`},
+	{Name: `2017-03-24.0.core.asset-successions.sql`, SQL: `
+		CREATE TABLE asset_successions (
+			predecessor_id bytea NOT NULL PRIMARY KEY,
+			successor_id bytea NOT NULL UNIQUE,
+			tx_hash bytea NOT NULL,
+			block_height bigint NOT NULL
+		);
+		CREATE INDEX asset_successions_block_height_idx ON asset_successions (block_height);
+
+		ALTER TABLE annotated_outputs ADD COLUMN asset_lineage_id bytea;
+		UPDATE annotated_outputs SET asset_lineage_id = asset_id;
+		ALTER TABLE annotated_outputs ALTER COLUMN asset_lineage_id SET NOT NULL;
+		CREATE INDEX annotated_outputs_asset_lineage_id_idx ON annotated_outputs (asset_lineage_id);
+	`, Down: `
+		ALTER TABLE annotated_outputs DROP COLUMN asset_lineage_id;
+		DROP TABLE asset_successions;
+	`},
+	{Name: `2017-03-25.0.core.query-functions.sql`, SQL: `
+		CREATE FUNCTION query_tx_after(begin_ms bigint, end_ms bigint, OUT from_height bigint, OUT stop_height bigint)
+			LANGUAGE plpgsql STABLE AS $$
+		BEGIN
+			SELECT COALESCE(MAX(height), 0), COALESCE(MIN(height), 0) INTO from_height, stop_height
+			FROM query_blocks WHERE timestamp >= begin_ms AND timestamp <= end_ms;
+		END;
+		$$;
+
+		CREATE FUNCTION query_block_timestamp(block_height bigint) RETURNS bigint
+			LANGUAGE plpgsql STABLE AS $$
+		BEGIN
+			RETURN (SELECT timestamp FROM query_blocks WHERE height = block_height);
+		END;
+		$$;
+
+		CREATE FUNCTION query_spend_outputs(spent_ms bigint, output_ids bytea[]) RETURNS void
+			LANGUAGE plpgsql AS $$
+		BEGIN
+			UPDATE annotated_outputs SET timespan = INT8RANGE(LOWER(timespan), spent_ms)
+			WHERE output_id = ANY(output_ids);
+		END;
+		$$;
+	`, Down: `
+		DROP FUNCTION query_tx_after(bigint, bigint);
+		DROP FUNCTION query_block_timestamp(bigint);
+		DROP FUNCTION query_spend_outputs(bigint, bytea[]);
+	`},
+	{Name: `2017-03-27.0.core.access-token-expiry.sql`, SQL: `
+		ALTER TABLE access_tokens ADD COLUMN expires_at timestamp with time zone;
+	`, Down: `
+		ALTER TABLE access_tokens DROP COLUMN expires_at;
+	`},
+	{Name: `2017-03-28.0.core.access-token-scopes.sql`, SQL: `
+		ALTER TABLE access_tokens ADD COLUMN scopes text[];
+	`, Down: `
+		ALTER TABLE access_tokens DROP COLUMN scopes;
+	`},
+	{Name: `2017-03-29.0.core.access-token-salted-secret.sql`, SQL: `
+		ALTER TABLE access_tokens
+			ADD COLUMN secret_hash bytea,
+			ALTER COLUMN hashed_secret DROP NOT NULL;
+	`},
+	{Name: `2017-03-30.0.core.access-token-usage.sql`, SQL: `
+		ALTER TABLE access_tokens
+			ADD COLUMN last_used_at timestamp with time zone,
+			ADD COLUMN last_used_ip text,
+			ADD COLUMN request_count bigint DEFAULT 0 NOT NULL;
+	`, Down: `
+		ALTER TABLE access_tokens
+			DROP COLUMN last_used_at,
+			DROP COLUMN last_used_ip,
+			DROP COLUMN request_count;
+	`},
+	{Name: `2017-03-31.0.core.config-history.sql`, SQL: `
+		CREATE TABLE config_history (
+			version bigserial PRIMARY KEY,
+			saved_at timestamp with time zone DEFAULT now() NOT NULL,
+			data jsonb NOT NULL
+		);
+	`, Down: `
+		DROP TABLE config_history;
+	`},
+	{Name: `2017-04-01.0.core.config-block-period.sql`, SQL: `
+		ALTER TABLE config ADD COLUMN block_period_ms bigint DEFAULT 0 NOT NULL;
+	`, Down: `
+		ALTER TABLE config DROP COLUMN block_period_ms;
+	`},
+	{Name: `2017-04-02.0.core.signer-rotations.sql`, SQL: `
+		CREATE TABLE signer_rotations (
+			height bigint PRIMARY KEY,
+			signers jsonb NOT NULL,
+			quorum integer NOT NULL,
+			program bytea NOT NULL,
+			proposed_at timestamp with time zone DEFAULT now() NOT NULL,
+			applied_block_hash bytea
+		);
+	`, Down: `
+		DROP TABLE signer_rotations;
+	`},
+	{Name: `2017-04-03.0.core.mockhsm-key-versions.sql`, SQL: `
+		CREATE TABLE mockhsm_key_versions (
+			alias text NOT NULL,
+			version integer NOT NULL,
+			pub bytea NOT NULL UNIQUE,
+			created_at timestamp with time zone DEFAULT now() NOT NULL,
+			PRIMARY KEY (alias, version)
+		);
+		INSERT INTO mockhsm_key_versions (alias, version, pub)
+		SELECT alias, 1, pub FROM mockhsm WHERE alias IS NOT NULL AND key_type = 'ed25519';
+	`, Down: `
+		DROP TABLE mockhsm_key_versions;
+	`},
+	{Name: `2017-04-05.0.core.mockhsm-key-policies.sql`, SQL: `
+		CREATE TABLE mockhsm_key_policies (
+			pub bytea PRIMARY KEY REFERENCES mockhsm ON DELETE CASCADE,
+			tokens text[] DEFAULT '{}' NOT NULL,
+			purposes text[] DEFAULT '{}' NOT NULL,
+			max_per_minute integer DEFAULT 0 NOT NULL
+		);
+	`, Down: `
+		DROP TABLE mockhsm_key_policies;
+	`},
+	{Name: `2017-04-06.0.core.mempool-submitter.sql`, SQL: `
+		ALTER TABLE mempool_txs ADD COLUMN submitter text DEFAULT '' NOT NULL;
+	`, Down: `
+		ALTER TABLE mempool_txs DROP COLUMN submitter;
+	`},
+	{Name: `2017-04-07.0.core.generator-idempotency-keys.sql`, SQL: `
+		CREATE TABLE generator_idempotency_keys (
+			submitter text NOT NULL,
+			key text NOT NULL,
+			tx_hash bytea NOT NULL,
+			created_at timestamp with time zone DEFAULT now() NOT NULL,
+			PRIMARY KEY (submitter, key)
+		);
+	`, Down: `
+		DROP TABLE generator_idempotency_keys;
+	`},
+	{Name: `2017-04-08.0.query.account-balances.sql`, SQL: `
+		CREATE TABLE account_balances (
+			account_id text NOT NULL,
+			asset_id bytea NOT NULL,
+			block_height bigint NOT NULL,
+			block_timestamp bigint NOT NULL,
+			amount bigint NOT NULL,
+			PRIMARY KEY (account_id, asset_id, block_height)
+		);
+		CREATE INDEX account_balances_timestamp_idx ON account_balances (account_id, asset_id, block_timestamp);
+
+		-- Backfill the balances of the blocks indexed so far.
+		INSERT INTO account_balances (account_id, asset_id, block_height, block_timestamp, amount)
+		SELECT d.account_id, d.asset_id, d.block_height, qb.timestamp,
+			SUM(d.delta) OVER (PARTITION BY d.account_id, d.asset_id ORDER BY d.block_height)::bigint
+		FROM (
+			SELECT account_id, asset_id, block_height, SUM(amount)::bigint AS delta FROM (
+				SELECT account_id, asset_id, block_height, amount FROM annotated_outputs
+				WHERE account_id IS NOT NULL
+				UNION ALL
+				SELECT inp.account_id, inp.asset_id, txs.block_height, -inp.amount
+				FROM annotated_inputs inp JOIN annotated_txs txs ON txs.tx_hash = inp.tx_hash
+				WHERE inp.type = 'spend' AND inp.account_id IS NOT NULL
+			) AS changes
+			GROUP BY account_id, asset_id, block_height HAVING SUM(amount) <> 0
+		) AS d JOIN query_blocks qb ON qb.height = d.block_height;
+	`, Down: `
+		DROP TABLE account_balances;
+	`},
+	{Name: `2017-04-09.0.query.reindex.sql`, SQL: `
+		CREATE TABLE query_reindex (
+			singleton boolean DEFAULT true NOT NULL PRIMARY KEY,
+			requested_at timestamp with time zone DEFAULT now() NOT NULL,
+			started_at timestamp with time zone,
+			finished_at timestamp with time zone,
+			height bigint DEFAULT 0 NOT NULL,
+			error text DEFAULT '' NOT NULL,
+			CONSTRAINT query_reindex_singleton CHECK (singleton)
+		);
+	`, Down: `
+		DROP TABLE query_reindex;
+		DROP SCHEMA IF EXISTS query_rebuild CASCADE;
+	`},
+	{Name: `2017-04-10.0.core.webhooks.sql`, SQL: `
+		CREATE TABLE webhooks (
+			id text DEFAULT next_chain_id('whk'::text) NOT NULL PRIMARY KEY,
+			alias text UNIQUE,
+			url text NOT NULL,
+			filter text NOT NULL,
+			secret text NOT NULL,
+			after text NOT NULL,
+			attempts integer DEFAULT 0 NOT NULL,
+			next_attempt_at timestamp with time zone DEFAULT now() NOT NULL,
+			last_error text DEFAULT '' NOT NULL,
+			client_token text UNIQUE
+		);
+		CREATE TABLE webhook_dead_letters (
+			id bigserial PRIMARY KEY,
+			webhook_id text NOT NULL REFERENCES webhooks ON DELETE CASCADE,
+			after text NOT NULL,
+			payload text NOT NULL,
+			attempts integer NOT NULL,
+			error text NOT NULL,
+			created_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+	`, Down: `
+		DROP TABLE webhook_dead_letters;
+		DROP TABLE webhooks;
+	`},
+	{Name: `2017-04-10.1.core.webhook-secret-rotation.sql`, SQL: `
+		ALTER TABLE webhooks
+			ADD COLUMN previous_secret text DEFAULT '' NOT NULL,
+			ADD COLUMN previous_secret_expires_at timestamp with time zone;
+	`, Down: `
+		ALTER TABLE webhooks
+			DROP COLUMN previous_secret,
+			DROP COLUMN previous_secret_expires_at;
+	`},
+	{Name: `2017-04-11.0.account.receiver-index.sql`, SQL: `
+		ALTER TABLE accounts ADD COLUMN next_receiver_index bigint DEFAULT 0 NOT NULL;
+		CREATE INDEX annotated_outputs_control_program_idx ON annotated_outputs (control_program);
+	`, Down: `
+		DROP INDEX annotated_outputs_control_program_idx;
+		ALTER TABLE accounts DROP COLUMN next_receiver_index;
+	`},
+	{Name: `2017-04-12.0.account.soft-delete.sql`, SQL: `
+		ALTER TABLE accounts ADD COLUMN deleted_at timestamp with time zone;
+		ALTER TABLE annotated_accounts ADD COLUMN deleted boolean DEFAULT false NOT NULL;
+	`, Down: `
+		ALTER TABLE annotated_accounts DROP COLUMN deleted;
+		ALTER TABLE accounts DROP COLUMN deleted_at;
+	`},
+	{Name: `2017-04-13.0.asset.issuance-policies.sql`, SQL: `
+		CREATE TABLE asset_issuance_policies (
+			asset_id bytea PRIMARY KEY REFERENCES assets ON DELETE CASCADE,
+			max_outstanding bigint DEFAULT 0 NOT NULL,
+			tokens text[] DEFAULT '{}' NOT NULL,
+			max_per_hour bigint DEFAULT 0 NOT NULL
+		);
+	`, Down: `
+		DROP TABLE asset_issuance_policies;
+	`},
+	{Name: `2017-04-14.0.asset.definition-versions.sql`, SQL: `
+		CREATE TABLE asset_definition_versions (
+			asset_id bytea NOT NULL REFERENCES assets ON DELETE CASCADE,
+			version bigint NOT NULL,
+			definition bytea NOT NULL,
+			tx_hash bytea NOT NULL,
+			block_height bigint NOT NULL,
+			PRIMARY KEY (asset_id, version),
+			UNIQUE (asset_id, tx_hash)
+		);
+		CREATE INDEX asset_definition_versions_block_height_idx ON asset_definition_versions (block_height);
+		ALTER TABLE annotated_assets ADD COLUMN definition_version bigint DEFAULT 0 NOT NULL;
+	`, Down: `
+		ALTER TABLE annotated_assets DROP COLUMN definition_version;
+		DROP TABLE asset_definition_versions;
+	`},
+	{Name: `2017-04-15.0.core.authz-grants.sql`, SQL: `
+		CREATE TABLE authz_grants (
+			guard_type text NOT NULL,
+			guard_data jsonb NOT NULL,
+			policy text NOT NULL,
+			created_at timestamp with time zone DEFAULT now() NOT NULL,
+			PRIMARY KEY (guard_type, guard_data, policy)
+		);
+		-- Keep the access that tokens had before grants:
+		-- client tokens could use every client route,
+		-- and network tokens every network route.
+		INSERT INTO authz_grants (guard_type, guard_data, policy) VALUES
+			('access_token', '{"type": "client"}', 'admin'),
+			('access_token', '{"type": "network"}', 'network');
+	`, Down: `
+		DROP TABLE authz_grants;
+	`},
+	{Name: `2017-04-16.0.core.audit-log.sql`, SQL: `
+		CREATE TABLE audit_log (
+			seq bigint PRIMARY KEY,
+			timestamp timestamp with time zone NOT NULL,
+			actor text NOT NULL,
+			action text NOT NULL,
+			detail bytea NOT NULL,
+			prev_hash bytea NOT NULL,
+			hash bytea NOT NULL
+		);
+		CREATE FUNCTION audit_log_append_only() RETURNS trigger
+			LANGUAGE plpgsql
+			AS $$
+		BEGIN
+			RAISE EXCEPTION 'audit_log is append-only';
+		END;
+		$$;
+		CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+			FOR EACH ROW EXECUTE PROCEDURE audit_log_append_only();
+		CREATE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON audit_log
+			FOR EACH STATEMENT EXECUTE PROCEDURE audit_log_append_only();
+	`, Down: `
+		DROP TABLE audit_log;
+		DROP FUNCTION audit_log_append_only();
+	`},
+	{Name: `2017-04-17.0.core.access-token-hmac.sql`, SQL: `
+		ALTER TABLE access_tokens ADD COLUMN secret_salt bytea;
+	`, Down: `
+		ALTER TABLE access_tokens DROP COLUMN secret_salt;
+	`},
 }
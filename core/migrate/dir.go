@@ -0,0 +1,84 @@
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"chain/errors"
+)
+
+// migrationName matches the names of migrations:
+// the date, a sequence number for the date, the
+// component migrated, and a short description,
+// as in 2017-03-25.0.core.query-functions.sql.
+var migrationName = regexp.MustCompile(`^([0-9]{4}-[0-9]{2}-[0-9]{2}\.[0-9]+)\.[a-z0-9-]+\.[a-z0-9-]+\.sql$`)
+
+var (
+	// ErrBadName is returned by LoadDir for a
+	// file not named like a migration.
+	ErrBadName = errors.New("bad migration file name")
+
+	// ErrConflict is returned by LoadDir for a migration
+	// with the same date and sequence number as another.
+	ErrConflict = errors.New("conflicting migration")
+)
+
+// LoadDir adds the migrations in the .sql files in dir to the
+// built-in migrations, for schema changes specific to a
+// deployment, such as indexes for custom query filters.
+// Each file must be named like a built-in migration,
+// YYYY-MM-DD.N.component.description.sql, and no two
+// migrations may share a date and sequence number N.
+// All the migrations run in order of their names.
+// It must be called before any other function in this package.
+func LoadDir(dir string) error {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return errors.Wrap(err, "reading migrations directory")
+	}
+
+	ms := make([]migration, len(migrations))
+	copy(ms, migrations)
+	seq := make(map[string]string) // date and sequence number -> name
+	for _, m := range ms {
+		if match := migrationName.FindStringSubmatch(m.Name); match != nil {
+			seq[match[1]] = m.Name
+		}
+	}
+
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+		match := migrationName.FindStringSubmatch(name)
+		if match == nil {
+			return errors.WithDetailf(ErrBadName, "%s does not have the form YYYY-MM-DD.N.component.description.sql", name)
+		}
+		if other, ok := seq[match[1]]; ok {
+			return errors.WithDetailf(ErrConflict, "%s has the same date and sequence number as %s", name, other)
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return errors.Wrap(err, "reading migration")
+		}
+		h := sha256.Sum256(b)
+		ms = append(ms, migration{Name: name, SQL: string(b), Hash: hex.EncodeToString(h[:])})
+		seq[match[1]] = name
+	}
+
+	sort.Sort(byName(ms))
+	migrations = ms
+	return nil
+}
+
+type byName []migration
+
+func (a byName) Len() int           { return len(a) }
+func (a byName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byName) Less(i, j int) bool { return a[i].Name < a[j].Name }
@@ -0,0 +1,88 @@
+package migrate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"chain/errors"
+)
+
+func TestLoadDir(t *testing.T) {
+	save := migrations
+	defer func() { migrations = save }()
+
+	migrations = []migration{
+		{Name: "2017-03-01.0.core.a.sql", SQL: "a"},
+		{Name: "2017-03-03.0.core.c.sql", SQL: "c"},
+	}
+
+	cases := []struct {
+		files     map[string]string
+		wantErr   error
+		wantNames []string
+	}{{
+		files: map[string]string{
+			"2017-03-02.0.custom.b.sql": "b",
+			"2017-03-04.0.custom.d.sql": "d",
+			"README":                    "not a migration",
+		},
+		wantNames: []string{
+			"2017-03-01.0.core.a.sql",
+			"2017-03-02.0.custom.b.sql",
+			"2017-03-03.0.core.c.sql",
+			"2017-03-04.0.custom.d.sql",
+		},
+	}, {
+		files:   map[string]string{"2017-03-03.0.custom.c.sql": "c2"},
+		wantErr: ErrConflict,
+	}, {
+		files:   map[string]string{"extra-index.sql": "x"},
+		wantErr: ErrBadName,
+	}}
+
+	for i, c := range cases {
+		dir, err := ioutil.TempDir("", "migrations")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		for name, sql := range c.files {
+			err = ioutil.WriteFile(filepath.Join(dir, name), []byte(sql), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		before := migrations
+		err = LoadDir(dir)
+		if errors.Root(err) != c.wantErr {
+			t.Errorf("case %d: err = %v, want %v", i, err, c.wantErr)
+		}
+		if err != nil {
+			if len(migrations) != len(before) {
+				t.Errorf("case %d: migrations changed despite error", i)
+			}
+			continue
+		}
+		var names []string
+		for _, m := range migrations {
+			names = append(names, m.Name)
+			if m.Hash == "" && c.files[m.Name] != "" {
+				t.Errorf("case %d: %s has no hash", i, m.Name)
+			}
+		}
+		if len(names) != len(c.wantNames) {
+			t.Errorf("case %d: names = %v, want %v", i, names, c.wantNames)
+			continue
+		}
+		for j := range names {
+			if names[j] != c.wantNames[j] {
+				t.Errorf("case %d: names = %v, want %v", i, names, c.wantNames)
+				break
+			}
+		}
+		migrations = before
+	}
+}
@@ -4,14 +4,21 @@ package migrate
 import (
 	"context"
 	"fmt"
+	"io"
+	"strings"
 	"time"
 
+	"chain/core/audit"
 	"chain/database/pg"
+	"chain/database/sql"
 	"chain/errors"
 	"chain/log"
 )
 
 // Run runs all built-in migrations.
+// It does not coordinate with other processes;
+// use RunExclusive where several might migrate
+// the same database at once.
 func Run(db pg.DB) error {
 	ctx := context.Background()
 
@@ -31,6 +38,7 @@ func Run(db pg.DB) error {
 		return err
 	}
 
+	var applied []string
 	for _, m := range migrations {
 		if !m.AppliedAt.IsZero() {
 			continue
@@ -50,11 +58,283 @@ func Run(db pg.DB) error {
 		}
 
 		log.Printkv(ctx, "migration", m.Name, "status", "success")
+		applied = append(applied, m.Name)
+	}
+	return recordAudit(ctx, db, "migrate", applied)
+}
+
+var (
+	// ErrSchemaBehind is returned by Check when built-in
+	// migrations have not been applied to the database.
+	ErrSchemaBehind = errors.New("database schema is out of date; run corectl migrate")
+
+	// ErrSchemaAhead is returned by Check when the database
+	// has migrations applied that are not built in, because
+	// it was migrated by a newer version of Chain Core.
+	ErrSchemaAhead = errors.New("database schema is newer than this version of Chain Core")
+)
+
+// Check verifies that the migrations applied to db are exactly
+// the built-in migrations, without running any. Use it in place
+// of Run to fail fast when the schema doesn't match.
+func Check(db pg.DB) error {
+	ctx := context.Background()
+
+	ms := make([]migration, len(migrations))
+	copy(ms, migrations)
+	err := loadStatus(db, ms)
+	if err != nil {
+		return err
+	}
+	for _, m := range ms {
+		if m.AppliedAt.IsZero() {
+			return errors.WithDetailf(ErrSchemaBehind, "migration %s is pending", m.Name)
+		}
+	}
+
+	// Every built-in migration has been applied,
+	// so the migrations table exists.
+	return checkUnknown(ctx, db, ms)
+}
+
+// checkUnknown returns ErrSchemaAhead if db has
+// a migration applied that is not in ms.
+func checkUnknown(ctx context.Context, db pg.DB, ms []migration) error {
+	var unknown []string
+	err := pg.ForQueryRows(ctx, db, `SELECT filename FROM migrations ORDER BY filename`, func(name string) {
+		if find(name, ms) == nil {
+			unknown = append(unknown, name)
+		}
+	})
+	if err != nil {
+		return errors.Wrap(err, "loading applied migrations")
+	}
+	if len(unknown) > 0 {
+		return errors.WithDetailf(ErrSchemaAhead, "migration %s is not built in", unknown[0])
+	}
+	return nil
+}
+
+// ErrHashMismatch is returned when the SQL of a built-in
+// migration differs from the SQL that was applied under the
+// same name, as recorded by its hash. The database's migration
+// history, and so its schema, is not the one this build expects,
+// for example because it was migrated by a fork of Chain Core.
+// Every function that loads the migration status checks this.
+var ErrHashMismatch = errors.New("migration was applied with different SQL")
+
+// ErrIrreversible is returned by Rollback when
+// a migration to revert has no Down SQL.
+var ErrIrreversible = errors.New("migration cannot be reverted")
+
+// Rollback reverts the n most recently applied built-in
+// migrations, newest first, by running their Down SQL.
+// It reverts nothing if any of them has no Down SQL,
+// or if db has migrations applied that are not built in.
+func Rollback(db pg.DB, n int) error {
+	ctx := context.Background()
+
+	ms := make([]migration, len(migrations))
+	copy(ms, migrations)
+	err := loadStatus(db, ms)
+	if err != nil {
+		return err
+	}
+
+	var revert []migration
+	for i := len(ms) - 1; i >= 0 && len(revert) < n; i-- {
+		if !ms[i].AppliedAt.IsZero() {
+			revert = append(revert, ms[i])
+		}
+	}
+	if len(revert) < n {
+		return errors.Wrap(fmt.Errorf("cannot revert %d migrations, only %d applied", n, len(revert)))
+	}
+	for _, m := range revert {
+		if m.Down == "" {
+			return errors.WithDetailf(ErrIrreversible, "migration %s has no down SQL", m.Name)
+		}
+	}
+	if n > 0 {
+		err = checkUnknown(ctx, db, ms)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, m := range revert {
+		fmt.Println("Reverting migration:", m.Name)
+		_, err := db.Exec(ctx, m.Down)
+		if err != nil {
+			return errors.Wrapf(err, "reverting migration %s", m.Name)
+		}
+
+		// As in Run, the migration and the change to
+		// its status cannot be in the same transaction.
+		_, err = db.Exec(ctx, `DELETE FROM migrations WHERE filename=$1`, m.Name)
+		if err != nil {
+			return errors.Wrap(err, "recording reverted migration")
+		}
+
+		log.Printkv(ctx, "migration", m.Name, "status", "reverted")
+	}
+
+	var names []string
+	for _, m := range revert {
+		names = append(names, m.Name)
+	}
+	return recordAudit(ctx, db, "rollback-migrations", names)
+}
+
+// recordAudit records the migrations named in names
+// in the audit log, if there are any, and if the audit
+// log exists at the current version of the schema.
+func recordAudit(ctx context.Context, db pg.DB, action string, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	var exists bool
+	err := db.QueryRow(ctx, `SELECT to_regclass('audit_log') IS NOT NULL`).Scan(&exists)
+	if err != nil || !exists {
+		return errors.Wrap(err, "checking for audit log")
+	}
+	_, err = audit.Record(ctx, db, audit.LocalActor(), action, map[string]interface{}{"migrations": names})
+	return err
+}
+
+// A Result describes the outcome of RunExclusive.
+type Result int
+
+const (
+	// Migrated means this process ran any pending migrations.
+	Migrated Result = iota
+
+	// MigratedElsewhere means another process ran
+	// the pending migrations while this one waited.
+	MigratedElsewhere
+)
+
+// migrationLockKey identifies the Postgres advisory lock
+// held while migrating. It is arbitrary, but must not change.
+const migrationLockKey = 0x636f72656d696772
+
+// lockPollInterval is how often RunExclusive
+// tries again to take the migration lock.
+var lockPollInterval = time.Second
+
+// RunExclusive is like Run, but first takes a Postgres
+// advisory lock, so that processes starting at the same time
+// against the same database migrate it one at a time.
+// While another process holds the lock, RunExclusive polls
+// until it is released. If by then the other process has
+// run every pending migration, it returns MigratedElsewhere.
+func RunExclusive(db *sql.DB) (Result, error) {
+	ctx := context.Background()
+
+	// The lock is held by a transaction, so that it stays on a
+	// single connection; the migrations, which can't all run in
+	// a transaction, use other connections. Rollback releases it.
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "begin transaction for migration lock")
+	}
+	defer tx.Rollback(ctx)
+
+	var waited bool
+	for {
+		var locked bool
+		const q = `SELECT pg_try_advisory_xact_lock($1)`
+		err = tx.QueryRow(ctx, q, migrationLockKey).Scan(&locked)
+		if err != nil {
+			return 0, errors.Wrap(err, "taking migration lock")
+		}
+		if locked {
+			break
+		}
+		if !waited {
+			log.Printkv(ctx, "at", "waiting for migrations in another process")
+			waited = true
+		}
+		time.Sleep(lockPollInterval)
+	}
+
+	if waited {
+		ms := make([]migration, len(migrations))
+		copy(ms, migrations)
+		err = loadStatus(db, ms)
+		if err != nil {
+			return 0, err
+		}
+		if !hasPending(ms) {
+			log.Printkv(ctx, "at", "migrated by another process")
+			return MigratedElsewhere, nil
+		}
+	}
+	return Migrated, Run(db)
+}
+
+func hasPending(ms []migration) bool {
+	for _, m := range ms {
+		if m.AppliedAt.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+// A PlannedMigration is a built-in migration
+// not yet applied to a database.
+type PlannedMigration struct {
+	Name string
+	SQL  string
+	Hash string
+}
+
+// Plan returns the built-in migrations that Run would apply
+// to db, in order, without applying any.
+func Plan(db pg.DB) ([]PlannedMigration, error) {
+	ms := make([]migration, len(migrations))
+	copy(ms, migrations)
+	err := loadStatus(db, ms)
+	if err != nil {
+		return nil, err
+	}
+	var plan []PlannedMigration
+	for _, m := range ms {
+		if m.AppliedAt.IsZero() {
+			plan = append(plan, PlannedMigration{Name: m.Name, SQL: m.SQL, Hash: m.Hash})
+		}
+	}
+	return plan, nil
+}
+
+// WritePlan writes the SQL of the migrations in plan to w,
+// as a script to be applied in place of Run, for example
+// with psql. After each migration, the script records it in
+// table migrations, as Run would. Like Run, it does not wrap
+// the migrations in a transaction, since some of them can't
+// run in one.
+func WritePlan(w io.Writer, plan []PlannedMigration) error {
+	_, err := fmt.Fprintf(w, "-- %d pending Chain Core migrations\n%s", len(plan), createMigrationTableSQL)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	for _, m := range plan {
+		_, err = fmt.Fprintf(w, "\n-- %s\n%s\nINSERT INTO migrations (filename, hash) VALUES (%s, %s);\n",
+			m.Name, m.SQL, quoteLiteral(m.Name), quoteLiteral(m.Hash))
+		if err != nil {
+			return errors.Wrap(err)
+		}
 	}
 	return nil
 }
 
-// PrintStatus prints the status of each built-in migration.
+func quoteLiteral(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// PrintStatus prints the status of each built-in migration,
+// and the progress of each backfill.
 func PrintStatus(db pg.DB) error {
 	err := loadStatus(db, migrations)
 	if err != nil {
@@ -69,7 +349,7 @@ func PrintStatus(db pg.DB) error {
 		}
 		fmt.Printf("%-60s\t%-6s\t%s\n", m.Name, m.Hash[:6], appliedAt)
 	}
-	return nil
+	return printBackfillStatus(db)
 }
 
 const createMigrationTableSQL = `
@@ -120,7 +400,7 @@ func loadStatus(db pg.DB, ms []migration) error {
 		m := find(name, ms)
 		if m != nil {
 			if m.Hash != hash {
-				return errors.Wrap(fmt.Errorf("%s hash mismatch %s != %s", name, hash, m.Hash))
+				return errors.WithDetailf(ErrHashMismatch, "migration %s was applied with hash %s, but this build's has hash %s", name, hash, m.Hash)
 			}
 			m.AppliedAt = t
 		}
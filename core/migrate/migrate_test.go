@@ -1,12 +1,16 @@
 package migrate
 
 import (
+	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"testing"
 	"time"
 
+	"chain/database/pg"
 	"chain/database/pg/pgtest"
+	"chain/errors"
 )
 
 func TestLoadStatus(t *testing.T) {
@@ -123,3 +127,214 @@ func TestApplyMigrationSQL(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestCheck(t *testing.T) {
+	save := migrations
+	defer func() { migrations = save }()
+
+	ctx := context.Background()
+	_, db := pgtest.NewDB(t, "testdata/empty.sql")
+
+	migrations = []migration{{
+		Name: "test-migration",
+		SQL:  `CREATE TABLE test_table (a int);`,
+	}}
+	h := sha256.Sum256([]byte(migrations[0].SQL))
+	migrations[0].Hash = hex.EncodeToString(h[:])
+
+	err := Check(db)
+	if errors.Root(err) != ErrSchemaBehind {
+		t.Fatalf("before Run: err = %v, want %v", err, ErrSchemaBehind)
+	}
+
+	err = Run(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Check(db)
+	if err != nil {
+		t.Fatalf("after Run: err = %v, want nil", err)
+	}
+
+	_, err = db.Exec(ctx, `INSERT INTO migrations (filename, hash) VALUES ('newer-migration', 'x')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Check(db)
+	if errors.Root(err) != ErrSchemaAhead {
+		t.Fatalf("with unknown migration: err = %v, want %v", err, ErrSchemaAhead)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	save := migrations
+	defer func() { migrations = save }()
+
+	ctx := context.Background()
+	_, db := pgtest.NewDB(t, "testdata/empty.sql")
+
+	migrations = []migration{
+		{Name: "a", SQL: `CREATE TABLE a (x int);`},
+		{Name: "b", SQL: `CREATE TABLE b (x int);`, Down: `DROP TABLE b;`},
+	}
+	for i, m := range migrations {
+		h := sha256.Sum256([]byte(m.SQL))
+		migrations[i].Hash = hex.EncodeToString(h[:])
+	}
+	err := Run(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Rollback(db, 2)
+	if errors.Root(err) != ErrIrreversible {
+		t.Fatalf("Rollback(2): err = %v, want %v", err, ErrIrreversible)
+	}
+
+	err = Rollback(db, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tables []string
+	const q = `SELECT tablename FROM pg_tables WHERE schemaname='public' AND tablename IN ('a', 'b')`
+	err = pg.ForQueryRows(ctx, db, q, func(name string) { tables = append(tables, name) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 1 || tables[0] != "a" {
+		t.Errorf("after Rollback(1): tables = %v, want [a]", tables)
+	}
+	err = Check(db)
+	if errors.Root(err) != ErrSchemaBehind {
+		t.Errorf("after Rollback(1): Check err = %v, want %v", err, ErrSchemaBehind)
+	}
+}
+
+func TestRunExclusiveWaits(t *testing.T) {
+	save, savePoll := migrations, lockPollInterval
+	defer func() { migrations, lockPollInterval = save, savePoll }()
+	lockPollInterval = 10 * time.Millisecond
+
+	ctx := context.Background()
+	_, db := pgtest.NewDB(t, "testdata/empty.sql")
+
+	migrations = []migration{{
+		Name: "test-migration",
+		SQL:  `CREATE TABLE test_table (a int);`,
+	}}
+	h := sha256.Sum256([]byte(migrations[0].SQL))
+	migrations[0].Hash = hex.EncodeToString(h[:])
+
+	// Hold the lock, as another process would,
+	// and migrate while RunExclusive waits.
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		err := Run(db)
+		if err != nil {
+			t.Error(err)
+		}
+		tx.Rollback(ctx)
+	}()
+
+	res, err := RunExclusive(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != MigratedElsewhere {
+		t.Errorf("result = %v, want MigratedElsewhere", res)
+	}
+
+	res, err = RunExclusive(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != Migrated {
+		t.Errorf("second run: result = %v, want Migrated", res)
+	}
+}
+
+func TestHashMismatch(t *testing.T) {
+	save := migrations
+	defer func() { migrations = save }()
+
+	_, db := pgtest.NewDB(t, "testdata/empty.sql")
+
+	setMigrations := func(sql string) {
+		h := sha256.Sum256([]byte(sql))
+		migrations = []migration{{
+			Name: "test-migration",
+			SQL:  sql,
+			Hash: hex.EncodeToString(h[:]),
+		}}
+	}
+
+	setMigrations(`CREATE TABLE test_table (a int);`)
+	err := Run(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setMigrations(`CREATE TABLE test_table (a bigint);`)
+	err = Run(db)
+	if errors.Root(err) != ErrHashMismatch {
+		t.Errorf("Run: err = %v, want %v", err, ErrHashMismatch)
+	}
+	err = Check(db)
+	if errors.Root(err) != ErrHashMismatch {
+		t.Errorf("Check: err = %v, want %v", err, ErrHashMismatch)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	save := migrations
+	defer func() { migrations = save }()
+
+	_, db := pgtest.NewDB(t, "testdata/empty.sql")
+
+	migrations = []migration{
+		{Name: "a", SQL: `CREATE TABLE a (x int);`},
+		{Name: "b", SQL: `CREATE TABLE b (x text DEFAULT 'b');`},
+	}
+	for i, m := range migrations {
+		h := sha256.Sum256([]byte(m.SQL))
+		migrations[i].Hash = hex.EncodeToString(h[:])
+	}
+	a, b := migrations[0], migrations[1]
+	migrations = migrations[:1]
+	err := Run(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrations = []migration{a, b}
+
+	plan, err := Plan(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan) != 1 || plan[0].Name != "b" {
+		t.Fatalf("plan = %+v, want only b", plan)
+	}
+
+	// Applying the plan's script is equivalent to Run.
+	var buf bytes.Buffer
+	err = WritePlan(&buf, plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(context.Background(), buf.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Check(db)
+	if err != nil {
+		t.Errorf("after applying plan: Check err = %v, want nil", err)
+	}
+}
@@ -35,6 +35,9 @@ type HSM struct {
 	cacheMu sync.Mutex
 	kdCache map[chainkd.XPub]chainkd.XPrv
 	edCache map[string]ed25519.PrivateKey // ed25519.PublicKeys must be turned into strings before being used as map keys
+
+	rateMu sync.Mutex
+	rates  map[string]*rateWindow // keyed by pub, as for edCache
 }
 
 type XPub struct {
@@ -52,6 +55,7 @@ func New(db pg.DB) *HSM {
 		db:      db,
 		kdCache: make(map[chainkd.XPub]chainkd.XPrv),
 		edCache: make(map[string]ed25519.PrivateKey),
+		rates:   make(map[string]*rateWindow),
 	}
 }
 
@@ -133,6 +137,12 @@ func (h *HSM) createEd25519Key(ctx context.Context, alias string, get bool) (*Pu
 		}
 		return nil, false, errors.Wrap(err, "storing new pub")
 	}
+	if alias != "" {
+		err = h.recordFirstVersion(ctx, alias)
+		if err != nil {
+			return nil, false, err
+		}
+	}
 	return &Pub{Pub: pub, Alias: ptrAlias}, true, nil
 }
 
@@ -273,3 +283,21 @@ func (h *HSM) Sign(ctx context.Context, pub ed25519.PublicKey, bh *bc.BlockHeade
 	msg := bh.Hash()
 	return ed25519.Sign(prv, msg[:]), nil
 }
+
+// MessagePrefix begins every message signed through
+// /mockhsm/sign-message. It keeps a client from getting
+// a signature that also stands for a block, a transaction,
+// or a message the core signs for itself.
+const MessagePrefix = "chain mockhsm signed message\n"
+
+// SignMessage looks up the prv given the pub and signs msg.
+func (h *HSM) SignMessage(ctx context.Context, pub ed25519.PublicKey, msg []byte) ([]byte, error) {
+	prv, err := h.loadEd25519Key(ctx, pub)
+	if err != nil {
+		return nil, err
+	}
+	if len(prv) != ed25519.PrivateKeySize {
+		return nil, ErrInvalidKeySize
+	}
+	return ed25519.Sign(prv, msg), nil
+}
@@ -1,12 +1,14 @@
 package mockhsm
 
 import (
+	"bytes"
 	"context"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
 
 	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/chainkd"
 	"chain/database/pg/pgtest"
 	"chain/errors"
 	"chain/protocol/bc"
@@ -196,6 +198,202 @@ func TestKeyOrdering(t *testing.T) {
 	}
 }
 
+func TestKeyVersions(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	hsm := New(db)
+
+	_, err := hsm.RotateKey(ctx, "blockkey")
+	if errors.Root(err) != ErrNoKey {
+		t.Fatalf("rotating missing key: got error %v want %v", err, ErrNoKey)
+	}
+
+	pub, err := hsm.Create(ctx, "blockkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := hsm.RotateKey(ctx, "blockkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v2.Version != 2 {
+		t.Errorf("rotated version = %d want 2", v2.Version)
+	}
+
+	versions, err := hsm.ListKeyVersions(ctx, "blockkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || !testutil.DeepEqual(versions[0].Pub, pub.Pub) || !testutil.DeepEqual(versions[1], v2) {
+		t.Fatalf("versions = %v", spew.Sdump(versions))
+	}
+
+	msg := []byte("rotate")
+	for _, c := range []struct {
+		version int
+		pub     ed25519.PublicKey
+	}{{0, v2.Pub}, {1, pub.Pub}, {2, v2.Pub}} {
+		sig, err := hsm.SignWithVersion(ctx, "blockkey", c.version, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ed25519.Verify(c.pub, msg, sig) {
+			t.Errorf("signature with version %d does not verify", c.version)
+		}
+	}
+	_, err = hsm.SignWithVersion(ctx, "blockkey", 3, msg)
+	if errors.Root(err) != ErrNoKeyVersion {
+		t.Errorf("signing with missing version: got error %v want %v", err, ErrNoKeyVersion)
+	}
+
+	pubs, err := hsm.KeyVersions(ctx, pub.Pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []ed25519.PublicKey{pub.Pub, v2.Pub}; !testutil.DeepEqual(pubs, want) {
+		t.Errorf("KeyVersions = %x want %x", pubs, want)
+	}
+}
+
+func TestExportImportKeys(t *testing.T) {
+	ctx := context.Background()
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	src := New(db)
+	_, db2 := pgtest.NewDB(t, pgtest.SchemaPath)
+	dst := New(db2)
+
+	kek := bytes.Repeat([]byte{7}, 32)
+	pub, err := src.Create(ctx, "blockkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := src.ExportKey(ctx, pub.Pub, kek)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = dst.ImportWrapped(ctx, "blockkey", w, bytes.Repeat([]byte{8}, 32))
+	if errors.Root(err) != ErrBadWrappedKey {
+		t.Errorf("importing with wrong wrapping key: got error %v want %v", err, ErrBadWrappedKey)
+	}
+	err = dst.ImportWrapped(ctx, "blockkey", w, kek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Importing the same key again is harmless.
+	err = dst.ImportWrapped(ctx, "blockkey", w, kek)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := []byte("migrate")
+	sig, err := dst.SignMessage(ctx, pub.Pub, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.Verify(pub.Pub, msg, sig) {
+		t.Error("signature with imported key does not verify")
+	}
+
+	// A deterministic xprv, as a test environment might seed.
+	var xprv chainkd.XPrv
+	copy(xprv[:], bytes.Repeat([]byte{1}, len(xprv)))
+	xpub, err := dst.ImportXPrv(ctx, "seeded", xprv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if xpub.XPub != xprv.XPub() {
+		t.Errorf("imported xpub = %x want %x", xpub.XPub, xprv.XPub())
+	}
+	sig, err = dst.XSign(ctx, xpub.XPub, nil, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !xpub.XPub.Verify(msg, sig) {
+		t.Error("signature with imported xprv does not verify")
+	}
+}
+
+func TestKeyPolicy(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	hsm := New(db)
+
+	pub, err := hsm.Create(ctx, "blockkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = hsm.Authorize(ctx, pub.Pub, "client", PurposeMessage)
+	if err != nil {
+		t.Errorf("key without policy: got error %v", err)
+	}
+	err = hsm.Authorize(ctx, pub.Pub, "client", PurposeExport)
+	if errors.Root(err) != ErrNotPermitted {
+		t.Errorf("exporting key without policy: got error %v want %v", err, ErrNotPermitted)
+	}
+
+	err = hsm.SetKeyPolicy(ctx, pub.Pub, &Policy{Purposes: []string{"everything"}})
+	if errors.Root(err) != ErrBadPolicy {
+		t.Errorf("setting bad policy: got error %v want %v", err, ErrBadPolicy)
+	}
+	want := &Policy{Tokens: []string{"signer"}, Purposes: []string{PurposeMessage}, MaxPerMinute: 2}
+	err = hsm.SetKeyPolicy(ctx, pub.Pub, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := hsm.KeyPolicy(ctx, pub.Pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !testutil.DeepEqual(got, want) {
+		t.Errorf("KeyPolicy = %+v want %+v", got, want)
+	}
+
+	cases := []struct {
+		token, purpose string
+		want           error
+	}{
+		{"client", PurposeMessage, ErrNotPermitted},
+		{"signer", PurposeBlock, ErrNotPermitted},
+		{"signer", PurposeMessage, nil},
+		{"", PurposeMessage, ErrNotPermitted},
+		{"signer", PurposeMessage, ErrRateLimited},
+	}
+	for _, c := range cases {
+		err = hsm.Authorize(ctx, pub.Pub, c.token, c.purpose)
+		if errors.Root(err) != c.want {
+			t.Errorf("Authorize(%q, %q) = %v want %v", c.token, c.purpose, err, c.want)
+		}
+	}
+}
+
+func TestBlockKeyNotAuthorized(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	hsm := New(db)
+
+	pub, _, err := hsm.GetOrCreate(ctx, BlockKeyAlias)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := hsm.RotateKey(ctx, BlockKeyAlias)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = hsm.SetKeyPolicy(ctx, pub.Pub, &Policy{Purposes: []string{PurposeExport}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []ed25519.PublicKey{pub.Pub, v2.Pub} {
+		for _, purpose := range []string{PurposeMessage, PurposeExport} {
+			err = hsm.Authorize(ctx, p, "", purpose)
+			if errors.Root(err) != ErrNotPermitted {
+				t.Errorf("Authorize(%x, %q) = %v want %v", p, purpose, err, ErrNotPermitted)
+			}
+		}
+	}
+}
+
 func BenchmarkSign(b *testing.B) {
 	b.StopTimer()
 
@@ -0,0 +1,210 @@
+package mockhsm
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/errors"
+)
+
+// Purposes for which a key is used. Only the core itself
+// signs blocks, so the API cannot sign with a key
+// restricted to PurposeBlock.
+const (
+	PurposeBlock   = "block"   // block headers
+	PurposeTx      = "tx"      // transaction witnesses
+	PurposeMessage = "message" // arbitrary messages
+	PurposeExport  = "export"  // export of the private key
+)
+
+// BlockKeyAlias is the alias of the key the core creates
+// to sign blocks (see chain/core/config). No request can
+// use a version of it through the API, whatever its policy.
+const BlockKeyAlias = "_CHAIN_CORE_AUTO_BLOCK_KEY"
+
+var (
+	ErrBadPolicy     = errors.New("invalid key policy")
+	ErrNotPermitted  = errors.New("key use not permitted by policy")
+	ErrRateLimited   = errors.New("key signing rate limit exceeded")
+	errUnknownPolicy = errors.New("unknown policy")
+)
+
+// Policy restricts the use of a key through the mockhsm
+// API; the core's own use of its keys, such as to sign
+// blocks, is not subject to it. A key without a policy
+// has an empty one.
+type Policy struct {
+	// Tokens lists the IDs of the access tokens
+	// that may use the key. If it is empty, any
+	// request may; if not, only requests that
+	// present one of these tokens may.
+	Tokens []string `json:"tokens"`
+
+	// Purposes lists what the key may be used for:
+	// PurposeBlock, PurposeTx, PurposeMessage, or
+	// PurposeExport. If it is empty, the key may
+	// sign transactions and messages. A key may be
+	// exported only if PurposeExport is listed.
+	Purposes []string `json:"purposes"`
+
+	// MaxPerMinute limits how many signatures
+	// the key makes per minute. 0 means no limit.
+	MaxPerMinute int `json:"max_per_minute"`
+}
+
+// rateWindow counts the signatures a key
+// has made in the minute starting at start.
+type rateWindow struct {
+	start time.Time
+	n     int
+}
+
+// SetKeyPolicy sets the policy for the key with the
+// given pubkey or xpub, replacing any earlier policy.
+func (h *HSM) SetKeyPolicy(ctx context.Context, pub []byte, p *Policy) error {
+	for _, purpose := range p.Purposes {
+		switch purpose {
+		case PurposeBlock, PurposeTx, PurposeMessage, PurposeExport:
+		default:
+			return errors.WithDetailf(ErrBadPolicy, "unknown purpose %q", purpose)
+		}
+	}
+	if p.MaxPerMinute < 0 {
+		return errors.WithDetail(ErrBadPolicy, "max_per_minute cannot be negative")
+	}
+
+	const q = `
+		INSERT INTO mockhsm_key_policies (pub, tokens, purposes, max_per_minute)
+		SELECT pub, COALESCE($2::text[], '{}'), COALESCE($3::text[], '{}'), $4 FROM mockhsm WHERE pub = $1
+		ON CONFLICT (pub) DO UPDATE
+		SET tokens = excluded.tokens, purposes = excluded.purposes, max_per_minute = excluded.max_per_minute
+	`
+	res, err := h.db.Exec(ctx, q, pub, pq.StringArray(p.Tokens), pq.StringArray(p.Purposes), p.MaxPerMinute)
+	if err != nil {
+		return errors.Wrap(err, "storing key policy")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "storing key policy")
+	}
+	if n == 0 {
+		return errors.WithDetailf(ErrNoKey, "pub: %x", pub)
+	}
+	return nil
+}
+
+// KeyPolicy returns the policy for the key with the given
+// pubkey or xpub. A key with no policy has an empty one.
+func (h *HSM) KeyPolicy(ctx context.Context, pub []byte) (*Policy, error) {
+	p, err := h.keyPolicy(ctx, pub)
+	if err == errUnknownPolicy {
+		var exists bool
+		err = h.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM mockhsm WHERE pub = $1)`, pub).Scan(&exists)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading key")
+		}
+		if !exists {
+			return nil, errors.WithDetailf(ErrNoKey, "pub: %x", pub)
+		}
+		return &Policy{Tokens: []string{}, Purposes: []string{}}, nil
+	}
+	return p, err
+}
+
+// Authorize reports whether the access token with the
+// given ID may use the key with the given pubkey or xpub
+// for purpose, and counts the use toward the key's rate
+// limit. An empty token, from a request authenticated
+// otherwise than by access token, may use only keys
+// whose policy lists no tokens. No token may use a
+// version of the block key.
+func (h *HSM) Authorize(ctx context.Context, pub []byte, token, purpose string) error {
+	blockKey, err := h.isBlockKey(ctx, pub)
+	if err != nil {
+		return err
+	}
+	if blockKey {
+		return errors.WithDetail(ErrNotPermitted, "the block key cannot be used through the API")
+	}
+
+	p, err := h.keyPolicy(ctx, pub)
+	if err == errUnknownPolicy {
+		p = new(Policy)
+	} else if err != nil {
+		return err
+	}
+	if len(p.Tokens) > 0 && !contains(p.Tokens, token) {
+		if token == "" {
+			return errors.WithDetail(ErrNotPermitted, "an access token is required to use this key")
+		}
+		return errors.WithDetailf(ErrNotPermitted, "access token %q may not use this key", token)
+	}
+	if !allowsPurpose(p.Purposes, purpose) {
+		return errors.WithDetailf(ErrNotPermitted, "key may not be used for purpose %q", purpose)
+	}
+	if p.MaxPerMinute == 0 {
+		return nil
+	}
+
+	h.rateMu.Lock()
+	defer h.rateMu.Unlock()
+	now := time.Now()
+	w := h.rates[string(pub)]
+	if w == nil || now.Sub(w.start) >= time.Minute {
+		w = &rateWindow{start: now}
+		h.rates[string(pub)] = w
+	}
+	if w.n >= p.MaxPerMinute {
+		return errors.WithDetailf(ErrRateLimited, "at most %d signatures per minute", p.MaxPerMinute)
+	}
+	w.n++
+	return nil
+}
+
+func (h *HSM) keyPolicy(ctx context.Context, pub []byte) (*Policy, error) {
+	const q = `SELECT tokens, purposes, max_per_minute FROM mockhsm_key_policies WHERE pub = $1`
+	var tokens, purposes pq.StringArray
+	p := new(Policy)
+	err := h.db.QueryRow(ctx, q, pub).Scan(&tokens, &purposes, &p.MaxPerMinute)
+	if err == sql.ErrNoRows {
+		return nil, errUnknownPolicy
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "reading key policy")
+	}
+	p.Tokens, p.Purposes = tokens, purposes
+	return p, nil
+}
+
+// allowsPurpose reports whether a key whose
+// policy lists purposes may be used for purpose.
+func allowsPurpose(purposes []string, purpose string) bool {
+	if len(purposes) == 0 {
+		return purpose == PurposeTx || purpose == PurposeMessage
+	}
+	return contains(purposes, purpose)
+}
+
+// isBlockKey reports whether pub is
+// a version of the block key.
+func (h *HSM) isBlockKey(ctx context.Context, pub []byte) (bool, error) {
+	const q = `
+		SELECT EXISTS (SELECT 1 FROM mockhsm WHERE pub = $1 AND alias = $2)
+			OR EXISTS (SELECT 1 FROM mockhsm_key_versions WHERE pub = $1 AND alias = $2)
+	`
+	var blockKey bool
+	err := h.db.QueryRow(ctx, q, pub, BlockKeyAlias).Scan(&blockKey)
+	return blockKey, errors.Wrap(err, "looking up key alias")
+}
+
+func contains(a []string, s string) bool {
+	for _, x := range a {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package mockhsm
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"chain/crypto/ed25519"
+	"chain/database/pg"
+	"chain/errors"
+)
+
+// ErrNoKeyVersion is returned when a requested
+// version of an aliased key does not exist.
+var ErrNoKeyVersion = errors.New("key version not found")
+
+// KeyVersion is one of the successive Ed25519 keys
+// that an alias has pointed to.
+type KeyVersion struct {
+	Alias     string            `json:"alias"`
+	Version   int               `json:"version"`
+	Pub       ed25519.PublicKey `json:"pub"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// RotateKey makes a new Ed25519 key the current version of
+// the key with the given alias. The earlier versions remain,
+// and can still sign. The key created with the alias, by
+// Create or GetOrCreate, is version 1.
+func (h *HSM) RotateKey(ctx context.Context, alias string) (*KeyVersion, error) {
+	err := h.recordFirstVersion(ctx, alias)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := h.keyVersion(ctx, alias, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return nil, err
+	}
+	const insertKeyQ = `INSERT INTO mockhsm (pub, prv, key_type) VALUES ($1, $2, 'ed25519')`
+	_, err = h.db.Exec(ctx, insertKeyQ, []byte(pub), []byte(prv))
+	if err != nil {
+		return nil, errors.Wrap(err, "storing new pub")
+	}
+
+	v := &KeyVersion{Alias: alias, Version: cur.Version + 1, Pub: pub}
+	const insertVersionQ = `
+		INSERT INTO mockhsm_key_versions (alias, version, pub) VALUES ($1, $2, $3)
+		RETURNING created_at
+	`
+	err = h.db.QueryRow(ctx, insertVersionQ, alias, v.Version, []byte(pub)).Scan(&v.CreatedAt)
+	if pg.IsUniqueViolation(err) {
+		return nil, errors.WithDetailf(ErrDuplicateKeyAlias, "version %d of %q was made concurrently", v.Version, alias)
+	}
+	return v, errors.Wrap(err, "storing key version")
+}
+
+// ListKeyVersions returns every version of the key
+// with the given alias, oldest first.
+func (h *HSM) ListKeyVersions(ctx context.Context, alias string) ([]*KeyVersion, error) {
+	const q = `
+		SELECT version, pub, created_at FROM mockhsm_key_versions
+		WHERE alias = $1 ORDER BY version
+	`
+	var versions []*KeyVersion
+	err := pg.ForQueryRows(ctx, h.db, q, alias, func(version int, pub []byte, createdAt time.Time) {
+		versions = append(versions, &KeyVersion{
+			Alias:     alias,
+			Version:   version,
+			Pub:       ed25519.PublicKey(pub),
+			CreatedAt: createdAt,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, errors.WithDetailf(ErrNoKey, "alias: %q", alias)
+	}
+	return versions, nil
+}
+
+// SignWithVersion signs msg with the given version of
+// the key with the given alias. Version 0 means the
+// current version.
+func (h *HSM) SignWithVersion(ctx context.Context, alias string, version int, msg []byte) ([]byte, error) {
+	v, err := h.KeyVersion(ctx, alias, version)
+	if err != nil {
+		return nil, err
+	}
+	return h.SignMessage(ctx, v.Pub, msg)
+}
+
+// KeyVersion returns the given version of the key with
+// the given alias. Version 0 means the current version.
+func (h *HSM) KeyVersion(ctx context.Context, alias string, version int) (*KeyVersion, error) {
+	return h.keyVersion(ctx, alias, version)
+}
+
+// KeyVersions returns every version of the aliased key
+// that includes pub, oldest first, or just pub if it has
+// no alias. It lets a block signer sign with whichever
+// version the consensus program requires.
+func (h *HSM) KeyVersions(ctx context.Context, pub ed25519.PublicKey) ([]ed25519.PublicKey, error) {
+	const q = `
+		SELECT alias FROM mockhsm_key_versions WHERE pub = $1
+		UNION
+		SELECT alias FROM mockhsm WHERE pub = $1 AND alias IS NOT NULL AND key_type = 'ed25519'
+	`
+	var alias string
+	err := h.db.QueryRow(ctx, q, []byte(pub)).Scan(&alias)
+	if err == sql.ErrNoRows {
+		return []ed25519.PublicKey{pub}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "looking up key alias")
+	}
+
+	versions, err := h.ListKeyVersions(ctx, alias)
+	if err != nil {
+		return nil, err
+	}
+	pubs := make([]ed25519.PublicKey, 0, len(versions))
+	for _, v := range versions {
+		pubs = append(pubs, v.Pub)
+	}
+	return pubs, nil
+}
+
+// keyVersion returns the given version of the key with
+// the given alias, or the current one if version is 0.
+func (h *HSM) keyVersion(ctx context.Context, alias string, version int) (*KeyVersion, error) {
+	const q = `
+		SELECT version, pub, created_at FROM mockhsm_key_versions
+		WHERE alias = $1 AND ($2 = 0 OR version = $2)
+		ORDER BY version DESC LIMIT 1
+	`
+	v := &KeyVersion{Alias: alias}
+	var pub []byte
+	err := h.db.QueryRow(ctx, q, alias, version).Scan(&v.Version, &pub, &v.CreatedAt)
+	if err == sql.ErrNoRows && version == 0 {
+		return nil, errors.WithDetailf(ErrNoKey, "alias: %q", alias)
+	} else if err == sql.ErrNoRows {
+		return nil, errors.WithDetailf(ErrNoKeyVersion, "alias: %q, version: %d", alias, version)
+	} else if err != nil {
+		return nil, errors.Wrap(err, "reading key version")
+	}
+	v.Pub = ed25519.PublicKey(pub)
+	return v, nil
+}
+
+// recordFirstVersion records the Ed25519 key created
+// with alias, if any, as version 1 of that alias.
+// It's called when a key is stored under an alias, so
+// that reading the versions never has to write.
+func (h *HSM) recordFirstVersion(ctx context.Context, alias string) error {
+	const q = `
+		INSERT INTO mockhsm_key_versions (alias, version, pub)
+		SELECT alias, 1, pub FROM mockhsm WHERE alias = $1 AND key_type = 'ed25519'
+		ON CONFLICT DO NOTHING
+	`
+	_, err := h.db.Exec(ctx, q, alias)
+	return errors.Wrap(err, "recording first key version")
+}
@@ -0,0 +1,223 @@
+package mockhsm
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+
+	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+)
+
+// Key types, as stored in the mockhsm table.
+const (
+	KeyTypeEd25519 = "ed25519"
+	KeyTypeChainKD = "chain_kd"
+)
+
+var (
+	ErrBadWrappingKey = errors.New("wrapping key must be 32 bytes")
+	ErrBadWrappedKey  = errors.New("wrapped key cannot be unwrapped")
+	ErrBadKeyType     = errors.New("invalid key type")
+)
+
+// WrappedKey is a private key encrypted under a wrapping key.
+// Wrapped is a random 12-byte nonce followed by the AES-256-GCM
+// encryption of the private key, authenticating KeyType and Pub.
+// The private key is a 64-byte ed25519.PrivateKey or a 64-byte
+// chainkd.XPrv.
+type WrappedKey struct {
+	KeyType string             `json:"key_type"`
+	Pub     chainjson.HexBytes `json:"pub"`
+	Wrapped chainjson.HexBytes `json:"wrapped"`
+}
+
+// WrapKey encrypts prv, of the given key type, under kek.
+// It lets a client prepare an externally generated key
+// for ImportWrapped.
+func WrapKey(kek []byte, keyType string, prv []byte) (*WrappedKey, error) {
+	pub, err := publicKey(keyType, prv)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(kek)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	ad := append([]byte(keyType), pub...)
+	return &WrappedKey{
+		KeyType: keyType,
+		Pub:     pub,
+		Wrapped: aead.Seal(nonce, nonce, prv, ad),
+	}, nil
+}
+
+// UnwrapKey decrypts w with kek, returning the private key.
+func UnwrapKey(kek []byte, w *WrappedKey) ([]byte, error) {
+	aead, err := newAEAD(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(w.Wrapped) < aead.NonceSize() {
+		return nil, errors.WithDetail(ErrBadWrappedKey, "too short")
+	}
+	nonce, sealed := w.Wrapped[:aead.NonceSize()], w.Wrapped[aead.NonceSize():]
+	ad := append([]byte(w.KeyType), w.Pub...)
+	prv, err := aead.Open(nil, nonce, sealed, ad)
+	if err != nil {
+		return nil, errors.WithDetail(ErrBadWrappedKey, "wrong wrapping key, or altered key")
+	}
+	pub, err := publicKey(w.KeyType, prv)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(pub, w.Pub) {
+		return nil, errors.WithDetail(ErrBadWrappedKey, "private key does not match pub")
+	}
+	return prv, nil
+}
+
+// ExportKey returns the private key for pub, an Ed25519
+// pubkey or a chainkd xpub, wrapped under kek.
+func (h *HSM) ExportKey(ctx context.Context, pub []byte, kek []byte) (*WrappedKey, error) {
+	const q = `SELECT key_type, prv FROM mockhsm WHERE pub = $1`
+	var (
+		keyType string
+		prv     []byte
+	)
+	err := h.db.QueryRow(ctx, q, pub).Scan(&keyType, &prv)
+	if err == sql.ErrNoRows {
+		return nil, errors.WithDetailf(ErrNoKey, "pub: %x", pub)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "reading key")
+	}
+	return WrapKey(kek, keyType, prv)
+}
+
+// ImportWrapped unwraps w with kek and stores the
+// private key under alias, which may be empty.
+func (h *HSM) ImportWrapped(ctx context.Context, alias string, w *WrappedKey, kek []byte) error {
+	prv, err := UnwrapKey(kek, w)
+	if err != nil {
+		return err
+	}
+	return h.importKey(ctx, alias, w.KeyType, w.Pub, prv)
+}
+
+// ImportEd25519 stores an externally generated Ed25519
+// private key under alias, which may be empty.
+func (h *HSM) ImportEd25519(ctx context.Context, alias string, prv ed25519.PrivateKey) (*Pub, error) {
+	pub, err := publicKey(KeyTypeEd25519, prv)
+	if err != nil {
+		return nil, err
+	}
+	err = h.importKey(ctx, alias, KeyTypeEd25519, pub, prv)
+	if err != nil {
+		return nil, err
+	}
+	return &Pub{Alias: aliasPtr(alias), Pub: ed25519.PublicKey(pub)}, nil
+}
+
+// ImportXPrv stores an externally generated chainkd
+// xprv under alias, which may be empty.
+func (h *HSM) ImportXPrv(ctx context.Context, alias string, xprv chainkd.XPrv) (*XPub, error) {
+	xpub := xprv.XPub()
+	err := h.importKey(ctx, alias, KeyTypeChainKD, xpub.Bytes(), xprv.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return &XPub{Alias: aliasPtr(alias), XPub: xpub}, nil
+}
+
+func (h *HSM) importKey(ctx context.Context, alias, keyType string, pub, prv []byte) error {
+	sqlAlias := sql.NullString{String: alias, Valid: alias != ""}
+	const q = `
+		INSERT INTO mockhsm (pub, prv, alias, key_type) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (pub) DO NOTHING
+	`
+	res, err := h.db.Exec(ctx, q, pub, prv, sqlAlias, keyType)
+	if pg.IsUniqueViolation(err) {
+		return errors.WithDetailf(ErrDuplicateKeyAlias, "value: %q", alias)
+	}
+	if err != nil {
+		return errors.Wrap(err, "storing imported key")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "storing imported key")
+	}
+	if n == 0 {
+		// The key is already here. Importing it again
+		// is fine, so long as the alias agrees.
+		var existing sql.NullString
+		err = h.db.QueryRow(ctx, `SELECT alias FROM mockhsm WHERE pub = $1`, pub).Scan(&existing)
+		if err != nil {
+			return errors.Wrap(err, "reading existing key")
+		}
+		if existing != sqlAlias {
+			return errors.WithDetailf(ErrDuplicateKeyAlias, "key already stored with alias %q", existing.String)
+		}
+	}
+	if alias != "" && keyType == KeyTypeEd25519 {
+		return h.recordFirstVersion(ctx, alias)
+	}
+	return nil
+}
+
+// publicKey checks that prv is a private key of the
+// given type, and returns its public key.
+func publicKey(keyType string, prv []byte) ([]byte, error) {
+	switch keyType {
+	case KeyTypeEd25519:
+		if len(prv) != ed25519.PrivateKeySize {
+			return nil, ErrInvalidKeySize
+		}
+		// An Ed25519 private key is its seed followed by its pubkey.
+		pub, _, err := ed25519.GenerateKey(bytes.NewReader(prv[:32]))
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(pub, prv[32:]) {
+			return nil, errors.WithDetail(ErrInvalidKeySize, "ed25519 private key does not contain its pubkey")
+		}
+		return pub, nil
+	case KeyTypeChainKD:
+		var xprv chainkd.XPrv
+		if len(prv) != len(xprv) {
+			return nil, ErrInvalidKeySize
+		}
+		copy(xprv[:], prv)
+		return xprv.XPub().Bytes(), nil
+	}
+	return nil, errors.WithDetailf(ErrBadKeyType, "key type %q", keyType)
+}
+
+func newAEAD(kek []byte) (cipher.AEAD, error) {
+	if len(kek) != 32 {
+		return nil, ErrBadWrappingKey
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func aliasPtr(alias string) *string {
+	if alias == "" {
+		return nil
+	}
+	return &alias
+}
@@ -132,48 +132,48 @@ func (s *Store) AllWaiter(height uint64) <-chan struct{} {
 	return ch
 }
 
+// Listen follows the height of the named pin as it is advanced
+// by other processes, until ctx is done. If the connection to the
+// database drops, Listen reloads the pin's height once it reconnects.
 func (s *Store) Listen(ctx context.Context, pinName, dbURL string) {
-	listener, err := pg.NewListener(ctx, dbURL, "pin-"+pinName)
+	payloads, err := pg.Subscribe(ctx, dbURL, "pin-"+pinName, func(ctx context.Context) (string, error) {
+		const q = `SELECT height FROM block_processors WHERE name=$1`
+		var height uint64
+		err := s.db.QueryRow(ctx, q, pinName).Scan(&height)
+		return strconv.FormatUint(height, 10), errors.Wrap(err, "loading pin height")
+	})
 	if err != nil {
 		log.Error(ctx, err)
 		return
 	}
 	go func() {
-		defer listener.Close()
-
 		var p *pin
 
-		for {
-			select {
-			case <-ctx.Done():
+		for payload := range payloads {
+			height, err := strconv.ParseUint(payload, 10, 64)
+			if err != nil {
+				log.Error(ctx, errors.Wrap(err, "parsing db notification payload"))
 				return
+			}
 
-			case n := <-listener.Notify:
-				height, err := strconv.ParseUint(n.Extra, 10, 64)
-				if err != nil {
-					log.Error(ctx, errors.Wrap(err, "parsing db notification payload"))
-					return
-				}
-
-				if p == nil {
-					s.mu.Lock()
-					var ok bool
-					p, ok = s.pins[pinName]
-					if !ok {
-						p = newPin(s.db, pinName, height)
-						s.pins[pinName] = p
-						s.cond.Broadcast()
-					}
-					s.mu.Unlock()
+			if p == nil {
+				s.mu.Lock()
+				var ok bool
+				p, ok = s.pins[pinName]
+				if !ok {
+					p = newPin(s.db, pinName, height)
+					s.pins[pinName] = p
+					s.cond.Broadcast()
 				}
+				s.mu.Unlock()
+			}
 
-				p.mu.Lock()
-				if p.height < height {
-					p.height = height
-					p.cond.Broadcast()
-				}
-				p.mu.Unlock()
+			p.mu.Lock()
+			if p.height < height {
+				p.height = height
+				p.cond.Broadcast()
 			}
+			p.mu.Unlock()
 		}
 	}()
 
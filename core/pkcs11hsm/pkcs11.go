@@ -0,0 +1,367 @@
+//+build pkcs11
+
+package pkcs11hsm
+
+/*
+#cgo linux LDFLAGS: -ldl
+
+#include <dlfcn.h>
+#include <stdlib.h>
+
+// The few PKCS#11 types and constants used here, from the
+// PKCS#11 3.0 specification, so that no header is needed.
+typedef unsigned long CK_ULONG;
+typedef CK_ULONG CK_RV;
+typedef CK_ULONG CK_SLOT_ID;
+typedef CK_ULONG CK_SESSION_HANDLE;
+typedef CK_ULONG CK_OBJECT_HANDLE;
+
+typedef struct {
+	CK_ULONG type;
+	void *pValue;
+	CK_ULONG ulValueLen;
+} CK_ATTRIBUTE;
+
+typedef struct {
+	CK_ULONG mechanism;
+	void *pParameter;
+	CK_ULONG ulParameterLen;
+} CK_MECHANISM;
+
+#define CKR_OK                              0x000UL
+#define CKR_USER_ALREADY_LOGGED_IN          0x100UL
+#define CKR_CRYPTOKI_ALREADY_INITIALIZED    0x191UL
+#define CKF_RW_SESSION                      0x002UL
+#define CKF_SERIAL_SESSION                  0x004UL
+#define CKU_USER                            1UL
+#define CKA_CLASS                           0x000UL
+#define CKA_KEY_TYPE                        0x100UL
+#define CKA_ID                              0x102UL
+#define CKA_EC_POINT                        0x181UL
+#define CKO_PUBLIC_KEY                      2UL
+#define CKO_PRIVATE_KEY                     3UL
+#define CKK_EC_EDWARDS                      0x040UL
+#define CKM_EDDSA                           0x1057UL
+#define CKM_SHA3_256                        0x2B0UL
+#define CKR_FUNCTION_NOT_SUPPORTED          0x054UL
+
+typedef struct {
+	void *lib;
+	CK_RV (*Initialize)(void *);
+	CK_RV (*Finalize)(void *);
+	CK_RV (*OpenSession)(CK_SLOT_ID, CK_ULONG, void *, void *, CK_SESSION_HANDLE *);
+	CK_RV (*CloseSession)(CK_SESSION_HANDLE);
+	CK_RV (*Login)(CK_SESSION_HANDLE, CK_ULONG, unsigned char *, CK_ULONG);
+	CK_RV (*FindObjectsInit)(CK_SESSION_HANDLE, CK_ATTRIBUTE *, CK_ULONG);
+	CK_RV (*FindObjects)(CK_SESSION_HANDLE, CK_OBJECT_HANDLE *, CK_ULONG, CK_ULONG *);
+	CK_RV (*FindObjectsFinal)(CK_SESSION_HANDLE);
+	CK_RV (*GetAttributeValue)(CK_SESSION_HANDLE, CK_OBJECT_HANDLE, CK_ATTRIBUTE *, CK_ULONG);
+	CK_RV (*SignInit)(CK_SESSION_HANDLE, CK_MECHANISM *, CK_OBJECT_HANDLE);
+	CK_RV (*Sign)(CK_SESSION_HANDLE, unsigned char *, CK_ULONG, unsigned char *, CK_ULONG *);
+	CK_RV (*DigestInit)(CK_SESSION_HANDLE, CK_MECHANISM *);
+	CK_RV (*Digest)(CK_SESSION_HANDLE, unsigned char *, CK_ULONG, unsigned char *, CK_ULONG *);
+} p11_module;
+
+// p11_load opens the library and looks up the functions.
+// It returns NULL if any needed for signing is missing.
+static p11_module *p11_load(const char *path) {
+	void *lib = dlopen(path, RTLD_NOW | RTLD_LOCAL);
+	if (lib == NULL) {
+		return NULL;
+	}
+	p11_module *m = calloc(1, sizeof(p11_module));
+	m->lib = lib;
+	m->Initialize = dlsym(lib, "C_Initialize");
+	m->Finalize = dlsym(lib, "C_Finalize");
+	m->OpenSession = dlsym(lib, "C_OpenSession");
+	m->CloseSession = dlsym(lib, "C_CloseSession");
+	m->Login = dlsym(lib, "C_Login");
+	m->FindObjectsInit = dlsym(lib, "C_FindObjectsInit");
+	m->FindObjects = dlsym(lib, "C_FindObjects");
+	m->FindObjectsFinal = dlsym(lib, "C_FindObjectsFinal");
+	m->GetAttributeValue = dlsym(lib, "C_GetAttributeValue");
+	m->SignInit = dlsym(lib, "C_SignInit");
+	m->Sign = dlsym(lib, "C_Sign");
+	m->DigestInit = dlsym(lib, "C_DigestInit");
+	m->Digest = dlsym(lib, "C_Digest");
+	if (!m->Initialize || !m->Finalize || !m->OpenSession || !m->CloseSession ||
+		!m->Login || !m->FindObjectsInit || !m->FindObjects || !m->FindObjectsFinal ||
+		!m->GetAttributeValue || !m->SignInit || !m->Sign) {
+		dlclose(lib);
+		free(m);
+		return NULL;
+	}
+	return m;
+}
+
+static void p11_unload(p11_module *m) {
+	dlclose(m->lib);
+	free(m);
+}
+
+static CK_RV p11_open(p11_module *m, CK_SLOT_ID slot, unsigned char *pin, CK_ULONG pinLen, CK_SESSION_HANDLE *s) {
+	CK_RV rv = m->Initialize(NULL);
+	if (rv != CKR_OK && rv != CKR_CRYPTOKI_ALREADY_INITIALIZED) {
+		return rv;
+	}
+	rv = m->OpenSession(slot, CKF_SERIAL_SESSION | CKF_RW_SESSION, NULL, NULL, s);
+	if (rv != CKR_OK) {
+		m->Finalize(NULL);
+		return rv;
+	}
+	rv = m->Login(*s, CKU_USER, pin, pinLen);
+	if (rv != CKR_OK && rv != CKR_USER_ALREADY_LOGGED_IN) {
+		m->CloseSession(*s);
+		m->Finalize(NULL);
+		return rv;
+	}
+	return CKR_OK;
+}
+
+static CK_RV p11_close(p11_module *m, CK_SESSION_HANDLE s) {
+	m->CloseSession(s);
+	return m->Finalize(NULL);
+}
+
+// p11_find finds up to max Edwards-curve keys of the given
+// class, with the given CKA_ID if id is not NULL.
+static CK_RV p11_find(p11_module *m, CK_SESSION_HANDLE s, CK_ULONG class, void *id, CK_ULONG idLen,
+	CK_OBJECT_HANDLE *out, CK_ULONG max, CK_ULONG *n) {
+	CK_ULONG keyType = CKK_EC_EDWARDS;
+	CK_ATTRIBUTE tmpl[3] = {
+		{CKA_CLASS, &class, sizeof(class)},
+		{CKA_KEY_TYPE, &keyType, sizeof(keyType)},
+		{CKA_ID, id, idLen},
+	};
+	CK_RV rv = m->FindObjectsInit(s, tmpl, id == NULL ? 2 : 3);
+	if (rv != CKR_OK) {
+		return rv;
+	}
+	rv = m->FindObjects(s, out, max, n);
+	m->FindObjectsFinal(s);
+	return rv;
+}
+
+// p11_attr reads an attribute of obj into buf, which has room
+// for *len bytes. If buf is NULL, it sets *len to the size.
+static CK_RV p11_attr(p11_module *m, CK_SESSION_HANDLE s, CK_OBJECT_HANDLE obj, CK_ULONG type, void *buf, CK_ULONG *len) {
+	CK_ATTRIBUTE a = {type, buf, *len};
+	CK_RV rv = m->GetAttributeValue(s, obj, &a, 1);
+	*len = a.ulValueLen;
+	return rv;
+}
+
+static CK_RV p11_sign(p11_module *m, CK_SESSION_HANDLE s, CK_OBJECT_HANDLE key,
+	unsigned char *msg, CK_ULONG msgLen, unsigned char *sig, CK_ULONG *sigLen) {
+	CK_MECHANISM mech = {CKM_EDDSA, NULL, 0};
+	CK_RV rv = m->SignInit(s, &mech, key);
+	if (rv != CKR_OK) {
+		return rv;
+	}
+	return m->Sign(s, msg, msgLen, sig, sigLen);
+}
+
+static CK_RV p11_digest(p11_module *m, CK_SESSION_HANDLE s,
+	unsigned char *msg, CK_ULONG msgLen, unsigned char *out, CK_ULONG *outLen) {
+	if (!m->DigestInit || !m->Digest) {
+		return CKR_FUNCTION_NOT_SUPPORTED;
+	}
+	CK_MECHANISM mech = {CKM_SHA3_256, NULL, 0};
+	CK_RV rv = m->DigestInit(s, &mech);
+	if (rv != CKR_OK) {
+		return rv;
+	}
+	return m->Digest(s, msg, msgLen, out, outLen);
+}
+*/
+import "C"
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"unsafe"
+
+	"chain/crypto/ed25519"
+	"chain/errors"
+)
+
+// maxKeys limits how many keys of one kind
+// are examined when looking for a key.
+const maxKeys = 256
+
+// HSM is a session with a PKCS#11 token.
+// It is safe for concurrent use; calls
+// to the token are serialized.
+type HSM struct {
+	mu      sync.Mutex
+	mod     *C.p11_module
+	session C.CK_SESSION_HANDLE
+	keys    map[string]C.CK_OBJECT_HANDLE // private keys, by string(pub)
+}
+
+type rvError C.CK_RV
+
+func (e rvError) Error() string {
+	return fmt.Sprintf("PKCS#11 error 0x%x", uint64(e))
+}
+
+func check(rv C.CK_RV, op string) error {
+	if rv == C.CKR_OK {
+		return nil
+	}
+	return errors.Wrap(rvError(rv), op)
+}
+
+// Open loads the PKCS#11 library, opens a session
+// with the token in c.Slot, and logs in with c.PIN.
+func Open(c Config) (*HSM, error) {
+	path := C.CString(c.Library)
+	defer C.free(unsafe.Pointer(path))
+	mod := C.p11_load(path)
+	if mod == nil {
+		return nil, fmt.Errorf("cannot load PKCS#11 library %s", c.Library)
+	}
+
+	h := &HSM{mod: mod, keys: make(map[string]C.CK_OBJECT_HANDLE)}
+	pin := []byte(c.PIN)
+	rv := C.p11_open(mod, C.CK_SLOT_ID(c.Slot), bytesPtr(pin), C.CK_ULONG(len(pin)), &h.session)
+	if err := check(rv, "opening session"); err != nil {
+		C.p11_unload(mod)
+		return nil, err
+	}
+	return h, nil
+}
+
+// SignMessage signs msg with the private key for pub.
+func (h *HSM) SignMessage(ctx context.Context, pub ed25519.PublicKey, msg []byte) ([]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key, err := h.findKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	sig := make([]byte, ed25519.SignatureSize)
+	sigLen := C.CK_ULONG(len(sig))
+	rv := C.p11_sign(h.mod, h.session, key, bytesPtr(msg), C.CK_ULONG(len(msg)), bytesPtr(sig), &sigLen)
+	if err := check(rv, "signing"); err != nil {
+		return nil, err
+	}
+	if int(sigLen) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("PKCS#11 signature has length %d", sigLen)
+	}
+	return sig, nil
+}
+
+// Digest returns the SHA3-256 digest of msg,
+// computed by the token.
+func (h *HSM) Digest(msg []byte) ([32]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var out [32]byte
+	outLen := C.CK_ULONG(len(out))
+	rv := C.p11_digest(h.mod, h.session, bytesPtr(msg), C.CK_ULONG(len(msg)), bytesPtr(out[:]), &outLen)
+	if err := check(rv, "digesting"); err != nil {
+		return out, err
+	}
+	if int(outLen) != len(out) {
+		return out, fmt.Errorf("PKCS#11 digest has length %d", outLen)
+	}
+	return out, nil
+}
+
+// Close logs out of the token and unloads the library.
+func (h *HSM) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	rv := C.p11_close(h.mod, h.session)
+	C.p11_unload(h.mod)
+	return check(rv, "closing session")
+}
+
+// findKey returns the handle of the private key for pub.
+// h.mu must be held.
+func (h *HSM) findKey(pub ed25519.PublicKey) (C.CK_OBJECT_HANDLE, error) {
+	if key, ok := h.keys[string(pub)]; ok {
+		return key, nil
+	}
+
+	objs, err := h.find(C.CKO_PUBLIC_KEY, nil)
+	if err != nil {
+		return 0, err
+	}
+	for _, obj := range objs {
+		point, err := h.attr(obj, C.CKA_EC_POINT)
+		if err != nil {
+			return 0, err
+		}
+		if !bytes.Equal(decodePoint(point), pub) {
+			continue
+		}
+		id, err := h.attr(obj, C.CKA_ID)
+		if err != nil {
+			return 0, err
+		}
+		prvs, err := h.find(C.CKO_PRIVATE_KEY, id)
+		if err != nil {
+			return 0, err
+		}
+		if len(prvs) == 0 {
+			break
+		}
+		h.keys[string(pub)] = prvs[0]
+		return prvs[0], nil
+	}
+	return 0, errors.WithDetailf(ErrNoKey, "pubkey %x", []byte(pub))
+}
+
+func (h *HSM) find(class C.CK_ULONG, id []byte) ([]C.CK_OBJECT_HANDLE, error) {
+	var (
+		objs = make([]C.CK_OBJECT_HANDLE, maxKeys)
+		n    C.CK_ULONG
+	)
+	rv := C.p11_find(h.mod, h.session, class, unsafe.Pointer(bytesPtr(id)), C.CK_ULONG(len(id)), &objs[0], maxKeys, &n)
+	if err := check(rv, "finding keys"); err != nil {
+		return nil, err
+	}
+	return objs[:n], nil
+}
+
+func (h *HSM) attr(obj C.CK_OBJECT_HANDLE, typ C.CK_ULONG) ([]byte, error) {
+	var n C.CK_ULONG
+	rv := C.p11_attr(h.mod, h.session, obj, typ, nil, &n)
+	if err := check(rv, "reading attribute size"); err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	buf := make([]byte, n)
+	rv = C.p11_attr(h.mod, h.session, obj, typ, unsafe.Pointer(&buf[0]), &n)
+	if err := check(rv, "reading attribute"); err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// bytesPtr returns a pointer to the first byte
+// of b, or nil if b is empty.
+func bytesPtr(b []byte) *C.uchar {
+	if len(b) == 0 {
+		return nil
+	}
+	return (*C.uchar)(&b[0])
+}
+
+// decodePoint returns the 32-byte public key in a CKA_EC_POINT
+// value. Modules store it either DER-encoded, as an OCTET STRING,
+// or raw.
+func decodePoint(p []byte) []byte {
+	if len(p) == 2+ed25519.PublicKeySize && p[0] == 0x04 && p[1] == ed25519.PublicKeySize {
+		return p[2:]
+	}
+	return p
+}
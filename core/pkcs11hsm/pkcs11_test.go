@@ -0,0 +1,78 @@
+//+build pkcs11
+
+package pkcs11hsm
+
+import (
+	"bytes"
+	"hash"
+	"os"
+	"testing"
+
+	"chain/protocol/bc"
+)
+
+// hsmHash is a hash.Hash whose digest the token computes.
+// It buffers its input, since PKCS#11 C_Digest
+// takes the whole message at once.
+type hsmHash struct {
+	h   *HSM
+	buf bytes.Buffer
+}
+
+func (d *hsmHash) Write(p []byte) (int, error) { return d.buf.Write(p) }
+func (d *hsmHash) Reset()                      { d.buf.Reset() }
+func (d *hsmHash) Size() int                   { return 32 }
+func (d *hsmHash) BlockSize() int              { return 136 } // SHA3-256 rate
+
+func (d *hsmHash) Sum(b []byte) []byte {
+	sum, err := d.h.Digest(d.buf.Bytes())
+	if err != nil {
+		panic(err)
+	}
+	return append(b, sum[:]...)
+}
+
+// BenchmarkEntryIDHSM compares computing entry IDs in
+// software with computing them in the PKCS#11 token
+// named by $PKCS11_URI, for example
+//
+//	PKCS11_URI='pkcs11:slot-id=0?module-path=/usr/lib/softhsm/libsofthsm2.so&pin-value=1234' \
+//		go test -tags pkcs11 -bench EntryIDHSM chain/core/pkcs11hsm
+func BenchmarkEntryIDHSM(b *testing.B) {
+	uri := os.Getenv("PKCS11_URI")
+	if uri == "" {
+		b.Skip("PKCS11_URI not set")
+	}
+	c, err := ParseURI(uri)
+	if err != nil {
+		b.Fatal(err)
+	}
+	h, err := Open(c)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer h.Close()
+
+	hsmSHA3 := &bc.HashFunc{
+		ID:   bc.SHA3.ID,
+		Name: "pkcs11-sha3-256",
+		New:  func() hash.Hash { return &hsmHash{h: h} },
+	}
+	defer bc.SetHashFunc(bc.SHA3)
+
+	e := bc.NewRetirement(bc.Hash{}, 1)
+	want := bc.EntryID(e)
+	bc.SetHashFunc(hsmSHA3)
+	if got := bc.EntryID(e); got != want {
+		b.Fatalf("token's SHA3-256 entry ID = %x want %x", got.Bytes(), want.Bytes())
+	}
+
+	for _, hf := range []*bc.HashFunc{bc.SHA3, hsmSHA3} {
+		b.Run(hf.Name, func(b *testing.B) {
+			bc.SetHashFunc(hf)
+			for i := 0; i < b.N; i++ {
+				bc.EntryID(e)
+			}
+		})
+	}
+}
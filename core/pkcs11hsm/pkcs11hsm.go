@@ -0,0 +1,120 @@
+// Package pkcs11hsm signs blocks with Ed25519 keys held in
+// a hardware security module, through its PKCS#11 library.
+//
+// The module must support PKCS#11 3.0 Edwards-curve keys
+// (CKK_EC_EDWARDS) and the CKM_EDDSA mechanism. Each key is
+// found by its public key's CKA_EC_POINT, and its private key
+// by the shared CKA_ID.
+//
+// A core uses a PKCS#11 token as its block HSM when its block
+// HSM URL is a PKCS#11 URI (RFC 7512), such as
+//
+//	pkcs11:slot-id=0?module-path=/usr/lib/softhsm/libsofthsm2.so
+//
+// The PIN is the block HSM access token, or the URI's pin-value.
+//
+// Support for PKCS#11 needs cgo and is built only with the
+// build tag pkcs11. Without it, Open returns ErrUnsupported.
+package pkcs11hsm
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"chain/crypto/ed25519"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+var (
+	// ErrUnsupported is returned by Open in
+	// a build without the pkcs11 build tag.
+	ErrUnsupported = errors.New("built without PKCS#11 support; rebuild with -tags pkcs11")
+
+	// ErrNoKey is returned when the token
+	// holds no private key for a public key.
+	ErrNoKey = errors.New("key not found in PKCS#11 token")
+)
+
+// ErrBadURI is returned by ParseURI.
+var ErrBadURI = errors.New("invalid PKCS#11 URI")
+
+// Config says how to reach the token.
+type Config struct {
+	Library string // path to the module's shared library
+	Slot    uint
+	PIN     string
+}
+
+// IsURI reports whether s is a PKCS#11 URI.
+func IsURI(s string) bool {
+	return strings.HasPrefix(s, "pkcs11:")
+}
+
+// ParseURI parses a PKCS#11 URI. It understands the path
+// attribute slot-id and the query attributes module-path
+// and pin-value, and ignores the others.
+func ParseURI(uri string) (Config, error) {
+	var c Config
+	if !IsURI(uri) {
+		return c, errors.WithDetailf(ErrBadURI, "%q does not start with pkcs11:", uri)
+	}
+	path, query := strings.TrimPrefix(uri, "pkcs11:"), ""
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path, query = path[:i], path[i+1:]
+	}
+
+	for _, attr := range strings.Split(path, ";") {
+		name, value, err := splitAttr(attr)
+		if err != nil {
+			return c, err
+		}
+		if name == "slot-id" {
+			slot, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				return c, errors.WithDetailf(ErrBadURI, "bad slot-id %q", value)
+			}
+			c.Slot = uint(slot)
+		}
+	}
+	for _, attr := range strings.Split(query, "&") {
+		name, value, err := splitAttr(attr)
+		if err != nil {
+			return c, err
+		}
+		switch name {
+		case "module-path":
+			c.Library = value
+		case "pin-value":
+			c.PIN = value
+		}
+	}
+	if c.Library == "" {
+		return c, errors.WithDetail(ErrBadURI, "no module-path")
+	}
+	return c, nil
+}
+
+func splitAttr(attr string) (name, value string, err error) {
+	if attr == "" {
+		return "", "", nil
+	}
+	i := strings.IndexByte(attr, '=')
+	if i < 0 {
+		return "", "", errors.WithDetailf(ErrBadURI, "attribute %q has no value", attr)
+	}
+	value, err = url.PathUnescape(attr[i+1:])
+	if err != nil {
+		return "", "", errors.WithDetailf(ErrBadURI, "attribute %q is badly escaped", attr)
+	}
+	return attr[:i], value, nil
+}
+
+// Sign signs the hash of bh with the private key for pub.
+// It implements blocksigner.Signer.
+func (h *HSM) Sign(ctx context.Context, pub ed25519.PublicKey, bh *bc.BlockHeader) ([]byte, error) {
+	msg := bh.Hash()
+	return h.SignMessage(ctx, pub, msg[:])
+}
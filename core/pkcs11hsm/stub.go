@@ -0,0 +1,32 @@
+//+build !pkcs11
+
+package pkcs11hsm
+
+import (
+	"context"
+
+	"chain/crypto/ed25519"
+)
+
+// HSM is a session with a PKCS#11 token.
+type HSM struct{}
+
+// Open returns ErrUnsupported.
+func Open(c Config) (*HSM, error) {
+	return nil, ErrUnsupported
+}
+
+// SignMessage returns ErrUnsupported.
+func (h *HSM) SignMessage(ctx context.Context, pub ed25519.PublicKey, msg []byte) ([]byte, error) {
+	return nil, ErrUnsupported
+}
+
+// Digest returns ErrUnsupported.
+func (h *HSM) Digest(msg []byte) ([32]byte, error) {
+	return [32]byte{}, ErrUnsupported
+}
+
+// Close does nothing.
+func (h *HSM) Close() error {
+	return nil
+}
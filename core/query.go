@@ -3,24 +3,95 @@ package core
 import (
 	"context"
 	"math"
+	"net/http"
+	"strconv"
 
 	"chain/core/query"
 	"chain/core/query/filter"
+	"chain/database/sql"
 	"chain/errors"
 	"chain/net/http/httpjson"
 )
 
+// HeaderBlockHeight, if set on a query request, pins the query
+// to the blockchain as of the given block height. A client can
+// send the same height to every core it reads from to get a
+// consistent view of the blockchain across them. A core that
+// has not yet indexed the block responds with an error.
+const HeaderBlockHeight = "Chain-Block-Height"
+
+type blockHeightKey struct{}
+
+// blockHeightContextHandler stores the block height
+// requested in HeaderBlockHeight, if any, in the
+// request context.
+func blockHeightContextHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		v := req.Header.Get(HeaderBlockHeight)
+		if v == "" {
+			handler.ServeHTTP(w, req)
+			return
+		}
+		height, err := strconv.ParseUint(v, 10, 64)
+		if err != nil || height == 0 {
+			err = errors.WithDetailf(errBadReqHeader, "invalid %s header: %q", HeaderBlockHeight, v)
+			WriteHTTPError(req.Context(), w, err)
+			return
+		}
+		// The indexer checks the height against its own progress,
+		// which a replica may not have caught up to, so pinned
+		// queries read from the primary.
+		ctx := context.WithValue(req.Context(), blockHeightKey{}, height)
+		ctx = sql.ReadPrimary(ctx)
+		handler.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+// pinnedHeight returns the block height the request in ctx
+// is pinned to, or 0 if it is not pinned, along with the
+// timestamp of that block. Query results that record no
+// history, such as accounts and assets, reflect the current
+// state, but pinned queries for them still fail until the
+// block has been indexed, so that a client reading through
+// a pinned session does not get ahead of the blockchain.
+func (a *API) pinnedHeight(ctx context.Context) (height, timestampMS uint64, err error) {
+	height, _ = ctx.Value(blockHeightKey{}).(uint64)
+	if height == 0 {
+		return 0, 0, nil
+	}
+	timestampMS, err = a.Indexer.BlockTimestamp(ctx, height)
+	return height, timestampMS, err
+}
+
+// pinTimestamp limits the point-in-time query timestamp
+// timestampMS to the timestamp of the pinned block, if any.
+func (a *API) pinTimestamp(ctx context.Context, timestampMS uint64) (uint64, error) {
+	height, pinnedMS, err := a.pinnedHeight(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if height > 0 && pinnedMS < timestampMS {
+		timestampMS = pinnedMS
+	}
+	return timestampMS, nil
+}
+
 // listAccounts is an http handler for listing accounts matching
 // an index or an ad-hoc filter.
 //
 // POST /list-accounts
 func (a *API) listAccounts(ctx context.Context, in requestQuery) (page, error) {
-	limit := in.PageSize
-	if limit == 0 {
-		limit = defGenericPageSize
+	limit, err := a.pageSize(in.PageSize)
+	if err != nil {
+		return page{}, err
 	}
 	after := in.After
 
+	_, _, err = a.pinnedHeight(ctx)
+	if err != nil {
+		return page{}, err
+	}
+
 	// Use the filter engine for querying account tags.
 	accounts, after, err := a.Indexer.Accounts(ctx, in.Filter, in.FilterParams, after, limit)
 	if err != nil {
@@ -42,12 +113,17 @@ func (a *API) listAccounts(ctx context.Context, in requestQuery) (page, error) {
 //
 // POST /list-assets
 func (a *API) listAssets(ctx context.Context, in requestQuery) (page, error) {
-	limit := in.PageSize
-	if limit == 0 {
-		limit = defGenericPageSize
+	limit, err := a.pageSize(in.PageSize)
+	if err != nil {
+		return page{}, err
 	}
 	after := in.After
 
+	_, _, err = a.pinnedHeight(ctx)
+	if err != nil {
+		return page{}, err
+	}
+
 	// Use the query engine for querying asset tags.
 	assets, after, err := a.Indexer.Assets(ctx, in.Filter, in.FilterParams, after, limit)
 	if err != nil {
@@ -81,12 +157,40 @@ func (a *API) listBalances(ctx context.Context, in requestQuery) (result page, e
 		sumBy = append(sumBy, f)
 	}
 
+	if in.BlockHeight > 0 {
+		if in.TimestampMS > 0 {
+			return result, errors.WithDetail(httpjson.ErrBadRequest, "cannot give both timestamp and block_height")
+		}
+		// A replica may not have indexed the block yet.
+		ctx = sql.ReadPrimary(ctx)
+		height := in.BlockHeight
+		pinned, _, err := a.pinnedHeight(ctx)
+		if err != nil {
+			return result, err
+		}
+		if pinned > 0 && pinned < height {
+			height = pinned
+		}
+		balances, err := a.Indexer.BalancesAtHeight(ctx, in.Filter, in.FilterParams, sumBy, height)
+		if err != nil {
+			return result, err
+		}
+		result.Items = httpjson.Array(balances)
+		result.LastPage = true
+		result.Next = in
+		return result, nil
+	}
+
 	timestampMS := in.TimestampMS
 	if timestampMS == 0 {
 		timestampMS = math.MaxInt64
 	} else if timestampMS > math.MaxInt64 {
 		return result, errors.WithDetail(httpjson.ErrBadRequest, "timestamp is too large")
 	}
+	timestampMS, err = a.pinTimestamp(ctx, timestampMS)
+	if err != nil {
+		return result, err
+	}
 
 	// TODO(jackson): paginate this endpoint.
 	balances, err := a.Indexer.Balances(ctx, in.Filter, in.FilterParams, sumBy, timestampMS)
@@ -100,6 +204,69 @@ func (a *API) listBalances(ctx context.Context, in requestQuery) (result page, e
 	return result, nil
 }
 
+// aggregateOutputs is an http handler for computing the sum of
+// the amounts and the count of the unspent outputs matching a
+// filter, grouped by the values of the fields in group_by, as
+// of a point in time. It computes both aggregates unless the
+// request names some in aggregates.
+//
+// POST /aggregate-outputs
+func (a *API) aggregateOutputs(ctx context.Context, in requestQuery) (result page, err error) {
+	var groupBy []filter.Field
+	for _, field := range in.GroupBy {
+		f, err := filter.ParseField(field)
+		if err != nil {
+			return result, err
+		}
+		groupBy = append(groupBy, f)
+	}
+	aggs := in.Aggregates
+	if len(aggs) == 0 {
+		aggs = []string{"sum", "count"}
+	}
+
+	var timestampMS uint64
+	if in.BlockHeight > 0 {
+		if in.TimestampMS > 0 {
+			return result, errors.WithDetail(httpjson.ErrBadRequest, "cannot give both timestamp and block_height")
+		}
+		// A replica may not have indexed the block yet.
+		ctx = sql.ReadPrimary(ctx)
+		height := in.BlockHeight
+		pinned, _, err := a.pinnedHeight(ctx)
+		if err != nil {
+			return result, err
+		}
+		if pinned > 0 && pinned < height {
+			height = pinned
+		}
+		timestampMS, err = a.Indexer.BlockTimestamp(ctx, height)
+		if err != nil {
+			return result, err
+		}
+	} else {
+		timestampMS = in.TimestampMS
+		if timestampMS == 0 {
+			timestampMS = math.MaxInt64
+		} else if timestampMS > math.MaxInt64 {
+			return result, errors.WithDetail(httpjson.ErrBadRequest, "timestamp is too large")
+		}
+		timestampMS, err = a.pinTimestamp(ctx, timestampMS)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	items, err := a.Indexer.AggregateOutputs(ctx, in.Filter, in.FilterParams, groupBy, aggs, timestampMS)
+	if err != nil {
+		return result, err
+	}
+	result.Items = httpjson.Array(items)
+	result.LastPage = true
+	result.Next = in
+	return result, nil
+}
+
 // listTransactions is an http handler for listing transactions matching
 // an index or an ad-hoc filter.
 //
@@ -112,53 +279,79 @@ func (a *API) listTransactions(ctx context.Context, in requestQuery) (result pag
 		defer c()
 	}
 
-	limit := in.PageSize
-	if limit == 0 {
-		limit = defGenericPageSize
+	limit, err := a.pageSize(in.PageSize)
+	if err != nil {
+		return result, err
+	}
+
+	after, err := a.txAfter(ctx, in)
+	if err != nil {
+		return result, err
+	}
+
+	txns, nextAfter, err := a.Indexer.Transactions(ctx, in.Filter, in.FilterParams, after, limit, in.AscLongPoll)
+	if err != nil {
+		return result, errors.Wrap(err, "running tx query")
 	}
 
+	out := in
+	out.After = nextAfter.String()
+	return page{
+		Items:    httpjson.Array(txns),
+		LastPage: len(txns) < limit,
+		Next:     out,
+	}, nil
+}
+
+// txAfter returns the position at which a transaction query
+// begins: either the provided `after`, or one looked up for the
+// time range, limited to the height pinned by the request.
+func (a *API) txAfter(ctx context.Context, in requestQuery) (query.TxAfter, error) {
 	endTimeMS := in.EndTimeMS
 	if endTimeMS == 0 {
 		endTimeMS = math.MaxInt64
 	} else if endTimeMS > math.MaxInt64 {
-		return result, errors.WithDetail(httpjson.ErrBadRequest, "end timestamp is too large")
+		return query.TxAfter{}, errors.WithDetail(httpjson.ErrBadRequest, "end timestamp is too large")
 	}
 
 	// Either parse the provided `after` or look one up for the time range.
 	var after query.TxAfter
+	var err error
 	if in.After != "" {
 		after, err = query.DecodeTxAfter(in.After)
 		if err != nil {
-			return result, errors.Wrap(err, "decoding `after`")
+			return after, errors.Wrap(err, "decoding `after`")
 		}
 	} else {
 		after, err = a.Indexer.LookupTxAfter(ctx, in.StartTimeMS, endTimeMS)
 		if err != nil {
-			return result, err
+			return after, err
 		}
 	}
 
-	txns, nextAfter, err := a.Indexer.Transactions(ctx, in.Filter, in.FilterParams, after, limit, in.AscLongPoll)
+	height, _, err := a.pinnedHeight(ctx)
 	if err != nil {
-		return result, errors.Wrap(err, "running tx query")
+		return after, err
 	}
-
-	out := in
-	out.After = nextAfter.String()
-	return page{
-		Items:    httpjson.Array(txns),
-		LastPage: len(txns) < limit,
-		Next:     out,
-	}, nil
+	if height > 0 {
+		if in.AscLongPoll {
+			return after, errors.WithDetailf(httpjson.ErrBadRequest, "%s cannot be used with ascending_with_long_poll", HeaderBlockHeight)
+		}
+		if after.FromBlockHeight > height {
+			after.FromBlockHeight = height
+			after.FromPosition = math.MaxInt32
+		}
+	}
+	return after, nil
 }
 
 // listTxFeeds is an http handler for listing txfeeds. It does not take a filter.
 //
 // POST /list-transaction-feeds
 func (a *API) listTxFeeds(ctx context.Context, in requestQuery) (page, error) {
-	limit := in.PageSize
-	if limit == 0 {
-		limit = defGenericPageSize
+	limit, err := a.pageSize(in.PageSize)
+	if err != nil {
+		return page{}, err
 	}
 
 	after := in.After
@@ -179,9 +372,9 @@ func (a *API) listTxFeeds(ctx context.Context, in requestQuery) (page, error) {
 
 // POST /list-unspent-outputs
 func (a *API) listUnspentOutputs(ctx context.Context, in requestQuery) (result page, err error) {
-	limit := in.PageSize
-	if limit == 0 {
-		limit = defGenericPageSize
+	limit, err := a.pageSize(in.PageSize)
+	if err != nil {
+		return result, err
 	}
 
 	var after *query.OutputsAfter
@@ -198,6 +391,10 @@ func (a *API) listUnspentOutputs(ctx context.Context, in requestQuery) (result p
 	} else if timestampMS > math.MaxInt64 {
 		return result, errors.WithDetail(httpjson.ErrBadRequest, "timestamp is too large")
 	}
+	timestampMS, err = a.pinTimestamp(ctx, timestampMS)
+	if err != nil {
+		return result, err
+	}
 	outputs, nextAfter, err := a.Indexer.Outputs(ctx, in.Filter, in.FilterParams, timestampMS, after, limit)
 	if err != nil {
 		return result, errors.Wrap(err, "querying outputs")
@@ -211,3 +408,42 @@ func (a *API) listUnspentOutputs(ctx context.Context, in requestQuery) (result p
 		Next:     outQuery,
 	}, nil
 }
+
+// reindex is an http handler that asks the leader to rebuild
+// the transaction and output indexes from the raw blocks.
+// Queries go on using the old indexes until the rebuild is
+// complete. Progress is reported by /get-reindex-status.
+//
+// POST /reindex
+func (a *API) reindex(ctx context.Context) (*query.ReindexStatus, error) {
+	return query.RequestReindex(ctx, a.DB)
+}
+
+// getReindexStatus is an http handler for the status of the
+// most recently requested index rebuild. It returns null if
+// none has been requested.
+//
+// POST /get-reindex-status
+func (a *API) getReindexStatus(ctx context.Context) (*query.ReindexStatus, error) {
+	return query.GetReindexStatus(ctx, a.DB)
+}
+
+// graphQL is an http handler for read-only GraphQL queries
+// over the annotated data. It is only available if the core
+// was started with GraphQL enabled.
+//
+// POST /graphql
+func (a *API) graphQL(ctx context.Context, in struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}) (map[string]interface{}, error) {
+	_, _, err := a.pinnedHeight(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := a.GraphQL.Execute(ctx, in.Query, in.Variables)
+	if err != nil {
+		return nil, errors.Wrap(err, "executing graphql query")
+	}
+	return map[string]interface{}{"data": data}, nil
+}
@@ -19,12 +19,12 @@ func (ind *Indexer) SaveAnnotatedAccount(ctx context.Context, account *Annotated
 	}
 
 	const q = `
-		INSERT INTO annotated_accounts (id, alias, keys, quorum, tags)
-		VALUES($1, $2, $3::jsonb, $4, $5::jsonb)
-		ON CONFLICT (id) DO UPDATE SET tags = $5::jsonb
+		INSERT INTO annotated_accounts (id, alias, keys, quorum, tags, deleted)
+		VALUES($1, $2, $3::jsonb, $4, $5::jsonb, $6)
+		ON CONFLICT (id) DO UPDATE SET alias = $2, tags = $5::jsonb, deleted = $6
 	`
 	_, err = ind.db.Exec(ctx, q, account.ID, account.Alias, keysJSON,
-		account.Quorum, string(*account.Tags))
+		account.Quorum, string(*account.Tags), bool(account.IsDeleted))
 	return errors.Wrap(err, "saving annotated account")
 }
 
@@ -43,7 +43,7 @@ func (ind *Indexer) Accounts(ctx context.Context, filt string, vals []interface{
 	}
 
 	queryStr, queryArgs := constructAccountsQuery(expr, vals, after, limit)
-	rows, err := ind.db.Query(ctx, queryStr, queryArgs...)
+	rows, err := ind.readDB.Query(ctx, queryStr, queryArgs...)
 	if err != nil {
 		return nil, "", errors.Wrap(err, "executing acc query")
 	}
@@ -60,6 +60,7 @@ func (ind *Indexer) Accounts(ctx context.Context, filt string, vals []interface{
 			&keysJSON,
 			&aa.Quorum,
 			&aa.Tags,
+			&aa.IsDeleted,
 		)
 		if err != nil {
 			return nil, "", errors.Wrap(err, "scanning account row")
@@ -79,7 +80,7 @@ func constructAccountsQuery(expr string, vals []interface{}, after string, limit
 	var buf bytes.Buffer
 
 	buf.WriteString("SELECT ")
-	buf.WriteString("id, alias, keys, quorum, tags")
+	buf.WriteString("id, alias, keys, quorum, tags, deleted")
 	buf.WriteString(" FROM annotated_accounts AS acc")
 	buf.WriteString(" WHERE ")
 
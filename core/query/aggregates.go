@@ -0,0 +1,142 @@
+package query
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/lib/pq"
+
+	"chain/core/query/filter"
+	"chain/errors"
+)
+
+// ErrBadAggregate is returned when an aggregate query
+// names an aggregate that is not in aggregateSQL.
+var ErrBadAggregate = errors.New("invalid aggregate")
+
+// aggregateSQL maps the aggregates an aggregate
+// query can compute over a group of outputs to
+// their SQL.
+var aggregateSQL = map[string]string{
+	"sum":   "COALESCE(SUM(amount), 0)",
+	"count": "COUNT(*)",
+}
+
+// AggregateOutputs computes the aggregates named in aggs over
+// the unspent outputs matching filt as of the given time, with
+// one result for each distinct combination of the values of
+// the fields in groupBy. The aggregates are "sum", the sum of
+// the outputs' amounts, and "count", the number of outputs.
+func (ind *Indexer) AggregateOutputs(ctx context.Context, filt string, vals []interface{}, groupBy []filter.Field, aggs []string, timestampMS uint64) ([]interface{}, error) {
+	p, err := filter.Parse(filt, outputsTable, vals)
+	if err != nil {
+		return nil, err
+	}
+	if len(vals) != p.Parameters {
+		return nil, ErrParameterCountMismatch
+	}
+	expr, err := filter.AsSQL(p, outputsTable, vals)
+	if err != nil {
+		return nil, err
+	}
+	queryStr, queryArgs, err := constructAggregateQuery(expr, vals, groupBy, aggs, timestampMS)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := ind.readDB.Query(ctx, queryStr, queryArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []interface{}
+	for rows.Next() {
+		values := make([]uint64, len(aggs))
+		groups := make([]*string, len(groupBy))
+		scanArguments := make([]interface{}, 0, len(aggs)+len(groupBy))
+		for i := range values {
+			scanArguments = append(scanArguments, &values[i])
+		}
+		for i := range groups {
+			scanArguments = append(scanArguments, &groups[i])
+		}
+		err := rows.Scan(scanArguments...)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning aggregate row")
+		}
+
+		// This struct enforces JSON field ordering in API output.
+		item := struct {
+			GroupBy map[string]interface{} `json:"group_by,omitempty"`
+			Sum     *uint64                `json:"sum,omitempty"`
+			Count   *uint64                `json:"count,omitempty"`
+		}{}
+		if len(groupBy) > 0 {
+			item.GroupBy = make(map[string]interface{}, len(groupBy))
+			for i, f := range groupBy {
+				item.GroupBy[f.String()] = groups[i]
+			}
+		}
+		for i, agg := range aggs {
+			switch agg {
+			case "sum":
+				item.Sum = &values[i]
+			case "count":
+				item.Count = &values[i]
+			}
+		}
+		results = append(results, item)
+	}
+	return results, errors.Wrap(rows.Err())
+}
+
+func constructAggregateQuery(expr string, vals []interface{}, groupBy []filter.Field, aggs []string, timestampMS uint64) (string, []interface{}, error) {
+	if len(aggs) == 0 {
+		return "", nil, errors.WithDetail(ErrBadAggregate, "no aggregates")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("SELECT ")
+	for i, agg := range aggs {
+		aggSQL, ok := aggregateSQL[agg]
+		if !ok {
+			return "", nil, errors.WithDetailf(ErrBadAggregate, "unknown aggregate %q", agg)
+		}
+		if i != 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(aggSQL)
+	}
+	for _, field := range groupBy {
+		fieldSQL, err := filter.FieldAsSQL(outputsTable, field)
+		if err != nil {
+			return "", nil, err
+		}
+		buf.WriteString(", ")
+		buf.WriteString(fieldSQL)
+	}
+	buf.WriteString(" FROM ")
+	buf.WriteString(pq.QuoteIdentifier("annotated_outputs"))
+	buf.WriteString(" AS out WHERE ")
+	if len(expr) > 0 {
+		buf.WriteString("(")
+		buf.WriteString(expr)
+		buf.WriteString(") AND ")
+	}
+
+	vals = append(vals, timestampMS)
+	buf.WriteString(fmt.Sprintf("timespan @> $%d::int8", len(vals)))
+
+	if len(groupBy) > 0 {
+		buf.WriteString(" GROUP BY ")
+		for i := range groupBy {
+			if i != 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(strconv.Itoa(len(aggs) + i + 1)) // 1-indexed, after the aggregates
+		}
+	}
+	return buf.String(), vals, nil
+}
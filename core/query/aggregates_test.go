@@ -0,0 +1,80 @@
+package query
+
+import (
+	"testing"
+
+	"chain/core/query/filter"
+	"chain/errors"
+	"chain/testutil"
+)
+
+func TestConstructAggregateQuery(t *testing.T) {
+	now := uint64(123456)
+	testCases := []struct {
+		predicate  string
+		groupBy    []string
+		aggs       []string
+		values     []interface{}
+		wantQuery  string
+		wantValues []interface{}
+	}{
+		{
+			predicate:  "account_id = $1",
+			groupBy:    []string{"asset_alias"},
+			aggs:       []string{"sum", "count"},
+			values:     []interface{}{"abc"},
+			wantQuery:  `SELECT COALESCE(SUM(amount), 0), COUNT(*), out."asset_alias" FROM "annotated_outputs" AS out WHERE (out."account_id" = $1) AND timespan @> $2::int8 GROUP BY 3`,
+			wantValues: []interface{}{"abc", now},
+		},
+		{
+			predicate:  "",
+			groupBy:    []string{"account_id", "asset_tags.currency"},
+			aggs:       []string{"count"},
+			wantQuery:  `SELECT COUNT(*), out."account_id", out."asset_tags"->>'currency' FROM "annotated_outputs" AS out WHERE timespan @> $1::int8 GROUP BY 2, 3`,
+			wantValues: []interface{}{now},
+		},
+		{
+			predicate:  "amount > 10",
+			aggs:       []string{"sum"},
+			wantQuery:  `SELECT COALESCE(SUM(amount), 0) FROM "annotated_outputs" AS out WHERE (out."amount" > 10::bigint) AND timespan @> $1::int8`,
+			wantValues: []interface{}{now},
+		},
+	}
+
+	for i, tc := range testCases {
+		p, err := filter.Parse(tc.predicate, outputsTable, tc.values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expr, err := filter.AsSQL(p, outputsTable, tc.values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fields []filter.Field
+		for _, s := range tc.groupBy {
+			f, err := filter.ParseField(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fields = append(fields, f)
+		}
+
+		query, values, err := constructAggregateQuery(expr, tc.values, fields, tc.aggs, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if query != tc.wantQuery {
+			t.Errorf("case %d: got\n%s\nwant\n%s", i, query, tc.wantQuery)
+		}
+		if !testutil.DeepEqual(values, tc.wantValues) {
+			t.Errorf("case %d: got %#v, want %#v", i, values, tc.wantValues)
+		}
+	}
+
+	for _, aggs := range [][]string{nil, {"sum", "avg"}} {
+		_, _, err := constructAggregateQuery("", nil, nil, aggs, now)
+		if errors.Root(err) != ErrBadAggregate {
+			t.Errorf("constructAggregateQuery(aggs=%q) err = %v want %v", aggs, err, ErrBadAggregate)
+		}
+	}
+}
@@ -63,11 +63,12 @@ type AnnotatedOutput struct {
 }
 
 type AnnotatedAccount struct {
-	ID     string           `json:"id"`
-	Alias  string           `json:"alias,omitempty"`
-	Keys   []*AccountKey    `json:"keys"`
-	Quorum int              `json:"quorum"`
-	Tags   *json.RawMessage `json:"tags"`
+	ID        string           `json:"id"`
+	Alias     string           `json:"alias,omitempty"`
+	Keys      []*AccountKey    `json:"keys"`
+	Quorum    int              `json:"quorum"`
+	Tags      *json.RawMessage `json:"tags"`
+	IsDeleted Bool             `json:"is_deleted,omitempty"`
 }
 
 type AccountKey struct {
@@ -77,14 +78,15 @@ type AccountKey struct {
 }
 
 type AnnotatedAsset struct {
-	ID              bc.AssetID         `json:"id"`
-	Alias           string             `json:"alias,omitempty"`
-	IssuanceProgram chainjson.HexBytes `json:"issuance_program"`
-	Keys            []*AssetKey        `json:"keys"`
-	Quorum          int                `json:"quorum"`
-	Definition      *json.RawMessage   `json:"definition"`
-	Tags            *json.RawMessage   `json:"tags"`
-	IsLocal         Bool               `json:"is_local"`
+	ID                bc.AssetID         `json:"id"`
+	Alias             string             `json:"alias,omitempty"`
+	IssuanceProgram   chainjson.HexBytes `json:"issuance_program"`
+	Keys              []*AssetKey        `json:"keys"`
+	Quorum            int                `json:"quorum"`
+	Definition        *json.RawMessage   `json:"definition"`
+	DefinitionVersion uint64             `json:"definition_version"`
+	Tags              *json.RawMessage   `json:"tags"`
+	IsLocal           Bool               `json:"is_local"`
 }
 
 type AssetKey struct {
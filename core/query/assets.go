@@ -20,12 +20,14 @@ func (ind *Indexer) SaveAnnotatedAsset(ctx context.Context, asset *AnnotatedAsse
 
 	const q = `
 		INSERT INTO annotated_assets
-			(id, sort_id, alias, issuance_program, keys, quorum, definition, tags, local)
-		VALUES($1, $2, $3, $4, $5, $6, $7::jsonb, $8::jsonb, $9)
-		ON CONFLICT (id) DO UPDATE SET sort_id = $2, tags = $8::jsonb
+			(id, sort_id, alias, issuance_program, keys, quorum, definition, tags, local, definition_version)
+		VALUES($1, $2, $3, $4, $5, $6, $7::jsonb, $8::jsonb, $9, $10)
+		ON CONFLICT (id) DO UPDATE SET sort_id = $2, tags = $8::jsonb,
+			definition = $7::jsonb, definition_version = $10
 	`
 	_, err = ind.db.Exec(ctx, q, asset.ID, sortID, asset.Alias, []byte(asset.IssuanceProgram),
-		keysJSON, asset.Quorum, string(*asset.Definition), string(*asset.Tags), bool(asset.IsLocal))
+		keysJSON, asset.Quorum, string(*asset.Definition), string(*asset.Tags), bool(asset.IsLocal),
+		int64(asset.DefinitionVersion))
 	return errors.Wrap(err, "saving annotated asset")
 }
 
@@ -44,7 +46,7 @@ func (ind *Indexer) Assets(ctx context.Context, filt string, vals []interface{},
 	}
 
 	queryStr, queryArgs := constructAssetsQuery(expr, vals, after, limit)
-	rows, err := ind.db.Query(ctx, queryStr, queryArgs...)
+	rows, err := ind.readDB.Query(ctx, queryStr, queryArgs...)
 	if err != nil {
 		return nil, "", errors.Wrap(err, "executing assets query")
 	}
@@ -67,6 +69,7 @@ func (ind *Indexer) Assets(ctx context.Context, filt string, vals []interface{},
 			&aa.Definition,
 			&aa.Tags,
 			&aa.IsLocal,
+			&aa.DefinitionVersion,
 		)
 		if err != nil {
 			return nil, "", errors.Wrap(err, "scanning annotated asset row")
@@ -91,7 +94,7 @@ func constructAssetsQuery(expr string, vals []interface{}, after string, limit i
 	var buf bytes.Buffer
 
 	buf.WriteString("SELECT ")
-	buf.WriteString("id, sort_id, alias, issuance_program, keys, quorum, definition, tags, local")
+	buf.WriteString("id, sort_id, alias, issuance_program, keys, quorum, definition, tags, local, definition_version")
 	buf.WriteString(" FROM annotated_assets AS ast")
 	buf.WriteString(" WHERE ")
 
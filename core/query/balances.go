@@ -3,6 +3,7 @@ package query
 import (
 	"bytes"
 	"context"
+	"encoding/hex"
 	"fmt"
 	"strconv"
 
@@ -10,10 +11,31 @@ import (
 
 	"chain/core/query/filter"
 	"chain/errors"
+	"chain/protocol/bc"
 )
 
-// Balances performs a balances query against the annotated_outputs.
+// Balances performs a balances query against the annotated_outputs,
+// as of the given time.
 func (ind *Indexer) Balances(ctx context.Context, filt string, vals []interface{}, sumBy []filter.Field, timestampMS uint64) ([]interface{}, error) {
+	return ind.balances(ctx, filt, vals, sumBy, timestampMS, 0)
+}
+
+// BalancesAtHeight is like Balances, but as of the block
+// at the given height. It returns ErrHeightNotIndexed if
+// the indexer has not yet finished indexing that block.
+func (ind *Indexer) BalancesAtHeight(ctx context.Context, filt string, vals []interface{}, sumBy []filter.Field, height uint64) ([]interface{}, error) {
+	timestampMS, err := ind.BlockTimestamp(ctx, height)
+	if err != nil {
+		return nil, err
+	}
+	return ind.balances(ctx, filt, vals, sumBy, timestampMS, height)
+}
+
+// balances performs a balances query as of timestampMS, or
+// if height is not 0, as of the block at height, which has
+// timestamp timestampMS. It uses the account_balances table
+// when the query allows, and annotated_outputs otherwise.
+func (ind *Indexer) balances(ctx context.Context, filt string, vals []interface{}, sumBy []filter.Field, timestampMS, height uint64) ([]interface{}, error) {
 	p, err := filter.Parse(filt, outputsTable, vals)
 	if err != nil {
 		return nil, err
@@ -21,15 +43,26 @@ func (ind *Indexer) Balances(ctx context.Context, filt string, vals []interface{
 	if len(vals) != p.Parameters {
 		return nil, ErrParameterCountMismatch
 	}
-	expr, err := filter.AsSQL(p, outputsTable, vals)
-	if err != nil {
-		return nil, err
+
+	var (
+		queryStr  string
+		queryArgs []interface{}
+		ok        bool
+	)
+	if terms, isEq := filter.EqualityTerms(p, vals); isEq {
+		queryStr, queryArgs, ok = constructIndexedBalancesQuery(terms, sumBy, timestampMS, height)
 	}
-	queryStr, queryArgs, err := constructBalancesQuery(expr, vals, sumBy, timestampMS)
-	if err != nil {
-		return nil, err
+	if !ok {
+		expr, err := filter.AsSQL(p, outputsTable, vals)
+		if err != nil {
+			return nil, err
+		}
+		queryStr, queryArgs, err = constructBalancesQuery(expr, vals, sumBy, timestampMS)
+		if err != nil {
+			return nil, err
+		}
 	}
-	rows, err := ind.db.Query(ctx, queryStr, queryArgs...)
+	rows, err := ind.readDB.Query(ctx, queryStr, queryArgs...)
 	if err != nil {
 		return nil, err
 	}
@@ -107,3 +140,117 @@ func constructBalancesQuery(expr string, vals []interface{}, sumBy []filter.Fiel
 	// TODO(jackson): Support pagination.
 	return buf.String(), vals, nil
 }
+
+// indexedBalanceFields maps the fields by which balances
+// from account_balances can be summed to their SQL.
+var indexedBalanceFields = map[string]string{
+	"account_id":  "b.account_id",
+	"asset_id":    "encode(b.asset_id, 'hex')",
+	"asset_alias": "COALESCE(ast.alias, '')",
+}
+
+// constructIndexedBalancesQuery returns a query of the
+// account_balances table equivalent to the balances query
+// with the given filter terms, if there is one: the terms
+// must fix the account_id, and may fix the asset_id, and
+// the balances must be summed by fields in
+// indexedBalanceFields. It reports whether there is one.
+func constructIndexedBalancesQuery(terms map[string]interface{}, sumBy []filter.Field, timestampMS, height uint64) (string, []interface{}, bool) {
+	accountID, ok := terms["account_id"].(string)
+	if !ok {
+		return "", nil, false
+	}
+	var assetID []byte
+	for attr, v := range terms {
+		switch attr {
+		case "account_id":
+		case "asset_id":
+			s, ok := v.(string)
+			if !ok {
+				return "", nil, false
+			}
+			var err error
+			assetID, err = hex.DecodeString(s)
+			if err != nil {
+				return "", nil, false
+			}
+		default:
+			return "", nil, false
+		}
+	}
+	var fields []string
+	for _, f := range sumBy {
+		fieldSQL, ok := indexedBalanceFields[f.String()]
+		if !ok {
+			return "", nil, false
+		}
+		fields = append(fields, fieldSQL)
+	}
+
+	// The latest balance of each asset at the given point,
+	// which an index scan finds directly.
+	args := []interface{}{accountID}
+	where := "account_id = $1"
+	if assetID != nil {
+		args = append(args, assetID)
+		where += fmt.Sprintf(" AND asset_id = $%d", len(args))
+	}
+	if height > 0 {
+		args = append(args, height)
+		where += fmt.Sprintf(" AND block_height <= $%d", len(args))
+	} else {
+		args = append(args, timestampMS)
+		where += fmt.Sprintf(" AND block_timestamp <= $%d", len(args))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("SELECT COALESCE(SUM(b.amount), 0)")
+	for _, f := range fields {
+		buf.WriteString(", ")
+		buf.WriteString(f)
+	}
+	buf.WriteString(" FROM (SELECT DISTINCT ON (asset_id) account_id, asset_id, amount FROM account_balances WHERE ")
+	buf.WriteString(where)
+	buf.WriteString(" ORDER BY asset_id, block_height DESC) AS b")
+	buf.WriteString(" LEFT JOIN annotated_assets AS ast ON ast.id = b.asset_id")
+	buf.WriteString(" WHERE b.amount <> 0")
+	if len(fields) > 0 {
+		buf.WriteString(" GROUP BY ")
+		for i := range fields {
+			if i != 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(strconv.Itoa(i + 2)) // 1-indexed, skipping first col
+		}
+	}
+	return buf.String(), args, true
+}
+
+// insertBalances records the balance of each account in
+// each asset whose balance changed in b. It must run after
+// b's annotated outputs and inputs are stored.
+func (ind *Indexer) insertBalances(ctx context.Context, b *bc.Block) error {
+	const q = `
+		WITH deltas AS (
+			SELECT account_id, asset_id, SUM(amount)::bigint AS delta FROM (
+				SELECT account_id, asset_id, amount FROM annotated_outputs
+				WHERE block_height = $1 AND account_id IS NOT NULL
+				UNION ALL
+				SELECT inp.account_id, inp.asset_id, -inp.amount
+				FROM annotated_inputs inp JOIN annotated_txs txs ON txs.tx_hash = inp.tx_hash
+				WHERE txs.block_height = $1 AND inp.type = 'spend' AND inp.account_id IS NOT NULL
+			) AS changes
+			GROUP BY account_id, asset_id HAVING SUM(amount) <> 0
+		)
+		INSERT INTO account_balances (account_id, asset_id, block_height, block_timestamp, amount)
+		SELECT d.account_id, d.asset_id, $1, $2, d.delta + COALESCE((
+			SELECT amount FROM account_balances
+			WHERE account_id = d.account_id AND asset_id = d.asset_id AND block_height < $1
+			ORDER BY block_height DESC LIMIT 1
+		), 0)
+		FROM deltas d
+		ON CONFLICT (account_id, asset_id, block_height) DO NOTHING
+	`
+	_, err := ind.db.Exec(ctx, q, b.Height, b.TimestampMS)
+	return errors.Wrap(err, "inserting account balances")
+}
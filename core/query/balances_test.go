@@ -74,3 +74,69 @@ func TestConstructBalancesQuery(t *testing.T) {
 		}
 	}
 }
+
+func TestConstructIndexedBalancesQuery(t *testing.T) {
+	now := uint64(123456)
+	const latest = `SELECT DISTINCT ON (asset_id) account_id, asset_id, amount FROM account_balances WHERE `
+	const rest = ` ORDER BY asset_id, block_height DESC) AS b LEFT JOIN annotated_assets AS ast ON ast.id = b.asset_id WHERE b.amount <> 0`
+	testCases := []struct {
+		predicate  string
+		sumBy      []string
+		values     []interface{}
+		height     uint64
+		wantOK     bool
+		wantQuery  string
+		wantValues []interface{}
+	}{
+		{
+			predicate:  "account_id = 'abc'",
+			sumBy:      []string{"asset_alias", "asset_id"},
+			wantOK:     true,
+			wantQuery:  `SELECT COALESCE(SUM(b.amount), 0), COALESCE(ast.alias, ''), encode(b.asset_id, 'hex') FROM (` + latest + `account_id = $1 AND block_timestamp <= $2` + rest + ` GROUP BY 2, 3`,
+			wantValues: []interface{}{"abc", now},
+		},
+		{
+			predicate:  "asset_id = $1 AND account_id = $2",
+			values:     []interface{}{"c0ffee", "abc"},
+			height:     7,
+			wantOK:     true,
+			wantQuery:  `SELECT COALESCE(SUM(b.amount), 0) FROM (` + latest + `account_id = $1 AND asset_id = $2 AND block_height <= $3` + rest,
+			wantValues: []interface{}{"abc", []byte{0xc0, 0xff, 0xee}, uint64(7)},
+		},
+		{predicate: "asset_id = 'c0ffee'"},                               // no account
+		{predicate: "account_id = 'abc' AND asset_id = 'foo'"},           // asset ID not hex
+		{predicate: "account_id = 'abc' AND amount = 5"},                 // other attribute
+		{predicate: "account_id = 'abc'", sumBy: []string{"asset_tags"}}, // other field
+	}
+
+	for i, tc := range testCases {
+		p, err := filter.Parse(tc.predicate, outputsTable, tc.values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		terms, ok := filter.EqualityTerms(p, tc.values)
+		if !ok {
+			t.Fatalf("case %d: EqualityTerms(%q) not ok", i, tc.predicate)
+		}
+		var fields []filter.Field
+		for _, s := range tc.sumBy {
+			f, err := filter.ParseField(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fields = append(fields, f)
+		}
+
+		query, values, ok := constructIndexedBalancesQuery(terms, fields, now, tc.height)
+		if ok != tc.wantOK {
+			t.Errorf("case %d: ok = %v want %v", i, ok, tc.wantOK)
+			continue
+		}
+		if query != tc.wantQuery {
+			t.Errorf("case %d: got\n%s\nwant\n%s", i, query, tc.wantQuery)
+		}
+		if !testutil.DeepEqual(values, tc.wantValues) {
+			t.Errorf("case %d: got %#v, want %#v", i, values, tc.wantValues)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package query
+
+import (
+	"context"
+	"encoding/json"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// AssetDefinitionVersion is a version of an asset's definition.
+// Version 0 is the original definition, from which the asset ID
+// is derived. Later versions were published by transactions
+// issuing the asset, and have the ID and block height of the
+// transaction.
+type AssetDefinitionVersion struct {
+	Version     uint64           `json:"version"`
+	Definition  *json.RawMessage `json:"definition"`
+	TxID        *bc.Hash         `json:"transaction_id,omitempty"`
+	BlockHeight uint64           `json:"block_height,omitempty"`
+}
+
+// AssetDefinitionHistory returns the versions of the definition of
+// the asset with the given ID, oldest first. The versions are
+// recorded by the asset block processor.
+func (ind *Indexer) AssetDefinitionHistory(ctx context.Context, assetID bc.AssetID) ([]*AssetDefinitionVersion, error) {
+	const q = `
+		SELECT 0, definition, NULL::bytea, 0 FROM assets WHERE id = $1
+		UNION ALL
+		SELECT version, definition, tx_hash, block_height FROM asset_definition_versions WHERE asset_id = $1
+		ORDER BY 1
+	`
+	var versions []*AssetDefinitionVersion
+	err := pg.ForQueryRows(ctx, ind.readDB, q, assetID, func(version uint64, def []byte, txHash []byte, height uint64) {
+		// Non-JSON original definitions are shown as empty,
+		// as in annotated transactions.
+		jsonDef := emptyJSONObject
+		var v interface{}
+		if len(def) > 0 && json.Unmarshal(def, &v) == nil {
+			jsonDef = json.RawMessage(def)
+		}
+		dv := &AssetDefinitionVersion{
+			Version:     version,
+			Definition:  &jsonDef,
+			BlockHeight: height,
+		}
+		if len(txHash) > 0 {
+			var h bc.Hash
+			copy(h[:], txHash)
+			dv.TxID = &h
+		}
+		versions = append(versions, dv)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "querying asset definition history")
+	}
+	if len(versions) == 0 {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "asset id: %s", assetID)
+	}
+	return versions, nil
+}
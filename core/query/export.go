@@ -0,0 +1,75 @@
+package query
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"chain/errors"
+)
+
+// TxCSVHeader names the columns of the records
+// written by WriteTxsCSV.
+var TxCSVHeader = []string{
+	"cursor",
+	"id",
+	"timestamp",
+	"block_id",
+	"block_height",
+	"position",
+	"is_local",
+	"reference_data",
+	"inputs",
+	"outputs",
+}
+
+// WriteTxsCSV writes a CSV record for each of txs to w, in the
+// columns of TxCSVHeader, and flushes w. The reference data,
+// inputs, and outputs are written as JSON.
+//
+// A record's cursor is the `after` of a query, stopping at
+// stopHeight, for the transactions that follow it. An export
+// cut short resumes with the cursor of its last record.
+func WriteTxsCSV(w *csv.Writer, txs []*AnnotatedTx, stopHeight uint64) error {
+	for _, tx := range txs {
+		cursor := TxAfter{
+			FromBlockHeight: tx.BlockHeight,
+			FromPosition:    tx.Position,
+			StopBlockHeight: stopHeight,
+		}
+		var refData string
+		if tx.ReferenceData != nil {
+			refData = string(*tx.ReferenceData)
+		}
+		inputs, err := json.Marshal(tx.Inputs)
+		if err != nil {
+			return errors.Wrap(err, "encoding inputs")
+		}
+		outputs, err := json.Marshal(tx.Outputs)
+		if err != nil {
+			return errors.Wrap(err, "encoding outputs")
+		}
+		isLocal := "no"
+		if tx.IsLocal {
+			isLocal = "yes"
+		}
+		err = w.Write([]string{
+			cursor.String(),
+			tx.ID.String(),
+			tx.Timestamp.Format(time.RFC3339Nano),
+			tx.BlockID.String(),
+			strconv.FormatUint(tx.BlockHeight, 10),
+			strconv.FormatUint(uint64(tx.Position), 10),
+			isLocal,
+			refData,
+			string(inputs),
+			string(outputs),
+		})
+		if err != nil {
+			return errors.Wrap(err, "writing csv")
+		}
+	}
+	w.Flush()
+	return errors.Wrap(w.Error(), "writing csv")
+}
@@ -0,0 +1,65 @@
+package query
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"chain/protocol/bc"
+)
+
+func TestWriteTxsCSV(t *testing.T) {
+	refData := json.RawMessage(`{"a":"b,c"}`)
+	txs := []*AnnotatedTx{{
+		ID:            bc.Hash{},
+		Timestamp:     time.Unix(1491782400, 0).UTC(),
+		BlockHeight:   7,
+		Position:      2,
+		ReferenceData: &refData,
+		IsLocal:       true,
+		Inputs:        []*AnnotatedInput{{Type: "issue", Amount: 10}},
+	}}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	err := WriteTxsCSV(w, txs, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("got %d records, want 1", len(records))
+	}
+	got := records[0]
+	if len(got) != len(TxCSVHeader) {
+		t.Fatalf("got %d columns, want %d", len(got), len(TxCSVHeader))
+	}
+	want := map[string]string{
+		"cursor":         "7:2-5",
+		"timestamp":      "2017-04-10T00:00:00Z",
+		"block_height":   "7",
+		"position":       "2",
+		"is_local":       "yes",
+		"reference_data": `{"a":"b,c"}`,
+		"outputs":        "null",
+	}
+	for i, col := range TxCSVHeader {
+		if w, ok := want[col]; ok && got[i] != w {
+			t.Errorf("%s = %q want %q", col, got[i], w)
+		}
+	}
+
+	after, err := DecodeTxAfter(got[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.FromBlockHeight != 7 || after.FromPosition != 2 || after.StopBlockHeight != 5 {
+		t.Errorf("cursor = %+v", after)
+	}
+}
@@ -12,8 +12,12 @@ Expressions in a filter expression have the following forms:
   Form                     Type     Subexpression types
   expr1 "OR" expr2         bool     bool, bool
   expr1 "AND" expr2        bool     bool, bool
+  "NOT" expr               bool     bool
   ident "(" expr ")"       bool     list, bool
-  expr1 "=" expr2          bool     any (must match)
+  expr1 "=" expr2          bool     scalar (must match)
+  expr1 cmp expr2          bool     scalar (must match)
+  expr "IN" "(" exprs ")"  bool     scalar (must match)
+  expr1 "PREFIX" expr2     bool     string, string
   expr "." ident           any      object
   "(" expr ")"             any      any
   ident                    any      n/a
@@ -22,6 +26,8 @@ Expressions in a filter expression have the following forms:
   int                      int      n/a
 
   ident is an alphanumeric identifier
+  cmp is one of "<", "<=", ">", ">="
+  exprs is one or more exprs, separated by ","
   placeholder is a decimal int with prefix "$"
   scalar means int or string
   string is single-quoted, and cannot contain backslash
@@ -37,6 +43,9 @@ subexpression 'expr' is evaluated in each subenvironment, and if
 there exists one subenvironment for which 'expr' is true, the
 expression as a whole is true.
 
+The form 'expr1 PREFIX expr2' is true if the string expr1 begins
+with the string expr2.
+
 Filters are statically type-checked: if a subexpression doesn't have
 the appropriate type, Parse will return an error.
 
@@ -15,6 +15,29 @@ func (e binaryExpr) String() string {
 	return e.l.String() + " " + e.op.name + " " + e.r.String()
 }
 
+type notExpr struct {
+	inner expr
+}
+
+func (e notExpr) String() string {
+	return "NOT " + e.inner.String()
+}
+
+type listExpr struct {
+	elems []expr
+}
+
+func (e listExpr) String() string {
+	s := "("
+	for i, elem := range e.elems {
+		if i > 0 {
+			s += ", "
+		}
+		s += elem.String()
+	}
+	return s + ")"
+}
+
 type attrExpr struct {
 	attr string
 }
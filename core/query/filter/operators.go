@@ -7,7 +7,18 @@ type binaryOp struct {
 }
 
 var binaryOps = map[string]*binaryOp{
-	"OR":  {1, "OR", "OR"},
-	"AND": {2, "AND", "AND"},
-	"=":   {3, "=", "="},
+	"OR":     {1, "OR", "OR"},
+	"AND":    {2, "AND", "AND"},
+	"=":      {3, "=", "="},
+	"<":      {3, "<", "<"},
+	"<=":     {3, "<=", "<="},
+	">":      {3, ">", ">"},
+	">=":     {3, ">=", ">="},
+	"IN":     {3, "IN", "IN"},
+	"PREFIX": {3, "PREFIX", "LIKE"},
 }
+
+// notPrecedence is the precedence of the unary NOT
+// operator: it binds more loosely than comparisons,
+// but more tightly than AND and OR.
+const notPrecedence = 3
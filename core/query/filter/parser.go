@@ -159,7 +159,12 @@ func parseExprCont(p *parser, lhs expr, minPrecedence int) expr {
 		}
 		p.next()
 
-		rhs := parsePrimaryExpr(p)
+		var rhs expr
+		if op.name == "IN" {
+			rhs = parseList(p)
+		} else {
+			rhs = parsePrimaryExpr(p)
+		}
 
 		for {
 			op2, ok := determineBinaryOp(p, op.precedence+1)
@@ -183,6 +188,10 @@ func parsePrimaryExpr(p *parser) expr {
 
 func parseOperand(p *parser) expr {
 	switch {
+	case p.lit == "NOT":
+		p.next()
+		inner := parseExprCont(p, parsePrimaryExpr(p), notPrecedence)
+		return notExpr{inner: inner}
 	case p.lit == "(":
 		p.next()
 		expr := parseExpr(p)
@@ -220,6 +229,22 @@ func parseOperand(p *parser) expr {
 	}
 }
 
+// parseList parses the parenthesized,
+// comma-separated list after IN.
+func parseList(p *parser) expr {
+	p.parseLit("(")
+	var list listExpr
+	for {
+		list.elems = append(list.elems, parsePrimaryExpr(p))
+		if p.lit != "," {
+			break
+		}
+		p.next()
+	}
+	p.parseLit(")")
+	return list
+}
+
 func parseSelectorExpr(p *parser, objExpr expr) expr {
 	p.next() // move past the '.'
 
@@ -118,6 +118,38 @@ func TestParseValid(t *testing.T) {
 				},
 			},
 		},
+		{
+			p: "NOT amount < 100 AND asset_alias IN ('gold', $1)",
+			expr: binaryExpr{
+				op: binaryOps["AND"],
+				l: notExpr{
+					inner: binaryExpr{
+						op: binaryOps["<"],
+						l:  attrExpr{attr: "amount"},
+						r:  valueExpr{typ: tokInteger, value: "100"},
+					},
+				},
+				r: binaryExpr{
+					op: binaryOps["IN"],
+					l:  attrExpr{attr: "asset_alias"},
+					r: listExpr{elems: []expr{
+						valueExpr{typ: tokString, value: "'gold'"},
+						placeholderExpr{num: 1},
+					}},
+				},
+			},
+		},
+		{
+			p: "reference_data.invoice PREFIX 'INV-'",
+			expr: binaryExpr{
+				op: binaryOps["PREFIX"],
+				l: selectorExpr{
+					objExpr: attrExpr{attr: "reference_data"},
+					ident:   "invoice",
+				},
+				r: valueExpr{typ: tokString, value: "'INV-'"},
+			},
+		},
 	}
 
 	for i, tc := range testCases {
@@ -146,6 +178,9 @@ func TestParseInvalid(t *testing.T) {
 		"an_identifier another_identifier",            // two identifiers w/o an operator (trailing garbage)
 		"inputs(account_tags.level = $1) or (1 == 1)", // lowercase 'or' (trailing garbage)
 		"reference.(recipient.email_address)`",        // expected ident, got paren expr
+		"amount IN ()",                                // empty IN list
+		"amount IN 1",                                 // IN without list
+		"amount =< 1",                                 // =< is not an operator
 	}
 	for _, tc := range testCases {
 		expr, _, err := parse(tc)
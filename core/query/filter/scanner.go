@@ -186,7 +186,7 @@ func (s *scanner) Scan() (pos int, tok token, lit string) {
 	case isLetter(ch):
 		lit = s.scanIdentifier()
 		switch lit {
-		case "AND", "OR":
+		case "AND", "OR", "NOT", "IN", "PREFIX":
 			tok = tokKeyword
 		default:
 			tok = tokIdent
@@ -203,7 +203,12 @@ func (s *scanner) Scan() (pos int, tok token, lit string) {
 		case '\'':
 			tok = tokString
 			s.scanString()
-		case '.', '(', ')', '=':
+		case '.', '(', ')', '=', ',':
+			tok = tokPunct
+		case '<', '>':
+			if s.ch == '=' {
+				s.next()
+			}
 			tok = tokPunct
 		case '$':
 			s.scanMantissa(10)
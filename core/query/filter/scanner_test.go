@@ -35,6 +35,29 @@ func TestScannerValid(t *testing.T) {
 				{pos: 5, lit: "", tok: tokEOF},
 			},
 		},
+		{
+			input: []byte("amount >= 10"),
+			toks: []scannedTok{
+				{pos: 0, lit: "amount", tok: tokIdent},
+				{pos: 7, lit: ">=", tok: tokPunct},
+				{pos: 10, lit: "10", tok: tokInteger},
+				{pos: 12, lit: "", tok: tokEOF},
+			},
+		},
+		{
+			input: []byte("NOT a IN (1,2)"),
+			toks: []scannedTok{
+				{pos: 0, lit: "NOT", tok: tokKeyword},
+				{pos: 4, lit: "a", tok: tokIdent},
+				{pos: 6, lit: "IN", tok: tokKeyword},
+				{pos: 9, lit: "(", tok: tokPunct},
+				{pos: 10, lit: "1", tok: tokInteger},
+				{pos: 11, lit: ",", tok: tokPunct},
+				{pos: 12, lit: "2", tok: tokInteger},
+				{pos: 13, lit: ")", tok: tokPunct},
+				{pos: 14, lit: "", tok: tokEOF},
+			},
+		},
 		{
 			input: []byte("   '   hello   ' "),
 			toks: []scannedTok{
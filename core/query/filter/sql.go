@@ -237,10 +237,32 @@ func asSQL(c *sqlContext, filterExpr expr) error {
 		c.buf.WriteString(e.op.sqlOp)
 		c.buf.WriteRune(' ')
 
+		if e.op.name == "PREFIX" {
+			return prefixPatternSQL(c, e.r)
+		}
 		err = asSQL(c, e.r)
 		if err != nil {
 			return err
 		}
+	case notExpr:
+		c.buf.WriteString("NOT (")
+		err := asSQL(c, e.inner)
+		if err != nil {
+			return err
+		}
+		c.buf.WriteRune(')')
+	case listExpr:
+		c.buf.WriteRune('(')
+		for i, elem := range e.elems {
+			if i > 0 {
+				c.buf.WriteString(", ")
+			}
+			err := asSQL(c, elem)
+			if err != nil {
+				return err
+			}
+		}
+		c.buf.WriteRune(')')
 	case placeholderExpr:
 		if e.num < 1 || e.num > len(c.values) {
 			return errors.WithDetailf(ErrBadFilter, "unbound placeholder: $%d", e.num)
@@ -284,3 +306,26 @@ func asSQL(c *sqlContext, filterExpr expr) error {
 	}
 	return nil
 }
+
+// prefixPatternSQL writes a LIKE pattern that matches strings
+// beginning with the value of e. A string literal becomes a
+// constant pattern, which an index on the column built with
+// text_pattern_ops can serve; any other value is escaped in
+// SQL so that its wildcard characters match themselves.
+func prefixPatternSQL(c *sqlContext, e expr) error {
+	if v, ok := e.(valueExpr); ok && v.typ == tokString {
+		// String literals cannot contain a backslash,
+		// so only the wildcards need escaping.
+		lit := v.value[1 : len(v.value)-1]
+		lit = strings.NewReplacer("%", `\%`, "_", `\_`).Replace(lit)
+		c.buf.WriteString("'" + lit + "%'")
+		return nil
+	}
+	c.buf.WriteString(`replace(replace(replace(`)
+	err := asSQL(c, e)
+	if err != nil {
+		return err
+	}
+	c.buf.WriteString(`, '\', '\\'), '%', '\%'), '_', '\_') || '%'`)
+	return nil
+}
@@ -165,6 +165,33 @@ EXISTS(SELECT 1 FROM annotated_outputs AS out WHERE out."tx_hash" = txs."tx_hash
 EXISTS(SELECT 1 FROM annotated_inputs AS inp WHERE inp."tx_hash" = txs."tx_hash" AND (inp."a" = 'a'))
  AND (txs."ref"->>'txbankref') = '1ab'`,
 		},
+		{ // numeric comparisons
+			q:   `position >= 2 AND ref.amount < 100`,
+			tbl: transactionsSQLTable,
+			sql: `txs."position"::bigint >= 2::bigint AND (txs."ref"->>'amount')::bigint < 100::bigint`,
+		},
+		{ // IN lists
+			q:   `type IN ('issue', $1)`,
+			tbl: inputsSQLTable,
+			sql: `inp."type" IN ('issue', $1)`,
+		},
+		{ // prefix of a string literal
+			q:   `ref.invoice PREFIX 'INV_2017%'`,
+			tbl: transactionsSQLTable,
+			sql: `(txs."ref"->>'invoice') LIKE 'INV\_2017\%%'`,
+		},
+		{ // prefix of a placeholder
+			q:   `a PREFIX $1`,
+			tbl: inputsSQLTable,
+			sql: `inp."a" LIKE replace(replace(replace($1, '\', '\\'), '%', '\%'), '_', '\_') || '%'`,
+		},
+		{ // negation
+			q:   `NOT inputs(a = 'a') AND NOT (is_local)`,
+			tbl: transactionsSQLTable,
+			sql: `NOT (
+EXISTS(SELECT 1 FROM annotated_inputs AS inp WHERE inp."tx_hash" = txs."tx_hash" AND (inp."a" = 'a'))
+) AND NOT ((txs."local"))`,
+		},
 	}
 
 	values := []interface{}{"hey"}
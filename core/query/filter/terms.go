@@ -0,0 +1,72 @@
+package filter
+
+import "strconv"
+
+// EqualityTerms reports whether p is a conjunction of terms
+// of the form attr = value, where attr is an attribute of
+// the queried object and value is a literal or a placeholder,
+// with each attribute in at most one term. If so, it returns
+// the value of each attribute: a string or an int64 for a
+// literal, or the placeholder's value from vals. A query of
+// that form can be answered from indexes that a general
+// predicate cannot use.
+func EqualityTerms(p Predicate, vals []interface{}) (map[string]interface{}, bool) {
+	terms := make(map[string]interface{})
+	if p.expr == nil {
+		return terms, true
+	}
+	ok := equalityTerms(p.expr, vals, terms)
+	return terms, ok
+}
+
+func equalityTerms(e expr, vals []interface{}, terms map[string]interface{}) bool {
+	switch e := e.(type) {
+	case parenExpr:
+		return equalityTerms(e.inner, vals, terms)
+	case binaryExpr:
+		switch e.op.name {
+		case "AND":
+			return equalityTerms(e.l, vals, terms) && equalityTerms(e.r, vals, terms)
+		case "=":
+			attr, ok := e.l.(attrExpr)
+			val := e.r
+			if !ok {
+				attr, ok = e.r.(attrExpr)
+				val = e.l
+			}
+			if !ok {
+				return false
+			}
+			v, ok := termValue(val, vals)
+			if !ok {
+				return false
+			}
+			if _, dup := terms[attr.attr]; dup {
+				return false
+			}
+			terms[attr.attr] = v
+			return true
+		}
+	}
+	return false
+}
+
+func termValue(e expr, vals []interface{}) (interface{}, bool) {
+	switch e := e.(type) {
+	case parenExpr:
+		return termValue(e.inner, vals)
+	case valueExpr:
+		switch e.typ {
+		case tokString:
+			return e.value[1 : len(e.value)-1], true
+		case tokInteger:
+			n, err := strconv.ParseInt(e.value, 10, 64)
+			return n, err == nil
+		}
+	case placeholderExpr:
+		if e.num >= 1 && e.num <= len(vals) {
+			return vals[e.num-1], true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,44 @@
+package filter
+
+import (
+	"testing"
+
+	"chain/testutil"
+)
+
+func TestEqualityTerms(t *testing.T) {
+	testCases := []struct {
+		q    string
+		vals []interface{}
+		want map[string]interface{}
+		ok   bool
+	}{
+		{q: ``, want: map[string]interface{}{}, ok: true},
+		{
+			q:    `a = 'x' AND ($1 = b) AND amount = 5`,
+			vals: []interface{}{"y"},
+			want: map[string]interface{}{"a": "x", "b": "y", "amount": int64(5)},
+			ok:   true,
+		},
+		{q: `a = 'x' OR b = 'y'`},
+		{q: `a = 'x' AND a = 'y'`},
+		{q: `amount >= 5`},
+		{q: `NOT a = 'x'`},
+		{q: `account_tags.x = 'y'`},
+		{q: `a = b`},
+	}
+	for _, tc := range testCases {
+		p, err := Parse(tc.q, inputsSQLTable, tc.vals)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, ok := EqualityTerms(p, tc.vals)
+		if ok != tc.ok {
+			t.Errorf("EqualityTerms(%q) ok = %v want %v", tc.q, ok, tc.ok)
+			continue
+		}
+		if ok && !testutil.DeepEqual(got, tc.want) {
+			t.Errorf("EqualityTerms(%q) = %v want %v", tc.q, got, tc.want)
+		}
+	}
+}
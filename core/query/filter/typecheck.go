@@ -67,9 +67,12 @@ func typeCheckExpr(expr expr, tbl *SQLTable, valTypes []Type, selectorTypes map[
 		if err != nil {
 			return leftTyp, err
 		}
-		rightTyp, err := typeCheckExpr(e.r, tbl, valTypes, selectorTypes)
-		if err != nil {
-			return rightTyp, err
+		var rightTyp Type
+		if _, ok := e.r.(listExpr); !ok {
+			rightTyp, err = typeCheckExpr(e.r, tbl, valTypes, selectorTypes)
+			if err != nil {
+				return rightTyp, err
+			}
 		}
 
 		switch e.op.name {
@@ -90,37 +93,60 @@ func typeCheckExpr(expr expr, tbl *SQLTable, valTypes []Type, selectorTypes map[
 				return typ, fmt.Errorf("%s expects bool operands", e.op.name)
 			}
 			return Bool, nil
-		case "=":
-			// The = operand requires left and right types to be equal. If
-			// one of our types is known but the other is not, we need to
-			// coerce the untyped one to a matching type.
-			if !knownType(leftTyp) && knownType(rightTyp) {
-				err := setType(e.l, rightTyp, selectorTypes)
+		case "=", "<", "<=", ">", ">=":
+			err := checkComparison(e.op.name, e.l, leftTyp, e.r, rightTyp, selectorTypes)
+			if err != nil {
+				return typ, err
+			}
+			return Bool, nil
+		case "IN":
+			list := e.r.(listExpr)
+			for _, elem := range list.elems {
+				elemTyp, err := typeCheckExpr(elem, tbl, valTypes, selectorTypes)
 				if err != nil {
-					return leftTyp, err
+					return typ, err
 				}
-				leftTyp = rightTyp
-			}
-			if !knownType(rightTyp) && knownType(leftTyp) {
-				err := setType(e.r, leftTyp, selectorTypes)
+				err = checkComparison(e.op.name, e.l, leftTyp, elem, elemTyp, selectorTypes)
 				if err != nil {
-					return leftTyp, err
+					return typ, err
+				}
+				if !knownType(leftTyp) && knownType(elemTyp) {
+					leftTyp = elemTyp
 				}
-				rightTyp = leftTyp
 			}
-			if !isType(leftTyp, String) && !isType(leftTyp, Integer) {
-				return typ, fmt.Errorf("%s expects integer or string operands", e.op.name)
+			return Bool, nil
+		case "PREFIX":
+			ok, err := assertType(e.l, leftTyp, String, selectorTypes)
+			if err != nil {
+				return typ, err
 			}
-			if !isType(rightTyp, String) && !isType(rightTyp, Integer) {
-				return typ, fmt.Errorf("%s expects integer or string operands", e.op.name)
+			if !ok {
+				return typ, fmt.Errorf("%s expects string operands", e.op.name)
 			}
-			if knownType(rightTyp) && knownType(leftTyp) && leftTyp != rightTyp {
-				return typ, fmt.Errorf("%s expects operands of matching types", e.op.name)
+			ok, err = assertType(e.r, rightTyp, String, selectorTypes)
+			if err != nil {
+				return typ, err
+			}
+			if !ok {
+				return typ, fmt.Errorf("%s expects string operands", e.op.name)
 			}
 			return Bool, nil
 		default:
 			panic(fmt.Errorf("unsupported operator: %s", e.op.name))
 		}
+	case notExpr:
+		typ, err = typeCheckExpr(e.inner, tbl, valTypes, selectorTypes)
+		if err != nil {
+			return typ, err
+		}
+		ok, err := assertType(e.inner, typ, Bool, selectorTypes)
+		if err != nil {
+			return typ, err
+		}
+		if !ok {
+			return typ, errors.New("NOT expects a bool operand")
+		}
+		return Bool, nil
 	case placeholderExpr:
 		if len(valTypes) == 0 {
 			return Any, nil
@@ -183,6 +209,37 @@ func typeCheckExpr(expr expr, tbl *SQLTable, valTypes []Type, selectorTypes map[
 	}
 }
 
+// checkComparison checks the operands of a comparison,
+// which must both be integers or both be strings. If the
+// type of one operand is known but the other is not, the
+// unknown one is coerced to match.
+func checkComparison(op string, l expr, leftTyp Type, r expr, rightTyp Type, selectorTypes map[string]Type) error {
+	if !knownType(leftTyp) && knownType(rightTyp) {
+		err := setType(l, rightTyp, selectorTypes)
+		if err != nil {
+			return err
+		}
+		leftTyp = rightTyp
+	}
+	if !knownType(rightTyp) && knownType(leftTyp) {
+		err := setType(r, leftTyp, selectorTypes)
+		if err != nil {
+			return err
+		}
+		rightTyp = leftTyp
+	}
+	if !isType(leftTyp, String) && !isType(leftTyp, Integer) {
+		return fmt.Errorf("%s expects integer or string operands", op)
+	}
+	if !isType(rightTyp, String) && !isType(rightTyp, Integer) {
+		return fmt.Errorf("%s expects integer or string operands", op)
+	}
+	if knownType(rightTyp) && knownType(leftTyp) && leftTyp != rightTyp {
+		return fmt.Errorf("%s expects operands of matching types", op)
+	}
+	return nil
+}
+
 func assertType(expr expr, got, want Type, selectorTypes map[string]Type) (bool, error) {
 	if !isType(got, want) { // type does not match
 		return false, nil
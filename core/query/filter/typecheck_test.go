@@ -26,6 +26,10 @@ func TestTypeCheckInvalid(t *testing.T) {
 		{p: `position.huh`, err: errors.New("selector `.` can only be used on objects")},
 		{p: `ref.something = 'abc' OR ref.something = 123`, err: errors.New("\"ref.something\" used as both string and integer")},
 		{p: `ref.buyer.id = 'abc' OR ref.buyer = 'hello'`, err: errors.New("\"ref.buyer\" used as both object and string")},
+		{p: `position >= 'a'`, err: errors.New(">= expects operands of matching types")},
+		{p: `position IN (1, 'a')`, err: errors.New("IN expects operands of matching types")},
+		{p: `position PREFIX '1'`, err: errors.New("PREFIX expects string operands")},
+		{p: `NOT position`, err: errors.New("NOT expects a bool operand")},
 	}
 
 	for _, tc := range testCases {
@@ -62,6 +66,12 @@ func TestTypeCheckValid(t *testing.T) {
 		{p: `ref.a_boolean_field AND ref.another_boolean_field`, typ: Bool},
 		{p: `$1`, valTypes: []Type{String}, typ: String},
 		{p: `$1 = $2`, valTypes: []Type{String, String}, typ: Bool},
+		{p: `position >= 2 AND position < 10`, typ: Bool},
+		{p: `ref.amount > 100`, typ: Bool},
+		{p: `ref.state IN ('CA', 'OR', $1)`, valTypes: []Type{String}, typ: Bool},
+		{p: `ref.invoice PREFIX 'INV-'`, typ: Bool},
+		{p: `NOT ref.paid`, typ: Bool},
+		{p: `NOT inputs(account_tags.type = 'revolving')`, typ: Bool},
 	}
 
 	for _, tc := range testCases {
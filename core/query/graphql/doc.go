@@ -0,0 +1,27 @@
+/*
+Package graphql implements a read-only GraphQL interface
+to the annotated blockchain data indexed by package query.
+
+It supports the subset of GraphQL needed for flexible reads:
+a single query operation with field selection, aliases,
+arguments, and variables. Fields present in an object's
+annotated JSON can be selected directly; relationships
+such as an output's account or an account's balances
+are resolved through the existing query indexes.
+
+	{
+	    unspent_outputs(filter: "account_alias=$1", filter_params: ["alice"]) {
+	        items {
+	            amount
+	            asset { alias definition }
+	        }
+	        next
+	    }
+	}
+
+Since each relationship costs a query per object, a query's
+work is bounded: page sizes are capped, and queries nested
+too deeply or resolving too many fields are rejected with
+ErrTooComplex.
+*/
+package graphql
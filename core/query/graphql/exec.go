@@ -0,0 +1,273 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"chain/errors"
+)
+
+// Resolver computes the value of a field,
+// given the object it's selected on and its arguments.
+// The result is converted to its JSON representation
+// before subfields are selected from it.
+type Resolver func(ctx context.Context, parent map[string]interface{}, args map[string]interface{}) (interface{}, error)
+
+// FieldDef describes a field of an object type.
+type FieldDef struct {
+	// Type names the object type of the field's value
+	// (or of its elements, if the value is a list).
+	// It is empty for scalars and plain JSON objects.
+	Type string
+
+	// Resolve computes the field's value.
+	// If it is nil, the value is taken from the
+	// parent object's JSON representation.
+	Resolve Resolver
+}
+
+// ObjectType describes the fields of an object type
+// that need type information or computation.
+// Any other key in an object's JSON representation
+// can be selected as a plain field.
+type ObjectType map[string]*FieldDef
+
+const (
+	// DefaultMaxDepth is the default limit
+	// on the nesting of a query's selections.
+	DefaultMaxDepth = 6
+
+	// DefaultMaxCost is the default limit on the number
+	// of resolved fields, each typically a database query,
+	// in the execution of a query.
+	DefaultMaxCost = 1000
+)
+
+// ErrTooComplex is returned when a query is nested more
+// deeply, or resolves more fields, than the schema allows.
+var ErrTooComplex = errors.New("graphql query too complex")
+
+// Schema describes the object types available to queries.
+type Schema struct {
+	Types map[string]ObjectType
+
+	// Query names the type of the root object.
+	Query string
+
+	// MaxDepth limits the nesting of selections in a query.
+	// If 0, DefaultMaxDepth is used.
+	MaxDepth int
+
+	// MaxCost limits the number of fields with a resolver
+	// that executing a query may resolve. A query that
+	// reaches it fails without a result.
+	// If 0, DefaultMaxCost is used.
+	MaxCost int
+}
+
+// Execute parses and executes query against s,
+// substituting vars for the query's variables.
+func (s *Schema) Execute(ctx context.Context, query string, vars map[string]interface{}) (Object, error) {
+	sel, err := Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	maxDepth := s.MaxDepth
+	if maxDepth == 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	if d := depth(sel); d > maxDepth {
+		return nil, errors.WithDetailf(ErrTooComplex, "query depth %d exceeds the limit of %d", d, maxDepth)
+	}
+	e := &execution{Schema: s, vars: vars, budget: s.MaxCost}
+	if e.budget == 0 {
+		e.budget = DefaultMaxCost
+	}
+	return e.selectObject(ctx, map[string]interface{}{}, s.Query, sel)
+}
+
+// depth returns the nesting depth of sel.
+func depth(sel []*Selection) int {
+	max := 0
+	for _, f := range sel {
+		if d := depth(f.Selection); d > max {
+			max = d
+		}
+	}
+	if len(sel) == 0 {
+		return 0
+	}
+	return max + 1
+}
+
+// execution holds the state of a single execution of a query.
+type execution struct {
+	*Schema
+	vars map[string]interface{}
+
+	// budget is the number of fields with
+	// a resolver that may still be resolved.
+	budget int
+}
+
+func (e *execution) selectObject(ctx context.Context, obj map[string]interface{}, typ string, sel []*Selection) (Object, error) {
+	out := make(Object, 0, len(sel))
+	for _, f := range sel {
+		var (
+			v   interface{}
+			def = e.Types[typ][f.Name]
+		)
+		if def != nil && def.Resolve != nil {
+			if e.budget == 0 {
+				max := e.MaxCost
+				if max == 0 {
+					max = DefaultMaxCost
+				}
+				return nil, errors.WithDetailf(ErrTooComplex, "query resolves more than %d fields", max)
+			}
+			e.budget--
+			args, err := resolveArgs(f.Args, e.vars)
+			if err != nil {
+				return nil, err
+			}
+			v, err = def.Resolve(ctx, obj, args)
+			if err != nil {
+				return nil, errors.Wrapf(err, "resolving %s", f.Name)
+			}
+			v, err = toJSON(v)
+			if err != nil {
+				return nil, err
+			}
+		} else if _, ok := obj[f.Name]; ok {
+			v = obj[f.Name]
+		} else {
+			return nil, errors.WithDetailf(ErrBadQuery, "unknown field %q", f.Name)
+		}
+
+		var fieldType string
+		if def != nil {
+			fieldType = def.Type
+		}
+		v, err := e.selectValue(ctx, v, fieldType, f)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, Member{f.key(), v})
+	}
+	return out, nil
+}
+
+func (e *execution) selectValue(ctx context.Context, v interface{}, typ string, f *Selection) (interface{}, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		out := make([]interface{}, 0, len(v))
+		for _, elem := range v {
+			sv, err := e.selectValue(ctx, elem, typ, f)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, sv)
+		}
+		return out, nil
+	case map[string]interface{}:
+		if len(f.Selection) == 0 {
+			if typ != "" {
+				return nil, errors.WithDetailf(ErrBadQuery, "field %q of type %s needs a selection of subfields", f.Name, typ)
+			}
+			return v, nil // plain JSON object, such as tags
+		}
+		return e.selectObject(ctx, v, typ, f.Selection)
+	}
+	if len(f.Selection) > 0 {
+		return nil, errors.WithDetailf(ErrBadQuery, "field %q is a scalar and has no subfields", f.Name)
+	}
+	return v, nil
+}
+
+func resolveArgs(args, vars map[string]interface{}) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		v, err := resolveValue(v, vars)
+		if err != nil {
+			return nil, err
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
+func resolveValue(v interface{}, vars map[string]interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case variable:
+		val, ok := vars[string(v)]
+		if !ok {
+			return nil, errors.WithDetailf(ErrBadQuery, "undefined variable $%s", v)
+		}
+		return val, nil
+	case []interface{}:
+		out := make([]interface{}, 0, len(v))
+		for _, elem := range v {
+			elem, err := resolveValue(elem, vars)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, elem)
+		}
+		return out, nil
+	case map[string]interface{}:
+		return resolveArgs(v, vars)
+	}
+	return v, nil
+}
+
+// toJSON converts v to its generic JSON representation,
+// preserving the precision of numbers.
+func toJSON(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var out interface{}
+	err = dec.Decode(&out)
+	return out, errors.Wrap(err)
+}
+
+// Object is a query result object.
+// It marshals to JSON with its members
+// in the order they were selected.
+type Object []Member
+
+// Member is a single key-value pair in an Object.
+type Member struct {
+	Key   string
+	Value interface{}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (o Object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(m.Key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(m.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,139 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"chain/errors"
+)
+
+func testSchema() *Schema {
+	accounts := map[string]map[string]interface{}{
+		"acc1": {"id": "acc1", "alias": "alice", "tags": map[string]interface{}{"x": 1}},
+	}
+	return &Schema{
+		Query: "Query",
+		Types: map[string]ObjectType{
+			"Query": {
+				"outputs": {Type: "Output", Resolve: func(ctx context.Context, _, args map[string]interface{}) (interface{}, error) {
+					outs := []map[string]interface{}{
+						{"id": "out1", "amount": uint64(1 << 62), "account_id": "acc1"},
+						{"id": "out2", "amount": 5},
+					}
+					if n, ok := args["page_size"].(int64); ok {
+						outs = outs[:n]
+					}
+					return outs, nil
+				}},
+			},
+			"Output": {
+				"account": {Type: "Account", Resolve: func(ctx context.Context, parent, _ map[string]interface{}) (interface{}, error) {
+					id, _ := parent["account_id"].(string)
+					if acc, ok := accounts[id]; ok {
+						return acc, nil
+					}
+					return nil, nil
+				}},
+			},
+		},
+	}
+}
+
+func TestExecute(t *testing.T) {
+	cases := []struct {
+		query string
+		vars  map[string]interface{}
+		want  string
+	}{{
+		query: `{ outputs { amount id account { alias } } }`,
+		want:  `{"outputs":[{"amount":4611686018427387904,"id":"out1","account":{"alias":"alice"}},{"amount":5,"id":"out2","account":null}]}`,
+	}, {
+		query: `query($n: Int) { o: outputs(page_size: $n) { acct: account { tags } } }`,
+		vars:  map[string]interface{}{"n": int64(1)},
+		want:  `{"o":[{"acct":{"tags":{"x":1}}}]}`,
+	}}
+
+	for _, c := range cases {
+		got, err := testSchema().Execute(context.Background(), c.query, c.vars)
+		if err != nil {
+			t.Errorf("Execute(%q) error %s", c.query, err)
+			continue
+		}
+		b, err := json.Marshal(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != c.want {
+			t.Errorf("Execute(%q) = %s want %s", c.query, b, c.want)
+		}
+	}
+}
+
+func TestExecuteErrors(t *testing.T) {
+	cases := []string{
+		`{ nonexistent }`,
+		`{ outputs { nonexistent } }`,
+		`{ outputs }`,                  // object type needs subfields
+		`{ outputs { amount { x } } }`, // scalar has no subfields
+		`{ outputs(page_size: $undefined) { id } }`,
+	}
+	for _, q := range cases {
+		_, err := testSchema().Execute(context.Background(), q, nil)
+		if errors.Root(err) != ErrBadQuery {
+			t.Errorf("Execute(%q) error = %v want %v", q, err, ErrBadQuery)
+		}
+	}
+}
+
+func TestExecuteLimits(t *testing.T) {
+	s := testSchema()
+	s.MaxDepth = 2
+	_, err := s.Execute(context.Background(), `{ outputs { account { alias } } }`, nil)
+	if errors.Root(err) != ErrTooComplex {
+		t.Errorf("depth 3 error = %v want %v", err, ErrTooComplex)
+	}
+	_, err = s.Execute(context.Background(), `{ outputs { id } }`, nil)
+	if err != nil {
+		t.Errorf("depth 2 error = %v", err)
+	}
+
+	// Resolving outputs, then the account of each of
+	// the two outputs, takes three resolver calls.
+	s = testSchema()
+	s.MaxCost = 2
+	_, err = s.Execute(context.Background(), `{ outputs { account { alias } } }`, nil)
+	if errors.Root(err) != ErrTooComplex {
+		t.Errorf("cost 3 error = %v want %v", err, ErrTooComplex)
+	}
+	s.MaxCost = 3
+	_, err = s.Execute(context.Background(), `{ outputs { account { alias } } }`, nil)
+	if err != nil {
+		t.Errorf("cost 3 error = %v", err)
+	}
+}
+
+func TestPageArgs(t *testing.T) {
+	r := &resolver{maxPageSize: 500}
+	cases := []struct {
+		args    map[string]interface{}
+		want    int
+		wantErr bool
+	}{
+		{map[string]interface{}{}, defPageSize, false},
+		{map[string]interface{}{"page_size": int64(10)}, 10, false},
+		{map[string]interface{}{"page_size": int64(1 << 40)}, 500, false},
+		{map[string]interface{}{"page_size": int64(0)}, 0, true},
+		{map[string]interface{}{"page_size": int64(-1)}, 0, true},
+	}
+	for _, c := range cases {
+		_, _, _, got, err := r.pageArgs(c.args)
+		if (err != nil) != c.wantErr {
+			t.Errorf("pageArgs(%v) err = %v, want error %v", c.args, err, c.wantErr)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("pageArgs(%v) = %d want %d", c.args, got, c.want)
+		}
+	}
+}
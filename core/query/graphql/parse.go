@@ -0,0 +1,322 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"chain/errors"
+)
+
+// ErrBadQuery is returned when a GraphQL query
+// cannot be parsed or executed.
+var ErrBadQuery = errors.New("invalid graphql query")
+
+// Selection is a field selected in a query,
+// along with its arguments and subselections.
+type Selection struct {
+	Alias     string
+	Name      string
+	Args      map[string]interface{}
+	Selection []*Selection
+}
+
+// key returns the name under which s
+// appears in the result.
+func (s *Selection) key() string {
+	if s.Alias != "" {
+		return s.Alias
+	}
+	return s.Name
+}
+
+// variable is an argument value that refers to
+// a query variable, to be resolved at execution time.
+type variable string
+
+// Parse parses a GraphQL query document
+// and returns its top-level selection set.
+//
+// Only the query operation is supported,
+// and only a single operation per document.
+// Fragments and directives are not supported.
+func Parse(query string) (sel []*Selection, err error) {
+	defer func() {
+		r := recover()
+		if perr, ok := r.(parseError); ok {
+			err = errors.WithDetail(ErrBadQuery, perr.Error())
+		} else if r != nil {
+			panic(r)
+		}
+	}()
+
+	p := &parser{src: query}
+	p.next()
+	if p.tok == tokName && p.lit == "query" {
+		p.next()
+		if p.tok == tokName {
+			p.next() // operation name; unused
+		}
+		if p.tok == tokPunct && p.lit == "(" {
+			p.skipVariableDefs()
+		}
+	}
+	sel = p.parseSelectionSet()
+	p.expect(tokEOF, "")
+	return sel, nil
+}
+
+type parseError struct {
+	pos int
+	msg string
+}
+
+func (err parseError) Error() string {
+	return fmt.Sprintf("col %d: %s", err.pos, err.msg)
+}
+
+type token int
+
+const (
+	tokEOF token = iota
+	tokName
+	tokInt
+	tokFloat
+	tokString
+	tokPunct
+)
+
+func (t token) String() string {
+	switch t {
+	case tokEOF:
+		return "end of query"
+	case tokName:
+		return "name"
+	case tokInt:
+		return "integer"
+	case tokFloat:
+		return "float"
+	case tokString:
+		return "string"
+	case tokPunct:
+		return "punctuation"
+	}
+	return "unknown token"
+}
+
+type parser struct {
+	src    string
+	offset int
+
+	// current token
+	pos int
+	tok token
+	lit string
+}
+
+func (p *parser) errorf(format string, args ...interface{}) {
+	panic(parseError{pos: p.pos, msg: fmt.Sprintf(format, args...)})
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// next advances to the next token.
+// Commas are insignificant in GraphQL
+// and are skipped along with whitespace and comments.
+func (p *parser) next() {
+	for p.offset < len(p.src) {
+		c := p.src[p.offset]
+		if c == '#' {
+			for p.offset < len(p.src) && p.src[p.offset] != '\n' {
+				p.offset++
+			}
+			continue
+		}
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' && c != ',' {
+			break
+		}
+		p.offset++
+	}
+	p.pos = p.offset
+	if p.offset >= len(p.src) {
+		p.tok, p.lit = tokEOF, ""
+		return
+	}
+
+	c := p.src[p.offset]
+	switch {
+	case isNameStart(c):
+		for p.offset < len(p.src) && (isNameStart(p.src[p.offset]) || isDigit(p.src[p.offset])) {
+			p.offset++
+		}
+		p.tok = tokName
+	case c == '-' || isDigit(c):
+		p.offset++
+		p.tok = tokInt
+		for p.offset < len(p.src) {
+			c := p.src[p.offset]
+			if c == '.' || c == 'e' || c == 'E' || c == '+' || (c == '-' && p.tok == tokFloat) {
+				p.tok = tokFloat
+			} else if !isDigit(c) {
+				break
+			}
+			p.offset++
+		}
+	case c == '"':
+		p.offset++
+		for p.offset < len(p.src) && p.src[p.offset] != '"' {
+			if p.src[p.offset] == '\\' {
+				p.offset++
+			}
+			p.offset++
+		}
+		if p.offset >= len(p.src) {
+			p.errorf("unterminated string")
+		}
+		p.offset++
+		p.tok = tokString
+	case strings.IndexByte("{}()[]:$!=", c) >= 0:
+		p.offset++
+		p.tok = tokPunct
+	default:
+		p.errorf("unexpected character %q", c)
+	}
+	p.lit = p.src[p.pos:p.offset]
+}
+
+// expect consumes the current token
+// if it has type tok and, if lit is nonempty, literal lit.
+// Otherwise it reports an error.
+func (p *parser) expect(tok token, lit string) string {
+	if p.tok != tok || (lit != "" && p.lit != lit) {
+		want := tok.String()
+		if lit != "" {
+			want = strconv.Quote(lit)
+		}
+		p.errorf("got %s %q, want %s", p.tok, p.lit, want)
+	}
+	s := p.lit
+	p.next()
+	return s
+}
+
+func (p *parser) isPunct(lit string) bool {
+	return p.tok == tokPunct && p.lit == lit
+}
+
+// skipVariableDefs skips over an operation's variable
+// definitions. Variables are untyped at execution time,
+// so their declared types are not needed.
+func (p *parser) skipVariableDefs() {
+	p.expect(tokPunct, "(")
+	for !p.isPunct(")") {
+		if p.tok == tokEOF {
+			p.errorf("unterminated variable definitions")
+		}
+		p.next()
+	}
+	p.next()
+}
+
+func (p *parser) parseSelectionSet() []*Selection {
+	p.expect(tokPunct, "{")
+	var sel []*Selection
+	for !p.isPunct("}") {
+		sel = append(sel, p.parseField())
+	}
+	p.next()
+	if len(sel) == 0 {
+		p.errorf("empty selection set")
+	}
+	return sel
+}
+
+func (p *parser) parseField() *Selection {
+	s := &Selection{Name: p.expect(tokName, "")}
+	if p.isPunct(":") {
+		p.next()
+		s.Alias = s.Name
+		s.Name = p.expect(tokName, "")
+	}
+	if p.isPunct("(") {
+		p.next()
+		s.Args = make(map[string]interface{})
+		for !p.isPunct(")") {
+			name := p.expect(tokName, "")
+			p.expect(tokPunct, ":")
+			s.Args[name] = p.parseValue()
+		}
+		p.next()
+	}
+	if p.isPunct("{") {
+		s.Selection = p.parseSelectionSet()
+	}
+	return s
+}
+
+func (p *parser) parseValue() interface{} {
+	switch {
+	case p.isPunct("$"):
+		p.next()
+		return variable(p.expect(tokName, ""))
+	case p.isPunct("["):
+		p.next()
+		list := []interface{}{}
+		for !p.isPunct("]") {
+			list = append(list, p.parseValue())
+		}
+		p.next()
+		return list
+	case p.isPunct("{"):
+		p.next()
+		obj := make(map[string]interface{})
+		for !p.isPunct("}") {
+			name := p.expect(tokName, "")
+			p.expect(tokPunct, ":")
+			obj[name] = p.parseValue()
+		}
+		p.next()
+		return obj
+	case p.tok == tokInt:
+		n, err := strconv.ParseInt(p.lit, 10, 64)
+		if err != nil {
+			p.errorf("bad integer %q", p.lit)
+		}
+		p.next()
+		return n
+	case p.tok == tokFloat:
+		f, err := strconv.ParseFloat(p.lit, 64)
+		if err != nil {
+			p.errorf("bad float %q", p.lit)
+		}
+		p.next()
+		return f
+	case p.tok == tokString:
+		s, err := strconv.Unquote(p.lit)
+		if err != nil {
+			p.errorf("bad string %s", p.lit)
+		}
+		p.next()
+		return s
+	case p.tok == tokName:
+		lit := p.lit
+		p.next()
+		switch lit {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		}
+		return lit // enum value
+	}
+	p.errorf("unexpected %s %q", p.tok, p.lit)
+	return nil
+}
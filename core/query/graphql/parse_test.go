@@ -0,0 +1,83 @@
+package graphql
+
+import (
+	"reflect"
+	"testing"
+
+	"chain/errors"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		query string
+		want  []*Selection
+	}{{
+		query: `{ accounts { items { id } } }`,
+		want: []*Selection{{
+			Name: "accounts",
+			Selection: []*Selection{{
+				Name:      "items",
+				Selection: []*Selection{{Name: "id"}},
+			}},
+		}},
+	}, {
+		query: `query Balances($alias: String!) {
+			# comments and commas are ignored
+			mine: balances(filter: "account_alias=$1", filter_params: [$alias], sum_by: ["asset_id"], timestamp: 12) {
+				amount, sum_by
+			}
+		}`,
+		want: []*Selection{{
+			Alias: "mine",
+			Name:  "balances",
+			Args: map[string]interface{}{
+				"filter":        "account_alias=$1",
+				"filter_params": []interface{}{variable("alias")},
+				"sum_by":        []interface{}{"asset_id"},
+				"timestamp":     int64(12),
+			},
+			Selection: []*Selection{{Name: "amount"}, {Name: "sum_by"}},
+		}},
+	}, {
+		query: `{ x(a: true, b: null, c: -1.5, d: {e: "f"}) }`,
+		want: []*Selection{{
+			Name: "x",
+			Args: map[string]interface{}{
+				"a": true,
+				"b": nil,
+				"c": -1.5,
+				"d": map[string]interface{}{"e": "f"},
+			},
+		}},
+	}}
+
+	for _, c := range cases {
+		got, err := Parse(c.query)
+		if err != nil {
+			t.Errorf("Parse(%q) error %s", c.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Parse(%q) = %v want %v", c.query, got, c.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []string{
+		``,
+		`{}`,
+		`{ accounts `,
+		`{ accounts(page_size 1) { id } }`,
+		`{ accounts(filter: "unterminated) { id } }`,
+		`mutation { reset }`,
+		`{ a } { b }`,
+		`{ a @include }`,
+	}
+	for _, q := range cases {
+		_, err := Parse(q)
+		if errors.Root(err) != ErrBadQuery {
+			t.Errorf("Parse(%q) error = %v want %v", q, err, ErrBadQuery)
+		}
+	}
+}
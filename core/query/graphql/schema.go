@@ -0,0 +1,341 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	"chain/core/query"
+	"chain/core/query/filter"
+	"chain/errors"
+)
+
+const (
+	defPageSize    = 100
+	defMaxPageSize = 1000
+)
+
+// NewSchema returns a schema exposing the annotated
+// accounts, assets, transactions, outputs and balances
+// indexed by ind.
+//
+// The root list fields take the same arguments as the
+// corresponding list endpoints (filter, filter_params,
+// page_size, after, and so on) and return a page with
+// fields items, next, and last_page. Inputs and outputs
+// can traverse to their account and asset, outputs to their
+// transaction, and accounts and assets to their balances.
+//
+// A page_size greater than maxPageSize is reduced to it.
+// If maxPageSize is 0, it is 1000, the default maximum
+// of the list endpoints.
+func NewSchema(ind *query.Indexer, maxPageSize int) *Schema {
+	if maxPageSize == 0 {
+		maxPageSize = defMaxPageSize
+	}
+	r := &resolver{ind: ind, maxPageSize: maxPageSize}
+	return &Schema{
+		Query: "Query",
+		Types: map[string]ObjectType{
+			"Query": {
+				"accounts":        {Type: "AccountPage", Resolve: r.accounts},
+				"assets":          {Type: "AssetPage", Resolve: r.assets},
+				"transactions":    {Type: "TransactionPage", Resolve: r.transactions},
+				"unspent_outputs": {Type: "OutputPage", Resolve: r.unspentOutputs},
+				"balances":        {Resolve: r.balances},
+			},
+			"AccountPage":     {"items": {Type: "Account"}},
+			"AssetPage":       {"items": {Type: "Asset"}},
+			"TransactionPage": {"items": {Type: "Transaction"}},
+			"OutputPage":      {"items": {Type: "Output"}},
+			"Account": {
+				"balances":        {Resolve: r.related(r.balances, "account_id", "id", "asset_alias", "asset_id")},
+				"unspent_outputs": {Type: "OutputPage", Resolve: r.related(r.unspentOutputs, "account_id", "id")},
+			},
+			"Asset": {
+				"balances":        {Resolve: r.related(r.balances, "asset_id", "id", "account_alias", "account_id")},
+				"unspent_outputs": {Type: "OutputPage", Resolve: r.related(r.unspentOutputs, "asset_id", "id")},
+			},
+			"Transaction": {
+				"inputs":  {Type: "Input"},
+				"outputs": {Type: "Output"},
+			},
+			"Input": {
+				"account": {Type: "Account", Resolve: r.account},
+				"asset":   {Type: "Asset", Resolve: r.asset},
+			},
+			"Output": {
+				"account":     {Type: "Account", Resolve: r.account},
+				"asset":       {Type: "Asset", Resolve: r.asset},
+				"transaction": {Type: "Transaction", Resolve: r.transaction},
+			},
+		},
+	}
+}
+
+type resolver struct {
+	ind         *query.Indexer
+	maxPageSize int
+}
+
+type page struct {
+	Items    interface{} `json:"items"`
+	Next     string      `json:"next"`
+	LastPage bool        `json:"last_page"`
+}
+
+func (r *resolver) accounts(ctx context.Context, _, args map[string]interface{}) (interface{}, error) {
+	filt, vals, after, limit, err := r.pageArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	accounts, next, err := r.ind.Accounts(ctx, filt, vals, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	return page{accounts, next, len(accounts) < limit}, nil
+}
+
+func (r *resolver) assets(ctx context.Context, _, args map[string]interface{}) (interface{}, error) {
+	filt, vals, after, limit, err := r.pageArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	assets, next, err := r.ind.Assets(ctx, filt, vals, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	return page{assets, next, len(assets) < limit}, nil
+}
+
+func (r *resolver) transactions(ctx context.Context, _, args map[string]interface{}) (interface{}, error) {
+	filt, vals, afterStr, limit, err := r.pageArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	startTimeMS, err := uintArg(args, "start_time", 0)
+	if err != nil {
+		return nil, err
+	}
+	endTimeMS, err := uintArg(args, "end_time", math.MaxInt64)
+	if err != nil {
+		return nil, err
+	}
+
+	var after query.TxAfter
+	if afterStr != "" {
+		after, err = query.DecodeTxAfter(afterStr)
+	} else {
+		after, err = r.ind.LookupTxAfter(ctx, startTimeMS, endTimeMS)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	txs, next, err := r.ind.Transactions(ctx, filt, vals, after, limit, false)
+	if err != nil {
+		return nil, err
+	}
+	return page{txs, next.String(), len(txs) < limit}, nil
+}
+
+func (r *resolver) unspentOutputs(ctx context.Context, _, args map[string]interface{}) (interface{}, error) {
+	filt, vals, afterStr, limit, err := r.pageArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	timestampMS, err := uintArg(args, "timestamp", math.MaxInt64)
+	if err != nil {
+		return nil, err
+	}
+
+	var after *query.OutputsAfter
+	if afterStr != "" {
+		after, err = query.DecodeOutputsAfter(afterStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	outputs, next, err := r.ind.Outputs(ctx, filt, vals, timestampMS, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	return page{outputs, next.String(), len(outputs) < limit}, nil
+}
+
+func (r *resolver) balances(ctx context.Context, _, args map[string]interface{}) (interface{}, error) {
+	filt, vals, err := filterArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	timestampMS, err := uintArg(args, "timestamp", math.MaxInt64)
+	if err != nil {
+		return nil, err
+	}
+
+	sumByStrs := []interface{}{"asset_alias", "asset_id"}
+	if v, ok := args["sum_by"]; ok {
+		sumByStrs, ok = v.([]interface{})
+		if !ok {
+			return nil, errors.WithDetail(ErrBadQuery, "sum_by must be a list")
+		}
+	}
+	var sumBy []filter.Field
+	for _, s := range sumByStrs {
+		str, ok := s.(string)
+		if !ok {
+			return nil, errors.WithDetail(ErrBadQuery, "sum_by must be a list of strings")
+		}
+		f, err := filter.ParseField(str)
+		if err != nil {
+			return nil, err
+		}
+		sumBy = append(sumBy, f)
+	}
+
+	return r.ind.Balances(ctx, filt, vals, sumBy, timestampMS)
+}
+
+// related returns a resolver that calls f with its filter
+// restricted to objects whose field equals the parent's key,
+// summing balances by sumBy if no sum_by argument is given.
+func (r *resolver) related(f Resolver, field, key string, sumBy ...string) Resolver {
+	return func(ctx context.Context, parent, args map[string]interface{}) (interface{}, error) {
+		filt, vals, err := filterArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		cond := fmt.Sprintf("%s=$%d", field, len(vals)+1)
+		if filt != "" {
+			filt = "(" + filt + ") AND " + cond
+		} else {
+			filt = cond
+		}
+
+		newArgs := make(map[string]interface{}, len(args)+3)
+		for k, v := range args {
+			newArgs[k] = v
+		}
+		newArgs["filter"] = filt
+		newArgs["filter_params"] = append(append([]interface{}{}, vals...), parent[key])
+		if _, ok := args["sum_by"]; !ok && len(sumBy) > 0 {
+			var l []interface{}
+			for _, s := range sumBy {
+				l = append(l, s)
+			}
+			newArgs["sum_by"] = l
+		}
+		return f(ctx, parent, newArgs)
+	}
+}
+
+func (r *resolver) account(ctx context.Context, parent, _ map[string]interface{}) (interface{}, error) {
+	id, _ := parent["account_id"].(string)
+	if id == "" {
+		return nil, nil
+	}
+	accounts, _, err := r.ind.Accounts(ctx, "id=$1", []interface{}{id}, "", 1)
+	if err != nil || len(accounts) == 0 {
+		return nil, err
+	}
+	return accounts[0], nil
+}
+
+func (r *resolver) asset(ctx context.Context, parent, _ map[string]interface{}) (interface{}, error) {
+	id, _ := parent["asset_id"].(string)
+	if id == "" {
+		return nil, nil
+	}
+	assets, _, err := r.ind.Assets(ctx, "id=$1", []interface{}{id}, "", 1)
+	if err != nil || len(assets) == 0 {
+		return nil, err
+	}
+	return assets[0], nil
+}
+
+func (r *resolver) transaction(ctx context.Context, parent, _ map[string]interface{}) (interface{}, error) {
+	id, _ := parent["transaction_id"].(string)
+	if id == "" {
+		return nil, nil
+	}
+	after, err := r.ind.LookupTxAfter(ctx, 0, math.MaxInt64)
+	if err != nil {
+		return nil, err
+	}
+	txs, _, err := r.ind.Transactions(ctx, "id=$1", []interface{}{id}, after, 1, false)
+	if err != nil || len(txs) == 0 {
+		return nil, err
+	}
+	return txs[0], nil
+}
+
+func filterArgs(args map[string]interface{}) (filt string, vals []interface{}, err error) {
+	if v, ok := args["filter"]; ok {
+		filt, ok = v.(string)
+		if !ok {
+			return "", nil, errors.WithDetail(ErrBadQuery, "filter must be a string")
+		}
+	}
+	if v, ok := args["filter_params"]; ok {
+		vals, ok = v.([]interface{})
+		if !ok {
+			return "", nil, errors.WithDetail(ErrBadQuery, "filter_params must be a list")
+		}
+	}
+	return filt, vals, nil
+}
+
+func (r *resolver) pageArgs(args map[string]interface{}) (filt string, vals []interface{}, after string, limit int, err error) {
+	filt, vals, err = filterArgs(args)
+	if err != nil {
+		return "", nil, "", 0, err
+	}
+	if v, ok := args["after"]; ok {
+		after, ok = v.(string)
+		if !ok {
+			return "", nil, "", 0, errors.WithDetail(ErrBadQuery, "after must be a string")
+		}
+	}
+	n, err := uintArg(args, "page_size", defPageSize)
+	if err != nil {
+		return "", nil, "", 0, err
+	}
+	if n == 0 {
+		return "", nil, "", 0, errors.WithDetail(ErrBadQuery, "page_size out of range")
+	}
+	if n > uint64(r.maxPageSize) {
+		n = uint64(r.maxPageSize)
+	}
+	return filt, vals, after, int(n), nil
+}
+
+// uintArg returns the nonnegative integer argument name,
+// or def if it is absent.
+// Integers may be query literals or JSON numbers from variables.
+func uintArg(args map[string]interface{}, name string, def uint64) (uint64, error) {
+	v, ok := args[name]
+	if !ok || v == nil {
+		return def, nil
+	}
+	var (
+		n   int64
+		err error
+	)
+	switch v := v.(type) {
+	case int64:
+		n = v
+	case json.Number:
+		n, err = v.Int64()
+	case string:
+		n, err = strconv.ParseInt(v, 10, 64)
+	default:
+		err = fmt.Errorf("%T", v)
+	}
+	if err != nil || n < 0 {
+		return 0, errors.WithDetailf(ErrBadQuery, "%s must be a nonnegative integer", name)
+	}
+	return uint64(n), nil
+}
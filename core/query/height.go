@@ -0,0 +1,24 @@
+package query
+
+import (
+	"context"
+
+	"chain/errors"
+)
+
+// ErrHeightNotIndexed is returned when a query is pinned to a block
+// height that this core has not yet indexed. Another core, or this
+// one a little later, may be able to answer it.
+var ErrHeightNotIndexed = errors.New("block height not yet indexed")
+
+// BlockTimestamp returns the timestamp, in milliseconds, of the
+// block at the given height. It returns ErrHeightNotIndexed
+// if the indexer has not yet finished indexing that block.
+func (ind *Indexer) BlockTimestamp(ctx context.Context, height uint64) (uint64, error) {
+	if indexed := ind.pinStore.Height(TxPinName); height > indexed {
+		return 0, errors.WithDetailf(ErrHeightNotIndexed, "block %d is not yet indexed; this core has indexed through block %d", height, indexed)
+	}
+	var ts uint64
+	err := ind.db.QueryRow(ctx, ind.sql(blockTimestampRoutine), height).Scan(&ts)
+	return ts, errors.Wrap(err, "querying block timestamp")
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"sync"
 
 	"github.com/lib/pq"
 
@@ -24,6 +25,7 @@ const (
 func NewIndexer(db pg.DB, c *protocol.Chain, pinStore *pin.Store) *Indexer {
 	indexer := &Indexer{
 		db:       db,
+		readDB:   db,
 		c:        c,
 		pinStore: pinStore,
 	}
@@ -33,9 +35,15 @@ func NewIndexer(db pg.DB, c *protocol.Chain, pinStore *pin.Store) *Indexer {
 // Indexer creates, updates and queries against indexes.
 type Indexer struct {
 	db         pg.DB
+	readDB     pg.DB // set by UseReadDB
 	c          *protocol.Chain
 	pinStore   *pin.Store
 	annotators []Annotator
+	functions  map[*routine]bool // set by UseQueryFunctions
+
+	// indexMu is held while a block is indexed, so that
+	// an index rebuild can finish without missing any.
+	indexMu sync.Mutex
 }
 
 // Annotator describes a function capable of adding annotations
@@ -48,6 +56,17 @@ func (ind *Indexer) RegisterAnnotator(annotator Annotator) {
 	ind.annotators = append(ind.annotators, annotator)
 }
 
+// UseReadDB makes the indexer answer queries for transactions,
+// outputs, balances, accounts, and assets from db, typically a
+// sql.ReadDB that reads from a replica. Results may then omit
+// the most recently indexed blocks, so callers pinning a query
+// to a block height should pass a context from sql.ReadPrimary.
+// Indexing still uses the database the indexer was created with.
+// It must be called before the indexer is used.
+func (ind *Indexer) UseReadDB(db pg.DB) {
+	ind.readDB = db
+}
+
 func (ind *Indexer) ProcessBlocks(ctx context.Context) {
 	if ind.pinStore == nil {
 		return
@@ -61,10 +80,24 @@ func (ind *Indexer) IndexTransactions(ctx context.Context, b *bc.Block) error {
 	<-ind.pinStore.PinWaiter("asset", b.Height)
 	<-ind.pinStore.PinWaiter("account", b.Height)
 
+	ind.indexMu.Lock()
+	defer ind.indexMu.Unlock()
+
 	err := ind.insertBlock(ctx, b)
 	if err != nil {
 		return err
 	}
+	err = ind.updateAssetLineages(ctx, b)
+	if err != nil {
+		return err
+	}
+	return ind.indexBlock(ctx, b)
+}
+
+// indexBlock saves the annotated transactions, inputs,
+// and outputs of b, and the account balances they change.
+// These are the tables an index rebuild replaces.
+func (ind *Indexer) indexBlock(ctx context.Context, b *bc.Block) error {
 	txs, err := ind.insertAnnotatedTxs(ctx, b)
 	if err != nil {
 		return err
@@ -74,7 +107,10 @@ func (ind *Indexer) IndexTransactions(ctx context.Context, b *bc.Block) error {
 		return err
 	}
 	err = ind.insertAnnotatedInputs(ctx, b, txs)
-	return err
+	if err != nil {
+		return err
+	}
+	return ind.insertBalances(ctx, b)
 }
 
 func (ind *Indexer) insertBlock(ctx context.Context, b *bc.Block) error {
@@ -216,6 +252,7 @@ func (ind *Indexer) insertAnnotatedOutputs(ctx context.Context, b *bc.Block, ann
 		outputAssetDefinitions pq.StringArray
 		outputAssetTags        pq.StringArray
 		outputAssetLocals      pq.BoolArray
+		outputAssetLineageIDs  pq.ByteaArray
 		outputAmounts          pq.Int64Array
 		outputAccountIDs       []sql.NullString
 		outputAccountAliases   []sql.NullString
@@ -244,6 +281,7 @@ func (ind *Indexer) insertAnnotatedOutputs(ctx context.Context, b *bc.Block, ann
 			outputAssetDefinitions = append(outputAssetDefinitions, string(*out.AssetDefinition))
 			outputAssetTags = append(outputAssetTags, string(*out.AssetTags))
 			outputAssetLocals = append(outputAssetLocals, bool(out.AssetIsLocal))
+			outputAssetLineageIDs = append(outputAssetLineageIDs, out.AssetID[:])
 			outputAmounts = append(outputAmounts, int64(out.Amount))
 			outputAccountIDs = append(outputAccountIDs, sql.NullString{String: out.AccountID, Valid: out.AccountID != ""})
 			outputAccountAliases = append(outputAccountAliases, sql.NullString{String: out.AccountAlias, Valid: out.AccountAlias != ""})
@@ -258,43 +296,53 @@ func (ind *Indexer) insertAnnotatedOutputs(ctx context.Context, b *bc.Block, ann
 		}
 	}
 
+	roots, err := assetLineageRoots(ctx, ind.db, outputAssetIDs)
+	if err != nil {
+		return err
+	}
+	for i, id := range outputAssetIDs {
+		var assetID bc.AssetID
+		copy(assetID[:], id)
+		if root, ok := roots[assetID]; ok {
+			outputAssetLineageIDs[i] = root[:]
+		}
+	}
+
 	// Insert all of the block's outputs at once.
 	const insertQ = `
 		WITH utxos AS (
 			SELECT * FROM unnest($2::integer[], $3::integer[], $4::bytea[], $6::bytea[], $7::text[], $8::text[],
 				$9::bytea[], $10::text[], $11::jsonb[], $12::jsonb[], $13::boolean[], $14::bigint[],
-				$15::text[], $16::text[], $17::jsonb[], $18::bytea[], $19::jsonb[], $20::boolean[])
+				$15::text[], $16::text[], $17::jsonb[], $18::bytea[], $19::jsonb[], $20::boolean[],
+				$21::bytea[])
 			AS t(tx_pos, output_index, tx_hash, output_id, type, purpose,
 				asset_id, asset_alias, asset_definition, asset_tags, asset_local, amount,
-				account_id, account_alias, account_tags, control_program, reference_data, local)
+				account_id, account_alias, account_tags, control_program, reference_data, local,
+				asset_lineage_id)
 		)
 		INSERT INTO annotated_outputs (block_height, tx_pos, output_index, tx_hash,
 			timespan, output_id, type, purpose, asset_id, asset_alias, asset_definition,
 			asset_tags, asset_local, amount, account_id, account_alias, account_tags,
-			control_program, reference_data, local)
+			control_program, reference_data, local, asset_lineage_id)
 		SELECT $1, tx_pos, output_index, tx_hash,
 		CASE WHEN type='retire' THEN int8range($5, $5) ELSE int8range($5, NULL) END,
 		output_id, type, purpose, asset_id, asset_alias, asset_definition, asset_tags,
 		asset_local, amount, account_id, account_alias, account_tags, control_program,
-		reference_data, local
+		reference_data, local, asset_lineage_id
 		FROM utxos
 		ON CONFLICT (block_height, tx_pos, output_index) DO NOTHING;
 	`
-	_, err := ind.db.Exec(ctx, insertQ, b.Height, outputTxPositions,
+	_, err = ind.db.Exec(ctx, insertQ, b.Height, outputTxPositions,
 		outputIndexes, outputTxHashes, b.TimestampMS, outputIDs, outputTypes,
 		outputPurposes, outputAssetIDs, outputAssetAliases,
 		outputAssetDefinitions, outputAssetTags, outputAssetLocals,
 		outputAmounts, pq.Array(outputAccountIDs), pq.Array(outputAccountAliases),
 		pq.Array(outputAccountTags), outputControlPrograms, outputReferenceDatas,
-		outputLocals)
+		outputLocals, outputAssetLineageIDs)
 	if err != nil {
 		return errors.Wrap(err, "batch inserting annotated outputs")
 	}
 
-	const updateQ = `
-		UPDATE annotated_outputs SET timespan = INT8RANGE(LOWER(timespan), $1)
-		WHERE (output_id) IN (SELECT unnest($2::bytea[]))
-	`
-	_, err = ind.db.Exec(ctx, updateQ, b.TimestampMS, prevoutIDs)
+	_, err = ind.db.Exec(ctx, ind.sql(spendOutputsRoutine), b.TimestampMS, prevoutIDs)
 	return errors.Wrap(err, "updating spent annotated outputs")
 }
@@ -0,0 +1,76 @@
+package query
+
+import (
+	"context"
+
+	"github.com/lib/pq"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// assetLineageRoots returns, for each of the given assets that
+// succeeds another asset, the original asset of its lineage.
+// Assets with no predecessor are omitted from the result.
+// The successions themselves are recorded by the asset
+// block processor, which runs ahead of the tx indexer.
+func assetLineageRoots(ctx context.Context, db pg.DB, assetIDs pq.ByteaArray) (map[bc.AssetID]bc.AssetID, error) {
+	const q = `
+		WITH RECURSIVE up (asset_id, root) AS (
+			SELECT s.successor_id, s.predecessor_id FROM asset_successions s
+			WHERE s.successor_id = ANY($1::bytea[])
+			UNION
+			SELECT up.asset_id, s.predecessor_id FROM up
+			JOIN asset_successions s ON s.successor_id = up.root
+		)
+		SELECT asset_id, root FROM up
+		WHERE NOT EXISTS (SELECT 1 FROM asset_successions WHERE successor_id = up.root)
+	`
+	roots := make(map[bc.AssetID]bc.AssetID)
+	err := pg.ForQueryRows(ctx, db, q, assetIDs, func(assetID, root bc.AssetID) {
+		roots[assetID] = root
+	})
+	return roots, errors.Wrap(err, "querying asset lineage roots")
+}
+
+// updateAssetLineages moves outputs already indexed under an asset
+// that gained a predecessor in block b into the predecessor's lineage,
+// so that balances can be summed across the whole lineage.
+func (ind *Indexer) updateAssetLineages(ctx context.Context, b *bc.Block) error {
+	const q = `
+		SELECT successor_id FROM asset_successions WHERE block_height = $1
+	`
+	var successors pq.ByteaArray
+	err := pg.ForQueryRows(ctx, ind.db, q, b.Height, func(id []byte) {
+		successors = append(successors, id)
+	})
+	if err != nil {
+		return errors.Wrap(err, "querying new asset successions")
+	}
+	if len(successors) == 0 {
+		return nil
+	}
+
+	roots, err := assetLineageRoots(ctx, ind.db, successors)
+	if err != nil {
+		return err
+	}
+	var from, to pq.ByteaArray
+	for successor, root := range roots {
+		successor, root := successor, root
+		from = append(from, successor[:])
+		to = append(to, root[:])
+	}
+
+	// An asset only gains a predecessor while it still
+	// heads its own lineage, so its outputs (and those of
+	// any of its own successors) carry its ID.
+	const updateQ = `
+		UPDATE annotated_outputs SET asset_lineage_id = l.root
+		FROM (SELECT unnest($1::bytea[]) AS asset_id, unnest($2::bytea[]) AS root) l
+		WHERE annotated_outputs.asset_lineage_id = l.asset_id
+	`
+	_, err = ind.db.Exec(ctx, updateQ, from, to)
+	return errors.Wrap(err, "updating annotated output asset lineages")
+}
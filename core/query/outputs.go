@@ -9,6 +9,8 @@ import (
 	"github.com/lib/pq"
 
 	"chain/core/query/filter"
+	"chain/database/pg"
+	"chain/database/sql"
 	"chain/errors"
 	"chain/protocol/bc"
 )
@@ -19,6 +21,10 @@ var defaultOutputsAfter = OutputsAfter{
 	lastIndex:       math.MaxUint32,
 }
 
+// OutputsAfter is the cursor for a list-unspent-outputs
+// query. Like TxAfter, it identifies an output by its
+// position in the blockchain: block height, transaction
+// position, and output index.
 type OutputsAfter struct {
 	lastBlockHeight uint64
 	lastTxPos       uint32
@@ -60,7 +66,7 @@ func (ind *Indexer) Outputs(ctx context.Context, filt string, vals []interface{}
 		return nil, nil, err
 	}
 	queryStr, queryArgs := constructOutputsQuery(expr, vals, timestampMS, after, limit)
-	rows, err := ind.db.Query(ctx, queryStr, queryArgs...)
+	rows, err := ind.readDB.Query(ctx, queryStr, queryArgs...)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -130,6 +136,48 @@ func (ind *Indexer) Outputs(ctx context.Context, filt string, vals []interface{}
 	return outputs, &newAfter, nil
 }
 
+// SpendCommitment returns the commitment of the output with the
+// given ID, as a spend input of it must repeat it. It reads the
+// output's transaction from the block that includes it, so it
+// works for outputs held by any control program, not only those
+// of the Core's accounts.
+func (ind *Indexer) SpendCommitment(ctx context.Context, outputID bc.Hash) (*bc.SpendCommitment, error) {
+	const q = `
+		SELECT block_height, tx_pos, output_index FROM annotated_outputs
+		WHERE output_id = $1 LIMIT 1
+	`
+	var (
+		height uint64
+		txPos  uint32
+		index  uint32
+	)
+	err := ind.readDB.QueryRow(ctx, q, outputID).Scan(&height, &txPos, &index)
+	if err == sql.ErrNoRows {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "output id: %s", outputID)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "looking up output")
+	}
+
+	b, err := ind.c.GetBlock(ctx, height)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting block %d", height)
+	}
+	if int(txPos) >= len(b.Transactions) || int(index) >= len(b.Transactions[txPos].Outputs) {
+		return nil, errors.Wrapf(errors.New("indexed output not in its block"), "output %d of tx %d in block %d", index, txPos, height)
+	}
+	tx := b.Transactions[txPos]
+	out := tx.Outputs[index]
+	return &bc.SpendCommitment{
+		AssetAmount:    out.AssetAmount,
+		SourceID:       tx.Results[index].SourceID,
+		SourcePosition: tx.Results[index].SourcePos,
+		VMVersion:      out.VMVersion,
+		ControlProgram: out.ControlProgram,
+		RefDataHash:    tx.Results[index].RefDataHash,
+	}, nil
+}
+
 func constructOutputsQuery(where string, vals []interface{}, timestampMS uint64, after *OutputsAfter, limit int) (string, []interface{}) {
 	var buf bytes.Buffer
 
@@ -0,0 +1,255 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"chain/database/pg"
+	"chain/database/sql"
+	"chain/errors"
+	"chain/log"
+)
+
+// ErrReindexInProgress is returned when an index rebuild is
+// requested while another has not yet finished.
+var ErrReindexInProgress = errors.New("index rebuild in progress")
+
+// rebuildSchema holds the tables of an index rebuild
+// until they replace the live ones.
+const rebuildSchema = "query_rebuild"
+
+// rebuildTables are the tables an index rebuild replaces,
+// the ones written by indexBlock. Reads are served from
+// the live ones until the rebuild is complete.
+var rebuildTables = []string{
+	"annotated_txs",
+	"annotated_inputs",
+	"annotated_outputs",
+	"account_balances",
+}
+
+// ReindexStatus describes the most recently
+// requested index rebuild.
+type ReindexStatus struct {
+	RequestedAt time.Time  `json:"requested_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+
+	// Height is the height of the last block rebuilt.
+	Height uint64 `json:"height"`
+
+	// Error is set if the rebuild failed, in which
+	// case the old index is still in use.
+	Error string `json:"error,omitempty"`
+}
+
+// RequestReindex asks the leader process to rebuild the
+// annotated transactions, inputs, and outputs, and the
+// account balances, from the raw blocks, for example
+// after a change to the annotations. It returns
+// ErrReindexInProgress if an earlier rebuild has not
+// yet finished.
+func RequestReindex(ctx context.Context, db pg.DB) (*ReindexStatus, error) {
+	const q = `
+		INSERT INTO query_reindex (singleton) VALUES (true)
+		ON CONFLICT (singleton) DO UPDATE
+		SET requested_at = now(), started_at = NULL, finished_at = NULL, height = 0, error = ''
+		WHERE query_reindex.finished_at IS NOT NULL
+		RETURNING requested_at
+	`
+	var st ReindexStatus
+	err := db.QueryRow(ctx, q).Scan(&st.RequestedAt)
+	if err == sql.ErrNoRows {
+		return nil, errors.Wrap(ErrReindexInProgress)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "requesting index rebuild")
+	}
+	return &st, nil
+}
+
+// GetReindexStatus returns the status of the most recently
+// requested index rebuild, or nil if none has been requested.
+func GetReindexStatus(ctx context.Context, db pg.DB) (*ReindexStatus, error) {
+	const q = `
+		SELECT requested_at, started_at, finished_at, height, error
+		FROM query_reindex
+	`
+	var st ReindexStatus
+	err := db.QueryRow(ctx, q).Scan(&st.RequestedAt, &st.StartedAt, &st.FinishedAt, &st.Height, &st.Error)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "loading index rebuild status")
+	}
+	return &st, nil
+}
+
+// ProcessReindex checks every period for a requested index
+// rebuild, and runs it, until ctx is done. It must run only in
+// the leader process, alongside ProcessBlocks.
+//
+// A rebuild interrupted by a change of leader starts over
+// in the new leader.
+func (ind *Indexer) ProcessReindex(ctx context.Context, period time.Duration) {
+	ticks := time.NewTicker(period)
+	defer ticks.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticks.C:
+			err := ind.reindexIfRequested(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Error(ctx, err)
+			}
+		}
+	}
+}
+
+func (ind *Indexer) reindexIfRequested(ctx context.Context) error {
+	const startQ = `
+		UPDATE query_reindex SET started_at = now(), height = 0, error = ''
+		WHERE finished_at IS NULL
+	`
+	res, err := ind.db.Exec(ctx, startQ)
+	if err != nil {
+		return errors.Wrap(err, "starting index rebuild")
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return errors.Wrap(err)
+	}
+
+	log.Printkv(ctx, "at", "rebuilding index")
+	err = ind.reindex(ctx)
+	if err == nil {
+		log.Printkv(ctx, "at", "rebuilt index")
+		return nil
+	}
+	if ctx.Err() != nil {
+		// Leave the request for the next leader.
+		return err
+	}
+	const failQ = `UPDATE query_reindex SET finished_at = now(), error = $1`
+	_, failErr := ind.db.Exec(ctx, failQ, err.Error())
+	if failErr != nil {
+		log.Error(ctx, failErr)
+	}
+	return errors.Wrap(err, "rebuilding index")
+}
+
+// reindex rebuilds the tables in rebuildTables, from the first
+// block indexed through the last, in rebuildSchema. Meanwhile the
+// live tables go on being read and indexed. Once the rebuild has
+// caught up, it stops indexing and replaces the live tables.
+func (ind *Indexer) reindex(ctx context.Context) error {
+	db, ok := ind.db.(*sql.DB)
+	if !ok {
+		return errors.New("index rebuild needs a database, not a transaction")
+	}
+
+	stmts := []string{
+		"DROP SCHEMA IF EXISTS " + rebuildSchema + " CASCADE",
+		"CREATE SCHEMA " + rebuildSchema,
+	}
+	for _, t := range rebuildTables {
+		stmts = append(stmts, fmt.Sprintf("CREATE TABLE %s.%s (LIKE %s INCLUDING ALL)", rebuildSchema, t, t))
+	}
+	_, err := db.Exec(ctx, strings.Join(stmts, ";\n"))
+	if err != nil {
+		return errors.Wrap(err, "creating rebuild tables")
+	}
+
+	var height uint64
+	err = db.QueryRow(ctx, `SELECT COALESCE(MIN(height), 1) FROM query_blocks`).Scan(&height)
+	if err != nil {
+		return errors.Wrap(err, "querying first indexed block")
+	}
+	for ; height <= ind.pinStore.Height(TxPinName); height++ {
+		err = ind.reindexBlock(ctx, db, height)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Stop indexing to rebuild the last few blocks
+	// and swap in the new tables.
+	ind.indexMu.Lock()
+	defer ind.indexMu.Unlock()
+
+	var last uint64
+	err = db.QueryRow(ctx, `SELECT COALESCE(MAX(height), 0) FROM query_blocks`).Scan(&last)
+	if err != nil {
+		return errors.Wrap(err, "querying last indexed block")
+	}
+	for ; height <= last; height++ {
+		err = ind.reindexBlock(ctx, db, height)
+		if err != nil {
+			return err
+		}
+	}
+
+	stmts = []string{"DROP TABLE " + strings.Join(rebuildTables, ", ")}
+	for _, t := range rebuildTables {
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s.%s SET SCHEMA public", rebuildSchema, t))
+	}
+	stmts = append(stmts,
+		"DROP SCHEMA "+rebuildSchema,
+		"UPDATE query_reindex SET finished_at = now()",
+	)
+	return inTx(ctx, db, func(tx pg.DB) error {
+		_, err := tx.Exec(ctx, strings.Join(stmts, ";\n"))
+		return errors.Wrap(err, "replacing index tables")
+	})
+}
+
+// reindexBlock indexes the block at height
+// into the tables in rebuildSchema.
+func (ind *Indexer) reindexBlock(ctx context.Context, db *sql.DB, height uint64) error {
+	b, err := ind.c.GetBlock(ctx, height)
+	if err != nil {
+		return errors.Wrapf(err, "getting block %d", height)
+	}
+	return inTx(ctx, db, func(tx pg.DB) error {
+		// Unqualified names refer to the rebuild tables
+		// first, and to the rest of the schema after.
+		_, err := tx.Exec(ctx, "SET LOCAL search_path TO "+rebuildSchema+", public")
+		if err != nil {
+			return errors.Wrap(err, "setting search path")
+		}
+		// The rebuild indexer runs its routines inline,
+		// so that they use the search path too.
+		rebuild := &Indexer{
+			db:         tx,
+			readDB:     tx,
+			c:          ind.c,
+			pinStore:   ind.pinStore,
+			annotators: ind.annotators,
+		}
+		err = rebuild.indexBlock(ctx, b)
+		if err != nil {
+			return errors.Wrapf(err, "rebuilding block %d", height)
+		}
+		_, err = tx.Exec(ctx, `UPDATE query_reindex SET height = $1`, height)
+		return errors.Wrap(err, "recording index rebuild progress")
+	})
+}
+
+// inTx calls f in a transaction, and commits
+// the transaction if f succeeds.
+func inTx(ctx context.Context, db *sql.DB, f func(pg.DB) error) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+	err = f(tx)
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+	return errors.Wrap(tx.Commit(ctx), "commit transaction")
+}
@@ -0,0 +1,158 @@
+package query_test
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"chain/core/account"
+	"chain/core/asset"
+	"chain/core/coretest"
+	"chain/core/generator"
+	"chain/core/pin"
+	"chain/core/query"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/prottest"
+)
+
+func TestReindex(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := prottest.NewChain(t)
+	pinStore := pin.NewStore(db)
+	coretest.CreatePins(ctx, t, pinStore)
+	indexer := query.NewIndexer(db, c, pinStore)
+	accounts := account.NewManager(db, c, pinStore)
+	assets := asset.NewRegistry(db, c, pinStore)
+	assets.IndexAssets(indexer)
+	indexer.RegisterAnnotator(accounts.AnnotateTxs)
+	indexer.RegisterAnnotator(assets.AnnotateTxs)
+	go assets.ProcessBlocks(ctx)
+	go accounts.ProcessBlocks(ctx)
+	go indexer.ProcessBlocks(ctx)
+
+	acct := coretest.CreateAccount(ctx, t, accounts, "", nil)
+	assetID := coretest.CreateAsset(ctx, t, assets, nil, "", nil)
+	g := generator.New(c, nil, db)
+	coretest.IssueAssets(ctx, t, c, g, assets, accounts, assetID, 100, acct)
+	prottest.MakeBlock(t, c, g.PendingTxs())
+	coretest.IssueAssets(ctx, t, c, g, assets, accounts, assetID, 200, acct)
+	prottest.MakeBlock(t, c, g.PendingTxs())
+	<-pinStore.PinWaiter(query.TxPinName, c.Height())
+
+	const filt = "account_id = $1"
+	vals := []interface{}{acct}
+	want, _, err := indexer.Outputs(ctx, filt, vals, uint64(1<<62), nil, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Take the first page of outputs and of transactions, and
+	// the pages following them, to check that their cursors
+	// still pick up in the same place after the rebuild.
+	_, outNext, err := indexer.Outputs(ctx, filt, vals, uint64(1<<62), nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantOutRest, _, err := indexer.Outputs(ctx, filt, vals, uint64(1<<62), outNext, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txAfter, err := indexer.LookupTxAfter(ctx, 0, math.MaxInt64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, txNext, err := indexer.Transactions(ctx, "", nil, txAfter, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTxRest, _, err := indexer.Transactions(ctx, "", nil, *txNext, 100, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wantOutRest) == 0 || len(wantTxRest) == 0 {
+		t.Fatalf("got %d outputs and %d txs after the first page, want some", len(wantOutRest), len(wantTxRest))
+	}
+	outCursor, txCursor := outNext.String(), txNext.String()
+
+	_, err = query.RequestReindex(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = query.RequestReindex(ctx, db)
+	if errors.Root(err) != query.ErrReindexInProgress {
+		t.Errorf("second request err = %v want %v", err, query.ErrReindexInProgress)
+	}
+
+	go indexer.ProcessReindex(ctx, 10*time.Millisecond)
+	var st *query.ReindexStatus
+	for deadline := time.Now().Add(10 * time.Second); ; {
+		st, err = query.GetReindexStatus(ctx, db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.FinishedAt != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("rebuild did not finish: %+v", st)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if st.Error != "" {
+		t.Fatalf("rebuild failed: %s", st.Error)
+	}
+	if st.Height != c.Height() {
+		t.Errorf("rebuilt through block %d want %d", st.Height, c.Height())
+	}
+
+	got, _, err := indexer.Outputs(ctx, filt, vals, uint64(1<<62), nil, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) || len(got) == 0 {
+		t.Fatalf("got %d outputs after rebuild, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i].OutputID != want[i].OutputID || got[i].Amount != want[i].Amount {
+			t.Errorf("output %d = %+v want %+v", i, got[i], want[i])
+		}
+	}
+
+	outAfter, err := query.DecodeOutputsAfter(outCursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotOutRest, _, err := indexer.Outputs(ctx, filt, vals, uint64(1<<62), outAfter, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gotOutRest) != len(wantOutRest) {
+		t.Fatalf("got %d outputs after cursor %s, want %d", len(gotOutRest), outCursor, len(wantOutRest))
+	}
+	for i := range gotOutRest {
+		if gotOutRest[i].OutputID != wantOutRest[i].OutputID {
+			t.Errorf("output %d after cursor = %x want %x", i, gotOutRest[i].OutputID, wantOutRest[i].OutputID)
+		}
+	}
+
+	txAfter, err = query.DecodeTxAfter(txCursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotTxRest, _, err := indexer.Transactions(ctx, "", nil, txAfter, 100, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gotTxRest) != len(wantTxRest) {
+		t.Fatalf("got %d txs after cursor %s, want %d", len(gotTxRest), txCursor, len(wantTxRest))
+	}
+	for i := range gotTxRest {
+		if gotTxRest[i].ID != wantTxRest[i].ID {
+			t.Errorf("tx %d after cursor = %x want %x", i, gotTxRest[i].ID, wantTxRest[i].ID)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package query
+
+import (
+	"context"
+
+	"chain/errors"
+	"chain/log"
+)
+
+// A routine is one of the query engine's hot, fixed statements.
+// Each is also installed in the database as a function (see the
+// query-functions migration). Calling a plpgsql function by name
+// lets the server reuse the plan it cached for the statement,
+// instead of parsing and planning the statement on every call.
+type routine struct {
+	function string // signature, as accepted by to_regprocedure
+	call     string // statement calling the function
+	inline   string // equivalent statement without the function
+}
+
+var (
+	txAfterRoutine = &routine{
+		function: "query_tx_after(bigint,bigint)",
+		call:     `SELECT from_height, stop_height FROM query_tx_after($1, $2)`,
+		inline: `
+			SELECT COALESCE(MAX(height), 0), COALESCE(MIN(height), 0) FROM query_blocks
+			WHERE timestamp >= $1 AND timestamp <= $2
+		`,
+	}
+	blockTimestampRoutine = &routine{
+		function: "query_block_timestamp(bigint)",
+		call:     `SELECT ts FROM query_block_timestamp($1) ts WHERE ts IS NOT NULL`,
+		inline:   `SELECT timestamp FROM query_blocks WHERE height = $1`,
+	}
+	spendOutputsRoutine = &routine{
+		function: "query_spend_outputs(bigint,bytea[])",
+		call:     `SELECT query_spend_outputs($1, $2)`,
+		inline: `
+			UPDATE annotated_outputs SET timespan = INT8RANGE(LOWER(timespan), $1)
+			WHERE (output_id) IN (SELECT unnest($2::bytea[]))
+		`,
+	}
+
+	routines = []*routine{txAfterRoutine, blockTimestampRoutine, spendOutputsRoutine}
+)
+
+// UseQueryFunctions makes the indexer run its hot statements by
+// calling the functions installed for them in the database.
+// If a function is missing, for example because the database
+// was restored from before it was added, the indexer logs it
+// and keeps running that statement inline.
+// It must be called before the indexer is used.
+func (ind *Indexer) UseQueryFunctions(ctx context.Context) error {
+	const q = `SELECT to_regprocedure($1) IS NOT NULL`
+	functions := make(map[*routine]bool)
+	for _, r := range routines {
+		var ok bool
+		err := ind.db.QueryRow(ctx, q, r.function).Scan(&ok)
+		if err != nil {
+			return errors.Wrap(err, "checking for query function")
+		}
+		if !ok {
+			log.Printkv(ctx, "at", "query function missing, running statement inline", "function", r.function)
+			continue
+		}
+		functions[r] = true
+	}
+	ind.functions = functions
+	return nil
+}
+
+// sql returns the statement to run for r.
+func (ind *Indexer) sql(r *routine) string {
+	if ind.functions[r] {
+		return r.call
+	}
+	return r.inline
+}
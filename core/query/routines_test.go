@@ -0,0 +1,42 @@
+package query
+
+import (
+	"context"
+	"testing"
+
+	"chain/database/pg/pgtest"
+	"chain/protocol"
+)
+
+func TestUseQueryFunctionsMissing(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.NewTx(t)
+	indexer := NewIndexer(db, &protocol.Chain{}, nil)
+
+	const q = `INSERT INTO query_blocks (height, timestamp) VALUES (1, 100), (2, 200), (3, 300)`
+	_, err := db.Exec(ctx, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Drop the function, as if the database predates it.
+	_, err = db.Exec(ctx, `DROP FUNCTION IF EXISTS query_tx_after(bigint, bigint)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = indexer.UseQueryFunctions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := indexer.sql(txAfterRoutine); got != txAfterRoutine.inline {
+		t.Errorf("sql(txAfterRoutine) = %q, want inline statement", got)
+	}
+
+	cur, err := indexer.LookupTxAfter(ctx, 150, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cur.FromBlockHeight != 3 || cur.StopBlockHeight != 2 {
+		t.Errorf("LookupTxAfter(150, 300) = %+v, want from 3 stop 2", cur)
+	}
+}
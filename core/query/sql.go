@@ -9,23 +9,25 @@ var (
 		Name:  "annotated_assets",
 		Alias: "ast",
 		Columns: map[string]*filter.SQLColumn{
-			"id":               {Name: "id", Type: filter.String, SQLType: filter.SQLBytea},
-			"alias":            {Name: "alias", Type: filter.String, SQLType: filter.SQLText},
-			"issuance_program": {Name: "issuance_program", Type: filter.String, SQLType: filter.SQLBytea},
-			"quorum":           {Name: "quorum", Type: filter.Integer, SQLType: filter.SQLInteger},
-			"tags":             {Name: "tags", Type: filter.Object, SQLType: filter.SQLJSONB},
-			"definition":       {Name: "definition", Type: filter.Object, SQLType: filter.SQLJSONB},
-			"is_local":         {Name: "local", Type: filter.String, SQLType: filter.SQLBool},
+			"id":                 {Name: "id", Type: filter.String, SQLType: filter.SQLBytea},
+			"alias":              {Name: "alias", Type: filter.String, SQLType: filter.SQLText},
+			"issuance_program":   {Name: "issuance_program", Type: filter.String, SQLType: filter.SQLBytea},
+			"quorum":             {Name: "quorum", Type: filter.Integer, SQLType: filter.SQLInteger},
+			"tags":               {Name: "tags", Type: filter.Object, SQLType: filter.SQLJSONB},
+			"definition":         {Name: "definition", Type: filter.Object, SQLType: filter.SQLJSONB},
+			"is_local":           {Name: "local", Type: filter.String, SQLType: filter.SQLBool},
+			"definition_version": {Name: "definition_version", Type: filter.Integer, SQLType: filter.SQLBigint},
 		},
 	}
 	accountsTable = &filter.SQLTable{
 		Name:  "annotated_accounts",
 		Alias: "acc",
 		Columns: map[string]*filter.SQLColumn{
-			"id":     {Name: "id", Type: filter.String, SQLType: filter.SQLText},
-			"alias":  {Name: "alias", Type: filter.String, SQLType: filter.SQLText},
-			"quorum": {Name: "quorum", Type: filter.Integer, SQLType: filter.SQLInteger},
-			"tags":   {Name: "tags", Type: filter.Object, SQLType: filter.SQLJSONB},
+			"id":         {Name: "id", Type: filter.String, SQLType: filter.SQLText},
+			"alias":      {Name: "alias", Type: filter.String, SQLType: filter.SQLText},
+			"quorum":     {Name: "quorum", Type: filter.Integer, SQLType: filter.SQLInteger},
+			"tags":       {Name: "tags", Type: filter.Object, SQLType: filter.SQLJSONB},
+			"is_deleted": {Name: "deleted", Type: filter.String, SQLType: filter.SQLBool},
 		},
 	}
 	outputsTable = &filter.SQLTable{
@@ -42,6 +44,7 @@ var (
 			"asset_definition": {Name: "asset_definition", Type: filter.Object, SQLType: filter.SQLJSONB},
 			"asset_tags":       {Name: "asset_tags", Type: filter.Object, SQLType: filter.SQLJSONB},
 			"asset_is_local":   {Name: "asset_local", Type: filter.String, SQLType: filter.SQLBool},
+			"asset_lineage_id": {Name: "asset_lineage_id", Type: filter.String, SQLType: filter.SQLBytea},
 			"amount":           {Name: "amount", Type: filter.Integer, SQLType: filter.SQLBigint},
 			"account_id":       {Name: "account_id", Type: filter.String, SQLType: filter.SQLText},
 			"account_alias":    {Name: "account_alias", Type: filter.String, SQLType: filter.SQLText},
@@ -17,6 +17,10 @@ var (
 	ErrParameterCountMismatch = errors.New("wrong number of parameters to query")
 )
 
+// TxAfter is the cursor for a list-transactions query.
+// It identifies a transaction by its block height and
+// position in the block, not by any database row, so it
+// stays valid if the transaction index is rebuilt.
 type TxAfter struct {
 	// FromBlockHeight and FromPosition uniquely identify the last transaction returned
 	// by a list-transactions query.
@@ -57,13 +61,8 @@ func ValidateTransactionFilter(filt string) error {
 
 // LookupTxAfter looks up the transaction `after` for the provided time range.
 func (ind *Indexer) LookupTxAfter(ctx context.Context, begin, end uint64) (TxAfter, error) {
-	const q = `
-		SELECT COALESCE(MAX(height), 0), COALESCE(MIN(height), 0) FROM query_blocks
-		WHERE timestamp >= $1 AND timestamp <= $2
-	`
-
 	var from, stop uint64
-	err := ind.db.QueryRow(ctx, q, begin, end).Scan(&from, &stop)
+	err := ind.readDB.QueryRow(ctx, ind.sql(txAfterRoutine), begin, end).Scan(&from, &stop)
 	if err != nil {
 		return TxAfter{}, errors.Wrap(err, "querying `query_blocks`")
 	}
@@ -77,24 +76,44 @@ func (ind *Indexer) LookupTxAfter(ctx context.Context, begin, end uint64) (TxAft
 // Transactions queries the blockchain for transactions matching the
 // filter predicate `filt`.
 func (ind *Indexer) Transactions(ctx context.Context, filt string, vals []interface{}, after TxAfter, limit int, asc bool) ([]*AnnotatedTx, *TxAfter, error) {
-	p, err := filter.Parse(filt, transactionsTable, vals)
+	queryStr, queryArgs, err := transactionsQuery(filt, vals, after, asc, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if asc {
+		return ind.waitForAndFetchTransactions(ctx, queryStr, queryArgs, after, limit)
+	}
+	return ind.fetchTransactions(ctx, queryStr, queryArgs, after, limit)
+}
+
+// TransactionsSince returns up to limit of the transactions
+// matching filt that follow after, oldest first. Unlike an
+// ascending Transactions query, it does not wait for new
+// transactions to be indexed; it returns no transactions
+// if there are none yet.
+func (ind *Indexer) TransactionsSince(ctx context.Context, filt string, vals []interface{}, after TxAfter, limit int) ([]*AnnotatedTx, *TxAfter, error) {
+	queryStr, queryArgs, err := transactionsQuery(filt, vals, after, true, limit)
 	if err != nil {
 		return nil, nil, err
 	}
+	return ind.fetchTransactions(ctx, queryStr, queryArgs, after, limit)
+}
+
+func transactionsQuery(filt string, vals []interface{}, after TxAfter, asc bool, limit int) (string, []interface{}, error) {
+	p, err := filter.Parse(filt, transactionsTable, vals)
+	if err != nil {
+		return "", nil, err
+	}
 	if len(vals) != p.Parameters {
-		return nil, nil, ErrParameterCountMismatch
+		return "", nil, ErrParameterCountMismatch
 	}
 	expr, err := filter.AsSQL(p, transactionsTable, vals)
 	if err != nil {
-		return nil, nil, errors.Wrap(err, "converting to SQL")
+		return "", nil, errors.Wrap(err, "converting to SQL")
 	}
-
 	queryStr, queryArgs := constructTransactionsQuery(expr, vals, after, asc, limit)
-
-	if asc {
-		return ind.waitForAndFetchTransactions(ctx, queryStr, queryArgs, after, limit)
-	}
-	return ind.fetchTransactions(ctx, queryStr, queryArgs, after, limit)
+	return queryStr, queryArgs, nil
 }
 
 // If asc is true, the transactions will be returned from "in front" of the `after`
@@ -133,7 +152,7 @@ func constructTransactionsQuery(expr string, vals []interface{}, after TxAfter,
 }
 
 func (ind *Indexer) fetchTransactions(ctx context.Context, queryStr string, queryArgs []interface{}, after TxAfter, limit int) ([]*AnnotatedTx, *TxAfter, error) {
-	rows, err := ind.db.Query(ctx, queryStr, queryArgs...)
+	rows, err := ind.readDB.Query(ctx, queryStr, queryArgs...)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "executing txn query")
 	}
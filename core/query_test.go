@@ -2,6 +2,8 @@ package core
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -54,3 +56,32 @@ func TestQueryWithClockSkew(t *testing.T) {
 		t.Errorf("got=%d txs, want %d", count, 1)
 	}
 }
+
+func TestBlockHeightHeader(t *testing.T) {
+	cases := []struct {
+		header     string
+		wantStatus int
+		wantHeight uint64
+	}{
+		{"", 200, 0},
+		{"12", 200, 12},
+		{"0", 400, 0},
+		{"-1", 400, 0},
+		{"twelve", 400, 0},
+	}
+	for _, c := range cases {
+		var got uint64
+		h := blockHeightContextHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			got, _ = req.Context().Value(blockHeightKey{}).(uint64)
+		}))
+		req := httptest.NewRequest("POST", "/list-transactions", nil)
+		if c.header != "" {
+			req.Header.Set(HeaderBlockHeight, c.header)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != c.wantStatus || got != c.wantHeight {
+			t.Errorf("header %q: status %d height %d, want %d %d", c.header, rec.Code, got, c.wantStatus, c.wantHeight)
+		}
+	}
+}
@@ -5,9 +5,16 @@ import (
 	"sync"
 	"time"
 
+	"chain/core/account"
+	"chain/errors"
+	"chain/net/http/httpjson"
 	"chain/net/http/reqid"
 )
 
+// maxGapLimit bounds the gap limit of a receiver recovery,
+// which derives and looks up that many programs at a time.
+const maxGapLimit = 1000
+
 // POST /create-account-receiver
 func (a *API) createAccountReceiver(ctx context.Context, ins []struct {
 	AccountID    string    `json:"account_id"`
@@ -36,3 +43,20 @@ func (a *API) createAccountReceiver(ctx context.Context, ins []struct {
 	wg.Wait()
 	return responses
 }
+
+// recoverAccountReceivers finds the receivers of an account restored
+// from the xpubs of an account on another Core that have been used
+// on the blockchain, and makes their unspent outputs spendable.
+//
+// POST /recover-account-receivers
+func (a *API) recoverAccountReceivers(ctx context.Context, in struct {
+	AccountID    string    `json:"account_id"`
+	AccountAlias string    `json:"account_alias"`
+	GapLimit     int       `json:"gap_limit"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}) (*account.ReceiverRecovery, error) {
+	if in.GapLimit < 0 || in.GapLimit > maxGapLimit {
+		return nil, errors.WithDetailf(httpjson.ErrBadRequest, "gap_limit must be between 0 and %d", maxGapLimit)
+	}
+	return a.Accounts.RecoverReceivers(ctx, in.AccountID, in.AccountAlias, in.GapLimit, in.ExpiresAt)
+}
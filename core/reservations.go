@@ -0,0 +1,59 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+
+	"chain/core/account"
+	"chain/core/leader"
+	"chain/errors"
+	"chain/net/http/httpjson"
+)
+
+// listReservations lists the reservations of account outputs
+// held for transactions being built, optionally limited to
+// one account's. Reservations live in the leader process,
+// so other processes forward the request to it.
+//
+// POST /list-reservations
+func (a *API) listReservations(ctx context.Context, in struct {
+	AccountID    string `json:"account_id"`
+	AccountAlias string `json:"account_alias"`
+}) (interface{}, error) {
+	if !leader.IsLeading() {
+		var resp json.RawMessage
+		err := a.forwardToLeader(ctx, "/list-reservations", in, &resp)
+		return resp, err
+	}
+
+	accountID := in.AccountID
+	if accountID == "" && in.AccountAlias != "" {
+		acc, err := a.Accounts.FindByAlias(ctx, in.AccountAlias)
+		if err != nil {
+			return nil, err
+		}
+		accountID = acc.ID
+	}
+	list := a.Accounts.Reservations(accountID)
+	if list == nil {
+		list = []*account.Reservation{}
+	}
+	return page{Items: list, LastPage: true}, nil
+}
+
+// cancelReservation cancels a reservation of account outputs,
+// making them available to other transactions, for example
+// when the transaction it was made for will not be submitted.
+//
+// POST /cancel-reservation
+func (a *API) cancelReservation(ctx context.Context, in struct {
+	ID *uint64 `json:"id,omitempty"`
+}) error {
+	if !leader.IsLeading() {
+		return a.forwardToLeader(ctx, "/cancel-reservation", in, nil)
+	}
+	if in.ID == nil {
+		return errors.WithDetail(httpjson.ErrBadRequest, "missing reservation id")
+	}
+	return a.Accounts.CancelReservation(ctx, *in.ID)
+}
@@ -5,12 +5,40 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"chain/core/build"
+	"chain/core/txbuilder"
 	chainjson "chain/encoding/json"
 	"chain/errors"
 	"chain/net/http/httpjson"
 	"chain/protocol/bc"
 )
 
+// submitIdempotentRPC submits a tx with an idempotency key.
+// It returns the ID of the tx submitted with the key, which
+// is that of an earlier tx if the key was used before.
+func (a *API) submitIdempotentRPC(ctx context.Context, x txbuilder.IdempotentSubmission) (map[string]bc.Hash, error) {
+	if x.Tx == nil {
+		return nil, errors.Wrap(txbuilder.ErrMissingRawTx)
+	}
+	// Keys are scoped by the original submitter, within the
+	// scope of the forwarding core, so that a core's clients
+	// can't claim each other's keys, nor another core's.
+	who := accessTokenID(ctx)
+	if x.Submitter != "" {
+		who += "/" + x.Submitter
+	}
+	ctx = txbuilder.WithSubmitter(ctx, who)
+	ctx = txbuilder.WithIdempotencyKey(ctx, x.Key)
+	err := a.Submitter.Submit(ctx, x.Tx)
+	if dup, ok := errors.Root(err).(*txbuilder.DuplicateError); ok {
+		return map[string]bc.Hash{"id": dup.TxID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]bc.Hash{"id": x.Tx.ID}, nil
+}
+
 // getBlockRPC returns the block at the requested height.
 // If successful, it always returns at least one block,
 // waiting if necessary until one is created.
@@ -79,3 +107,42 @@ func (a *API) getSnapshotRPC(rw http.ResponseWriter, req *http.Request) {
 	rw.Header().Set("Content-Type", "application/x-protobuf")
 	rw.Write(data)
 }
+
+// getBuildInfoRPC returns information about this core's build,
+// so that peers can check they run the same consensus-relevant code.
+func (a *API) getBuildInfoRPC(ctx context.Context) build.Info {
+	return build.Current()
+}
+
+type peerBuild struct {
+	URL     string         `json:"url"`
+	Build   *build.Info    `json:"build,omitempty"`
+	Matches bool           `json:"matches"`
+	Error   *detailedError `json:"error,omitempty"`
+}
+
+// checkNetworkBuild asks each of this core's peers
+// (its generator, or a generator's remote signers)
+// for its build information and reports whether
+// it matches this core's consensus digest.
+func (a *API) checkNetworkBuild(ctx context.Context) []peerBuild {
+	self := build.Current()
+	res := make([]peerBuild, 0, len(a.Peers))
+	for _, peer := range a.Peers {
+		pb := peerBuild{URL: peer.BaseURL}
+		var info build.Info
+		err := peer.Call(ctx, networkRPCPrefix+"build-info", nil, &info)
+		if err == nil {
+			pb.Build = &info
+			err = self.Compare(info)
+		}
+		if err != nil {
+			body, _ := errInfo(err)
+			pb.Error = &body
+		} else {
+			pb.Matches = true
+		}
+		res = append(res, pb)
+	}
+	return res
+}
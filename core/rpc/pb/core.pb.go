@@ -0,0 +1,674 @@
+// Code generated by protoc-gen-go.
+// source: core.proto
+// DO NOT EDIT!
+
+/*
+Package pb is a generated protocol buffer package.
+
+It is generated from these files:
+	core.proto
+
+It has these top-level messages:
+	Error
+	InfoRequest
+	InfoResponse
+	BuildTxsRequest
+	SubmitTxsRequest
+	TxsResponse
+	QueryRequest
+	QueryResponse
+	StreamBlocksRequest
+	Block
+*/
+package pb
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+// Error describes an error in the
+// form used by the JSON API.
+type Error struct {
+	Code      string `protobuf:"bytes,1,opt,name=code" json:"code,omitempty"`
+	Message   string `protobuf:"bytes,2,opt,name=message" json:"message,omitempty"`
+	Detail    string `protobuf:"bytes,3,opt,name=detail" json:"detail,omitempty"`
+	Data      []byte `protobuf:"bytes,4,opt,name=data,proto3" json:"data,omitempty"`
+	Temporary bool   `protobuf:"varint,5,opt,name=temporary" json:"temporary,omitempty"`
+}
+
+func (m *Error) Reset()                    { *m = Error{} }
+func (m *Error) String() string            { return proto.CompactTextString(m) }
+func (*Error) ProtoMessage()               {}
+func (*Error) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+type InfoRequest struct {
+}
+
+func (m *InfoRequest) Reset()                    { *m = InfoRequest{} }
+func (m *InfoRequest) String() string            { return proto.CompactTextString(m) }
+func (*InfoRequest) ProtoMessage()               {}
+func (*InfoRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+type InfoResponse struct {
+	IsConfigured         bool   `protobuf:"varint,1,opt,name=is_configured,json=isConfigured" json:"is_configured,omitempty"`
+	IsGenerator          bool   `protobuf:"varint,2,opt,name=is_generator,json=isGenerator" json:"is_generator,omitempty"`
+	IsSigner             bool   `protobuf:"varint,3,opt,name=is_signer,json=isSigner" json:"is_signer,omitempty"`
+	IsProduction         bool   `protobuf:"varint,4,opt,name=is_production,json=isProduction" json:"is_production,omitempty"`
+	BlockchainId         string `protobuf:"bytes,5,opt,name=blockchain_id,json=blockchainId" json:"blockchain_id,omitempty"`
+	GeneratorUrl         string `protobuf:"bytes,6,opt,name=generator_url,json=generatorUrl" json:"generator_url,omitempty"`
+	BlockHeight          uint64 `protobuf:"varint,7,opt,name=block_height,json=blockHeight" json:"block_height,omitempty"`
+	GeneratorBlockHeight uint64 `protobuf:"varint,8,opt,name=generator_block_height,json=generatorBlockHeight" json:"generator_block_height,omitempty"`
+	CoreId               string `protobuf:"bytes,9,opt,name=core_id,json=coreId" json:"core_id,omitempty"`
+	Version              string `protobuf:"bytes,10,opt,name=version" json:"version,omitempty"`
+	NetworkRpcVersion    uint64 `protobuf:"varint,11,opt,name=network_rpc_version,json=networkRpcVersion" json:"network_rpc_version,omitempty"`
+}
+
+func (m *InfoResponse) Reset()                    { *m = InfoResponse{} }
+func (m *InfoResponse) String() string            { return proto.CompactTextString(m) }
+func (*InfoResponse) ProtoMessage()               {}
+func (*InfoResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+type BuildTxsRequest struct {
+	Requests []*BuildTxsRequest_Request `protobuf:"bytes,1,rep,name=requests" json:"requests,omitempty"`
+}
+
+func (m *BuildTxsRequest) Reset()                    { *m = BuildTxsRequest{} }
+func (m *BuildTxsRequest) String() string            { return proto.CompactTextString(m) }
+func (*BuildTxsRequest) ProtoMessage()               {}
+func (*BuildTxsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *BuildTxsRequest) GetRequests() []*BuildTxsRequest_Request {
+	if m != nil {
+		return m.Requests
+	}
+	return nil
+}
+
+type BuildTxsRequest_Request struct {
+	BaseTransaction []byte   `protobuf:"bytes,1,opt,name=base_transaction,json=baseTransaction,proto3" json:"base_transaction,omitempty"`
+	Actions         [][]byte `protobuf:"bytes,2,rep,name=actions,proto3" json:"actions,omitempty"`
+	TtlMs           uint64   `protobuf:"varint,3,opt,name=ttl_ms,json=ttlMs" json:"ttl_ms,omitempty"`
+}
+
+func (m *BuildTxsRequest_Request) Reset()                    { *m = BuildTxsRequest_Request{} }
+func (m *BuildTxsRequest_Request) String() string            { return proto.CompactTextString(m) }
+func (*BuildTxsRequest_Request) ProtoMessage()               {}
+func (*BuildTxsRequest_Request) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3, 0} }
+
+type SubmitTxsRequest struct {
+	Transactions    [][]byte `protobuf:"bytes,1,rep,name=transactions,proto3" json:"transactions,omitempty"`
+	WaitUntil       string   `protobuf:"bytes,2,opt,name=wait_until,json=waitUntil" json:"wait_until,omitempty"`
+	IdempotencyKeys []string `protobuf:"bytes,3,rep,name=idempotency_keys,json=idempotencyKeys" json:"idempotency_keys,omitempty"`
+}
+
+func (m *SubmitTxsRequest) Reset()                    { *m = SubmitTxsRequest{} }
+func (m *SubmitTxsRequest) String() string            { return proto.CompactTextString(m) }
+func (*SubmitTxsRequest) ProtoMessage()               {}
+func (*SubmitTxsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+// TxsResponse holds one response for each transaction
+// in a BuildTxsRequest or SubmitTxsRequest.
+type TxsResponse struct {
+	Responses []*TxsResponse_Response `protobuf:"bytes,1,rep,name=responses" json:"responses,omitempty"`
+}
+
+func (m *TxsResponse) Reset()                    { *m = TxsResponse{} }
+func (m *TxsResponse) String() string            { return proto.CompactTextString(m) }
+func (*TxsResponse) ProtoMessage()               {}
+func (*TxsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *TxsResponse) GetResponses() []*TxsResponse_Response {
+	if m != nil {
+		return m.Responses
+	}
+	return nil
+}
+
+type TxsResponse_Response struct {
+	Error *Error `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+	Json  []byte `protobuf:"bytes,2,opt,name=json,proto3" json:"json,omitempty"`
+}
+
+func (m *TxsResponse_Response) Reset()                    { *m = TxsResponse_Response{} }
+func (m *TxsResponse_Response) String() string            { return proto.CompactTextString(m) }
+func (*TxsResponse_Response) ProtoMessage()               {}
+func (*TxsResponse_Response) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5, 0} }
+
+func (m *TxsResponse_Response) GetError() *Error {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type QueryRequest struct {
+	Filter                string   `protobuf:"bytes,1,opt,name=filter" json:"filter,omitempty"`
+	FilterParams          [][]byte `protobuf:"bytes,2,rep,name=filter_params,json=filterParams,proto3" json:"filter_params,omitempty"`
+	SumBy                 []string `protobuf:"bytes,3,rep,name=sum_by,json=sumBy" json:"sum_by,omitempty"`
+	PageSize              int32    `protobuf:"varint,4,opt,name=page_size,json=pageSize" json:"page_size,omitempty"`
+	After                 string   `protobuf:"bytes,5,opt,name=after" json:"after,omitempty"`
+	StartTime             uint64   `protobuf:"varint,6,opt,name=start_time,json=startTime" json:"start_time,omitempty"`
+	EndTime               uint64   `protobuf:"varint,7,opt,name=end_time,json=endTime" json:"end_time,omitempty"`
+	Timestamp             uint64   `protobuf:"varint,8,opt,name=timestamp" json:"timestamp,omitempty"`
+	AscendingWithLongPoll bool     `protobuf:"varint,9,opt,name=ascending_with_long_poll,json=ascendingWithLongPoll" json:"ascending_with_long_poll,omitempty"`
+	TimeoutMs             uint64   `protobuf:"varint,10,opt,name=timeout_ms,json=timeoutMs" json:"timeout_ms,omitempty"`
+}
+
+func (m *QueryRequest) Reset()                    { *m = QueryRequest{} }
+func (m *QueryRequest) String() string            { return proto.CompactTextString(m) }
+func (*QueryRequest) ProtoMessage()               {}
+func (*QueryRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+type QueryResponse struct {
+	Items    [][]byte      `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+	Next     *QueryRequest `protobuf:"bytes,2,opt,name=next" json:"next,omitempty"`
+	LastPage bool          `protobuf:"varint,3,opt,name=last_page,json=lastPage" json:"last_page,omitempty"`
+}
+
+func (m *QueryResponse) Reset()                    { *m = QueryResponse{} }
+func (m *QueryResponse) String() string            { return proto.CompactTextString(m) }
+func (*QueryResponse) ProtoMessage()               {}
+func (*QueryResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *QueryResponse) GetNext() *QueryRequest {
+	if m != nil {
+		return m.Next
+	}
+	return nil
+}
+
+type StreamBlocksRequest struct {
+	AfterHeight uint64 `protobuf:"varint,1,opt,name=after_height,json=afterHeight" json:"after_height,omitempty"`
+}
+
+func (m *StreamBlocksRequest) Reset()                    { *m = StreamBlocksRequest{} }
+func (m *StreamBlocksRequest) String() string            { return proto.CompactTextString(m) }
+func (*StreamBlocksRequest) ProtoMessage()               {}
+func (*StreamBlocksRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+type Block struct {
+	Height uint64 `protobuf:"varint,1,opt,name=height" json:"height,omitempty"`
+	Block  []byte `protobuf:"bytes,2,opt,name=block,proto3" json:"block,omitempty"`
+}
+
+func (m *Block) Reset()                    { *m = Block{} }
+func (m *Block) String() string            { return proto.CompactTextString(m) }
+func (*Block) ProtoMessage()               {}
+func (*Block) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func init() {
+	proto.RegisterType((*Error)(nil), "chain.core.rpc.pb.Error")
+	proto.RegisterType((*InfoRequest)(nil), "chain.core.rpc.pb.InfoRequest")
+	proto.RegisterType((*InfoResponse)(nil), "chain.core.rpc.pb.InfoResponse")
+	proto.RegisterType((*BuildTxsRequest)(nil), "chain.core.rpc.pb.BuildTxsRequest")
+	proto.RegisterType((*BuildTxsRequest_Request)(nil), "chain.core.rpc.pb.BuildTxsRequest.Request")
+	proto.RegisterType((*SubmitTxsRequest)(nil), "chain.core.rpc.pb.SubmitTxsRequest")
+	proto.RegisterType((*TxsResponse)(nil), "chain.core.rpc.pb.TxsResponse")
+	proto.RegisterType((*TxsResponse_Response)(nil), "chain.core.rpc.pb.TxsResponse.Response")
+	proto.RegisterType((*QueryRequest)(nil), "chain.core.rpc.pb.QueryRequest")
+	proto.RegisterType((*QueryResponse)(nil), "chain.core.rpc.pb.QueryResponse")
+	proto.RegisterType((*StreamBlocksRequest)(nil), "chain.core.rpc.pb.StreamBlocksRequest")
+	proto.RegisterType((*Block)(nil), "chain.core.rpc.pb.Block")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// Client API for Node service
+
+type NodeClient interface {
+	// Info reports the Core's configuration and status, like /info.
+	Info(ctx context.Context, in *InfoRequest, opts ...grpc.CallOption) (*InfoResponse, error)
+	// BuildTxs builds transaction templates, like /build-transaction.
+	BuildTxs(ctx context.Context, in *BuildTxsRequest, opts ...grpc.CallOption) (*TxsResponse, error)
+	// SubmitTxs submits signed transaction templates,
+	// like /submit-transaction.
+	SubmitTxs(ctx context.Context, in *SubmitTxsRequest, opts ...grpc.CallOption) (*TxsResponse, error)
+	ListTxs(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryResponse, error)
+	ListAccounts(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryResponse, error)
+	ListAssets(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryResponse, error)
+	ListBalances(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryResponse, error)
+	ListUnspentOutputs(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryResponse, error)
+	// StreamBlocks sends the blocks after the given height as
+	// the Core receives them, until the client cancels the call.
+	StreamBlocks(ctx context.Context, in *StreamBlocksRequest, opts ...grpc.CallOption) (Node_StreamBlocksClient, error)
+}
+
+type nodeClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewNodeClient(cc *grpc.ClientConn) NodeClient {
+	return &nodeClient{cc}
+}
+
+func (c *nodeClient) Info(ctx context.Context, in *InfoRequest, opts ...grpc.CallOption) (*InfoResponse, error) {
+	out := new(InfoResponse)
+	err := grpc.Invoke(ctx, "/chain.core.rpc.pb.Node/Info", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) BuildTxs(ctx context.Context, in *BuildTxsRequest, opts ...grpc.CallOption) (*TxsResponse, error) {
+	out := new(TxsResponse)
+	err := grpc.Invoke(ctx, "/chain.core.rpc.pb.Node/BuildTxs", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) SubmitTxs(ctx context.Context, in *SubmitTxsRequest, opts ...grpc.CallOption) (*TxsResponse, error) {
+	out := new(TxsResponse)
+	err := grpc.Invoke(ctx, "/chain.core.rpc.pb.Node/SubmitTxs", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) ListTxs(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryResponse, error) {
+	out := new(QueryResponse)
+	err := grpc.Invoke(ctx, "/chain.core.rpc.pb.Node/ListTxs", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) ListAccounts(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryResponse, error) {
+	out := new(QueryResponse)
+	err := grpc.Invoke(ctx, "/chain.core.rpc.pb.Node/ListAccounts", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) ListAssets(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryResponse, error) {
+	out := new(QueryResponse)
+	err := grpc.Invoke(ctx, "/chain.core.rpc.pb.Node/ListAssets", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) ListBalances(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryResponse, error) {
+	out := new(QueryResponse)
+	err := grpc.Invoke(ctx, "/chain.core.rpc.pb.Node/ListBalances", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) ListUnspentOutputs(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryResponse, error) {
+	out := new(QueryResponse)
+	err := grpc.Invoke(ctx, "/chain.core.rpc.pb.Node/ListUnspentOutputs", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) StreamBlocks(ctx context.Context, in *StreamBlocksRequest, opts ...grpc.CallOption) (Node_StreamBlocksClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Node_serviceDesc.Streams[0], c.cc, "/chain.core.rpc.pb.Node/StreamBlocks", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &nodeStreamBlocksClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Node_StreamBlocksClient interface {
+	Recv() (*Block, error)
+	grpc.ClientStream
+}
+
+type nodeStreamBlocksClient struct {
+	grpc.ClientStream
+}
+
+func (x *nodeStreamBlocksClient) Recv() (*Block, error) {
+	m := new(Block)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Server API for Node service
+
+type NodeServer interface {
+	// Info reports the Core's configuration and status, like /info.
+	Info(context.Context, *InfoRequest) (*InfoResponse, error)
+	// BuildTxs builds transaction templates, like /build-transaction.
+	BuildTxs(context.Context, *BuildTxsRequest) (*TxsResponse, error)
+	// SubmitTxs submits signed transaction templates,
+	// like /submit-transaction.
+	SubmitTxs(context.Context, *SubmitTxsRequest) (*TxsResponse, error)
+	ListTxs(context.Context, *QueryRequest) (*QueryResponse, error)
+	ListAccounts(context.Context, *QueryRequest) (*QueryResponse, error)
+	ListAssets(context.Context, *QueryRequest) (*QueryResponse, error)
+	ListBalances(context.Context, *QueryRequest) (*QueryResponse, error)
+	ListUnspentOutputs(context.Context, *QueryRequest) (*QueryResponse, error)
+	// StreamBlocks sends the blocks after the given height as
+	// the Core receives them, until the client cancels the call.
+	StreamBlocks(*StreamBlocksRequest, Node_StreamBlocksServer) error
+}
+
+func RegisterNodeServer(s *grpc.Server, srv NodeServer) {
+	s.RegisterService(&_Node_serviceDesc, srv)
+}
+
+func _Node_Info_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).Info(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chain.core.rpc.pb.Node/Info",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).Info(ctx, req.(*InfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_BuildTxs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BuildTxsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).BuildTxs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chain.core.rpc.pb.Node/BuildTxs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).BuildTxs(ctx, req.(*BuildTxsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_SubmitTxs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitTxsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).SubmitTxs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chain.core.rpc.pb.Node/SubmitTxs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).SubmitTxs(ctx, req.(*SubmitTxsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_ListTxs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).ListTxs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chain.core.rpc.pb.Node/ListTxs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).ListTxs(ctx, req.(*QueryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_ListAccounts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).ListAccounts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chain.core.rpc.pb.Node/ListAccounts",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).ListAccounts(ctx, req.(*QueryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_ListAssets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).ListAssets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chain.core.rpc.pb.Node/ListAssets",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).ListAssets(ctx, req.(*QueryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_ListBalances_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).ListBalances(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chain.core.rpc.pb.Node/ListBalances",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).ListBalances(ctx, req.(*QueryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_ListUnspentOutputs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).ListUnspentOutputs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chain.core.rpc.pb.Node/ListUnspentOutputs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).ListUnspentOutputs(ctx, req.(*QueryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_StreamBlocks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamBlocksRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NodeServer).StreamBlocks(m, &nodeStreamBlocksServer{stream})
+}
+
+type Node_StreamBlocksServer interface {
+	Send(*Block) error
+	grpc.ServerStream
+}
+
+type nodeStreamBlocksServer struct {
+	grpc.ServerStream
+}
+
+func (x *nodeStreamBlocksServer) Send(m *Block) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _Node_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "chain.core.rpc.pb.Node",
+	HandlerType: (*NodeServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Info",
+			Handler:    _Node_Info_Handler,
+		},
+		{
+			MethodName: "BuildTxs",
+			Handler:    _Node_BuildTxs_Handler,
+		},
+		{
+			MethodName: "SubmitTxs",
+			Handler:    _Node_SubmitTxs_Handler,
+		},
+		{
+			MethodName: "ListTxs",
+			Handler:    _Node_ListTxs_Handler,
+		},
+		{
+			MethodName: "ListAccounts",
+			Handler:    _Node_ListAccounts_Handler,
+		},
+		{
+			MethodName: "ListAssets",
+			Handler:    _Node_ListAssets_Handler,
+		},
+		{
+			MethodName: "ListBalances",
+			Handler:    _Node_ListBalances_Handler,
+		},
+		{
+			MethodName: "ListUnspentOutputs",
+			Handler:    _Node_ListUnspentOutputs_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamBlocks",
+			Handler:       _Node_StreamBlocks_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "core.proto",
+}
+
+func init() { proto.RegisterFile("core.proto", fileDescriptor0) }
+
+var fileDescriptor0 = []byte{
+	// 1009 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x56, 0xcf, 0x72, 0x1b, 0x45,
+	0x13, 0xaf, 0xb5, 0x25, 0x6b, 0xd5, 0x5a, 0x97, 0x93, 0x89, 0xe3, 0x6f, 0x3f, 0x01, 0x89, 0xb2,
+	0xa9, 0x02, 0xc1, 0x41, 0x45, 0x39, 0x50, 0x70, 0xc5, 0x54, 0x08, 0x2e, 0x1c, 0xc7, 0x19, 0xdb,
+	0xa4, 0x8a, 0xcb, 0xd6, 0x68, 0x77, 0x2c, 0x0d, 0xde, 0x9d, 0x59, 0x66, 0x66, 0x71, 0x94, 0x0b,
+	0x3c, 0x09, 0x27, 0x1e, 0x84, 0x0b, 0x4f, 0xc2, 0x8b, 0x50, 0xd3, 0xfb, 0x47, 0x72, 0xa2, 0xe0,
+	0x8b, 0x4f, 0x9a, 0xfe, 0x75, 0x4f, 0x4f, 0x77, 0xff, 0xba, 0x5b, 0x0b, 0x90, 0x28, 0xcd, 0x27,
+	0x85, 0x56, 0x56, 0x91, 0xbb, 0xc9, 0x9c, 0x09, 0x39, 0x41, 0x44, 0x17, 0xc9, 0xa4, 0x98, 0x46,
+	0xbf, 0x41, 0xf7, 0xa9, 0xd6, 0x4a, 0x13, 0x02, 0x9d, 0x44, 0xa5, 0x3c, 0xf4, 0x46, 0xde, 0xb8,
+	0x4f, 0xf1, 0x4c, 0x42, 0xe8, 0xe5, 0xdc, 0x18, 0x36, 0xe3, 0xe1, 0x06, 0xc2, 0x8d, 0x48, 0xf6,
+	0x60, 0x2b, 0xe5, 0x96, 0x89, 0x2c, 0xdc, 0x44, 0x45, 0x2d, 0x39, 0x2f, 0x29, 0xb3, 0x2c, 0xec,
+	0x8c, 0xbc, 0x71, 0x40, 0xf1, 0x4c, 0x3e, 0x84, 0xbe, 0xe5, 0x79, 0xa1, 0x34, 0xd3, 0x8b, 0xb0,
+	0x3b, 0xf2, 0xc6, 0x3e, 0x5d, 0x02, 0xd1, 0x36, 0x0c, 0x0e, 0xe5, 0x85, 0xa2, 0xfc, 0x97, 0x92,
+	0x1b, 0x1b, 0xfd, 0xb1, 0x09, 0x41, 0x25, 0x9b, 0x42, 0x49, 0xc3, 0xc9, 0x63, 0xd8, 0x16, 0x26,
+	0x4e, 0x94, 0xbc, 0x10, 0xb3, 0x52, 0xf3, 0x14, 0x03, 0xf4, 0x69, 0x20, 0xcc, 0xb7, 0x2d, 0x46,
+	0x1e, 0x41, 0x20, 0x4c, 0x3c, 0xe3, 0x92, 0x6b, 0x66, 0x95, 0xc6, 0x68, 0x7d, 0x3a, 0x10, 0xe6,
+	0x59, 0x03, 0x91, 0x0f, 0xa0, 0x2f, 0x4c, 0x6c, 0xc4, 0x4c, 0x72, 0x8d, 0x41, 0xfb, 0xd4, 0x17,
+	0xe6, 0x14, 0xe5, 0xfa, 0x91, 0x42, 0xab, 0xb4, 0x4c, 0xac, 0x50, 0x32, 0xec, 0x34, 0x8f, 0x9c,
+	0xb4, 0x98, 0x33, 0x9a, 0x66, 0x2a, 0xb9, 0xc4, 0x22, 0xc6, 0x22, 0xc5, 0x5c, 0xfa, 0x34, 0x58,
+	0x82, 0x87, 0xa9, 0x33, 0x6a, 0xc3, 0x88, 0x4b, 0x9d, 0x85, 0x5b, 0x95, 0x51, 0x0b, 0x9e, 0xeb,
+	0xcc, 0x85, 0x8b, 0x97, 0xe2, 0x39, 0x17, 0xb3, 0xb9, 0x0d, 0x7b, 0x23, 0x6f, 0xdc, 0xa1, 0x03,
+	0xc4, 0xbe, 0x47, 0x88, 0x7c, 0x01, 0x7b, 0x4b, 0x3f, 0xd7, 0x8c, 0x7d, 0x34, 0xde, 0x6d, 0xb5,
+	0x07, 0x2b, 0xb7, 0xfe, 0x07, 0x3d, 0x47, 0xae, 0x0b, 0xae, 0x5f, 0xf1, 0xe2, 0xc4, 0xc3, 0xd4,
+	0x31, 0xf9, 0x2b, 0xd7, 0xc6, 0xa5, 0x06, 0x15, 0x93, 0xb5, 0x48, 0x26, 0x70, 0x4f, 0x72, 0x7b,
+	0xa5, 0xf4, 0x65, 0xac, 0x8b, 0x24, 0x6e, 0xac, 0x06, 0xf8, 0xca, 0xdd, 0x5a, 0x45, 0x8b, 0xe4,
+	0xc7, 0x4a, 0x11, 0xfd, 0xe5, 0xc1, 0xce, 0x41, 0x29, 0xb2, 0xf4, 0xec, 0xb5, 0xa9, 0x49, 0x23,
+	0xdf, 0x81, 0xaf, 0xab, 0xa3, 0x09, 0xbd, 0xd1, 0xe6, 0x78, 0xb0, 0xff, 0xd9, 0xe4, 0x9d, 0x56,
+	0x9b, 0xbc, 0x75, 0x6b, 0x52, 0xff, 0xd2, 0xf6, 0xee, 0x90, 0x43, 0xaf, 0x71, 0xf9, 0x29, 0xdc,
+	0x99, 0x32, 0xc3, 0x63, 0xab, 0x99, 0x34, 0xac, 0x22, 0xc5, 0xc3, 0xa6, 0xda, 0x71, 0xf8, 0xd9,
+	0x12, 0x76, 0xb9, 0x55, 0x27, 0x13, 0x6e, 0x8c, 0x36, 0xc7, 0x01, 0x6d, 0x44, 0x72, 0x1f, 0xb6,
+	0xac, 0xcd, 0xe2, 0xdc, 0x20, 0xe1, 0x1d, 0xda, 0xb5, 0x36, 0x7b, 0x6e, 0xa2, 0xdf, 0x3d, 0xb8,
+	0x73, 0x5a, 0x4e, 0x73, 0x61, 0x57, 0x72, 0x88, 0x20, 0x58, 0x79, 0xab, 0xca, 0x23, 0xa0, 0xd7,
+	0x30, 0xf2, 0x11, 0xc0, 0x15, 0x13, 0x36, 0x2e, 0xa5, 0x15, 0x59, 0x3d, 0x12, 0x7d, 0x87, 0x9c,
+	0x3b, 0xc0, 0xc5, 0x2c, 0x52, 0xd7, 0xd8, 0x96, 0xcb, 0x64, 0x11, 0x5f, 0xf2, 0x85, 0x7b, 0x78,
+	0x73, 0xdc, 0xa7, 0x3b, 0x2b, 0xf8, 0x0f, 0x7c, 0x61, 0xa2, 0x3f, 0x3d, 0x18, 0xe0, 0xe3, 0x75,
+	0x97, 0x3f, 0x85, 0xbe, 0xae, 0xcf, 0x4d, 0x09, 0x3f, 0x59, 0x53, 0xc2, 0x95, 0x2b, 0x93, 0xe6,
+	0x40, 0x97, 0x37, 0x87, 0xc7, 0xe0, 0xb7, 0x2e, 0x27, 0xd0, 0xe5, 0x6e, 0xb2, 0xb1, 0x6c, 0x83,
+	0xfd, 0x70, 0x8d, 0x3b, 0x9c, 0x7c, 0x5a, 0x99, 0xb9, 0xd1, 0xfd, 0xd9, 0x28, 0x89, 0x69, 0x05,
+	0x14, 0xcf, 0xd1, 0xdf, 0x1b, 0x10, 0xbc, 0x2c, 0xb9, 0x5e, 0x34, 0x55, 0xda, 0x83, 0xad, 0x0b,
+	0x91, 0x59, 0xae, 0xeb, 0x3d, 0x51, 0x4b, 0xae, 0xed, 0xab, 0x53, 0x5c, 0x30, 0xcd, 0xf2, 0x86,
+	0x89, 0xa0, 0x02, 0x4f, 0x10, 0x73, 0x74, 0x98, 0x32, 0x8f, 0xa7, 0x8b, 0xba, 0x2a, 0x5d, 0x53,
+	0xe6, 0x07, 0x0b, 0x37, 0x99, 0x05, 0x9b, 0xf1, 0xd8, 0x88, 0x37, 0x1c, 0x07, 0xaf, 0x4b, 0x7d,
+	0x07, 0x9c, 0x8a, 0x37, 0x9c, 0xec, 0x42, 0x97, 0x5d, 0xb8, 0xf7, 0xaa, 0x61, 0xab, 0x04, 0x47,
+	0x84, 0xb1, 0x4c, 0xdb, 0xd8, 0x8a, 0x9c, 0xe3, 0x88, 0x75, 0x68, 0x1f, 0x91, 0x33, 0x91, 0x73,
+	0xf2, 0x7f, 0xf0, 0xb9, 0x4c, 0x2b, 0x65, 0x35, 0x5b, 0x3d, 0x2e, 0x53, 0x54, 0xb9, 0x65, 0x24,
+	0x72, 0x6e, 0x2c, 0xcb, 0x8b, 0x7a, 0x94, 0x96, 0x00, 0xf9, 0x0a, 0x42, 0x66, 0x12, 0x2e, 0x53,
+	0x21, 0x67, 0xf1, 0x95, 0xb0, 0xf3, 0x38, 0x53, 0x72, 0x16, 0x17, 0x2a, 0xcb, 0x70, 0xa0, 0x7c,
+	0x7a, 0xbf, 0xd5, 0xbf, 0x12, 0x76, 0x7e, 0xa4, 0xe4, 0xec, 0x44, 0x65, 0x99, 0x0b, 0xc8, 0x79,
+	0x51, 0xa5, 0x75, 0xdd, 0x06, 0x4b, 0xbf, 0xaa, 0xb4, 0xcf, 0x4d, 0x74, 0x05, 0xdb, 0x75, 0x19,
+	0x6b, 0x72, 0x76, 0xa1, 0x2b, 0x2c, 0xcf, 0x9b, 0x36, 0xab, 0x04, 0xf2, 0x04, 0x3a, 0x92, 0xbf,
+	0xb6, 0x48, 0xc1, 0x60, 0xff, 0xe1, 0x1a, 0xc6, 0x56, 0xc9, 0xa0, 0x68, 0xec, 0xca, 0x97, 0x31,
+	0x63, 0x63, 0x57, 0xb2, 0x66, 0xb1, 0x39, 0xe0, 0x84, 0xcd, 0x78, 0xf4, 0x35, 0xdc, 0x3b, 0xb5,
+	0x9a, 0xb3, 0x1c, 0xb7, 0x44, 0xdb, 0xec, 0x8f, 0x20, 0xc0, 0x42, 0x36, 0x3b, 0xc5, 0xab, 0x16,
+	0x10, 0x62, 0xd5, 0x2a, 0x89, 0xbe, 0x84, 0x2e, 0xde, 0x71, 0x94, 0x5f, 0xb3, 0xaa, 0x25, 0x97,
+	0x02, 0xee, 0xa5, 0xba, 0x61, 0x2a, 0x61, 0xff, 0x9f, 0x2e, 0x74, 0x8e, 0xdd, 0x7f, 0xc7, 0x33,
+	0xe8, 0xb8, 0x3d, 0x4e, 0x1e, 0xac, 0xc9, 0x62, 0x65, 0xe1, 0x0f, 0x1f, 0xbe, 0x57, 0x5f, 0x97,
+	0xea, 0x18, 0xfc, 0x66, 0x73, 0x90, 0xe8, 0xe6, 0xb5, 0x32, 0x7c, 0xf0, 0xdf, 0x73, 0x43, 0x4e,
+	0xa0, 0xdf, 0x0e, 0x3f, 0x79, 0xbc, 0xc6, 0xf8, 0xed, 0xd5, 0x70, 0xa3, 0xc7, 0x23, 0xe8, 0x1d,
+	0x09, 0x83, 0xfe, 0x6e, 0xe2, 0x6c, 0x38, 0x7a, 0xbf, 0x41, 0xed, 0xed, 0x25, 0x04, 0xce, 0xdb,
+	0x37, 0x49, 0xa2, 0x4a, 0x69, 0x6f, 0xc5, 0xe5, 0x0b, 0x00, 0x74, 0x69, 0x0c, 0xb7, 0xb7, 0x19,
+	0xe3, 0x01, 0xcb, 0x98, 0x4c, 0xf8, 0xad, 0xb8, 0x7c, 0x05, 0xc4, 0xb9, 0x3c, 0x97, 0xa6, 0xe0,
+	0xd2, 0xbe, 0x28, 0x6d, 0x51, 0xde, 0x4e, 0xac, 0x14, 0x82, 0xd5, 0x11, 0x20, 0x1f, 0xaf, 0xa3,
+	0xfc, 0xdd, 0x19, 0x19, 0xae, 0x5b, 0x98, 0x68, 0xf1, 0xb9, 0x77, 0xd0, 0xf9, 0x69, 0xa3, 0x98,
+	0x4e, 0xb7, 0xf0, 0xab, 0xea, 0xc9, 0xbf, 0x03, 0x00, 0x7d, 0x99, 0x19, 0xa5, 0x63, 0x09, 0x00,
+	0x00,
+}
@@ -37,6 +37,19 @@ CREATE TYPE access_token_type AS ENUM (
 );
 
 
+--
+-- Name: audit_log_append_only(); Type: FUNCTION; Schema: public; Owner: -
+--
+
+CREATE FUNCTION audit_log_append_only() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+		BEGIN
+			RAISE EXCEPTION 'audit_log is append-only';
+		END;
+		$$;
+
+
 --
 -- Name: b32enc_crockford(bytea); Type: FUNCTION; Schema: public; Owner: -
 --
@@ -141,6 +154,47 @@ END;
 $$;
 
 
+--
+-- Name: query_block_timestamp(bigint); Type: FUNCTION; Schema: public; Owner: -
+--
+
+CREATE FUNCTION query_block_timestamp(block_height bigint) RETURNS bigint
+    LANGUAGE plpgsql STABLE
+    AS $$
+		BEGIN
+			RETURN (SELECT timestamp FROM query_blocks WHERE height = block_height);
+		END;
+		$$;
+
+
+--
+-- Name: query_spend_outputs(bigint, bytea[]); Type: FUNCTION; Schema: public; Owner: -
+--
+
+CREATE FUNCTION query_spend_outputs(spent_ms bigint, output_ids bytea[]) RETURNS void
+    LANGUAGE plpgsql
+    AS $$
+		BEGIN
+			UPDATE annotated_outputs SET timespan = INT8RANGE(LOWER(timespan), spent_ms)
+			WHERE output_id = ANY(output_ids);
+		END;
+		$$;
+
+
+--
+-- Name: query_tx_after(bigint, bigint); Type: FUNCTION; Schema: public; Owner: -
+--
+
+CREATE FUNCTION query_tx_after(begin_ms bigint, end_ms bigint, OUT from_height bigint, OUT stop_height bigint) RETURNS record
+    LANGUAGE plpgsql STABLE
+    AS $$
+		BEGIN
+			SELECT COALESCE(MAX(height), 0), COALESCE(MIN(height), 0) INTO from_height, stop_height
+			FROM query_blocks WHERE timestamp >= begin_ms AND timestamp <= end_ms;
+		END;
+		$$;
+
+
 SET default_tablespace = '';
 
 SET default_with_oids = false;
@@ -153,8 +207,28 @@ CREATE TABLE access_tokens (
     id text NOT NULL,
     sort_id text DEFAULT next_chain_id('at'::text),
     type access_token_type NOT NULL,
-    hashed_secret bytea NOT NULL,
-    created timestamp with time zone DEFAULT now() NOT NULL
+    hashed_secret bytea,
+    created timestamp with time zone DEFAULT now() NOT NULL,
+    expires_at timestamp with time zone,
+    scopes text[],
+    secret_hash bytea,
+    last_used_at timestamp with time zone,
+    last_used_ip text,
+    request_count bigint DEFAULT 0 NOT NULL,
+    secret_salt bytea
+);
+
+
+--
+-- Name: account_balances; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE account_balances (
+    account_id text NOT NULL,
+    asset_id bytea NOT NULL,
+    block_height bigint NOT NULL,
+    block_timestamp bigint NOT NULL,
+    amount bigint NOT NULL
 );
 
 
@@ -183,6 +257,40 @@ CREATE TABLE account_control_programs (
 );
 
 
+--
+-- Name: account_events; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE account_events (
+    seq bigint NOT NULL,
+    type text NOT NULL,
+    account_id text NOT NULL,
+    tx_hash bytea NOT NULL,
+    output_id bytea DEFAULT '\x'::bytea NOT NULL,
+    block_height bigint DEFAULT 0 NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: account_events_seq_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE account_events_seq_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: account_events_seq_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE account_events_seq_seq OWNED BY account_events.seq;
+
+
 --
 -- Name: account_utxos; Type: TABLE; Schema: public; Owner: -
 --
@@ -209,7 +317,9 @@ CREATE TABLE account_utxos (
 CREATE TABLE accounts (
     account_id text NOT NULL,
     tags jsonb,
-    alias text
+    alias text,
+    next_receiver_index bigint DEFAULT 0 NOT NULL,
+    deleted_at timestamp with time zone
 );
 
 
@@ -222,7 +332,8 @@ CREATE TABLE annotated_accounts (
     alias text NOT NULL,
     keys jsonb NOT NULL,
     quorum integer NOT NULL,
-    tags jsonb NOT NULL
+    tags jsonb NOT NULL,
+    deleted boolean DEFAULT false NOT NULL
 );
 
 
@@ -239,7 +350,8 @@ CREATE TABLE annotated_assets (
     quorum integer NOT NULL,
     definition jsonb NOT NULL,
     tags jsonb NOT NULL,
-    local boolean NOT NULL
+    local boolean NOT NULL,
+    definition_version bigint DEFAULT 0 NOT NULL
 );
 
 
@@ -291,7 +403,8 @@ CREATE TABLE annotated_outputs (
     account_tags jsonb,
     control_program bytea NOT NULL,
     reference_data jsonb NOT NULL,
-    local boolean NOT NULL
+    local boolean NOT NULL,
+    asset_lineage_id bytea NOT NULL
 );
 
 
@@ -311,6 +424,43 @@ CREATE TABLE annotated_txs (
 );
 
 
+--
+-- Name: asset_definition_versions; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE asset_definition_versions (
+    asset_id bytea NOT NULL,
+    version bigint NOT NULL,
+    definition bytea NOT NULL,
+    tx_hash bytea NOT NULL,
+    block_height bigint NOT NULL
+);
+
+
+--
+-- Name: asset_issuance_policies; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE asset_issuance_policies (
+    asset_id bytea NOT NULL,
+    max_outstanding bigint DEFAULT 0 NOT NULL,
+    tokens text[] DEFAULT '{}'::text[] NOT NULL,
+    max_per_hour bigint DEFAULT 0 NOT NULL
+);
+
+
+--
+-- Name: asset_successions; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE asset_successions (
+    predecessor_id bytea NOT NULL,
+    successor_id bytea NOT NULL,
+    tx_hash bytea NOT NULL,
+    block_height bigint NOT NULL
+);
+
+
 --
 -- Name: asset_tags; Type: TABLE; Schema: public; Owner: -
 --
@@ -352,6 +502,33 @@ CREATE SEQUENCE assets_key_index_seq
     CACHE 1;
 
 
+--
+-- Name: audit_log; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE audit_log (
+    seq bigint NOT NULL,
+    "timestamp" timestamp with time zone NOT NULL,
+    actor text NOT NULL,
+    action text NOT NULL,
+    detail bytea NOT NULL,
+    prev_hash bytea NOT NULL,
+    hash bytea NOT NULL
+);
+
+
+--
+-- Name: authz_grants; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE authz_grants (
+    guard_type text NOT NULL,
+    guard_data jsonb NOT NULL,
+    policy text NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
 --
 -- Name: block_processors; Type: TABLE; Schema: public; Owner: -
 --
@@ -404,10 +581,54 @@ CREATE TABLE config (
     id text NOT NULL,
     block_hsm_url text DEFAULT ''::text,
     block_hsm_access_token text DEFAULT ''::text,
+    final_block_height bigint DEFAULT 0 NOT NULL,
+    block_period_ms bigint DEFAULT 0 NOT NULL,
     CONSTRAINT config_singleton CHECK (singleton)
 );
 
 
+--
+-- Name: config_history; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE config_history (
+    version bigint NOT NULL,
+    saved_at timestamp with time zone DEFAULT now() NOT NULL,
+    data jsonb NOT NULL
+);
+
+
+--
+-- Name: config_history_version_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE config_history_version_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: config_history_version_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE config_history_version_seq OWNED BY config_history.version;
+
+
+--
+-- Name: generator_idempotency_keys; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE generator_idempotency_keys (
+    submitter text NOT NULL,
+    key text NOT NULL,
+    tx_hash bytea NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
 --
 -- Name: generator_pending_block; Type: TABLE; Schema: public; Owner: -
 --
@@ -432,6 +653,38 @@ CREATE TABLE leader (
 );
 
 
+--
+-- Name: mempool_txs; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE mempool_txs (
+    tx_hash bytea NOT NULL,
+    data bytea NOT NULL,
+    added_at timestamp with time zone NOT NULL,
+    seq bigint NOT NULL,
+    submitter text DEFAULT ''::text NOT NULL
+);
+
+
+--
+-- Name: mempool_txs_seq_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE mempool_txs_seq_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: mempool_txs_seq_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE mempool_txs_seq_seq OWNED BY mempool_txs.seq;
+
+
 --
 -- Name: migrations; Type: TABLE; Schema: public; Owner: -
 --
@@ -468,6 +721,30 @@ CREATE TABLE mockhsm (
 );
 
 
+--
+-- Name: mockhsm_key_policies; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE mockhsm_key_policies (
+    pub bytea NOT NULL,
+    tokens text[] DEFAULT '{}'::text[] NOT NULL,
+    purposes text[] DEFAULT '{}'::text[] NOT NULL,
+    max_per_minute integer DEFAULT 0 NOT NULL
+);
+
+
+--
+-- Name: mockhsm_key_versions; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE mockhsm_key_versions (
+    alias text NOT NULL,
+    version integer NOT NULL,
+    pub bytea NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
 --
 -- Name: query_blocks; Type: TABLE; Schema: public; Owner: -
 --
@@ -478,6 +755,21 @@ CREATE TABLE query_blocks (
 );
 
 
+--
+-- Name: query_reindex; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE query_reindex (
+    singleton boolean DEFAULT true NOT NULL,
+    requested_at timestamp with time zone DEFAULT now() NOT NULL,
+    started_at timestamp with time zone,
+    finished_at timestamp with time zone,
+    height bigint DEFAULT 0 NOT NULL,
+    error text DEFAULT ''::text NOT NULL,
+    CONSTRAINT query_reindex_singleton CHECK (singleton)
+);
+
+
 --
 -- Name: signed_blocks; Type: TABLE; Schema: public; Owner: -
 --
@@ -488,6 +780,20 @@ CREATE TABLE signed_blocks (
 );
 
 
+--
+-- Name: signer_rotations; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE signer_rotations (
+    height bigint NOT NULL,
+    signers jsonb NOT NULL,
+    quorum integer NOT NULL,
+    program bytea NOT NULL,
+    proposed_at timestamp with time zone DEFAULT now() NOT NULL,
+    applied_block_hash bytea
+);
+
+
 --
 -- Name: signers; Type: TABLE; Schema: public; Owner: -
 --
@@ -521,6 +827,18 @@ CREATE SEQUENCE signers_key_index_seq
 ALTER SEQUENCE signers_key_index_seq OWNED BY signers.key_index;
 
 
+--
+-- Name: snapshot_diffs; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE snapshot_diffs (
+    height bigint NOT NULL,
+    base_height bigint NOT NULL,
+    data bytea NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
 --
 -- Name: snapshots; Type: TABLE; Schema: public; Owner: -
 --
@@ -556,6 +874,67 @@ CREATE TABLE txfeeds (
 );
 
 
+--
+-- Name: webhook_dead_letters; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE webhook_dead_letters (
+    id bigint NOT NULL,
+    webhook_id text NOT NULL,
+    after text NOT NULL,
+    payload text NOT NULL,
+    attempts integer NOT NULL,
+    error text NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: webhook_dead_letters_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE webhook_dead_letters_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: webhook_dead_letters_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE webhook_dead_letters_id_seq OWNED BY webhook_dead_letters.id;
+
+
+--
+-- Name: webhooks; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE webhooks (
+    id text DEFAULT next_chain_id('whk'::text) NOT NULL,
+    alias text,
+    url text NOT NULL,
+    filter text NOT NULL,
+    secret text NOT NULL,
+    after text NOT NULL,
+    attempts integer DEFAULT 0 NOT NULL,
+    next_attempt_at timestamp with time zone DEFAULT now() NOT NULL,
+    last_error text DEFAULT ''::text NOT NULL,
+    client_token text,
+    previous_secret text DEFAULT ''::text NOT NULL,
+    previous_secret_expires_at timestamp with time zone
+);
+
+
+--
+-- Name: id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY webhook_dead_letters ALTER COLUMN id SET DEFAULT nextval('webhook_dead_letters_id_seq'::regclass);
+
+
 --
 -- Name: key_index; Type: DEFAULT; Schema: public; Owner: -
 --
@@ -563,6 +942,27 @@ CREATE TABLE txfeeds (
 ALTER TABLE ONLY signers ALTER COLUMN key_index SET DEFAULT nextval('signers_key_index_seq'::regclass);
 
 
+--
+-- Name: seq; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY mempool_txs ALTER COLUMN seq SET DEFAULT nextval('mempool_txs_seq_seq'::regclass);
+
+
+--
+-- Name: seq; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY account_events ALTER COLUMN seq SET DEFAULT nextval('account_events_seq_seq'::regclass);
+
+
+--
+-- Name: version; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY config_history ALTER COLUMN version SET DEFAULT nextval('config_history_version_seq'::regclass);
+
+
 --
 -- Name: access_tokens_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -571,6 +971,14 @@ ALTER TABLE ONLY access_tokens
     ADD CONSTRAINT access_tokens_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: account_balances_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY account_balances
+    ADD CONSTRAINT account_balances_pkey PRIMARY KEY (account_id, asset_id, block_height);
+
+
 --
 -- Name: account_control_programs_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -579,6 +987,22 @@ ALTER TABLE ONLY account_control_programs
     ADD CONSTRAINT account_control_programs_pkey PRIMARY KEY (control_program);
 
 
+--
+-- Name: account_events_account_id_type_tx_hash_output_id_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY account_events
+    ADD CONSTRAINT account_events_account_id_type_tx_hash_output_id_key UNIQUE (account_id, type, tx_hash, output_id);
+
+
+--
+-- Name: account_events_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY account_events
+    ADD CONSTRAINT account_events_pkey PRIMARY KEY (seq);
+
+
 --
 -- Name: account_tags_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -659,6 +1083,46 @@ ALTER TABLE ONLY annotated_txs
     ADD CONSTRAINT annotated_txs_pkey PRIMARY KEY (block_height, tx_pos);
 
 
+--
+-- Name: asset_definition_versions_asset_id_tx_hash_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY asset_definition_versions
+    ADD CONSTRAINT asset_definition_versions_asset_id_tx_hash_key UNIQUE (asset_id, tx_hash);
+
+
+--
+-- Name: asset_definition_versions_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY asset_definition_versions
+    ADD CONSTRAINT asset_definition_versions_pkey PRIMARY KEY (asset_id, version);
+
+
+--
+-- Name: asset_issuance_policies_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY asset_issuance_policies
+    ADD CONSTRAINT asset_issuance_policies_pkey PRIMARY KEY (asset_id);
+
+
+--
+-- Name: asset_successions_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY asset_successions
+    ADD CONSTRAINT asset_successions_pkey PRIMARY KEY (predecessor_id);
+
+
+--
+-- Name: asset_successions_successor_id_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY asset_successions
+    ADD CONSTRAINT asset_successions_successor_id_key UNIQUE (successor_id);
+
+
 --
 -- Name: asset_tags_asset_id_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -691,6 +1155,22 @@ ALTER TABLE ONLY assets
     ADD CONSTRAINT assets_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: audit_log_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY audit_log
+    ADD CONSTRAINT audit_log_pkey PRIMARY KEY (seq);
+
+
+--
+-- Name: authz_grants_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY authz_grants
+    ADD CONSTRAINT authz_grants_pkey PRIMARY KEY (guard_type, guard_data, policy);
+
+
 --
 -- Name: block_processors_name_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -715,6 +1195,14 @@ ALTER TABLE ONLY blocks
     ADD CONSTRAINT blocks_pkey PRIMARY KEY (block_hash);
 
 
+--
+-- Name: config_history_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY config_history
+    ADD CONSTRAINT config_history_pkey PRIMARY KEY (version);
+
+
 --
 -- Name: config_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -723,6 +1211,14 @@ ALTER TABLE ONLY config
     ADD CONSTRAINT config_pkey PRIMARY KEY (singleton);
 
 
+--
+-- Name: generator_idempotency_keys_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY generator_idempotency_keys
+    ADD CONSTRAINT generator_idempotency_keys_pkey PRIMARY KEY (submitter, key);
+
+
 --
 -- Name: generator_pending_block_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -739,6 +1235,14 @@ ALTER TABLE ONLY leader
     ADD CONSTRAINT leader_singleton_key UNIQUE (singleton);
 
 
+--
+-- Name: mempool_txs_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY mempool_txs
+    ADD CONSTRAINT mempool_txs_pkey PRIMARY KEY (tx_hash);
+
+
 --
 -- Name: migrations_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -755,6 +1259,30 @@ ALTER TABLE ONLY mockhsm
     ADD CONSTRAINT mockhsm_alias_key UNIQUE (alias);
 
 
+--
+-- Name: mockhsm_key_policies_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY mockhsm_key_policies
+    ADD CONSTRAINT mockhsm_key_policies_pkey PRIMARY KEY (pub);
+
+
+--
+-- Name: mockhsm_key_versions_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY mockhsm_key_versions
+    ADD CONSTRAINT mockhsm_key_versions_pkey PRIMARY KEY (alias, version);
+
+
+--
+-- Name: mockhsm_key_versions_pub_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY mockhsm_key_versions
+    ADD CONSTRAINT mockhsm_key_versions_pub_key UNIQUE (pub);
+
+
 --
 -- Name: mockhsm_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -771,6 +1299,22 @@ ALTER TABLE ONLY query_blocks
     ADD CONSTRAINT query_blocks_pkey PRIMARY KEY (height);
 
 
+--
+-- Name: query_reindex_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY query_reindex
+    ADD CONSTRAINT query_reindex_pkey PRIMARY KEY (singleton);
+
+
+--
+-- Name: signer_rotations_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY signer_rotations
+    ADD CONSTRAINT signer_rotations_pkey PRIMARY KEY (height);
+
+
 --
 -- Name: signers_client_token_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -787,6 +1331,14 @@ ALTER TABLE ONLY signers
     ADD CONSTRAINT signers_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: snapshot_diffs_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY snapshot_diffs
+    ADD CONSTRAINT snapshot_diffs_pkey PRIMARY KEY (height);
+
+
 --
 -- Name: sort_id_index; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -835,6 +1387,52 @@ ALTER TABLE ONLY txfeeds
     ADD CONSTRAINT txfeeds_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: webhook_dead_letters_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY webhook_dead_letters
+    ADD CONSTRAINT webhook_dead_letters_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: webhooks_alias_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY webhooks
+    ADD CONSTRAINT webhooks_alias_key UNIQUE (alias);
+
+
+--
+-- Name: webhooks_client_token_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY webhooks
+    ADD CONSTRAINT webhooks_client_token_key UNIQUE (client_token);
+
+
+--
+-- Name: webhooks_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY webhooks
+    ADD CONSTRAINT webhooks_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: account_balances_timestamp_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX account_balances_timestamp_idx ON account_balances USING btree (account_id, asset_id, block_timestamp);
+
+
+--
+-- Name: account_events_account_id_seq_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX account_events_account_id_seq_idx ON account_events USING btree (account_id, seq);
+
+
 --
 -- Name: account_utxos_asset_id_account_id_confirmed_in_idx; Type: INDEX; Schema: public; Owner: -
 --
@@ -849,6 +1447,20 @@ CREATE INDEX account_utxos_asset_id_account_id_confirmed_in_idx ON account_utxos
 CREATE INDEX annotated_assets_sort_id ON annotated_assets USING btree (sort_id);
 
 
+--
+-- Name: annotated_outputs_asset_lineage_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX annotated_outputs_asset_lineage_id_idx ON annotated_outputs USING btree (asset_lineage_id);
+
+
+--
+-- Name: annotated_outputs_control_program_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX annotated_outputs_control_program_idx ON annotated_outputs USING btree (control_program);
+
+
 --
 -- Name: annotated_outputs_timespan_idx; Type: INDEX; Schema: public; Owner: -
 --
@@ -863,6 +1475,20 @@ CREATE INDEX annotated_outputs_timespan_idx ON annotated_outputs USING gist (tim
 CREATE INDEX annotated_txs_data_idx ON annotated_txs USING gin (data jsonb_path_ops);
 
 
+--
+-- Name: asset_definition_versions_block_height_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX asset_definition_versions_block_height_idx ON asset_definition_versions USING btree (block_height);
+
+
+--
+-- Name: asset_successions_block_height_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX asset_successions_block_height_idx ON asset_successions USING btree (block_height);
+
+
 --
 -- Name: assets_sort_id; Type: INDEX; Schema: public; Owner: -
 --
@@ -891,6 +1517,52 @@ CREATE UNIQUE INDEX signed_blocks_block_height_idx ON signed_blocks USING btree
 CREATE INDEX signers_type_id_idx ON signers USING btree (type, id);
 
 
+--
+-- Name: audit_log_append_only; Type: TRIGGER; Schema: public; Owner: -
+--
+
+CREATE TRIGGER audit_log_append_only BEFORE DELETE OR UPDATE ON audit_log FOR EACH ROW EXECUTE PROCEDURE audit_log_append_only();
+
+
+--
+-- Name: audit_log_no_truncate; Type: TRIGGER; Schema: public; Owner: -
+--
+
+CREATE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON audit_log FOR EACH STATEMENT EXECUTE PROCEDURE audit_log_append_only();
+
+
+--
+-- Name: asset_definition_versions_asset_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY asset_definition_versions
+    ADD CONSTRAINT asset_definition_versions_asset_id_fkey FOREIGN KEY (asset_id) REFERENCES assets(id) ON DELETE CASCADE;
+
+
+--
+-- Name: asset_issuance_policies_asset_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY asset_issuance_policies
+    ADD CONSTRAINT asset_issuance_policies_asset_id_fkey FOREIGN KEY (asset_id) REFERENCES assets(id) ON DELETE CASCADE;
+
+
+--
+-- Name: mockhsm_key_policies_pub_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY mockhsm_key_policies
+    ADD CONSTRAINT mockhsm_key_policies_pub_fkey FOREIGN KEY (pub) REFERENCES mockhsm(pub) ON DELETE CASCADE;
+
+
+--
+-- Name: webhook_dead_letters_webhook_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY webhook_dead_letters
+    ADD CONSTRAINT webhook_dead_letters_webhook_id_fkey FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE;
+
+
 --
 -- PostgreSQL database dump complete
 --
@@ -901,3 +1573,31 @@ insert into migrations (filename, hash) values ('2017-02-16.0.query.spent-output
 insert into migrations (filename, hash) values ('2017-02-28.0.core.remove-outpoints.sql', '067638e2a826eac70d548f2d6bb234660f3200064072baf42db741456ecf8deb');
 insert into migrations (filename, hash) values ('2017-03-02.0.core.add-output-source-info.sql', 'f44c7cfbff346f6f797d497910c0a76f2a7600ca8b5be4fe4e4a04feaf32e0df');
 insert into migrations (filename, hash) values ('2017-03-09.0.core.account-utxos-change.sql', 'a99e0e41be3da126a8c47151454098669334bf7e30de6cd539ba535add4e85d1');
+insert into migrations (filename, hash) values ('2017-03-20.0.core.config-final-block-height.sql', 'd7885a0aa32845b4576eee25360287b26f7a09b7ec4e404aba7fea9cb01602a6');
+insert into migrations (filename, hash) values ('2017-03-21.0.core.mempool-txs.sql', '8df5a110733533d768bee433cea91298e3a177a427bda278d453a6cc277bf65a');
+insert into migrations (filename, hash) values ('2017-03-22.0.core.account-events.sql', 'fbdfbd7aa1066a52e3d5d409de8edf2249b9ddedc6f776749b192633be269876');
+insert into migrations (filename, hash) values ('2017-03-23.0.core.snapshot-diffs.sql', 'aa158f3e602790e7399c1744c90bac9e414a30cc99cb2537e8b695fac4289a6f');
+insert into migrations (filename, hash) values ('2017-03-24.0.core.asset-successions.sql', 'c28e7ac03dbbaaeee0f2832e2a27632918f7c7cee4bc2990b31d4b9cb0c13518');
+insert into migrations (filename, hash) values ('2017-03-25.0.core.query-functions.sql', 'c30479b24ee4d72e17a1b3817ab0e36b23e47fafe804230325067a0f2055c774');
+insert into migrations (filename, hash) values ('2017-03-27.0.core.access-token-expiry.sql', '1450915930263f7fb2ecc972d39aaf9ba12fc378d23bcfdc3a39d4337cd87e32');
+insert into migrations (filename, hash) values ('2017-03-28.0.core.access-token-scopes.sql', '2a2fa5566aab0df585bb0eeff26ea291eed450bcf5d759d456a3b82e6388ab3f');
+insert into migrations (filename, hash) values ('2017-03-29.0.core.access-token-salted-secret.sql', '0e3f476592ecd7f41521f9f8364c9c8aef438e30eeae83086a3309f681200e89');
+insert into migrations (filename, hash) values ('2017-03-30.0.core.access-token-usage.sql', '87dba913eb86d7e5f97ca79c1dec75b27c0b3c385416085473bd5ea267eb0d1b');
+insert into migrations (filename, hash) values ('2017-03-31.0.core.config-history.sql', '71c2b54dcaeb636eb16d3a5620b838eea0e46d3774efa7ccf87c0358888541be');
+insert into migrations (filename, hash) values ('2017-04-01.0.core.config-block-period.sql', 'd0281e5077472595b6b176b1982872ffcab5967546b315d8be5a1cba8eeb8df3');
+insert into migrations (filename, hash) values ('2017-04-02.0.core.signer-rotations.sql', '72bf7dbd744a048816e831ef72d16efd1a0ea1a7ff53282199ef567c589e4997');
+insert into migrations (filename, hash) values ('2017-04-03.0.core.mockhsm-key-versions.sql', '4a19c9ac0e430cebc19b5a4589b071980f8112c5dc1427b57afad5276e95cab7');
+insert into migrations (filename, hash) values ('2017-04-05.0.core.mockhsm-key-policies.sql', '3573fba1651cacd74cc86cb8e20b6b0210e7dfaa62a18004115bffce0bbe2a66');
+insert into migrations (filename, hash) values ('2017-04-06.0.core.mempool-submitter.sql', '9acc9c4cec2a816332f3cb48b960c9826a0ba7361f404033932be396087dd9c1');
+insert into migrations (filename, hash) values ('2017-04-07.0.core.generator-idempotency-keys.sql', 'e9386b19fc98b96b945f78b9a27074e19fc9188650ed7004129e6af2535fefe2');
+insert into migrations (filename, hash) values ('2017-04-08.0.query.account-balances.sql', '37e23d78331a840edf19972e7d3b4c76d9ad4b12eb6bc0d961270904e13b4113');
+insert into migrations (filename, hash) values ('2017-04-09.0.query.reindex.sql', '4d831a4a6a3891a05a531324308926c36d4c229e0fe63ac5e7a0e4b792dcefde');
+insert into migrations (filename, hash) values ('2017-04-10.0.core.webhooks.sql', 'e82d8bee6bd504fb96a49820fda0b9519b6e0053dab620aea4decb75d9b4c824');
+insert into migrations (filename, hash) values ('2017-04-10.1.core.webhook-secret-rotation.sql', '0380da7a5109218c9d7e9e6ae36dad7ba285cf0449566d536c95dd979408a4a7');
+insert into migrations (filename, hash) values ('2017-04-11.0.account.receiver-index.sql', '96ab9ee8194304dc81d2768f6b72dc5a6e96e0f12a882b84709f5d4b7ccc3471');
+insert into migrations (filename, hash) values ('2017-04-12.0.account.soft-delete.sql', '32ab01c33b63f10b226883804c9f07dd34e422222a51360fe0c5dcf5cddace68');
+insert into migrations (filename, hash) values ('2017-04-13.0.asset.issuance-policies.sql', 'ee35ec385effeb9dc61049755257588d1a5694835a2c403db429c3a376fa116e');
+insert into migrations (filename, hash) values ('2017-04-14.0.asset.definition-versions.sql', 'a77bc75820712b302cc78fa3b7082c15675d2568f1e7452536a04025d8aa12e5');
+insert into migrations (filename, hash) values ('2017-04-15.0.core.authz-grants.sql', '83f16b3e5adf058298e500ab4f5936838eed328d252ed77ad5364b08a6134138');
+insert into migrations (filename, hash) values ('2017-04-16.0.core.audit-log.sql', 'c2245d413f63735ff93d5271f0d6010e871beaa465670b48a8bfe6c72a55b308');
+insert into migrations (filename, hash) values ('2017-04-17.0.core.access-token-hmac.sql', '3680d7b11631b6e167f4ef582c8c5100bbbbabfabbc8c8739f21cb5bca120acd');
@@ -5,7 +5,6 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
-	"encoding/binary"
 	"sort"
 
 	"github.com/lib/pq"
@@ -18,8 +17,8 @@ import (
 type keySpace byte
 
 const (
-	AssetKeySpace   keySpace = 0
-	AccountKeySpace keySpace = 1
+	AssetKeySpace   = keySpace(chainkd.AssetKeySpace)
+	AccountKeySpace = keySpace(chainkd.AccountKeySpace)
 )
 
 var typeIDMap = map[string]string{
@@ -63,15 +62,8 @@ type Signer struct {
 
 // Path returns the complete path for derived keys
 func Path(s *Signer, ks keySpace, itemIndexes ...uint64) [][]byte {
-	var path [][]byte
-	signerPath := [9]byte{byte(ks)}
-	binary.LittleEndian.PutUint64(signerPath[1:], s.KeyIndex)
-	path = append(path, signerPath[:])
-	for _, idx := range itemIndexes {
-		var idxBytes [8]byte
-		binary.LittleEndian.PutUint64(idxBytes[:], idx)
-		path = append(path, idxBytes[:])
-	}
+	// A signer path has no hardened steps, so Selectors can't fail.
+	path, _ := chainkd.SignerPath(byte(ks), s.KeyIndex, itemIndexes...).Selectors()
 	return path
 }
 
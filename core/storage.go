@@ -0,0 +1,208 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"chain/database/pg"
+	"chain/errors"
+)
+
+// storageSampleBlocks is the number of recent blocks
+// used to estimate storage growth.
+const storageSampleBlocks = 1000
+
+// storageProjections are the horizons reported by /storage-usage.
+var storageProjections = []struct {
+	name string
+	d    time.Duration
+}{
+	{"30d", 30 * 24 * time.Hour},
+	{"90d", 90 * 24 * time.Hour},
+	{"365d", 365 * 24 * time.Hour},
+}
+
+// subsystemTables maps each storage subsystem to the tables it owns.
+// Tables not listed here, which hold configuration, access control,
+// and migration records and stay small, are reported under "other".
+// This core has no raft log; its state lives entirely in Postgres.
+var subsystemTables = map[string][]string{
+	"blocks": {
+		"blocks",
+		"snapshots",
+		"snapshot_diffs",
+		"signed_blocks",
+		"generator_pending_block",
+		"mempool_txs",
+		"submitted_txs",
+		"generator_idempotency_keys",
+	},
+	"index": {
+		"annotated_accounts",
+		"annotated_assets",
+		"annotated_inputs",
+		"annotated_outputs",
+		"account_balances",
+		"annotated_txs",
+		"query_blocks",
+		"query_reindex",
+		"account_utxos",
+		"account_events",
+		"account_control_programs",
+		"accounts",
+		"assets",
+		"asset_tags",
+		"asset_successions",
+		"asset_definition_versions",
+		"asset_issuance_policies",
+		"block_processors",
+		"signers",
+		"txfeeds",
+		"webhooks",
+		"webhook_dead_letters",
+	},
+	"mockhsm": {"mockhsm", "mockhsm_key_versions", "mockhsm_key_policies"},
+	"audit":   {"audit_log"},
+}
+
+type tableUsage struct {
+	Name       string `json:"name"`
+	Subsystem  string `json:"subsystem"`
+	TotalBytes int64  `json:"total_bytes"`
+	IndexBytes int64  `json:"index_bytes"`
+	Rows       int64  `json:"estimated_rows"`
+}
+
+type subsystemUsage struct {
+	TotalBytes     int64            `json:"total_bytes"`
+	BytesPerDay    float64          `json:"bytes_per_day"`
+	ProjectedBytes map[string]int64 `json:"projected_bytes"`
+}
+
+type growthSample struct {
+	Blocks        int     `json:"blocks"`
+	AvgBlockBytes float64 `json:"avg_block_bytes"`
+	BlocksPerDay  float64 `json:"blocks_per_day"`
+}
+
+type storageReport struct {
+	TotalBytes int64                      `json:"total_bytes"`
+	Subsystems map[string]*subsystemUsage `json:"subsystems"`
+	Tables     []tableUsage               `json:"tables"`
+	Growth     growthSample               `json:"growth"`
+}
+
+// POST /storage-usage
+//
+// storageUsage reports the space used by each table and subsystem,
+// with projections of future usage extrapolated from the size and
+// rate of recent blocks. Block storage grows with the block data
+// itself; the other subsystems are assumed to grow in proportion
+// to their current size relative to block storage.
+func (a *API) storageUsage(ctx context.Context) (*storageReport, error) {
+	tables, err := tableSizes(ctx, a.DB)
+	if err != nil {
+		return nil, err
+	}
+	growth, err := recentGrowth(ctx, a.DB, storageSampleBlocks)
+	if err != nil {
+		return nil, err
+	}
+	return newStorageReport(tables, growth), nil
+}
+
+func tableSizes(ctx context.Context, db pg.DB) ([]tableUsage, error) {
+	const q = `
+		SELECT c.relname, pg_total_relation_size(c.oid), pg_indexes_size(c.oid), c.reltuples::bigint
+		FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind = 'r' AND n.nspname = current_schema()
+		ORDER BY c.relname
+	`
+	var tables []tableUsage
+	err := pg.ForQueryRows(ctx, db, q, func(name string, total, index, rows int64) {
+		tables = append(tables, tableUsage{
+			Name:       name,
+			Subsystem:  tableSubsystem(name),
+			TotalBytes: total,
+			IndexBytes: index,
+			Rows:       rows,
+		})
+	})
+	return tables, errors.Wrap(err, "querying table sizes")
+}
+
+// recentGrowth samples the size and timestamps of
+// the most recent n blocks.
+func recentGrowth(ctx context.Context, db pg.DB, n int) (growthSample, error) {
+	const q = `
+		SELECT COUNT(*), COALESCE(AVG(size), 0), COALESCE(MIN(ts), 0), COALESCE(MAX(ts), 0) FROM (
+			SELECT octet_length(b.data) AS size, q.timestamp AS ts
+			FROM blocks b JOIN query_blocks q ON q.height = b.height
+			ORDER BY b.height DESC LIMIT $1
+		) recent
+	`
+	var (
+		g            growthSample
+		minMS, maxMS int64
+	)
+	err := db.QueryRow(ctx, q, n).Scan(&g.Blocks, &g.AvgBlockBytes, &minMS, &maxMS)
+	if err != nil {
+		return g, errors.Wrap(err, "sampling recent blocks")
+	}
+	if g.Blocks > 1 && maxMS > minMS {
+		span := time.Duration(maxMS-minMS) * time.Millisecond
+		g.BlocksPerDay = float64(g.Blocks-1) * float64(24*time.Hour) / float64(span)
+	}
+	return g, nil
+}
+
+func newStorageReport(tables []tableUsage, g growthSample) *storageReport {
+	r := &storageReport{
+		Subsystems: make(map[string]*subsystemUsage),
+		Tables:     tables,
+		Growth:     g,
+	}
+	for _, t := range tables {
+		s := r.Subsystems[t.Subsystem]
+		if s == nil {
+			s = &subsystemUsage{ProjectedBytes: make(map[string]int64)}
+			r.Subsystems[t.Subsystem] = s
+		}
+		s.TotalBytes += t.TotalBytes
+		r.TotalBytes += t.TotalBytes
+	}
+
+	blockRate := g.AvgBlockBytes * g.BlocksPerDay
+	var blockBytes int64
+	if s := r.Subsystems["blocks"]; s != nil {
+		blockBytes = s.TotalBytes
+	}
+	for name, s := range r.Subsystems {
+		switch {
+		case name == "blocks":
+			s.BytesPerDay = blockRate
+		case blockBytes > 0:
+			s.BytesPerDay = blockRate * float64(s.TotalBytes) / float64(blockBytes)
+		}
+		for _, p := range storageProjections {
+			days := float64(p.d) / float64(24*time.Hour)
+			s.ProjectedBytes[p.name] = s.TotalBytes + int64(s.BytesPerDay*days)
+		}
+	}
+	return r
+}
+
+func tableSubsystem(table string) string {
+	for sub, tables := range subsystemTables {
+		for _, t := range tables {
+			if t == table {
+				return sub
+			}
+		}
+	}
+	if strings.HasPrefix(table, "annotated_") {
+		return "index"
+	}
+	return "other"
+}
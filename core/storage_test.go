@@ -0,0 +1,40 @@
+package core
+
+import "testing"
+
+func TestStorageReport(t *testing.T) {
+	tables := []tableUsage{
+		{Name: "blocks", Subsystem: tableSubsystem("blocks"), TotalBytes: 1000},
+		{Name: "annotated_txs", Subsystem: tableSubsystem("annotated_txs"), TotalBytes: 2000},
+		{Name: "mockhsm", Subsystem: tableSubsystem("mockhsm"), TotalBytes: 10},
+		{Name: "mockhsm_key_policies", Subsystem: tableSubsystem("mockhsm_key_policies"), TotalBytes: 10},
+		{Name: "config", Subsystem: tableSubsystem("config"), TotalBytes: 5},
+	}
+	g := growthSample{Blocks: 100, AvgBlockBytes: 50, BlocksPerDay: 2}
+	r := newStorageReport(tables, g)
+
+	if r.TotalBytes != 3025 {
+		t.Errorf("total bytes = %d want 3025", r.TotalBytes)
+	}
+	cases := []struct {
+		sub         string
+		total       int64
+		perDay      float64
+		projected30 int64
+	}{
+		{"blocks", 1000, 100, 4000},
+		{"index", 2000, 200, 8000},
+		{"mockhsm", 20, 2, 80},
+		{"other", 5, 0.5, 20},
+	}
+	for _, c := range cases {
+		s := r.Subsystems[c.sub]
+		if s == nil {
+			t.Errorf("missing subsystem %s", c.sub)
+			continue
+		}
+		if s.TotalBytes != c.total || s.BytesPerDay != c.perDay || s.ProjectedBytes["30d"] != c.projected30 {
+			t.Errorf("%s = %d, %v, %d want %d, %v, %d", c.sub, s.TotalBytes, s.BytesPerDay, s.ProjectedBytes["30d"], c.total, c.perDay, c.projected30)
+		}
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"sync"
 	"time"
 
+	"chain/core/account"
+	"chain/core/asset"
 	"chain/core/fetch"
 	"chain/core/leader"
 	"chain/core/txbuilder"
@@ -13,6 +15,7 @@ import (
 	chainjson "chain/encoding/json"
 	"chain/errors"
 	"chain/log"
+	"chain/net/http/httpjson"
 	"chain/net/http/reqid"
 	"chain/protocol/bc"
 )
@@ -22,14 +25,26 @@ var defaultTxTTL = 5 * time.Minute
 func (a *API) actionDecoder(action string) (func([]byte) (txbuilder.Action, error), bool) {
 	var decoder func([]byte) (txbuilder.Action, error)
 	switch action {
+	case "claim_hash_lock":
+		decoder = txbuilder.DecodeClaimHashLockAction(a.Indexer.SpendCommitment)
 	case "control_account":
 		decoder = a.Accounts.DecodeControlAction
+	case "control_hash_lock":
+		decoder = txbuilder.DecodeControlHashLockAction
 	case "control_program":
 		decoder = txbuilder.DecodeControlProgramAction
 	case "control_receiver":
 		decoder = txbuilder.DecodeControlReceiverAction
+	case "control_time_lock":
+		decoder = txbuilder.DecodeControlTimeLockAction
 	case "issue":
 		decoder = a.Assets.DecodeIssueAction
+	case "link_asset_successor":
+		decoder = a.Assets.DecodeLinkSuccessorAction
+	case "update_asset_definition":
+		decoder = a.Assets.DecodeUpdateDefinitionAction
+	case "refund_hash_lock":
+		decoder = txbuilder.DecodeRefundHashLockAction(a.Indexer.SpendCommitment)
 	case "retire":
 		decoder = txbuilder.DecodeRetireAction
 	case "spend_account":
@@ -38,6 +53,8 @@ func (a *API) actionDecoder(action string) (func([]byte) (txbuilder.Action, erro
 		decoder = a.Accounts.DecodeSpendUTXOAction
 	case "set_transaction_reference_data":
 		decoder = txbuilder.DecodeSetTxRefDataAction
+	case "unlock_time_lock":
+		decoder = txbuilder.DecodeUnlockTimeLockAction(a.Indexer.SpendCommitment)
 	default:
 		return nil, false
 	}
@@ -90,6 +107,12 @@ func (a *API) buildSingle(ctx context.Context, req *buildRequest) (*txbuilder.Te
 	if tpl.SigningInstructions == nil {
 		tpl.SigningInstructions = []*txbuilder.SigningInstruction{}
 	}
+
+	// The template is usable even if its event can't be recorded.
+	err = a.Accounts.RecordTxEvent(ctx, account.EventBuilt, tpl.Transaction)
+	if err != nil {
+		log.Error(ctx, err)
+	}
 	return tpl, nil
 }
 
@@ -104,6 +127,9 @@ func (a *API) build(ctx context.Context, buildReqs []*buildRequest) (interface{}
 		return resp, err
 	}
 
+	// Record who builds the txs, for assets' issuance policies.
+	ctx = asset.WithIssuer(ctx, accessTokenID(ctx))
+
 	responses := make([]interface{}, len(buildReqs))
 	var wg sync.WaitGroup
 	wg.Add(len(responses))
@@ -117,9 +143,18 @@ func (a *API) build(ctx context.Context, buildReqs []*buildRequest) (interface{}
 			tmpl, err := a.buildSingle(subctx, buildReqs[i])
 			if err != nil {
 				responses[i] = err
-			} else {
-				responses[i] = tmpl
+				return
 			}
+			est, err := txbuilder.EstimateTemplate(tmpl)
+			if err != nil {
+				// The template is still usable;
+				// omit the estimate.
+				log.Error(subctx, err, "estimating template ")
+			}
+			responses[i] = struct {
+				*txbuilder.Template
+				Estimate *txbuilder.Estimate `json:"estimate,omitempty"`
+			}{tmpl, est}
 		}(i)
 	}
 
@@ -132,7 +167,17 @@ func (a *API) submitSingle(ctx context.Context, tpl *txbuilder.Template, waitUnt
 		return nil, errors.Wrap(txbuilder.ErrMissingRawTx)
 	}
 
-	err := a.finalizeTxWait(ctx, tpl, waitUntil)
+	err := a.Assets.AuthorizeIssuances(ctx, tpl.Transaction, accessTokenID(ctx))
+	if err != nil {
+		return nil, errors.Wrapf(err, "tx %s", tpl.Transaction.ID)
+	}
+
+	err = a.finalizeTxWait(ctx, tpl, waitUntil)
+	if dup, ok := errors.Root(err).(*txbuilder.DuplicateError); ok {
+		// A retry of an earlier submission; report
+		// the tx that one submitted.
+		return map[string]string{"id": dup.TxID.String()}, nil
+	}
 	if err != nil {
 		return nil, errors.Wrapf(err, "tx %s", tpl.Transaction.ID)
 	}
@@ -225,6 +270,10 @@ func (a *API) finalizeTxWait(ctx context.Context, txTemplate *txbuilder.Template
 	if err != nil {
 		return err
 	}
+	err = a.Accounts.RecordTxEvent(ctx, account.EventSubmitted, txTemplate.Transaction)
+	if err != nil {
+		log.Error(ctx, err)
+	}
 	if waitUntil == "none" {
 		return nil
 	}
@@ -288,6 +337,12 @@ type submitArg struct {
 	Transactions []txbuilder.Template
 	wait         chainjson.Duration
 	WaitUntil    string `json:"wait_until"` // values none, confirmed, processed. default: processed
+
+	// IdempotencyKeys, if given, has one key per transaction,
+	// or "" for none. Submitting a transaction with a key
+	// used before for another one returns the ID of the
+	// other one instead. See txbuilder.WithIdempotencyKey.
+	IdempotencyKeys []string `json:"idempotency_keys"`
 }
 
 // POST /submit-transaction
@@ -298,6 +353,14 @@ func (a *API) submit(ctx context.Context, x submitArg) (interface{}, error) {
 		return resp, err
 	}
 
+	if x.IdempotencyKeys != nil && len(x.IdempotencyKeys) != len(x.Transactions) {
+		return nil, errors.WithDetailf(httpjson.ErrBadRequest, "got %d idempotency keys for %d transactions", len(x.IdempotencyKeys), len(x.Transactions))
+	}
+
+	// Record who submitted the txs, for the generator's
+	// block assembly policy and idempotency keys.
+	ctx = txbuilder.WithSubmitter(ctx, accessTokenID(ctx))
+
 	// Setup a timeout for the provided wait duration.
 	timeout := x.wait.Duration
 	if timeout <= 0 {
@@ -315,6 +378,10 @@ func (a *API) submit(ctx context.Context, x submitArg) (interface{}, error) {
 			defer wg.Done()
 			defer batchRecover(subctx, &responses[i])
 
+			if x.IdempotencyKeys != nil && x.IdempotencyKeys[i] != "" {
+				subctx = txbuilder.WithIdempotencyKey(subctx, x.IdempotencyKeys[i])
+			}
+
 			tx, err := a.submitSingle(subctx, &x.Transactions[i], x.WaitUntil)
 			if err != nil {
 				responses[i] = err
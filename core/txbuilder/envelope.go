@@ -0,0 +1,236 @@
+package txbuilder
+
+import (
+	"bytes"
+
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+var (
+	ErrMergeConflict    = errors.New("transaction parts conflict")
+	ErrEnvelopeMismatch = errors.New("signed transaction does not match envelope")
+)
+
+// Part is one party's contribution to a multi-party
+// transaction: an unsigned template built on the base
+// transaction shared by all the parties.
+type Part struct {
+	Party    string    `json:"party"`
+	Template *Template `json:"template"`
+}
+
+// Envelope carries a transaction built by several parties
+// through signing. Merge combines the parties' parts into
+// Template; each party signs Template in turn, and
+// AddSignatures takes from each signed copy only the
+// witnesses of that party's own inputs.
+type Envelope struct {
+	Template *Template        `json:"template"`
+	Parties  []*EnvelopeParty `json:"parties"`
+}
+
+// EnvelopeParty records the positions in an envelope's
+// transaction of the inputs and outputs a party added.
+type EnvelopeParty struct {
+	Party   string   `json:"party"`
+	Inputs  []uint32 `json:"inputs"`
+	Outputs []uint32 `json:"outputs"`
+	Signed  bool     `json:"signed"`
+}
+
+// Merge combines parts built on base into an envelope whose
+// transaction has the inputs and outputs of base followed by
+// those each part added, in order. It returns ErrMergeConflict
+// if a part alters base, spends an input another part spends,
+// or is already signed, since a signature made before the
+// merge would not hold after it.
+//
+// The merged template does not allow additional actions,
+// so each party's signatures commit to the whole transaction.
+func Merge(base *bc.TxData, parts []*Part) (*Envelope, error) {
+	if base == nil {
+		base = &bc.TxData{Version: bc.CurrentTransactionVersion}
+	}
+	if len(parts) == 0 {
+		return nil, errors.WithDetail(ErrMergeConflict, "no parts to merge")
+	}
+
+	tx := &bc.TxData{
+		Version:       base.Version,
+		Inputs:        append([]*bc.TxInput(nil), base.Inputs...),
+		Outputs:       append([]*bc.TxOutput(nil), base.Outputs...),
+		MinTime:       base.MinTime,
+		MaxTime:       base.MaxTime,
+		ReferenceData: base.ReferenceData,
+	}
+	tpl := &Template{Local: true}
+	env := &Envelope{Template: tpl}
+
+	spentBy := make(map[string]string) // input commitment -> party
+	for _, in := range base.Inputs {
+		spentBy[inputCommitment(in)] = "the base transaction"
+	}
+	for _, part := range parts {
+		if part.Template == nil || part.Template.Transaction == nil {
+			return nil, errors.WithDetailf(ErrMissingRawTx, "party %q", part.Party)
+		}
+		for _, p := range env.Parties {
+			if p.Party == part.Party {
+				return nil, errors.WithDetailf(ErrMergeConflict, "party %q has more than one part", part.Party)
+			}
+		}
+		ptx := &part.Template.Transaction.TxData
+		err := checkExtends(base, ptx)
+		if err != nil {
+			return nil, errors.WithDetailf(err, "party %q altered the base transaction", part.Party)
+		}
+
+		if len(ptx.ReferenceData) > 0 {
+			if len(tx.ReferenceData) == 0 {
+				tx.ReferenceData = ptx.ReferenceData
+			} else if !bytes.Equal(tx.ReferenceData, ptx.ReferenceData) {
+				return nil, errors.WithDetailf(ErrBadRefData, "party %q", part.Party)
+			}
+		}
+		if ptx.MinTime > tx.MinTime {
+			tx.MinTime = ptx.MinTime
+		}
+		if ptx.MaxTime > 0 && (tx.MaxTime == 0 || ptx.MaxTime < tx.MaxTime) {
+			tx.MaxTime = ptx.MaxTime
+		}
+
+		ep := &EnvelopeParty{Party: part.Party}
+		positions := make(map[uint32]uint32) // in ptx -> in tx
+		for i := len(base.Inputs); i < len(ptx.Inputs); i++ {
+			in := ptx.Inputs[i]
+			if len(in.Arguments()) > 0 {
+				return nil, errors.WithDetailf(ErrMergeConflict, "party %q signed input %d before the merge", part.Party, i)
+			}
+			c := inputCommitment(in)
+			if other, ok := spentBy[c]; ok {
+				return nil, errors.WithDetailf(ErrMergeConflict, "party %q input %d is also an input of %s", part.Party, i, other)
+			}
+			spentBy[c] = "party " + part.Party
+			positions[uint32(i)] = uint32(len(tx.Inputs))
+			ep.Inputs = append(ep.Inputs, uint32(len(tx.Inputs)))
+			tx.Inputs = append(tx.Inputs, in)
+		}
+		for i := len(base.Outputs); i < len(ptx.Outputs); i++ {
+			ep.Outputs = append(ep.Outputs, uint32(len(tx.Outputs)))
+			tx.Outputs = append(tx.Outputs, ptx.Outputs[i])
+		}
+
+		for _, si := range part.Template.SigningInstructions {
+			pos, ok := positions[si.Position]
+			if !ok {
+				return nil, errors.WithDetailf(ErrMergeConflict, "party %q has signing instructions for input %d, which it did not add", part.Party, si.Position)
+			}
+			for _, sw := range si.SignatureWitnesses {
+				for _, sig := range sw.Sigs {
+					if len(sig) > 0 {
+						return nil, errors.WithDetailf(ErrMergeConflict, "party %q signed input %d before the merge", part.Party, si.Position)
+					}
+				}
+			}
+			tpl.SigningInstructions = append(tpl.SigningInstructions, &SigningInstruction{
+				Position:           pos,
+				AssetAmount:        si.AssetAmount,
+				SignatureWitnesses: si.SignatureWitnesses,
+				Arguments:          si.Arguments,
+			})
+		}
+		tpl.Local = tpl.Local && part.Template.Local
+		env.Parties = append(env.Parties, ep)
+	}
+
+	if tx.MaxTime > 0 && tx.MinTime > tx.MaxTime {
+		return nil, errors.WithDetail(ErrMergeConflict, "the parts' time ranges do not overlap")
+	}
+	err := checkBlankCheck(tx)
+	if err != nil {
+		return nil, err
+	}
+	tpl.Transaction = bc.NewTx(*tx)
+	return env, nil
+}
+
+// checkExtends returns ErrMergeConflict unless tx begins
+// with the inputs and outputs of base, unchanged.
+func checkExtends(base, tx *bc.TxData) error {
+	if tx.Version != base.Version {
+		return errors.WithDetailf(ErrMergeConflict, "version %d, base has version %d", tx.Version, base.Version)
+	}
+	if len(tx.Inputs) < len(base.Inputs) || len(tx.Outputs) < len(base.Outputs) {
+		return errors.WithDetail(ErrMergeConflict, "missing inputs or outputs of the base transaction")
+	}
+	for i, in := range base.Inputs {
+		if inputCommitment(in) != inputCommitment(tx.Inputs[i]) || !bytes.Equal(in.ReferenceData, tx.Inputs[i].ReferenceData) {
+			return errors.WithDetailf(ErrMergeConflict, "input %d differs from the base transaction's", i)
+		}
+	}
+	for i, out := range base.Outputs {
+		if outputCommitment(out) != outputCommitment(tx.Outputs[i]) {
+			return errors.WithDetailf(ErrMergeConflict, "output %d differs from the base transaction's", i)
+		}
+	}
+	return nil
+}
+
+func inputCommitment(in *bc.TxInput) string {
+	var buf bytes.Buffer
+	in.WriteInputCommitment(&buf, bc.SerValid) // error is impossible
+	return buf.String()
+}
+
+func outputCommitment(out *bc.TxOutput) string {
+	var buf bytes.Buffer
+	out.WriteCommitment(&buf) // error is impossible
+	buf.Write(out.ReferenceData)
+	return buf.String()
+}
+
+// AddSignatures copies into env the witnesses of the inputs
+// party added, from signed, party's signed copy of
+// env.Template. It returns ErrEnvelopeMismatch if signed's
+// transaction differs from env's in anything other than
+// witnesses; in particular, a party cannot alter the inputs
+// or outputs of another.
+func AddSignatures(env *Envelope, party string, signed *Template) error {
+	var ep *EnvelopeParty
+	for _, p := range env.Parties {
+		if p.Party == party {
+			ep = p
+		}
+	}
+	if ep == nil {
+		return errors.WithDetailf(ErrEnvelopeMismatch, "no party %q in the envelope", party)
+	}
+	if signed == nil || signed.Transaction == nil {
+		return errors.Wrap(ErrMissingRawTx)
+	}
+	if env.Template == nil || env.Template.Transaction == nil {
+		return errors.WithDetail(ErrMissingRawTx, "envelope has no transaction")
+	}
+	tx := env.Template.Transaction
+	if signed.Transaction.ID != tx.ID {
+		return errors.WithDetailf(ErrEnvelopeMismatch, "party %q signed transaction %s, not %s", party, signed.Transaction.ID, tx.ID)
+	}
+
+	for _, pos := range ep.Inputs {
+		tx.Inputs[pos].SetArguments(signed.Transaction.Inputs[pos].Arguments())
+		for _, si := range signed.SigningInstructions {
+			if si.Position != pos {
+				continue
+			}
+			for i, envSI := range env.Template.SigningInstructions {
+				if envSI.Position == pos {
+					env.Template.SigningInstructions[i] = si
+				}
+			}
+		}
+	}
+	env.Template.Transaction = bc.NewTx(tx.TxData)
+	ep.Signed = true
+	return nil
+}
@@ -0,0 +1,132 @@
+package txbuilder
+
+import (
+	"testing"
+
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+func TestMerge(t *testing.T) {
+	assetX, assetY := bc.AssetID{1}, bc.AssetID{2}
+	spendX := bc.NewSpendInput(nil, bc.Hash{1}, assetX, 5, 0, []byte("alice"), bc.Hash{}, nil)
+	spendY := bc.NewSpendInput(nil, bc.Hash{2}, assetY, 3, 0, []byte("bob"), bc.Hash{}, nil)
+	payY := bc.NewTxOutput(assetY, 3, []byte("alice"), nil)
+	payX := bc.NewTxOutput(assetX, 5, []byte("bob"), nil)
+
+	part := func(party string, in *bc.TxInput, out *bc.TxOutput) *Part {
+		return &Part{Party: party, Template: &Template{
+			Transaction: bc.NewTx(bc.TxData{
+				Version: 1,
+				Inputs:  []*bc.TxInput{in},
+				Outputs: []*bc.TxOutput{out},
+			}),
+			SigningInstructions: []*SigningInstruction{{Position: 0}},
+			AllowAdditional:     true,
+		}}
+	}
+
+	env, err := Merge(nil, []*Part{part("alice", spendX, payY), part("bob", spendY, payX)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := env.Template.Transaction
+	if len(tx.Inputs) != 2 || tx.Inputs[0] != spendX || tx.Inputs[1] != spendY {
+		t.Errorf("merged inputs = %v", tx.Inputs)
+	}
+	if len(tx.Outputs) != 2 || tx.Outputs[0] != payY || tx.Outputs[1] != payX {
+		t.Errorf("merged outputs = %v", tx.Outputs)
+	}
+	if env.Template.AllowAdditional {
+		t.Error("merged template allows additional actions")
+	}
+	if len(env.Template.SigningInstructions) != 2 || env.Template.SigningInstructions[1].Position != 1 {
+		t.Errorf("signing instructions = %+v", env.Template.SigningInstructions)
+	}
+	if p := env.Parties[1]; p.Party != "bob" || len(p.Inputs) != 1 || p.Inputs[0] != 1 || p.Outputs[0] != 1 {
+		t.Errorf("party bob = %+v", p)
+	}
+
+	// Alice signs every input she can see; only hers is kept.
+	var signed Template
+	raw, _ := tx.MarshalText()
+	signed.Transaction = new(bc.Tx)
+	err = signed.Transaction.UnmarshalText(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, in := range signed.Transaction.Inputs {
+		in.SetArguments([][]byte{[]byte("sig")})
+	}
+	err = AddSignatures(env, "alice", &signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := env.Template.Transaction.Inputs[0].Arguments(); len(got) != 1 {
+		t.Errorf("alice's input arguments = %x", got)
+	}
+	if got := env.Template.Transaction.Inputs[1].Arguments(); len(got) != 0 {
+		t.Errorf("bob's input arguments = %x, want none", got)
+	}
+	if !env.Parties[0].Signed || env.Parties[1].Signed {
+		t.Errorf("signed = %v, %v; want true, false", env.Parties[0].Signed, env.Parties[1].Signed)
+	}
+
+	// Bob cannot sign an altered transaction.
+	altered := bc.NewTx(bc.TxData{
+		Version: 1,
+		Inputs:  []*bc.TxInput{spendX, spendY},
+		Outputs: []*bc.TxOutput{payY, bc.NewTxOutput(assetX, 5, []byte("eve"), nil)},
+	})
+	err = AddSignatures(env, "bob", &Template{Transaction: altered})
+	if errors.Root(err) != ErrEnvelopeMismatch {
+		t.Errorf("altered transaction err = %v want %v", err, ErrEnvelopeMismatch)
+	}
+}
+
+func TestMergeConflicts(t *testing.T) {
+	assetX := bc.AssetID{1}
+	spend := bc.NewSpendInput(nil, bc.Hash{1}, assetX, 5, 0, []byte("alice"), bc.Hash{}, nil)
+	signedSpend := bc.NewSpendInput([][]byte{[]byte("sig")}, bc.Hash{2}, assetX, 5, 0, []byte("alice"), bc.Hash{}, nil)
+	pay := bc.NewTxOutput(assetX, 5, []byte("bob"), nil)
+	base := &bc.TxData{Version: 1, Outputs: []*bc.TxOutput{pay}}
+
+	part := func(party string, ins []*bc.TxInput, outs []*bc.TxOutput) *Part {
+		return &Part{Party: party, Template: &Template{
+			Transaction: bc.NewTx(bc.TxData{Version: 1, Inputs: ins, Outputs: outs}),
+		}}
+	}
+
+	cases := []struct {
+		name  string
+		parts []*Part
+	}{{
+		name: "double spend",
+		parts: []*Part{
+			part("alice", []*bc.TxInput{spend}, []*bc.TxOutput{pay}),
+			part("bob", []*bc.TxInput{spend}, []*bc.TxOutput{pay}),
+		},
+	}, {
+		name: "altered base",
+		parts: []*Part{
+			part("alice", []*bc.TxInput{spend}, []*bc.TxOutput{bc.NewTxOutput(assetX, 5, []byte("eve"), nil)}),
+		},
+	}, {
+		name: "signed before merge",
+		parts: []*Part{
+			part("alice", []*bc.TxInput{signedSpend}, []*bc.TxOutput{pay}),
+		},
+	}, {
+		name: "duplicate party",
+		parts: []*Part{
+			part("alice", []*bc.TxInput{spend}, []*bc.TxOutput{pay}),
+			part("alice", nil, []*bc.TxOutput{pay}),
+		},
+	}}
+	for _, c := range cases {
+		_, err := Merge(base, c.parts)
+		if errors.Root(err) != ErrMergeConflict {
+			t.Errorf("%s: err = %v want %v", c.name, err, ErrMergeConflict)
+		}
+	}
+}
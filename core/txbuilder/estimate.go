@@ -0,0 +1,120 @@
+package txbuilder
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+
+	"chain/errors"
+	"chain/protocol/vm"
+)
+
+// sigSize is the size of an ed25519 signature.
+const sigSize = 64
+
+// Estimate describes the resources a transaction
+// template will need once it is signed, so that a
+// client can tell whether the transaction will exceed
+// the VM's limits before collecting signatures.
+type Estimate struct {
+	// Size is the estimated size in bytes
+	// of the signed, serialized transaction.
+	Size int64 `json:"size"`
+
+	// RunCost is the sum of the inputs' run costs.
+	RunCost int64 `json:"run_cost"`
+
+	Inputs []*InputEstimate `json:"inputs"`
+}
+
+// InputEstimate describes the signatures an input needs
+// and the estimated run cost of verifying them.
+type InputEstimate struct {
+	Position uint32 `json:"position"`
+
+	// SignaturesRequired is the number of signatures
+	// needed, of the Keys keys that may provide them.
+	SignaturesRequired int `json:"signatures_required"`
+	Keys               int `json:"keys"`
+
+	// RunCost is estimated with vm.Estimate, from the
+	// input's program and its signature programs.
+	RunCost int64 `json:"run_cost"`
+
+	// ExceedsRunLimit is true if RunCost is more
+	// than the VM's initial run limit.
+	ExceedsRunLimit bool `json:"exceeds_run_limit"`
+}
+
+// EstimateTemplate estimates the size and run cost of tpl's
+// transaction once tpl's signing instructions are carried out.
+func EstimateTemplate(tpl *Template) (*Estimate, error) {
+	if tpl.Transaction == nil {
+		return nil, errors.Wrap(ErrMissingRawTx)
+	}
+	v1, _ := vm.Version(1)
+	tx := tpl.Transaction
+
+	size, err := tx.WriteTo(ioutil.Discard)
+	if err != nil {
+		return nil, errors.Wrap(err, "serializing transaction")
+	}
+	est := &Estimate{Size: size}
+
+	for _, si := range tpl.SigningInstructions {
+		if si.Position >= uint32(len(tx.Inputs)) {
+			return nil, errors.WithDetailf(ErrBadTxInputIdx, "signing instruction references missing tx input %d", si.Position)
+		}
+		in := tx.Inputs[si.Position]
+		prog := in.ControlProgram()
+		if in.IsIssuance() {
+			prog = in.IssuanceProgram()
+		}
+		cost, err := vm.Estimate(prog)
+		if err != nil {
+			return nil, errors.Wrapf(err, "estimating program of input %d", si.Position)
+		}
+
+		ie := &InputEstimate{Position: si.Position}
+		var nargs int64
+		for _, sw := range si.SignatureWitnesses {
+			sigProg := []byte(sw.Program)
+			if len(sigProg) == 0 {
+				sigProg = buildSigProgram(tpl, si.Position)
+			}
+			sigCost, err := vm.Estimate(sigProg)
+			if err != nil {
+				return nil, errors.Wrapf(err, "estimating signature program of input %d", si.Position)
+			}
+			ie.SignaturesRequired += sw.Quorum
+			ie.Keys += len(sw.Keys)
+
+			// materialize adds N, the quorum's
+			// signatures, and the program.
+			n := vm.Int64Bytes(nargs)
+			args := varstrSize(len(n)) + int64(sw.Quorum)*varstrSize(sigSize) + varstrSize(len(sigProg))
+			est.Size += args
+			nargs += 2 + int64(sw.Quorum)
+
+			// Pushing the arguments costs their
+			// memory, and checking the signatures
+			// is charged to the sig program.
+			cost += sigCost + args + v1.Limits.StackItemCost*(2+int64(sw.Quorum))
+		}
+		for _, arg := range si.Arguments {
+			est.Size += varstrSize(len(arg))
+			cost += int64(len(arg)) + v1.Limits.StackItemCost
+		}
+		ie.RunCost = cost
+		ie.ExceedsRunLimit = cost > v1.Limits.InitialRunLimit
+		est.RunCost += cost
+		est.Inputs = append(est.Inputs, ie)
+	}
+	return est, nil
+}
+
+// varstrSize is the serialized size
+// of a varstr of n bytes.
+func varstrSize(n int) int64 {
+	var buf [binary.MaxVarintLen64]byte
+	return int64(binary.PutUvarint(buf[:], uint64(n)) + n)
+}
@@ -0,0 +1,83 @@
+package txbuilder
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"golang.org/x/crypto/sha3"
+
+	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/chainkd"
+	"chain/encoding/json"
+	"chain/protocol/bc"
+	"chain/protocol/vmutil"
+)
+
+func TestEstimateTemplate(t *testing.T) {
+	var (
+		initialBlockHash bc.Hash
+		privs            []chainkd.XPrv
+		pubs             []ed25519.PublicKey
+		keys             []keyID
+	)
+	for i := 0; i < 3; i++ {
+		priv, pub, err := chainkd.NewXKeys(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		privs = append(privs, priv)
+		pubs = append(pubs, pub.PublicKey())
+		keys = append(keys, keyID{XPub: pub, DerivationPath: []json.HexBytes{}})
+	}
+	issuanceProg, _ := vmutil.P2SPMultiSigProgram(pubs, 2)
+	assetID := bc.ComputeAssetID(issuanceProg, initialBlockHash, 1, bc.EmptyStringHash)
+	tpl := &Template{
+		Transaction: bc.NewTx(bc.TxData{
+			Version: 1,
+			Inputs: []*bc.TxInput{
+				bc.NewIssuanceInput([]byte{1}, 100, nil, initialBlockHash, issuanceProg, nil, nil),
+			},
+			Outputs: []*bc.TxOutput{
+				bc.NewTxOutput(assetID, 100, []byte("dest"), nil),
+			},
+		}),
+		SigningInstructions: []*SigningInstruction{{
+			SignatureWitnesses: []*signatureWitness{{Quorum: 2, Keys: keys}},
+		}},
+	}
+
+	est, err := EstimateTemplate(tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(est.Inputs) != 1 {
+		t.Fatalf("got %d input estimates, want 1", len(est.Inputs))
+	}
+	in := est.Inputs[0]
+	if in.SignaturesRequired != 2 || in.Keys != 3 {
+		t.Errorf("signatures required = %d of %d keys, want 2 of 3", in.SignaturesRequired, in.Keys)
+	}
+	if in.RunCost < 3*1024 || in.ExceedsRunLimit {
+		t.Errorf("run cost = %d (exceeds limit %v), want at least %d within the limit", in.RunCost, in.ExceedsRunLimit, 3*1024)
+	}
+	if est.RunCost != in.RunCost {
+		t.Errorf("total run cost = %d want %d", est.RunCost, in.RunCost)
+	}
+
+	// Sign and compare the estimated size with the actual one.
+	sw := tpl.SigningInstructions[0].SignatureWitnesses[0]
+	sw.Program = buildSigProgram(tpl, 0)
+	h := sha3.Sum256(sw.Program)
+	sw.Sigs = []json.HexBytes{privs[0].Sign(h[:]), privs[1].Sign(h[:])}
+	err = materializeWitnesses(tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, err := tpl.Transaction.WriteTo(ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := est.Size - size; d < 0 || d > 4 {
+		t.Errorf("estimated size %d, signed size %d", est.Size, size)
+	}
+}
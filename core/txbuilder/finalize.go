@@ -3,6 +3,7 @@ package txbuilder
 import (
 	"bytes"
 	"context"
+	"fmt"
 
 	"chain/core/rpc"
 	"chain/errors"
@@ -22,6 +23,10 @@ var (
 
 // Submitter submits a transaction to the generator so that it may
 // be confirmed in a block.
+//
+// If ctx carries an idempotency key (see WithIdempotencyKey) that was
+// recently used to submit a different transaction, Submit does not
+// submit tx, and returns a *DuplicateError naming the other one.
 type Submitter interface {
 	Submit(ctx context.Context, tx *bc.Tx) error
 }
@@ -39,6 +44,12 @@ func FinalizeTx(ctx context.Context, c *protocol.Chain, s Submitter, tx *bc.Tx)
 	// finalize a tx before the initial block has landed
 	<-c.BlockWaiter(1)
 
+	// No block can hold tx once the blockchain is sunset.
+	err = c.CheckFinalHeight(c.Height() + 1)
+	if err != nil {
+		return err
+	}
+
 	// If this transaction is valid, ValidateTxCached will store it in the cache.
 	err = c.ValidateTxCached(tx)
 	if errors.Root(err) == validation.ErrBadTx {
@@ -115,6 +126,52 @@ func checkTxSighashCommitment(tx *bc.Tx) error {
 	return lastError
 }
 
+// A DuplicateError is returned by a Submitter given an
+// idempotency key that was already used to submit a
+// different transaction.
+type DuplicateError struct {
+	Key  string
+	TxID bc.Hash // of the transaction first submitted with Key
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("idempotency key %q already used for tx %s", e.Key, e.TxID)
+}
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey returns a context carrying key,
+// which identifies a submission so that retrying it
+// does not submit a second transaction. See Submitter.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// IdempotencyKey returns the idempotency key
+// carried by ctx, or "" if there is none.
+func IdempotencyKey(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKey{}).(string)
+	return key
+}
+
+type submitterKey struct{}
+
+// WithSubmitter returns a context carrying the identity
+// of whoever submits a tx with it, such as the ID of
+// their access token. A generator records it with the tx,
+// for its block assembly policy, and scopes idempotency
+// keys by it. RemoteGenerator passes it on.
+func WithSubmitter(ctx context.Context, submitter string) context.Context {
+	return context.WithValue(ctx, submitterKey{}, submitter)
+}
+
+// SubmitterID returns the identity carried
+// by ctx, or "" if there is none.
+func SubmitterID(ctx context.Context) string {
+	s, _ := ctx.Value(submitterKey{}).(string)
+	return s
+}
+
 // RemoteGenerator implements the Submitter interface and submits the
 // transaction to a remote generator.
 // TODO(jackson): This implementation maybe belongs elsewhere.
@@ -123,7 +180,34 @@ type RemoteGenerator struct {
 }
 
 func (rg *RemoteGenerator) Submit(ctx context.Context, tx *bc.Tx) error {
-	err := rg.Peer.Call(ctx, "/rpc/submit", tx, nil)
-	err = errors.Wrap(err, "generator transaction notice")
-	return err
+	key := IdempotencyKey(ctx)
+	if key == "" {
+		err := rg.Peer.Call(ctx, "/rpc/submit", tx, nil)
+		err = errors.Wrap(err, "generator transaction notice")
+		return err
+	}
+
+	req := IdempotentSubmission{Tx: tx, Key: key, Submitter: SubmitterID(ctx)}
+	var resp struct {
+		ID bc.Hash `json:"id"`
+	}
+	err := rg.Peer.Call(ctx, "/rpc/submit-idempotent", req, &resp)
+	if err != nil {
+		return errors.Wrap(err, "generator transaction notice")
+	}
+	if resp.ID != tx.ID {
+		return &DuplicateError{Key: key, TxID: resp.ID}
+	}
+	return nil
+}
+
+// IdempotentSubmission is the request
+// to a generator's /rpc/submit-idempotent.
+type IdempotentSubmission struct {
+	Tx  *bc.Tx `json:"transaction"`
+	Key string `json:"idempotency_key"`
+
+	// Submitter identifies whoever submitted Tx to the
+	// core forwarding it. See WithSubmitter.
+	Submitter string `json:"submitter,omitempty"`
 }
@@ -2,6 +2,9 @@ package txbuilder_test
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -11,6 +14,7 @@ import (
 	"chain/core/generator"
 	"chain/core/pin"
 	"chain/core/query"
+	"chain/core/rpc"
 	. "chain/core/txbuilder"
 	"chain/database/pg"
 	"chain/database/pg/pgtest"
@@ -189,6 +193,49 @@ func TestTransferConfirmed(t *testing.T) {
 	}
 }
 
+func TestRemoteGeneratorIdempotent(t *testing.T) {
+	ctx := context.Background()
+	first := bc.NewTx(bc.TxData{Version: 1, ReferenceData: []byte("first")})
+	retry := bc.NewTx(bc.TxData{Version: 1, ReferenceData: []byte("retry")})
+
+	var got IdempotentSubmission
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/rpc/submit-idempotent" {
+			t.Errorf("path = %s want /rpc/submit-idempotent", req.URL.Path)
+		}
+		err := json.NewDecoder(req.Body).Decode(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		json.NewEncoder(w).Encode(map[string]bc.Hash{"id": first.ID})
+	}))
+	defer srv.Close()
+
+	rg := &RemoteGenerator{Peer: &rpc.Client{BaseURL: srv.URL}}
+	ctx = WithIdempotencyKey(ctx, "k1")
+	ctx = WithSubmitter(ctx, "alice")
+
+	err := rg.Submit(ctx, first)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if got.Key != "k1" || got.Tx.ID != first.ID {
+		t.Errorf("submitted key %q tx %s, want k1 %s", got.Key, got.Tx.ID, first.ID)
+	}
+	if got.Submitter != "alice" {
+		t.Errorf("submitted for %q want alice", got.Submitter)
+	}
+
+	err = rg.Submit(ctx, retry)
+	dup, ok := errors.Root(err).(*DuplicateError)
+	if !ok {
+		t.Fatalf("Submit(retry) = %v want *DuplicateError", err)
+	}
+	if dup.TxID != first.ID {
+		t.Errorf("duplicate of %s want %s", dup.TxID, first.ID)
+	}
+}
+
 func BenchmarkTransferWithBlocks(b *testing.B) {
 	_, db := pgtest.NewDB(b, pgtest.SchemaPath)
 	ctx := context.Background()
@@ -0,0 +1,276 @@
+package txbuilder
+
+import (
+	"bytes"
+	"context"
+	stdjson "encoding/json"
+	"time"
+
+	"chain/crypto/ed25519"
+	"chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/vm"
+	"chain/protocol/vmutil"
+)
+
+// ErrBadLock is returned when a lock action's keys or
+// hash are invalid, or when the output spent by an unlock
+// action is not held by the lock it names, or its keys
+// do not match the lock's.
+var ErrBadLock = errors.New("invalid lock")
+
+// OutputLookup returns the commitment of the output with the
+// given ID, for actions that spend outputs that are not held
+// by the Core's accounts.
+type OutputLookup func(ctx context.Context, outputID bc.Hash) (*bc.SpendCommitment, error)
+
+// lockKeys are the keys that may take one path of
+// a lock, and the number of them that must sign.
+type lockKeys struct {
+	Keys   []keyID `json:"keys"`
+	Quorum int     `json:"quorum"`
+}
+
+func (k *lockKeys) pubkeys() []ed25519.PublicKey {
+	return pubkeys(k.Keys)
+}
+
+func pubkeys(keys []keyID) []ed25519.PublicKey {
+	pubs := make([]ed25519.PublicKey, 0, len(keys))
+	for _, k := range keys {
+		path := make([][]byte, 0, len(k.DerivationPath))
+		for _, p := range k.DerivationPath {
+			path = append(path, p)
+		}
+		pubs = append(pubs, k.XPub.Derive(path).PublicKey())
+	}
+	return pubs
+}
+
+func DecodeControlTimeLockAction(data []byte) (Action, error) {
+	a := new(controlTimeLockAction)
+	err := stdjson.Unmarshal(data, a)
+	return a, err
+}
+
+type controlTimeLockAction struct {
+	bc.AssetAmount
+	lockKeys
+	LockTime      time.Time `json:"lock_time"`
+	ReferenceData json.Map  `json:"reference_data"`
+}
+
+func (a *controlTimeLockAction) Build(ctx context.Context, b *TemplateBuilder) error {
+	var missing []string
+	if len(a.Keys) == 0 {
+		missing = append(missing, "keys")
+	}
+	if a.LockTime.IsZero() {
+		missing = append(missing, "lock_time")
+	}
+	if a.AssetID == (bc.AssetID{}) {
+		missing = append(missing, "asset_id")
+	}
+	if len(missing) > 0 {
+		return MissingFieldsError(missing...)
+	}
+
+	prog, err := vmutil.TimeLockProgram(a.pubkeys(), a.Quorum, bc.Millis(a.LockTime))
+	if err != nil {
+		return errors.Sub(ErrBadLock, err)
+	}
+	out := bc.NewTxOutput(a.AssetID, a.Amount, prog, a.ReferenceData)
+	return b.AddOutput(out)
+}
+
+func DecodeControlHashLockAction(data []byte) (Action, error) {
+	a := new(controlHashLockAction)
+	err := stdjson.Unmarshal(data, a)
+	return a, err
+}
+
+type controlHashLockAction struct {
+	bc.AssetAmount
+	Hash          json.HexBytes `json:"hash"`
+	Claim         *lockKeys     `json:"claim"`
+	Refund        *lockKeys     `json:"refund"`
+	Timeout       time.Time     `json:"timeout"`
+	ReferenceData json.Map      `json:"reference_data"`
+}
+
+func (a *controlHashLockAction) Build(ctx context.Context, b *TemplateBuilder) error {
+	var missing []string
+	if len(a.Hash) == 0 {
+		missing = append(missing, "hash")
+	}
+	if a.Claim == nil || len(a.Claim.Keys) == 0 {
+		missing = append(missing, "claim.keys")
+	}
+	if a.Refund == nil || len(a.Refund.Keys) == 0 {
+		missing = append(missing, "refund.keys")
+	}
+	if a.Timeout.IsZero() {
+		missing = append(missing, "timeout")
+	}
+	if a.AssetID == (bc.AssetID{}) {
+		missing = append(missing, "asset_id")
+	}
+	if len(missing) > 0 {
+		return MissingFieldsError(missing...)
+	}
+
+	prog, err := vmutil.HashLockProgram(&vmutil.HashLock{
+		Hash:         a.Hash,
+		ClaimKeys:    a.Claim.pubkeys(),
+		ClaimQuorum:  a.Claim.Quorum,
+		RefundKeys:   a.Refund.pubkeys(),
+		RefundQuorum: a.Refund.Quorum,
+		TimeoutMS:    bc.Millis(a.Timeout),
+	})
+	if err != nil {
+		return errors.Sub(ErrBadLock, err)
+	}
+	out := bc.NewTxOutput(a.AssetID, a.Amount, prog, a.ReferenceData)
+	return b.AddOutput(out)
+}
+
+// unlockAction holds the fields common to the actions
+// that spend an output locked by a time or hash lock.
+type unlockAction struct {
+	lookup        OutputLookup
+	OutputID      *bc.Hash `json:"output_id"`
+	Keys          []keyID  `json:"keys"`
+	ReferenceData json.Map `json:"reference_data"`
+}
+
+func (a *unlockAction) missing() []string {
+	var missing []string
+	if a.OutputID == nil {
+		missing = append(missing, "output_id")
+	}
+	if len(a.Keys) == 0 {
+		missing = append(missing, "keys")
+	}
+	return missing
+}
+
+// addInput adds an input spending the output, with
+// instructions for signing with a.Keys, which must
+// be the keys in the lock path in the same order,
+// and for adding args after the signatures.
+func (a *unlockAction) addInput(b *TemplateBuilder, sc *bc.SpendCommitment, lockPubs []ed25519.PublicKey, quorum int, args ...[]byte) error {
+	pubs := pubkeys(a.Keys)
+	if len(pubs) != len(lockPubs) {
+		return errors.WithDetailf(ErrBadLock, "got %d keys, lock has %d", len(pubs), len(lockPubs))
+	}
+	for i := range pubs {
+		if !bytes.Equal(pubs[i], lockPubs[i]) {
+			return errors.WithDetailf(ErrBadLock, "key %d does not match the lock", i)
+		}
+	}
+
+	in := bc.NewSpendInput(nil, sc.SourceID, sc.AssetID, sc.Amount, sc.SourcePosition, sc.ControlProgram, sc.RefDataHash, a.ReferenceData)
+	sigInst := &SigningInstruction{
+		AssetAmount: sc.AssetAmount,
+		SignatureWitnesses: []*signatureWitness{{
+			Quorum: quorum,
+			Keys:   a.Keys,
+		}},
+	}
+	for _, arg := range args {
+		sigInst.Arguments = append(sigInst.Arguments, arg)
+	}
+	return b.AddInput(in, sigInst)
+}
+
+func DecodeUnlockTimeLockAction(lookup OutputLookup) func([]byte) (Action, error) {
+	return func(data []byte) (Action, error) {
+		a := &unlockTimeLockAction{unlockAction{lookup: lookup}}
+		err := stdjson.Unmarshal(data, a)
+		return a, err
+	}
+}
+
+type unlockTimeLockAction struct {
+	unlockAction
+}
+
+func (a *unlockTimeLockAction) Build(ctx context.Context, b *TemplateBuilder) error {
+	if missing := a.missing(); len(missing) > 0 {
+		return MissingFieldsError(missing...)
+	}
+	sc, err := a.lookup(ctx, *a.OutputID)
+	if err != nil {
+		return err
+	}
+	pubs, quorum, locktime, err := vmutil.ParseTimeLockProgram(sc.ControlProgram)
+	if err != nil {
+		return errors.WithDetail(ErrBadLock, "output is not time-locked")
+	}
+
+	// The lock program checks the mintime, which
+	// the signatures commit to.
+	b.RestrictMinTime(time.Unix(0, int64(locktime)*int64(time.Millisecond)))
+	return a.addInput(b, sc, pubs, quorum)
+}
+
+func DecodeClaimHashLockAction(lookup OutputLookup) func([]byte) (Action, error) {
+	return func(data []byte) (Action, error) {
+		a := &claimHashLockAction{unlockAction: unlockAction{lookup: lookup}}
+		err := stdjson.Unmarshal(data, a)
+		return a, err
+	}
+}
+
+type claimHashLockAction struct {
+	unlockAction
+	Preimage json.HexBytes `json:"preimage"`
+}
+
+func (a *claimHashLockAction) Build(ctx context.Context, b *TemplateBuilder) error {
+	missing := a.missing()
+	if len(a.Preimage) == 0 {
+		missing = append(missing, "preimage")
+	}
+	if len(missing) > 0 {
+		return MissingFieldsError(missing...)
+	}
+	sc, err := a.lookup(ctx, *a.OutputID)
+	if err != nil {
+		return err
+	}
+	h, err := vmutil.ParseHashLockProgram(sc.ControlProgram)
+	if err != nil {
+		return errors.WithDetail(ErrBadLock, "output is not hash-locked")
+	}
+	return a.addInput(b, sc, h.ClaimKeys, h.ClaimQuorum, a.Preimage, vm.Int64Bytes(1))
+}
+
+func DecodeRefundHashLockAction(lookup OutputLookup) func([]byte) (Action, error) {
+	return func(data []byte) (Action, error) {
+		a := &refundHashLockAction{unlockAction{lookup: lookup}}
+		err := stdjson.Unmarshal(data, a)
+		return a, err
+	}
+}
+
+type refundHashLockAction struct {
+	unlockAction
+}
+
+func (a *refundHashLockAction) Build(ctx context.Context, b *TemplateBuilder) error {
+	if missing := a.missing(); len(missing) > 0 {
+		return MissingFieldsError(missing...)
+	}
+	sc, err := a.lookup(ctx, *a.OutputID)
+	if err != nil {
+		return err
+	}
+	h, err := vmutil.ParseHashLockProgram(sc.ControlProgram)
+	if err != nil {
+		return errors.WithDetail(ErrBadLock, "output is not hash-locked")
+	}
+	b.RestrictMinTime(time.Unix(0, int64(h.TimeoutMS)*int64(time.Millisecond)))
+	return a.addInput(b, sc, h.RefundKeys, h.RefundQuorum, vm.Int64Bytes(0))
+}
@@ -0,0 +1,153 @@
+package txbuilder
+
+import (
+	"context"
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	"chain/crypto/ed25519/chainkd"
+	"chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/vm"
+)
+
+func TestHashLock(t *testing.T) {
+	ctx := context.Background()
+	claimer, claimKey := newLockKey(t)
+	refunder, refundKey := newLockKey(t)
+	preimage := []byte("swap secret")
+	hash := sha256.Sum256(preimage)
+	timeout := time.Now().Add(-time.Minute).Truncate(time.Millisecond)
+
+	lock := &controlHashLockAction{
+		AssetAmount: bc.AssetAmount{AssetID: bc.AssetID{1}, Amount: 100},
+		Hash:        hash[:],
+		Claim:       &lockKeys{Keys: []keyID{claimKey}, Quorum: 1},
+		Refund:      &lockKeys{Keys: []keyID{refundKey}, Quorum: 1},
+		Timeout:     timeout,
+	}
+	lookup := lockOutput(ctx, t, lock)
+
+	claim := &claimHashLockAction{
+		unlockAction: unlockAction{lookup: lookup, OutputID: &bc.Hash{}, Keys: []keyID{claimKey}},
+		Preimage:     preimage,
+	}
+	tpl := signUnlock(ctx, t, claim, claimer)
+	err := vm.VerifyTxInput(tpl.Transaction, 0)
+	if err != nil {
+		t.Errorf("claim: %v", err)
+	}
+
+	claim.Preimage = []byte("wrong secret")
+	tpl = signUnlock(ctx, t, claim, claimer)
+	err = vm.VerifyTxInput(tpl.Transaction, 0)
+	if err == nil {
+		t.Error("claim with wrong preimage succeeded")
+	}
+
+	refund := &refundHashLockAction{
+		unlockAction{lookup: lookup, OutputID: &bc.Hash{}, Keys: []keyID{refundKey}},
+	}
+	tpl = signUnlock(ctx, t, refund, refunder)
+	err = vm.VerifyTxInput(tpl.Transaction, 0)
+	if err != nil {
+		t.Errorf("refund: %v", err)
+	}
+	if tpl.Transaction.MinTime != bc.Millis(timeout) {
+		t.Errorf("refund mintime = %d want %d", tpl.Transaction.MinTime, bc.Millis(timeout))
+	}
+
+	wrongKeys := &refundHashLockAction{
+		unlockAction{lookup: lookup, OutputID: &bc.Hash{}, Keys: []keyID{claimKey}},
+	}
+	err = wrongKeys.Build(ctx, NewBuilder(time.Now().Add(time.Minute)))
+	if errors.Root(err) != ErrBadLock {
+		t.Errorf("refund with claim keys err = %v want %v", err, ErrBadLock)
+	}
+}
+
+func TestTimeLock(t *testing.T) {
+	ctx := context.Background()
+	xprv, key := newLockKey(t)
+	lockTime := time.Now().Add(-time.Minute).Truncate(time.Millisecond)
+
+	lock := &controlTimeLockAction{
+		AssetAmount: bc.AssetAmount{AssetID: bc.AssetID{1}, Amount: 100},
+		lockKeys:    lockKeys{Keys: []keyID{key}, Quorum: 1},
+		LockTime:    lockTime,
+	}
+	lookup := lockOutput(ctx, t, lock)
+
+	unlock := &unlockTimeLockAction{
+		unlockAction{lookup: lookup, OutputID: &bc.Hash{}, Keys: []keyID{key}},
+	}
+	tpl := signUnlock(ctx, t, unlock, xprv)
+	if tpl.Transaction.MinTime != bc.Millis(lockTime) {
+		t.Errorf("unlock mintime = %d want %d", tpl.Transaction.MinTime, bc.Millis(lockTime))
+	}
+	err := vm.VerifyTxInput(tpl.Transaction, 0)
+	if err != nil {
+		t.Error(err)
+	}
+
+	claim := &claimHashLockAction{
+		unlockAction: unlockAction{lookup: lookup, OutputID: &bc.Hash{}, Keys: []keyID{key}},
+		Preimage:     []byte("secret"),
+	}
+	err = claim.Build(ctx, NewBuilder(time.Now().Add(time.Minute)))
+	if errors.Root(err) != ErrBadLock {
+		t.Errorf("claiming a time lock err = %v want %v", err, ErrBadLock)
+	}
+}
+
+func newLockKey(t *testing.T) (chainkd.XPrv, keyID) {
+	xprv, xpub, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return xprv, keyID{XPub: xpub, DerivationPath: []json.HexBytes{{1, 0, 0, 0}}}
+}
+
+// lockOutput builds a transaction with the output of the lock
+// action, and returns a lookup that finds that output for any ID.
+func lockOutput(ctx context.Context, t *testing.T, lock Action) OutputLookup {
+	tpl, err := Build(ctx, nil, []Action{lock}, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := tpl.Transaction
+	sc := &bc.SpendCommitment{
+		AssetAmount:    tx.Outputs[0].AssetAmount,
+		SourceID:       tx.Results[0].SourceID,
+		SourcePosition: tx.Results[0].SourcePos,
+		VMVersion:      tx.Outputs[0].VMVersion,
+		ControlProgram: tx.Outputs[0].ControlProgram,
+		RefDataHash:    tx.Results[0].RefDataHash,
+	}
+	return func(context.Context, bc.Hash) (*bc.SpendCommitment, error) {
+		return sc, nil
+	}
+}
+
+// signUnlock builds a transaction with the input of the unlock
+// action, paying its value elsewhere, and signs it with xprv.
+func signUnlock(ctx context.Context, t *testing.T, unlock Action, xprv chainkd.XPrv) *Template {
+	pay := &controlProgramAction{
+		AssetAmount: bc.AssetAmount{AssetID: bc.AssetID{1}, Amount: 100},
+		Program:     []byte("dest"),
+	}
+	tpl, err := Build(ctx, nil, []Action{unlock, pay}, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signFn := func(_ context.Context, _ chainkd.XPub, path [][]byte, h [32]byte) ([]byte, error) {
+		return xprv.Derive(path).Sign(h[:]), nil
+	}
+	err = Sign(ctx, tpl, []chainkd.XPub{xprv.XPub()}, signFn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tpl
+}
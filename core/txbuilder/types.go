@@ -38,6 +38,12 @@ type SigningInstruction struct {
 	Position uint32 `json:"position"`
 	bc.AssetAmount
 	SignatureWitnesses []*signatureWitness `json:"witness_components,omitempty"`
+
+	// Arguments are added to the input's witness after the
+	// signature witnesses' arguments, for control programs
+	// that take arguments of their own, such as the preimage
+	// and branch selector of a hash lock.
+	Arguments []chainjson.HexBytes `json:"arguments,omitempty"`
 }
 
 func (si *SigningInstruction) UnmarshalJSON(b []byte) error {
@@ -48,6 +54,7 @@ func (si *SigningInstruction) UnmarshalJSON(b []byte) error {
 			Type string
 			signatureWitness
 		} `json:"witness_components"`
+		Arguments []chainjson.HexBytes `json:"arguments"`
 	}
 	err := json.Unmarshal(b, &pre)
 	if err != nil {
@@ -56,6 +63,7 @@ func (si *SigningInstruction) UnmarshalJSON(b []byte) error {
 
 	si.AssetAmount = pre.AssetAmount
 	si.Position = pre.Position
+	si.Arguments = pre.Arguments
 	si.SignatureWitnesses = make([]*signatureWitness, 0, len(pre.SignatureWitnesses))
 	for i, w := range pre.SignatureWitnesses {
 		if w.Type != "signature" {
@@ -44,6 +44,9 @@ func materializeWitnesses(txTemplate *Template) error {
 				return errors.WithDetailf(err, "error in witness component %d of input %d", j, i)
 			}
 		}
+		for _, arg := range sigInst.Arguments {
+			witness = append(witness, arg)
+		}
 
 		msg.Inputs[sigInst.Position].SetArguments(witness)
 	}
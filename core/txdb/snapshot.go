@@ -1,13 +1,16 @@
 package txdb
 
 import (
+	"bytes"
 	"context"
+	"sort"
 
 	"github.com/golang/protobuf/proto"
 
 	"chain/core/txdb/internal/storage"
 	"chain/database/pg"
 	"chain/database/sql"
+	"chain/encoding/blockchain"
 	"chain/errors"
 	"chain/protocol/bc"
 	"chain/protocol/patricia"
@@ -78,9 +81,20 @@ func storeStateSnapshot(ctx context.Context, db pg.DB, snapshot *state.Snapshot,
 		return errors.Wrap(err, "writing state snapshot to database")
 	}
 
-	const deleteQ = `DELETE FROM snapshots WHERE created_at < NOW() - INTERVAL '24 hours'`
-	_, err = db.Exec(ctx, deleteQ)
-	return errors.Wrap(err, "deleting old snapshots")
+	// Keep the latest full snapshot regardless of its age,
+	// since later diffs build on it. Diffs up to that height
+	// are no longer needed.
+	const deleteQ = `
+		DELETE FROM snapshots
+		WHERE created_at < NOW() - INTERVAL '24 hours' AND height < $1
+	`
+	_, err = db.Exec(ctx, deleteQ, blockHeight)
+	if err != nil {
+		return errors.Wrap(err, "deleting old snapshots")
+	}
+	const deleteDiffsQ = `DELETE FROM snapshot_diffs WHERE height <= $1`
+	_, err = db.Exec(ctx, deleteDiffsQ, blockHeight)
+	return errors.Wrap(err, "deleting old snapshot diffs")
 }
 
 func getStateSnapshot(ctx context.Context, db pg.DB) (*state.Snapshot, uint64, error) {
@@ -103,7 +117,7 @@ func getStateSnapshot(ctx context.Context, db pg.DB) (*state.Snapshot, uint64, e
 	if err != nil {
 		return nil, height, errors.Wrap(err, "decoding snapshot")
 	}
-	return snapshot, height, nil
+	return applySnapshotDiffs(ctx, db, snapshot, height)
 }
 
 // getRawSnapshot returns the raw, protobuf-encoded snapshot data at the
@@ -116,3 +130,122 @@ func getRawSnapshot(ctx context.Context, db pg.DB, height uint64) (data []byte,
 	}
 	return data, err
 }
+
+// encodeDiff returns the binary representation of d:
+// the inserted and deleted state tree items as varstr lists,
+// followed by a varint31 count of issuances,
+// each a varstr hash and a varint63 expiry time.
+func encodeDiff(d *state.Diff) ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := blockchain.WriteVarstrList(&buf, d.Inserted)
+	if err != nil {
+		return nil, err
+	}
+	_, err = blockchain.WriteVarstrList(&buf, d.Deleted)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]bc.Hash, 0, len(d.Issuances))
+	for h := range d.Issuances {
+		hashes = append(hashes, h)
+	}
+	sort.Sort(byHash(hashes))
+	_, err = blockchain.WriteVarint31(&buf, uint64(len(hashes)))
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range hashes {
+		_, err = blockchain.WriteVarstr31(&buf, h[:])
+		if err != nil {
+			return nil, err
+		}
+		_, err = blockchain.WriteVarint63(&buf, d.Issuances[h])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeDiff(data []byte) (*state.Diff, error) {
+	r := bytes.NewReader(data)
+	d := new(state.Diff)
+	var err error
+	d.Inserted, _, err = blockchain.ReadVarstrList(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading inserted items")
+	}
+	d.Deleted, _, err = blockchain.ReadVarstrList(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading deleted items")
+	}
+	n, _, err := blockchain.ReadVarint31(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading issuance count")
+	}
+	d.Issuances = make(map[bc.Hash]uint64, n)
+	for ; n > 0; n-- {
+		b, _, err := blockchain.ReadVarstr31(r)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading issuance hash")
+		}
+		var h bc.Hash
+		copy(h[:], b)
+		d.Issuances[h], _, err = blockchain.ReadVarint63(r)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading issuance expiry")
+		}
+	}
+	if r.Len() > 0 {
+		return nil, errors.New("trailing data in snapshot diff")
+	}
+	return d, nil
+}
+
+// storeSnapshotDiff stores the diff between the snapshot
+// at baseHeight and the one at blockHeight.
+func storeSnapshotDiff(ctx context.Context, db pg.DB, d *state.Diff, baseHeight, blockHeight uint64) error {
+	b, err := encodeDiff(d)
+	if err != nil {
+		return errors.Wrap(err, "encoding snapshot diff")
+	}
+	const q = `
+		INSERT INTO snapshot_diffs (height, base_height, data) VALUES($1, $2, $3)
+		ON CONFLICT (height) DO UPDATE SET base_height = $2, data = $3
+	`
+	_, err = db.Exec(ctx, q, blockHeight, baseHeight, b)
+	return errors.Wrap(err, "writing snapshot diff to database")
+}
+
+// applySnapshotDiffs applies, in order, the stored diffs that
+// extend snapshot at the given height, and returns the result
+// and its height.
+func applySnapshotDiffs(ctx context.Context, db pg.DB, snapshot *state.Snapshot, height uint64) (*state.Snapshot, uint64, error) {
+	const q = `
+		SELECT height, base_height, data FROM snapshot_diffs
+		WHERE height > $1 ORDER BY height
+	`
+	err := pg.ForQueryRows(ctx, db, q, height, func(diffHeight, baseHeight uint64, data []byte) error {
+		if baseHeight != height {
+			return nil // not part of the chain of diffs from our snapshot
+		}
+		d, err := decodeDiff(data)
+		if err != nil {
+			return errors.Wrapf(err, "decoding snapshot diff at height %d", diffHeight)
+		}
+		snapshot, err = d.Apply(snapshot)
+		if err != nil {
+			return errors.Wrapf(err, "applying snapshot diff at height %d", diffHeight)
+		}
+		height = diffHeight
+		return nil
+	})
+	return snapshot, height, err
+}
+
+type byHash []bc.Hash
+
+func (a byHash) Len() int           { return len(a) }
+func (a byHash) Less(i, j int) bool { return bytes.Compare(a[i][:], a[j][:]) < 0 }
+func (a byHash) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
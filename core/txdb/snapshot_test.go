@@ -122,6 +122,82 @@ func TestReadWriteStateSnapshot(t *testing.T) {
 	}
 }
 
+func TestEncodeDecodeDiff(t *testing.T) {
+	want := &state.Diff{
+		Inserted: [][]byte{{0x01}, {0x02, 0x03}},
+		Deleted:  [][]byte{{0x04}},
+		Issuances: map[bc.Hash]uint64{
+			bc.Hash{0x05}: 10,
+			bc.Hash{0x06}: 20,
+		},
+	}
+	b, err := encodeDiff(want)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	got, err := decodeDiff(b)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !testutil.DeepEqual(got, want) {
+		t.Errorf("decodeDiff(encodeDiff(d)) = %#v want %#v", got, want)
+	}
+
+	_, err = decodeDiff(append(b, 0))
+	if err == nil {
+		t.Error("decodeDiff with trailing data: expected error")
+	}
+}
+
+func TestSaveSnapshotDiffs(t *testing.T) {
+	dbtx := pgtest.NewTx(t)
+	ctx := context.Background()
+	store := NewStore(dbtx)
+
+	snapshot := state.Empty()
+	for i := 0; i < fullSnapshotInterval+2; i++ {
+		snapshot = state.Copy(snapshot)
+		err := snapshot.Tree.Insert([]byte{byte(i)})
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		if i > 0 {
+			snapshot.Tree.Delete([]byte{byte(i - 1)})
+		}
+		snapshot.Issuances[bc.Hash{byte(i)}] = uint64(i)
+
+		err = store.SaveSnapshot(ctx, uint64(i+1), snapshot)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+
+		got, height, err := store.LatestSnapshot(ctx)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		if height != uint64(i+1) {
+			t.Fatalf("%d: LatestSnapshot height = %d want %d", i, height, i+1)
+		}
+		if got.Tree.RootHash() != snapshot.Tree.RootHash() {
+			t.Fatalf("%d: LatestSnapshot root = %s want %s", i, got.Tree.RootHash(), snapshot.Tree.RootHash())
+		}
+		if !testutil.DeepEqual(got.Issuances, snapshot.Issuances) {
+			t.Fatalf("%d: LatestSnapshot issuances = %#v want %#v", i, got.Issuances, snapshot.Issuances)
+		}
+	}
+
+	// Only the first and the (fullSnapshotInterval+1)th
+	// snapshots are full; the others are diffs.
+	var n int
+	err := dbtx.QueryRow(ctx, `SELECT count(*) FROM snapshots`).Scan(&n)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if n != 2 {
+		t.Errorf("full snapshots = %d want 2", n)
+	}
+}
+
 func BenchmarkStoreSnapshot100(b *testing.B) {
 	benchmarkStoreSnapshot(100, 100, b)
 }
@@ -2,6 +2,7 @@ package txdb
 
 import (
 	"context"
+	"sync"
 
 	"chain/database/pg"
 	"chain/errors"
@@ -17,8 +18,19 @@ type Store struct {
 	db pg.DB
 
 	cache blockCache
+
+	// Most snapshots are saved as diffs against
+	// the snapshot saved before them.
+	snapshotMu     sync.Mutex
+	lastSnapshot   *state.Snapshot
+	lastSnapHeight uint64
+	nSnapDiffs     int
 }
 
+// fullSnapshotInterval is how often SaveSnapshot
+// writes a full snapshot rather than a diff.
+const fullSnapshotInterval = 24
+
 var _ protocol.Store = (*Store)(nil)
 
 // NewStore creates and returns a new Store object.
@@ -63,7 +75,7 @@ func (s *Store) LatestSnapshot(ctx context.Context) (*state.Snapshot, uint64, er
 }
 
 // LatestSnapshotInfo returns the height and size of the most recent
-// state snapshot stored in the database.
+// full state snapshot stored in the database.
 func (s *Store) LatestSnapshotInfo(ctx context.Context) (height uint64, size uint64, err error) {
 	const q = `
 		SELECT height, octet_length(data) FROM snapshots ORDER BY height DESC LIMIT 1
@@ -96,9 +108,29 @@ func (s *Store) SaveBlock(ctx context.Context, block *bc.Block) error {
 }
 
 // SaveSnapshot saves a state snapshot to the database.
+// If this Store saved the previous snapshot, and hasn't
+// saved a full snapshot in a while, it saves only the
+// difference between the two.
 func (s *Store) SaveSnapshot(ctx context.Context, height uint64, snapshot *state.Snapshot) error {
-	err := storeStateSnapshot(ctx, s.db, snapshot, height)
-	return errors.Wrap(err, "saving state tree")
+	s.snapshotMu.Lock()
+	defer s.snapshotMu.Unlock()
+
+	var err error
+	if s.lastSnapshot != nil && height > s.lastSnapHeight && s.nSnapDiffs < fullSnapshotInterval-1 {
+		d := state.NewDiff(s.lastSnapshot, snapshot)
+		err = storeSnapshotDiff(ctx, s.db, d, s.lastSnapHeight, height)
+		s.nSnapDiffs++
+	} else {
+		err = storeStateSnapshot(ctx, s.db, snapshot, height)
+		s.nSnapDiffs = 0
+	}
+	if err != nil {
+		s.lastSnapshot = nil // start over with a full snapshot
+		return errors.Wrap(err, "saving state tree")
+	}
+	s.lastSnapshot = state.Copy(snapshot)
+	s.lastSnapHeight = height
+	return nil
 }
 
 func (s *Store) FinalizeBlock(ctx context.Context, height uint64) error {
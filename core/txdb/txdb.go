@@ -11,32 +11,29 @@ import (
 	"chain/log"
 )
 
-func ListenBlocks(ctx context.Context, dbURL string) (<-chan uint64, error) {
-	listener, err := pg.NewListener(ctx, dbURL, "newblock")
+// ListenBlocks returns a channel that receives the height of
+// each block as it is finalized, until ctx is done.
+// If the connection to the database drops, the channel
+// receives the current height once it reconnects.
+func (s *Store) ListenBlocks(ctx context.Context, dbURL string) (<-chan uint64, error) {
+	payloads, err := pg.Subscribe(ctx, dbURL, "newblock", func(ctx context.Context) (string, error) {
+		height, err := s.Height(ctx)
+		return strconv.FormatUint(height, 10), err
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	c := make(chan uint64)
 	go func() {
-		defer func() {
-			listener.Close()
-			close(c)
-		}()
-
-		for {
-			select {
-			case <-ctx.Done():
+		defer close(c)
+		for payload := range payloads {
+			height, err := strconv.ParseUint(payload, 10, 64)
+			if err != nil {
+				log.Error(ctx, errors.Wrap(err, "parsing db notification payload"))
 				return
-
-			case n := <-listener.Notify:
-				height, err := strconv.ParseUint(n.Extra, 10, 64)
-				if err != nil {
-					log.Error(ctx, errors.Wrap(err, "parsing db notification payload"))
-					return
-				}
-				c <- height
 			}
+			c <- height
 		}
 	}()
 
@@ -110,7 +110,7 @@ func TestListenFinalizeBlocks(t *testing.T) {
 	store := NewStore(db)
 
 	// Start listening for new blocks.
-	heightCh, err := ListenBlocks(ctx, dbURL)
+	heightCh, err := store.ListenBlocks(ctx, dbURL)
 	if err != nil {
 		t.Fatal(err)
 	}
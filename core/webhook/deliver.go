@@ -0,0 +1,323 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"chain/core/query"
+	"chain/errors"
+	"chain/log"
+)
+
+const (
+	// batchSize is the most transactions
+	// sent in a single delivery.
+	batchSize = 100
+
+	// maxAttempts is the number of failed deliveries of a
+	// batch after which it is moved to the dead letters:
+	// about four hours of retries.
+	maxAttempts = 16
+
+	minBackoff = time.Second
+	maxBackoff = time.Hour
+)
+
+// HeaderSignature is the header of a delivery holding its
+// signatures, in the form
+//
+//	t=<timestamp>,v1=<signature>[,v1=<signature>]
+//
+// where timestamp is the time of the delivery in seconds since
+// the Unix epoch, and each signature is the hex-encoded
+// HMAC-SHA256, keyed by a secret of the webhook, of the
+// timestamp, a period, and the body. While a rotated secret
+// is still valid, a delivery carries a signature by each of
+// the webhook's secrets. Receivers should check it with Verify
+// or an equivalent implementation, and ignore schemes other
+// than v1.
+const HeaderSignature = "Chain-Webhook-Signature"
+
+// DefaultTolerance is the age, and the clock skew, beyond
+// which receivers should reject a delivery, so that a
+// recorded delivery cannot be replayed later.
+const DefaultTolerance = 5 * time.Minute
+
+var (
+	ErrBadSignature   = errors.New("webhook signature does not match")
+	ErrStaleSignature = errors.New("webhook signature timestamp outside tolerance")
+	ErrBadHeader      = errors.New("malformed webhook signature header")
+)
+
+var defaultClient = &http.Client{Timeout: 10 * time.Second}
+
+// Payload is the body of a delivery.
+type Payload struct {
+	WebhookID string               `json:"webhook_id"`
+	Items     []*query.AnnotatedTx `json:"items"`
+
+	// After is the cursor following the last of the items.
+	After string `json:"after"`
+}
+
+// Sign returns the v1 signature, by secret, of a
+// delivery of body made at the Unix time timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignatureHeader returns the value of HeaderSignature for a
+// delivery of body made at t, with a signature by each of
+// the non-empty secrets.
+func SignatureHeader(secrets []string, t time.Time, body []byte) string {
+	ts := t.Unix()
+	h := "t=" + strconv.FormatInt(ts, 10)
+	for _, secret := range secrets {
+		if secret != "" {
+			h += ",v1=" + Sign(secret, ts, body)
+		}
+	}
+	return h
+}
+
+// Verify checks that header, the value of HeaderSignature of
+// a delivery with the given body, has a v1 signature by secret,
+// and a timestamp no more than tolerance away from now.
+func Verify(header, secret string, body []byte, now time.Time, tolerance time.Duration) error {
+	var (
+		ts     int64
+		haveTS bool
+		sigs   []string
+	)
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			return errors.WithDetailf(ErrBadHeader, "bad element %q", part)
+		}
+		switch kv[0] {
+		case "t":
+			var err error
+			ts, err = strconv.ParseInt(kv[1], 10, 64)
+			if err != nil || haveTS {
+				return errors.WithDetail(ErrBadHeader, "bad or repeated timestamp")
+			}
+			haveTS = true
+		case "v1":
+			sigs = append(sigs, kv[1])
+		}
+	}
+	if !haveTS {
+		return errors.WithDetail(ErrBadHeader, "no timestamp")
+	}
+	if len(sigs) == 0 {
+		return errors.WithDetail(ErrBadHeader, "no v1 signature")
+	}
+
+	d := now.Sub(time.Unix(ts, 0))
+	if d > tolerance || d < -tolerance {
+		return errors.WithDetailf(ErrStaleSignature, "signed at %s", time.Unix(ts, 0).UTC())
+	}
+
+	want, _ := hex.DecodeString(Sign(secret, ts, body))
+	for _, sig := range sigs {
+		got, err := hex.DecodeString(sig)
+		if err == nil && hmac.Equal(got, want) {
+			return nil
+		}
+	}
+	return ErrBadSignature
+}
+
+// backoff returns how long to wait before the next
+// delivery of a batch after attempts failures.
+func backoff(attempts int) time.Duration {
+	d := minBackoff
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
+
+// Deliver checks every period for webhooks with newly indexed
+// transactions, and delivers them, until ctx is done. It must
+// run only in the leader process.
+//
+// A batch that fails is retried with exponential backoff. After
+// maxAttempts failures it is kept as a dead letter, and delivery
+// continues with the next batch.
+func (m *Manager) Deliver(ctx context.Context, period time.Duration) {
+	ticks := time.NewTicker(period)
+	defer ticks.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticks.C:
+			err := m.deliverDue(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Error(ctx, err)
+			}
+		}
+	}
+}
+
+func (m *Manager) deliverDue(ctx context.Context) error {
+	const q = `
+		SELECT id, url, filter, secret,
+			CASE WHEN previous_secret_expires_at > now() THEN previous_secret ELSE '' END,
+			after, attempts
+		FROM webhooks WHERE next_attempt_at <= now()
+	`
+	var hooks []*Webhook
+	rows, err := m.DB.Query(ctx, q)
+	if err != nil {
+		return errors.Wrap(err, "querying due webhooks")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hook Webhook
+		err := rows.Scan(&hook.ID, &hook.URL, &hook.Filter, &hook.Secret, &hook.previousSecret, &hook.After, &hook.Attempts)
+		if err != nil {
+			return errors.Wrap(err, "scanning webhook row")
+		}
+		hooks = append(hooks, &hook)
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err)
+	}
+
+	var wg sync.WaitGroup
+	for _, hook := range hooks {
+		wg.Add(1)
+		go func(hook *Webhook) {
+			defer wg.Done()
+			err := m.deliver(ctx, hook)
+			if err != nil && ctx.Err() == nil {
+				log.Error(ctx, err, "webhook ", hook.ID)
+			}
+		}(hook)
+	}
+	wg.Wait()
+	return nil
+}
+
+// deliver sends the transactions following hook.After to
+// hook.URL, a batch at a time, until it has caught up or
+// a delivery fails.
+func (m *Manager) deliver(ctx context.Context, hook *Webhook) error {
+	for {
+		after, err := query.DecodeTxAfter(hook.After)
+		if err != nil {
+			return errors.Wrap(err, "decoding webhook cursor")
+		}
+		txs, next, err := m.Indexer.TransactionsSince(ctx, hook.Filter, nil, after, batchSize)
+		if err != nil {
+			return errors.Wrap(err, "querying webhook transactions")
+		}
+		if len(txs) == 0 {
+			return nil
+		}
+
+		body, err := json.Marshal(Payload{WebhookID: hook.ID, Items: txs, After: next.String()})
+		if err != nil {
+			return errors.Wrap(err, "encoding webhook payload")
+		}
+		err = m.post(ctx, hook, body)
+		if err != nil {
+			return m.recordFailure(ctx, hook, next.String(), body, err)
+		}
+
+		const q = `
+			UPDATE webhooks SET after=$2, attempts=0, last_error='', next_attempt_at=now()
+			WHERE id=$1
+		`
+		_, err = m.DB.Exec(ctx, q, hook.ID, next.String())
+		if err != nil {
+			return errors.Wrap(err, "advancing webhook cursor")
+		}
+		hook.After, hook.Attempts = next.String(), 0
+		if len(txs) < batchSize {
+			return nil
+		}
+	}
+}
+
+// post sends body to hook.URL, and returns an error
+// unless the response has a 2xx status.
+func (m *Manager) post(ctx context.Context, hook *Webhook, body []byte) error {
+	req, err := http.NewRequest("POST", hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderSignature, SignatureHeader([]string{hook.Secret, hook.previousSecret}, time.Now(), body))
+
+	client := m.Client
+	if client == nil {
+		client = defaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 1<<16)) // allow the connection to be reused
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// recordFailure records a failed delivery of the batch in body,
+// which ends at the cursor next, and schedules the next attempt.
+// After maxAttempts, it moves the batch to the dead letters
+// instead, and advances the webhook past it.
+func (m *Manager) recordFailure(ctx context.Context, hook *Webhook, next string, body []byte, deliveryErr error) error {
+	hook.Attempts++
+	msg := deliveryErr.Error()
+	if hook.Attempts < maxAttempts {
+		const q = `
+			UPDATE webhooks
+			SET attempts=$2, last_error=$3, next_attempt_at=now() + $4 * interval '1 millisecond'
+			WHERE id=$1
+		`
+		_, err := m.DB.Exec(ctx, q, hook.ID, hook.Attempts, msg, int64(backoff(hook.Attempts)/time.Millisecond))
+		if err != nil {
+			return errors.Wrap(err, "recording webhook failure")
+		}
+		return errors.Wrapf(deliveryErr, "delivering to webhook (attempt %d)", hook.Attempts)
+	}
+
+	const q = `
+		WITH dead AS (
+			INSERT INTO webhook_dead_letters (webhook_id, after, payload, attempts, error)
+			VALUES ($1, $2, $3, $4, $5)
+		)
+		UPDATE webhooks SET after=$6, attempts=0, last_error=$5, next_attempt_at=now()
+		WHERE id=$1
+	`
+	_, err := m.DB.Exec(ctx, q, hook.ID, hook.After, string(body), hook.Attempts, msg, next)
+	if err != nil {
+		return errors.Wrap(err, "recording webhook dead letter")
+	}
+	return errors.Wrapf(deliveryErr, "giving up on webhook batch after %d attempts", hook.Attempts)
+}
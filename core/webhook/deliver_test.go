@@ -0,0 +1,112 @@
+package webhook
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"chain/errors"
+)
+
+func TestBackoff(t *testing.T) {
+	cases := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{13, maxBackoff},
+		{100, maxBackoff},
+	}
+	for _, c := range cases {
+		if got := backoff(c.attempts); got != c.want {
+			t.Errorf("backoff(%d) = %v want %v", c.attempts, got, c.want)
+		}
+	}
+}
+
+func TestPost(t *testing.T) {
+	const secret = "s3cret"
+	body := []byte(`{"webhook_id":"whk1","items":[],"after":"1:2-3"}`)
+
+	status := http.StatusOK
+	wantSecrets := []string{secret}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		got, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(body) {
+			t.Errorf("body = %s want %s", got, body)
+		}
+		h := req.Header.Get(HeaderSignature)
+		for _, s := range wantSecrets {
+			err := Verify(h, s, got, time.Now(), DefaultTolerance)
+			if err != nil {
+				t.Errorf("Verify(%q, %q) = %v", h, s, err)
+			}
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	m := &Manager{}
+	hook := &Webhook{ID: "whk1", URL: srv.URL, Secret: secret}
+	err := m.post(context.Background(), hook, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// During a rotation, deliveries are signed with both secrets.
+	hook.previousSecret = "old"
+	wantSecrets = []string{secret, "old"}
+	err = m.post(context.Background(), hook, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status = http.StatusInternalServerError
+	err = m.post(context.Background(), hook, body)
+	if err == nil {
+		t.Error("expected error for 500 response")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"webhook_id":"whk1"}`)
+	now := time.Unix(1490000000, 0)
+	sig := func(secret string, t time.Time) string {
+		return SignatureHeader([]string{secret}, t, body)
+	}
+
+	cases := []struct {
+		header string
+		secret string
+		body   []byte
+		want   error
+	}{
+		{sig("s", now), "s", body, nil},
+		{sig("s", now.Add(-4*time.Minute)), "s", body, nil},
+		{SignatureHeader([]string{"new", "old"}, now, body), "old", body, nil},
+		{SignatureHeader([]string{"new", "old"}, now, body), "new", body, nil},
+		{"v0=00," + sig("s", now), "s", body, nil}, // unknown schemes are ignored
+		{sig("s", now), "other", body, ErrBadSignature},
+		{sig("s", now), "s", []byte(`{"webhook_id":"whk2"}`), ErrBadSignature},
+		{sig("s", now.Add(-6*time.Minute)), "s", body, ErrStaleSignature},
+		{sig("s", now.Add(6*time.Minute)), "s", body, ErrStaleSignature},
+		{"v1=" + Sign("s", now.Unix(), body), "s", body, ErrBadHeader},
+		{"t=1490000000", "s", body, ErrBadHeader},
+		{"t=x,v1=00", "s", body, ErrBadHeader},
+		{"", "s", body, ErrBadHeader},
+	}
+	for _, c := range cases {
+		err := Verify(c.header, c.secret, c.body, now, DefaultTolerance)
+		if errors.Root(err) != c.want {
+			t.Errorf("Verify(%q, %q, %s) = %v want %v", c.header, c.secret, c.body, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+// Package webhook implements Chain Core's webhooks, which
+// notify an external URL of newly indexed transactions
+// matching a filter.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"chain/core/query"
+	"chain/database/pg"
+	"chain/errors"
+	"chain/net/http/httpjson"
+)
+
+var (
+	ErrDuplicateAlias = errors.New("duplicate webhook alias")
+	ErrBadURL         = errors.New("invalid webhook url")
+)
+
+// Manager registers webhooks and delivers their notifications.
+type Manager struct {
+	DB      pg.DB
+	Indexer *query.Indexer
+
+	// Client makes the deliveries.
+	// If nil, a client with a 10-second timeout is used.
+	Client *http.Client
+}
+
+type Webhook struct {
+	ID     string  `json:"id"`
+	Alias  *string `json:"alias"`
+	URL    string  `json:"url"`
+	Filter string  `json:"filter"`
+	After  string  `json:"after"`
+
+	// Secret is the key of the HMAC signing each delivery.
+	// It is returned only when the webhook is created or
+	// its secret rotated.
+	Secret string `json:"secret,omitempty"`
+
+	// previousSecret is the secret replaced by the last
+	// rotation, if it is still valid. Deliveries are signed
+	// with it too.
+	previousSecret string
+
+	// Attempts is the number of failed attempts
+	// to deliver the current batch, and LastError
+	// the error from the last of them.
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// DeadLetter is a batch of transactions that could not be
+// delivered to a webhook, kept so that it can be replayed.
+type DeadLetter struct {
+	ID        string `json:"id"`
+	WebhookID string `json:"webhook_id"`
+
+	// After is the webhook's cursor before the batch.
+	After    string `json:"after"`
+	Payload  string `json:"payload"`
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error"`
+}
+
+// Create registers a webhook that notifies u of the transactions
+// matching fil that are indexed after the cursor after.
+func (m *Manager) Create(ctx context.Context, alias, u, fil, after, clientToken string) (*Webhook, error) {
+	err := query.ValidateTransactionFilter(fil)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := url.Parse(u)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, errors.WithDetailf(ErrBadURL, "url %q must be an absolute http or https URL", u)
+	}
+
+	var secret [32]byte
+	_, err = rand.Read(secret[:])
+	if err != nil {
+		return nil, errors.Wrap(err, "generating webhook secret")
+	}
+
+	var ptrAlias *string
+	if alias != "" {
+		ptrAlias = &alias
+	}
+	hook := &Webhook{
+		Alias:  ptrAlias,
+		URL:    u,
+		Filter: fil,
+		After:  after,
+		Secret: hex.EncodeToString(secret[:]),
+	}
+	return insertWebhook(ctx, m.DB, hook, clientToken)
+}
+
+// insertWebhook adds the webhook to the database. If the webhook has a
+// client token, and there already exists a webhook with that client
+// token, insertWebhook will lookup and return the existing webhook,
+// without its secret, instead.
+func insertWebhook(ctx context.Context, db pg.DB, hook *Webhook, clientToken string) (*Webhook, error) {
+	const q = `
+		INSERT INTO webhooks (alias, url, filter, secret, after, client_token)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (client_token) DO NOTHING
+		RETURNING id
+	`
+	var alias sql.NullString
+	if hook.Alias != nil {
+		alias = sql.NullString{Valid: true, String: *hook.Alias}
+	}
+	nullToken := sql.NullString{
+		String: clientToken,
+		Valid:  clientToken != "",
+	}
+
+	err := db.QueryRow(ctx, q, alias, hook.URL, hook.Filter, hook.Secret, hook.After, nullToken).Scan(&hook.ID)
+	if pg.IsUniqueViolation(err) {
+		return nil, errors.WithDetail(ErrDuplicateAlias, "a webhook with the provided alias already exists")
+	} else if err == sql.ErrNoRows && clientToken != "" {
+		hook, err = findWebhook(ctx, db, "client_token", clientToken)
+		if err != nil {
+			return nil, errors.Wrap(err, "retrieving existing webhook")
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+const webhookColumns = `id, alias, url, filter, after, attempts, last_error`
+
+func scanWebhook(row interface {
+	Scan(...interface{}) error
+}) (*Webhook, error) {
+	var (
+		hook  Webhook
+		alias sql.NullString
+	)
+	err := row.Scan(&hook.ID, &alias, &hook.URL, &hook.Filter, &hook.After, &hook.Attempts, &hook.LastError)
+	if err != nil {
+		return nil, err
+	}
+	if alias.Valid {
+		hook.Alias = &alias.String
+	}
+	return &hook, nil
+}
+
+func findWebhook(ctx context.Context, db pg.DB, column, value string) (*Webhook, error) {
+	q := fmt.Sprintf(`SELECT %s FROM webhooks WHERE %s=$1`, webhookColumns, column)
+	hook, err := scanWebhook(db.QueryRow(ctx, q, value))
+	if err == sql.ErrNoRows {
+		err = errors.Sub(pg.ErrUserInputNotFound, err)
+		return nil, errors.WithDetailf(err, "%s: %s", column, value)
+	}
+	return hook, errors.Wrap(err)
+}
+
+// RotateSecret replaces the secret of the webhook with the given
+// id or, if id is empty, alias, and returns the webhook with its
+// new secret. Deliveries are signed with both the new and the old
+// secret until overlap has passed, so that receivers can switch
+// to the new secret without rejecting any delivery.
+//
+// Rotating again within the overlap ends the validity
+// of the oldest secret at once.
+func (m *Manager) RotateSecret(ctx context.Context, id, alias string, overlap time.Duration) (*Webhook, error) {
+	if overlap < 0 {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "overlap must not be negative")
+	}
+	var secret [32]byte
+	_, err := rand.Read(secret[:])
+	if err != nil {
+		return nil, errors.Wrap(err, "generating webhook secret")
+	}
+	newSecret := hex.EncodeToString(secret[:])
+
+	column, value := "id", id
+	if id == "" {
+		column, value = "alias", alias
+	}
+	q := fmt.Sprintf(`
+		UPDATE webhooks
+		SET previous_secret=secret, secret=$2,
+			previous_secret_expires_at=now() + $3 * interval '1 millisecond'
+		WHERE %s=$1
+		RETURNING %s
+	`, column, webhookColumns)
+	hook, err := scanWebhook(m.DB.QueryRow(ctx, q, value, newSecret, int64(overlap/time.Millisecond)))
+	if err == sql.ErrNoRows {
+		err = errors.Sub(pg.ErrUserInputNotFound, err)
+		return nil, errors.WithDetailf(err, "%s: %s", column, value)
+	} else if err != nil {
+		return nil, errors.Wrap(err, "rotating webhook secret")
+	}
+	hook.Secret = newSecret
+	return hook, nil
+}
+
+// Find returns the webhook with the given id or, if id is
+// empty, alias.
+func (m *Manager) Find(ctx context.Context, id, alias string) (*Webhook, error) {
+	if id != "" {
+		return findWebhook(ctx, m.DB, "id", id)
+	}
+	return findWebhook(ctx, m.DB, "alias", alias)
+}
+
+// Delete removes the webhook with the given id or, if id
+// is empty, alias, and its dead letters.
+func (m *Manager) Delete(ctx context.Context, id, alias string) error {
+	var q bytes.Buffer
+	q.WriteString(`DELETE FROM webhooks WHERE `)
+	if id != "" {
+		q.WriteString(`id=$1`)
+	} else {
+		q.WriteString(`alias=$1`)
+		id = alias
+	}
+
+	res, err := m.DB.Exec(ctx, q.String(), id)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.WithDetailf(pg.ErrUserInputNotFound, "could not find and delete webhook with id/alias=%s", id)
+	}
+	return nil
+}
+
+// Query returns the webhooks with IDs before after,
+// newest first.
+func (m *Manager) Query(ctx context.Context, after string, limit int) ([]*Webhook, string, error) {
+	const baseQ = `
+		SELECT %s FROM webhooks
+		WHERE ($1='' OR id < $1) ORDER BY id DESC LIMIT %d
+	`
+	rows, err := m.DB.Query(ctx, fmt.Sprintf(baseQ, webhookColumns, limit), after)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "executing webhooks query")
+	}
+	defer rows.Close()
+
+	hooks := make([]*Webhook, 0, limit)
+	for rows.Next() {
+		hook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "scanning webhook row")
+		}
+		after = hook.ID
+		hooks = append(hooks, hook)
+	}
+	return hooks, after, errors.Wrap(rows.Err())
+}
+
+// DeadLetters returns the dead letters of the webhook with
+// the given ID, or of every webhook if webhookID is empty,
+// with IDs before after, newest first.
+func (m *Manager) DeadLetters(ctx context.Context, webhookID, after string, limit int) ([]*DeadLetter, string, error) {
+	const baseQ = `
+		SELECT id, webhook_id, after, payload, attempts, error
+		FROM webhook_dead_letters
+		WHERE ($1='' OR webhook_id=$1) AND ($2=0 OR id < $2)
+		ORDER BY id DESC LIMIT %d
+	`
+	var afterID int64
+	if after != "" {
+		var err error
+		afterID, err = strconv.ParseInt(after, 10, 64)
+		if err != nil {
+			return nil, "", errors.WithDetail(httpjson.ErrBadRequest, "malformed pagination parameter `after`")
+		}
+	}
+	rows, err := m.DB.Query(ctx, fmt.Sprintf(baseQ, limit), webhookID, afterID)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "executing dead letters query")
+	}
+	defer rows.Close()
+
+	letters := make([]*DeadLetter, 0, limit)
+	for rows.Next() {
+		var l DeadLetter
+		err := rows.Scan(&l.ID, &l.WebhookID, &l.After, &l.Payload, &l.Attempts, &l.Error)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "scanning dead letter row")
+		}
+		after = l.ID
+		letters = append(letters, &l)
+	}
+	return letters, after, errors.Wrap(rows.Err())
+}
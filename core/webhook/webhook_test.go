@@ -0,0 +1,103 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"chain/database/pg"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+)
+
+func TestInsertWebhookRepeatToken(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.NewTx(t)
+	alias := "test_webhook"
+	hook := &Webhook{
+		Alias:  &alias,
+		URL:    "https://example.com/hook",
+		After:  "1:0-2",
+		Secret: "secret",
+	}
+
+	result0, err := insertWebhook(ctx, db, hook, "test_token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result1, err := insertWebhook(ctx, db, hook, "test_token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result0.ID != result1.ID {
+		t.Errorf("expected the same webhook, got %s and %s", result0.ID, result1.ID)
+	}
+	if result1.Secret != "" {
+		t.Error("existing webhook returned with its secret")
+	}
+
+	_, err = insertWebhook(ctx, db, hook, "other_token")
+	if errors.Root(err) != ErrDuplicateAlias {
+		t.Errorf("duplicate alias err = %v want %v", err, ErrDuplicateAlias)
+	}
+}
+
+func TestCreateBadURL(t *testing.T) {
+	m := &Manager{}
+	for _, u := range []string{"", "example.com/hook", "ftp://example.com/hook", "http://"} {
+		_, err := m.Create(context.Background(), "", u, "", "1:0-2", "")
+		if errors.Root(err) != ErrBadURL {
+			t.Errorf("Create(url=%q) err = %v want %v", u, err, ErrBadURL)
+		}
+	}
+}
+
+func TestRotateSecret(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.NewTx(t)
+	m := &Manager{DB: db}
+	hook, err := insertWebhook(ctx, db, &Webhook{URL: "https://example.com/hook", After: "1:0-2", Secret: "first"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := m.RotateSecret(ctx, hook.ID, "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.Secret == "" || rotated.Secret == "first" {
+		t.Fatalf("rotated secret = %q, want a new secret", rotated.Secret)
+	}
+
+	// Both secrets sign deliveries until the overlap has passed.
+	var secret, previous string
+	const q = `
+		SELECT secret, CASE WHEN previous_secret_expires_at > now() THEN previous_secret ELSE '' END
+		FROM webhooks WHERE id=$1
+	`
+	err = db.QueryRow(ctx, q, hook.ID).Scan(&secret, &previous)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret != rotated.Secret || previous != "first" {
+		t.Errorf("secrets = %q, %q want %q, %q", secret, previous, rotated.Secret, "first")
+	}
+
+	// Without an overlap, the old secret stops signing at once.
+	_, err = m.RotateSecret(ctx, hook.ID, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.QueryRow(ctx, q, hook.ID).Scan(&secret, &previous)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previous != "" {
+		t.Errorf("previous secret = %q, want none", previous)
+	}
+
+	_, err = m.RotateSecret(ctx, "whknonexistent", "", time.Hour)
+	if errors.Root(err) != pg.ErrUserInputNotFound {
+		t.Errorf("RotateSecret(nonexistent) err = %v want %v", err, pg.ErrUserInputNotFound)
+	}
+}
@@ -0,0 +1,122 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"chain/core/webhook"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/net/http/httpjson"
+)
+
+// POST /create-webhook
+func (a *API) createWebhook(ctx context.Context, in struct {
+	Alias  string
+	URL    string
+	Filter string
+
+	// ClientToken is the application's unique token for the webhook.
+	// Duplicate create webhook requests with the same client_token
+	// will only create one webhook.
+	ClientToken string `json:"client_token"`
+}) (*webhook.Webhook, error) {
+	after := fmt.Sprintf("%d:%d-%d", a.Chain.Height(), math.MaxInt32, uint64(math.MaxInt64))
+	return a.Webhooks.Create(ctx, in.Alias, in.URL, in.Filter, after, in.ClientToken)
+}
+
+// POST /get-webhook
+func (a *API) getWebhook(ctx context.Context, in struct {
+	ID    string `json:"id,omitempty"`
+	Alias string `json:"alias,omitempty"`
+}) (*webhook.Webhook, error) {
+	return a.Webhooks.Find(ctx, in.ID, in.Alias)
+}
+
+// defaultSecretOverlap is how long a rotated webhook secret
+// stays valid if the rotation request does not say.
+const defaultSecretOverlap = 24 * time.Hour
+
+// POST /rotate-webhook-secret
+func (a *API) rotateWebhookSecret(ctx context.Context, in struct {
+	ID    string `json:"id,omitempty"`
+	Alias string `json:"alias,omitempty"`
+
+	// Overlap is how long deliveries stay signed with the
+	// old secret as well as the new one.
+	Overlap *chainjson.Duration `json:"overlap,omitempty"`
+}) (*webhook.Webhook, error) {
+	overlap := defaultSecretOverlap
+	if in.Overlap != nil {
+		overlap = in.Overlap.Duration
+	}
+	return a.Webhooks.RotateSecret(ctx, in.ID, in.Alias, overlap)
+}
+
+// POST /delete-webhook
+func (a *API) deleteWebhook(ctx context.Context, in struct {
+	ID    string `json:"id,omitempty"`
+	Alias string `json:"alias,omitempty"`
+}) error {
+	return a.Webhooks.Delete(ctx, in.ID, in.Alias)
+}
+
+// listWebhooks is an http handler for listing webhooks.
+// It does not take a filter.
+//
+// POST /list-webhooks
+func (a *API) listWebhooks(ctx context.Context, in requestQuery) (page, error) {
+	limit, err := a.pageSize(in.PageSize)
+	if err != nil {
+		return page{}, err
+	}
+
+	hooks, after, err := a.Webhooks.Query(ctx, in.After, limit)
+	if err != nil {
+		return page{}, errors.Wrap(err, "running webhook query")
+	}
+
+	out := in
+	out.After = after
+	return page{
+		Items:    httpjson.Array(hooks),
+		LastPage: len(hooks) < limit,
+		Next:     out,
+	}, nil
+}
+
+type deadLetterQuery struct {
+	requestQuery
+	WebhookID string `json:"webhook_id,omitempty"`
+}
+
+// listWebhookDeadLetters is an http handler for listing the
+// batches that could not be delivered to a webhook, or to
+// any webhook if webhook_id is omitted, newest first.
+//
+// POST /list-webhook-dead-letters
+func (a *API) listWebhookDeadLetters(ctx context.Context, in deadLetterQuery) (interface{}, error) {
+	limit, err := a.pageSize(in.PageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	letters, after, err := a.Webhooks.DeadLetters(ctx, in.WebhookID, in.After, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "running dead letter query")
+	}
+
+	out := in
+	out.After = after
+	return struct {
+		Items    interface{}     `json:"items"`
+		Next     deadLetterQuery `json:"next"`
+		LastPage bool            `json:"last_page"`
+	}{
+		Items:    httpjson.Array(letters),
+		Next:     out,
+		LastPage: len(letters) < limit,
+	}, nil
+}
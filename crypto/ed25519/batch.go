@@ -0,0 +1,168 @@
+package ed25519
+
+import (
+	cryptorand "crypto/rand"
+	"crypto/sha512"
+	"sort"
+	"strconv"
+
+	"chain/crypto/ed25519/internal/edwards25519"
+)
+
+// VerifyBatch reports whether, for every i, sigs[i] is a valid
+// signature of msgs[i] by pubs[i]. If not, it also returns the
+// indexes of the invalid signatures, in increasing order.
+//
+// It checks all the signatures at once, as a random linear
+// combination of their verification equations, which takes
+// much less time than checking each one with Verify. Only if
+// that fails does it check them one at a time.
+//
+// VerifyBatch checks the cofactored verification equation,
+// [8][S]B = [8]R + [8][k]A. It agrees with Verify on every
+// signature made by Sign. It differs only on a signature
+// deliberately built from points of small order, which it
+// accepts and Verify rejects. So it must not replace Verify
+// where every node has to reach the same answer, such as in
+// the VM.
+//
+// It will panic if the slices differ in length, or if any
+// public key's length is not PublicKeySize.
+func VerifyBatch(pubs []PublicKey, msgs, sigs [][]byte) (bool, []int) {
+	if len(pubs) != len(msgs) || len(pubs) != len(sigs) {
+		panic("ed25519: VerifyBatch arguments differ in length")
+	}
+
+	var bad []int
+	entries := make([]*batchEntry, 0, len(pubs))
+	for i := range pubs {
+		e, ok := newBatchEntry(pubs[i], msgs[i], sigs[i])
+		if !ok {
+			bad = append(bad, i)
+			continue
+		}
+		e.index = i
+		entries = append(entries, e)
+	}
+
+	if len(entries) > 0 && !verifyEntries(entries, true) {
+		// Find the bad ones.
+		for _, e := range entries {
+			if !verifyEntries([]*batchEntry{e}, false) {
+				bad = append(bad, e.index)
+			}
+		}
+		sort.Ints(bad)
+	}
+	return len(bad) == 0, bad
+}
+
+// batchEntry holds the parts of one signature's
+// verification equation, [S]B = R + [k]A,
+// with R and A negated.
+type batchEntry struct {
+	index int
+	negR  edwards25519.ExtendedGroupElement
+	negA  edwards25519.ExtendedGroupElement
+	s, k  [32]byte
+}
+
+func newBatchEntry(publicKey PublicKey, message, sig []byte) (*batchEntry, bool) {
+	if l := len(publicKey); l != PublicKeySize {
+		panic("ed25519: bad public key length: " + strconv.Itoa(l))
+	}
+	if len(sig) != SignatureSize || sig[63]&224 != 0 {
+		return nil, false
+	}
+
+	e := new(batchEntry)
+	var buf [32]byte
+	copy(buf[:], publicKey)
+	if !e.negA.FromBytes(&buf) {
+		return nil, false
+	}
+
+	// Verify compares the encoding of R, so
+	// accept only canonical encodings here.
+	copy(buf[:], sig[:32])
+	if !e.negR.FromBytes(&buf) {
+		return nil, false
+	}
+	var check [32]byte
+	e.negR.ToBytes(&check)
+	if check != buf {
+		return nil, false
+	}
+
+	edwards25519.FeNeg(&e.negA.X, &e.negA.X)
+	edwards25519.FeNeg(&e.negA.T, &e.negA.T)
+	edwards25519.FeNeg(&e.negR.X, &e.negR.X)
+	edwards25519.FeNeg(&e.negR.T, &e.negR.T)
+
+	h := sha512.New()
+	h.Write(sig[:32])
+	h.Write(publicKey)
+	h.Write(message)
+	var digest [64]byte
+	h.Sum(digest[:0])
+	edwards25519.ScReduce(&e.k, &digest)
+
+	copy(e.s[:], sig[32:])
+	return e, true
+}
+
+// verifyEntries reports whether
+//
+//	[8]([Σ z·S]B - Σ [z]R - Σ [z·k]A)
+//
+// is the identity. If randomize is true, each z is
+// a random 128-bit scalar; otherwise z is 1, which
+// is how a single entry is checked.
+func verifyEntries(entries []*batchEntry, randomize bool) bool {
+	zs := make([][32]byte, len(entries))
+	if randomize {
+		var rnd [16]byte
+		for i := range zs {
+			_, err := cryptorand.Read(rnd[:])
+			if err != nil {
+				return false // the caller checks each entry by itself
+			}
+			copy(zs[i][:16], rnd[:])
+		}
+	} else {
+		for i := range zs {
+			zs[i][0] = 1
+		}
+	}
+
+	var (
+		zero    [32]byte
+		sumS    [32]byte
+		scalars = make([]*[32]byte, 0, 2*len(entries))
+		points  = make([]*edwards25519.ExtendedGroupElement, 0, 2*len(entries))
+	)
+	for i, e := range entries {
+		z := &zs[i]
+		edwards25519.ScMulAdd(&sumS, z, &e.s, &sumS)
+
+		zk := new([32]byte)
+		edwards25519.ScMulAdd(zk, z, &e.k, &zero)
+
+		scalars = append(scalars, z, zk)
+		points = append(points, &e.negR, &e.negA)
+	}
+
+	var p edwards25519.ProjectiveGroupElement
+	edwards25519.GeMultiScalarMultVartime(&p, scalars, points, &sumS)
+
+	// Multiply by the cofactor.
+	var t edwards25519.CompletedGroupElement
+	for i := 0; i < 3; i++ {
+		p.Double(&t)
+		t.ToProjective(&p)
+	}
+
+	var enc [32]byte
+	p.ToBytes(&enc)
+	return enc == [32]byte{1}
+}
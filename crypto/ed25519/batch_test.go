@@ -0,0 +1,81 @@
+package ed25519
+
+import (
+	"crypto/rand"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func batchFixture(t testing.TB, n int) (pubs []PublicKey, msgs, sigs [][]byte) {
+	for i := 0; i < n; i++ {
+		pub, priv, err := GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := []byte(fmt.Sprintf("message %d", i))
+		pubs = append(pubs, pub)
+		msgs = append(msgs, msg)
+		sigs = append(sigs, Sign(priv, msg))
+	}
+	return pubs, msgs, sigs
+}
+
+func TestVerifyBatch(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 7, 64} {
+		pubs, msgs, sigs := batchFixture(t, n)
+		ok, bad := VerifyBatch(pubs, msgs, sigs)
+		if !ok || len(bad) != 0 {
+			t.Errorf("VerifyBatch(%d valid) = %v, %v, want true, []", n, ok, bad)
+		}
+	}
+
+	pubs, msgs, sigs := batchFixture(t, 10)
+	msgs[2] = []byte("wrong message")
+	sigs[5] = append([]byte(nil), sigs[5]...)
+	sigs[5][40] ^= 1
+	sigs[7] = sigs[7][:SignatureSize-1]
+	pubs[9] = pubs[8]
+
+	ok, bad := VerifyBatch(pubs, msgs, sigs)
+	if want := []int{2, 5, 7, 9}; ok || !reflect.DeepEqual(bad, want) {
+		t.Errorf("VerifyBatch = %v, %v, want false, %v", ok, bad, want)
+	}
+	for i := range pubs {
+		if Verify(pubs[i], msgs[i], sigs[i]) == contains(bad, i) {
+			t.Errorf("VerifyBatch and Verify disagree on signature %d", i)
+		}
+	}
+}
+
+func contains(a []int, x int) bool {
+	for _, y := range a {
+		if x == y {
+			return true
+		}
+	}
+	return false
+}
+
+func BenchmarkVerifyBatch64(b *testing.B) {
+	pubs, msgs, sigs := batchFixture(b, 64)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ok, _ := VerifyBatch(pubs, msgs, sigs)
+		if !ok {
+			b.Fatal("signatures not valid")
+		}
+	}
+}
+
+func BenchmarkVerify64(b *testing.B) {
+	pubs, msgs, sigs := batchFixture(b, 64)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := range pubs {
+			if !Verify(pubs[j], msgs[j], sigs[j]) {
+				b.Fatal("signature not valid")
+			}
+		}
+	}
+}
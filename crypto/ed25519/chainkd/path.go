@@ -0,0 +1,192 @@
+package chainkd
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrBadPath      = errors.New("bad key path")
+	ErrHardenedPath = errors.New("hardened key path cannot be derived from an xpub")
+)
+
+// Key spaces of the keys Chain Core derives from
+// the xpubs of a signer. See SignerPath.
+const (
+	AssetKeySpace    byte = 0
+	AccountKeySpace  byte = 1
+	ReceiverKeySpace byte = 2
+)
+
+// A PathStep is one child derivation in a Path.
+type PathStep struct {
+	Selector []byte
+	Hardened bool
+}
+
+// A Path is a sequence of child derivations.
+//
+// Its text form is "m" followed by one element per step,
+// each preceded by a slash, as in "m/1'/2/3". An element
+// is either a decimal index, the selector being its eight
+// bytes in little-endian order, or "x" and the selector in
+// hex, as in "x0102". A trailing ' marks a hardened step.
+type Path []PathStep
+
+// NewPath returns the path of non-hardened
+// steps with the given selectors.
+func NewPath(selectors [][]byte) Path {
+	p := make(Path, 0, len(selectors))
+	for _, sel := range selectors {
+		p = append(p, PathStep{Selector: sel})
+	}
+	return p
+}
+
+// ParsePath parses the text form of a path.
+func ParsePath(s string) (Path, error) {
+	elems := strings.Split(s, "/")
+	if elems[0] != "m" {
+		return nil, ErrBadPath
+	}
+	p := make(Path, 0, len(elems)-1)
+	for _, e := range elems[1:] {
+		var step PathStep
+		if strings.HasSuffix(e, "'") {
+			step.Hardened = true
+			e = e[:len(e)-1]
+		}
+		if strings.HasPrefix(e, "x") {
+			sel, err := hex.DecodeString(e[1:])
+			if err != nil || len(sel) == 0 {
+				return nil, ErrBadPath
+			}
+			step.Selector = sel
+		} else {
+			n, err := strconv.ParseUint(e, 10, 64)
+			if err != nil {
+				return nil, ErrBadPath
+			}
+			step.Selector = indexSelector(n)
+		}
+		p = append(p, step)
+	}
+	return p, nil
+}
+
+// String returns the text form of p.
+func (p Path) String() string {
+	s := "m"
+	for _, step := range p {
+		if len(step.Selector) == 8 {
+			s += "/" + strconv.FormatUint(binary.LittleEndian.Uint64(step.Selector), 10)
+		} else {
+			s += "/x" + hex.EncodeToString(step.Selector)
+		}
+		if step.Hardened {
+			s += "'"
+		}
+	}
+	return s
+}
+
+func (p Path) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *Path) UnmarshalText(inp []byte) error {
+	q, err := ParsePath(string(inp))
+	if err != nil {
+		return err
+	}
+	*p = q
+	return nil
+}
+
+// Hardened reports whether any step of p is hardened.
+func (p Path) Hardened() bool {
+	for _, step := range p {
+		if step.Hardened {
+			return true
+		}
+	}
+	return false
+}
+
+// Selectors returns the selectors of the steps of p,
+// as taken by Derive. It returns ErrHardenedPath
+// if any step is hardened.
+func (p Path) Selectors() ([][]byte, error) {
+	if p.Hardened() {
+		return nil, ErrHardenedPath
+	}
+	sels := make([][]byte, 0, len(p))
+	for _, step := range p {
+		sels = append(sels, step.Selector)
+	}
+	return sels, nil
+}
+
+// DerivePath derives the descendant of xprv at path p.
+func (xprv XPrv) DerivePath(p Path) XPrv {
+	res := xprv
+	for _, step := range p {
+		res = res.Child(step.Selector, step.Hardened)
+	}
+	return res
+}
+
+// DerivePath derives the descendant of xpub at path p.
+// It returns ErrHardenedPath if any step is hardened.
+func (xpub XPub) DerivePath(p Path) (XPub, error) {
+	sels, err := p.Selectors()
+	if err != nil {
+		return XPub{}, err
+	}
+	return xpub.Derive(sels), nil
+}
+
+// SignerPath returns the path Chain Core uses to derive keys
+// in keySpace for a signer with the given key index, and,
+// if any itemIndexes are given, for items of that signer,
+// such as the control programs of an account.
+func SignerPath(keySpace byte, keyIndex uint64, itemIndexes ...uint64) Path {
+	var signerSel [9]byte
+	signerSel[0] = keySpace
+	binary.LittleEndian.PutUint64(signerSel[1:], keyIndex)
+	p := Path{{Selector: signerSel[:]}}
+	for _, idx := range itemIndexes {
+		p = append(p, PathStep{Selector: indexSelector(idx)})
+	}
+	return p
+}
+
+// AssetPath returns the path of the issuance
+// keys of the asset with the given key index.
+func AssetPath(keyIndex uint64) Path {
+	return SignerPath(AssetKeySpace, keyIndex)
+}
+
+// AccountPath returns the path of the keys of the account
+// with the given key index, and if programIndex is given,
+// of the control program with that index in the account.
+func AccountPath(keyIndex uint64, programIndex ...uint64) Path {
+	return SignerPath(AccountKeySpace, keyIndex, programIndex...)
+}
+
+// ReceiverPath returns the path of the keys of the receiver
+// with the given index in an account. Unlike AccountPath, it
+// does not depend on the account's key index, so receivers
+// can be derived again from the account's xpubs alone.
+func ReceiverPath(receiverIndex uint64) Path {
+	return SignerPath(ReceiverKeySpace, 0, receiverIndex)
+}
+
+func indexSelector(n uint64) []byte {
+	sel := make([]byte, 8)
+	binary.LittleEndian.PutUint64(sel, n)
+	return sel
+}
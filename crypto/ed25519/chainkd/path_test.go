@@ -0,0 +1,109 @@
+package chainkd
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	cases := []struct {
+		s    string
+		want Path
+	}{
+		{"m", Path{}},
+		{"m/1", Path{{Selector: []byte{1, 0, 0, 0, 0, 0, 0, 0}}}},
+		{"m/1'/2/3", Path{
+			{Selector: []byte{1, 0, 0, 0, 0, 0, 0, 0}, Hardened: true},
+			{Selector: []byte{2, 0, 0, 0, 0, 0, 0, 0}},
+			{Selector: []byte{3, 0, 0, 0, 0, 0, 0, 0}},
+		}},
+		{"m/x0102'", Path{{Selector: []byte{1, 2}, Hardened: true}}},
+	}
+	for _, c := range cases {
+		got, err := ParsePath(c.s)
+		if err != nil {
+			t.Errorf("ParsePath(%q) error = %v", c.s, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("ParsePath(%q) = %v, want %v", c.s, got, c.want)
+		}
+		if got.String() != c.s {
+			t.Errorf("ParsePath(%q).String() = %q", c.s, got.String())
+		}
+	}
+
+	for _, s := range []string{"", "1/2", "m/", "m/-1", "m/x", "m/xzz", "m/1''", "n/1"} {
+		_, err := ParsePath(s)
+		if err != ErrBadPath {
+			t.Errorf("ParsePath(%q) error = %v, want %v", s, err, ErrBadPath)
+		}
+	}
+}
+
+func TestDerivePath(t *testing.T) {
+	xprv, xpub, err := NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := ParsePath("m/1/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sels, err := p.Selectors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dpub, err := xpub.DerivePath(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dpub != xpub.Derive(sels) {
+		t.Error("XPub.DerivePath differs from XPub.Derive")
+	}
+	if xprv.DerivePath(p).XPub() != dpub {
+		t.Error("XPrv.DerivePath does not match XPub.DerivePath")
+	}
+
+	h, err := ParsePath("m/1'/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = xpub.DerivePath(h)
+	if err != ErrHardenedPath {
+		t.Errorf("XPub.DerivePath(%s) error = %v, want %v", h, err, ErrHardenedPath)
+	}
+	hprv := xprv.DerivePath(h)
+	if hprv.XPub() == dpub {
+		t.Errorf("hardened and non-hardened derivations are the same")
+	}
+	msg := []byte("hardened")
+	doverify(t, hprv.XPub(), msg, hprv.Sign(msg), "hardened xpub", "hardened xprv")
+}
+
+func TestSignerPath(t *testing.T) {
+	p := AccountPath(5, 7)
+	want := [][]byte{
+		{AccountKeySpace, 5, 0, 0, 0, 0, 0, 0, 0},
+		{7, 0, 0, 0, 0, 0, 0, 0},
+	}
+	sels, err := p.Selectors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sels) != len(want) || !bytes.Equal(sels[0], want[0]) || !bytes.Equal(sels[1], want[1]) {
+		t.Errorf("AccountPath(5, 7) selectors = %x, want %x", sels, want)
+	}
+	if s := p.String(); s != "m/x010500000000000000/7" {
+		t.Errorf("AccountPath(5, 7).String() = %q", s)
+	}
+}
+
+func TestReceiverPath(t *testing.T) {
+	p := ReceiverPath(3)
+	if s := p.String(); s != "m/x020000000000000000/3" {
+		t.Errorf("ReceiverPath(3).String() = %q", s)
+	}
+}
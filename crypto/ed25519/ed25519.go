@@ -8,6 +8,12 @@
 //
 // These functions are also compatible with the “Ed25519” function defined in
 // https://tools.ietf.org/html/draft-irtf-cfrg-eddsa-05.
+//
+// VerifyBatch checks many signatures at once, but it is not a
+// drop-in replacement for Verify: the two disagree on signatures
+// built from points of small order. Consensus code, such as the
+// VM's CHECKSIG and CHECKMULTISIG, uses Verify only; moving it to
+// VerifyBatch would need a new VM version and is not done here.
 package ed25519
 
 // This code is a port of the public domain, “ref10” implementation of ed25519
@@ -0,0 +1,145 @@
+package threshold
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+
+	"chain/crypto/ed25519/internal/edwards25519"
+)
+
+// A Scalar is an integer modulo the order of
+// the Ed25519 base point, little-endian.
+type Scalar [32]byte
+
+// A Point is the encoding of a point on the Ed25519 curve.
+type Point [32]byte
+
+var (
+	scZero Scalar
+	scOne  = Scalar{1}
+
+	// scLMinus1 is the group order minus 1.
+	scLMinus1 = Scalar{
+		0xec, 0xd3, 0xf5, 0x5c, 0x1a, 0x63, 0x12, 0x58,
+		0xd6, 0x9c, 0xf7, 0xa2, 0xde, 0xf9, 0xde, 0x14,
+		0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0x10,
+	}
+
+	// scLMinus2 is the group order minus 2,
+	// the exponent that inverts a scalar.
+	scLMinus2 = Scalar{
+		0xeb, 0xd3, 0xf5, 0x5c, 0x1a, 0x63, 0x12, 0x58,
+		0xd6, 0x9c, 0xf7, 0xa2, 0xde, 0xf9, 0xde, 0x14,
+		0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0x10,
+	}
+)
+
+func (s Scalar) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(s[:])), nil
+}
+
+func (s *Scalar) UnmarshalText(inp []byte) error {
+	return unmarshalHex32((*[32]byte)(s), inp)
+}
+
+func (p Point) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(p[:])), nil
+}
+
+func (p *Point) UnmarshalText(inp []byte) error {
+	return unmarshalHex32((*[32]byte)(p), inp)
+}
+
+func unmarshalHex32(dst *[32]byte, inp []byte) error {
+	if len(inp) != 64 {
+		return ErrBadEncoding
+	}
+	_, err := hex.Decode(dst[:], inp)
+	if err != nil {
+		return ErrBadEncoding
+	}
+	return nil
+}
+
+func randomScalar(r io.Reader) (Scalar, error) {
+	var buf [64]byte
+	_, err := io.ReadFull(r, buf[:])
+	if err != nil {
+		return Scalar{}, err
+	}
+	var s Scalar
+	edwards25519.ScReduce((*[32]byte)(&s), &buf)
+	return s, nil
+}
+
+func scalarFromIndex(i uint32) Scalar {
+	var s Scalar
+	binary.LittleEndian.PutUint32(s[:], i)
+	return s
+}
+
+// scMulAdd returns a*b + c.
+func scMulAdd(a, b, c Scalar) Scalar {
+	var s Scalar
+	edwards25519.ScMulAdd((*[32]byte)(&s), (*[32]byte)(&a), (*[32]byte)(&b), (*[32]byte)(&c))
+	return s
+}
+
+func scAdd(a, b Scalar) Scalar { return scMulAdd(a, scOne, b) }
+func scMul(a, b Scalar) Scalar { return scMulAdd(a, b, scZero) }
+func scSub(a, b Scalar) Scalar { return scMulAdd(b, scLMinus1, a) }
+
+// scInvert returns 1/a, computed as a^(l-2).
+func scInvert(a Scalar) Scalar {
+	res := scOne
+	for i := 255; i >= 0; i-- {
+		res = scMul(res, res)
+		if scLMinus2[i>>3]>>uint(i&7)&1 == 1 {
+			res = scMul(res, a)
+		}
+	}
+	return res
+}
+
+// scalarBaseMult returns s*B.
+func scalarBaseMult(s Scalar) Point {
+	var P edwards25519.ExtendedGroupElement
+	edwards25519.GeScalarMultBase(&P, (*[32]byte)(&s))
+	var p Point
+	P.ToBytes((*[32]byte)(&p))
+	return p
+}
+
+// scalarMult returns s*P.
+func scalarMult(s Scalar, p Point) (Point, error) {
+	var P edwards25519.ExtendedGroupElement
+	if !P.FromBytes((*[32]byte)(&p)) {
+		return Point{}, ErrBadEncoding
+	}
+	var R edwards25519.ProjectiveGroupElement
+	var zero [32]byte
+	edwards25519.GeDoubleScalarMultVartime(&R, (*[32]byte)(&s), &P, &zero)
+	var res Point
+	R.ToBytes((*[32]byte)(&res))
+	return res, nil
+}
+
+// addPoints returns p + q.
+func addPoints(p, q Point) (Point, error) {
+	var P, Q edwards25519.ExtendedGroupElement
+	if !P.FromBytes((*[32]byte)(&p)) || !Q.FromBytes((*[32]byte)(&q)) {
+		return Point{}, ErrBadEncoding
+	}
+	var Qc edwards25519.CachedGroupElement
+	Q.ToCached(&Qc)
+	var R edwards25519.CompletedGroupElement
+	edwards25519.GeAdd(&R, &P, &Qc)
+	var S edwards25519.ExtendedGroupElement
+	R.ToExtended(&S)
+	var res Point
+	S.ToBytes((*[32]byte)(&res))
+	return res, nil
+}
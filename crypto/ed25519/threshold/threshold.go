@@ -0,0 +1,354 @@
+// Package threshold implements t-of-n threshold signing
+// for Ed25519, after FROST (Komlo and Goldberg, 2020).
+//
+// A dealer splits a signing key into n shares, any t of
+// which can together make a signature that verifies with
+// ed25519.Verify under the single group public key. No
+// signer learns another's share, and the signing key is
+// never reassembled.
+//
+// Signing takes two rounds. In the first, each of t signers
+// makes a fresh Nonce and publishes its Commitment. In the
+// second, each signer, given the message and all t
+// commitments, makes its signature share with Share.Sign.
+// Anyone can then combine the shares with Aggregate.
+package threshold
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+
+	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/internal/edwards25519"
+)
+
+var (
+	ErrBadParams      = errors.New("threshold must be at least 1 and at most the number of shares")
+	ErrBadEncoding    = errors.New("bad scalar or point encoding")
+	ErrBadCommitments = errors.New("bad signing commitments")
+	ErrNonceUsed      = errors.New("signing nonce already used")
+	ErrBadSignature   = errors.New("signature shares do not combine to a valid signature")
+)
+
+// A Share is one signer's part of a threshold key.
+type Share struct {
+	Index     uint32 `json:"index"` // from 1 to n
+	Threshold int    `json:"threshold"`
+	Secret    Scalar `json:"secret"`
+	GroupKey  Point  `json:"group_key"`
+
+	// PublicShares holds Secret*B for every share,
+	// in order of index, to check signature shares.
+	PublicShares []Point `json:"public_shares"`
+}
+
+// Deal makes a new signing key and splits it into n
+// shares, any t of which can sign. It returns the group
+// public key and the shares. The caller must give each
+// share to a different signer and keep none of them.
+// If r is nil, crypto/rand.Reader is used.
+func Deal(t, n int, r io.Reader) (ed25519.PublicKey, []*Share, error) {
+	if t < 1 || t > n || n >= 1<<32-1 {
+		return nil, nil, ErrBadParams
+	}
+	if r == nil {
+		r = rand.Reader
+	}
+
+	// The key is coeffs[0], shared with a random
+	// polynomial of degree t-1.
+	coeffs := make([]Scalar, t)
+	for i := range coeffs {
+		c, err := randomScalar(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		coeffs[i] = c
+	}
+	groupKey := scalarBaseMult(coeffs[0])
+
+	shares := make([]*Share, n)
+	publicShares := make([]Point, n)
+	for i := range shares {
+		x := scalarFromIndex(uint32(i + 1))
+		y := scZero
+		for j := t - 1; j >= 0; j-- {
+			y = scMulAdd(y, x, coeffs[j])
+		}
+		shares[i] = &Share{
+			Index:     uint32(i + 1),
+			Threshold: t,
+			Secret:    y,
+			GroupKey:  groupKey,
+		}
+		publicShares[i] = scalarBaseMult(y)
+	}
+	for _, s := range shares {
+		s.PublicShares = publicShares
+	}
+	return ed25519.PublicKey(groupKey[:]), shares, nil
+}
+
+// A Nonce is a signer's secret for one signature.
+// It must never be used twice.
+type Nonce struct {
+	d, e Scalar
+	c    Commitment
+	used bool
+}
+
+// Commitment is the public part of a Nonce, which the
+// signer sends to whoever coordinates the signature.
+type Commitment struct {
+	Index uint32 `json:"index"`
+	D     Point  `json:"d"`
+	E     Point  `json:"e"`
+}
+
+// NewNonce makes a nonce for the signer holding s.
+// If r is nil, crypto/rand.Reader is used.
+func (s *Share) NewNonce(r io.Reader) (*Nonce, error) {
+	if r == nil {
+		r = rand.Reader
+	}
+	d, err := randomScalar(r)
+	if err != nil {
+		return nil, err
+	}
+	e, err := randomScalar(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Nonce{
+		d: d,
+		e: e,
+		c: Commitment{Index: s.Index, D: scalarBaseMult(d), E: scalarBaseMult(e)},
+	}, nil
+}
+
+// Commitment returns the commitment to n.
+func (n *Nonce) Commitment() Commitment {
+	return n.c
+}
+
+// Sign returns the signer's share of the signature of msg,
+// using nonce, which it then marks used. Commitments must
+// hold the commitment of each signer taking part, this
+// one's among them, and at least s.Threshold in all.
+func (s *Share) Sign(msg []byte, nonce *Nonce, commitments []Commitment) (Scalar, error) {
+	if nonce.used {
+		return Scalar{}, ErrNonceUsed
+	}
+	commitments, err := sortCommitments(commitments, s.Threshold)
+	if err != nil {
+		return Scalar{}, err
+	}
+	var mine *Commitment
+	for i := range commitments {
+		if commitments[i].Index == s.Index {
+			mine = &commitments[i]
+		}
+	}
+	if mine == nil || *mine != nonce.c {
+		return Scalar{}, ErrBadCommitments
+	}
+
+	rhos, R, err := groupCommitment(msg, commitments)
+	if err != nil {
+		return Scalar{}, err
+	}
+	c := challenge(R, s.GroupKey, msg)
+	lambda := lagrange(s.Index, commitments)
+
+	nonce.used = true
+	z := scMulAdd(nonce.e, rhos[s.Index], nonce.d)
+	return scMulAdd(scMul(lambda, s.Secret), c, z), nil
+}
+
+// Aggregate combines the signature shares of the signers
+// with the given commitments into a signature of msg by
+// groupKey, which it checks. Shares are in the order of
+// commitments. If publicShares is given, as in a Share,
+// Aggregate also checks each share, and if the signature
+// is invalid, names the first bad one in the error.
+func Aggregate(groupKey ed25519.PublicKey, msg []byte, commitments []Commitment, shares []Scalar, publicShares []Point) ([]byte, error) {
+	if len(commitments) != len(shares) || len(groupKey) != ed25519.PublicKeySize {
+		return nil, ErrBadCommitments
+	}
+	byIndex := make(map[uint32]Scalar, len(shares))
+	for i, c := range commitments {
+		byIndex[c.Index] = shares[i]
+	}
+	sorted, err := sortCommitments(commitments, 1)
+	if err != nil {
+		return nil, err
+	}
+	rhos, R, err := groupCommitment(msg, sorted)
+	if err != nil {
+		return nil, err
+	}
+
+	z := scZero
+	for _, c := range sorted {
+		z = scAdd(z, byIndex[c.Index])
+	}
+	sig := append(R[:], z[:]...)
+	if ed25519.Verify(groupKey, msg, sig) {
+		return sig, nil
+	}
+
+	if publicShares != nil {
+		var Y Point
+		copy(Y[:], groupKey)
+		ch := challenge(R, Y, msg)
+		for _, c := range sorted {
+			if c.Index < 1 || int(c.Index) > len(publicShares) {
+				return nil, ErrBadCommitments
+			}
+			// z_i*B must equal D_i + rho_i*E_i + c*lambda_i*Y_i.
+			want, err := scalarMult(rhos[c.Index], c.E)
+			if err == nil {
+				want, err = addPoints(want, c.D)
+			}
+			var cy Point
+			if err == nil {
+				cy, err = scalarMult(scMul(ch, lagrange(c.Index, sorted)), publicShares[c.Index-1])
+			}
+			if err == nil {
+				want, err = addPoints(want, cy)
+			}
+			if err != nil || scalarBaseMult(byIndex[c.Index]) != want {
+				return nil, &ShareError{Index: c.Index}
+			}
+		}
+	}
+	return nil, ErrBadSignature
+}
+
+// ShareError is returned by Aggregate
+// when a signer's share is invalid.
+type ShareError struct {
+	Index uint32
+}
+
+func (e *ShareError) Error() string {
+	return "invalid signature share from signer " + itoa(e.Index)
+}
+
+// sortCommitments returns a copy of commitments sorted by
+// index, after checking that there are at least min, with
+// distinct indexes, and that their points are valid.
+func sortCommitments(commitments []Commitment, min int) ([]Commitment, error) {
+	if len(commitments) < min || len(commitments) == 0 {
+		return nil, ErrBadCommitments
+	}
+	sorted := append([]Commitment(nil), commitments...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Index < sorted[j].Index })
+	for i, c := range sorted {
+		if c.Index == 0 || (i > 0 && c.Index == sorted[i-1].Index) {
+			return nil, ErrBadCommitments
+		}
+		var P edwards25519.ExtendedGroupElement
+		if !P.FromBytes((*[32]byte)(&c.D)) || !P.FromBytes((*[32]byte)(&c.E)) {
+			return nil, ErrBadCommitments
+		}
+	}
+	return sorted, nil
+}
+
+// groupCommitment returns the binding factor of each signer
+// and the group commitment R = sum of D_i + rho_i*E_i.
+// Commitments must be sorted.
+func groupCommitment(msg []byte, commitments []Commitment) (map[uint32]Scalar, Point, error) {
+	var encoded []byte
+	for _, c := range commitments {
+		var idx [4]byte
+		binary.LittleEndian.PutUint32(idx[:], c.Index)
+		encoded = append(encoded, idx[:]...)
+		encoded = append(encoded, c.D[:]...)
+		encoded = append(encoded, c.E[:]...)
+	}
+
+	rhos := make(map[uint32]Scalar, len(commitments))
+	var R Point
+	for i, c := range commitments {
+		h := sha512.New()
+		h.Write([]byte("chain threshold rho"))
+		var idx [4]byte
+		binary.LittleEndian.PutUint32(idx[:], c.Index)
+		h.Write(idx[:])
+		h.Write(msg)
+		h.Write(encoded)
+		rho := hashToScalar(h.Sum(nil))
+		rhos[c.Index] = rho
+
+		term, err := scalarMult(rho, c.E)
+		if err != nil {
+			return nil, Point{}, ErrBadCommitments
+		}
+		term, err = addPoints(term, c.D)
+		if err != nil {
+			return nil, Point{}, ErrBadCommitments
+		}
+		if i == 0 {
+			R = term
+		} else {
+			R, err = addPoints(R, term)
+			if err != nil {
+				return nil, Point{}, ErrBadCommitments
+			}
+		}
+	}
+	return rhos, R, nil
+}
+
+// challenge returns the Ed25519 challenge, H(R || Y || msg).
+func challenge(R, Y Point, msg []byte) Scalar {
+	h := sha512.New()
+	h.Write(R[:])
+	h.Write(Y[:])
+	h.Write(msg)
+	return hashToScalar(h.Sum(nil))
+}
+
+// lagrange returns the Lagrange coefficient at 0 of
+// signer i, among the signers with the given commitments.
+func lagrange(i uint32, commitments []Commitment) Scalar {
+	num, den := scOne, scOne
+	xi := scalarFromIndex(i)
+	for _, c := range commitments {
+		if c.Index == i {
+			continue
+		}
+		xj := scalarFromIndex(c.Index)
+		num = scMul(num, xj)
+		den = scMul(den, scSub(xj, xi))
+	}
+	return scMul(num, scInvert(den))
+}
+
+func hashToScalar(digest []byte) Scalar {
+	var buf [64]byte
+	copy(buf[:], digest)
+	var s Scalar
+	edwards25519.ScReduce((*[32]byte)(&s), &buf)
+	return s
+}
+
+func itoa(n uint32) string {
+	var buf [10]byte
+	i := len(buf)
+	for {
+		i--
+		buf[i] = byte('0' + n%10)
+		n /= 10
+		if n == 0 {
+			break
+		}
+	}
+	return string(buf[i:])
+}
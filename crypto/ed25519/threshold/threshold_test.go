@@ -0,0 +1,133 @@
+package threshold
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"chain/crypto/ed25519"
+)
+
+func sign(t *testing.T, shares []*Share, msg []byte) ([]Commitment, []Scalar) {
+	var (
+		nonces      []*Nonce
+		commitments []Commitment
+	)
+	for _, s := range shares {
+		n, err := s.NewNonce(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nonces = append(nonces, n)
+		commitments = append(commitments, n.Commitment())
+	}
+	var sigShares []Scalar
+	for i, s := range shares {
+		z, err := s.Sign(msg, nonces[i], commitments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sigShares = append(sigShares, z)
+	}
+	return commitments, sigShares
+}
+
+func TestThresholdSign(t *testing.T) {
+	cases := []struct {
+		t, n    int
+		signers []int
+	}{
+		{1, 1, []int{0}},
+		{2, 3, []int{0, 2}},
+		{2, 3, []int{2, 1, 0}},
+		{3, 5, []int{4, 1, 3}},
+	}
+	msg := []byte("block hash")
+	for _, c := range cases {
+		pub, shares, err := Deal(c.t, c.n, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var signers []*Share
+		for _, i := range c.signers {
+			signers = append(signers, shares[i])
+		}
+		commitments, sigShares := sign(t, signers, msg)
+		sig, err := Aggregate(pub, msg, commitments, sigShares, shares[0].PublicShares)
+		if err != nil {
+			t.Errorf("%d of %d, signers %v: Aggregate error = %v", c.t, c.n, c.signers, err)
+			continue
+		}
+		if !ed25519.Verify(pub, msg, sig) {
+			t.Errorf("%d of %d, signers %v: signature does not verify", c.t, c.n, c.signers)
+		}
+	}
+}
+
+func TestThresholdErrors(t *testing.T) {
+	for _, p := range [][2]int{{0, 1}, {3, 2}} {
+		_, _, err := Deal(p[0], p[1], nil)
+		if err != ErrBadParams {
+			t.Errorf("Deal(%d, %d) error = %v, want %v", p[0], p[1], err, ErrBadParams)
+		}
+	}
+
+	msg := []byte("block hash")
+	pub, shares, err := Deal(2, 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Too few signers.
+	n, err := shares[0].NewNonce(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = shares[0].Sign(msg, n, []Commitment{n.Commitment()})
+	if err != ErrBadCommitments {
+		t.Errorf("Sign with 1 of 2 error = %v, want %v", err, ErrBadCommitments)
+	}
+
+	// Nonce reuse.
+	n1, _ := shares[1].NewNonce(nil)
+	commitments := []Commitment{n.Commitment(), n1.Commitment()}
+	_, err = shares[0].Sign(msg, n, commitments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = shares[0].Sign(msg, n, commitments)
+	if err != ErrNonceUsed {
+		t.Errorf("Sign with used nonce error = %v, want %v", err, ErrNonceUsed)
+	}
+
+	// A bad share is named.
+	commitments, sigShares := sign(t, shares[1:], msg)
+	sigShares[1][0] ^= 1
+	_, err = Aggregate(pub, msg, commitments, sigShares, shares[0].PublicShares)
+	if e, ok := err.(*ShareError); !ok || e.Index != 3 {
+		t.Errorf("Aggregate with bad share error = %v, want share 3", err)
+	}
+	_, err = Aggregate(pub, msg, commitments, sigShares, nil)
+	if err != ErrBadSignature {
+		t.Errorf("Aggregate with bad share error = %v, want %v", err, ErrBadSignature)
+	}
+}
+
+func TestShareJSON(t *testing.T) {
+	_, shares, err := Deal(2, 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(shares[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := new(Share)
+	err = json.Unmarshal(b, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, shares[1]) {
+		t.Errorf("share round trip = %+v, want %+v", got, shares[1])
+	}
+}
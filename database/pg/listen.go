@@ -11,13 +11,84 @@ import (
 	"chain/net"
 )
 
+// pingInterval is how long a subscription waits without
+// a notification before checking its connection.
+const pingInterval = 90 * time.Second
+
 // NewListener creates a new pq.Listener and begins listening.
 func NewListener(ctx context.Context, dbURL, channel string) (*pq.Listener, error) {
 	// We want etcd name lookups so we use our own Dialer.
 	d := new(net.Dialer)
 	result := pq.NewDialListener(d, dbURL, 1*time.Second, 10*time.Second, func(ev pq.ListenerEventType, err error) {
-		log.Error(ctx, errors.Wrapf(err, "event in %s listener: %v", channel, ev))
+		if err != nil {
+			log.Error(ctx, errors.Wrapf(err, "event in %s listener: %v", channel, ev))
+		} else if ev == pq.ListenerEventReconnected {
+			log.Printkv(ctx, "at", "listener reconnected", "channel", channel)
+		}
 	})
 	err := result.Listen(channel)
 	return result, errors.Wrap(err, "listening to channel")
 }
+
+// Subscribe listens for notifications on channel and sends
+// their payloads on the returned channel until ctx is done,
+// then closes it.
+//
+// If the connection to the database drops, the listener
+// reconnects, waiting up to 10 seconds between attempts.
+// Notifications sent while it is disconnected are lost,
+// so after reconnecting Subscribe calls resync, if it is
+// not nil, and sends the payload it returns as though it
+// had been notified. Resync should look up the current
+// value of whatever the notifications announce.
+func Subscribe(ctx context.Context, dbURL, channel string, resync func(context.Context) (string, error)) (<-chan string, error) {
+	listener, err := NewListener(ctx, dbURL, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	c := make(chan string)
+	go func() {
+		defer func() {
+			listener.Close()
+			close(c)
+		}()
+
+		for {
+			var payload string
+			select {
+			case <-ctx.Done():
+				return
+
+			case n := <-listener.Notify:
+				if n != nil {
+					payload = n.Extra
+					break
+				}
+				// The listener sends nil after reconnecting.
+				if resync == nil {
+					continue
+				}
+				payload, err = resync(ctx)
+				if err != nil {
+					log.Error(ctx, errors.Wrapf(err, "resyncing %s listener", channel))
+					continue
+				}
+
+			case <-time.After(pingInterval):
+				// A connection that dies without being closed
+				// is noticed only when something is sent on it.
+				go listener.Ping()
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case c <- payload:
+			}
+		}
+	}()
+
+	return c, nil
+}
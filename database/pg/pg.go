@@ -35,7 +35,11 @@ func (d hapgDriver) Open(name string) (driver.Conn, error) {
 		return nil, err
 	}
 
-	return pq.Open(name)
+	conn, err := pq.Open(name)
+	if err != nil || StatementCacheSize <= 0 {
+		return conn, err
+	}
+	return newCachingConn(conn, StatementCacheSize), nil
 }
 
 func init() {
@@ -0,0 +1,48 @@
+package pg
+
+import (
+	"context"
+	"time"
+
+	"chain/database/sql"
+	"chain/errors"
+)
+
+// ErrNotReceiving is returned by ReplicaLag when the standby
+// has no running WAL receiver, so it can fall arbitrarily far
+// behind its primary without its replay lag showing it.
+var ErrNotReceiving = errors.New("pg: standby is not receiving WAL")
+
+// ReplicaLag reports how long ago the last transaction
+// replayed by the Postgres standby db was committed on
+// the primary. It is zero if db has replayed everything it
+// has received, or if db is not a standby. If db is a standby
+// whose WAL receiver is not running, it returns ErrNotReceiving.
+// It needs the pg_stat_wal_receiver view of Postgres 9.6 or
+// later; on an older standby it always returns an error.
+// It satisfies sql.LagFunc.
+func ReplicaLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	const q = `
+		SELECT pg_is_in_recovery(),
+			EXISTS (SELECT 1 FROM pg_stat_wal_receiver),
+			CASE
+				WHEN pg_last_xlog_receive_location() = pg_last_xlog_replay_location() THEN 0
+				ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+			END
+	`
+	var (
+		standby, receiving bool
+		secs               float64
+	)
+	err := db.QueryRow(ctx, q).Scan(&standby, &receiving, &secs)
+	if err != nil {
+		return 0, errors.Wrap(err, "querying replica lag")
+	}
+	if !standby {
+		return 0, nil
+	}
+	if !receiving {
+		return 0, ErrNotReceiving
+	}
+	return time.Duration(secs * float64(time.Second)), nil
+}
@@ -0,0 +1,87 @@
+package pg
+
+import (
+	"database/sql/driver"
+	"expvar"
+	"strings"
+
+	"github.com/golang/groupcache/lru"
+)
+
+// StatementCacheSize is the number of prepared statements
+// the hapg driver keeps on each connection, for reuse by
+// later queries with the same text. If it is 0, the default,
+// every query with arguments is parsed and planned anew.
+// It must be set before the first connection is opened.
+var StatementCacheSize = 0
+
+var (
+	stmtCacheHits   = expvar.NewInt("pg_stmt_cache_hits")
+	stmtCacheMisses = expvar.NewInt("pg_stmt_cache_misses")
+)
+
+// cachingConn is a connection that keeps
+// the statements it prepares in an LRU cache.
+//
+// It sends queries with arguments through Prepare,
+// so that they use the cache. Queries without arguments
+// still go to the underlying connection's Exec and Query,
+// which run them with the simple query protocol; that is
+// what allows several statements in a single string, as
+// in migrations.
+type cachingConn struct {
+	driver.Conn
+	stmts *lru.Cache
+}
+
+func newCachingConn(c driver.Conn, size int) *cachingConn {
+	stmts := lru.New(size)
+	stmts.OnEvicted = func(_ lru.Key, v interface{}) {
+		v.(driver.Stmt).Close()
+	}
+	return &cachingConn{Conn: c, stmts: stmts}
+}
+
+func (c *cachingConn) Prepare(query string) (driver.Stmt, error) {
+	// A COPY statement can only be used once.
+	if len(query) >= 4 && strings.EqualFold(query[:4], "COPY") {
+		return c.Conn.Prepare(query)
+	}
+	if v, ok := c.stmts.Get(query); ok {
+		stmtCacheHits.Add(1)
+		return cachedStmt{v.(driver.Stmt)}, nil
+	}
+	stmtCacheMisses.Add(1)
+	s, err := c.Conn.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	c.stmts.Add(query, s)
+	return cachedStmt{s}, nil
+}
+
+func (c *cachingConn) Exec(query string, args []driver.Value) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.Execer)
+	if !ok || len(args) > 0 {
+		return nil, driver.ErrSkip
+	}
+	return execer.Exec(query, args)
+}
+
+func (c *cachingConn) Query(query string, args []driver.Value) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.Queryer)
+	if !ok || len(args) > 0 {
+		return nil, driver.ErrSkip
+	}
+	return queryer.Query(query, args)
+}
+
+// cachedStmt is a statement owned by a cachingConn.
+// Closing it leaves it prepared for the next use;
+// it is closed when it is evicted from the cache,
+// or with its connection.
+type cachedStmt struct {
+	driver.Stmt
+}
+
+func (cachedStmt) Close() error { return nil }
@@ -0,0 +1,76 @@
+package pg
+
+import (
+	"database/sql/driver"
+	"testing"
+)
+
+type fakeConn struct {
+	prepared []string
+	closed   []string
+}
+
+func (c *fakeConn) Prepare(q string) (driver.Stmt, error) {
+	c.prepared = append(c.prepared, q)
+	return &fakeStmt{c, q}, nil
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return nil, driver.ErrSkip }
+
+type fakeStmt struct {
+	c *fakeConn
+	q string
+}
+
+func (s *fakeStmt) Close() error                               { s.c.closed = append(s.c.closed, s.q); return nil }
+func (s *fakeStmt) NumInput() int                              { return -1 }
+func (s *fakeStmt) Exec([]driver.Value) (driver.Result, error) { return driver.ResultNoRows, nil }
+func (s *fakeStmt) Query([]driver.Value) (driver.Rows, error)  { return nil, driver.ErrSkip }
+
+func TestStatementCache(t *testing.T) {
+	fc := new(fakeConn)
+	c := newCachingConn(fc, 2)
+
+	for _, q := range []string{"a", "b", "a", "c", "b", "COPY t FROM STDIN", "copy t FROM STDIN"} {
+		s, err := c.Prepare(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Close()
+	}
+
+	wantPrepared := []string{"a", "b", "c", "b", "COPY t FROM STDIN", "copy t FROM STDIN"}
+	if !equalStrings(fc.prepared, wantPrepared) {
+		t.Errorf("prepared %q, want %q", fc.prepared, wantPrepared)
+	}
+	// Statements are closed when evicted; COPY
+	// statements, which aren't cached, when used.
+	wantClosed := []string{"b", "a", "COPY t FROM STDIN", "copy t FROM STDIN"}
+	if !equalStrings(fc.closed, wantClosed) {
+		t.Errorf("closed %q, want %q", fc.closed, wantClosed)
+	}
+}
+
+func TestStatementCacheSkip(t *testing.T) {
+	c := newCachingConn(new(fakeConn), 2)
+	_, err := c.Exec("SELECT 1; SELECT 2", nil)
+	if err != driver.ErrSkip {
+		t.Errorf("Exec on conn without Execer: err = %v, want ErrSkip", err)
+	}
+	_, err = c.Query("SELECT $1", []driver.Value{int64(1)})
+	if err != driver.ErrSkip {
+		t.Errorf("Query with args: err = %v, want ErrSkip", err)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package sql
+
+import (
+	"context"
+	"strings"
+
+	"chain/errors"
+)
+
+// ErrBulkRowLen is returned by BulkInsert when a row
+// has a different number of values than there are columns.
+var ErrBulkRowLen = errors.New("row length does not match columns")
+
+// BulkInsert inserts rows into the named columns of table
+// using COPY FROM STDIN, which streams the rows to the
+// database without parsing or planning a statement for them.
+// It works with drivers, like lib/pq, that run COPY through
+// a prepared statement, one Exec per row.
+//
+// Unlike INSERT, COPY fails on the first row that violates
+// a constraint, and there is no equivalent of ON CONFLICT.
+// Writes that must be idempotent, like those of the query
+// indexer, should keep using INSERT ... SELECT unnest(...),
+// which also sends all rows in a single statement.
+func (tx *Tx) BulkInsert(ctx context.Context, table string, columns []string, rows [][]interface{}) error {
+	q := copyStatement(table, columns)
+	logQuery(ctx, q, nil)
+	s := startStmt(q, nil)
+	err := tx.bulkInsert(q, len(columns), rows)
+	if err != nil {
+		s.finish(ctx, -1, err)
+		return errors.Wrapf(err, "bulk inserting into %s", table)
+	}
+	s.finish(ctx, int64(len(rows)), nil)
+	return nil
+}
+
+func (tx *Tx) bulkInsert(q string, ncols int, rows [][]interface{}) error {
+	for i, row := range rows {
+		if len(row) != ncols {
+			return errors.WithDetailf(ErrBulkRowLen, "row %d has %d values, want %d", i, len(row), ncols)
+		}
+	}
+	stmt, err := tx.tx.Prepare(q)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, row := range rows {
+		_, err = stmt.Exec(row...)
+		if err != nil {
+			return err
+		}
+	}
+	// An Exec with no values ends the COPY.
+	_, err = stmt.Exec()
+	return err
+}
+
+func copyStatement(table string, columns []string) string {
+	quoted := make([]string, 0, len(columns))
+	for _, c := range columns {
+		quoted = append(quoted, quoteIdentifier(c))
+	}
+	return "COPY " + quoteIdentifier(table) + " (" + strings.Join(quoted, ", ") + ") FROM STDIN"
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
@@ -0,0 +1,88 @@
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+
+	"chain/errors"
+)
+
+// copyDriver records the statements it prepares
+// and the values each is executed with.
+type copyDriver struct {
+	prepared []string
+	execs    [][]driver.Value
+}
+
+func (d *copyDriver) Open(string) (driver.Conn, error) { return copyConn{d}, nil }
+
+type copyConn struct{ d *copyDriver }
+
+func (c copyConn) Prepare(q string) (driver.Stmt, error) {
+	c.d.prepared = append(c.d.prepared, q)
+	return copyStmt{c.d}, nil
+}
+func (c copyConn) Close() error              { return nil }
+func (c copyConn) Begin() (driver.Tx, error) { return copyTx{}, nil }
+
+type copyTx struct{}
+
+func (copyTx) Commit() error   { return nil }
+func (copyTx) Rollback() error { return nil }
+
+type copyStmt struct{ d *copyDriver }
+
+func (s copyStmt) Close() error  { return nil }
+func (s copyStmt) NumInput() int { return -1 }
+func (s copyStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.execs = append(s.d.execs, args)
+	return driver.RowsAffected(0), nil
+}
+func (s copyStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func TestBulkInsert(t *testing.T) {
+	ctx := context.Background()
+	d := new(copyDriver)
+	Register("copy", d)
+	db, err := Open("copy", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := [][]interface{}{
+		{int64(1), "a"},
+		{int64(2), []byte("b")},
+	}
+	err = tx.BulkInsert(ctx, `my"table`, []string{"id", "name"}, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPrepared := []string{`COPY "my""table" ("id", "name") FROM STDIN`}
+	if !reflect.DeepEqual(d.prepared, wantPrepared) {
+		t.Errorf("prepared %q, want %q", d.prepared, wantPrepared)
+	}
+	wantExecs := [][]driver.Value{
+		{int64(1), "a"},
+		{int64(2), []byte("b")},
+		{}, // ends the COPY
+	}
+	if !reflect.DeepEqual(d.execs, wantExecs) {
+		t.Errorf("execs %v, want %v", d.execs, wantExecs)
+	}
+
+	err = tx.BulkInsert(ctx, "t", []string{"id", "name"}, [][]interface{}{{int64(1)}})
+	if errors.Root(err) != ErrBulkRowLen {
+		t.Errorf("BulkInsert(short row) error = %v, want %v", err, ErrBulkRowLen)
+	}
+	if len(d.prepared) != 1 {
+		t.Errorf("prepared %d statements after bad row, want 1", len(d.prepared))
+	}
+}
@@ -0,0 +1,84 @@
+package sql
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// PoolStats describes the use of a DB's connection pool.
+type PoolStats struct {
+	// MaxOpen is the limit set by SetMaxOpenConns.
+	// It is 0 if there is no limit.
+	MaxOpen int `json:"max_open"`
+
+	// Open is the number of connections open,
+	// in use or idle.
+	Open int `json:"open"`
+
+	// InUse is the number of statements, result sets,
+	// and transactions holding a connection, plus those
+	// waiting for one.
+	InUse int64 `json:"in_use"`
+
+	// Waits counts the statements and transactions that,
+	// since the DB was opened, found every connection in
+	// use and had to wait for one.
+	Waits int64 `json:"waits"`
+}
+
+// pool tracks the use of a DB's connections.
+// The standard library's pool does not report how
+// often it makes callers wait, so the DB counts the
+// connections it hands out itself.
+type pool struct {
+	maxOpen int64 // accessed atomically
+	inUse   int64 // accessed atomically
+	waits   int64 // accessed atomically
+}
+
+func (p *pool) setMaxOpen(n int) {
+	if n < 0 {
+		n = 0
+	}
+	atomic.StoreInt64(&p.maxOpen, int64(n))
+}
+
+// acquire records that a connection is about to be taken.
+// It is safe to call on a nil pool.
+func (p *pool) acquire() {
+	if p == nil {
+		return
+	}
+	n := atomic.AddInt64(&p.inUse, 1)
+	if max := atomic.LoadInt64(&p.maxOpen); max > 0 && n > max {
+		atomic.AddInt64(&p.waits, 1)
+	}
+}
+
+// release records that a connection has been returned.
+// It is safe to call on a nil pool.
+func (p *pool) release() {
+	if p == nil {
+		return
+	}
+	atomic.AddInt64(&p.inUse, -1)
+}
+
+// Stats returns statistics about the DB's connection pool.
+func (db *DB) Stats() PoolStats {
+	return PoolStats{
+		MaxOpen: int(atomic.LoadInt64(&db.pool.maxOpen)),
+		Open:    db.db.Stats().OpenConnections,
+		InUse:   atomic.LoadInt64(&db.pool.inUse),
+		Waits:   atomic.LoadInt64(&db.pool.waits),
+	}
+}
+
+// SetConnMaxLifetime sets the maximum amount of time
+// a connection may be reused. Expired connections are
+// closed lazily, before reuse.
+//
+// If d <= 0, connections are reused forever.
+func (db *DB) SetConnMaxLifetime(d time.Duration) {
+	db.db.SetConnMaxLifetime(d)
+}
@@ -0,0 +1,62 @@
+package sql
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPoolWaits(t *testing.T) {
+	p := new(pool)
+	p.setMaxOpen(2)
+	p.acquire()
+	p.acquire()
+	p.acquire() // waits
+	p.release()
+	p.acquire() // waits
+	p.release()
+	p.release()
+	if p.inUse != 1 || p.waits != 2 {
+		t.Errorf("inUse = %d waits = %d, want 1 and 2", p.inUse, p.waits)
+	}
+
+	// With no limit, nothing waits.
+	p = new(pool)
+	for i := 0; i < 10; i++ {
+		p.acquire()
+	}
+	if p.waits != 0 {
+		t.Errorf("waits = %d with no limit, want 0", p.waits)
+	}
+}
+
+func TestStatsInUse(t *testing.T) {
+	ctx := context.Background()
+	db, _ := openFailDriver(t)
+	db.SetMaxOpenConns(3)
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := db.Stats(); got.InUse != 1 || got.MaxOpen != 3 || got.Open != 1 {
+		t.Errorf("Stats() in transaction = %+v, want 1 in use and open, 3 max", got)
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Rollback(ctx) // fails with ErrTxDone; must not release again
+	if got := db.Stats(); got.InUse != 0 {
+		t.Errorf("Stats().InUse after commit = %d, want 0", got.InUse)
+	}
+
+	// The fake driver can't prepare statements,
+	// so every statement fails, but releases its
+	// connection all the same.
+	db.Exec(ctx, "SELECT 1")
+	db.Query(ctx, "SELECT 1")
+	db.QueryRow(ctx, "SELECT 1").Scan()
+	if got := db.Stats(); got.InUse != 0 || got.Waits != 0 {
+		t.Errorf("Stats() after statements = %+v, want none in use and no waits", got)
+	}
+}
@@ -0,0 +1,118 @@
+package sql
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"chain/errors"
+	"chain/log"
+)
+
+// LagFunc reports how far replica is behind its primary.
+type LagFunc func(ctx context.Context, replica *DB) (time.Duration, error)
+
+// ReadDB sends the queries of read-only code paths to
+// a replica of the primary database, as long as the
+// replica is no more than a bounded time behind.
+// When it falls further behind, or its lag can't be
+// measured, queries go to the primary instead.
+// Exec always goes to the primary.
+//
+// Readers of a ReadDB may not see their own writes,
+// or others', for up to the maximum lag. Code that must
+// see them should use the primary directly, or pass
+// a context from ReadPrimary.
+type ReadDB struct {
+	primary *DB
+	replica *DB
+	maxLag  time.Duration
+	lag     LagFunc
+	fresh   int32 // accessed atomically; 1 if the replica is in use
+}
+
+// NewReadDB returns a ReadDB that reads from replica while
+// its lag, as reported by lag, is at most maxLag. It uses
+// the primary until Monitor first checks the replica.
+func NewReadDB(primary, replica *DB, maxLag time.Duration, lag LagFunc) *ReadDB {
+	return &ReadDB{
+		primary: primary,
+		replica: replica,
+		maxLag:  maxLag,
+		lag:     lag,
+	}
+}
+
+// Monitor checks the replica's lag every interval,
+// until ctx is done.
+func (r *ReadDB) Monitor(ctx context.Context, interval time.Duration) {
+	ticks := time.NewTicker(interval)
+	defer ticks.Stop()
+	for {
+		r.check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticks.C:
+		}
+	}
+}
+
+func (r *ReadDB) check(ctx context.Context) {
+	lag, err := r.lag(ctx, r.replica)
+	if err != nil {
+		log.Error(ctx, errors.Wrap(err, "checking replica lag"))
+	}
+	var fresh int32
+	if err == nil && lag <= r.maxLag {
+		fresh = 1
+	}
+	if atomic.SwapInt32(&r.fresh, fresh) == fresh {
+		return
+	}
+	if fresh == 1 {
+		log.Printkv(ctx, "at", "reading from replica", "lag", lag)
+	} else {
+		log.Printkv(ctx, "at", "reading from primary", "lag", lag)
+	}
+}
+
+type readPrimaryKey struct{}
+
+// ReadPrimary returns a context that makes a ReadDB
+// send queries made with it to the primary, regardless
+// of the replica's lag. It is for reads that depend on
+// something already seen on the primary, such as a
+// block height.
+func ReadPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readPrimaryKey{}, true)
+}
+
+// db returns the database to read from.
+func (r *ReadDB) db(ctx context.Context) *DB {
+	if primary, _ := ctx.Value(readPrimaryKey{}).(bool); primary {
+		return r.primary
+	}
+	if atomic.LoadInt32(&r.fresh) == 1 {
+		return r.replica
+	}
+	return r.primary
+}
+
+// Query executes a query that returns rows, typically a SELECT,
+// on the replica if it is fresh enough, otherwise on the primary.
+func (r *ReadDB) Query(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	return r.db(ctx).Query(ctx, query, args...)
+}
+
+// QueryRow executes a query that is expected to return at most one row,
+// on the replica if it is fresh enough, otherwise on the primary.
+func (r *ReadDB) QueryRow(ctx context.Context, query string, args ...interface{}) *Row {
+	return r.db(ctx).QueryRow(ctx, query, args...)
+}
+
+// Exec executes a query without returning any rows.
+// It always runs on the primary.
+func (r *ReadDB) Exec(ctx context.Context, query string, args ...interface{}) (Result, error) {
+	return r.primary.Exec(ctx, query, args...)
+}
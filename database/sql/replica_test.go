@@ -0,0 +1,53 @@
+package sql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"chain/errors"
+)
+
+func TestReadDBRouting(t *testing.T) {
+	ctx := context.Background()
+	primary, _ := openFailDriver(t)
+	replica, _ := openFailDriver(t)
+
+	var (
+		lag    time.Duration
+		lagErr error
+	)
+	r := NewReadDB(primary, replica, time.Second, func(_ context.Context, db *DB) (time.Duration, error) {
+		if db != replica {
+			t.Errorf("lag checked on %p, want replica %p", db, replica)
+		}
+		return lag, lagErr
+	})
+	if r.db(ctx) != primary {
+		t.Error("before check: reading from replica, want primary")
+	}
+
+	cases := []struct {
+		lag         time.Duration
+		err         error
+		wantReplica bool
+	}{
+		{0, nil, true},
+		{time.Second, nil, true},
+		{2 * time.Second, nil, false},
+		{500 * time.Millisecond, nil, true},
+		{0, errors.New("no connection"), false},
+		{0, nil, true},
+	}
+	for i, c := range cases {
+		lag, lagErr = c.lag, c.err
+		r.check(ctx)
+		if got := r.db(ctx) == replica; got != c.wantReplica {
+			t.Errorf("case %d: reading from replica = %v, want %v", i, got, c.wantReplica)
+		}
+	}
+
+	if r.db(ReadPrimary(ctx)) != primary {
+		t.Error("with ReadPrimary: reading from replica, want primary")
+	}
+}
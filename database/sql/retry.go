@@ -0,0 +1,86 @@
+package sql
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"chain/errors"
+)
+
+const (
+	// maxTxAttempts is the number of times RunTx
+	// runs a transaction before giving up.
+	maxTxAttempts = 5
+
+	txRetryBase = 10 * time.Millisecond
+	txRetryMax  = time.Second
+)
+
+// SQLSTATE codes of failures after which the database has
+// rolled back the transaction, and it is safe to try again.
+var retryableStates = map[string]bool{
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected (Postgres)
+}
+
+// RunTx runs f in a transaction and commits it.
+// If f returns an error, RunTx rolls back the
+// transaction and returns the error.
+//
+// If the transaction fails because of a serialization failure
+// or deadlock, RunTx runs it again, after a short, random
+// delay, up to a few times. These are the only failures
+// retried, since the database reports them only after
+// rolling back the transaction, so none of f's work is
+// committed twice. Any other failure, including an error
+// committing with an unknown outcome, is returned
+// immediately. Because f may run more than once,
+// it must affect nothing outside the transaction.
+func RunTx(ctx context.Context, db *DB, f func(*Tx) error) error {
+	for attempt := 1; ; attempt++ {
+		err := runTx(ctx, db, f)
+		if err == nil || !isRetryable(err) || attempt == maxTxAttempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "retrying transaction")
+		case <-time.After(txRetryDelay(attempt)):
+		}
+	}
+}
+
+func runTx(ctx context.Context, db *DB, f func(*Tx) error) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+	err = f(tx)
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+	return errors.Wrap(tx.Commit(ctx), "commit transaction")
+}
+
+// txRetryDelay returns a random delay before the given
+// attempt, growing exponentially with each attempt.
+func txRetryDelay(attempt int) time.Duration {
+	max := txRetryBase << uint(attempt-1)
+	if max > txRetryMax {
+		max = txRetryMax
+	}
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+// isRetryable reports whether err is a failure after which
+// the database rolled back the transaction on its own.
+// It recognizes the errors of drivers, like lib/pq, that
+// report the SQLSTATE code through a Get('C') method.
+func isRetryable(err error) bool {
+	e, ok := errors.Root(err).(interface {
+		Get(byte) string
+	})
+	return ok && retryableStates[e.Get('C')]
+}
@@ -0,0 +1,138 @@
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"testing"
+
+	"chain/errors"
+)
+
+// stateError is a driver error with a SQLSTATE code,
+// reported the same way as by lib/pq.
+type stateError string
+
+func (e stateError) Error() string { return "sqlstate " + string(e) }
+
+func (e stateError) Get(k byte) string {
+	if k == 'C' {
+		return string(e)
+	}
+	return ""
+}
+
+// failDriver is a driver whose transactions
+// fail to commit with the errors in commitErrs,
+// in order, and then succeed.
+type failDriver struct {
+	commitErrs []error
+	commits    int
+	rollbacks  int
+}
+
+func (d *failDriver) Open(string) (driver.Conn, error) { return failConn{d}, nil }
+
+func (d *failDriver) Commit() error {
+	d.commits++
+	if len(d.commitErrs) == 0 {
+		return nil
+	}
+	err := d.commitErrs[0]
+	d.commitErrs = d.commitErrs[1:]
+	return err
+}
+
+type failConn struct{ d *failDriver }
+
+func (c failConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c failConn) Close() error                        { return nil }
+func (c failConn) Begin() (driver.Tx, error)           { return failTx{c.d}, nil }
+
+type failTx struct{ d *failDriver }
+
+func (tx failTx) Commit() error   { return tx.d.Commit() }
+func (tx failTx) Rollback() error { tx.d.rollbacks++; return nil }
+
+var failDrivers int
+
+func openFailDriver(t *testing.T, commitErrs ...error) (*DB, *failDriver) {
+	failDrivers++
+	name := fmt.Sprintf("fail%d", failDrivers)
+	d := &failDriver{commitErrs: commitErrs}
+	Register(name, d)
+	db, err := Open(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, d
+}
+
+func TestRunTx(t *testing.T) {
+	ctx := context.Background()
+	errOther := errors.New("other")
+
+	cases := []struct {
+		commitErrs  []error
+		wantCommits int
+		wantErr     error
+	}{
+		{nil, 1, nil},
+		{[]error{stateError("40001")}, 2, nil},
+		{[]error{stateError("40P01"), stateError("40001")}, 3, nil},
+		{[]error{stateError("23505")}, 1, stateError("23505")},
+		{[]error{errOther}, 1, errOther},
+		{
+			[]error{stateError("40001"), stateError("40001"), stateError("40001"), stateError("40001"), stateError("40001")},
+			maxTxAttempts,
+			stateError("40001"),
+		},
+	}
+	for i, c := range cases {
+		db, d := openFailDriver(t, c.commitErrs...)
+		var runs int
+		err := RunTx(ctx, db, func(*Tx) error {
+			runs++
+			return nil
+		})
+		if errors.Root(err) != c.wantErr {
+			t.Errorf("case %d: err = %v, want %v", i, err, c.wantErr)
+		}
+		if d.commits != c.wantCommits || runs != c.wantCommits {
+			t.Errorf("case %d: commits = %d, runs = %d, want %d", i, d.commits, runs, c.wantCommits)
+		}
+	}
+}
+
+func TestRunTxFuncError(t *testing.T) {
+	ctx := context.Background()
+	db, d := openFailDriver(t)
+	want := stateError("40001")
+	var runs int
+	err := RunTx(ctx, db, func(*Tx) error {
+		runs++
+		return want
+	})
+	// Errors from f are retried too, if they are
+	// retryable errors from the database.
+	if errors.Root(err) != want || runs != maxTxAttempts {
+		t.Errorf("err = %v after %d runs, want %v after %d", err, runs, want, maxTxAttempts)
+	}
+	if d.commits != 0 || d.rollbacks != maxTxAttempts {
+		t.Errorf("commits = %d rollbacks = %d, want 0 and %d", d.commits, d.rollbacks, maxTxAttempts)
+	}
+}
+
+func TestRunTxCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	db, _ := openFailDriver(t, stateError("40001"))
+	var runs int
+	err := RunTx(ctx, db, func(*Tx) error {
+		runs++
+		cancel()
+		return nil
+	})
+	if errors.Root(err) != context.Canceled || runs != 1 {
+		t.Errorf("err = %v after %d runs, want %v after 1", err, runs, context.Canceled)
+	}
+}
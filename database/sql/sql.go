@@ -45,16 +45,20 @@ func EnableQueryLogging(e bool) {
 	logQueries = e
 }
 
-func logQuery(ctx context.Context, query string, args interface{}) {
+func logQuery(ctx context.Context, query string, args []interface{}) {
 	if logQueries {
-		s := fmt.Sprint(args)
-		if len(s) > maxArgsLogLen {
-			s = s[:maxArgsLogLen-3] + "..."
-		}
-		log.Printkv(ctx, "query", query, "args", s)
+		log.Printkv(ctx, "query", query, "args", truncateArgs(args))
 	}
 }
 
+func truncateArgs(args []interface{}) string {
+	s := fmt.Sprint(args)
+	if len(s) > maxArgsLogLen {
+		s = s[:maxArgsLogLen-3] + "..."
+	}
+	return s
+}
+
 // ErrNoRows is returned by Scan when QueryRow doesn't return a
 // row. In such a case, QueryRow returns a placeholder *Row value that
 // defers this error until a Scan.
@@ -77,7 +81,8 @@ var ErrTxDone = sql.ErrTxDone
 // connection is returned to DB's idle connection pool. The pool size
 // can be controlled with SetMaxIdleConns.
 type DB struct {
-	db *sql.DB
+	db   *sql.DB
+	pool *pool
 }
 
 // Tx is an in-progress database transaction.
@@ -91,7 +96,8 @@ type DB struct {
 // the transaction's Prepare or Stmt methods are closed
 // by the call to Commit or Rollback.
 type Tx struct {
-	tx *sql.Tx
+	tx   *sql.Tx
+	pool *pool // nil after Commit or Rollback
 }
 
 // Rows is the result of a query. Its cursor starts before the first row
@@ -111,12 +117,17 @@ type Tx struct {
 type Rows struct {
 	ctx  context.Context
 	rows *sql.Rows
+	stmt *stmtLog
+	n    int64 // rows read so far
+	pool *pool // non-nil while holding a DB connection
 }
 
 // Row is the result of calling QueryRow to select a single row.
 type Row struct {
-	ctx context.Context
-	row *sql.Row
+	ctx  context.Context
+	row  *sql.Row
+	stmt *stmtLog
+	pool *pool // non-nil while holding a DB connection
 }
 
 // A Result summarizes an executed SQL command.
@@ -156,7 +167,7 @@ func Open(driverName, dataSourceName string) (*DB, error) {
 	if err != nil {
 		return nil, errors.Wrap(err)
 	}
-	return &DB{db: db}, nil
+	return &DB{db: db, pool: new(pool)}, nil
 }
 
 // Close closes the database, releasing any open resources.
@@ -188,34 +199,46 @@ func (db *DB) SetMaxIdleConns(n int) {
 // The default is 0 (unlimited).
 func (db *DB) SetMaxOpenConns(n int) {
 	db.db.SetMaxOpenConns(n)
+	db.pool.setMaxOpen(n)
 }
 
 // Begin starts a transaction. The isolation level is dependent on
 // the driver.
 func (db *DB) Begin(ctx context.Context) (*Tx, error) {
+	db.pool.acquire()
 	tx, err := db.db.Begin()
 	if err != nil {
+		db.pool.release()
 		return nil, errors.Wrap(err)
 	}
-	return &Tx{tx: tx}, nil
+	return &Tx{tx: tx, pool: db.pool}, nil
 }
 
 // Exec executes a query without returning any rows.
 // The args are for any placeholder parameters in the query.
 func (db *DB) Exec(ctx context.Context, query string, args ...interface{}) (Result, error) {
 	logQuery(ctx, query, args)
-	return db.db.Exec(query, args...)
+	s := startStmt(query, args)
+	db.pool.acquire()
+	res, err := db.db.Exec(query, args...)
+	db.pool.release()
+	s.finishExec(ctx, res, err)
+	return res, err
 }
 
 // Query executes a query that returns rows, typically a SELECT.
 // The args are for any placeholder parameters in the query.
 func (db *DB) Query(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
 	logQuery(ctx, query, args)
+	s := startStmt(query, args)
+	db.pool.acquire()
 	rows, err := db.db.Query(query, args...)
 	if err != nil {
+		db.pool.release()
+		s.finish(ctx, -1, err)
 		return nil, errors.Wrap(err)
 	}
-	return &Rows{rows: rows, ctx: ctx}, nil
+	return &Rows{rows: rows, ctx: ctx, stmt: s, pool: db.pool}, nil
 }
 
 // QueryRow executes a query that is expected to return at most one row.
@@ -223,36 +246,52 @@ func (db *DB) Query(ctx context.Context, query string, args ...interface{}) (*Ro
 // Row's Scan method is called.
 func (db *DB) QueryRow(ctx context.Context, query string, args ...interface{}) *Row {
 	logQuery(ctx, query, args)
+	s := startStmt(query, args)
+	db.pool.acquire()
 	row := db.db.QueryRow(query, args...)
-	return &Row{row: row, ctx: ctx}
+	return &Row{row: row, ctx: ctx, stmt: s, pool: db.pool}
 }
 
 // Commit commits the transaction.
 func (tx *Tx) Commit(ctx context.Context) error {
-	return tx.tx.Commit()
+	err := tx.tx.Commit()
+	tx.done()
+	return err
 }
 
 // Rollback aborts the transaction.
 func (tx *Tx) Rollback(ctx context.Context) error {
-	return tx.tx.Rollback()
+	err := tx.tx.Rollback()
+	tx.done()
+	return err
+}
+
+func (tx *Tx) done() {
+	tx.pool.release()
+	tx.pool = nil
 }
 
 // Exec executes a query that doesn't return rows.
 // For example: an INSERT and UPDATE.
 func (tx *Tx) Exec(ctx context.Context, query string, args ...interface{}) (Result, error) {
 	logQuery(ctx, query, args)
-	return tx.tx.Exec(query, args...)
+	s := startStmt(query, args)
+	res, err := tx.tx.Exec(query, args...)
+	s.finishExec(ctx, res, err)
+	return res, err
 }
 
 // Query executes a query that returns rows, typically a SELECT.
 // The args are for any placeholder parameters in the query.
 func (tx *Tx) Query(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
 	logQuery(ctx, query, args)
+	s := startStmt(query, args)
 	rows, err := tx.tx.Query(query, args...)
 	if err != nil {
+		s.finish(ctx, -1, err)
 		return nil, errors.Wrap(err)
 	}
-	return &Rows{rows: rows, ctx: ctx}, nil
+	return &Rows{rows: rows, ctx: ctx, stmt: s}, nil
 }
 
 // QueryRow executes a query that is expected to return at most one row.
@@ -260,15 +299,18 @@ func (tx *Tx) Query(ctx context.Context, query string, args ...interface{}) (*Ro
 // Row's Scan method is called.
 func (tx *Tx) QueryRow(ctx context.Context, query string, args ...interface{}) *Row {
 	logQuery(ctx, query, args)
+	s := startStmt(query, args)
 	row := tx.tx.QueryRow(query, args...)
-	return &Row{row: row, ctx: ctx}
+	return &Row{row: row, ctx: ctx, stmt: s}
 }
 
 // Close closes the Rows, preventing further enumeration. If Next returns
 // false, the Rows are closed automatically and it will suffice to check the
 // result of Err. Close is idempotent and does not affect the result of Err.
 func (rs *Rows) Close() error {
-	return rs.rows.Close()
+	err := rs.rows.Close()
+	rs.finish()
+	return err
 }
 
 // Next prepares the next result row for reading with the Scan method.  It
@@ -278,7 +320,24 @@ func (rs *Rows) Close() error {
 //
 // Every call to Scan, even the first one, must be preceded by a call to Next.
 func (rs *Rows) Next() bool {
-	return rs.rows.Next()
+	if !rs.rows.Next() {
+		rs.finish()
+		return false
+	}
+	rs.n++
+	return true
+}
+
+// finish logs the query and releases its connection,
+// once, when its rows are done.
+func (rs *Rows) finish() {
+	rs.pool.release()
+	rs.pool = nil
+	if rs.stmt == nil {
+		return
+	}
+	rs.stmt.finish(rs.ctx, rs.n, rs.rows.Err())
+	rs.stmt = nil
 }
 
 // Err returns the error, if any, that was encountered during iteration.
@@ -308,5 +367,17 @@ func (rs *Rows) Scan(dest ...interface{}) error {
 // Scan uses the first row and discards the rest.  If no row matches
 // the query, Scan returns ErrNoRows.
 func (r *Row) Scan(dest ...interface{}) error {
-	return r.row.Scan(dest...)
+	err := r.row.Scan(dest...)
+	r.pool.release()
+	r.pool = nil
+	switch err {
+	case nil:
+		r.stmt.finish(r.ctx, 1, nil)
+	case ErrNoRows:
+		r.stmt.finish(r.ctx, 0, nil)
+	default:
+		r.stmt.finish(r.ctx, -1, err)
+	}
+	r.stmt = nil
+	return err
 }
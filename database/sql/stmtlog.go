@@ -0,0 +1,160 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"chain/log"
+)
+
+// ArgPolicy controls how statement arguments
+// appear in the statement log.
+type ArgPolicy int
+
+const (
+	// ArgsRedact logs only the type and size of each argument.
+	ArgsRedact ArgPolicy = iota
+
+	// ArgsTruncate logs the arguments, truncated
+	// to a few bytes as in the query log.
+	ArgsTruncate
+
+	// ArgsFull logs the arguments in full.
+	ArgsFull
+)
+
+var (
+	logStatements   bool
+	stmtMinDuration time.Duration
+	stmtArgPolicy   ArgPolicy
+)
+
+// EnableStatementLogging enables log output for each
+// statement that takes at least min to run. Each entry has
+// the statement, its arguments (formatted according to args),
+// the number of rows it affected or returned, how long it took,
+// and the package that ran it. The duration of a query runs
+// until its last row is read, or until it is closed.
+// It must be called before Open.
+func EnableStatementLogging(min time.Duration, args ArgPolicy) {
+	logStatements = true
+	stmtMinDuration = min
+	stmtArgPolicy = args
+}
+
+// stmtLog records a statement in progress
+// for the statement log.
+type stmtLog struct {
+	query string
+	args  []interface{}
+	start time.Time
+}
+
+// startStmt returns a record of a statement about to run,
+// or nil if statement logging is disabled.
+func startStmt(query string, args []interface{}) *stmtLog {
+	if !logStatements {
+		return nil
+	}
+	return &stmtLog{query: query, args: args, start: time.Now()}
+}
+
+// finishExec logs s, with the rows affected by res.
+func (s *stmtLog) finishExec(ctx context.Context, res Result, err error) {
+	if s == nil {
+		return
+	}
+	rows := int64(-1)
+	if err == nil {
+		if n, err := res.RowsAffected(); err == nil {
+			rows = n
+		}
+	}
+	s.finish(ctx, rows, err)
+}
+
+// finish logs s if it ran long enough.
+// If rows is negative, it is omitted.
+func (s *stmtLog) finish(ctx context.Context, rows int64, err error) {
+	if s == nil {
+		return
+	}
+	d := time.Since(s.start)
+	if d < stmtMinDuration {
+		return
+	}
+	keyvals := []interface{}{
+		"statement", s.query,
+		"args", formatArgs(s.args, stmtArgPolicy),
+		"duration", d,
+		"caller", callerPackage(),
+	}
+	if rows >= 0 {
+		keyvals = append(keyvals, "rows", rows)
+	}
+	if err != nil {
+		// Log the message alone; the stack of a
+		// driver error says nothing useful.
+		keyvals = append(keyvals, log.KeyError, err.Error())
+	}
+	log.Printkv(ctx, keyvals...)
+}
+
+func formatArgs(args []interface{}, p ArgPolicy) string {
+	switch p {
+	case ArgsFull:
+		return fmt.Sprint(args)
+	case ArgsTruncate:
+		return truncateArgs(args)
+	}
+	strs := make([]string, 0, len(args))
+	for _, a := range args {
+		switch v := a.(type) {
+		case []byte:
+			strs = append(strs, fmt.Sprintf("[]byte(%d)", len(v)))
+		case string:
+			strs = append(strs, fmt.Sprintf("string(%d)", len(v)))
+		default:
+			strs = append(strs, fmt.Sprintf("%T", a))
+		}
+	}
+	return "[" + strings.Join(strs, " ") + "]"
+}
+
+// callerPackage returns the import path of the package
+// of the innermost function on the stack outside the
+// database packages.
+func callerPackage() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		pkg := funcPackage(f.Function)
+		if pkg != "" && !isDatabasePackage(pkg) {
+			return pkg
+		}
+		if !more {
+			return "?"
+		}
+	}
+}
+
+func isDatabasePackage(pkg string) bool {
+	return pkg == "database/sql" || strings.HasPrefix(pkg, "chain/database/")
+}
+
+// funcPackage returns the import path of the package
+// containing the function with the fully-qualified name fn,
+// for example chain/core/query.(*Indexer).LookupTxAfter.
+func funcPackage(fn string) string {
+	slash := strings.LastIndex(fn, "/")
+	dot := strings.Index(fn[slash+1:], ".")
+	if dot < 0 {
+		return ""
+	}
+	return fn[:slash+1+dot]
+}
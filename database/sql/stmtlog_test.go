@@ -0,0 +1,46 @@
+package sql
+
+import "testing"
+
+func TestFuncPackage(t *testing.T) {
+	cases := []struct {
+		fn, want string
+	}{
+		{"chain/core/query.(*Indexer).LookupTxAfter", "chain/core/query"},
+		{"chain/core.(*API).listTransactions.func1", "chain/core"},
+		{"chain/database/pg.ForQueryRows", "chain/database/pg"},
+		{"main.main", "main"},
+		{"runtime.goexit", "runtime"},
+		{"", ""},
+	}
+	for _, c := range cases {
+		if got := funcPackage(c.fn); got != c.want {
+			t.Errorf("funcPackage(%q) = %q, want %q", c.fn, got, c.want)
+		}
+	}
+}
+
+func TestCallerPackage(t *testing.T) {
+	// Functions in this package are skipped,
+	// so the caller is the test runner.
+	if got := callerPackage(); got != "testing" {
+		t.Errorf("callerPackage() = %q, want testing", got)
+	}
+}
+
+func TestFormatArgs(t *testing.T) {
+	args := []interface{}{[]byte("secret"), "hunter2", int64(5), nil}
+	cases := []struct {
+		p    ArgPolicy
+		want string
+	}{
+		{ArgsRedact, "[[]byte(6) string(7) int64 <nil>]"},
+		{ArgsTruncate, "[[115 101 99 114 ..."},
+		{ArgsFull, "[[115 101 99 114 101 116] hunter2 5 <nil>]"},
+	}
+	for _, c := range cases {
+		if got := formatArgs(args, c.p); got != c.want {
+			t.Errorf("formatArgs(%v) = %q, want %q", c.p, got, c.want)
+		}
+	}
+}
@@ -1,18 +1,61 @@
 // Package env provides a convenient way to convert environment
 // variables into Go data. It is similar in design to package
 // flag.
+//
+// Each variable is registered, with its default value, by one
+// of the functions below. Parse assigns the values in the
+// environment to them, checks that the variables named with
+// Require are set, and runs the checks added with Validate.
+// Print describes every registered variable.
 package env
 
 import (
+	"fmt"
+	"io"
 	"log"
 	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"text/tabwriter"
 	"time"
 )
 
-var funcs []func() bool
+// Sources of a variable's value, as shown by Print.
+const (
+	sourceDefault = "default"
+	sourceEnv     = "environment"
+)
+
+// redacted replaces the values of secrets in Print.
+const redacted = "<redacted>"
+
+// A variable is a registered environment variable.
+type variable struct {
+	name     string
+	def      string // default value, formatted
+	secret   bool
+	required bool
+	set      func(string) error // parses and assigns a value
+	get      func() string      // formats the current value
+
+	// set by parse
+	source string
+	err    error
+}
+
+var (
+	vars       []*variable
+	validators []func() error
+)
+
+// define registers a variable named name, with the default
+// value def, that set parses and get formats.
+func define(name, def string, set func(string) error, get func() string) *variable {
+	v := &variable{name: name, def: def, set: set, get: get, source: sourceDefault}
+	vars = append(vars, v)
+	return v
+}
 
 // Int returns a new int pointer.
 // When Parse is called,
@@ -31,17 +74,14 @@ func Int(name string, value int) *int {
 // value of the environment var.
 func IntVar(p *int, name string, value int) {
 	*p = value
-	funcs = append(funcs, func() bool {
-		if s := os.Getenv(name); s != "" {
-			v, err := strconv.Atoi(s)
-			if err != nil {
-				log.Println(name, err)
-				return false
-			}
-			*p = v
+	define(name, strconv.Itoa(value), func(s string) error {
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return err
 		}
-		return true
-	})
+		*p = v
+		return nil
+	}, func() string { return strconv.Itoa(*p) })
 }
 
 // Bool returns a new bool pointer.
@@ -62,17 +102,14 @@ func Bool(name string, value bool) *bool {
 // of the environment variable.
 func BoolVar(p *bool, name string, value bool) {
 	*p = value
-	funcs = append(funcs, func() bool {
-		if s := os.Getenv(name); s != "" {
-			v, err := strconv.ParseBool(s)
-			if err != nil {
-				log.Println(name, err)
-				return false
-			}
-			*p = v
+	define(name, strconv.FormatBool(value), func(s string) error {
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
 		}
-		return true
-	})
+		*p = v
+		return nil
+	}, func() string { return strconv.FormatBool(*p) })
 }
 
 // Duration returns a new time.Duration pointer.
@@ -93,17 +130,14 @@ func Duration(name string, value time.Duration) *time.Duration {
 // variable.
 func DurationVar(p *time.Duration, name string, value time.Duration) {
 	*p = value
-	funcs = append(funcs, func() bool {
-		if s := os.Getenv(name); s != "" {
-			v, err := time.ParseDuration(s)
-			if err != nil {
-				log.Println(name, err)
-				return false
-			}
-			*p = v
+	define(name, value.String(), func(s string) error {
+		v, err := time.ParseDuration(s)
+		if err != nil {
+			return err
 		}
-		return true
-	})
+		*p = v
+		return nil
+	}, func() string { return p.String() })
 }
 
 // URL returns a new url.URL pointer.
@@ -130,17 +164,14 @@ func URLVar(p *url.URL, name string, value string) {
 		panic(err)
 	}
 	*p = *v
-	funcs = append(funcs, func() bool {
-		if s := os.Getenv(name); s != "" {
-			v, err := url.Parse(s)
-			if err != nil {
-				log.Println(name, err)
-				return false
-			}
-			*p = *v
+	define(name, value, func(s string) error {
+		v, err := url.Parse(s)
+		if err != nil {
+			return err
 		}
-		return true
-	})
+		*p = *v
+		return nil
+	}, func() string { return p.String() })
 }
 
 // String returns a new string pointer.
@@ -160,12 +191,30 @@ func String(name string, value string) *string {
 // var.
 func StringVar(p *string, name string, value string) {
 	*p = value
-	funcs = append(funcs, func() bool {
-		if s := os.Getenv(name); s != "" {
-			*p = s
-		}
-		return true
-	})
+	define(name, value, func(s string) error {
+		*p = s
+		return nil
+	}, func() string { return *p })
+}
+
+// Secret returns a new string pointer, like String,
+// for a variable, such as a password or access token,
+// whose value Print doesn't show.
+func Secret(name string, value string) *string {
+	p := new(string)
+	SecretVar(p, name, value)
+	return p
+}
+
+// SecretVar defines a string, like StringVar,
+// for a variable whose value Print doesn't show.
+func SecretVar(p *string, name string, value string) {
+	*p = value
+	v := define(name, value, func(s string) error {
+		*p = s
+		return nil
+	}, func() string { return *p })
+	v.secret = true
 }
 
 // StringSlice returns a pointer to a slice
@@ -186,27 +235,147 @@ func StringSlice(name string, value ...string) *[]string {
 // to store the value of the environment var.
 func StringSliceVar(p *[]string, name string, value ...string) {
 	*p = value
-	funcs = append(funcs, func() bool {
-		if s := os.Getenv(name); s != "" {
-			a := strings.Split(s, ",")
-			*p = a
+	define(name, strings.Join(value, ","), func(s string) error {
+		a := strings.Split(s, ",")
+		*p = a
+		return nil
+	}, func() string { return strings.Join(*p, ",") })
+}
+
+// Require makes the named variables required:
+// Parse fails if any of them is not set.
+// Require panics if a name has not been
+// registered.
+func Require(names ...string) {
+	for _, name := range names {
+		found := false
+		for _, v := range vars {
+			if v.name == name {
+				v.required = true
+				found = true
+			}
+		}
+		if !found {
+			panic("env: Require of unregistered variable " + name)
+		}
+	}
+}
+
+// Validate adds f to the checks Parse runs once it has
+// assigned every variable without error. Use it to check
+// constraints between variables, such as that one needs
+// another. If f returns an error, Parse fails.
+func Validate(f func() error) {
+	validators = append(validators, f)
+}
+
+// parse assigns the values of the registered variables
+// from the environment, and runs the validators if
+// that succeeds. It returns the errors it found.
+func parse() []error {
+	var errs []error
+	for _, v := range vars {
+		v.source, v.err = sourceDefault, nil
+		s := os.Getenv(v.name)
+		if s == "" {
+			if v.required {
+				v.err = fmt.Errorf("required but not set")
+				errs = append(errs, fmt.Errorf("%s %s", v.name, v.err))
+			}
+			continue
+		}
+		v.source = sourceEnv
+		v.err = v.set(s)
+		if v.err != nil {
+			errs = append(errs, fmt.Errorf("%s %s", v.name, v.err))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	for _, f := range validators {
+		if err := f(); err != nil {
+			errs = append(errs, err)
 		}
-		return true
-	})
+	}
+	return errs
 }
 
 // Parse parses known env vars
 // and assigns the values to the variables
 // that were previously registered.
 // If any values cannot be parsed,
+// a required var is not set,
+// or a check added by Validate fails,
 // Parse prints an error message for each one
 // and exits the process with status 1.
 func Parse() {
-	ok := true
-	for _, f := range funcs {
-		ok = f() && ok
+	errs := parse()
+	for _, err := range errs {
+		log.Println(err)
 	}
-	if !ok {
+	if len(errs) > 0 {
 		os.Exit(1)
 	}
 }
+
+// Print parses the registered variables, as Parse does but
+// without exiting on error, and writes a table to w of each
+// one's name, default value, current value, and source:
+// whether the value came from the environment or is the
+// default. Variables that are invalid or required and not
+// set are marked so. Print shows the values of secrets only
+// as <redacted>, and hides any password in a URL.
+func Print(w io.Writer) error {
+	parse()
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tDEFAULT\tVALUE\tSOURCE")
+	for _, v := range vars {
+		def, val := v.def, v.get()
+		if v.secret {
+			def, val = redact(def), redact(val)
+		} else {
+			def, val = redactURL(def), redactURL(val)
+		}
+		source := v.source
+		if v.required {
+			source += " (required)"
+		}
+		if v.err != nil {
+			source += ": " + v.err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", v.name, quote(def), quote(val), source)
+	}
+	return tw.Flush()
+}
+
+func redact(s string) string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+// redactURL returns s with its password
+// replaced if s is a URL with a password.
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	if _, ok := u.User.Password(); !ok {
+		return s
+	}
+	u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	return u.String()
+}
+
+// quote makes empty values and values
+// with blanks visible in Print's table.
+func quote(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t") {
+		return strconv.Quote(s)
+	}
+	return s
+}
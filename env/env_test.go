@@ -1,9 +1,12 @@
 package env
 
 import (
+	"bytes"
+	"fmt"
 	"net/url"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -273,3 +276,141 @@ func TestStringSliceVar(t *testing.T) {
 		t.Fatalf("expected %v, got %v", exp, result)
 	}
 }
+
+// isolate keeps the variables and validators
+// registered by a test from other tests.
+func isolate() func() {
+	oldVars, oldValidators := vars, validators
+	vars, validators = nil, nil
+	return func() { vars, validators = oldVars, oldValidators }
+}
+
+func TestSecret(t *testing.T) {
+	defer isolate()()
+
+	err := os.Setenv("secret-key", "hunter2")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	result := Secret("secret-key", "")
+	Parse()
+
+	if *result != "hunter2" {
+		t.Fatalf("expected result=hunter2, got result=%s", *result)
+	}
+}
+
+func TestRequire(t *testing.T) {
+	defer isolate()()
+
+	os.Unsetenv("required-key")
+	String("required-key", "default")
+	Require("required-key")
+
+	errs := parse()
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "required-key") {
+		t.Fatalf("expected an error for required-key, got %v", errs)
+	}
+
+	err := os.Setenv("required-key", "set")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	if errs = parse(); len(errs) != 0 {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	defer isolate()()
+
+	os.Unsetenv("validate-crt")
+	err := os.Setenv("validate-key", "key.pem")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	crt := String("validate-crt", "")
+	key := String("validate-key", "")
+	n := Int("validate-int", 0)
+	var called bool
+	Validate(func() error {
+		called = true
+		if (*crt == "") != (*key == "") {
+			return fmt.Errorf("validate-crt and validate-key must be set together")
+		}
+		return nil
+	})
+
+	errs := parse()
+	if len(errs) != 1 || !called {
+		t.Fatalf("expected validation error, got %v", errs)
+	}
+
+	// Validators don't run on variables that failed to parse.
+	called = false
+	err = os.Setenv("validate-int", "x")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer os.Unsetenv("validate-int")
+	errs = parse()
+	if len(errs) != 1 || called || *n != 0 {
+		t.Fatalf("expected only a parse error, got %v (validator called %t)", errs, called)
+	}
+}
+
+func TestPrint(t *testing.T) {
+	defer isolate()()
+
+	os.Unsetenv("print-default")
+	os.Unsetenv("print-required")
+	for k, v := range map[string]string{
+		"print-url":    "postgres://core:pw@db/core",
+		"print-secret": "hunter2",
+		"print-bad":    "x",
+	} {
+		err := os.Setenv(k, v)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+		defer os.Unsetenv(k)
+	}
+
+	Duration("print-default", 5*time.Second)
+	String("print-url", "postgres:///core")
+	Secret("print-secret", "")
+	Int("print-bad", 1)
+	String("print-required", "")
+	Require("print-required")
+
+	var buf bytes.Buffer
+	err := Print(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	t.Logf("output:\n%s", got)
+
+	if strings.Contains(got, "hunter2") || strings.Contains(got, ":pw@") {
+		t.Errorf("output contains secret")
+	}
+	want := [][]string{
+		{"NAME", "DEFAULT", "VALUE", "SOURCE"},
+		{"print-default", "5s", "5s", "default"},
+		{"print-url", "postgres:///core", "postgres://core:xxxxx@db/core", "environment"},
+		{"print-secret", `""`, redacted, "environment"},
+		{"print-bad", "1", "1", "environment:", "strconv.Atoi:", `parsing`, `"x":`, "invalid", "syntax"},
+		{"print-required", `""`, `""`, "default", "(required):", "required", "but", "not", "set"},
+	}
+	lines := strings.Split(strings.TrimSpace(got), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d", len(lines), len(want))
+	}
+	for i, line := range lines {
+		if f := strings.Fields(line); !reflect.DeepEqual(f, want[i]) {
+			t.Errorf("line %d = %q want %q", i, f, want[i])
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// crashDumpOn is 1 if the crash ring is enabled.
+// It's read without holding logWriterMu, so that
+// printkv can skip entries nothing will keep.
+var crashDumpOn int32
+
+var (
+	// protected by logWriterMu
+	crashRing ring
+	crashDir  string
+)
+
+// ring holds the most recent entries added to it.
+type ring struct {
+	entries [][]byte
+	next    int // index of the oldest entry, once full
+	full    bool
+}
+
+func (r *ring) add(b []byte) {
+	if len(r.entries) == 0 {
+		return
+	}
+	r.entries[r.next] = b
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// each calls f on each entry, oldest first.
+func (r *ring) each(f func([]byte)) {
+	if r.full {
+		for _, b := range r.entries[r.next:] {
+			f(b)
+		}
+	}
+	for _, b := range r.entries[:r.next] {
+		f(b)
+	}
+}
+
+// SetCrashDump keeps the last n entries, at every level,
+// whether written or not, in memory, in the text format.
+// They're written to a new file in dir by WriteCrashDump,
+// which Fatalkv, RecoverAndLogError, and DumpOnPanic call.
+// If n is 0, SetCrashDump discards the entries it kept,
+// and crash dumps are off.
+func SetCrashDump(dir string, n int) {
+	logWriterMu.Lock()
+	crashRing = ring{entries: make([][]byte, n)}
+	crashDir = dir
+	logWriterMu.Unlock()
+	var on int32
+	if n > 0 {
+		on = 1
+	}
+	atomic.StoreInt32(&crashDumpOn, on)
+}
+
+// WriteCrashDump writes the entries kept since SetCrashDump,
+// oldest first, and the counts of repeated errors, to a new
+// file in the crash dump directory. It returns the name of
+// the file. If crash dumps are off, it does nothing and
+// returns an empty name.
+func WriteCrashDump(reason string) (string, error) {
+	if atomic.LoadInt32(&crashDumpOn) == 0 {
+		return "", nil
+	}
+
+	var buf bytes.Buffer
+	now := time.Now().UTC()
+	fmt.Fprintf(&buf, "crash dump: %s\n", reason)
+	fmt.Fprintf(&buf, "time: %s\n", now.Format(rfc3339NanoFixed))
+	fmt.Fprintf(&buf, "pid: %d\n\n", os.Getpid())
+
+	logWriterMu.Lock()
+	dir := crashDir
+	crashRing.each(func(b []byte) { buf.Write(b) })
+	logWriterMu.Unlock()
+
+	if counts := errorCounts(); len(counts) > 0 {
+		buf.WriteString("\nrepeated errors:\n")
+		for _, c := range counts {
+			fmt.Fprintf(&buf, "%d\t%s\t%s\n", c.n, c.at, c.msg)
+		}
+	}
+
+	name := filepath.Join(dir, fmt.Sprintf("crash-%s-%d.log", now.Format("20060102T150405Z"), os.Getpid()))
+	err := ioutil.WriteFile(name, buf.Bytes(), 0600)
+	if err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// writeCrashDump calls WriteCrashDump,
+// and logs where it wrote the dump.
+func writeCrashDump(ctx context.Context, reason string) {
+	name, err := WriteCrashDump(reason)
+	if err != nil {
+		printkv(ctx, LevelError, []interface{}{KeyMessage, "writing crash dump", KeyError, err.Error()})
+	} else if name != "" {
+		printkv(ctx, LevelError, []interface{}{KeyMessage, "wrote crash dump", "file", name})
+	}
+}
+
+// DumpOnPanic logs a panic in the calling goroutine,
+// writes a crash dump, and then continues panicking.
+// It must be deferred, usually first thing in main,
+// to record panics that end the process.
+func DumpOnPanic(ctx context.Context) {
+	if err := recover(); err != nil {
+		logPanic(ctx, err)
+		writeCrashDump(ctx, fmt.Sprint("panic: ", err))
+		panic(err)
+	}
+}
+
+// errorCount is a summary of a repeated error.
+type errorCount struct {
+	at, msg string
+	n       int
+}
+
+// errorCounts returns the number of times each
+// repeated error was logged, most frequent first.
+func errorCounts() []errorCount {
+	errorsMu.Lock()
+	var counts []errorCount
+	for k, e := range repeated {
+		if e.total > 1 {
+			counts = append(counts, errorCount{k.at, e.msg, e.total})
+		}
+	}
+	errorsMu.Unlock()
+	sort.Sort(byCount(counts))
+	return counts
+}
+
+type byCount []errorCount
+
+func (a byCount) Len() int           { return len(a) }
+func (a byCount) Less(i, j int) bool { return a[i].n > a[j].n }
+func (a byCount) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
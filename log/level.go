@@ -0,0 +1,194 @@
+package log
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"chain/errors"
+)
+
+// ErrBadLevel is returned when a log level
+// or level spec can't be parsed.
+var ErrBadLevel = errors.New("invalid log level")
+
+// A Level is the severity of a log entry.
+// Entries below the level of the module
+// that logs them are discarded.
+type Level int
+
+// Levels, from least to most severe.
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelError
+)
+
+var levelNames = []string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelError: "error",
+}
+
+func (l Level) String() string {
+	if l < 0 || int(l) >= len(levelNames) {
+		return "?"
+	}
+	return levelNames[l]
+}
+
+// MarshalText satisfies encoding.TextMarshaler.
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText satisfies encoding.TextUnmarshaler.
+func (l *Level) UnmarshalText(b []byte) error {
+	v, err := ParseLevel(string(b))
+	if err != nil {
+		return err
+	}
+	*l = v
+	return nil
+}
+
+// ParseLevel returns the level named s.
+func ParseLevel(s string) (Level, error) {
+	for l, name := range levelNames {
+		if s == name {
+			return Level(l), nil
+		}
+	}
+	return 0, errors.WithDetailf(ErrBadLevel, "level %q", s)
+}
+
+var (
+	levelMu      sync.RWMutex // protects the following
+	defaultLevel = LevelInfo
+	moduleLevels = make(map[string]Level)
+)
+
+// SetLevel sets the level of modules
+// without a level of their own.
+func SetLevel(l Level) {
+	levelMu.Lock()
+	defaultLevel = l
+	levelMu.Unlock()
+}
+
+// SetModuleLevel sets the level of module, an import path
+// such as chain/core/fetch, and of the packages below it
+// that have no level of their own.
+func SetModuleLevel(module string, l Level) {
+	levelMu.Lock()
+	moduleLevels[module] = l
+	levelMu.Unlock()
+}
+
+// ClearModuleLevel removes the level of module,
+// which then takes the level of its nearest
+// parent with a level, or the default level.
+func ClearModuleLevel(module string) {
+	levelMu.Lock()
+	delete(moduleLevels, module)
+	levelMu.Unlock()
+}
+
+// Levels returns the default level
+// and a copy of the module levels.
+func Levels() (Level, map[string]Level) {
+	levelMu.RLock()
+	defer levelMu.RUnlock()
+	m := make(map[string]Level, len(moduleLevels))
+	for k, v := range moduleLevels {
+		m[k] = v
+	}
+	return defaultLevel, m
+}
+
+// SetLevels sets levels from spec, a comma-separated list
+// whose items are either a level, which sets the default
+// level, or module=level, which sets the level of module.
+// For example, "error,chain/core/fetch=debug".
+// Module levels not named in spec are cleared.
+// If spec is invalid, SetLevels changes nothing.
+func SetLevels(spec string) error {
+	def := LevelInfo
+	modules := make(map[string]Level)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		module, name := "", item
+		if i := strings.Index(item, "="); i >= 0 {
+			module, name = item[:i], item[i+1:]
+			if module == "" {
+				return errors.WithDetailf(ErrBadLevel, "item %q has no module", item)
+			}
+		}
+		l, err := ParseLevel(name)
+		if err != nil {
+			return err
+		}
+		if module == "" {
+			def = l
+		} else {
+			modules[module] = l
+		}
+	}
+
+	levelMu.Lock()
+	defaultLevel = def
+	moduleLevels = modules
+	levelMu.Unlock()
+	return nil
+}
+
+// LevelSpec returns the current levels
+// in the form accepted by SetLevels.
+func LevelSpec() string {
+	def, modules := Levels()
+	items := []string{def.String()}
+	var names []string
+	for m := range modules {
+		names = append(names, m)
+	}
+	sort.Strings(names)
+	for _, m := range names {
+		items = append(items, m+"="+modules[m].String())
+	}
+	return strings.Join(items, ",")
+}
+
+// enabled reports whether an entry at level l,
+// logged by the given module, should be written.
+// The longest matching module level applies.
+func enabled(module string, l Level) bool {
+	levelMu.RLock()
+	defer levelMu.RUnlock()
+	min, n := defaultLevel, -1
+	for m, ml := range moduleLevels {
+		if len(m) > n && (module == m || strings.HasPrefix(module, m+"/")) {
+			min, n = ml, len(m)
+		}
+	}
+	return l >= min
+}
+
+// mightBeEnabled reports whether an entry at level l
+// could be written by some module. It lets entries
+// that can't be written skip finding their caller.
+func mightBeEnabled(l Level) bool {
+	levelMu.RLock()
+	defer levelMu.RUnlock()
+	if l >= defaultLevel {
+		return true
+	}
+	for _, ml := range moduleLevels {
+		if l >= ml {
+			return true
+		}
+	}
+	return false
+}
@@ -1,10 +1,17 @@
 // Package log implements a standard convention for structured logging.
-// Log entries are formatted as K=V pairs.
+// Log entries are formatted as K=V pairs, or as JSON objects with the
+// same field names; see SetFormat.
 // By default, output is written to stdout; this can be changed with SetOutput.
+//
+// Each entry has a level, and is written only if its level is at
+// least the level of the module, the package, that logs it.
+// See SetLevel and SetModuleLevel.
 package log
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -12,6 +19,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"chain/errors"
@@ -26,6 +34,9 @@ var (
 	logWriterMu sync.Mutex // protects the following
 	logWriter   io.Writer  = os.Stdout
 	procPrefix  []byte     // process-global prefix; see SetPrefix vs AddPrefixkv
+	format      Format     // see SetFormat
+
+	procKV []interface{} // procPrefix unformatted, for FormatJSON; protected by logWriterMu
 
 	// pairDelims contains a list of characters that may be used as delimeters
 	// between key-value pairs in a log entry. Keys and values will be quoted or
@@ -38,6 +49,24 @@ var (
 
 	// context key for log line prefixes
 	prefixKey key = 0
+
+	// context key for log line prefixes, unformatted
+	prefixKVKey key = 1
+)
+
+// A Format is a way to write log entries.
+type Format int
+
+// Formats
+const (
+	// FormatText writes K=V pairs, followed
+	// by the stack trace, if any, on separate lines.
+	FormatText Format = iota
+
+	// FormatJSON writes a JSON object on one line.
+	// Values are strings, numbers, or booleans,
+	// and the stack trace is an array of lines.
+	FormatJSON
 )
 
 // Conventional key names for log entries
@@ -48,8 +77,10 @@ const (
 	KeyMessage = "message" // produced by Message
 	KeyError   = "error"   // produced by Error
 	KeyStack   = "stack"   // used by Printkv to print stack on subsequent lines
+	KeyLevel   = "level"   // level of entry
 
 	keyLogError = "log-error" // for errors produced by the log package itself
+	keyRepeated = "repeated"  // produced by Error and Errorf
 )
 
 // SetOutput sets the log output to w.
@@ -61,6 +92,15 @@ func SetOutput(w io.Writer) {
 	logWriterMu.Unlock()
 }
 
+// SetFormat sets the format of log entries.
+// If SetFormat hasn't been called,
+// the default format is FormatText.
+func SetFormat(f Format) {
+	logWriterMu.Lock()
+	format = f
+	logWriterMu.Unlock()
+}
+
 func appendPrefix(b []byte, keyval ...interface{}) []byte {
 	// Invariant: len(keyval) is always even.
 	if len(keyval)%2 != 0 {
@@ -82,6 +122,7 @@ func SetPrefix(keyval ...interface{}) {
 	b := appendPrefix(nil, keyval...)
 	logWriterMu.Lock()
 	procPrefix = b
+	procKV = keyval
 	logWriterMu.Unlock()
 }
 
@@ -92,6 +133,9 @@ func AddPrefixkv(ctx context.Context, keyval ...interface{}) context.Context {
 	// Note: subsequent calls will append to p, so set cap(p) here.
 	// See TestAddPrefixkvAppendTwice.
 	p = p[0:len(p):len(p)]
+	kv := append(prefixKV(ctx), keyval...)
+	kv = kv[0:len(kv):len(kv)]
+	ctx = context.WithValue(ctx, prefixKVKey, kv)
 	return context.WithValue(ctx, prefixKey, p)
 }
 
@@ -100,13 +144,19 @@ func prefix(ctx context.Context) []byte {
 	return b
 }
 
-// Printkv prints a structured log entry to stdout. Log fields are
-// specified as a variadic sequence of alternating keys and values.
+func prefixKV(ctx context.Context) []interface{} {
+	kv, _ := ctx.Value(prefixKVKey).([]interface{})
+	return kv
+}
+
+// Printkv prints a structured log entry at LevelInfo to stdout.
+// Log fields are specified as a variadic sequence of alternating
+// keys and values.
 //
 // Duplicate keys will be preserved.
 //
-// Two fields are automatically added to the log entry: t=[time]
-// and at=[file:line] indicating the location of the caller.
+// Three fields are automatically added to the log entry: t=[time],
+// level=[level], and at=[file:line] indicating the location of the caller.
 // Use SkipFunc to prevent helper functions from showing up in the
 // at=[file:line] field.
 //
@@ -116,6 +166,25 @@ func prefix(ctx context.Context) []byte {
 //   - a KeyStack value with type []byte or []errors.StackFrame
 //   - a KeyError value with type error, using the result of errors.Stack
 func Printkv(ctx context.Context, keyvals ...interface{}) {
+	printkv(ctx, LevelInfo, keyvals)
+}
+
+// Debugkv is like Printkv, but prints the entry at LevelDebug.
+func Debugkv(ctx context.Context, keyvals ...interface{}) {
+	printkv(ctx, LevelDebug, keyvals)
+}
+
+func printkv(ctx context.Context, level Level, keyvals []interface{}) {
+	keep := atomic.LoadInt32(&crashDumpOn) != 0
+	if !keep && !mightBeEnabled(level) {
+		return
+	}
+	at, module := caller()
+	write := enabled(module, level)
+	if !keep && !write {
+		return
+	}
+
 	// Invariant: len(keyvals) is always even.
 	if len(keyvals)%2 != 0 {
 		keyvals = append(keyvals, "", keyLogError, "odd number of log params")
@@ -124,11 +193,11 @@ func Printkv(ctx context.Context, keyvals ...interface{}) {
 	t := time.Now().UTC()
 
 	// Prepend the log entry with auto-generated fields.
-	out := fmt.Sprintf(
-		"%s=%s %s=%s",
-		KeyCaller, caller(),
-		KeyTime, formatValue(t.Format(rfc3339NanoFixed)),
-	)
+	fields := []interface{}{
+		KeyCaller, at,
+		KeyTime, t.Format(rfc3339NanoFixed),
+		KeyLevel, level,
+	}
 
 	var stack interface{}
 	for i := 0; i < len(keyvals); i += 2 {
@@ -143,24 +212,111 @@ func Printkv(ctx context.Context, keyvals ...interface{}) {
 				stack = errors.Stack(errors.Wrap(e)) // wrap to ensure callstack
 			}
 		}
-		out += " " + formatKey(k) + "=" + formatValue(v)
+		fields = append(fields, k, v)
 	}
 
 	logWriterMu.Lock()
-	logWriter.Write(procPrefix)
-	logWriter.Write(prefix(ctx))
-	logWriter.Write([]byte(out)) // ignore errors
-	logWriter.Write([]byte{'\n'})
-	writeRawStack(logWriter, stack)
+	var text []byte
+	if keep || format == FormatText {
+		text = formatText(procPrefix, prefix(ctx), fields, stack)
+	}
+	if keep {
+		crashRing.add(text)
+	}
+	if write && format == FormatJSON {
+		var all []interface{}
+		all = append(all, procKV...)
+		all = append(all, prefixKV(ctx)...)
+		all = append(all, fields...)
+		logWriter.Write(formatJSON(all, stack)) // ignore errors
+	} else if write {
+		logWriter.Write(text) // ignore errors
+	}
 	logWriterMu.Unlock()
 }
 
-// Fatalkv is equivalent to Printkv() followed by a call to os.Exit(1).
+// Fatalkv is equivalent to Printkv() followed by a call to os.Exit(1),
+// except that it prints the entry at LevelError, and writes a
+// crash dump if SetCrashDump turned them on.
 func Fatalkv(ctx context.Context, keyvals ...interface{}) {
-	Printkv(ctx, keyvals...)
+	printkv(ctx, LevelError, keyvals)
+	writeCrashDump(ctx, "fatal error")
 	os.Exit(1)
 }
 
+// formatText returns a log entry, with the given prefixes,
+// the fields in keyvals, and stack, as K=V pairs followed
+// by a newline and the lines of the stack, if any.
+func formatText(procPrefix, prefix []byte, keyvals []interface{}, stack interface{}) []byte {
+	var buf bytes.Buffer
+	buf.Write(procPrefix)
+	buf.Write(prefix)
+	out := appendPrefix(nil, keyvals...)
+	buf.Write(out[:len(out)-1]) // trailing space
+	buf.WriteByte('\n')
+	writeRawStack(&buf, stack)
+	return buf.Bytes()
+}
+
+// formatJSON returns a log entry, with the fields in keyvals
+// and stack, as a JSON object followed by a newline.
+func formatJSON(keyvals []interface{}, stack interface{}) []byte {
+	b := []byte{'{'}
+	for i := 0; i < len(keyvals); i += 2 {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendJSON(b, formatKey(keyvals[i]))
+		b = append(b, ':')
+		b = appendJSON(b, jsonValue(keyvals[i+1]))
+	}
+	if lines := stackLines(stack); len(lines) > 0 {
+		b = append(b, ',')
+		b = appendJSON(b, KeyStack)
+		b = append(b, ':')
+		b = appendJSON(b, lines)
+	}
+	return append(b, '}', '\n')
+}
+
+func appendJSON(b []byte, v interface{}) []byte {
+	j, err := json.Marshal(v)
+	if err != nil {
+		j, _ = json.Marshal(fmt.Sprint(v))
+	}
+	return append(b, j...)
+}
+
+// jsonValue returns v as a JSON string,
+// number, or boolean.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string, bool,
+		int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64,
+		float32, float64:
+		return v
+	case error:
+		return v.Error()
+	}
+	return fmt.Sprint(v)
+}
+
+func stackLines(v interface{}) []string {
+	var lines []string
+	switch v := v.(type) {
+	case []byte:
+		if len(v) > 0 {
+			lines = strings.Split(strings.TrimRight(string(v), "\n"), "\n")
+		}
+	case []errors.StackFrame:
+		for _, s := range v {
+			lines = append(lines, s.String())
+		}
+	}
+	return lines
+}
+
 func writeRawStack(w io.Writer, v interface{}) {
 	switch v := v.(type) {
 	case []byte:
@@ -189,20 +345,61 @@ func isStackVal(v interface{}) bool {
 // Printf prints a log entry containing a message assigned to the
 // "message" key. Arguments are handled as in fmt.Printf.
 func Printf(ctx context.Context, format string, a ...interface{}) {
-	Printkv(ctx, KeyMessage, fmt.Sprintf(format, a...))
+	printkv(ctx, LevelInfo, []interface{}{KeyMessage, fmt.Sprintf(format, a...)})
+}
+
+// Debugf is like Printf, but prints the entry at LevelDebug.
+func Debugf(ctx context.Context, format string, a ...interface{}) {
+	if !mightBeEnabled(LevelDebug) {
+		return // don't format
+	}
+	printkv(ctx, LevelDebug, []interface{}{KeyMessage, fmt.Sprintf(format, a...)})
 }
 
-// Error prints a log entry containing an error message assigned to the
-// "error" key.
+// Error prints a log entry at LevelError containing an error message
+// assigned to the "error" key.
 // Optionally, an error message prefix can be included. Prefix arguments are
 // handled as in fmt.Print.
+//
+// Repeats of the same error are counted, rather than
+// printed, for a while after it is; see SetErrorWindow.
 func Error(ctx context.Context, err error, a ...interface{}) {
 	if len(a) > 0 && len(errors.Stack(err)) > 0 {
 		err = errors.Wrap(err, a...) // keep err's stack
 	} else if len(a) > 0 {
 		err = fmt.Errorf("%s: %s", fmt.Sprint(a...), err) // don't add a stack here
 	}
-	Printkv(ctx, KeyError, err)
+	msg := fmt.Sprint(err)
+	at, _ := caller()
+	write, n := repeat(repeatKey{at, msg}, msg)
+	if !write {
+		return
+	}
+	keyvals := []interface{}{KeyError, err}
+	if n > 0 {
+		keyvals = append(keyvals, keyRepeated, n)
+	}
+	printkv(ctx, LevelError, keyvals)
+}
+
+// Errorf prints a log entry at LevelError containing an error message
+// assigned to the "error" key. Arguments are handled as in fmt.Printf.
+//
+// Repeats of the same error, those with the same format,
+// are counted, rather than printed, for a while after
+// it is; see SetErrorWindow.
+func Errorf(ctx context.Context, format string, a ...interface{}) {
+	msg := fmt.Sprintf(format, a...)
+	at, _ := caller()
+	write, n := repeat(repeatKey{at, format}, msg)
+	if !write {
+		return
+	}
+	keyvals := []interface{}{KeyError, msg}
+	if n > 0 {
+		keyvals = append(keyvals, keyRepeated, n)
+	}
+	printkv(ctx, LevelError, keyvals)
 }
 
 // formatKey ensures that the stringified key is valid for use in a
@@ -233,15 +430,21 @@ func formatValue(v interface{}) string {
 }
 
 // RecoverAndLogError must be used inside a defer.
+// It also writes a crash dump if SetCrashDump turned them on.
 func RecoverAndLogError(ctx context.Context) {
 	if err := recover(); err != nil {
-		const size = 64 << 10
-		buf := make([]byte, size)
-		buf = buf[:runtime.Stack(buf, false)]
-		Printkv(ctx,
-			KeyMessage, "panic",
-			KeyError, err,
-			KeyStack, buf,
-		)
+		logPanic(ctx, err)
+		writeCrashDump(ctx, fmt.Sprint("panic: ", err))
 	}
 }
+
+func logPanic(ctx context.Context, err interface{}) {
+	const size = 64 << 10
+	buf := make([]byte, size)
+	buf = buf[:runtime.Stack(buf, false)]
+	printkv(ctx, LevelError, []interface{}{
+		KeyMessage, "panic",
+		KeyError, err,
+		KeyStack, buf,
+	})
+}
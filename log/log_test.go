@@ -3,11 +3,15 @@ package log
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"chain/errors"
 )
@@ -363,3 +367,219 @@ func TestFormatValue(t *testing.T) {
 		}
 	}
 }
+
+func TestLevels(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	defer SetOutput(os.Stdout)
+	defer SetLevels("")
+	SetErrorWindow(0)
+	defer SetErrorWindow(time.Minute)
+
+	cases := []struct {
+		spec  string
+		debug bool
+		info  bool
+	}{
+		{"", false, true},
+		{"error", false, false},
+		{"debug", true, true},
+		{"error,chain=debug", true, true},
+		{"error,chain/log=info", false, true},
+		{"debug,chain/lo=error", true, true},
+		{"info,chain/log=error,chain=debug", false, false},
+	}
+	for _, c := range cases {
+		err := SetLevels(c.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf.Reset()
+		Debugkv(context.Background(), "k", "debug")
+		Printkv(context.Background(), "k", "info")
+		Error(context.Background(), errors.New("boo"))
+
+		got := buf.String()
+		if strings.Contains(got, "k=debug") != c.debug {
+			t.Errorf("levels %q: debug entry written = %t want %t", c.spec, !c.debug, c.debug)
+		}
+		if strings.Contains(got, "k=info") != c.info {
+			t.Errorf("levels %q: info entry written = %t want %t", c.spec, !c.info, c.info)
+		}
+		if !strings.Contains(got, "level=error") {
+			t.Errorf("levels %q: error entry not written", c.spec)
+		}
+	}
+}
+
+func TestSetLevels(t *testing.T) {
+	defer SetLevels("")
+
+	cases := []struct {
+		spec    string
+		want    string
+		wantErr error
+	}{
+		{"", "info", nil},
+		{"debug", "debug", nil},
+		{" chain/core/fetch=debug, error ", "error,chain/core/fetch=debug", nil},
+		{"chain=error,chain/protocol=debug", "info,chain=error,chain/protocol=debug", nil},
+		{"warn", "", ErrBadLevel},
+		{"=debug", "", ErrBadLevel},
+		{"chain=", "", ErrBadLevel},
+	}
+	for _, c := range cases {
+		SetLevels("")
+		err := SetLevels(c.spec)
+		if errors.Root(err) != c.wantErr {
+			t.Errorf("SetLevels(%q) error = %v want %v", c.spec, err, c.wantErr)
+			continue
+		}
+		if got := LevelSpec(); err == nil && got != c.want {
+			t.Errorf("SetLevels(%q): LevelSpec() = %q want %q", c.spec, got, c.want)
+		}
+	}
+}
+
+func TestFormatJSON(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	SetFormat(FormatJSON)
+	SetPrefix("app", "test")
+	defer func() {
+		SetOutput(os.Stdout)
+		SetFormat(FormatText)
+		SetPrefix()
+	}()
+
+	ctx := AddPrefixkv(context.Background(), "reqid", "r1")
+	Printkv(ctx, "n", 1, "ok", true, "msg", "hello world", "stack", []byte("a\nb\n"))
+
+	var got map[string]interface{}
+	err := json.Unmarshal(buf.Bytes(), &got)
+	if err != nil {
+		t.Fatalf("output %q: %s", buf.String(), err)
+	}
+	if !strings.HasPrefix(got[KeyCaller].(string), "log_test.go:") {
+		t.Errorf("at = %v want log_test.go:...", got[KeyCaller])
+	}
+	for k, v := range map[string]interface{}{
+		KeyLevel: "info",
+		"app":    "test",
+		"reqid":  "r1",
+		"n":      1.0,
+		"ok":     true,
+		"msg":    "hello world",
+		KeyStack: []interface{}{"a", "b"},
+	} {
+		if !reflect.DeepEqual(got[k], v) {
+			t.Errorf("%s = %#v want %#v", k, got[k], v)
+		}
+	}
+}
+
+func TestFuncPackage(t *testing.T) {
+	cases := []struct{ name, want string }{
+		{"chain/core/fetch.Fetch", "chain/core/fetch"},
+		{"chain/core/fetch.(*T).f.func1", "chain/core/fetch"},
+		{"chain/net/http/httpjson.Array", "chain/net/http/httpjson"},
+		{"main.main", "main"},
+		{"runtime.goexit", "runtime"},
+	}
+	for _, c := range cases {
+		if got := funcPackage(c.name); got != c.want {
+			t.Errorf("funcPackage(%q) = %q want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestErrorf(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	defer SetOutput(os.Stdout)
+	defer SetErrorWindow(time.Minute)
+
+	// Repeats are errors logged from the same place.
+	errorf := func(i int) { Errorf(context.Background(), "fetching block %d", i) }
+
+	SetErrorWindow(time.Hour)
+	for i := 0; i < 3; i++ {
+		errorf(i)
+	}
+	got := buf.String()
+	if n := strings.Count(got, "\n"); n != 1 || !strings.Contains(got, `error="fetching block 0"`) {
+		t.Errorf("output = %q want one entry for block 0", got)
+	}
+
+	// Start a new window, keeping the count.
+	errorsMu.Lock()
+	for _, s := range repeated {
+		s.printed = time.Now().Add(-2 * time.Hour)
+	}
+	errorsMu.Unlock()
+	buf.Reset()
+	errorf(3)
+	got = buf.String()
+	if !strings.Contains(got, `error="fetching block 3"`) || !strings.Contains(got, "repeated=2") {
+		t.Errorf("output = %q want block 3 with repeated=2", got)
+	}
+	counts := errorCounts()
+	if len(counts) != 1 || counts[0].n != 4 || counts[0].msg != "fetching block 3" {
+		t.Errorf("errorCounts() = %+v want 4 of fetching block 3", counts)
+	}
+}
+
+func TestCrashDump(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crashdump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	SetLevels("error")
+	SetCrashDump(dir, 3)
+	defer func() {
+		SetOutput(os.Stdout)
+		SetLevels("")
+		SetCrashDump("", 0)
+	}()
+
+	for i := 0; i < 5; i++ {
+		Debugkv(context.Background(), "entry", i)
+	}
+	if buf.Len() > 0 {
+		t.Errorf("output = %q want none at level error", buf.String())
+	}
+
+	name, err := WriteCrashDump("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(name) != dir {
+		t.Errorf("crash dump %s not in %s", name, dir)
+	}
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(b)
+	if !strings.HasPrefix(got, "crash dump: test\n") {
+		t.Errorf("crash dump = %q want prefix %q", got, "crash dump: test\n")
+	}
+	for i := 0; i < 5; i++ {
+		entry := fmt.Sprintf("level=debug entry=%d\n", i)
+		if want := i >= 2; strings.Contains(got, entry) != want {
+			t.Errorf("crash dump has %q = %t want %t", entry, !want, want)
+		}
+	}
+	if strings.Index(got, "entry=2") > strings.Index(got, "entry=4") {
+		t.Errorf("crash dump = %q want oldest entry first", got)
+	}
+
+	SetCrashDump(dir, 0)
+	if name, _ := WriteCrashDump("off"); name != "" {
+		t.Errorf("WriteCrashDump with dumps off wrote %s", name)
+	}
+}
@@ -0,0 +1,89 @@
+package log
+
+import (
+	"sync"
+	"time"
+)
+
+// maxRepeated bounds the number of errors tracked
+// for aggregation. When it's reached, errors not
+// seen within the window are forgotten.
+const maxRepeated = 1000
+
+// repeatKey identifies an error by where it
+// was logged and its message or format string.
+type repeatKey struct {
+	at, text string
+}
+
+type repeatState struct {
+	msg        string    // most recent message
+	printed    time.Time // when last written
+	total      int       // times logged, written or not
+	suppressed int       // times not written since printed
+}
+
+var (
+	errorsMu    sync.Mutex // protects the following
+	errorWindow = time.Minute
+	repeated    = make(map[repeatKey]*repeatState)
+)
+
+// SetErrorWindow sets how long after Error or Errorf writes
+// an error they count repeats of it rather than write them.
+// The first repeat written after the window reports how
+// many were counted in field "repeated". A repeat is an
+// error logged from the same place with the same message,
+// or, for Errorf, the same format string.
+// If d is 0, every error is written. The default is 1 minute.
+func SetErrorWindow(d time.Duration) {
+	errorsMu.Lock()
+	errorWindow = d
+	repeated = make(map[repeatKey]*repeatState)
+	errorsMu.Unlock()
+}
+
+// repeat records an error with key k and message msg,
+// and reports whether to write it, and how many
+// repeats were not written since it last was.
+func repeat(k repeatKey, msg string) (write bool, suppressed int) {
+	now := time.Now()
+
+	errorsMu.Lock()
+	defer errorsMu.Unlock()
+	if errorWindow == 0 {
+		return true, 0
+	}
+	s := repeated[k]
+	if s == nil {
+		if len(repeated) >= maxRepeated {
+			forgetRepeated(now)
+		}
+		s = &repeatState{}
+		repeated[k] = s
+	}
+	s.msg = msg
+	s.total++
+	if !s.printed.IsZero() && now.Sub(s.printed) < errorWindow {
+		s.suppressed++
+		return false, 0
+	}
+	suppressed, s.suppressed = s.suppressed, 0
+	s.printed = now
+	return true, suppressed
+}
+
+// forgetRepeated removes the errors that
+// are no longer within the window, or
+// all of them if every one is.
+// errorsMu must be held.
+func forgetRepeated(now time.Time) {
+	for k, s := range repeated {
+		if now.Sub(s.printed) >= errorWindow {
+			delete(repeated, k)
+		}
+	}
+	if len(repeated) >= maxRepeated {
+		repeated = make(map[repeatKey]*repeatState)
+	}
+}
@@ -4,14 +4,22 @@ import (
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 )
 
 var skipFunc = map[string]bool{
 	"chain/log.Printkv":            true,
 	"chain/log.Printf":             true,
+	"chain/log.Debugkv":            true,
+	"chain/log.Debugf":             true,
 	"chain/log.Error":              true,
 	"chain/log.Fatalkv":            true,
 	"chain/log.RecoverAndLogError": true,
+	"chain/log.Errorf":             true,
+	"chain/log.DumpOnPanic":        true,
+	"chain/log.printkv":            true,
+	"chain/log.logPanic":           true,
+	"chain/log.writeCrashDump":     true,
 }
 
 // SkipFunc removes the named function from stack traces
@@ -27,18 +35,31 @@ func SkipFunc(name string) {
 
 // caller returns a string containing filename and line number of
 // the deepest function invocation on the calling goroutine's stack,
-// after skipping functions in skipFunc.
-// If no stack information is available, it returns "?:?".
-func caller() string {
+// after skipping functions in skipFunc, and the import path of
+// that function's package, which is the module it logs for.
+// If no stack information is available, it returns "?:?" and "".
+func caller() (at, module string) {
 	for i := 1; ; i++ {
 		// NOTE(kr): This is quadratic in the number of frames we
 		// ultimately have to skip. Consider using Callers instead.
 		pc, file, line, ok := runtime.Caller(i)
 		if !ok {
-			return "?:?"
+			return "?:?", ""
 		}
-		if !skipFunc[runtime.FuncForPC(pc).Name()] {
-			return filepath.Base(file) + ":" + strconv.Itoa(line)
+		name := runtime.FuncForPC(pc).Name()
+		if !skipFunc[name] {
+			return filepath.Base(file) + ":" + strconv.Itoa(line), funcPackage(name)
 		}
 	}
 }
+
+// funcPackage returns the import path of the package
+// of the function with the fully-qualified name,
+// such as chain/core/fetch for chain/core/fetch.(*T).f.
+func funcPackage(name string) string {
+	slash := strings.LastIndex(name, "/") + 1
+	if dot := strings.Index(name[slash:], "."); dot >= 0 {
+		return name[:slash+dot]
+	}
+	return name
+}
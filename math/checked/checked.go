@@ -266,3 +266,21 @@ func LshiftUint32(a, b uint32) (result uint32, ok bool) {
 	}
 	return a << uint(b), true
 }
+
+// Uint64ToInt64 returns a as an int64
+// with an integer overflow check.
+func Uint64ToInt64(a uint64) (b int64, ok bool) {
+	if a > math.MaxInt64 {
+		return 0, false
+	}
+	return int64(a), true
+}
+
+// Int64ToUint64 returns a as a uint64,
+// failing if a is negative.
+func Int64ToUint64(a int64) (b uint64, ok bool) {
+	if a < 0 {
+		return 0, false
+	}
+	return uint64(a), true
+}
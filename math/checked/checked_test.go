@@ -117,6 +117,42 @@ func TestUint64(t *testing.T) {
 	}
 }
 
+func TestConvert64(t *testing.T) {
+	uCases := []struct {
+		a      uint64
+		want   int64
+		wantOk bool
+	}{
+		{0, 0, true},
+		{math.MaxInt64, math.MaxInt64, true},
+		{math.MaxInt64 + 1, 0, false},
+		{math.MaxUint64, 0, false},
+	}
+	for _, c := range uCases {
+		got, gotOk := Uint64ToInt64(c.a)
+		if got != c.want || gotOk != c.wantOk {
+			t.Errorf("Uint64ToInt64(%d) = %d, %v want %d, %v", c.a, got, gotOk, c.want, c.wantOk)
+		}
+	}
+
+	iCases := []struct {
+		a      int64
+		want   uint64
+		wantOk bool
+	}{
+		{0, 0, true},
+		{math.MaxInt64, math.MaxInt64, true},
+		{-1, 0, false},
+		{math.MinInt64, 0, false},
+	}
+	for _, c := range iCases {
+		got, gotOk := Int64ToUint64(c.a)
+		if got != c.want || gotOk != c.wantOk {
+			t.Errorf("Int64ToUint64(%d) = %d, %v want %d, %v", c.a, got, gotOk, c.want, c.wantOk)
+		}
+	}
+}
+
 func TestInt32(t *testing.T) {
 	cases := []struct {
 		f          func(a, b int32) (int32, bool)
@@ -0,0 +1,125 @@
+// Package authz authorizes HTTP requests with grants.
+//
+// A grant gives a policy to the requests that satisfy its
+// guard: those authenticated with a certain access token,
+// those with a client certificate whose subject matches,
+// or those from the local host. Each route allows some
+// policies, and a request may use the route if any grant
+// satisfied by the request gives it one of them. The admin
+// policy is allowed on every route.
+package authz
+
+import (
+	"context"
+	"crypto/x509/pkix"
+	"sync"
+	"time"
+
+	"chain/errors"
+)
+
+// Policies.
+const (
+	PolicyClientReadWrite = "client-readwrite"
+	PolicyClientReadOnly  = "client-readonly"
+	PolicyNetwork         = "network"
+	PolicyMonitoring      = "monitoring"
+	PolicyAdmin           = "admin"
+)
+
+var validPolicies = map[string]bool{
+	PolicyClientReadWrite: true,
+	PolicyClientReadOnly:  true,
+	PolicyNetwork:         true,
+	PolicyMonitoring:      true,
+	PolicyAdmin:           true,
+}
+
+// ErrNotAuthorized is returned by Authorize
+// when no grant allows the request.
+var ErrNotAuthorized = errors.New("not authorized")
+
+// grantExpiry is how long an Authorizer uses the grants it
+// loaded before loading them again, so changes to the grants
+// take effect after at most this long.
+const grantExpiry = time.Minute
+
+// Credentials describe what authentication
+// established about the sender of a request.
+type Credentials struct {
+	// AccessTokenID is the ID of the access token
+	// that authenticated the request, or "" if none did.
+	// AccessTokenType and AccessTokenScopes describe it.
+	AccessTokenID     string
+	AccessTokenType   string
+	AccessTokenScopes []string
+
+	// X509Subject is the subject of the verified
+	// client certificate of the request, if any.
+	X509Subject *pkix.Name
+
+	// Localhost is true if the request
+	// came from the loopback interface.
+	Localhost bool
+}
+
+// An Authorizer authorizes requests to
+// routes with the grants in a Store.
+type Authorizer struct {
+	store    *Store
+	policies map[string][]string
+
+	mu     sync.Mutex // protects the following
+	grants []*Grant
+	loaded time.Time
+}
+
+// NewAuthorizer returns an Authorizer with the grants in store.
+// Policies maps each route to the policies, other than admin,
+// that it allows. A route not in policies allows only admin.
+func NewAuthorizer(store *Store, policies map[string][]string) *Authorizer {
+	return &Authorizer{store: store, policies: policies}
+}
+
+// Authorize returns nil if a grant satisfied by creds gives
+// a policy allowed on route, and ErrNotAuthorized otherwise.
+func (a *Authorizer) Authorize(ctx context.Context, creds *Credentials, route string) error {
+	grants, err := a.cachedGrants(ctx)
+	if err != nil {
+		return err
+	}
+	for _, g := range grants {
+		if allows(a.policies[route], g.Policy) && g.guard.satisfiedBy(creds) {
+			return nil
+		}
+	}
+	return ErrNotAuthorized
+}
+
+func (a *Authorizer) cachedGrants(ctx context.Context) ([]*Grant, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.loaded.IsZero() || time.Since(a.loaded) > grantExpiry {
+		grants, err := a.store.List(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading grants")
+		}
+		a.grants = grants
+		a.loaded = time.Now()
+	}
+	return a.grants, nil
+}
+
+// allows reports whether a route allowing
+// policies allows policy.
+func allows(policies []string, policy string) bool {
+	if policy == PolicyAdmin {
+		return true
+	}
+	for _, p := range policies {
+		if p == policy {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package authz
+
+import (
+	"context"
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+
+	"chain/errors"
+)
+
+func TestParseGuard(t *testing.T) {
+	cases := []struct {
+		typ, data string
+		want      string
+		wantErr   error
+	}{
+		{GuardAccessToken, `{"type": "client", "id": "alice"}`, `{"id":"alice","type":"client"}`, nil},
+		{GuardAccessToken, `{"scope": "read-accounts"}`, `{"scope":"read-accounts"}`, nil},
+		{GuardAccessToken, `{}`, ``, ErrBadGuardData},
+		{GuardAccessToken, `{"type": "root"}`, ``, ErrBadGuardData},
+		{GuardX509, `{"subject": {"OU": "ops", "CN": "monitor"}}`, `{"subject":{"CN":"monitor","OU":"ops"}}`, nil},
+		{GuardX509, `{"subject": {"UID": "x"}}`, ``, ErrBadGuardData},
+		{GuardX509, `{"subject": "CN=monitor"}`, ``, ErrBadGuardData},
+		{GuardLocalhost, ``, `{}`, nil},
+		{"password", `{}`, ``, ErrBadGuardType},
+	}
+	for _, c := range cases {
+		_, got, err := parseGuard(c.typ, []byte(c.data))
+		if errors.Root(err) != c.wantErr {
+			t.Errorf("parseGuard(%s, %s) error = %v want %v", c.typ, c.data, err, c.wantErr)
+			continue
+		}
+		if err == nil && string(got) != c.want {
+			t.Errorf("parseGuard(%s, %s) = %s want %s", c.typ, c.data, got, c.want)
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	grant := func(typ, data, policy string) *Grant {
+		g, err := newGrant(typ, []byte(data), policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return g
+	}
+	a := NewAuthorizer(nil, map[string][]string{
+		"/list-accounts":  {PolicyClientReadWrite, PolicyClientReadOnly},
+		"/create-account": {PolicyClientReadWrite},
+		"/debug/vars":     {PolicyMonitoring},
+	})
+	a.grants = []*Grant{
+		grant(GuardAccessToken, `{"id": "reader"}`, PolicyClientReadOnly),
+		grant(GuardAccessToken, `{"type": "client", "scope": "submit-tx"}`, PolicyClientReadWrite),
+		grant(GuardX509, `{"subject": {"CN": "monitor", "OU": "ops"}}`, PolicyMonitoring),
+		grant(GuardLocalhost, `{}`, PolicyAdmin),
+	}
+	a.loaded = time.Now()
+
+	reader := &Credentials{AccessTokenID: "reader", AccessTokenType: "client"}
+	submitter := &Credentials{AccessTokenID: "app", AccessTokenType: "client", AccessTokenScopes: []string{"submit-tx"}}
+	unscoped := &Credentials{AccessTokenID: "app", AccessTokenType: "client"}
+	monitor := &Credentials{X509Subject: &pkix.Name{CommonName: "monitor", OrganizationalUnit: []string{"dev", "ops"}}}
+	stranger := &Credentials{X509Subject: &pkix.Name{CommonName: "monitor"}}
+	local := &Credentials{Localhost: true}
+
+	cases := []struct {
+		creds *Credentials
+		route string
+		want  bool
+	}{
+		{reader, "/list-accounts", true},
+		{reader, "/create-account", false},
+		{submitter, "/create-account", true},
+		{unscoped, "/list-accounts", false},
+		{monitor, "/debug/vars", true},
+		{monitor, "/list-accounts", false},
+		{stranger, "/debug/vars", false},
+		{local, "/configure", true},
+		{reader, "/configure", false},
+	}
+	for _, c := range cases {
+		err := a.Authorize(context.Background(), c.creds, c.route)
+		if got := err == nil; got != c.want {
+			t.Errorf("Authorize(%+v, %s) = %v want allowed %t", c.creds, c.route, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package authz
+
+import (
+	"crypto/x509/pkix"
+	"encoding/json"
+
+	"chain/errors"
+)
+
+// Guard types.
+const (
+	GuardAccessToken = "access_token"
+	GuardX509        = "x509"
+	GuardLocalhost   = "localhost"
+)
+
+var (
+	ErrBadGuardType = errors.New("unknown guard type")
+	ErrBadGuardData = errors.New("invalid guard data")
+	ErrBadPolicy    = errors.New("unknown policy")
+)
+
+// A guard decides which requests a grant applies to.
+type guard interface {
+	satisfiedBy(*Credentials) bool
+}
+
+// parseGuard parses the guard data of a grant with
+// the given guard type, and returns it in canonical form.
+func parseGuard(typ string, data []byte) (guard, []byte, error) {
+	var g guard
+	switch typ {
+	case GuardAccessToken:
+		g = new(accessTokenGuard)
+	case GuardX509:
+		g = new(x509Guard)
+	case GuardLocalhost:
+		g = new(localhostGuard)
+	default:
+		return nil, nil, errors.WithDetailf(ErrBadGuardType, "guard type %q", typ)
+	}
+	if len(data) == 0 {
+		data = []byte(`{}`)
+	}
+	err := json.Unmarshal(data, g)
+	if err != nil {
+		return nil, nil, errors.WithDetail(ErrBadGuardData, err.Error())
+	}
+	if v, ok := g.(interface {
+		validate() error
+	}); ok {
+		err = v.validate()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	canon, err := json.Marshal(g)
+	if err != nil {
+		return nil, nil, errors.Wrap(err)
+	}
+	return g, canon, nil
+}
+
+// accessTokenGuard is satisfied by requests authenticated
+// with an access token that has all of the given
+// properties. A token limited to no scopes does not
+// have any scope in this sense.
+type accessTokenGuard struct {
+	ID    string `json:"id,omitempty"`
+	Type  string `json:"type,omitempty"`
+	Scope string `json:"scope,omitempty"`
+}
+
+func (g *accessTokenGuard) validate() error {
+	if g.ID == "" && g.Type == "" && g.Scope == "" {
+		return errors.WithDetail(ErrBadGuardData, "access token guard needs an id, type, or scope")
+	}
+	if g.Type != "" && g.Type != "client" && g.Type != "network" {
+		return errors.WithDetailf(ErrBadGuardData, "unknown access token type %q", g.Type)
+	}
+	return nil
+}
+
+func (g *accessTokenGuard) satisfiedBy(c *Credentials) bool {
+	if c.AccessTokenID == "" {
+		return false
+	}
+	if g.ID != "" && g.ID != c.AccessTokenID {
+		return false
+	}
+	if g.Type != "" && g.Type != c.AccessTokenType {
+		return false
+	}
+	return g.Scope == "" || contains(c.AccessTokenScopes, g.Scope)
+}
+
+// x509Guard is satisfied by requests with a verified client
+// certificate whose subject has the given value for each of
+// the given attributes, named as in RFC 4514, such as CN
+// and OU. An attribute with several values in the subject
+// needs only one of them to match.
+type x509Guard struct {
+	Subject map[string]string `json:"subject"`
+}
+
+func (g *x509Guard) validate() error {
+	if len(g.Subject) == 0 {
+		return errors.WithDetail(ErrBadGuardData, "x509 guard needs a subject")
+	}
+	for attr := range g.Subject {
+		if subjectValues(new(pkix.Name), attr) == nil {
+			return errors.WithDetailf(ErrBadGuardData, "unknown subject attribute %q", attr)
+		}
+	}
+	return nil
+}
+
+func (g *x509Guard) satisfiedBy(c *Credentials) bool {
+	if c.X509Subject == nil {
+		return false
+	}
+	for attr, v := range g.Subject {
+		if !contains(subjectValues(c.X509Subject, attr), v) {
+			return false
+		}
+	}
+	return true
+}
+
+// subjectValues returns the values of the attribute named attr
+// in subject, or nil if there is no such attribute.
+func subjectValues(subject *pkix.Name, attr string) []string {
+	switch attr {
+	case "C":
+		return append([]string{}, subject.Country...)
+	case "O":
+		return append([]string{}, subject.Organization...)
+	case "OU":
+		return append([]string{}, subject.OrganizationalUnit...)
+	case "L":
+		return append([]string{}, subject.Locality...)
+	case "ST":
+		return append([]string{}, subject.Province...)
+	case "STREET":
+		return append([]string{}, subject.StreetAddress...)
+	case "POSTALCODE":
+		return append([]string{}, subject.PostalCode...)
+	case "SERIALNUMBER":
+		return []string{subject.SerialNumber}
+	case "CN":
+		return []string{subject.CommonName}
+	}
+	return nil
+}
+
+// localhostGuard is satisfied by requests
+// from the loopback interface.
+type localhostGuard struct{}
+
+func (g *localhostGuard) satisfiedBy(c *Credentials) bool {
+	return c.Localhost
+}
+
+func contains(a []string, s string) bool {
+	for _, x := range a {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package authz
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"chain/database/pg"
+	"chain/errors"
+)
+
+// A Grant gives the requests that satisfy
+// its guard the use of its policy.
+type Grant struct {
+	GuardType string          `json:"guard_type"`
+	GuardData json.RawMessage `json:"guard_data"`
+	Policy    string          `json:"policy"`
+	CreatedAt time.Time       `json:"created_at"`
+
+	guard guard
+}
+
+// Store holds grants in the core's database.
+type Store struct {
+	DB pg.DB
+}
+
+// newGrant validates a grant, and returns
+// it with its guard data in canonical form.
+func newGrant(guardType string, guardData []byte, policy string) (*Grant, error) {
+	if !validPolicies[policy] {
+		return nil, errors.WithDetailf(ErrBadPolicy, "policy %q", policy)
+	}
+	g, data, err := parseGuard(guardType, guardData)
+	if err != nil {
+		return nil, err
+	}
+	return &Grant{GuardType: guardType, GuardData: data, Policy: policy, guard: g}, nil
+}
+
+// Create stores a grant of policy to the requests that
+// satisfy the guard of type guardType with guardData.
+// If the grant already exists, Create returns it.
+func (s *Store) Create(ctx context.Context, guardType string, guardData []byte, policy string) (*Grant, error) {
+	g, err := newGrant(guardType, guardData, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	const q = `
+		INSERT INTO authz_grants (guard_type, guard_data, policy)
+		VALUES ($1, $2::jsonb, $3)
+		ON CONFLICT (guard_type, guard_data, policy) DO UPDATE SET policy = EXCLUDED.policy
+		RETURNING created_at
+	`
+	err = s.DB.QueryRow(ctx, q, g.GuardType, string(g.GuardData), g.Policy).Scan(&g.CreatedAt)
+	if err != nil {
+		return nil, errors.Wrap(err, "inserting grant")
+	}
+	return g, nil
+}
+
+// List returns all grants, oldest first.
+func (s *Store) List(ctx context.Context) ([]*Grant, error) {
+	const q = `
+		SELECT guard_type, guard_data, policy, created_at
+		FROM authz_grants ORDER BY created_at, guard_type, policy
+	`
+	var grants []*Grant
+	err := pg.ForQueryRows(ctx, s.DB, q, func(guardType string, guardData []byte, policy string, created time.Time) error {
+		g, err := newGrant(guardType, guardData, policy)
+		if err != nil {
+			return errors.Wrapf(err, "grant of %s to %s guard %s", policy, guardType, guardData)
+		}
+		g.CreatedAt = created
+		grants = append(grants, g)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "listing grants")
+	}
+	return grants, nil
+}
+
+// Delete deletes the grant of policy to
+// the guard of type guardType with guardData.
+func (s *Store) Delete(ctx context.Context, guardType string, guardData []byte, policy string) error {
+	g, err := newGrant(guardType, guardData, policy)
+	if err != nil {
+		return err
+	}
+
+	const q = `
+		DELETE FROM authz_grants
+		WHERE guard_type=$1 AND guard_data=$2::jsonb AND policy=$3
+	`
+	res, err := s.DB.Exec(ctx, q, g.GuardType, string(g.GuardData), g.Policy)
+	if err != nil {
+		return errors.Wrap(err, "deleting grant")
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	if deleted == 0 {
+		return errors.WithDetailf(pg.ErrUserInputNotFound, "grant of %s to %s guard %s", policy, guardType, g.GuardData)
+	}
+	return nil
+}
+
+// DeleteAccessToken deletes the grants whose guards
+// name the access token with the given ID.
+func (s *Store) DeleteAccessToken(ctx context.Context, id string) error {
+	const q = `
+		DELETE FROM authz_grants
+		WHERE guard_type=$1 AND guard_data->>'id'=$2
+	`
+	_, err := s.DB.Exec(ctx, q, GuardAccessToken, id)
+	return errors.Wrap(err, "deleting access token grants")
+}
@@ -49,6 +49,7 @@ type responseWriter struct {
 
 var _ http.ResponseWriter = (*responseWriter)(nil)
 var _ http.Hijacker = (*responseWriter)(nil)
+var _ http.Flusher = (*responseWriter)(nil)
 
 func (w *responseWriter) Write(p []byte) (int, error) { return w.w.Write(p) }
 
@@ -59,3 +60,16 @@ func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	}
 	return h.Hijack()
 }
+
+// Flush sends any compressed data buffered so far
+// to the client, for handlers that stream a response.
+func (w *responseWriter) Flush() {
+	if f, ok := w.w.(interface {
+		Flush() error
+	}); ok {
+		f.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
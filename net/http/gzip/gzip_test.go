@@ -91,3 +91,17 @@ func TestNoGzip(t *testing.T) {
 		t.Error("unexpected gzip")
 	}
 }
+
+func TestGzipFlush(t *testing.T) {
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/foo", nil)
+	r.Header.Set("accept-encoding", "gzip")
+	h := Handler{http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		io.WriteString(rw, "hello, world")
+		rw.(http.Flusher).Flush()
+		if !w.Flushed || w.Body.Len() == 0 {
+			t.Errorf("Flush did not send buffered data: flushed %v, %d bytes", w.Flushed, w.Body.Len())
+		}
+	})}
+	h.ServeHTTP(w, r)
+}
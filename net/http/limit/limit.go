@@ -2,7 +2,9 @@ package limit
 
 import (
 	"net/http"
+	"strconv"
 	"sync"
+	"time"
 
 	"golang.org/x/time/rate"
 )
@@ -57,13 +59,51 @@ func Handler(next, limited http.Handler, freq, burst int, f func(*http.Request)
 
 func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	id := h.f(r)
-	if !h.limiter.Allow(id) {
+	res := h.limiter.bucket(id).Reserve()
+	if !res.OK() {
+		retryAfter(w, time.Second)
+		h.limited.ServeHTTP(w, r)
+		return
+	}
+	if d := res.Delay(); d > 0 {
+		// Give back the token, and tell the client
+		// how long until it would have been granted.
+		res.Cancel()
+		retryAfter(w, d)
 		h.limited.ServeHTTP(w, r)
 		return
 	}
 	h.next.ServeHTTP(w, r)
 }
 
+// Concurrency returns a handler that serves at most max
+// requests at a time with next. It serves the requests
+// beyond that with saturated, telling clients to retry
+// after retry.
+func Concurrency(next, saturated http.Handler, max int, retry time.Duration) http.Handler {
+	sem := make(chan struct{}, max)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case sem <- struct{}{}:
+			defer func() { <-sem }()
+			next.ServeHTTP(w, r)
+		default:
+			retryAfter(w, retry)
+			saturated.ServeHTTP(w, r)
+		}
+	})
+}
+
+// retryAfter sets the Retry-After header of w
+// to d, rounded up to a whole number of seconds.
+func retryAfter(w http.ResponseWriter, d time.Duration) {
+	secs := int64((d + time.Second - 1) / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	w.Header().Set("Retry-After", strconv.FormatInt(secs, 10))
+}
+
 func RemoteAddrID(r *http.Request) string {
 	return r.RemoteAddr
 }
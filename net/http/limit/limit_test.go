@@ -0,0 +1,119 @@
+package limit
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandlerRetryAfter(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	limited := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	h := Handler(next, limited, 1, 1, RemoteAddrID)
+
+	for i, want := range []int{200, 429} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("POST", "/info", nil))
+		if w.Code != want {
+			t.Errorf("request %d: status = %d want %d", i, w.Code, want)
+		}
+		if got := w.Header().Get("Retry-After"); (want == 429) != (got == "1") {
+			t.Errorf("request %d: Retry-After = %q", i, got)
+		}
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	})
+	saturated := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	h := Concurrency(next, saturated, 1, 1500*time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", nil))
+		close(done)
+	}()
+	<-started
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "2" {
+		t.Errorf("saturated: status = %d, Retry-After = %q want 503, 2", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	close(release)
+	<-done
+	go func() { <-started }()
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("after release: status = %d want 200", w.Code)
+	}
+}
+
+func TestBodySize(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+	tooLarge := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	})
+	h := BodySize(next, tooLarge, 4, map[string]int64{"/big": 0, "/small": 2})
+
+	cases := []struct {
+		path, body string
+		chunked    bool
+		want       int
+	}{
+		{"/", "1234", false, 200},
+		{"/", "12345", false, 413},
+		{"/", "12345", true, 400},
+		{"/small", "123", false, 413},
+		{"/big", strings.Repeat("x", 100), false, 200},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("POST", c.path, strings.NewReader(c.body))
+		if c.chunked {
+			req.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != c.want {
+			t.Errorf("POST %s with %d bytes (chunked %t): status = %d want %d", c.path, len(c.body), c.chunked, w.Code, c.want)
+		}
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	var deadline time.Time
+	var ok bool
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, ok = r.Context().Deadline()
+	})
+	h := Timeout(next, map[string]time.Duration{"/quick": time.Second})
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/slow", nil))
+	if ok {
+		t.Errorf("/slow has deadline %v, want none", deadline)
+	}
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/quick", nil))
+	if !ok || deadline.Sub(time.Now()) > time.Second {
+		t.Errorf("/quick deadline = %v, %t want within 1s", deadline, ok)
+	}
+}
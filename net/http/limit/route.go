@@ -0,0 +1,47 @@
+package limit
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// BodySize returns a handler that limits request bodies to
+// max bytes, or, for the paths in routes, to the number of
+// bytes given there, where 0 means no limit. It serves
+// requests that declare a longer body with tooLarge.
+// Reading a longer body of undeclared length fails
+// once it passes the limit.
+func BodySize(next, tooLarge http.Handler, max int64, routes map[string]int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, ok := routes[r.URL.Path]
+		if !ok {
+			n = max
+		}
+		if n > 0 {
+			if r.ContentLength > n {
+				tooLarge.ServeHTTP(w, r)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Timeout returns a handler that serves requests to the
+// paths in timeouts with a context that is canceled after
+// the path's timeout. A shorter deadline already in the
+// request's context still applies.
+func Timeout(next http.Handler, timeouts map[string]time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d, ok := timeouts[r.URL.Path]
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
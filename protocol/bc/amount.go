@@ -0,0 +1,32 @@
+package bc
+
+import (
+	"chain/errors"
+	"chain/math/checked"
+)
+
+// errOverflow is returned when an asset amount
+// can't be represented in the VM's int64,
+// or a VM integer can't be an asset amount.
+var errOverflow = errors.New("asset amount out of range")
+
+// AmountToVM converts an asset amount to the int64
+// representation the VM uses, returning an error
+// if amount exceeds math.MaxInt64.
+func AmountToVM(amount uint64) (int64, error) {
+	n, ok := checked.Uint64ToInt64(amount)
+	if !ok {
+		return 0, errors.WithDetailf(errOverflow, "amount %d exceeds the maximum of int64", amount)
+	}
+	return n, nil
+}
+
+// AmountFromVM converts a VM integer to an asset amount,
+// returning an error if n is negative.
+func AmountFromVM(n int64) (uint64, error) {
+	amount, ok := checked.Int64ToUint64(n)
+	if !ok {
+		return 0, errors.WithDetailf(errOverflow, "amount %d is negative", n)
+	}
+	return amount, nil
+}
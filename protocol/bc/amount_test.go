@@ -0,0 +1,54 @@
+package bc
+
+import (
+	"math"
+	"testing"
+
+	"chain/errors"
+)
+
+func TestAmountToVM(t *testing.T) {
+	cases := []struct {
+		amount  uint64
+		want    int64
+		wantErr error
+	}{
+		{0, 0, nil},
+		{1, 1, nil},
+		{math.MaxInt64, math.MaxInt64, nil},
+		{math.MaxInt64 + 1, 0, errOverflow},
+		{math.MaxUint64, 0, errOverflow},
+	}
+	for _, c := range cases {
+		got, err := AmountToVM(c.amount)
+		if errors.Root(err) != c.wantErr {
+			t.Errorf("AmountToVM(%d) err = %v want %v", c.amount, err, c.wantErr)
+		}
+		if got != c.want {
+			t.Errorf("AmountToVM(%d) = %d want %d", c.amount, got, c.want)
+		}
+	}
+}
+
+func TestAmountFromVM(t *testing.T) {
+	cases := []struct {
+		n       int64
+		want    uint64
+		wantErr error
+	}{
+		{0, 0, nil},
+		{1, 1, nil},
+		{math.MaxInt64, math.MaxInt64, nil},
+		{-1, 0, errOverflow},
+		{math.MinInt64, 0, errOverflow},
+	}
+	for _, c := range cases {
+		got, err := AmountFromVM(c.n)
+		if errors.Root(err) != c.wantErr {
+			t.Errorf("AmountFromVM(%d) err = %v want %v", c.n, err, c.wantErr)
+		}
+		if got != c.want {
+			t.Errorf("AmountFromVM(%d) = %d want %d", c.n, got, c.want)
+		}
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"io"
 	"reflect"
 
-	"chain/crypto/sha3pool"
 	"chain/encoding/blockchain"
 	"chain/errors"
 )
@@ -33,7 +32,8 @@ type Entry interface {
 var errInvalidValue = errors.New("invalid value")
 
 // EntryID computes the identifier of an entry, as the hash of its
-// body plus some metadata.
+// body plus some metadata. It uses the hash function
+// set by SetHashFunc (SHA3-256 by default).
 func EntryID(e Entry) (hash Hash) {
 	if e == nil {
 		return hash
@@ -45,24 +45,26 @@ func EntryID(e Entry) (hash Hash) {
 		return hash
 	}
 
-	hasher := sha3pool.Get256()
-	defer sha3pool.Put256(hasher)
+	hf := CurrentHashFunc()
+
+	hasher := hf.get()
+	defer hf.put(hasher)
 
 	hasher.Write([]byte("entryid:"))
 	hasher.Write([]byte(e.Type()))
 	hasher.Write([]byte{':'})
 
-	bh := sha3pool.Get256()
-	defer sha3pool.Put256(bh)
+	bh := hf.get()
+	defer hf.put(bh)
 	err := writeForHash(bh, e.Body())
 	if err != nil {
 		panic(err)
 	}
 	var innerHash Hash
-	bh.Read(innerHash[:])
+	bh.Sum(innerHash[:0])
 	hasher.Write(innerHash[:])
 
-	hasher.Read(hash[:])
+	hasher.Sum(hash[:0])
 	return hash
 }
 
@@ -0,0 +1,127 @@
+package bc
+
+import (
+	"bytes"
+	"fmt"
+	"hash"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/crypto/sha3"
+
+	"chain/encoding/blockchain"
+	"chain/errors"
+)
+
+// HashFunc is a hash function for computing entry IDs
+// (and so transaction and block IDs).
+//
+// The hash function is fixed for the lifetime of a blockchain.
+// A blockchain using anything other than the default commits
+// to its choice in the initial block. See HashFuncID.
+type HashFunc struct {
+	// ID identifies the hash function in the initial block.
+	// The default, SHA3-256, has ID 0.
+	ID uint64
+
+	// Name is a short human-readable name for the hash function.
+	Name string
+
+	// New returns a new hash state.
+	// Its Sum must produce exactly 32 bytes.
+	New func() hash.Hash
+
+	pool sync.Pool
+}
+
+func (hf *HashFunc) get() hash.Hash {
+	if h, ok := hf.pool.Get().(hash.Hash); ok {
+		return h
+	}
+	return hf.New()
+}
+
+func (hf *HashFunc) put(h hash.Hash) {
+	h.Reset()
+	hf.pool.Put(h)
+}
+
+// SHA3 is the default hash function, SHA3-256.
+var SHA3 = &HashFunc{ID: 0, Name: "sha3-256", New: sha3.New256}
+
+// ErrUnknownHashFunc is returned when an initial block
+// commits to a hash function that is not registered.
+var ErrUnknownHashFunc = errors.New("unknown hash function")
+
+var (
+	hashFuncsMu sync.Mutex
+	hashFuncs   = map[uint64]*HashFunc{SHA3.ID: SHA3}
+
+	curHashFunc atomic.Value // *HashFunc
+)
+
+func init() {
+	curHashFunc.Store(SHA3)
+}
+
+// RegisterHashFunc makes hf available for use
+// by SetHashFunc and LookupHashFunc.
+// It panics if a hash function with the same ID
+// is already registered.
+// Experimental networks can use this to evaluate
+// alternatives such as BLAKE2b or HSM-backed hashing.
+func RegisterHashFunc(hf *HashFunc) {
+	hashFuncsMu.Lock()
+	defer hashFuncsMu.Unlock()
+	if _, ok := hashFuncs[hf.ID]; ok {
+		panic(fmt.Sprintf("bc: hash function %d registered twice", hf.ID))
+	}
+	hashFuncs[hf.ID] = hf
+}
+
+// LookupHashFunc returns the registered hash function with the given ID.
+func LookupHashFunc(id uint64) (*HashFunc, error) {
+	hashFuncsMu.Lock()
+	defer hashFuncsMu.Unlock()
+	hf, ok := hashFuncs[id]
+	if !ok {
+		return nil, errors.WithDetailf(ErrUnknownHashFunc, "hash function id %d", id)
+	}
+	return hf, nil
+}
+
+// SetHashFunc sets the hash function used by EntryID.
+// It should be called once, before any entry IDs are computed,
+// with the hash function named by the blockchain's initial block.
+func SetHashFunc(hf *HashFunc) {
+	curHashFunc.Store(hf)
+}
+
+// CurrentHashFunc returns the hash function used by EntryID.
+func CurrentHashFunc() *HashFunc {
+	return curHashFunc.Load().(*HashFunc)
+}
+
+// HashFuncCommitment returns the commitment suffix
+// an initial block uses to commit to hf.
+// It is empty for the default hash function,
+// so that blockchains using SHA3-256 are unaffected.
+func HashFuncCommitment(hf *HashFunc) []byte {
+	if hf.ID == SHA3.ID {
+		return nil
+	}
+	var buf bytes.Buffer
+	blockchain.WriteVarint63(&buf, hf.ID) // error is impossible
+	return buf.Bytes()
+}
+
+// HashFuncID returns the ID of the hash function
+// the initial block bh commits to.
+// It is only meaningful for the initial block.
+func (bh *BlockHeader) HashFuncID() (uint64, error) {
+	if len(bh.CommitmentSuffix) == 0 {
+		return SHA3.ID, nil
+	}
+	id, _, err := blockchain.ReadVarint63(bytes.NewReader(bh.CommitmentSuffix))
+	return id, errors.Wrap(err, "reading hash function commitment")
+}
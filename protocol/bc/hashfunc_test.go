@@ -0,0 +1,63 @@
+package bc
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"chain/errors"
+)
+
+var testSHA256 = &HashFunc{ID: 99, Name: "sha2-256", New: sha256.New}
+
+func init() {
+	RegisterHashFunc(testSHA256)
+}
+
+func TestSetHashFunc(t *testing.T) {
+	e := NewRetirement(Hash{}, 1)
+	want := EntryID(e)
+
+	SetHashFunc(testSHA256)
+	got := EntryID(e)
+	SetHashFunc(SHA3)
+
+	if got == want {
+		t.Error("expected a different entry ID with a different hash function")
+	}
+	if again := EntryID(e); again != want {
+		t.Errorf("EntryID after restoring SHA3 = %x want %x", again[:], want[:])
+	}
+}
+
+func TestHashFuncCommitment(t *testing.T) {
+	if c := HashFuncCommitment(SHA3); len(c) != 0 {
+		t.Errorf("HashFuncCommitment(SHA3) = %x want empty", c)
+	}
+
+	cases := []*HashFunc{SHA3, testSHA256}
+	for _, hf := range cases {
+		bh := &BlockHeader{Height: 1, CommitmentSuffix: HashFuncCommitment(hf)}
+		id, err := bh.HashFuncID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != hf.ID {
+			t.Errorf("HashFuncID() = %d want %d", id, hf.ID)
+		}
+	}
+}
+
+func TestLookupHashFunc(t *testing.T) {
+	hf, err := LookupHashFunc(testSHA256.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hf != testSHA256 {
+		t.Errorf("LookupHashFunc(%d) = %v want %v", testSHA256.ID, hf, testSHA256)
+	}
+
+	_, err = LookupHashFunc(12345)
+	if errors.Root(err) != ErrUnknownHashFunc {
+		t.Errorf("LookupHashFunc(12345) error = %v want %v", err, ErrUnknownHashFunc)
+	}
+}
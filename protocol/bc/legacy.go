@@ -0,0 +1,57 @@
+package bc
+
+import (
+	"bytes"
+
+	"chain/errors"
+)
+
+var errTrailingData = errors.New("trailing data after transaction")
+
+// TxEntries is a transaction in entries form:
+// its header plus every entry reachable from it.
+type TxEntries struct {
+	ID      Hash // the ID of Header, which is the transaction ID
+	Header  *TxHeader
+	Entries map[Hash]Entry // includes Header
+}
+
+// MapTx converts tx to entries form.
+func MapTx(tx *TxData) (*TxEntries, error) {
+	id, hdr, entries, err := mapTx(tx)
+	if err != nil {
+		return nil, errors.Wrap(err, "mapping transaction to entries")
+	}
+	return &TxEntries{ID: id, Header: hdr, Entries: entries}, nil
+}
+
+// DecodeLegacyTx decodes a transaction serialized in the
+// legacy (pre-entries) wire format, as written to blocks by
+// 1.0.x cores, and converts it to entries form.
+// It returns both forms so callers can re-serialize the
+// transaction exactly as it appeared on the blockchain.
+//
+// The legacy wire format is the one TxData reads and writes:
+// entries exist only in memory, for hashing and validation,
+// and have no serialization of their own. So this is TxData's
+// decoder plus MapTx, and the golden tests pin that the format
+// and the mapping do not change.
+//
+// It is an error for b to contain anything after
+// the transaction.
+func DecodeLegacyTx(b []byte) (*TxData, *TxEntries, error) {
+	tx := new(TxData)
+	r := bytes.NewReader(b)
+	err := tx.readFrom(r)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "decoding legacy transaction")
+	}
+	if r.Len() > 0 {
+		return nil, nil, errors.WithDetailf(errTrailingData, "%d extra bytes", r.Len())
+	}
+	ents, err := MapTx(tx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tx, ents, nil
+}
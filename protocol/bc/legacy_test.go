@@ -0,0 +1,112 @@
+package bc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"chain/errors"
+	"chain/testutil"
+)
+
+// These are pinned so that any change to either serialization,
+// or to the mapping between them, fails loudly. Historical
+// blocks written by 1.0.x cores depend on both.
+const (
+	legacySampleTxHex = "07010a" + "b0bbdcc705ffbfdcc705" + "00" + "02" +
+		"01" + "6c" + "01" + "6a" +
+		"dd385f6fe25d91d8c1bd0fa58951ad56b0c5229dcc01f61d9f9e8b9eb92d3292" +
+		"a9b2b6c5394888ab5396f583ae484b8459486b14268e2bef1b637440335eb6c1" +
+		"80a094a58d1d" + "01" + "01" + "0101" +
+		"0000000000000000000000000000000000000000000000000000000000000000" +
+		"05696e707574" + "01" + "00" +
+		"01" + "67" + "01" + "65" +
+		"1100000000000000000000000000000000000000000000000000000000000000" +
+		"a9b2b6c5394888ab5396f583ae484b8459486b14268e2bef1b637440335eb6c1" +
+		"01" + "01" + "01" + "0102" +
+		"0000000000000000000000000000000000000000000000000000000000000000" +
+		"06696e70757432" + "01" + "00" +
+		"02" +
+		"01" + "29" +
+		"a9b2b6c5394888ab5396f583ae484b8459486b14268e2bef1b637440335eb6c1" +
+		"80e0a596bb11" + "01" + "0101" + "00" + "00" +
+		"01" + "29" +
+		"a9b2b6c5394888ab5396f583ae484b8459486b14268e2bef1b637440335eb6c1" +
+		"80c0ee8ed20b" + "01" + "0102" + "00" + "00" +
+		"0c646973747269627574696f6e"
+	legacySampleTxID = "9fad4f5024412d99d17508ef3cc66f81f1e09914a71b2641683acca87081c098"
+)
+
+func TestLegacyTxGolden(t *testing.T) {
+	got := hex.EncodeToString(serialize(t, sampleTx()))
+	if got != legacySampleTxHex {
+		t.Errorf("legacy serialization:\ngot  %s\nwant %s", got, legacySampleTxHex)
+	}
+
+	b, err := hex.DecodeString(legacySampleTxHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, ents, err := DecodeLegacyTx(b)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !testutil.DeepEqual(tx, sampleTx()) {
+		t.Errorf("decoded tx = %+v want %+v", tx, sampleTx())
+	}
+	if !bytes.Equal(serialize(t, tx), b) {
+		t.Error("re-serialized legacy tx differs from original")
+	}
+
+	if ents.ID != mustDecodeHash(legacySampleTxID) {
+		t.Errorf("tx id = %x want %s", ents.ID[:], legacySampleTxID)
+	}
+	if EntryID(ents.Header) != ents.ID {
+		t.Errorf("header id = %x want %x", EntryID(ents.Header), ents.ID[:])
+	}
+	if ents.Entries[ents.ID] != ents.Header {
+		t.Error("entries do not include the header")
+	}
+	for id, e := range ents.Entries {
+		if EntryID(e) != id {
+			t.Errorf("entry %x has id %x", id[:], EntryID(e))
+		}
+	}
+
+	hashes, err := ComputeTxHashes(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ents.Header.body.Results) != len(hashes.Results) {
+		t.Fatalf("got %d results want %d", len(ents.Header.body.Results), len(hashes.Results))
+	}
+	for i, r := range hashes.Results {
+		if ents.Header.body.Results[i] != r.ID {
+			t.Errorf("result %d = %x want %x", i, ents.Header.body.Results[i][:], r.ID[:])
+		}
+	}
+}
+
+func TestDecodeLegacyTxErrors(t *testing.T) {
+	b, err := hex.DecodeString(legacySampleTxHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := [][]byte{
+		nil,
+		b[:len(b)-1],
+		append(append([]byte{}, b...), 0),
+		append([]byte{0x03}, b[1:]...), // unsupported serflags
+	}
+	for i, c := range cases {
+		_, _, err := DecodeLegacyTx(c)
+		if err == nil {
+			t.Errorf("case %d: got no error", i)
+		}
+	}
+
+	_, _, err = DecodeLegacyTx(append(b, 0))
+	if errors.Root(err) != errTrailingData {
+		t.Errorf("trailing byte: got %v want %v", err, errTrailingData)
+	}
+}
@@ -9,6 +9,18 @@ import (
 )
 
 func mapTx(tx *TxData) (headerID Hash, hdr *TxHeader, entryMap map[Hash]Entry, err error) {
+	// Inputs become the mux's sources and outputs its destinations.
+	err = CheckCapacity(len(tx.Inputs))
+	if err != nil {
+		err = errors.Wrap(err, "checking mux sources")
+		return
+	}
+	err = CheckCapacity(len(tx.Outputs))
+	if err != nil {
+		err = errors.Wrap(err, "checking mux destinations")
+		return
+	}
+
 	entryMap = make(map[Hash]Entry)
 
 	addEntry := func(e Entry) (id Hash, err error) {
@@ -1,5 +1,45 @@
 package bc
 
+import (
+	"math"
+
+	"chain/errors"
+)
+
+// MaxMuxPositions is the maximum number of sources or
+// destinations a mux may have. Positions are popped off the
+// VM stack as int64 and must also fit in a uint32 on the wire,
+// so the limit is the smaller of the two signed ranges.
+const MaxMuxPositions = math.MaxInt32
+
+// ErrPosition is returned when a mux source or destination
+// position is out of range, or when a mux has too many of them.
+var ErrPosition = errors.New("invalid source or destination position")
+
+// CheckPosition returns ErrPosition if pos is not a valid
+// index into a list of n mux sources or destinations,
+// or if n exceeds MaxMuxPositions.
+// The error detail names the offending value.
+func CheckPosition(pos uint64, n int) error {
+	err := CheckCapacity(n)
+	if err != nil {
+		return err
+	}
+	if pos >= uint64(n) {
+		return errors.WithDetailf(ErrPosition, "position %d out of range for %d entries", pos, n)
+	}
+	return nil
+}
+
+// CheckCapacity returns ErrPosition if n sources or
+// destinations is more than a mux can address.
+func CheckCapacity(n int) error {
+	if n < 0 || uint64(n) > MaxMuxPositions {
+		return errors.WithDetailf(ErrPosition, "%d entries exceeds the limit of %d", n, MaxMuxPositions)
+	}
+	return nil
+}
+
 // Mux splits and combines value from one or more source entries,
 // making it available to one or more destination entries. It
 // satisfies the Entry interface.
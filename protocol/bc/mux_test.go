@@ -0,0 +1,60 @@
+package bc
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"chain/errors"
+)
+
+func TestCheckPosition(t *testing.T) {
+	cases := []struct {
+		pos  uint64
+		n    int
+		want error
+	}{
+		{0, 0, ErrPosition},
+		{0, 1, nil},
+		{1, 1, ErrPosition},
+		{4, 5, nil},
+		{5, 5, ErrPosition},
+		{MaxMuxPositions - 1, MaxMuxPositions, nil},
+		{MaxMuxPositions, MaxMuxPositions, ErrPosition},
+		{math.MaxUint32, MaxMuxPositions, ErrPosition},
+		{math.MaxUint32 + 1, MaxMuxPositions, ErrPosition},
+		{math.MaxUint64, MaxMuxPositions, ErrPosition},
+		{0, MaxMuxPositions + 1, ErrPosition},
+		{0, -1, ErrPosition},
+	}
+	for _, c := range cases {
+		err := CheckPosition(c.pos, c.n)
+		if errors.Root(err) != c.want {
+			t.Errorf("CheckPosition(%d, %d) = %v want %v", c.pos, c.n, err, c.want)
+		}
+	}
+}
+
+func TestCheckPositionDetail(t *testing.T) {
+	f := func(pos uint64, n uint32) bool {
+		err := CheckPosition(pos, int(n))
+		valid := uint64(n) <= MaxMuxPositions && pos < uint64(n)
+		if valid {
+			return err == nil
+		}
+		if errors.Root(err) != ErrPosition {
+			return false
+		}
+		// The detail must name the offending value.
+		offender := pos
+		if uint64(n) > MaxMuxPositions {
+			offender = uint64(n)
+		}
+		return strings.Contains(errors.Detail(err), strconv.FormatUint(offender, 10))
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
@@ -14,9 +14,9 @@ import (
 	"chain/protocol/vmutil"
 )
 
-// maxBlockTxs limits the number of transactions
+// MaxBlockTxs limits the number of transactions
 // included in each block.
-const maxBlockTxs = 10000
+const MaxBlockTxs = 10000
 
 // saveSnapshotFrequency stores how often to save a state
 // snapshot to the Store.
@@ -25,10 +25,31 @@ const saveSnapshotFrequency = time.Hour
 // ErrBadBlock is returned when a block is invalid.
 var ErrBadBlock = errors.New("invalid block")
 
+// ErrFork is returned when a block at the next height
+// does not build on the current block. Blocks are final
+// once signed, so this means the network's block signers
+// have signed two different blocks at the same height.
+// There is no rollback, so callers keep retrying and
+// an operator must decide which blockchain to follow.
+var ErrFork = errors.New("block does not extend the current blockchain")
+
 // ErrStaleState is returned when the Chain does not have a current
 // blockchain state.
 var ErrStaleState = errors.New("stale blockchain state")
 
+// ErrNetworkSunset is returned when a block would exceed
+// the blockchain's final height.
+var ErrNetworkSunset = errors.New("blockchain has reached its final height")
+
+// CheckFinalHeight returns ErrNetworkSunset if height
+// is past the blockchain's final height.
+func (c *Chain) CheckFinalHeight(height uint64) error {
+	if c.FinalHeight != 0 && height > c.FinalHeight {
+		return errors.WithDetailf(ErrNetworkSunset, "block height %d exceeds final height %d", height, c.FinalHeight)
+	}
+	return nil
+}
+
 // GetBlock returns the block at the given height, if there is one,
 // otherwise it returns an error.
 func (c *Chain) GetBlock(ctx context.Context, height uint64) (*bc.Block, error) {
@@ -42,6 +63,11 @@ func (c *Chain) GetBlock(ctx context.Context, height uint64) (*bc.Block, error)
 // After generating the block, the pending transaction pool will be
 // empty.
 func (c *Chain) GenerateBlock(ctx context.Context, prev *bc.Block, snapshot *state.Snapshot, now time.Time, txs []*bc.Tx) (b *bc.Block, result *state.Snapshot, err error) {
+	err = c.CheckFinalHeight(prev.Height + 1)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	timestampMS := bc.Millis(now)
 	if timestampMS < prev.TimestampMS {
 		return nil, nil, fmt.Errorf("timestamp %d is earlier than prevblock timestamp %d", timestampMS, prev.TimestampMS)
@@ -64,7 +90,7 @@ func (c *Chain) GenerateBlock(ctx context.Context, prev *bc.Block, snapshot *sta
 	}
 
 	for _, tx := range txs {
-		if len(b.Transactions) >= maxBlockTxs {
+		if len(b.Transactions) >= MaxBlockTxs {
 			break
 		}
 
@@ -93,9 +119,20 @@ func (c *Chain) GenerateBlock(ctx context.Context, prev *bc.Block, snapshot *sta
 // ValidateBlock performs validation on an incoming block, in advance
 // of committing the block. ValidateBlock returns the state after
 // the block has been applied.
+// If block is at the next height but has a different previous
+// block, it returns ErrFork.
 func (c *Chain) ValidateBlock(ctx context.Context, prevState *state.Snapshot, prev, block *bc.Block) (*state.Snapshot, error) {
+	if prev != nil && block.Height == prev.Height+1 && block.PreviousBlockHash != prev.Hash() {
+		return nil, errors.WithDetailf(ErrFork, "block %d has previous block %s, want %s", block.Height, block.PreviousBlockHash, prev.Hash())
+	}
+
+	err := c.CheckFinalHeight(block.Height)
+	if err != nil {
+		return nil, errors.Sub(ErrBadBlock, err)
+	}
+
 	newState := state.Copy(prevState)
-	err := validation.ValidateBlockForAccept(ctx, newState, c.InitialBlockHash, prev, block, c.ValidateTxCached)
+	err = validation.ValidateBlockForAccept(ctx, newState, c.InitialBlockHash, prev, block, c.ValidateTxCached)
 	if err != nil {
 		return nil, errors.Sub(ErrBadBlock, err)
 	}
@@ -134,7 +171,7 @@ func (c *Chain) CommitBlock(ctx context.Context, block *bc.Block, snapshot *stat
 
 	// c.setState will update the local blockchain state and height.
 	// When c.store is a txdb.Store, and c has been initialized with a
-	// channel from txdb.ListenBlocks, then the above call to
+	// channel from txdb.Store.ListenBlocks, then the above call to
 	// c.store.FinalizeBlock will have done a postgresql NOTIFY and
 	// that will wake up the goroutine in NewChain, which also calls
 	// setHeight.  But duplicate calls with the same blockheight are
@@ -179,13 +216,17 @@ func (c *Chain) setHeight(h uint64) {
 // block in preparation for signing it. By definition it does not
 // execute the sigscript.
 func (c *Chain) ValidateBlockForSig(ctx context.Context, block *bc.Block) error {
+	err := c.CheckFinalHeight(block.Height)
+	if err != nil {
+		return err
+	}
+
 	var (
 		prev     *bc.Block
 		snapshot = state.Empty()
 	)
 
 	if block.Height > 1 {
-		var err error
 		prev, err = c.store.GetBlock(ctx, block.Height-1)
 		if err != nil {
 			return errors.Wrap(err, "getting previous block")
@@ -200,10 +241,13 @@ func (c *Chain) ValidateBlockForSig(ctx context.Context, block *bc.Block) error
 	// TODO(kr): cache the applied snapshot, and maybe
 	// we can skip re-applying it later
 	snapshot = state.Copy(snapshot)
-	err := validation.ValidateBlock(ctx, snapshot, c.InitialBlockHash, prev, block, validation.CheckTxWellFormed)
+	err = validation.ValidateBlock(ctx, snapshot, c.InitialBlockHash, prev, block, validation.CheckTxWellFormed)
 	return errors.Wrap(err, "validation")
 }
 
+// NewInitialBlock creates the initial block of a new blockchain.
+// The block commits to the current entry hash function
+// (see bc.SetHashFunc).
 func NewInitialBlock(pubkeys []ed25519.PublicKey, nSigs int, timestamp time.Time) (*bc.Block, error) {
 	script, err := vmutil.BlockMultiSigProgram(pubkeys, nSigs)
 	if err != nil {
@@ -224,6 +268,7 @@ func NewInitialBlock(pubkeys []ed25519.PublicKey, nSigs int, timestamp time.Time
 				TransactionsMerkleRoot: root,
 				ConsensusProgram:       script,
 			},
+			CommitmentSuffix: bc.HashFuncCommitment(bc.CurrentHashFunc()),
 		},
 	}
 	return b, nil
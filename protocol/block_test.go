@@ -2,10 +2,12 @@ package protocol
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/hex"
 	"testing"
 	"time"
 
+	"chain/errors"
 	"chain/protocol/bc"
 	"chain/protocol/memstore"
 	"chain/protocol/state"
@@ -211,6 +213,120 @@ func TestValidateBlockForSig(t *testing.T) {
 	}
 }
 
+func TestFinalHeight(t *testing.T) {
+	ctx := context.Background()
+	c, b1 := newTestChain(t, time.Now())
+	c.FinalHeight = 2
+	makeEmptyBlock(t, c) // height=2
+
+	b2, err := c.GetBlock(ctx, 2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	_, _, err = c.GenerateBlock(ctx, b2, state.Empty(), time.Now(), nil)
+	if errors.Root(err) != ErrNetworkSunset {
+		t.Errorf("GenerateBlock past final height: got err = %v want %v", err, ErrNetworkSunset)
+	}
+
+	c.FinalHeight = 0
+	b3, s3, err := c.GenerateBlock(ctx, b2, state.Empty(), time.Now(), nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	c.FinalHeight = 2
+
+	_, err = c.ValidateBlock(ctx, s3, b2, b3)
+	if errors.Root(err) != ErrBadBlock {
+		t.Errorf("ValidateBlock past final height: got err = %v want %v", err, ErrBadBlock)
+	}
+	err = c.ValidateBlockForSig(ctx, b3)
+	if errors.Root(err) != ErrNetworkSunset {
+		t.Errorf("ValidateBlockForSig past final height: got err = %v want %v", err, ErrNetworkSunset)
+	}
+
+	// Blocks up to and including the final height are still valid.
+	err = c.ValidateBlockForSig(ctx, b1)
+	if err != nil {
+		t.Errorf("ValidateBlockForSig(b1): unexpected error %v", err)
+	}
+}
+
+func TestValidateBlockFork(t *testing.T) {
+	ctx := context.Background()
+	c, b1 := newTestChain(t, time.Now())
+	makeEmptyBlock(t, c) // height=2
+
+	b2, err := c.GetBlock(ctx, 2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// A competing block at height 2, built on b1.
+	fork, s, err := c.GenerateBlock(ctx, b1, state.Empty(), time.Now().Add(time.Second), nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	b3, _, err := c.GenerateBlock(ctx, fork, s, time.Now().Add(2*time.Second), nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	_, err = c.ValidateBlock(ctx, state.Empty(), b2, b3)
+	if errors.Root(err) != ErrFork {
+		t.Errorf("ValidateBlock(fork) = %v want %v", err, ErrFork)
+	}
+}
+
+var testSHA256 = &bc.HashFunc{ID: 98, Name: "sha2-256", New: sha256.New}
+
+func init() {
+	bc.RegisterHashFunc(testSHA256)
+}
+
+func TestInitHashFunc(t *testing.T) {
+	ctx := context.Background()
+	defer bc.SetHashFunc(bc.SHA3)
+
+	// With no initial block, the default ID decides.
+	empty := memstore.New()
+	err := InitHashFunc(ctx, empty, testSHA256.ID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if got := bc.CurrentHashFunc(); got != testSHA256 {
+		t.Fatalf("CurrentHashFunc() = %s want %s", got.Name, testSHA256.Name)
+	}
+
+	b1, err := NewInitialBlock(testutil.TestPubs, 1, time.Now())
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	store := memstore.New()
+	err = store.SaveBlock(ctx, b1)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// Once there is an initial block, its commitment decides.
+	bc.SetHashFunc(bc.SHA3)
+	err = InitHashFunc(ctx, store, bc.SHA3.ID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if got := bc.CurrentHashFunc(); got != testSHA256 {
+		t.Fatalf("CurrentHashFunc() = %s want %s", got.Name, testSHA256.Name)
+	}
+	_, err = NewChain(ctx, b1.Hash(), store, nil)
+	if err != nil {
+		t.Errorf("NewChain after InitHashFunc: %v", err)
+	}
+
+	err = InitHashFunc(ctx, empty, 12345)
+	if errors.Root(err) != bc.ErrUnknownHashFunc {
+		t.Errorf("InitHashFunc(12345) error = %v want %v", err, bc.ErrUnknownHashFunc)
+	}
+}
+
 // newTestChain returns a new Chain using memstore for storage,
 // along with an initial block b1 (with a 0/0 multisig program).
 // It commits b1 before returning.
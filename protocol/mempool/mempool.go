@@ -0,0 +1,487 @@
+// Package mempool holds validated transactions
+// that are waiting to be included in a block.
+//
+// A Pool tracks spend dependencies between the transactions
+// it holds, so that it can hand a generator the transactions
+// in an order in which they can be applied, and so that
+// evicting a transaction also evicts the transactions
+// that depend on it.
+//
+// Making a block does not empty the pool. A transaction
+// stays until it is confirmed, until it conflicts with a
+// confirmed transaction or is rejected, until its maximum
+// time passes, or until it has been in the pool for
+// Limits.MaxAge (24 hours by default). So a transaction
+// left out of a full block is offered again for the next
+// one, for up to a day, where formerly it would have been
+// dropped.
+package mempool
+
+import (
+	"context"
+	"expvar"
+	"sort"
+	"sync"
+	"time"
+
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// Limits bounds the contents of a Pool.
+// A zero field means no limit.
+type Limits struct {
+	// MaxTxs is the maximum number of transactions in the pool.
+	// When it is exceeded, the oldest transactions
+	// (and their dependents) are evicted.
+	MaxTxs int
+
+	// MaxAge is how long a transaction may stay in the pool
+	// before Evict removes it, however many blocks are
+	// made in the meantime.
+	MaxAge time.Duration
+}
+
+// Eviction reasons, used as keys in the
+// "mempool.evictions" expvar map.
+const (
+	// EvictExpired is for transactions whose maximum time
+	// passed before they were included in a block,
+	// and for the pool transactions that depend on them.
+	EvictExpired = "expired"
+
+	EvictMaxAge    = "max_age"
+	EvictMaxTxs    = "max_txs"
+	EvictRejected  = "rejected"
+	EvictConflicts = "conflicts"
+)
+
+// evictions counts the transactions evicted
+// from all pools, by reason.
+var evictions = expvar.NewMap("mempool.evictions")
+
+// DefaultLimits are the limits used by New
+// if none are given.
+var DefaultLimits = Limits{
+	MaxTxs: 100000,
+	MaxAge: 24 * time.Hour,
+}
+
+// Item is a transaction in the pool,
+// along with the time it was added.
+type Item struct {
+	Tx    *bc.Tx
+	Added time.Time
+
+	// Submitter identifies who submitted the transaction,
+	// such as by the ID of an access token. It may be empty.
+	Submitter string
+}
+
+// Store provides persistent storage for the contents of a Pool,
+// so that they survive a crash.
+type Store interface {
+	SaveTx(context.Context, Item) error
+	DeleteTxs(context.Context, []bc.Hash) error
+	LoadTxs(context.Context) ([]Item, error) // in the order added
+}
+
+type entry struct {
+	Item
+	seq      uint64
+	parents  map[bc.Hash]bool // pool txs whose outputs this one spends
+	children map[bc.Hash]bool // pool txs spending this one's outputs
+}
+
+// Pool is a set of unconfirmed transactions.
+// It is safe for concurrent use.
+type Pool struct {
+	store Store // may be nil
+
+	mu       sync.Mutex
+	limits   Limits
+	onExpire func(context.Context, []*bc.Tx)
+	seq      uint64
+	txs      map[bc.Hash]*entry
+	outputs  map[bc.Hash]bc.Hash   // output ID -> pool tx creating it
+	spends   map[bc.Hash][]bc.Hash // output ID -> pool txs spending it
+}
+
+// New returns an empty pool that persists its contents
+// to store. If store is nil, the pool is held only in memory.
+func New(store Store, limits Limits) *Pool {
+	return &Pool{
+		store:   store,
+		limits:  limits,
+		txs:     make(map[bc.Hash]*entry),
+		outputs: make(map[bc.Hash]bc.Hash),
+		spends:  make(map[bc.Hash][]bc.Hash),
+	}
+}
+
+// SetLimits changes the pool's limits.
+// They take effect on the next call to Add or Evict.
+func (p *Pool) SetLimits(l Limits) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.limits = l
+}
+
+// OnExpire sets f to be called with the transactions
+// evicted by Evict because they can no longer be included
+// in a block before their maximum time. It is called
+// without the pool's lock held.
+func (p *Pool) OnExpire(f func(context.Context, []*bc.Tx)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onExpire = f
+}
+
+// Recover loads the transactions saved in the pool's store.
+func (p *Pool) Recover(ctx context.Context) error {
+	if p.store == nil {
+		return nil
+	}
+	items, err := p.store.LoadTxs(ctx)
+	if err != nil {
+		return errors.Wrap(err, "loading pool transactions")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, item := range items {
+		if p.txs[item.Tx.ID] == nil {
+			p.insert(item)
+		}
+	}
+	return p.enforceMaxTxs(ctx)
+}
+
+// Add adds tx, which must already be validated, to the pool.
+// Adding a transaction already in the pool has no effect.
+// If the pool is then over its size limit, the oldest
+// transactions are evicted.
+func (p *Pool) Add(ctx context.Context, tx *bc.Tx, now time.Time) error {
+	return p.AddItem(ctx, Item{Tx: tx, Added: now})
+}
+
+// AddItem is like Add, but takes the time the transaction
+// was added, and who submitted it, from item.
+func (p *Pool) AddItem(ctx context.Context, item Item) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.txs[item.Tx.ID] != nil {
+		return nil
+	}
+	if p.store != nil {
+		err := p.store.SaveTx(ctx, item)
+		if err != nil {
+			return errors.Wrap(err, "saving pool transaction")
+		}
+	}
+	p.insert(item)
+	return p.enforceMaxTxs(ctx)
+}
+
+// Contains reports whether the transaction with the given ID
+// is in the pool.
+func (p *Pool) Contains(id bc.Hash) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.txs[id] != nil
+}
+
+// Len returns the number of transactions in the pool.
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.txs)
+}
+
+// Snapshot returns the transactions in the pool
+// in topological order: every transaction comes after
+// the pool transactions whose outputs it spends.
+// Otherwise, transactions are in the order they were added.
+func (p *Pool) Snapshot() []*bc.Tx {
+	items := p.Ordered(nil)
+	txs := make([]*bc.Tx, 0, len(items))
+	for _, item := range items {
+		txs = append(txs, item.Tx)
+	}
+	return txs
+}
+
+// Ordered returns the items in the pool in topological
+// order: every transaction comes after the pool transactions
+// whose outputs it spends. Otherwise, items are ordered by
+// less, or if it is nil or leaves them equal, in the order
+// they were added.
+func (p *Pool) Ordered(less func(a, b *Item) bool) []Item {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sortEntries := func(a []*entry) {
+		sort.Sort(bySeq(a))
+		if less != nil {
+			sort.SliceStable(a, func(i, j int) bool { return less(&a[i].Item, &a[j].Item) })
+		}
+	}
+
+	entries := make([]*entry, 0, len(p.txs))
+	for _, e := range p.txs {
+		entries = append(entries, e)
+	}
+	sortEntries(entries)
+
+	var (
+		items   = make([]Item, 0, len(entries))
+		visited = make(map[bc.Hash]bool, len(entries))
+		visit   func(e *entry)
+	)
+	visit = func(e *entry) {
+		if visited[e.Tx.ID] {
+			return
+		}
+		visited[e.Tx.ID] = true
+		var parents []*entry
+		for id := range e.parents {
+			parents = append(parents, p.txs[id])
+		}
+		sortEntries(parents)
+		for _, parent := range parents {
+			visit(parent)
+		}
+		items = append(items, e.Item)
+	}
+	for _, e := range entries {
+		visit(e)
+	}
+	return items
+}
+
+// Confirm removes the transactions in txs, which have been
+// included in a block, from the pool. It also evicts any pool
+// transaction that spends an output also spent by one of txs
+// (and that transaction's dependents), since it can no longer
+// be confirmed.
+func (p *Pool) Confirm(ctx context.Context, txs []*bc.Tx) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var removed, conflicts []*bc.Tx
+	confirmed := make(map[bc.Hash]bool, len(txs))
+	for _, tx := range txs {
+		confirmed[tx.ID] = true
+		if e := p.txs[tx.ID]; e != nil {
+			p.remove(tx.ID)
+			removed = append(removed, e.Tx)
+		}
+	}
+	for _, tx := range txs {
+		for _, spent := range tx.SpentOutputIDs {
+			if (spent == bc.Hash{}) {
+				continue // not a spend
+			}
+			for _, id := range p.spends[spent] {
+				if !confirmed[id] {
+					conflicts = append(conflicts, p.removeTree(id)...)
+				}
+			}
+		}
+	}
+	evictions.Add(EvictConflicts, int64(len(conflicts)))
+	return p.deleteFromStore(ctx, append(removed, conflicts...))
+}
+
+// Remove removes the transactions with the given IDs,
+// along with their dependents. It is used to drop transactions
+// that a block generator found to be no longer valid.
+func (p *Pool) Remove(ctx context.Context, ids []bc.Hash) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var removed []*bc.Tx
+	for _, id := range ids {
+		removed = append(removed, p.removeTree(id)...)
+	}
+	evictions.Add(EvictRejected, int64(len(removed)))
+	return p.deleteFromStore(ctx, removed)
+}
+
+// Evict removes transactions whose maximum time is before now,
+// so that they can no longer be included in a block, and
+// transactions that have been in the pool longer than its
+// maximum age, along with their dependents.
+// Expired transactions are passed to the function set by OnExpire.
+func (p *Pool) Evict(ctx context.Context, now time.Time) error {
+	p.mu.Lock()
+	expired, err := p.evict(ctx, now)
+	onExpire := p.onExpire
+	p.mu.Unlock()
+
+	if onExpire != nil && len(expired) > 0 {
+		onExpire(ctx, expired)
+	}
+	return err
+}
+
+// evict implements Evict, returning the expired transactions.
+// Caller must hold p.mu.
+func (p *Pool) evict(ctx context.Context, now time.Time) (expired []*bc.Tx, err error) {
+	nowMS := bc.Millis(now)
+	for _, e := range p.sorted() {
+		if p.txs[e.Tx.ID] != nil && e.Tx.MaxTime > 0 && e.Tx.MaxTime < nowMS {
+			expired = append(expired, p.removeTree(e.Tx.ID)...)
+		}
+	}
+	evictions.Add(EvictExpired, int64(len(expired)))
+
+	var old []*bc.Tx
+	if p.limits.MaxAge > 0 {
+		cutoff := now.Add(-p.limits.MaxAge)
+		for _, e := range p.sorted() {
+			if p.txs[e.Tx.ID] != nil && e.Added.Before(cutoff) {
+				old = append(old, p.removeTree(e.Tx.ID)...)
+			}
+		}
+		evictions.Add(EvictMaxAge, int64(len(old)))
+	}
+	return expired, p.deleteFromStore(ctx, append(old, expired...))
+}
+
+// enforceMaxTxs evicts the oldest transactions,
+// and their dependents, until the pool is within
+// its size limit.
+// Caller must hold p.mu.
+func (p *Pool) enforceMaxTxs(ctx context.Context) error {
+	if p.limits.MaxTxs == 0 || len(p.txs) <= p.limits.MaxTxs {
+		return nil
+	}
+	var removed []*bc.Tx
+	for _, e := range p.sorted() {
+		if len(p.txs) <= p.limits.MaxTxs {
+			break
+		}
+		if p.txs[e.Tx.ID] != nil {
+			removed = append(removed, p.removeTree(e.Tx.ID)...)
+		}
+	}
+	evictions.Add(EvictMaxTxs, int64(len(removed)))
+	return p.deleteFromStore(ctx, removed)
+}
+
+// insert adds item to the pool's indexes.
+// Caller must hold p.mu.
+func (p *Pool) insert(item Item) {
+	p.seq++
+	e := &entry{
+		Item:     item,
+		seq:      p.seq,
+		parents:  make(map[bc.Hash]bool),
+		children: make(map[bc.Hash]bool),
+	}
+	id := item.Tx.ID
+	for _, spent := range item.Tx.SpentOutputIDs {
+		if (spent == bc.Hash{}) {
+			continue // not a spend
+		}
+		p.spends[spent] = append(p.spends[spent], id)
+		if parentID, ok := p.outputs[spent]; ok {
+			e.parents[parentID] = true
+			p.txs[parentID].children[id] = true
+		}
+	}
+	for _, res := range item.Tx.Results {
+		p.outputs[res.ID] = id
+		for _, childID := range p.spends[res.ID] {
+			if childID != id {
+				e.children[childID] = true
+				p.txs[childID].parents[id] = true
+			}
+		}
+	}
+	p.txs[id] = e
+}
+
+// remove removes the transaction with the given ID
+// from the pool's indexes, leaving its dependents.
+// Caller must hold p.mu.
+func (p *Pool) remove(id bc.Hash) {
+	e := p.txs[id]
+	for parentID := range e.parents {
+		delete(p.txs[parentID].children, id)
+	}
+	for childID := range e.children {
+		delete(p.txs[childID].parents, id)
+	}
+	for _, spent := range e.Tx.SpentOutputIDs {
+		spenders := p.spends[spent]
+		for i, s := range spenders {
+			if s == id {
+				spenders = append(spenders[:i:i], spenders[i+1:]...)
+				break
+			}
+		}
+		if len(spenders) == 0 {
+			delete(p.spends, spent)
+		} else {
+			p.spends[spent] = spenders
+		}
+	}
+	for _, res := range e.Tx.Results {
+		if p.outputs[res.ID] == id {
+			delete(p.outputs, res.ID)
+		}
+	}
+	delete(p.txs, id)
+}
+
+// removeTree removes the transaction with the given ID
+// and all its dependents, and returns them.
+// Caller must hold p.mu.
+func (p *Pool) removeTree(id bc.Hash) []*bc.Tx {
+	e := p.txs[id]
+	if e == nil {
+		return nil
+	}
+	removed := []*bc.Tx{e.Tx}
+	var children []bc.Hash
+	for childID := range e.children {
+		children = append(children, childID)
+	}
+	p.remove(id)
+	for _, childID := range children {
+		removed = append(removed, p.removeTree(childID)...)
+	}
+	return removed
+}
+
+// sorted returns the pool's entries in the order they were added.
+// Caller must hold p.mu.
+func (p *Pool) sorted() []*entry {
+	entries := make([]*entry, 0, len(p.txs))
+	for _, e := range p.txs {
+		entries = append(entries, e)
+	}
+	sort.Sort(bySeq(entries))
+	return entries
+}
+
+func (p *Pool) deleteFromStore(ctx context.Context, txs []*bc.Tx) error {
+	if p.store == nil || len(txs) == 0 {
+		return nil
+	}
+	ids := make([]bc.Hash, 0, len(txs))
+	for _, tx := range txs {
+		ids = append(ids, tx.ID)
+	}
+	err := p.store.DeleteTxs(ctx, ids)
+	return errors.Wrap(err, "deleting pool transactions")
+}
+
+type bySeq []*entry
+
+func (a bySeq) Len() int           { return len(a) }
+func (a bySeq) Less(i, j int) bool { return a[i].seq < a[j].seq }
+func (a bySeq) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
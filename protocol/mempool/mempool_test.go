@@ -0,0 +1,308 @@
+package mempool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"chain/protocol/bc"
+)
+
+// mockTx returns a tx with the given ID
+// that spends the given outputs and creates the given outputs.
+func mockTx(id byte, spends []byte, outputs ...byte) *bc.Tx {
+	tx := &bc.Tx{}
+	tx.ID = bc.Hash{id}
+	for _, s := range spends {
+		tx.SpentOutputIDs = append(tx.SpentOutputIDs, bc.Hash{s})
+	}
+	for _, o := range outputs {
+		tx.Results = append(tx.Results, bc.ResultInfo{ID: bc.Hash{o}})
+	}
+	return tx
+}
+
+func ids(txs []*bc.Tx) []byte {
+	var b []byte
+	for _, tx := range txs {
+		b = append(b, tx.ID[0])
+	}
+	return b
+}
+
+type memStore map[bc.Hash]Item
+
+func (s memStore) SaveTx(ctx context.Context, item Item) error {
+	s[item.Tx.ID] = item
+	return nil
+}
+
+func (s memStore) DeleteTxs(ctx context.Context, ids []bc.Hash) error {
+	for _, id := range ids {
+		delete(s, id)
+	}
+	return nil
+}
+
+func (s memStore) LoadTxs(ctx context.Context) ([]Item, error) {
+	var items []Item
+	for _, item := range s {
+		items = append(items, item)
+	}
+	// Deliberately unordered; the pool must still link dependencies.
+	return items, nil
+}
+
+var t0 = time.Unix(1e9, 0)
+
+func TestSnapshotOrder(t *testing.T) {
+	ctx := context.Background()
+	p := New(nil, Limits{})
+
+	// Tx 3 spends an output of tx 2 but arrives first.
+	// Tx 2 spends an output of tx 1.
+	txs := []*bc.Tx{
+		mockTx(3, []byte{20}, 30),
+		mockTx(4, nil, 40),
+		mockTx(2, []byte{10}, 20),
+		mockTx(1, nil, 10),
+	}
+	for i, tx := range txs {
+		err := p.Add(ctx, tx, t0.Add(time.Duration(i)*time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := p.Add(ctx, txs[0], t0) // duplicate
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := ids(p.Snapshot())
+	want := []byte{1, 2, 3, 4}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Snapshot() = %v want %v", got, want)
+	}
+}
+
+func TestOrdered(t *testing.T) {
+	ctx := context.Background()
+	p := New(nil, Limits{})
+
+	// Tx 2, from the priority submitter, spends an output
+	// of tx 1, which must still come before it.
+	items := []Item{
+		{Tx: mockTx(1, nil, 10), Submitter: "a"},
+		{Tx: mockTx(3, nil, 30), Submitter: "a"},
+		{Tx: mockTx(2, []byte{10}, 20), Submitter: "b"},
+		{Tx: mockTx(4, nil, 40), Submitter: "b"},
+	}
+	for i, item := range items {
+		item.Added = t0.Add(time.Duration(i) * time.Second)
+		err := p.AddItem(ctx, item)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	byB := func(a, b *Item) bool { return a.Submitter == "b" && b.Submitter != "b" }
+	var got []byte
+	for _, item := range p.Ordered(byB) {
+		got = append(got, item.Tx.ID[0])
+	}
+	want := []byte{1, 2, 4, 3}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Ordered() = %v want %v", got, want)
+	}
+}
+
+func TestMaxTxs(t *testing.T) {
+	ctx := context.Background()
+	store := memStore{}
+	p := New(store, Limits{MaxTxs: 3})
+
+	txs := []*bc.Tx{
+		mockTx(1, nil, 10),
+		mockTx(2, nil, 20),
+		mockTx(3, []byte{10}, 30), // child of 1
+		mockTx(4, nil, 40),
+	}
+	for _, tx := range txs {
+		err := p.Add(ctx, tx, t0)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Evicting tx 1, the oldest, also evicts its child, tx 3.
+	got := ids(p.Snapshot())
+	want := []byte{2, 4}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Snapshot() = %v want %v", got, want)
+	}
+	if len(store) != 2 {
+		t.Errorf("len(store) = %d want 2", len(store))
+	}
+}
+
+func TestEvict(t *testing.T) {
+	ctx := context.Background()
+	p := New(nil, Limits{MaxAge: time.Hour})
+
+	p.Add(ctx, mockTx(1, nil, 10), t0)
+	p.Add(ctx, mockTx(2, nil, 20), t0.Add(time.Hour))
+	p.Add(ctx, mockTx(3, []byte{10}, 30), t0.Add(time.Hour)) // child of 1
+
+	err := p.Evict(ctx, t0.Add(90*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := ids(p.Snapshot())
+	want := []byte{2}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Snapshot() = %v want %v", got, want)
+	}
+}
+
+func TestMaxAgeAcrossBlocks(t *testing.T) {
+	ctx := context.Background()
+	p := New(nil, DefaultLimits)
+
+	p.Add(ctx, mockTx(1, nil, 10), t0)
+	p.Add(ctx, mockTx(2, nil, 20), t0)
+
+	// A block confirms tx 2 but not tx 1.
+	now := t0.Add(time.Second)
+	err := p.Evict(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Confirm(ctx, []*bc.Tx{mockTx(2, nil, 20)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ids(p.Snapshot()), []byte{1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("after block, Snapshot() = %v want %v", got, want)
+	}
+
+	err = p.Evict(ctx, t0.Add(DefaultLimits.MaxAge))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Contains(bc.Hash{1}) {
+		t.Fatal("tx 1 evicted before MaxAge")
+	}
+
+	err = p.Evict(ctx, t0.Add(DefaultLimits.MaxAge+time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Contains(bc.Hash{1}) {
+		t.Error("tx 1 still in pool after MaxAge")
+	}
+}
+
+func TestEvictExpired(t *testing.T) {
+	ctx := context.Background()
+	p := New(nil, Limits{})
+
+	expiring := mockTx(1, nil, 10)
+	expiring.MaxTime = bc.Millis(t0.Add(time.Minute))
+	p.Add(ctx, expiring, t0)
+	p.Add(ctx, mockTx(2, []byte{10}, 20), t0) // child of 1
+	p.Add(ctx, mockTx(3, nil, 30), t0)
+
+	var expired []*bc.Tx
+	p.OnExpire(func(ctx context.Context, txs []*bc.Tx) {
+		expired = append(expired, txs...)
+	})
+
+	err := p.Evict(ctx, t0.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 0 {
+		t.Fatalf("expired %v before max time", ids(expired))
+	}
+
+	err = p.Evict(ctx, t0.Add(2*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ids(expired), []byte{1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expired = %v want %v", got, want)
+	}
+	if got, want := ids(p.Snapshot()), []byte{3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Snapshot() = %v want %v", got, want)
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	ctx := context.Background()
+	p := New(nil, Limits{})
+
+	p.Add(ctx, mockTx(1, []byte{100}, 10), t0)
+	p.Add(ctx, mockTx(2, []byte{10}, 20), t0)  // child of 1
+	p.Add(ctx, mockTx(3, []byte{101}, 30), t0) // double-spends with confirmed tx 5
+	p.Add(ctx, mockTx(4, []byte{30}, 40), t0)  // child of 3
+	p.Add(ctx, mockTx(6, nil, 60), t0)
+
+	block := []*bc.Tx{
+		mockTx(1, []byte{100}, 10),
+		mockTx(5, []byte{101}, 50),
+	}
+	err := p.Confirm(ctx, block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := ids(p.Snapshot())
+	want := []byte{2, 6}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Snapshot() = %v want %v", got, want)
+	}
+	if p.Contains(bc.Hash{1}) {
+		t.Error("confirmed tx 1 still in pool")
+	}
+}
+
+func TestRemove(t *testing.T) {
+	ctx := context.Background()
+	p := New(nil, Limits{})
+
+	p.Add(ctx, mockTx(1, nil, 10), t0)
+	p.Add(ctx, mockTx(2, []byte{10}, 20), t0)
+	p.Add(ctx, mockTx(3, nil, 30), t0)
+
+	err := p.Remove(ctx, []bc.Hash{{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Len() != 1 || !p.Contains(bc.Hash{3}) {
+		t.Errorf("Snapshot() = %v want [3]", ids(p.Snapshot()))
+	}
+}
+
+func TestRecover(t *testing.T) {
+	ctx := context.Background()
+	store := memStore{}
+	p := New(store, Limits{})
+	p.Add(ctx, mockTx(1, nil, 10), t0)
+	p.Add(ctx, mockTx(2, []byte{10}, 20), t0)
+	p.Add(ctx, mockTx(3, []byte{20}, 30), t0)
+
+	p2 := New(store, Limits{})
+	err := p2.Recover(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p2.Remove(ctx, []bc.Hash{{2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := ids(p2.Snapshot())
+	want := []byte{1}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Snapshot() = %v want %v", got, want)
+	}
+}
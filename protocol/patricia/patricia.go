@@ -206,6 +206,75 @@ func delete(n *node, key []uint8) *node {
 	return newNode
 }
 
+// Diff returns the items in b that are not in a (inserted)
+// and the items in a that are not in b (deleted).
+//
+// Subtrees that a and b share, or that have the same hash,
+// are skipped, so when b was derived from a by a small number
+// of insertions and deletions, Diff does work proportional
+// to the number of changes rather than to the size of the trees.
+func Diff(a, b *Tree) (inserted, deleted [][]byte) {
+	diff(a.root, b.root, &inserted, &deleted)
+	return inserted, deleted
+}
+
+func diff(a, b *node, inserted, deleted *[][]byte) {
+	if a == b {
+		return
+	}
+	if a == nil {
+		*inserted = append(*inserted, items(b)...)
+		return
+	}
+	if b == nil {
+		*deleted = append(*deleted, items(a)...)
+		return
+	}
+	if a.Hash() == b.Hash() {
+		return
+	}
+	if !a.isLeaf && !b.isLeaf && bytes.Equal(a.key, b.key) {
+		diff(a.children[0], b.children[0], inserted, deleted)
+		diff(a.children[1], b.children[1], inserted, deleted)
+		return
+	}
+
+	// The subtrees are shaped differently.
+	// Compare their items in sorted order.
+	aItems, bItems := items(a), items(b)
+	for len(aItems) > 0 || len(bItems) > 0 {
+		var c int
+		switch {
+		case len(aItems) == 0:
+			c = 1
+		case len(bItems) == 0:
+			c = -1
+		default:
+			c = bytes.Compare(aItems[0], bItems[0])
+		}
+		switch {
+		case c < 0:
+			*deleted = append(*deleted, aItems[0])
+			aItems = aItems[1:]
+		case c > 0:
+			*inserted = append(*inserted, bItems[0])
+			bItems = bItems[1:]
+		default:
+			aItems, bItems = aItems[1:], bItems[1:]
+		}
+	}
+}
+
+// items returns the items in the subtree rooted at n,
+// in sorted order.
+func items(n *node) (result [][]byte) {
+	walk(n, func(item []byte) error {
+		result = append(result, item)
+		return nil
+	})
+	return result
+}
+
 // RootHash returns the Merkle root of the tree.
 func (t *Tree) RootHash() bc.Hash {
 	root := t.root
@@ -134,6 +134,82 @@ func TestRootHashInsertQuickCheck(t *testing.T) {
 	}
 }
 
+func TestDiffQuickCheck(t *testing.T) {
+	f := func(base, ins [][4]byte, delIdx []uint8) bool {
+		a := new(Tree)
+		for _, item := range base {
+			a.Insert(item[:])
+		}
+
+		b := new(Tree)
+		*b = *a
+		want := make(map[string]bool)
+		for _, item := range base {
+			want[string(item[:])] = true
+		}
+		for _, i := range delIdx {
+			if len(base) == 0 {
+				break
+			}
+			item := base[int(i)%len(base)]
+			b.Delete(item[:])
+			want[string(item[:])] = false // can't use delete; it's shadowed in this package
+		}
+		for _, item := range ins {
+			b.Insert(item[:])
+			want[string(item[:])] = true
+		}
+
+		// Applying the diff to a must yield exactly b's items.
+		inserted, deleted := Diff(a, b)
+		c := new(Tree)
+		*c = *a
+		for _, item := range deleted {
+			c.Delete(item)
+		}
+		for _, item := range inserted {
+			err := c.Insert(item)
+			if err != nil {
+				return false
+			}
+		}
+		wantItems := make(map[string]bool)
+		for k, v := range want {
+			if v {
+				wantItems[k] = true
+			}
+		}
+		got := make(map[string]bool)
+		Walk(c, func(item []byte) error {
+			got[string(item)] = true
+			return nil
+		})
+		return testutil.DeepEqual(got, wantItems) && c.RootHash() == b.RootHash()
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDiffShared(t *testing.T) {
+	a := new(Tree)
+	for i := 0; i < 1000; i++ {
+		a.Insert([]byte(fmt.Sprintf("%08d", i)))
+	}
+	b := new(Tree)
+	*b = *a
+	b.Delete([]byte("00000500"))
+	b.Insert([]byte("00001000"))
+
+	inserted, deleted := Diff(a, b)
+	if !testutil.DeepEqual(inserted, [][]byte{[]byte("00001000")}) {
+		t.Errorf("inserted = %q want [00001000]", inserted)
+	}
+	if !testutil.DeepEqual(deleted, [][]byte{[]byte("00000500")}) {
+		t.Errorf("deleted = %q want [00000500]", deleted)
+	}
+}
+
 func TestLookup(t *testing.T) {
 	tr := &Tree{
 		root: &node{key: bools("11111111"), hash: hashPtr(hashForLeaf(bits("11111111"))), isLeaf: true},
@@ -51,6 +51,7 @@ import (
 	"chain/log"
 	"chain/protocol/bc"
 	"chain/protocol/state"
+	"chain/protocol/validation"
 )
 
 // maxCachedValidatedTxs is the max number of validated txs to cache.
@@ -87,6 +88,12 @@ type Chain struct {
 	InitialBlockHash  bc.Hash
 	MaxIssuanceWindow time.Duration // only used by generators
 
+	// FinalHeight, if nonzero, is the last block height
+	// this blockchain will produce or accept.
+	// It lets a network shut down in an orderly way
+	// before migrating to a successor blockchain.
+	FinalHeight uint64
+
 	state struct {
 		cond     sync.Cond // protects height, block, snapshot
 		height   uint64
@@ -106,6 +113,39 @@ type pendingSnapshot struct {
 	snapshot *state.Snapshot
 }
 
+// InitHashFunc sets the entry hash function (see bc.SetHashFunc)
+// for the blockchain in store. It must be called before any
+// entry IDs are computed.
+//
+// If store has an initial block, InitHashFunc uses the hash
+// function that block commits to. Otherwise it uses the one
+// with ID defaultID: a new generator commits to it in its
+// initial block, and a new participant expects the generator's
+// initial block to commit to it.
+func InitHashFunc(ctx context.Context, store Store, defaultID uint64) error {
+	id := defaultID
+	height, err := store.Height(ctx)
+	if err != nil {
+		return errors.Wrap(err, "looking up blockchain height")
+	}
+	if height > 0 {
+		b, err := store.GetBlock(ctx, 1)
+		if err != nil {
+			return errors.Wrap(err, "loading initial block")
+		}
+		id, err = b.HashFuncID()
+		if err != nil {
+			return errors.Sub(validation.ErrBadHashFunc, err)
+		}
+	}
+	hf, err := bc.LookupHashFunc(id)
+	if err != nil {
+		return err
+	}
+	bc.SetHashFunc(hf)
+	return nil
+}
+
 // NewChain returns a new Chain using store as the underlying storage.
 func NewChain(ctx context.Context, initialBlockHash bc.Hash, store Store, heights <-chan uint64) (*Chain, error) {
 	c := &Chain{
@@ -124,6 +164,19 @@ func NewChain(ctx context.Context, initialBlockHash bc.Hash, store Store, height
 		return nil, errors.Wrap(err, "looking up blockchain height")
 	}
 
+	// The entry hash function is fixed by the initial block;
+	// refuse to operate on a blockchain that committed to a different one.
+	if c.state.height > 0 {
+		b, err := store.GetBlock(ctx, 1)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading initial block")
+		}
+		err = validation.CheckHashFunc(&b.BlockHeader)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Note that c.height.n may still be zero here.
 	if heights != nil {
 		go func() {
@@ -0,0 +1,425 @@
+package prottest
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"chain/crypto/ed25519"
+	"chain/errors"
+	"chain/protocol"
+	"chain/protocol/bc"
+	"chain/protocol/memstore"
+	"chain/protocol/state"
+	"chain/testutil"
+)
+
+// SimEpoch is the virtual time at which every Sim starts.
+// It is fixed so that initial blocks, and therefore
+// blockchain IDs, are the same from run to run.
+var SimEpoch = time.Unix(1483228800, 0)
+
+// SimGenerator is the ID of the generator node in a Sim.
+// Signers are numbered from 1.
+const SimGenerator = 0
+
+// A Sim is a deterministic simulation of a network of cores:
+// one generator and a set of block signers, each with its own
+// Chain. Messages between nodes are delivered by a single event
+// queue ordered by a virtual clock, so a scenario run with the
+// same seed always unfolds the same way. Tests script a scenario
+// by submitting transactions, proposing blocks, cutting links
+// and delaying nodes, then advancing the clock.
+//
+// When an invariant fails, the Sim reports its seed together
+// with the full event log.
+type Sim struct {
+	tb     testing.TB
+	seed   int64
+	rand   *rand.Rand
+	now    time.Time
+	events eventQueue
+	seq    int
+	log    bytes.Buffer
+
+	// Latency is the base delivery time of every message.
+	// Jitter up to Latency/2 is added from the Sim's seed.
+	Latency time.Duration
+
+	// SignTimeout is how long the generator waits
+	// for signatures before abandoning a round.
+	SignTimeout time.Duration
+
+	nodes  []*SimNode
+	quorum int
+	cut    map[[2]int]bool
+	delay  map[int]time.Duration
+
+	pending  []*bc.Tx
+	proposal *bc.Block
+	propSt   *state.Snapshot
+	sigs     [][]byte
+	round    int
+}
+
+// SimNode is one core in a Sim.
+type SimNode struct {
+	ID    int
+	Chain *protocol.Chain
+	Pub   ed25519.PublicKey
+	prv   ed25519.PrivateKey
+
+	// signed records, for a signer, the block
+	// it has signed at each height.
+	signed map[uint64]bc.Hash
+
+	// Forks counts blocks this node rejected
+	// with protocol.ErrFork.
+	Forks int
+}
+
+// NewSim returns a Sim with a generator and nSigners
+// block signers, quorum of which must sign each block.
+func NewSim(tb testing.TB, seed int64, nSigners, quorum int) *Sim {
+	s := &Sim{
+		tb:          tb,
+		seed:        seed,
+		rand:        rand.New(rand.NewSource(seed)),
+		now:         SimEpoch,
+		Latency:     10 * time.Millisecond,
+		SignTimeout: time.Second,
+		quorum:      quorum,
+		cut:         make(map[[2]int]bool),
+		delay:       make(map[int]time.Duration),
+	}
+
+	var pubkeys []ed25519.PublicKey
+	for i := 0; i <= nSigners; i++ {
+		pub, prv, err := ed25519.GenerateKey(s.rand)
+		if err != nil {
+			testutil.FatalErr(tb, err)
+		}
+		s.nodes = append(s.nodes, &SimNode{ID: i, Pub: pub, prv: prv, signed: make(map[uint64]bc.Hash)})
+		if i != SimGenerator {
+			pubkeys = append(pubkeys, pub)
+		}
+	}
+
+	b1, err := protocol.NewInitialBlock(pubkeys, quorum, SimEpoch)
+	if err != nil {
+		testutil.FatalErr(tb, err)
+	}
+	ctx := context.Background()
+	for _, n := range s.nodes {
+		n.Chain, err = protocol.NewChain(ctx, b1.Hash(), memstore.New(), nil)
+		if err != nil {
+			testutil.FatalErr(tb, err)
+		}
+		n.Chain.MaxIssuanceWindow = 48 * time.Hour
+		err = n.Chain.CommitBlock(ctx, b1, state.Empty())
+		if err != nil {
+			testutil.FatalErr(tb, err)
+		}
+	}
+	s.logf("start: %d signers, quorum %d, initial block %s", nSigners, quorum, b1.Hash())
+	return s
+}
+
+// Node returns the node with the given ID.
+func (s *Sim) Node(id int) *SimNode { return s.nodes[id] }
+
+// Now returns the current virtual time.
+func (s *Sim) Now() time.Time { return s.now }
+
+// Partition cuts the link between nodes a and b.
+// Messages sent in either direction are dropped.
+func (s *Sim) Partition(a, b int) {
+	s.logf("partition %d-%d", a, b)
+	s.cut[[2]int{a, b}] = true
+	s.cut[[2]int{b, a}] = true
+}
+
+// Heal restores the link between nodes a and b.
+func (s *Sim) Heal(a, b int) {
+	s.logf("heal %d-%d", a, b)
+	delete(s.cut, [2]int{a, b})
+	delete(s.cut, [2]int{b, a})
+}
+
+// Delay adds d to the delivery time of every
+// message sent to or from node id.
+func (s *Sim) Delay(id int, d time.Duration) {
+	s.logf("delay %d by %s", id, d)
+	s.delay[id] = d
+}
+
+// Submit adds tx to the generator's pending pool.
+// Conflicting transactions may be submitted;
+// the generator includes only those that are valid
+// when its next block is proposed.
+func (s *Sim) Submit(tx *bc.Tx) {
+	s.logf("submit tx %s", tx.ID)
+	s.pending = append(s.pending, tx)
+}
+
+// NewIssuanceTx returns a transaction issuing a new asset,
+// built from the Sim's seed and valid at the current virtual time.
+func (s *Sim) NewIssuanceTx() *bc.Tx {
+	return newIssuanceTx(s.tb, s.nodes[SimGenerator].Chain.InitialBlockHash, s.now, s.rand)
+}
+
+// Propose starts a signing round on the generator.
+// If an earlier proposal was never committed, it is
+// proposed again unchanged, since signers refuse to
+// sign two different blocks at the same height.
+func (s *Sim) Propose() {
+	ctx := context.Background()
+	gen := s.nodes[SimGenerator]
+	if s.proposal == nil {
+		prev, snapshot := gen.Chain.State()
+		b, st, err := gen.Chain.GenerateBlock(ctx, prev, snapshot, s.now, s.pending)
+		if err != nil {
+			s.Fatalf("generating block: %v", err)
+		}
+		s.pending = nil
+		s.proposal, s.propSt = b, st
+	}
+	s.round++
+	s.sigs = make([][]byte, len(s.nodes)-1)
+	b, round := s.proposal, s.round
+	s.logf("propose block %d (%s) with %d txs, round %d", b.Height, b.Hash(), len(b.Transactions), round)
+	for _, n := range s.nodes[1:] {
+		n := n
+		s.send(SimGenerator, n.ID, "sign-request", func() {
+			sig, err := s.sign(n, b)
+			if err != nil {
+				s.logf("node %d refuses block %d: %v", n.ID, b.Height, err)
+				return
+			}
+			s.send(n.ID, SimGenerator, "signature", func() {
+				s.collect(round, n.ID, sig)
+			})
+		})
+	}
+	s.after(s.SignTimeout, "sign-timeout", func() {
+		if s.round == round && s.proposal == b {
+			// Abandon the round; signatures
+			// arriving later are ignored.
+			s.round++
+			s.logf("round %d timed out", round)
+		}
+	})
+}
+
+// sign validates b on signer n and signs it.
+func (s *Sim) sign(n *SimNode, b *bc.Block) ([]byte, error) {
+	if h, ok := n.signed[b.Height]; ok && h != b.Hash() {
+		return nil, fmt.Errorf("already signed block %s at height %d", h, b.Height)
+	}
+	err := n.Chain.ValidateBlockForSig(context.Background(), b)
+	if err != nil {
+		return nil, err
+	}
+	n.signed[b.Height] = b.Hash()
+	h := b.Hash()
+	return ed25519.Sign(n.prv, h[:]), nil
+}
+
+// collect records a signature for the current proposal
+// and commits the block once a quorum has signed.
+func (s *Sim) collect(round, id int, sig []byte) {
+	if round != s.round || s.proposal == nil {
+		s.logf("late signature from node %d for round %d", id, round)
+		return
+	}
+	s.sigs[id-1] = sig
+	var witness [][]byte
+	for _, sig := range s.sigs {
+		if sig != nil {
+			witness = append(witness, sig)
+		}
+	}
+	if len(witness) < s.quorum {
+		return
+	}
+
+	b := *s.proposal
+	b.Witness = witness
+	st := s.propSt
+	s.proposal, s.propSt = nil, nil
+
+	s.logf("block %d signed by quorum", b.Height)
+	s.accept(s.nodes[SimGenerator], &b, st)
+	for _, n := range s.nodes[1:] {
+		n := n
+		s.send(SimGenerator, n.ID, "block", func() {
+			s.receive(n, &b)
+		})
+	}
+}
+
+// receive validates and commits b on node n,
+// catching up from the generator first if n is behind.
+func (s *Sim) receive(n *SimNode, b *bc.Block) {
+	ctx := context.Background()
+	prev, snapshot := n.Chain.State()
+	if b.Height <= prev.Height {
+		return
+	}
+	if b.Height > prev.Height+1 {
+		s.logf("node %d at height %d is behind; catching up", n.ID, prev.Height)
+		s.send(n.ID, SimGenerator, "catch-up", func() {
+			gen := s.nodes[SimGenerator].Chain
+			var blocks []*bc.Block
+			for h := prev.Height + 1; h <= b.Height; h++ {
+				blk, err := gen.GetBlock(ctx, h)
+				if err != nil {
+					s.Fatalf("generator missing block %d: %v", h, err)
+				}
+				blocks = append(blocks, blk)
+			}
+			s.send(SimGenerator, n.ID, "blocks", func() {
+				for _, blk := range blocks {
+					s.receive(n, blk)
+				}
+			})
+		})
+		return
+	}
+	st, err := n.Chain.ValidateBlock(ctx, snapshot, prev, b)
+	if errors.Root(err) == protocol.ErrFork {
+		n.Forks++
+		s.logf("node %d detected fork at height %d", n.ID, b.Height)
+		return
+	} else if err != nil {
+		s.Fatalf("node %d rejected block %d: %v", n.ID, b.Height, err)
+	}
+	s.accept(n, b, st)
+}
+
+func (s *Sim) accept(n *SimNode, b *bc.Block, st *state.Snapshot) {
+	err := n.Chain.CommitBlock(context.Background(), b, st)
+	if err != nil {
+		s.Fatalf("node %d committing block %d: %v", n.ID, b.Height, err)
+	}
+	s.logf("node %d committed block %d", n.ID, b.Height)
+}
+
+// Advance runs every event due within d of the current
+// virtual time, then moves the clock forward by d.
+func (s *Sim) Advance(d time.Duration) {
+	end := s.now.Add(d)
+	for len(s.events) > 0 && !s.events[0].at.After(end) {
+		ev := heap.Pop(&s.events).(*simEvent)
+		s.now = ev.at
+		ev.fn()
+	}
+	s.now = end
+}
+
+// RunUntilIdle runs events until none remain.
+func (s *Sim) RunUntilIdle() {
+	for len(s.events) > 0 {
+		ev := heap.Pop(&s.events).(*simEvent)
+		s.now = ev.at
+		ev.fn()
+	}
+}
+
+// CheckConsistency fails the test if any two nodes
+// have committed different blocks at the same height.
+func (s *Sim) CheckConsistency() {
+	ctx := context.Background()
+	for h := uint64(1); ; h++ {
+		var (
+			want  bc.Hash
+			found bool
+		)
+		for _, n := range s.nodes {
+			if n.Chain.Height() < h {
+				continue
+			}
+			b, err := n.Chain.GetBlock(ctx, h)
+			if err != nil {
+				s.Fatalf("node %d: getting block %d: %v", n.ID, h, err)
+			}
+			if !found {
+				want, found = b.Hash(), true
+			} else if b.Hash() != want {
+				s.Fatalf("node %d has block %s at height %d, want %s", n.ID, b.Hash(), h, want)
+			}
+		}
+		if !found {
+			return
+		}
+	}
+}
+
+// Fatalf fails the test, reporting the seed
+// and event log needed to reproduce the failure.
+func (s *Sim) Fatalf(format string, args ...interface{}) {
+	s.tb.Fatalf("simnet (seed %d) at %s: %s\nevent log:\n%s",
+		s.seed, s.now.Sub(SimEpoch), fmt.Sprintf(format, args...), s.log.String())
+}
+
+// Log returns the event log so far.
+func (s *Sim) Log() string { return s.log.String() }
+
+func (s *Sim) logf(format string, args ...interface{}) {
+	fmt.Fprintf(&s.log, "%10s  ", s.now.Sub(SimEpoch))
+	fmt.Fprintf(&s.log, format, args...)
+	s.log.WriteByte('\n')
+}
+
+// send schedules fn to run on delivery of a message
+// from node src to node dst, unless the link is cut.
+// Cut links are checked at delivery time.
+func (s *Sim) send(src, dst int, what string, fn func()) {
+	d := s.Latency + s.delay[src] + s.delay[dst]
+	if s.Latency > 0 {
+		d += time.Duration(s.rand.Int63n(int64(s.Latency/2) + 1))
+	}
+	s.after(d, fmt.Sprintf("%s %d->%d", what, src, dst), func() {
+		if s.cut[[2]int{src, dst}] {
+			s.logf("drop %s %d->%d", what, src, dst)
+			return
+		}
+		fn()
+	})
+}
+
+func (s *Sim) after(d time.Duration, desc string, fn func()) {
+	s.seq++
+	heap.Push(&s.events, &simEvent{at: s.now.Add(d), seq: s.seq, desc: desc, fn: fn})
+}
+
+type simEvent struct {
+	at   time.Time
+	seq  int
+	desc string
+	fn   func()
+}
+
+// eventQueue orders events by time, then by the order
+// they were scheduled, so ties resolve deterministically.
+type eventQueue []*simEvent
+
+func (q eventQueue) Len() int { return len(q) }
+func (q eventQueue) Less(i, j int) bool {
+	if !q[i].at.Equal(q[j].at) {
+		return q[i].at.Before(q[j].at)
+	}
+	return q[i].seq < q[j].seq
+}
+func (q eventQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *eventQueue) Push(x interface{}) { *q = append(*q, x.(*simEvent)) }
+func (q *eventQueue) Pop() interface{} {
+	old := *q
+	ev := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return ev
+}
@@ -0,0 +1,95 @@
+package prottest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSimBlocks(t *testing.T) {
+	s := NewSim(t, 1, 3, 2)
+	for i := 0; i < 3; i++ {
+		s.Submit(s.NewIssuanceTx())
+		s.Propose()
+		s.RunUntilIdle()
+	}
+	for id := 0; id <= 3; id++ {
+		if h := s.Node(id).Chain.Height(); h != 4 {
+			t.Errorf("node %d height = %d want 4", id, h)
+		}
+	}
+	s.CheckConsistency()
+}
+
+func TestSimConflictingSubmissions(t *testing.T) {
+	s := NewSim(t, 1, 1, 1)
+	tx := s.NewIssuanceTx()
+	s.Submit(tx)
+	s.Submit(tx) // the same issuance twice
+	s.Propose()
+	s.RunUntilIdle()
+
+	b, _ := s.Node(1).Chain.State()
+	if b.Height != 2 || len(b.Transactions) != 1 {
+		t.Fatalf("got block %d with %d txs, want block 2 with 1 tx\n%s", b.Height, len(b.Transactions), s.Log())
+	}
+}
+
+func TestSimPartition(t *testing.T) {
+	s := NewSim(t, 2, 3, 2)
+	s.Partition(SimGenerator, 3)
+	s.Propose()
+	s.RunUntilIdle()
+	s.Propose()
+	s.RunUntilIdle()
+	if h := s.Node(3).Chain.Height(); h != 1 {
+		t.Fatalf("partitioned node height = %d want 1", h)
+	}
+
+	s.Heal(SimGenerator, 3)
+	s.Propose()
+	s.RunUntilIdle()
+	for id := 0; id <= 3; id++ {
+		if h := s.Node(id).Chain.Height(); h != 4 {
+			t.Errorf("node %d height = %d want 4\n%s", id, h, s.Log())
+		}
+	}
+	s.CheckConsistency()
+}
+
+func TestSimDelayedSignatures(t *testing.T) {
+	s := NewSim(t, 3, 2, 2)
+	s.Delay(2, s.SignTimeout)
+	s.Propose()
+	s.Advance(2 * s.SignTimeout)
+	if h := s.Node(SimGenerator).Chain.Height(); h != 1 {
+		t.Fatalf("generator height = %d want 1 (round should time out)", h)
+	}
+
+	// The same block is proposed again and now
+	// collects both signatures in time.
+	s.Delay(2, 0)
+	s.Propose()
+	s.RunUntilIdle()
+	if h := s.Node(SimGenerator).Chain.Height(); h != 2 {
+		t.Fatalf("generator height = %d want 2\n%s", h, s.Log())
+	}
+	s.CheckConsistency()
+}
+
+func TestSimDeterministic(t *testing.T) {
+	run := func() string {
+		s := NewSim(t, 42, 4, 3)
+		s.Partition(1, SimGenerator)
+		s.Submit(s.NewIssuanceTx())
+		s.Propose()
+		s.Advance(500 * time.Millisecond)
+		s.Heal(1, SimGenerator)
+		s.Submit(s.NewIssuanceTx())
+		s.Propose()
+		s.RunUntilIdle()
+		return s.Log()
+	}
+	if a, b := run(), run(); a != b {
+		t.Errorf("runs with the same seed differ:\n%s\n---\n%s", a, b)
+	}
+}
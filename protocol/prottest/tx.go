@@ -3,6 +3,7 @@ package prottest
 import (
 	"context"
 	"crypto/rand"
+	"io"
 	"testing"
 	"time"
 
@@ -28,9 +29,14 @@ func NewIssuanceTx(tb testing.TB, c *protocol.Chain) *bc.Tx {
 	if err != nil {
 		testutil.FatalErr(tb, err)
 	}
+	return newIssuanceTx(tb, b1.Hash(), time.Now(), rand.Reader)
+}
 
+// newIssuanceTx is NewIssuanceTx with an explicit
+// initial block hash, current time, and source of randomness.
+func newIssuanceTx(tb testing.TB, initialBlockHash bc.Hash, now time.Time, rand io.Reader) *bc.Tx {
 	// Generate a random key pair for the asset being issued.
-	xprv, xpub, err := chainkd.NewXKeys(nil)
+	xprv, xpub, err := chainkd.NewXKeys(rand)
 	if err != nil {
 		testutil.FatalErr(tb, err)
 	}
@@ -47,17 +53,17 @@ func NewIssuanceTx(tb testing.TB, c *protocol.Chain) *bc.Tx {
 
 	// Create a transaction issuing this new asset.
 	var nonce [8]byte
-	_, err = rand.Read(nonce[:])
+	_, err = io.ReadFull(rand, nonce[:])
 	if err != nil {
 		testutil.FatalErr(tb, err)
 	}
 	assetdef := []byte(`{"type": "prottest issuance"}`)
-	txin := bc.NewIssuanceInput(nonce[:], 100, nil, b1.Hash(), issuanceProgram, nil, assetdef)
+	txin := bc.NewIssuanceInput(nonce[:], 100, nil, initialBlockHash, issuanceProgram, nil, assetdef)
 
 	tx := bc.NewTx(bc.TxData{
 		Version: bc.CurrentTransactionVersion,
-		MinTime: bc.Millis(time.Now().Add(-5 * time.Minute)),
-		MaxTime: bc.Millis(time.Now().Add(5 * time.Minute)),
+		MinTime: bc.Millis(now.Add(-5 * time.Minute)),
+		MaxTime: bc.Millis(now.Add(5 * time.Minute)),
 		Inputs:  []*bc.TxInput{txin},
 		Outputs: []*bc.TxOutput{
 			bc.NewTxOutput(txin.AssetID(), 100, []byte{0xbe, 0xef}, nil),
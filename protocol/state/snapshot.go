@@ -2,6 +2,7 @@
 package state
 
 import (
+	"chain/errors"
 	"chain/protocol/bc"
 	"chain/protocol/patricia"
 )
@@ -51,3 +52,46 @@ func Empty() *Snapshot {
 		Issuances: make(map[bc.Hash]uint64),
 	}
 }
+
+// Diff describes how to derive one snapshot from another.
+// The state tree changes are recorded item by item;
+// the issuance memory, which is small and pruned
+// continually, is recorded in full.
+type Diff struct {
+	Inserted  [][]byte
+	Deleted   [][]byte
+	Issuances map[bc.Hash]uint64
+}
+
+// NewDiff returns the diff that transforms base into s.
+func NewDiff(base, s *Snapshot) *Diff {
+	d := &Diff{Issuances: make(map[bc.Hash]uint64, len(s.Issuances))}
+	d.Inserted, d.Deleted = patricia.Diff(base.Tree, s.Tree)
+	for k, v := range s.Issuances {
+		d.Issuances[k] = v
+	}
+	return d
+}
+
+// Apply returns a new snapshot made by applying d to base.
+// It does not modify base.
+func (d *Diff) Apply(base *Snapshot) (*Snapshot, error) {
+	s := &Snapshot{
+		Tree:      new(patricia.Tree),
+		Issuances: make(map[bc.Hash]uint64, len(d.Issuances)),
+	}
+	*s.Tree = *base.Tree
+	for _, item := range d.Deleted {
+		s.Tree.Delete(item)
+	}
+	for _, item := range d.Inserted {
+		err := s.Tree.Insert(item)
+		if err != nil {
+			return nil, errors.Wrap(err, "applying state tree diff")
+		}
+	}
+	for k, v := range d.Issuances {
+		s.Issuances[k] = v
+	}
+	return s, nil
+}
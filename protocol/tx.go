@@ -48,6 +48,13 @@ func (c *prevalidatedTxsCache) cache(txID bc.Hash, err error) {
 	c.mu.Unlock()
 }
 
+// CheckIssuanceWindow returns an error if tx has an issuance
+// input and its time range is wider than the maximum issuance
+// window. Such a tx cannot be included in a block.
+func (c *Chain) CheckIssuanceWindow(tx *bc.Tx) error {
+	return c.checkIssuanceWindow(tx)
+}
+
 func (c *Chain) checkIssuanceWindow(tx *bc.Tx) error {
 	for _, txi := range tx.Inputs {
 		if _, ok := txi.TypedInput.(*bc.IssuanceInput); ok {
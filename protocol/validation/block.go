@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"runtime"
 	"strings"
+	"time"
 
 	"golang.org/x/sync/errgroup"
 
@@ -25,6 +26,7 @@ var (
 	ErrBadSig       = errors.New("invalid signature script")
 	ErrBadTxRoot    = errors.New("invalid transaction merkle root")
 	ErrBadStateRoot = errors.New("invalid state merkle root")
+	ErrBadHashFunc  = errors.New("initial block commits to a different hash function")
 )
 
 // ValidateBlockForAccept performs steps 1 and 2
@@ -33,6 +35,7 @@ var (
 // It evaluates the prevBlock's consensus program,
 // then calls ValidateBlock.
 func ValidateBlockForAccept(ctx context.Context, snapshot *state.Snapshot, initialBlockHash bc.Hash, prevBlock, block *bc.Block, validateTx func(*bc.Tx) error) error {
+	start := time.Now()
 	if prevBlock != nil {
 		err := vm.VerifyBlockHeader(&prevBlock.BlockHeader, block)
 		if err != nil {
@@ -45,8 +48,16 @@ func ValidateBlockForAccept(ctx context.Context, snapshot *state.Snapshot, initi
 			return errors.Sub(ErrBadSig, errors.Wrapf(err, "program [%s] witness [%s]", pkScriptStr, witnessStr))
 		}
 	}
+	consensusProgram := time.Since(start)
 
-	return ValidateBlock(ctx, snapshot, initialBlockHash, prevBlock, block, validateTx)
+	bt, txs, err := validateBlock(ctx, snapshot, initialBlockHash, prevBlock, block, validateTx)
+	if err != nil {
+		return err
+	}
+	bt.Header += consensusProgram
+	bt.Total = time.Since(start)
+	bt.record(txs)
+	return nil
 }
 
 // ValidateBlock performs the "validate block" procedure from the spec,
@@ -55,6 +66,18 @@ func ValidateBlockForAccept(ctx context.Context, snapshot *state.Snapshot, initi
 // Note that it does not execute prevBlock's consensus program.
 // (See ValidateBlockForAccept for that.)
 func ValidateBlock(ctx context.Context, snapshot *state.Snapshot, initialBlockHash bc.Hash, prevBlock, block *bc.Block, validateTx func(*bc.Tx) error) error {
+	_, _, err := validateBlock(ctx, snapshot, initialBlockHash, prevBlock, block, validateTx)
+	return err
+}
+
+// validateBlock implements ValidateBlock,
+// timing each stage of validation.
+func validateBlock(ctx context.Context, snapshot *state.Snapshot, initialBlockHash bc.Hash, prevBlock, block *bc.Block, validateTx func(*bc.Tx) error) (*BlockTiming, []TxTiming, error) {
+	bt := &BlockTiming{Height: block.Height}
+	txs := make([]TxTiming, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		txs[i] = TxTiming{BlockHeight: block.Height, TxID: tx.ID}
+	}
 
 	var g errgroup.Group
 	// Do all of the unparallelizable work, plus validating the block
@@ -64,49 +87,68 @@ func ValidateBlock(ctx context.Context, snapshot *state.Snapshot, initialBlockHa
 		if prevBlock != nil {
 			prev = &prevBlock.BlockHeader
 		}
+		t0 := time.Now()
 		err := validateBlockHeader(prev, block)
 		if err != nil {
 			return err
 		}
+		bt.Header = time.Since(t0)
 		snapshot.PruneIssuances(block.TimestampMS)
 
 		// TODO: Check that other block headers are valid.
 		// TODO(erykwalder): consider writing to a copy of the state tree
 		// of the one provided and make the caller call ApplyBlock as well
-		for _, tx := range block.Transactions {
+		for i, tx := range block.Transactions {
+			t0 = time.Now()
 			err = ConfirmTx(snapshot, initialBlockHash, block.Version, block.TimestampMS, tx)
 			if err != nil {
 				return err
 			}
+			t1 := time.Now()
 			err = ApplyTx(snapshot, tx)
 			if err != nil {
 				return err
 			}
+			txs[i].Confirm = t1.Sub(t0)
+			txs[i].Apply = time.Since(t1)
+			bt.Confirm += txs[i].Confirm
+			bt.Apply += txs[i].Apply
 		}
+		t0 = time.Now()
 		if block.AssetsMerkleRoot != snapshot.Tree.RootHash() {
 			return ErrBadStateRoot
 		}
+		bt.StateRoot = time.Since(t0)
 		return nil
 	})
 
 	// Distribute checking well-formedness of the transactions across
 	// GOMAXPROCS goroutines.
-	ch := make(chan *bc.Tx, len(block.Transactions))
+	t0 := time.Now()
+	ch := make(chan int, len(block.Transactions))
+	var txsDone errgroup.Group
 	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
-		g.Go(func() error {
-			for tx := range ch {
-				if err := validateTx(tx); err != nil {
+		txsDone.Go(func() error {
+			for i := range ch {
+				start := time.Now()
+				if err := validateTx(block.Transactions[i]); err != nil {
 					return err
 				}
+				txs[i].Validate = time.Since(start)
 			}
 			return nil
 		})
 	}
-	for _, tx := range block.Transactions {
-		ch <- tx
+	for i := range block.Transactions {
+		ch <- i
 	}
 	close(ch)
-	return g.Wait()
+	g.Go(func() error {
+		err := txsDone.Wait()
+		bt.Txs = time.Since(t0)
+		return err
+	})
+	return bt, txs, g.Wait()
 }
 
 // ApplyBlock applies the transactions in the block to the state tree.
@@ -121,10 +163,29 @@ func ApplyBlock(snapshot *state.Snapshot, block *bc.Block) error {
 	return nil
 }
 
+// CheckHashFunc checks that the initial block header bh
+// commits to the hash function currently in use for entry IDs.
+func CheckHashFunc(bh *bc.BlockHeader) error {
+	id, err := bh.HashFuncID()
+	if err != nil {
+		return errors.Sub(ErrBadHashFunc, err)
+	}
+	if cur := bc.CurrentHashFunc(); id != cur.ID {
+		return errors.WithDetailf(ErrBadHashFunc, "block commits to hash function %d, using %d (%s)", id, cur.ID, cur.Name)
+	}
+	return nil
+}
+
 func validateBlockHeader(prev *bc.BlockHeader, block *bc.Block) error {
 	if prev == nil && block.Height != 1 {
 		return ErrBadHeight
 	}
+	if prev == nil {
+		err := CheckHashFunc(&block.BlockHeader)
+		if err != nil {
+			return err
+		}
+	}
 	if prev != nil {
 		prevHash := prev.Hash()
 		if !bytes.Equal(block.PreviousBlockHash[:], prevHash[:]) {
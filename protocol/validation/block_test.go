@@ -138,3 +138,31 @@ func TestValidateBlockHeader(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateInitialBlockHashFunc(t *testing.T) {
+	ctx := context.Background()
+	var suffix [1]byte
+	suffix[0] = 7 // varint63 hash function ID 7; not in use
+
+	cases := []struct {
+		suffix []byte
+		want   error
+	}{
+		{nil, nil},
+		{suffix[:], ErrBadHashFunc},
+	}
+	for _, c := range cases {
+		block := &bc.Block{BlockHeader: bc.BlockHeader{
+			Height: 1,
+			BlockCommitment: bc.BlockCommitment{
+				TransactionsMerkleRoot: emptyMerkleRoot,
+				ConsensusProgram:       []byte{byte(vm.OP_TRUE)},
+			},
+			CommitmentSuffix: c.suffix,
+		}}
+		got := ValidateBlock(ctx, state.Empty(), bc.Hash{}, nil, block, nil)
+		if errors.Root(got) != c.want {
+			t.Errorf("suffix %x: got %v want %v", c.suffix, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package validation
+
+import (
+	"encoding/json"
+	"expvar"
+	"sort"
+	"sync"
+	"time"
+
+	"chain/metrics"
+	"chain/protocol/bc"
+)
+
+const (
+	// timedBlocks is the number of recent
+	// accepted blocks whose timings are kept.
+	timedBlocks = 100
+
+	// timedTxsPerBlock is the number of the slowest
+	// transactions kept from each block.
+	timedTxsPerBlock = 50
+
+	// publishedBlocks is the number of recent block
+	// timings published under the "block_validation" expvar.
+	publishedBlocks = 10
+)
+
+// Latency histograms for each stage of block validation.
+var (
+	headerLatency    = newStageLatency("validation.header", time.Second)
+	txLatency        = newStageLatency("validation.tx", 100*time.Millisecond)
+	vmLatency        = newStageLatency("validation.vm", 100*time.Millisecond)
+	confirmLatency   = newStageLatency("validation.confirm", 100*time.Millisecond)
+	applyLatency     = newStageLatency("validation.apply", 100*time.Millisecond)
+	blockLatency     = newStageLatency("validation.block", 20*time.Second)
+	stateRootLatency = newStageLatency("validation.state_root", time.Second)
+)
+
+var recent = new(timingLog)
+
+func init() {
+	expvar.Publish("block_validation", expvar.Func(func() interface{} {
+		return RecentBlockTimings(publishedBlocks)
+	}))
+}
+
+func newStageLatency(name string, max time.Duration) *metrics.RotatingLatency {
+	l := metrics.NewRotatingLatency(5, max)
+	metrics.PublishLatency(name, l)
+	return l
+}
+
+// TxTiming records the time spent on each
+// validation stage of one transaction in a block.
+// Validate includes VM execution; it is zero for
+// transactions validated before the block arrived.
+// VM execution alone is recorded only in aggregate,
+// in the "validation.vm" latency histogram.
+type TxTiming struct {
+	BlockHeight uint64        `json:"block_height"`
+	TxID        bc.Hash       `json:"tx_id"`
+	Validate    time.Duration `json:"validate_ns"`
+	Confirm     time.Duration `json:"confirm_ns"`
+	Apply       time.Duration `json:"apply_ns"`
+}
+
+// Total returns the time spent on all stages of t.
+func (t TxTiming) Total() time.Duration {
+	return t.Validate + t.Confirm + t.Apply
+}
+
+// MarshalJSON includes the total in the JSON form of t.
+func (t TxTiming) MarshalJSON() ([]byte, error) {
+	type txTiming TxTiming
+	return json.Marshal(struct {
+		txTiming
+		Total time.Duration `json:"total_ns"`
+	}{txTiming(t), t.Total()})
+}
+
+// BlockTiming records the time spent on each stage
+// of validating one block. Validation of the transactions
+// runs in parallel with the other stages, so the stages
+// need not add up to Total.
+type BlockTiming struct {
+	Height    uint64        `json:"block_height"`
+	Header    time.Duration `json:"header_ns"`
+	Txs       time.Duration `json:"txs_ns"`
+	Confirm   time.Duration `json:"confirm_ns"`
+	Apply     time.Duration `json:"apply_ns"`
+	StateRoot time.Duration `json:"state_root_ns"`
+	Total     time.Duration `json:"total_ns"`
+
+	// SlowTxs holds the slowest transactions
+	// in the block, slowest first.
+	SlowTxs []TxTiming `json:"slow_txs,omitempty"`
+}
+
+// RecentBlockTimings returns the timings of up to n of the most
+// recently accepted blocks, most recent first.
+func RecentBlockTimings(n int) []BlockTiming {
+	return recent.blocks(n)
+}
+
+// SlowestTxs returns the n slowest transactions
+// among the most recent nblocks accepted blocks.
+func SlowestTxs(nblocks, n int) []TxTiming {
+	var txs []TxTiming
+	for _, b := range recent.blocks(nblocks) {
+		txs = append(txs, b.SlowTxs...)
+	}
+	sort.Sort(byTotal(txs))
+	if len(txs) > n {
+		txs = txs[:n]
+	}
+	return txs
+}
+
+// record publishes the timings of an accepted block.
+func (bt *BlockTiming) record(txs []TxTiming) {
+	headerLatency.Record(bt.Header)
+	confirmLatency.Record(bt.Confirm)
+	applyLatency.Record(bt.Apply)
+	stateRootLatency.Record(bt.StateRoot)
+	blockLatency.Record(bt.Total)
+	for i := range txs {
+		if txs[i].Validate > 0 {
+			txLatency.Record(txs[i].Validate)
+		}
+	}
+
+	sort.Sort(byTotal(txs))
+	if len(txs) > timedTxsPerBlock {
+		txs = txs[:timedTxsPerBlock]
+	}
+	bt.SlowTxs = append([]TxTiming(nil), txs...)
+	recent.add(*bt)
+}
+
+// timingLog is a fixed-size circular log of block timings.
+type timingLog struct {
+	mu  sync.Mutex
+	buf [timedBlocks]BlockTiming
+	n   int // total number of blocks ever added
+}
+
+func (l *timingLog) add(bt BlockTiming) {
+	l.mu.Lock()
+	l.buf[l.n%len(l.buf)] = bt
+	l.n++
+	l.mu.Unlock()
+}
+
+func (l *timingLog) blocks(n int) []BlockTiming {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if n > l.n {
+		n = l.n
+	}
+	if n > len(l.buf) {
+		n = len(l.buf)
+	}
+	res := make([]BlockTiming, 0, n)
+	for i := 1; i <= n; i++ {
+		res = append(res, l.buf[(l.n-i)%len(l.buf)])
+	}
+	return res
+}
+
+type byTotal []TxTiming
+
+func (a byTotal) Len() int           { return len(a) }
+func (a byTotal) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byTotal) Less(i, j int) bool { return a[i].Total() > a[j].Total() }
@@ -0,0 +1,86 @@
+package validation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"chain/protocol/bc"
+	"chain/protocol/state"
+	"chain/protocol/vm"
+)
+
+func TestTimingLog(t *testing.T) {
+	l := new(timingLog)
+	if got := l.blocks(5); len(got) != 0 {
+		t.Fatalf("empty log has %d blocks", len(got))
+	}
+	for h := uint64(1); h <= timedBlocks+3; h++ {
+		l.add(BlockTiming{Height: h})
+	}
+	got := l.blocks(timedBlocks + 10)
+	if len(got) != timedBlocks {
+		t.Fatalf("got %d blocks want %d", len(got), timedBlocks)
+	}
+	if got[0].Height != timedBlocks+3 || got[len(got)-1].Height != 4 {
+		t.Errorf("got heights %d..%d want %d..4", got[0].Height, got[len(got)-1].Height, timedBlocks+3)
+	}
+}
+
+func TestSlowestTxs(t *testing.T) {
+	saved := recent
+	recent = new(timingLog)
+	defer func() { recent = saved }()
+
+	for h := uint64(1); h <= 3; h++ {
+		var txs []TxTiming
+		for i := 0; i < timedTxsPerBlock+5; i++ {
+			txs = append(txs, TxTiming{BlockHeight: h, Confirm: time.Duration(h*1000) + time.Duration(i)})
+		}
+		bt := &BlockTiming{Height: h}
+		bt.record(txs)
+		if len(bt.SlowTxs) != timedTxsPerBlock {
+			t.Fatalf("block %d kept %d txs want %d", h, len(bt.SlowTxs), timedTxsPerBlock)
+		}
+	}
+
+	got := SlowestTxs(2, 3)
+	want := []time.Duration{3000 + timedTxsPerBlock + 4, 3000 + timedTxsPerBlock + 3, 3000 + timedTxsPerBlock + 2}
+	if len(got) != len(want) {
+		t.Fatalf("got %d txs want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Total() != want[i] {
+			t.Errorf("tx %d total = %d want %d", i, got[i].Total(), want[i])
+		}
+	}
+}
+
+func TestValidateBlockRecordsTiming(t *testing.T) {
+	saved := recent
+	recent = new(timingLog)
+	defer func() { recent = saved }()
+
+	prev := &bc.Block{BlockHeader: bc.BlockHeader{
+		Height: 1,
+		BlockCommitment: bc.BlockCommitment{
+			ConsensusProgram: []byte{byte(vm.OP_TRUE)},
+		},
+	}}
+	block := &bc.Block{BlockHeader: bc.BlockHeader{
+		PreviousBlockHash: prev.Hash(),
+		Height:            2,
+		BlockCommitment: bc.BlockCommitment{
+			TransactionsMerkleRoot: emptyMerkleRoot,
+			ConsensusProgram:       []byte{byte(vm.OP_TRUE)},
+		},
+	}}
+	err := ValidateBlockForAccept(context.Background(), state.Empty(), bc.Hash{}, prev, block, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := RecentBlockTimings(10)
+	if len(got) != 1 || got[0].Height != 2 || got[0].Total <= 0 {
+		t.Errorf("RecentBlockTimings = %+v want one timing for block 2", got)
+	}
+}
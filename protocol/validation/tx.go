@@ -2,7 +2,7 @@ package validation
 
 import (
 	"bytes"
-	"math"
+	"time"
 
 	"chain/errors"
 	"chain/math/checked"
@@ -129,8 +129,8 @@ func CheckTxWellFormed(tx *bc.Tx) error {
 		return badTxErr(errNoInputs)
 	}
 
-	if len(tx.Inputs) > math.MaxInt32 {
-		return badTxErr(errTooManyInputs)
+	if err := bc.CheckCapacity(len(tx.Inputs)); err != nil {
+		return badTxErrf(errTooManyInputs, "%s", errors.Detail(err))
 	}
 
 	// Are all inputs issuances, all with asset version 1, and all with empty nonces?
@@ -173,11 +173,12 @@ func CheckTxWellFormed(tx *bc.Tx) error {
 
 		assetID := txin.AssetID()
 
-		if txin.Amount() > math.MaxInt64 {
+		amount, err := bc.AmountToVM(txin.Amount())
+		if err != nil {
 			return badTxErr(errInputTooBig)
 		}
 
-		sum, ok := checked.AddInt64(parity[assetID], int64(txin.Amount()))
+		sum, ok := checked.AddInt64(parity[assetID], amount)
 		if !ok {
 			return badTxErrf(errInputSumTooBig, "adding input %d overflows the allowed asset amount", i)
 		}
@@ -211,8 +212,8 @@ func CheckTxWellFormed(tx *bc.Tx) error {
 		commitments[string(buf.Bytes())] = i
 	}
 
-	if len(tx.Outputs) > math.MaxInt32 {
-		return badTxErr(errTooManyOutputs)
+	if err := bc.CheckCapacity(len(tx.Outputs)); err != nil {
+		return badTxErrf(errTooManyOutputs, "%s", errors.Detail(err))
 	}
 
 	// Check that every output has a valid value.
@@ -232,11 +233,12 @@ func CheckTxWellFormed(tx *bc.Tx) error {
 			return badTxErr(errEmptyOutput)
 		}
 
-		if txout.Amount > math.MaxInt64 {
+		amount, err := bc.AmountToVM(txout.Amount)
+		if err != nil {
 			return badTxErr(errOutputTooBig)
 		}
 
-		sum, ok := checked.SubInt64(parity[txout.AssetID], int64(txout.Amount))
+		sum, ok := checked.SubInt64(parity[txout.AssetID], amount)
 		if !ok {
 			return badTxErrf(errOutputSumTooBig, "adding output %d overflows the allowed asset amount", i)
 		}
@@ -249,6 +251,7 @@ func CheckTxWellFormed(tx *bc.Tx) error {
 		}
 	}
 
+	defer vmLatency.RecordSince(time.Now())
 	for i := range tx.Inputs {
 		err := vm.VerifyTxInput(tx, uint32(i))
 		if err != nil {
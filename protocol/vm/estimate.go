@@ -0,0 +1,58 @@
+package vm
+
+// Estimate returns an estimate of the run cost of executing
+// prog once in VM version 1, for tooling that must budget for
+// a program before it can run it: before the signatures the
+// program checks exist, for instance.
+//
+// The estimate assumes straight-line execution: every
+// instruction runs once, in order, and no jump is taken. Each
+// instruction is charged its base cost, and each push the
+// memory cost of the item it pushes. Memory refunded by pops,
+// and pushed by ops other than the push ops, is not counted.
+// CHECKMULTISIG is charged for the number of public keys
+// pushed just before it, and CHECKPREDICATE only for its own
+// overhead, not for the predicate it runs, which can be
+// estimated separately.
+func Estimate(prog []byte) (int64, error) {
+	insts, err := ParseProgram(prog)
+	if err != nil {
+		return 0, err
+	}
+	v1 := versions[1]
+	itemCost := v1.Limits.StackItemCost
+
+	var (
+		cost int64
+		last []byte // item pushed by the previous instruction
+		push bool   // whether the previous instruction pushed last
+	)
+	for _, inst := range insts {
+		d := v1.Ops[inst.Op]
+		switch {
+		case isPush(inst.Op):
+			cost += d.BaseCost + itemCost + int64(len(inst.Data))
+		case inst.Op == OP_CHECKMULTISIG:
+			// The stack is [... PUB PUB PUB M N]; N
+			// is usually pushed just before the op.
+			if push {
+				n, err := AsInt64(last)
+				if err == nil && n > 0 {
+					cost += 1024 * n
+				}
+			}
+		case inst.Op == OP_CHECKPREDICATE:
+			cost += 64 // the part of the base cost not refunded
+		default:
+			cost += d.BaseCost
+		}
+		last, push = inst.Data, isPush(inst.Op)
+	}
+	return cost, nil
+}
+
+// isPush reports whether op pushes data
+// from the program onto the stack.
+func isPush(op Op) bool {
+	return op <= OP_PUSHDATA4 || op == OP_1NEGATE || (op >= OP_1 && op <= OP_16)
+}
@@ -0,0 +1,39 @@
+package vm
+
+import "testing"
+
+func TestEstimate(t *testing.T) {
+	cases := []struct {
+		prog string
+		want int64
+	}{
+		{"", 0},
+		{"TRUE", 1 + 8 + 1},
+		{"0x0102 DROP", 1 + 8 + 2 + 1},
+		{"1 2 ADD 3 NUMEQUAL", 3*(1+8+1) + 2 + 2},
+		{"SHA3", 64},
+		// DUP TOALTSTACK SHA3 <2 pubkeys> 1 2 CHECKMULTISIG VERIFY FROMALTSTACK 0 CHECKPREDICATE
+		{
+			"DUP TOALTSTACK SHA3 0x0101 0x0202 1 2 CHECKMULTISIG VERIFY FROMALTSTACK 0 CHECKPREDICATE",
+			1 + 2 + 64 + 2*(1+8+2) + 2*(1+8+1) + 2*1024 + 1 + 2 + (1 + 8 + 0) + 64,
+		},
+	}
+	for _, c := range cases {
+		prog, err := Assemble(c.prog)
+		if err != nil {
+			t.Fatalf("Assemble(%q): %v", c.prog, err)
+		}
+		got, err := Estimate(prog)
+		if err != nil {
+			t.Fatalf("Estimate(%q): %v", c.prog, err)
+		}
+		if got != c.want {
+			t.Errorf("Estimate(%q) = %d want %d", c.prog, got, c.want)
+		}
+	}
+
+	_, err := Estimate([]byte{byte(OP_PUSHDATA1)})
+	if err == nil {
+		t.Error("expected error for truncated program")
+	}
+}
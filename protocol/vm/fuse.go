@@ -0,0 +1,169 @@
+package vm
+
+import "golang.org/x/crypto/sha3"
+
+// Superinstructions, if true, makes the VM compile each program
+// before running it, replacing common sequences of instructions
+// with superinstructions. A superinstruction does the work of its
+// whole sequence in one step and applies the sequence's combined
+// cost at once, saving the cost of dispatching each instruction.
+//
+// Programs produce the same results, errors, and run limits
+// either way. Superinstructions are not used while tracing.
+// Set this only at startup, before any programs run.
+var Superinstructions bool
+
+// A superinstruction replaces a sequence
+// of instructions at a particular pc.
+type superinstruction struct {
+	len uint32 // total length in bytes of the sequence
+
+	// exec runs the sequence. It returns false, without changing
+	// the VM, if it cannot be sure of running the sequence exactly
+	// as the individual instructions would (for example, if an
+	// instruction would fail or exhaust the run limit partway).
+	// The VM then runs the instructions one at a time.
+	exec func(vm *virtualMachine) bool
+}
+
+// compile finds the sequences of instructions in prog
+// that can be replaced by superinstructions.
+// The result is keyed by the pc of each sequence.
+// A jump into the middle of a sequence, or to a pc
+// missing from the result, runs without superinstructions.
+func compile(prog []byte) map[uint32]*superinstruction {
+	var (
+		insts []Instruction
+		pcs   []uint32
+	)
+	for pc := uint32(0); pc < uint32(len(prog)); {
+		inst, err := ParseOp(prog, pc)
+		if err != nil {
+			// The VM reports the error if it gets here.
+			break
+		}
+		insts = append(insts, inst)
+		pcs = append(pcs, pc)
+		pc += inst.Len
+	}
+
+	var res map[uint32]*superinstruction
+	for i := 0; i < len(insts); {
+		s, n := fuse(insts[i:])
+		if s == nil {
+			i++
+			continue
+		}
+		if res == nil {
+			res = make(map[uint32]*superinstruction)
+		}
+		res[pcs[i]] = s
+		i += n
+	}
+	return res
+}
+
+// fuse returns a superinstruction for a sequence at the start
+// of insts, and the number of instructions it replaces.
+// It returns nil if insts does not start with such a sequence.
+func fuse(insts []Instruction) (*superinstruction, int) {
+	// DUP TOALTSTACK SHA3 begins every standard control program,
+	// stashing the predicate and hashing it for CHECKMULTISIG.
+	if len(insts) >= 3 &&
+		insts[0].Op == OP_DUP &&
+		insts[1].Op == OP_TOALTSTACK &&
+		insts[2].Op == OP_SHA3 {
+		s := &superinstruction{
+			len:  insts[0].Len + insts[1].Len + insts[2].Len,
+			exec: execStashHash,
+		}
+		return s, 3
+	}
+
+	// Runs of pushes, such as the keys and counts
+	// given to CHECKMULTISIG.
+	var (
+		vals [][]byte
+		n    uint32
+	)
+	for _, inst := range insts {
+		v, ok := pushValue(inst)
+		if !ok {
+			break
+		}
+		vals = append(vals, v)
+		n += inst.Len
+	}
+	if len(vals) >= 2 {
+		return &superinstruction{len: n, exec: pushRun(vals)}, len(vals)
+	}
+	return nil, 0
+}
+
+// pushValue returns the item pushed by inst,
+// if inst pushes a constant.
+func pushValue(inst Instruction) ([]byte, bool) {
+	switch {
+	case inst.Op == OP_FALSE:
+		return BoolBytes(false), true
+	case inst.Op == OP_1NEGATE:
+		return Int64Bytes(-1), true
+	case inst.Op >= OP_DATA_1 && inst.Op <= OP_DATA_75,
+		inst.Op >= OP_1 && inst.Op <= OP_16,
+		inst.Op == OP_PUSHDATA1,
+		inst.Op == OP_PUSHDATA2,
+		inst.Op == OP_PUSHDATA4:
+		return inst.Data, true
+	}
+	return nil, false
+}
+
+// pushRun returns the exec function of a superinstruction
+// pushing vals. Each push costs 1, plus the memory cost of
+// the item pushed. The costs only ever reduce the run limit,
+// so if the run limit covers them all, no push can fail.
+func pushRun(vals [][]byte) func(*virtualMachine) bool {
+	var cost int64
+	for _, v := range vals {
+		cost += 1 + 8 + int64(len(v))
+	}
+	return func(vm *virtualMachine) bool {
+		if cost > vm.runLimit {
+			return false
+		}
+		vm.runLimit -= cost
+		for _, v := range vals {
+			d := make([]byte, len(v))
+			copy(d, v)
+			vm.dataStack = append(vm.dataStack, d)
+		}
+		return true
+	}
+}
+
+// execStashHash runs DUP TOALTSTACK SHA3: it moves a copy of
+// the top item x to the alt stack and replaces x with its hash.
+// DUP costs 1 and TOALTSTACK 2. The copy's memory cost, charged
+// by DUP, is refunded when SHA3 pops x, and SHA3 charges the
+// larger of 64 and len(x), plus the memory cost of the hash.
+// The run limit is at its lowest after the hash is pushed.
+func execStashHash(vm *virtualMachine) bool {
+	n := len(vm.dataStack)
+	if n == 0 {
+		return false
+	}
+	x := vm.dataStack[n-1]
+	hashCost := int64(len(x))
+	if hashCost < 64 {
+		hashCost = 64
+	}
+	cost := 1 + 2 + hashCost + 8 + 32
+	if cost > vm.runLimit {
+		return false
+	}
+	vm.runLimit -= cost
+	h := sha3.Sum256(x)
+	vm.altStack = append(vm.altStack, x)
+	vm.dataStack[n-1] = h[:]
+	return true
+}
@@ -0,0 +1,240 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/sha3"
+
+	"chain/crypto/ed25519"
+	"chain/errors"
+)
+
+func TestCompile(t *testing.T) {
+	pub := bytes.Repeat([]byte{1}, 32)
+	cases := []struct {
+		prog string
+		want map[uint32]uint32 // pc -> len
+	}{
+		{"1 VERIFY", nil},
+		{"1 2 ADD", map[uint32]uint32{0: 2}},
+		{"DUP TOALTSTACK SHA3", map[uint32]uint32{0: 3}},
+		{"DUP TOALTSTACK SHA256", nil},
+		{"DUP DUP TOALTSTACK SHA3 1", map[uint32]uint32{1: 3}},
+		{
+			"DUP TOALTSTACK SHA3 0x" + hex.EncodeToString(pub) + " 1 1 CHECKMULTISIG VERIFY FROMALTSTACK 0 CHECKPREDICATE",
+			map[uint32]uint32{0: 3, 3: 35},
+		},
+	}
+	for _, c := range cases {
+		prog, err := Assemble(c.prog)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[uint32]uint32)
+		for pc, s := range compile(prog) {
+			got[pc] = s.len
+		}
+		if len(got) == 0 {
+			got = nil
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("compile(%s) = %v, want %v", c.prog, got, c.want)
+		}
+	}
+
+	// A program that fails to parse
+	// is compiled up to the failure.
+	got := compile([]byte{byte(OP_1), byte(OP_1), byte(OP_DATA_4), 0})
+	if len(got) != 1 || got[0] == nil || got[0].len != 2 {
+		t.Errorf("compile(truncated) = %v, want one superinstruction at pc 0", got)
+	}
+}
+
+// TestSuperinstructionsStandard runs the standard control
+// program, with and without superinstructions, under every
+// run limit up to the cost of checking the first signature,
+// beyond which the fused prefix always fits, and a sample
+// of higher run limits.
+func TestSuperinstructionsStandard(t *testing.T) {
+	var (
+		pubs  []ed25519.PublicKey
+		privs []ed25519.PrivateKey
+	)
+	for i := 0; i < 3; i++ {
+		pub, priv, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubs = append(pubs, pub)
+		privs = append(privs, priv)
+	}
+	pred := []byte{byte(OP_TRUE)}
+	predHash := sha3.Sum256(pred)
+
+	cases := []struct {
+		nrequired int
+		npubs     int
+		nsigs     int
+	}{
+		{1, 1, 1},
+		{2, 3, 2},
+		{1, 1, 0}, // too few signatures
+	}
+	for _, c := range cases {
+		src := "DUP TOALTSTACK SHA3"
+		for _, pub := range pubs[:c.npubs] {
+			src += " 0x" + hex.EncodeToString(pub)
+		}
+		src += fmt.Sprintf(" %d %d CHECKMULTISIG VERIFY FROMALTSTACK 0 CHECKPREDICATE", c.nrequired, c.npubs)
+		prog, err := Assemble(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		args := [][]byte{Int64Bytes(0)}
+		for _, priv := range privs[:c.nsigs] {
+			args = append(args, ed25519.Sign(priv, predHash[:]))
+		}
+		args = append(args, pred)
+
+		for limit := int64(0); limit <= 1024; limit++ {
+			checkConformance(t, prog, args, limit)
+		}
+		for limit := int64(1024); limit <= initialRunLimit; limit += 97 {
+			checkConformance(t, prog, args, limit)
+		}
+	}
+}
+
+// TestSuperinstructionsExhaustive runs every short program
+// made from instructions that are fused, and some that are not,
+// with and without superinstructions, under a range of run limits.
+func TestSuperinstructionsExhaustive(t *testing.T) {
+	words := []string{
+		"DUP", "TOALTSTACK", "SHA3", "FROMALTSTACK",
+		"0", "1NEGATE",
+		"0x" + strings.Repeat("ab", 40),
+		"0x" + strings.Repeat("cd", 100), // PUSHDATA1
+		"JUMP:1",
+	}
+	argSets := [][][]byte{
+		nil,
+		{{1}},
+		{bytes.Repeat([]byte{2}, 100)},
+	}
+	limits := []int64{initialRunLimit}
+	for limit := int64(0); limit <= 160; limit++ {
+		limits = append(limits, limit)
+	}
+
+	progs := []string{""}
+	for n := 0; n < 3; n++ {
+		var longer []string
+		for _, p := range progs {
+			for _, w := range words {
+				longer = append(longer, strings.TrimSpace(p+" "+w))
+			}
+		}
+		for _, src := range longer {
+			prog, err := Assemble(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, args := range argSets {
+				for _, limit := range limits {
+					checkConformance(t, prog, args, limit)
+				}
+			}
+		}
+		progs = longer
+	}
+}
+
+// checkConformance runs prog with and without superinstructions
+// and reports any difference in the outcome.
+func checkConformance(t *testing.T, prog []byte, args [][]byte, limit int64) {
+	defer func(s bool, w io.Writer) {
+		Superinstructions = s
+		TraceOut = w
+	}(Superinstructions, TraceOut)
+	TraceOut = nil
+
+	run := func(fused bool) (*virtualMachine, error) {
+		Superinstructions = fused
+		vm := &virtualMachine{
+			program:   prog,
+			mainprog:  prog,
+			runLimit:  limit,
+			dataStack: append([][]byte{}, args...),
+		}
+		return vm, vm.run()
+	}
+	want, wantErr := run(false)
+	got, gotErr := run(true)
+
+	if errors.Root(gotErr) != errors.Root(wantErr) {
+		t.Fatalf("%s limit %d: error %v, want %v", disassemble(prog), limit, gotErr, wantErr)
+	}
+	if got.runLimit != want.runLimit {
+		t.Fatalf("%s limit %d: run limit %d, want %d", disassemble(prog), limit, got.runLimit, want.runLimit)
+	}
+	if got.pc != want.pc {
+		t.Fatalf("%s limit %d: pc %d, want %d", disassemble(prog), limit, got.pc, want.pc)
+	}
+	if !reflect.DeepEqual(got.dataStack, want.dataStack) {
+		t.Fatalf("%s limit %d: data stack %x, want %x", disassemble(prog), limit, got.dataStack, want.dataStack)
+	}
+	if !reflect.DeepEqual(got.altStack, want.altStack) {
+		t.Fatalf("%s limit %d: alt stack %x, want %x", disassemble(prog), limit, got.altStack, want.altStack)
+	}
+}
+
+func disassemble(prog []byte) string {
+	s, err := Disassemble(prog)
+	if err != nil {
+		return hex.EncodeToString(prog)
+	}
+	return s
+}
+
+func BenchmarkStandardProgram(b *testing.B) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	prog, err := Assemble("DUP TOALTSTACK SHA3 0x" + hex.EncodeToString(pub) + " 1 1 CHECKMULTISIG VERIFY FROMALTSTACK 0 CHECKPREDICATE")
+	if err != nil {
+		b.Fatal(err)
+	}
+	pred := []byte{byte(OP_TRUE)}
+	predHash := sha3.Sum256(pred)
+	args := [][]byte{Int64Bytes(0), ed25519.Sign(priv, predHash[:]), pred}
+
+	for _, fused := range []bool{false, true} {
+		b.Run(fmt.Sprintf("superinstructions=%t", fused), func(b *testing.B) {
+			defer func(s bool, w io.Writer) {
+				Superinstructions = s
+				TraceOut = w
+			}(Superinstructions, TraceOut)
+			Superinstructions = fused
+			TraceOut = nil
+			for i := 0; i < b.N; i++ {
+				vm := &virtualMachine{
+					program:   prog,
+					mainprog:  prog,
+					runLimit:  initialRunLimit,
+					dataStack: append([][]byte{}, args...),
+				}
+				err := vm.run()
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -35,11 +35,12 @@ func opCheckOutput(vm *virtualMachine) error {
 	if err != nil {
 		return err
 	}
-	amount, err := vm.popInt64(true)
+	vmAmount, err := vm.popInt64(true)
 	if err != nil {
 		return err
 	}
-	if amount < 0 {
+	amount, err := bc.AmountFromVM(vmAmount)
+	if err != nil {
 		return ErrBadValue
 	}
 	refdatahash, err := vm.pop(true)
@@ -50,16 +51,20 @@ func opCheckOutput(vm *virtualMachine) error {
 	if err != nil {
 		return err
 	}
-	if index < 0 || int64(len(vm.tx.Outputs)) <= index {
+	if index < 0 {
 		return ErrBadValue
 	}
+	err = bc.CheckPosition(uint64(index), len(vm.tx.Outputs))
+	if err != nil {
+		return err
+	}
 
 	o := vm.tx.Outputs[index]
 
 	if o.AssetVersion != 1 {
 		return vm.pushBool(false, true)
 	}
-	if o.Amount != uint64(amount) {
+	if o.Amount != amount {
 		return vm.pushBool(false, true)
 	}
 	if o.VMVersion != uint64(vmVersion) {
@@ -104,8 +109,11 @@ func opAmount(vm *virtualMachine) error {
 		return err
 	}
 
-	amount := vm.tx.Inputs[vm.inputIndex].Amount()
-	return vm.pushInt64(int64(amount), true)
+	amount, err := bc.AmountToVM(vm.tx.Inputs[vm.inputIndex].Amount())
+	if err != nil {
+		return ErrRange
+	}
+	return vm.pushInt64(amount, true)
 }
 
 func opProgram(vm *virtualMachine) error {
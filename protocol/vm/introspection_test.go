@@ -1,8 +1,10 @@
 package vm
 
 import (
+	"math"
 	"testing"
 
+	"chain/errors"
 	"chain/protocol/bc"
 	"chain/testutil"
 )
@@ -362,7 +364,21 @@ func TestIntrospectionOps(t *testing.T) {
 				[]byte("controlprog"),
 			},
 		},
-		wantErr: ErrBadValue,
+		wantErr: bc.ErrPosition,
+	}, {
+		op: OP_CHECKOUTPUT,
+		startVM: &virtualMachine{
+			tx: tx,
+			dataStack: [][]byte{
+				Int64Bytes(math.MaxInt64),
+				mustDecodeHex("1f2a05f881ed9fa0c9068a84823677409f863891a2196eb55dbfbb677a566374"),
+				{7},
+				append([]byte{2}, make([]byte, 31)...),
+				{1},
+				[]byte("controlprog"),
+			},
+		},
+		wantErr: bc.ErrPosition,
 	}, {
 		op: OP_CHECKOUTPUT,
 		startVM: &virtualMachine{
@@ -490,6 +506,21 @@ func TestIntrospectionOps(t *testing.T) {
 		},
 	}}
 
+	// Such a transaction has no valid ID (see bc.NewTx),
+	// but the VM must still reject its amount.
+	bigTx := &bc.Tx{TxData: bc.TxData{
+		Inputs: []*bc.TxInput{
+			bc.NewSpendInput(nil, bc.Hash{}, bc.AssetID{1}, math.MaxInt64+1, 1, []byte("spendprog"), bc.Hash{}, nil),
+		},
+	}}
+	cases = append(cases, testStruct{
+		op: OP_AMOUNT,
+		startVM: &virtualMachine{
+			tx: bigTx,
+		},
+		wantErr: ErrRange,
+	})
+
 	txops := []Op{
 		OP_CHECKOUTPUT, OP_ASSET, OP_AMOUNT, OP_PROGRAM,
 		OP_MINTIME, OP_MAXTIME, OP_TXREFDATAHASH, OP_REFDATAHASH,
@@ -527,7 +558,7 @@ func TestIntrospectionOps(t *testing.T) {
 		}
 		vm.program = prog
 		err := vm.run()
-		switch err {
+		switch errors.Root(err) {
 		case c.wantErr:
 			// ok
 		case nil:
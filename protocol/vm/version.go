@@ -0,0 +1,270 @@
+package vm
+
+import "math"
+
+// Context identifies the kind of data an opcode
+// introspects, and so where it may be used.
+type Context int
+
+const (
+	// ContextAny opcodes may run in any program.
+	ContextAny Context = iota
+
+	// ContextTx opcodes may run only in programs
+	// that control or issue transaction inputs.
+	ContextTx
+
+	// ContextBlock opcodes may run only in
+	// consensus programs.
+	ContextBlock
+)
+
+func (c Context) String() string {
+	switch c {
+	case ContextTx:
+		return "tx"
+	case ContextBlock:
+		return "block"
+	}
+	return "any"
+}
+
+// OpDesc describes one opcode as it behaves
+// in a particular version of the VM.
+type OpDesc struct {
+	Op   Op
+	Name string
+
+	// Expansion is true for the reserved NOPx opcodes.
+	// They fail when expansion opcodes are reserved
+	// (in version 1 transactions and in block headers),
+	// and are otherwise no-ops costing 1.
+	Expansion bool
+
+	// BaseCost is the fixed cost charged to the run limit
+	// whenever the op executes. If VariableCost is true,
+	// the op charges an additional amount that depends
+	// on its arguments. In either case, memory costs for
+	// items pushed and popped apply as described in Limits.
+	BaseCost     int64
+	VariableCost bool
+
+	Context Context
+}
+
+// Limits describes the resource limits
+// of a particular version of the VM.
+type Limits struct {
+	// InitialRunLimit is the run limit a program
+	// starts with.
+	InitialRunLimit int64
+
+	// MaxProgramLen is the length of the longest
+	// program the VM will parse.
+	MaxProgramLen int64
+
+	// StackItemCost is charged for each item pushed
+	// onto a stack, in addition to the item's length
+	// in bytes. The same amount is refunded when the
+	// item is popped.
+	StackItemCost int64
+}
+
+// VersionInfo describes a version of the VM:
+// its opcodes, their costs, and its limits.
+// It is intended for tooling (SDKs, disassemblers,
+// static analyzers) that must track the VM.
+type VersionInfo struct {
+	Version uint64
+	Limits  Limits
+
+	// Ops is indexed by opcode and
+	// has an entry for every byte value.
+	Ops [256]OpDesc
+}
+
+// Introspection returns the opcodes in v
+// that require a tx or block context.
+func (v *VersionInfo) Introspection() []OpDesc {
+	var res []OpDesc
+	for _, d := range v.Ops {
+		if d.Context != ContextAny {
+			res = append(res, d)
+		}
+	}
+	return res
+}
+
+// Version returns a description of VM version v.
+// It returns false if v is not supported.
+func Version(v uint64) (*VersionInfo, bool) {
+	info, ok := versions[v]
+	return info, ok
+}
+
+// Versions returns the supported VM versions, in order.
+func Versions() []uint64 {
+	return append([]uint64(nil), versionList...)
+}
+
+var (
+	versions    = make(map[uint64]*VersionInfo)
+	versionList []uint64
+)
+
+func addVersion(v *VersionInfo) {
+	versions[v.Version] = v
+	versionList = append(versionList, v.Version)
+}
+
+// baseCosts lists the fixed cost of each opcode in VM version 1,
+// and whether it charges more depending on its arguments.
+// It must be kept in sync with the op implementations.
+var baseCosts = map[Op]struct {
+	cost     int64
+	variable bool
+}{
+	OP_FALSE:   {1, false},
+	OP_1NEGATE: {1, false},
+	OP_NOP:     {1, false},
+
+	OP_JUMP:           {1, false},
+	OP_JUMPIF:         {1, false},
+	OP_VERIFY:         {1, false},
+	OP_FAIL:           {1, false},
+	OP_CHECKPREDICATE: {256, true},
+
+	OP_TOALTSTACK:   {2, false},
+	OP_FROMALTSTACK: {2, false},
+	OP_2DROP:        {2, false},
+	OP_2DUP:         {2, false},
+	OP_3DUP:         {3, false},
+	OP_2OVER:        {2, false},
+	OP_2ROT:         {2, false},
+	OP_2SWAP:        {2, false},
+	OP_IFDUP:        {1, false},
+	OP_DEPTH:        {1, false},
+	OP_DROP:         {1, false},
+	OP_DUP:          {1, false},
+	OP_NIP:          {1, false},
+	OP_OVER:         {1, false},
+	OP_PICK:         {2, false},
+	OP_ROLL:         {2, false},
+	OP_ROT:          {2, false},
+	OP_SWAP:         {1, false},
+	OP_TUCK:         {1, false},
+
+	OP_CAT:         {4, true},
+	OP_SUBSTR:      {4, true},
+	OP_LEFT:        {4, true},
+	OP_RIGHT:       {4, true},
+	OP_SIZE:        {1, false},
+	OP_CATPUSHDATA: {4, true},
+
+	OP_INVERT:      {1, true},
+	OP_AND:         {1, true},
+	OP_OR:          {1, true},
+	OP_XOR:         {1, true},
+	OP_EQUAL:       {1, true},
+	OP_EQUALVERIFY: {1, true},
+
+	OP_1ADD:               {2, false},
+	OP_1SUB:               {2, false},
+	OP_2MUL:               {2, false},
+	OP_2DIV:               {2, false},
+	OP_NEGATE:             {2, false},
+	OP_ABS:                {2, false},
+	OP_NOT:                {2, false},
+	OP_0NOTEQUAL:          {2, false},
+	OP_ADD:                {2, false},
+	OP_SUB:                {2, false},
+	OP_MUL:                {8, false},
+	OP_DIV:                {8, false},
+	OP_MOD:                {8, false},
+	OP_LSHIFT:             {8, false},
+	OP_RSHIFT:             {8, false},
+	OP_BOOLAND:            {2, false},
+	OP_BOOLOR:             {2, false},
+	OP_NUMEQUAL:           {2, false},
+	OP_NUMEQUALVERIFY:     {2, false},
+	OP_NUMNOTEQUAL:        {2, false},
+	OP_LESSTHAN:           {2, false},
+	OP_GREATERTHAN:        {2, false},
+	OP_LESSTHANOREQUAL:    {2, false},
+	OP_GREATERTHANOREQUAL: {2, false},
+	OP_MIN:                {2, false},
+	OP_MAX:                {2, false},
+	OP_WITHIN:             {4, false},
+
+	OP_SHA256:        {64, true},
+	OP_SHA3:          {64, true},
+	OP_CHECKSIG:      {1024, false},
+	OP_CHECKMULTISIG: {0, true},
+	OP_TXSIGHASH:     {256, false},
+	OP_BLOCKHASH:     {128, false},
+
+	OP_CHECKOUTPUT:   {16, false},
+	OP_ASSET:         {1, false},
+	OP_AMOUNT:        {1, false},
+	OP_PROGRAM:       {1, false},
+	OP_MINTIME:       {1, false},
+	OP_MAXTIME:       {1, false},
+	OP_TXREFDATAHASH: {1, false},
+	OP_REFDATAHASH:   {1, false},
+	OP_INDEX:         {1, false},
+	OP_OUTPUTID:      {1, false},
+	OP_NONCE:         {1, false},
+	OP_NEXTPROGRAM:   {1, false},
+	OP_BLOCKTIME:     {1, false},
+}
+
+// opContexts lists the introspection opcodes
+// and the context each requires.
+var opContexts = map[Op]Context{
+	OP_TXSIGHASH:     ContextTx,
+	OP_CHECKOUTPUT:   ContextTx,
+	OP_ASSET:         ContextTx,
+	OP_AMOUNT:        ContextTx,
+	OP_PROGRAM:       ContextTx,
+	OP_MINTIME:       ContextTx,
+	OP_MAXTIME:       ContextTx,
+	OP_TXREFDATAHASH: ContextTx,
+	OP_REFDATAHASH:   ContextTx,
+	OP_INDEX:         ContextTx,
+	OP_OUTPUTID:      ContextTx,
+	OP_NONCE:         ContextTx,
+
+	OP_BLOCKHASH:   ContextBlock,
+	OP_NEXTPROGRAM: ContextBlock,
+	OP_BLOCKTIME:   ContextBlock,
+}
+
+func init() {
+	v1 := &VersionInfo{
+		Version: 1,
+		Limits: Limits{
+			InitialRunLimit: initialRunLimit,
+			MaxProgramLen:   math.MaxInt32,
+			StackItemCost:   8,
+		},
+	}
+	for i, info := range ops {
+		d := OpDesc{
+			Op:        info.op,
+			Name:      info.name,
+			Expansion: isExpansion[i],
+		}
+		if c, ok := baseCosts[info.op]; ok {
+			d.BaseCost, d.VariableCost = c.cost, c.variable
+		} else {
+			// Expansion NOPs and the pushdata ops
+			// (including small integers) cost 1.
+			d.BaseCost = 1
+		}
+		if !d.Expansion {
+			d.Context = opContexts[info.op]
+		}
+		v1.Ops[i] = d
+	}
+	addVersion(v1)
+}
@@ -0,0 +1,70 @@
+package vm
+
+import "testing"
+
+func TestVersionContexts(t *testing.T) {
+	v1, ok := Version(1)
+	if !ok {
+		t.Fatal("VM version 1 not registered")
+	}
+	for _, d := range v1.Ops {
+		if d.Expansion {
+			continue
+		}
+		vm := &virtualMachine{runLimit: 50000, data: make([]byte, 4)}
+		err := ops[d.Op].fn(vm)
+		if (err == ErrContext) != (d.Context != ContextAny) {
+			t.Errorf("%s with no context: err = %v, registry context = %s", d.Name, err, d.Context)
+		}
+	}
+}
+
+func TestVersionCosts(t *testing.T) {
+	v1, _ := Version(1)
+	for _, d := range v1.Ops {
+		if d.Expansion || d.VariableCost || d.Context != ContextAny {
+			continue
+		}
+		switch d.Op {
+		case OP_JUMP, OP_JUMPIF, OP_FAIL, OP_CHECKSIG:
+			// These need operands or stack contents
+			// that the generic setup below doesn't provide.
+			continue
+		}
+
+		vm := &virtualMachine{
+			program:  []byte{byte(d.Op)},
+			runLimit: 50000,
+		}
+		switch {
+		case d.Op >= OP_DATA_1 && d.Op <= OP_DATA_75:
+			vm.program = append(vm.program, make([]byte, d.Op)...)
+		case d.Op >= OP_PUSHDATA1 && d.Op <= OP_PUSHDATA4:
+			vm.program = append(vm.program, 0, 0, 0, 0) // zero-length data
+		}
+		for i := 0; i < 10; i++ {
+			vm.dataStack = append(vm.dataStack, []byte{1})
+		}
+		vm.altStack = [][]byte{{1}}
+
+		before := vm.runLimit + stackCost(vm.dataStack) + stackCost(vm.altStack)
+		err := vm.step()
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", d.Name, err)
+			continue
+		}
+		after := vm.runLimit + stackCost(vm.dataStack) + stackCost(vm.altStack)
+		if got := before - after; got != d.BaseCost {
+			t.Errorf("%s: cost = %d, registry base cost = %d", d.Name, got, d.BaseCost)
+		}
+	}
+}
+
+func TestUnsupportedVersion(t *testing.T) {
+	if _, ok := Version(2); ok {
+		t.Error("Version(2) ok = true, want false")
+	}
+	if got := Versions(); len(got) != 1 || got[0] != 1 {
+		t.Errorf("Versions() = %v want [1]", got)
+	}
+}
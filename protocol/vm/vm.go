@@ -66,7 +66,7 @@ func verifyTxInput(tx *bc.Tx, inputIndex uint32) error {
 	expansionReserved := tx.Version == 1
 
 	f := func(vmversion uint64, prog []byte, args [][]byte) error {
-		if vmversion != 1 {
+		if _, ok := versions[vmversion]; !ok {
 			return ErrUnsupportedVM
 		}
 
@@ -144,7 +144,16 @@ func (vm *virtualMachine) falseResult() bool {
 }
 
 func (vm *virtualMachine) run() error {
+	var fused map[uint32]*superinstruction
+	if Superinstructions && TraceOut == nil {
+		fused = compile(vm.program)
+	}
 	for vm.pc = 0; vm.pc < uint32(len(vm.program)); { // handle vm.pc updates in step
+		if s := fused[vm.pc]; s != nil && s.exec(vm) {
+			vm.nextPC = vm.pc + s.len
+			vm.pc = vm.nextPC
+			continue
+		}
 		err := vm.step()
 		if err != nil {
 			return err
@@ -0,0 +1,157 @@
+package vmutil
+
+import (
+	"encoding/binary"
+	"math"
+
+	"chain/crypto/ed25519"
+	"chain/errors"
+	"chain/protocol/vm"
+)
+
+var ErrLockFormat = errors.New("bad lock program format")
+
+// TimeLockProgram returns a program that behaves like
+// P2SPMultiSigProgram(pubkeys, nrequired), except that it can only
+// be satisfied by a transaction whose mintime is at least
+// locktimeMS. The result is: <locktimeMS> MINTIME LESSTHANOREQUAL
+// VERIFY <p2sp multisig program>
+func TimeLockProgram(pubkeys []ed25519.PublicKey, nrequired int, locktimeMS uint64) ([]byte, error) {
+	if locktimeMS > math.MaxInt64 {
+		return nil, errors.WithDetail(ErrBadValue, "lock time too big")
+	}
+	p2sp, err := P2SPMultiSigProgram(pubkeys, nrequired)
+	if err != nil {
+		return nil, err
+	}
+	builder := NewBuilder()
+	builder.AddInt64(int64(locktimeMS))
+	builder.AddOp(vm.OP_MINTIME).AddOp(vm.OP_LESSTHANOREQUAL).AddOp(vm.OP_VERIFY)
+	builder.AddRawBytes(p2sp)
+	return builder.Program, nil
+}
+
+// ParseTimeLockProgram returns the keys, quorum, and lock
+// time of a program made by TimeLockProgram.
+func ParseTimeLockProgram(program []byte) ([]ed25519.PublicKey, int, uint64, error) {
+	pops, err := vm.ParseProgram(program)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if len(pops) < 4 {
+		return nil, 0, 0, vm.ErrShortProgram
+	}
+	if pops[1].Op != vm.OP_MINTIME || pops[2].Op != vm.OP_LESSTHANOREQUAL || pops[3].Op != vm.OP_VERIFY {
+		return nil, 0, 0, errors.Wrap(ErrLockFormat, "no mintime check")
+	}
+	locktime, err := vm.AsInt64(pops[0].Data)
+	if err != nil || locktime < 0 {
+		return nil, 0, 0, errors.Wrap(ErrLockFormat, "parsing lock time")
+	}
+	var n uint32
+	for _, pop := range pops[:4] {
+		n += pop.Len
+	}
+	pubkeys, nrequired, err := ParseP2SPMultiSigProgram(program[n:])
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return pubkeys, nrequired, uint64(locktime), nil
+}
+
+// HashLock describes a hashed time-locked contract: the keys in
+// ClaimKeys may spend its value at any time by revealing a preimage
+// of Hash, and after TimeoutMS the keys in RefundKeys may spend it
+// without one.
+type HashLock struct {
+	// Hash is the SHA-256 hash of the preimage,
+	// as used by hash locks on other blockchains.
+	Hash []byte
+
+	ClaimKeys   []ed25519.PublicKey
+	ClaimQuorum int
+
+	RefundKeys   []ed25519.PublicKey
+	RefundQuorum int
+	TimeoutMS    uint64
+}
+
+// HashLockProgram returns the program for h. Its witness is that of a
+// P2SP multisig program for one set of keys, followed by the preimage
+// and 1 to claim, or by 0 to refund. The result is: JUMPIF:claim
+// <refund time lock program> JUMP:end claim: SHA256 <hash>
+// EQUALVERIFY <claim p2sp multisig program> end:
+func HashLockProgram(h *HashLock) ([]byte, error) {
+	if len(h.Hash) != 32 {
+		return nil, errors.WithDetail(ErrBadValue, "hash must be 32 bytes")
+	}
+	refund, err := TimeLockProgram(h.RefundKeys, h.RefundQuorum, h.TimeoutMS)
+	if err != nil {
+		return nil, err
+	}
+	claim, err := P2SPMultiSigProgram(h.ClaimKeys, h.ClaimQuorum)
+	if err != nil {
+		return nil, err
+	}
+	claim = NewBuilder().
+		AddOp(vm.OP_SHA256).AddData(h.Hash).AddOp(vm.OP_EQUALVERIFY).
+		AddRawBytes(claim).Program
+
+	claimAddr := 5 + len(refund) + 5
+	end := claimAddr + len(claim)
+	builder := NewBuilder()
+	builder.AddOp(vm.OP_JUMPIF).AddRawBytes(jumpAddr(claimAddr))
+	builder.AddRawBytes(refund)
+	builder.AddOp(vm.OP_JUMP).AddRawBytes(jumpAddr(end))
+	builder.AddRawBytes(claim)
+	return builder.Program, nil
+}
+
+// ParseHashLockProgram parses a program made by HashLockProgram.
+func ParseHashLockProgram(program []byte) (*HashLock, error) {
+	first, err := vm.ParseOp(program, 0)
+	if err != nil {
+		return nil, err
+	}
+	if first.Op != vm.OP_JUMPIF {
+		return nil, errors.Wrap(ErrLockFormat, "no branch")
+	}
+	claimAddr := binary.LittleEndian.Uint32(first.Data)
+	if claimAddr < 10 || claimAddr > uint32(len(program)) {
+		return nil, errors.Wrap(ErrLockFormat, "bad claim address")
+	}
+	jump, err := vm.ParseOp(program, claimAddr-5)
+	if err != nil {
+		return nil, err
+	}
+	if jump.Op != vm.OP_JUMP || binary.LittleEndian.Uint32(jump.Data) != uint32(len(program)) {
+		return nil, errors.Wrap(ErrLockFormat, "no jump past the claim path")
+	}
+
+	h := new(HashLock)
+	h.RefundKeys, h.RefundQuorum, h.TimeoutMS, err = ParseTimeLockProgram(program[5 : claimAddr-5])
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing refund path")
+	}
+
+	claim := program[claimAddr:]
+	pops, err := vm.ParseProgram(claim)
+	if err != nil {
+		return nil, err
+	}
+	if len(pops) < 3 || pops[0].Op != vm.OP_SHA256 || len(pops[1].Data) != 32 || pops[2].Op != vm.OP_EQUALVERIFY {
+		return nil, errors.Wrap(ErrLockFormat, "no hash check")
+	}
+	h.Hash = pops[1].Data
+	h.ClaimKeys, h.ClaimQuorum, err = ParseP2SPMultiSigProgram(claim[pops[0].Len+pops[1].Len+pops[2].Len:])
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing claim path")
+	}
+	return h, nil
+}
+
+func jumpAddr(addr int) []byte {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], uint32(addr))
+	return b[:]
+}
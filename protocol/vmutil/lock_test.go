@@ -0,0 +1,71 @@
+package vmutil
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"chain/crypto/ed25519"
+)
+
+func TestTimeLock(t *testing.T) {
+	pub1, _, _ := ed25519.GenerateKey(nil)
+	pub2, _, _ := ed25519.GenerateKey(nil)
+	prog, err := TimeLockProgram([]ed25519.PublicKey{pub1, pub2}, 2, 1491849600000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubs, n, locktime, err := ParseTimeLockProgram(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected nrequired=2, got %d", n)
+	}
+	if locktime != 1491849600000 {
+		t.Errorf("expected locktime=1491849600000, got %d", locktime)
+	}
+	if len(pubs) != 2 || !bytes.Equal(pubs[0], pub1) || !bytes.Equal(pubs[1], pub2) {
+		t.Errorf("expected pubkeys %x, %x, got %x", pub1, pub2, pubs)
+	}
+
+	_, _, _, err = ParseTimeLockProgram(prog[4:])
+	if err == nil {
+		t.Error("ParseTimeLockProgram(truncated) = success want error")
+	}
+}
+
+func TestHashLock(t *testing.T) {
+	pub1, _, _ := ed25519.GenerateKey(nil)
+	pub2, _, _ := ed25519.GenerateKey(nil)
+	pub3, _, _ := ed25519.GenerateKey(nil)
+	want := &HashLock{
+		Hash:         bytes.Repeat([]byte{0xaa}, 32),
+		ClaimKeys:    []ed25519.PublicKey{pub1},
+		ClaimQuorum:  1,
+		RefundKeys:   []ed25519.PublicKey{pub2, pub3},
+		RefundQuorum: 1,
+		TimeoutMS:    1491849600000,
+	}
+	prog, err := HashLockProgram(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseHashLockProgram(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseHashLockProgram(HashLockProgram(%+v)) = %+v", want, got)
+	}
+
+	_, err = ParseHashLockProgram(prog[:len(prog)-1])
+	if err == nil {
+		t.Error("ParseHashLockProgram(truncated) = success want error")
+	}
+
+	_, err = HashLockProgram(&HashLock{Hash: []byte{1}, ClaimKeys: want.ClaimKeys, ClaimQuorum: 1})
+	if err == nil {
+		t.Error("HashLockProgram(short hash) = success want error")
+	}
+}